@@ -0,0 +1,35 @@
+// Command all-in-one runs the migrations, the API and the background
+// collectors in one static binary, for scratch container images. All
+// configuration comes from the environment; a .env file is optional.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"example.com/spotifydb/internal/app"
+
+	// Scratch images ship no zoneinfo; embed it for time.LoadLocation
+	_ "time/tzdata"
+)
+
+func main() {
+	migrateOnly := flag.Bool("migrate-only", false, "apply database migrations and exit")
+	flag.Parse()
+
+	cfg, err := app.LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *migrateOnly {
+		app.Migrate()
+		fmt.Println("✅ Migrations applied")
+		return
+	}
+
+	if err := app.Run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
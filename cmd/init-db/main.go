@@ -5,14 +5,15 @@ import (
 	"fmt"
 	"log"
 
+	"example.com/spotifydb/internal/config"
 	"example.com/spotifydb/internal/repository"
-	"github.com/joho/godotenv"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file:", err)
+	// Environment first, then an optional .env file
+	if err := config.LoadEnv(config.EnvDatabase); err != nil {
+		log.Fatal("❌ ", err)
 	}
 
 	// Initialize database connection
@@ -55,7 +56,7 @@ func createTables() error {
 		album_name TEXT,
 		album_cover_url TEXT,
 		genre TEXT,
-		played_at TIMESTAMP NOT NULL,
+		played_at TIMESTAMPTZ NOT NULL,
 		source VARCHAR(50) DEFAULT 'cron',
 		created_at TIMESTAMP DEFAULT NOW(),
 		UNIQUE(spotify_song_id, played_at)
@@ -86,7 +87,7 @@ func createTables() error {
 		album_cover_width INTEGER,
 		album_cover_height INTEGER,
 		genre TEXT,
-		added_at TIMESTAMP NOT NULL,
+		added_at TIMESTAMPTZ NOT NULL,
 		created_at TIMESTAMP DEFAULT NOW()
 	);`
 
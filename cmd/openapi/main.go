@@ -0,0 +1,20 @@
+// Command openapi prints the OpenAPI spec for the /api/v1 routes, for
+// generating frontend clients without running the server:
+//
+//	go run ./cmd/openapi > openapi.json
+package main
+
+import (
+	"log"
+	"os"
+
+	"example.com/spotifydb/internal/app"
+)
+
+func main() {
+	spec, err := app.OpenAPISpec().JSON()
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(append(spec, '\n'))
+}
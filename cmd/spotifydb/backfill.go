@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/handlers"
+	"example.com/spotifydb/internal/jobs"
+)
+
+// The CLI takes plural names ("backfill genres"); the API's types are singular
+var backfillAliases = map[string]string{
+	"genres":       "genre",
+	"album_covers": "album_cover",
+}
+
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	batchSize := fs.Int("batch-size", 50, "items to process")
+	dryRun := fs.Bool("dry-run", false, "only count what's missing")
+	fs.Usage = func() {
+		kinds := handlers.BackfillTypes()
+		for plural, kind := range backfillAliases {
+			for i := range kinds {
+				if kinds[i] == kind {
+					kinds[i] = plural
+				}
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Usage: spotifydb backfill [flags] <%s>\n", strings.Join(kinds, "|"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	kind := fs.Arg(0)
+	if alias, ok := backfillAliases[kind]; ok {
+		kind = alias
+	}
+	if *batchSize < 1 {
+		log.Fatal("❌ -batch-size must be at least 1")
+	}
+
+	connect(config.EnvSpotify)
+
+	// Run as a job so progress shows up in GET /admin/jobs
+	params := map[string]any{"type": kind, "batch_size": *batchSize, "dry_run": *dryRun}
+	job := jobs.Run("backfill_"+kind, params, func(t *jobs.Task) (any, error) {
+		return handlers.RunBackfill(t, kind, *batchSize, *dryRun)
+	})
+	if job.Status != jobs.StatusSucceeded {
+		log.Fatalf("❌ Backfill job %s %s: %s", job.ID, job.Status, job.Error)
+	}
+
+	result, _ := json.Marshal(job.Result)
+	fmt.Printf("✅ Backfill complete (job %s): %s\n", job.ID, result)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+)
+
+// backupManifest describes a backup directory: one <table>.ndjson.gz per table
+// plus this manifest.json
+type backupManifest struct {
+	Version   int           `json:"version"`
+	CreatedAt time.Time     `json:"created_at"`
+	Tables    []backupTable `json:"tables"`
+}
+
+type backupTable struct {
+	Name    string   `json:"name"`
+	File    string   `json:"file"`
+	Rows    int      `json:"rows"`
+	Columns []string `json:"columns"`
+}
+
+const backupManifestFile = "manifest.json"
+
+// maxBackupRow caps one NDJSON line on restore; reports carry their payload
+const maxBackupRow = 64 << 20
+
+// parseTables reads a comma-separated -tables flag, all backup tables when empty
+func parseTables(flagValue string) []string {
+	if flagValue == "" {
+		return models.BackupTables
+	}
+	var tables []string
+	for _, name := range strings.Split(flagValue, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(models.BackupTables, name) {
+			log.Fatalf("❌ invalid table %q (expected %s)", name, strings.Join(models.BackupTables, ", "))
+		}
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+// runBackup streams every domain table to gzipped NDJSON, independent of
+// pg_dump and the provider's tooling
+func runBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := fs.String("dir", "", "directory to write the backup to (default: spotifydb_backup_<date>)")
+	tablesFlag := fs.String("tables", "", "comma-separated tables to back up (default: all)")
+	fs.Parse(args)
+
+	tables := parseTables(*tablesFlag)
+	if *dir == "" {
+		*dir = "spotifydb_backup_" + time.Now().Format("20060102-150405")
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	connect()
+
+	manifest := backupManifest{Version: 1, CreatedAt: time.Now().UTC()}
+	params := map[string]any{"dir": *dir, "tables": len(tables)}
+	job := jobs.Run("backup", params, func(t *jobs.Task) (any, error) {
+		for i, table := range tables {
+			entry, err := backupTableTo(t.Context(), *dir, table)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Printf("💾 %s: %d rows\n", table, entry.Rows)
+			manifest.Tables = append(manifest.Tables, entry)
+			t.Progress(i+1, len(tables))
+		}
+
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(*dir, backupManifestFile), data, 0o644); err != nil {
+			return nil, err
+		}
+		return manifest.Tables, nil
+	})
+	if job.Status != jobs.StatusSucceeded {
+		log.Fatalf("❌ Backup job %s %s: %s", job.ID, job.Status, job.Error)
+	}
+	fmt.Printf("✅ Backed up %d tables to %s\n", len(manifest.Tables), *dir)
+}
+
+// backupTableTo writes table to <dir>/<table>.ndjson.gz
+func backupTableTo(ctx context.Context, dir, table string) (backupTable, error) {
+	entry := backupTable{Name: table, File: table + ".ndjson.gz"}
+	columns, err := models.TableColumns(ctx, repository.Pool, table)
+	if err != nil {
+		return entry, err
+	}
+	entry.Columns = columns
+
+	f, err := os.Create(filepath.Join(dir, entry.File))
+	if err != nil {
+		return entry, err
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	bw := bufio.NewWriter(zw)
+
+	entry.Rows, err = models.DumpTable(ctx, repository.Pool, table, func(row []byte) error {
+		bw.Write(row)
+		return bw.WriteByte('\n')
+	})
+	if err != nil {
+		return entry, err
+	}
+	if err := bw.Flush(); err != nil {
+		return entry, err
+	}
+	if err := zw.Close(); err != nil {
+		return entry, err
+	}
+	return entry, f.Close()
+}
+
+// runRestore loads a backup directory, upserting on each table's primary key
+// so it can be re-run after a failure or over a database that already has
+// some of the rows
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := fs.String("dir", "", "backup directory written by 'spotifydb backup'")
+	tablesFlag := fs.String("tables", "", "comma-separated tables to restore (default: all in the backup)")
+	batchSize := fs.Int("batch-size", 1000, "rows per insert")
+	fs.Parse(args)
+
+	if *dir == "" {
+		log.Fatal("❌ -dir is required")
+	}
+	if *batchSize < 1 {
+		log.Fatal("❌ -batch-size must be positive")
+	}
+	data, err := os.ReadFile(filepath.Join(*dir, backupManifestFile))
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		log.Fatalf("❌ invalid %s: %v", backupManifestFile, err)
+	}
+	if manifest.Version != 1 {
+		log.Fatalf("❌ unsupported backup version %d", manifest.Version)
+	}
+
+	// Restore in BackupTables order, so referenced rows exist first
+	selected := parseTables(*tablesFlag)
+	var entries []backupTable
+	for _, table := range models.BackupTables {
+		i := slices.IndexFunc(manifest.Tables, func(e backupTable) bool { return e.Name == table })
+		if i >= 0 && slices.Contains(selected, table) {
+			entries = append(entries, manifest.Tables[i])
+		}
+	}
+	connect()
+
+	restored := map[string]int{}
+	params := map[string]any{"dir": *dir, "tables": len(entries), "backup_created_at": manifest.CreatedAt}
+	job := jobs.Run("restore", params, func(t *jobs.Task) (any, error) {
+		for i, entry := range entries {
+			n, err := restoreTableFrom(t.Context(), *dir, entry, *batchSize)
+			if err != nil {
+				return restored, err
+			}
+			fmt.Printf("📥 %s: %d of %d rows written\n", entry.Name, n, entry.Rows)
+			restored[entry.Name] = n
+			t.Progress(i+1, len(entries))
+		}
+		return restored, nil
+	})
+	if job.Status != jobs.StatusSucceeded {
+		log.Fatalf("❌ Restore job %s %s: %s", job.ID, job.Status, job.Error)
+	}
+	fmt.Printf("✅ Restored %d tables from %s (backup of %s)\n", len(entries), *dir, manifest.CreatedAt.Format(time.RFC3339))
+}
+
+// restoreTableFrom upserts <dir>/<file> into its table in batches, then moves
+// the table's sequences past the restored ids
+func restoreTableFrom(ctx context.Context, dir string, entry backupTable, batchSize int) (int, error) {
+	// Only columns both the backup and this database have; the others keep
+	// their defaults (a newer schema) or are dropped (an older one)
+	existing, err := models.TableColumns(ctx, repository.Pool, entry.Name)
+	if err != nil {
+		return 0, err
+	}
+	var columns []string
+	for _, c := range entry.Columns {
+		if slices.Contains(existing, c) {
+			columns = append(columns, c)
+		} else {
+			fmt.Printf("⚠️  %s.%s isn't in this database, skipping it\n", entry.Name, c)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, entry.File))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", entry.File, err)
+	}
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBackupRow)
+
+	written := 0
+	batch := make([]json.RawMessage, 0, batchSize)
+	flush := func() error {
+		n, err := models.RestoreRows(ctx, repository.Pool, entry.Name, columns, batch)
+		written += n
+		batch = batch[:0]
+		return err
+	}
+	for scanner.Scan() {
+		batch = append(batch, json.RawMessage(slices.Clone(scanner.Bytes())))
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return written, fmt.Errorf("%s: %v", entry.File, err)
+	}
+	if err := flush(); err != nil {
+		return written, err
+	}
+	return written, models.ResetSequences(ctx, repository.Pool, entry.Name)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/handlers"
+	"example.com/spotifydb/internal/models"
+)
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "csv", "csv, json, ndjson or parquet")
+	fromFlag := fs.String("from", "", "YYYY-MM-DD, inclusive")
+	toFlag := fs.String("to", "", "YYYY-MM-DD, inclusive")
+	source := fs.String("source", "", "only plays stored by this source ("+strings.Join(models.Sources, ", ")+")")
+	out := fs.String("o", "", "output file (default: recently_played_<date>.<format>)")
+	fs.Parse(args)
+
+	switch *format {
+	case "csv", "json", "ndjson", "parquet":
+	default:
+		log.Fatalf("❌ invalid -format %q (expected csv, json, ndjson or parquet)", *format)
+	}
+	checkSource(*source)
+	from, to := parseExportRange(*fromFlag, *toFlag)
+	connect()
+
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("recently_played_%s.%s", time.Now().Format("20060102"), *format)
+	}
+	written, err := writeExportFile(path, func(w io.Writer) (int, error) {
+		return handlers.WriteHistory(w, *format, from, to, *source)
+	})
+	if err != nil {
+		log.Fatalf("❌ Export failed after %d rows: %v", written, err)
+	}
+	fmt.Printf("✅ Exported %d plays to %s\n", written, path)
+}
+
+// runExportParquet writes recently_played and recently_liked as Parquet
+// files, every column typed, for DuckDB, Pandas and friends
+func runExportParquet(args []string) {
+	fs := flag.NewFlagSet("export-parquet", flag.ExitOnError)
+	fromFlag := fs.String("from", "", "YYYY-MM-DD, inclusive (played_at / added_at)")
+	toFlag := fs.String("to", "", "YYYY-MM-DD, inclusive (played_at / added_at)")
+	source := fs.String("source", "", "only rows stored by this source ("+strings.Join(models.Sources, ", ")+")")
+	dir := fs.String("dir", ".", "directory to write <table>_<date>.parquet to")
+	fs.Parse(args)
+
+	checkSource(*source)
+	from, to := parseExportRange(*fromFlag, *toFlag)
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	connect()
+
+	for _, table := range []models.ExportTable{models.RecentlyPlayedExport, models.RecentlyLikedExport} {
+		path := filepath.Join(*dir, fmt.Sprintf("%s_%s.parquet", table.Name, time.Now().Format("20060102")))
+		written, err := writeExportFile(path, func(w io.Writer) (int, error) {
+			return handlers.WriteParquet(w, table, from, to, *source)
+		})
+		if err != nil {
+			log.Fatalf("❌ Exporting %s failed after %d rows: %v", table.Name, written, err)
+		}
+		fmt.Printf("✅ Exported %d rows of %s to %s\n", written, table.Name, path)
+	}
+}
+
+func checkSource(source string) {
+	if source != "" && !models.IsSource(source) {
+		log.Fatalf("❌ invalid -source %q (expected %s)", source, strings.Join(models.Sources, ", "))
+	}
+}
+
+// parseExportRange reads -from and -to as days in TIMEZONE, like ?from=&to=
+// on GET /export/recently-played
+func parseExportRange(fromFlag, toFlag string) (from, to *time.Time) {
+	if fromFlag != "" {
+		t, err := time.ParseInLocation("2006-01-02", fromFlag, config.Timezone())
+		if err != nil {
+			log.Fatalf("❌ invalid -from %q: expected YYYY-MM-DD", fromFlag)
+		}
+		from = &t
+	}
+	if toFlag != "" {
+		t, err := time.ParseInLocation("2006-01-02", toFlag, config.Timezone())
+		if err != nil {
+			log.Fatalf("❌ invalid -to %q: expected YYYY-MM-DD", toFlag)
+		}
+		endOfDay := t.Add(24*time.Hour - time.Nanosecond)
+		to = &endOfDay
+	}
+	return from, to
+}
+
+// writeExportFile creates path and lets write fill it, returning how many rows it wrote
+func writeExportFile(path string, write func(w io.Writer) (int, error)) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	written, err := write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return written, err
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/utils"
+)
+
+// Imports Spotify's "Download your data" export into recently_played.
+//
+// Two formats are understood:
+//   - StreamingHistory*.json (account data): endTime, artistName, trackName, msPlayed
+//   - endsong*.json / Streaming_History_Audio_*.json (extended history): ts, ms_played,
+//     master_metadata_*, spotify_track_uri
+//
+// Usage:
+//
+//	go run ./cmd/spotifydb import -dir ~/Downloads/my_spotify_data
+//	go run ./cmd/spotifydb import -dry-run StreamingHistory0.json
+//
+// Imported plays are tagged with the gdpr_export source.
+
+// play is one entry normalized from either export format
+type play struct {
+	TrackID    string
+	TrackName  string
+	ArtistName string
+	AlbumName  string
+	MsPlayed   int
+	PlayedAt   time.Time
+}
+
+// streamingHistoryEntry is the basic account-data format
+type streamingHistoryEntry struct {
+	EndTime    string `json:"endTime"`
+	ArtistName string `json:"artistName"`
+	TrackName  string `json:"trackName"`
+	MsPlayed   int    `json:"msPlayed"`
+}
+
+// extendedHistoryEntry is the extended streaming history format
+type extendedHistoryEntry struct {
+	Ts         string  `json:"ts"`
+	MsPlayed   int     `json:"ms_played"`
+	TrackName  *string `json:"master_metadata_track_name"`
+	ArtistName *string `json:"master_metadata_album_artist_name"`
+	AlbumName  *string `json:"master_metadata_album_album_name"`
+	TrackURI   *string `json:"spotify_track_uri"`
+}
+
+type importStats struct {
+	read, inserted, duplicates, tooShort, unresolved, failed int
+}
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := fs.String("dir", "", "directory containing the export's JSON files")
+	minMs := fs.Int("min-ms", 30000, "skip plays shorter than this (Spotify counts a stream at 30s)")
+	dedupWindow := fs.Duration("dedup-window", 90*time.Second, "treat an existing play of the same track within this window as a duplicate")
+	resolve := fs.Bool("resolve", true, "look up missing track IDs via the Spotify search API")
+	dryRun := fs.Bool("dry-run", false, "parse and resolve but don't write to the database")
+	fs.Parse(args)
+
+	files := fs.Args()
+	if *dir != "" {
+		for _, pattern := range []string{"StreamingHistory*.json", "endsong*.json", "Streaming_History_Audio_*.json"} {
+			matches, _ := filepath.Glob(filepath.Join(*dir, pattern))
+			files = append(files, matches...)
+		}
+	}
+	if len(files) == 0 {
+		log.Fatal("❌ No export files given. Pass -dir or file paths.")
+	}
+
+	var accessToken string
+	if *resolve {
+		connect(config.EnvSpotify)
+		accessToken = spotifyAccessToken()
+	} else {
+		connect()
+	}
+
+	resolver := &trackResolver{
+		accessToken: accessToken,
+		enabled:     *resolve,
+		rateLimiter: utils.NewRateLimiter(),
+		cache:       map[string]*services.TrackDetails{},
+	}
+
+	// Run as a job so progress shows up in GET /admin/jobs
+	var stats importStats
+	params := map[string]any{"files": len(files), "dry_run": *dryRun, "resolve": *resolve}
+	job := jobs.Run("import_history", params, func(t *jobs.Task) (any, error) {
+		for i, file := range files {
+			plays, err := readExportFile(file)
+			if err != nil {
+				fmt.Printf("❌ %s: %v\n", file, err)
+				continue
+			}
+			fmt.Printf("📄 %s: %d plays\n", filepath.Base(file), len(plays))
+
+			for j, p := range plays {
+				stats.read++
+				importPlay(p, resolver, *minMs, *dedupWindow, *dryRun, &stats)
+
+				if stats.read%500 == 0 {
+					fmt.Printf("🔄 Processed %d plays (inserted: %d, duplicates: %d, unresolved: %d)\n",
+						stats.read, stats.inserted, stats.duplicates, stats.unresolved)
+					// Files are read one at a time, so progress is per file plus the fraction of this one
+					t.Progress(i*len(plays)+j, len(files)*len(plays))
+				}
+			}
+			t.Progress(i+1, len(files))
+		}
+		return map[string]int{
+			"read":       stats.read,
+			"inserted":   stats.inserted,
+			"duplicates": stats.duplicates,
+			"too_short":  stats.tooShort,
+			"unresolved": stats.unresolved,
+			"failed":     stats.failed,
+		}, nil
+	})
+	if job.Status != jobs.StatusSucceeded {
+		log.Fatalf("❌ Import job %s %s: %s", job.ID, job.Status, job.Error)
+	}
+
+	fmt.Println("\n✅ Import complete!")
+	fmt.Printf("📊 Plays read: %d\n", stats.read)
+	fmt.Printf("💾 Inserted: %d\n", stats.inserted)
+	fmt.Printf("🔁 Duplicates skipped: %d\n", stats.duplicates)
+	fmt.Printf("⏩ Shorter than %dms: %d\n", *minMs, stats.tooShort)
+	fmt.Printf("❓ Unresolved track IDs: %d\n", stats.unresolved)
+	fmt.Printf("❌ Failed: %d\n", stats.failed)
+	if *dryRun {
+		fmt.Println("🧪 Dry run — nothing was written")
+	}
+}
+
+func importPlay(p play, resolver *trackResolver, minMs int, dedupWindow time.Duration, dryRun bool, stats *importStats) {
+	if p.MsPlayed < minMs {
+		stats.tooShort++
+		return
+	}
+
+	albumCoverURL := ""
+	if p.TrackID == "" {
+		track := resolver.resolve(p.TrackName, p.ArtistName)
+		if track == nil {
+			stats.unresolved++
+			return
+		}
+		p.TrackID = track.ID
+		if p.AlbumName == "" {
+			p.AlbumName = track.Album.Name
+		}
+		if len(track.Album.Images) > 0 {
+			albumCoverURL = track.Album.Images[0].URL
+		}
+	}
+
+	exists, err := repository.HasPlayNear(p.TrackID, p.PlayedAt, dedupWindow)
+	if err != nil {
+		fmt.Printf("❌ Dedup check failed for %s: %v\n", p.TrackName, err)
+		stats.failed++
+		return
+	}
+	if exists {
+		stats.duplicates++
+		return
+	}
+
+	if dryRun {
+		stats.inserted++
+		return
+	}
+
+	// The export only records how long the track played, so use that as the duration
+	inserted, err := models.InsertRecentlyPlayed(context.Background(), models.RecentlyPlayedTrack{
+		SpotifySongID: p.TrackID,
+		TrackName:     p.TrackName,
+		ArtistName:    p.ArtistName,
+		AlbumName:     p.AlbumName,
+		AlbumCoverUrl: albumCoverURL,
+		DurationMS:    p.MsPlayed,
+		PlayedAt:      p.PlayedAt,
+		Source:        models.SourceGDPRExport,
+	})
+	if err != nil {
+		fmt.Printf("❌ Insert error for %s: %v\n", p.TrackName, err)
+		stats.failed++
+		return
+	}
+	if !inserted {
+		// stored since the check above, or archived
+		stats.duplicates++
+		return
+	}
+	stats.inserted++
+}
+
+// readExportFile detects the export format from the first entry and normalizes it
+func readExportFile(path string) ([]play, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var probe []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("not a JSON array: %v", err)
+	}
+	if len(probe) == 0 {
+		return nil, nil
+	}
+
+	if _, ok := probe[0]["ts"]; ok {
+		var entries []extendedHistoryEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+		return fromExtendedHistory(entries), nil
+	}
+
+	var entries []streamingHistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return fromStreamingHistory(entries), nil
+}
+
+func fromStreamingHistory(entries []streamingHistoryEntry) []play {
+	plays := make([]play, 0, len(entries))
+	for _, e := range entries {
+		// endTime is UTC with minute precision, e.g. "2023-01-05 14:32"
+		playedAt, err := time.Parse("2006-01-02 15:04", e.EndTime)
+		if err != nil || e.TrackName == "" {
+			continue
+		}
+		plays = append(plays, play{
+			TrackName:  e.TrackName,
+			ArtistName: e.ArtistName,
+			MsPlayed:   e.MsPlayed,
+			PlayedAt:   playedAt,
+		})
+	}
+	return plays
+}
+
+func fromExtendedHistory(entries []extendedHistoryEntry) []play {
+	plays := make([]play, 0, len(entries))
+	for _, e := range entries {
+		// Podcast episodes have no track metadata
+		if e.TrackName == nil || *e.TrackName == "" {
+			continue
+		}
+		playedAt, err := time.Parse(time.RFC3339, e.Ts)
+		if err != nil {
+			continue
+		}
+		p := play{
+			TrackName: *e.TrackName,
+			MsPlayed:  e.MsPlayed,
+			PlayedAt:  playedAt,
+		}
+		if e.ArtistName != nil {
+			p.ArtistName = *e.ArtistName
+		}
+		if e.AlbumName != nil {
+			p.AlbumName = *e.AlbumName
+		}
+		if e.TrackURI != nil {
+			p.TrackID = strings.TrimPrefix(*e.TrackURI, "spotify:track:")
+		}
+		plays = append(plays, p)
+	}
+	return plays
+}
+
+// trackResolver maps (track, artist) names to Spotify tracks via search, caching results
+type trackResolver struct {
+	accessToken string
+	enabled     bool
+	rateLimiter *utils.RateLimiter
+	cache       map[string]*services.TrackDetails
+}
+
+func (r *trackResolver) resolve(trackName, artistName string) *services.TrackDetails {
+	if !r.enabled {
+		return nil
+	}
+
+	key := strings.ToLower(trackName + "\x00" + artistName)
+	if track, ok := r.cache[key]; ok {
+		return track
+	}
+
+	query := fmt.Sprintf("track:%q artist:%q", trackName, artistName)
+	var results []services.TrackDetails
+	err := r.rateLimiter.RetryWithBackoff(func() error {
+		var err error
+		results, err = services.SearchTracks(context.Background(), r.accessToken, query, 1)
+		return err
+	}, 3)
+	if err != nil {
+		fmt.Printf("⚠️  Search failed for %s - %s: %v\n", artistName, trackName, err)
+		return nil // don't cache failures so a later entry can retry
+	}
+
+	var track *services.TrackDetails
+	if len(results) > 0 {
+		track = &results[0]
+	}
+	r.cache[key] = track
+	return track
+}
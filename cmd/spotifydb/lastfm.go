@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/lastfm"
+)
+
+// runLastFMAuth walks through Last.fm's desktop auth flow: it prints a URL
+// to approve the app at, then exchanges the approved token for the session
+// key the server scrobbles with
+func runLastFMAuth(args []string) {
+	fs := flag.NewFlagSet("lastfm-auth", flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := config.LoadEnv([]string{"LASTFM_API_KEY", "LASTFM_API_SECRET"}); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	client := lastfm.New(os.Getenv("LASTFM_API_KEY"), os.Getenv("LASTFM_API_SECRET"), "")
+
+	token, err := client.GetToken()
+	if err != nil {
+		log.Fatal("❌ Failed to get a Last.fm token: ", err)
+	}
+	fmt.Printf("Open this URL and allow access:\n\n  %s\n\nThen press Enter.", client.AuthURL(token))
+	bufio.NewReader(os.Stdin).ReadString('\n')
+
+	user, key, err := client.GetSession(token)
+	if err != nil {
+		log.Fatal("❌ Failed to get a Last.fm session (was access allowed?): ", err)
+	}
+	fmt.Printf("\n✅ Authorized as %s. Add this to your environment:\n\nLASTFM_SESSION_KEY=%s\n", user, key)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/handlers"
+	"example.com/spotifydb/internal/jobs"
+)
+
+// runListenBrainzBackfill exports the whole listening history to
+// ListenBrainz, skipping listens it already has
+func runListenBrainzBackfill(args []string) {
+	fs := flag.NewFlagSet("listenbrainz-backfill", flag.ExitOnError)
+	batchSize := fs.Int("batch-size", 500, "listens per request (at most 1000)")
+	dryRun := fs.Bool("dry-run", false, "only count the plays not sent yet")
+	fs.Parse(args)
+	if *batchSize < 1 || *batchSize > 1000 {
+		log.Fatal("❌ -batch-size must be between 1 and 1000")
+	}
+
+	connect([]string{"LISTENBRAINZ_TOKEN"})
+	cfg, err := config.LoadScrobbleConfig()
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	handlers.SetScrobbling(cfg)
+
+	// Run as a job so progress shows up in GET /admin/jobs
+	params := map[string]any{"batch_size": *batchSize, "dry_run": *dryRun}
+	job := jobs.Run("listenbrainz_backfill", params, func(t *jobs.Task) (any, error) {
+		return handlers.RunListenBrainzBackfill(t, *batchSize, *dryRun)
+	})
+	if job.Status != jobs.StatusSucceeded {
+		log.Fatalf("❌ ListenBrainz backfill job %s %s: %s", job.ID, job.Status, job.Error)
+	}
+
+	result, _ := json.Marshal(job.Result)
+	fmt.Printf("✅ ListenBrainz backfill complete (job %s): %s\n", job.ID, result)
+}
@@ -0,0 +1,121 @@
+// Command spotifydb is the maintenance CLI: one-off recoveries, backfills,
+// imports, exports, backups and migrations against the same database and Spotify
+// account as the server.
+//
+// Usage:
+//
+//	go run ./cmd/spotifydb recover [-safe] [-since 2024-06-21] [-restart] [-workers 4]
+//	go run ./cmd/spotifydb backfill [-batch-size 50] [-dry-run] genres|album_covers|audio_features|musicbrainz
+//	go run ./cmd/spotifydb import [-dir ~/Downloads/my_spotify_data] [-dry-run] [files...]
+//	go run ./cmd/spotifydb export [-format csv] [-from 2024-01-01] [-to 2024-12-31] [-source cron] [-o file]
+//	go run ./cmd/spotifydb export-parquet [-from 2024-01-01] [-to 2024-12-31] [-source cron] [-dir out]
+//	go run ./cmd/spotifydb backup [-dir dir] [-tables recently_played,recently_liked]
+//	go run ./cmd/spotifydb restore -dir dir [-tables ...] [-batch-size 1000]
+//	go run ./cmd/spotifydb migrate
+//	go run ./cmd/spotifydb lastfm-auth
+//	go run ./cmd/spotifydb listenbrainz-backfill [-batch-size 500] [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"example.com/spotifydb/internal/app"
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+)
+
+// command is one subcommand; run gets the arguments after its name
+type command struct {
+	summary string
+	run     func(args []string)
+}
+
+var commands = map[string]command{
+	"recover":               {"re-collect recent plays and every saved track from Spotify", runRecover},
+	"backfill":              {"fill missing genres, album covers or audio features", runBackfill},
+	"import":                {"import Spotify's \"Download your data\" export into recently_played", runImport},
+	"export":                {"write the listening history to a csv, json, ndjson or parquet file", runExport},
+	"export-parquet":        {"write recently_played and recently_liked as Parquet files", runExportParquet},
+	"backup":                {"write every domain table to gzipped NDJSON files", runBackup},
+	"restore":               {"upsert a backup into the database", runRestore},
+	"migrate":               {"apply database migrations and exit", runMigrate},
+	"lastfm-auth":           {"authorize scrobbling and print a LASTFM_SESSION_KEY", runLastFMAuth},
+	"listenbrainz-backfill": {"submit every play ListenBrainz doesn't have yet", runListenBrainzBackfill},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "help" && os.Args[1] != "-h" && os.Args[1] != "--help" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+	cmd.run(os.Args[2:])
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: spotifydb <command> [flags]\n\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-21s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'spotifydb <command> -h' for a command's flags.")
+}
+
+// runMigrate applies the schema, as every command does on connecting, and
+// marks jobs a crashed server left running as failed
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := config.LoadEnv(config.EnvDatabase); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	app.Migrate()
+	fmt.Println("✅ Migrations applied")
+}
+
+// connect loads the environment (variables already set win over .env),
+// checks the required groups are present and opens the database
+func connect(required ...[]string) {
+	if err := config.LoadEnv(append([][]string{config.EnvDatabase}, required...)...); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	repository.InitDB()
+}
+
+// spotifyAccessToken exchanges the stored refresh token for an access token
+// and keeps it fresh for runs that outlive it
+func spotifyAccessToken() string {
+	refreshToken, err := repository.GetRefreshToken()
+	if err != nil || refreshToken == "" {
+		log.Fatal("❌ No refresh token found. Please authenticate first using your web app.")
+	}
+	accessToken, newRefresh, err := services.RefreshAccessToken(context.Background(), refreshToken)
+	if err != nil {
+		log.Fatal("❌ Failed to refresh access token: ", err)
+	}
+	if newRefresh != nil && *newRefresh != refreshToken {
+		repository.SaveOrUpdateRefreshToken(*newRefresh)
+	}
+	// Long runs can outlive the access token; refresh on 401 and retry
+	services.SetTokenRefresher(services.StoredTokenRefresher(
+		repository.GetRefreshToken, repository.SaveOrUpdateRefreshToken))
+	return accessToken
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/utils"
+)
+
+// recoverOptions tunes how hard recover leans on the Spotify API
+type recoverOptions struct {
+	since     time.Time
+	restart   bool
+	pageSize  int           // saved tracks per page
+	workers   int           // saved-track pages fetched at once
+	pageDelay time.Duration // pause between pages
+	retries   int           // retries per request, with backoff
+	maxErrors int           // failed pages in a row before giving up
+}
+
+// recoverCheckpoint is where the saved-tracks walk resumes from
+const recoverCheckpoint = "recovery:recently_liked"
+
+func runRecover(args []string) {
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	safe := fs.Bool("safe", false, "smaller pages, longer pauses and retries, to stay clear of rate limits")
+	since := fs.String("since", "2024-06-21", "YYYY-MM-DD; saved tracks added since then are counted as recovered")
+	restart := fs.Bool("restart", false, "ignore the saved-tracks checkpoint and start from the first page")
+	workers := fs.Int("workers", 0, "saved-track pages fetched at once, 1-4 (default 4, or 2 with -safe)")
+	fs.Parse(args)
+
+	opts := recoverOptions{restart: *restart, pageSize: 50, workers: 4, pageDelay: 300 * time.Millisecond, maxErrors: 1}
+	if *safe {
+		opts.pageSize, opts.workers, opts.pageDelay, opts.retries, opts.maxErrors = 20, 2, 2*time.Second, 3, 5
+	}
+	if *workers != 0 {
+		if *workers < 1 || *workers > 4 {
+			log.Fatalf("❌ invalid -workers %d: expected 1-4", *workers)
+		}
+		opts.workers = *workers
+	}
+	var err error
+	if opts.since, err = time.ParseInLocation("2006-01-02", *since, time.UTC); err != nil {
+		log.Fatalf("❌ invalid -since %q: expected YYYY-MM-DD", *since)
+	}
+
+	connect(config.EnvSpotify)
+	accessToken := spotifyAccessToken()
+	rateLimiter := utils.NewRateLimiter()
+
+	fmt.Printf("🔄 Starting data recovery (safe: %v)\n", *safe)
+	fmt.Printf("📅 Recovery period: %s to %s\n", opts.since.Format("2006-01-02"), time.Now().Format("2006-01-02"))
+
+	// Run as a job so progress shows up in GET /admin/jobs
+	jobType := "recovery"
+	if *safe {
+		jobType = "recovery_safe"
+	}
+	params := map[string]string{"since": opts.since.Format("2006-01-02")}
+	job := jobs.Run(jobType, params, func(t *jobs.Task) (any, error) {
+		fmt.Println("\n🎵 Starting recently played recovery...")
+		recoverRecentlyPlayed(accessToken, rateLimiter, opts)
+
+		fmt.Println("\n💚 Starting recently liked recovery...")
+		recoverRecentlyLiked(t, accessToken, rateLimiter, opts)
+		return nil, nil
+	})
+	if job.Status != jobs.StatusSucceeded {
+		log.Fatalf("❌ Recovery job %s %s: %s", job.ID, job.Status, job.Error)
+	}
+
+	fmt.Printf("\n✅ Recovery complete! (job %s)\n", job.ID)
+}
+
+// recoverRecentlyPlayed stores what Spotify still has of recently played,
+// which is only the last 50 plays
+func recoverRecentlyPlayed(accessToken string, rateLimiter *utils.RateLimiter, opts recoverOptions) {
+	fmt.Println("⚠️  Note: Spotify's recently played API only stores ~50 recent tracks.")
+
+	var items []services.PlayedItem
+	err := rateLimiter.RetryWithBackoff(func() error {
+		var fetchErr error
+		items, fetchErr = services.GetRecentlyPlayed(context.Background(), accessToken, 50)
+		return fetchErr
+	}, opts.retries)
+	if err != nil {
+		fmt.Printf("❌ Error fetching recently played: %v\n", err)
+		return
+	}
+	if len(items) == 0 {
+		fmt.Println("📭 No recently played tracks found")
+		return
+	}
+
+	success := 0
+	for _, item := range items {
+		artist, genre, albumCoverURL := "", "", ""
+		if len(item.Track.Artists) > 0 {
+			artistID := item.Track.Artists[0].ID
+			var artistObj *services.Artist
+			err := rateLimiter.RetryWithBackoff(func() error {
+				var fetchErr error
+				artistObj, fetchErr = services.GetArtistById(context.Background(), accessToken, artistID)
+				return fetchErr
+			}, opts.retries)
+			if err != nil {
+				fmt.Printf("⚠️  Failed to fetch artist %s: %v\n", artistID, err)
+			} else if artistObj != nil {
+				artist = artistObj.Name
+				genre = strings.Join(artistObj.Genres, ", ")
+			}
+		}
+		if len(item.Track.Album.Images) > 0 {
+			albumCoverURL = item.Track.Album.Images[0].URL
+		}
+
+		inserted, err := models.InsertRecentlyPlayed(context.Background(), models.RecentlyPlayedTrack{
+			SpotifySongID: item.Track.ID,
+			TrackName:     item.Track.Name,
+			ArtistName:    artist,
+			AlbumName:     item.Track.Album.Name,
+			AlbumCoverUrl: albumCoverURL,
+			Genre:         genre,
+			DurationMS:    item.Track.DurationMs,
+			PlayedAt:      item.PlayedAt,
+			Source:        models.SourceRecovery,
+		})
+		if err != nil {
+			fmt.Printf("❌ Insert error for %s: %v\n", item.Track.Name, err)
+			continue
+		}
+		if !inserted {
+			continue // already stored
+		}
+		credited := make([]models.PlayArtist, 0, len(item.Track.Artists))
+		for _, a := range item.Track.Artists {
+			credited = append(credited, models.PlayArtist{ID: a.ID, Name: a.Name})
+		}
+		if err := models.SetPlayArtists(item.Track.ID, item.PlayedAt, credited, artist); err != nil {
+			fmt.Printf("⚠️  %s: %v\n", item.Track.Name, err)
+		}
+		success++
+	}
+
+	fmt.Printf("✅ Recovered %d recently played tracks\n", success)
+	fmt.Printf("📊 Date range: %s to %s\n",
+		items[len(items)-1].PlayedAt.Format("2006-01-02 15:04"),
+		items[0].PlayedAt.Format("2006-01-02 15:04"))
+}
+
+// savedPage is one fetched page of saved tracks
+type savedPage struct {
+	offset int
+	page   *services.UserSavedTracks
+	err    error
+}
+
+// fetchSavedPage fetches the page at offset, retrying with backoff and, after
+// a failure, waiting 30 seconds before each of up to maxErrors attempts
+func fetchSavedPage(accessToken string, rateLimiter *utils.RateLimiter, opts recoverOptions, offset int) savedPage {
+	for attempt := 1; ; attempt++ {
+		var page *services.UserSavedTracks
+		err := rateLimiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			page, fetchErr = services.GetUserSavedTracksPage(context.Background(), accessToken, offset, opts.pageSize)
+			return fetchErr
+		}, opts.retries)
+		if err == nil || attempt >= opts.maxErrors {
+			return savedPage{offset: offset, page: page, err: err}
+		}
+		fmt.Printf("❌ Failed to fetch page at offset %d (attempt %d): %v\n", offset, attempt, err)
+		fmt.Println("⏳ Waiting 30 seconds before retrying...")
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// recoverRecentlyLiked walks every saved track, newest first. The first page
+// gives the total; the rest are fetched by opts.workers goroutines sharing the
+// Spotify request budget, and stored in order so the checkpoint saved after
+// each page always marks a point everything before is stored.
+func recoverRecentlyLiked(t *jobs.Task, accessToken string, rateLimiter *utils.RateLimiter, opts recoverOptions) {
+	fmt.Println("🔍 Fetching all saved/liked tracks from Spotify...")
+
+	stats := likedStats{}
+	offset := 0
+	// Back up a page on resume in case tracks were unliked since; re-saving
+	// the overlap is harmless
+	if opts.restart {
+		if err := jobs.ClearCheckpoint(recoverCheckpoint); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	} else if cp, err := jobs.LoadCheckpoint(recoverCheckpoint); err != nil {
+		fmt.Printf("⚠️  %v; starting from the first page\n", err)
+	} else if cp != nil {
+		offset = max(cp.Offset-opts.pageSize, 0)
+		fmt.Printf("⏩ Resuming from offset %d (checkpoint saved %s, -restart to start over)\n",
+			offset, cp.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	pace := jobs.NewThroughput()
+	completed := false
+
+	// store saves one page and the checkpoint after it; false stops the walk
+	store := func(r savedPage) bool {
+		if r.err != nil {
+			fmt.Printf("❌ Giving up at offset %d: %v\n", r.offset, r.err)
+			return false
+		}
+		if len(r.page.Items) == 0 {
+			completed = true
+			return false
+		}
+		fmt.Printf("📄 Processing page %d (offset %d, %d tracks)...\n", r.offset/opts.pageSize+1, r.offset, len(r.page.Items))
+		lastAddedAt, err := stats.add(r.page.Items, opts.since)
+		if err != nil {
+			fmt.Printf("❌ Insert error at offset %d: %v\n", r.offset, err)
+			return false
+		}
+
+		offset = r.offset + opts.pageSize
+		t.Progress(offset, r.page.Total)
+		pace.Page()
+		if err := jobs.SaveCheckpoint(recoverCheckpoint, offset, lastAddedAt); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+		if remaining := r.page.Total - offset; remaining > 0 {
+			fmt.Printf("⏱️  %d/%d saved tracks, about %s left\n",
+				offset, r.page.Total, pace.ETA((remaining+opts.pageSize-1)/opts.pageSize))
+		} else {
+			completed = true
+			return false
+		}
+		return !t.Canceled()
+	}
+
+	first := fetchSavedPage(accessToken, rateLimiter, opts, offset)
+	if store(first) {
+		walkSavedPages(accessToken, rateLimiter, opts, offset, first.page.Total, store)
+	}
+
+	// A finished run starts from the top next time; an interrupted one resumes
+	if completed {
+		if err := jobs.ClearCheckpoint(recoverCheckpoint); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	} else {
+		fmt.Printf("💾 Stopped at offset %d; run again to resume from there\n", offset)
+	}
+
+	fmt.Printf("📊 Total tracks processed: %d\n", stats.total)
+	fmt.Printf("💾 Successfully saved: %d\n", stats.success)
+	fmt.Printf("♻️  Already stored: %d\n", stats.existing)
+	fmt.Printf("📅 Added since %s: %d\n", opts.since.Format("2006-01-02"), stats.fromPeriod)
+	if stats.success > 0 {
+		fmt.Printf("📊 Date range saved: %s to %s\n",
+			stats.oldest.Format("2006-01-02 15:04"),
+			stats.newest.Format("2006-01-02 15:04"))
+	}
+}
+
+// walkSavedPages fetches the pages after from concurrently and hands them to
+// store in offset order until it returns false. At most two pages per worker
+// are in flight or waiting, so one slow page doesn't let the rest pile up.
+func walkSavedPages(accessToken string, rateLimiter *utils.RateLimiter, opts recoverOptions,
+	from, total int, store func(savedPage) bool) {
+	offsets := make(chan int)
+	results := make(chan savedPage)
+	window := make(chan struct{}, 2*opts.workers)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(offsets)
+		for off := from + opts.pageSize; off < total; off += opts.pageSize {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case offsets <- off:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for off := range offsets {
+				r := fetchSavedPage(accessToken, rateLimiter, opts, off)
+				select {
+				case results <- r:
+				case <-done:
+					return
+				}
+				time.Sleep(opts.pageDelay)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := map[int]savedPage{}
+	next := from + opts.pageSize
+	for r := range results {
+		pending[r.offset] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			<-window
+			if !store(r) {
+				return
+			}
+			next += opts.pageSize
+		}
+	}
+}
+
+// likedStats counts what recover did with saved tracks
+type likedStats struct {
+	success, existing, total, fromPeriod int
+	oldest, newest                       time.Time
+}
+
+// add stores one page of saved tracks in a single batch and returns the
+// added_at of its last track
+func (s *likedStats) add(items []services.UserSavedItems, since time.Time) (lastAddedAt time.Time, err error) {
+	rows := make([]models.RecentlyLikedRow, 0, len(items))
+	for _, item := range items {
+		s.total++
+		addedAt, err := time.Parse(time.RFC3339, item.AddedAt)
+		if err != nil {
+			continue
+		}
+		lastAddedAt = addedAt
+		if !addedAt.Before(since) {
+			s.fromPeriod++
+		}
+
+		track := item.Track
+		if len(track.Artists) == 0 || len(track.Album.Images) == 0 {
+			continue
+		}
+		artist := track.Artists[0]
+		album := track.Album
+		image := album.Images[0]
+		rows = append(rows, models.RecentlyLikedRow{
+			Source:                    models.SourceRecovery,
+			SpotifyID:                 track.ID,
+			TrackName:                 track.Name,
+			TrackPopularity:           fmt.Sprintf("%d", track.Popularity),
+			AlbumName:                 album.Name,
+			AlbumType:                 album.AlbumType,
+			AlbumCoverURL:             image.URL,
+			AlbumReleaseDate:          album.ReleaseDate,
+			AlbumReleaseDatePrecision: album.ReleaseDatePrecision,
+			ArtistName:                artist.Name,
+			ArtistID:                  artist.ID,
+			ArtistHref:                artist.Href,
+			ArtistURI:                 artist.URI,
+			AlbumTotalTracks:          album.TotalTracks,
+			AlbumCoverWidth:           image.Width,
+			AlbumCoverHeight:          image.Height,
+			AddedAt:                   addedAt,
+			Metadata:                  track.TrackMetadata,
+		})
+	}
+
+	inserted, err := models.InsertRecentlyLikedBatch(rows)
+	if err != nil {
+		return lastAddedAt, err
+	}
+	for i, row := range rows {
+		if !inserted[i] {
+			s.existing++
+			continue
+		}
+		s.success++
+		if s.success == 1 {
+			s.newest = row.AddedAt
+		}
+		s.oldest = row.AddedAt
+	}
+	return lastAddedAt, nil
+}
@@ -0,0 +1,221 @@
+// Package app wires the HTTP API, the background collectors and the schema
+// migrations into one process. cmd/server and cmd/all-in-one both run it.
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/handlers"
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/ulule/limiter/v3"
+	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+// Run modes, from RUN_MODE. Scaling out runs any number of ModeServer
+// replicas and exactly one ModeCollector, so Spotify is polled only once.
+const (
+	ModeAll       = "all"       // API and collectors (default)
+	ModeServer    = "server"    // API only, stateless
+	ModeCollector = "collector" // collectors, with only health checks and metrics served
+)
+
+// Config is everything the process reads from the environment
+type Config struct {
+	Addr     string // listen address, ":" + PORT (default :8080)
+	Mode     string // ModeAll, ModeServer or ModeCollector
+	HTTP     config.HTTPConfig
+	Cron     config.CronConfig
+	Notify   config.NotifyConfig
+	Scrobble config.ScrobbleConfig
+	APILog   config.APILogConfig
+	Debug    config.DebugCaptureConfig
+}
+
+// LoadConfig reads and validates the configuration. A .env file is optional;
+// variables can come straight from the container or service manager.
+func LoadConfig() (Config, error) {
+	if err := config.LoadEnv(config.EnvDatabase, config.EnvSpotify); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Config{Addr: ":8080", Mode: ModeAll}
+	if port := os.Getenv("PORT"); port != "" {
+		cfg.Addr = ":" + port
+	}
+	if mode := os.Getenv("RUN_MODE"); mode != "" {
+		cfg.Mode = strings.ToLower(strings.TrimSpace(mode))
+	}
+	switch cfg.Mode {
+	case ModeAll, ModeServer, ModeCollector:
+	default:
+		return cfg, fmt.Errorf("invalid RUN_MODE %q (expected %s, %s or %s)", cfg.Mode, ModeAll, ModeServer, ModeCollector)
+	}
+
+	httpCfg, err := config.LoadHTTPConfig()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid HTTP configuration: %v", err)
+	}
+	cfg.HTTP = httpCfg
+
+	cron, err := config.LoadCronConfig()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid cron configuration: %v", err)
+	}
+	cfg.Cron = cron
+
+	notify, err := config.LoadNotifyConfig()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid notification configuration: %v", err)
+	}
+	cfg.Notify = notify
+
+	scrobble, err := config.LoadScrobbleConfig()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid scrobbling configuration: %v", err)
+	}
+	cfg.Scrobble = scrobble
+
+	apiLog, err := config.LoadAPILogConfig()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid API log configuration: %v", err)
+	}
+	cfg.APILog = apiLog
+
+	debug, err := config.LoadDebugCaptureConfig()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid debug capture configuration: %v", err)
+	}
+	cfg.Debug = debug
+	return cfg, nil
+}
+
+// Migrate connects to the database and brings the schema up to date
+func Migrate() {
+	repository.InitDB()
+	jobs.MarkInterrupted()
+}
+
+// NewRouter builds the HTTP API
+func NewRouter(cfg Config, st store.Store) *gin.Engine {
+	router := gin.Default()
+	// Client IPs (rate limits, the admin allowlist) come from X-Forwarded-For
+	// only when the request came through one of these proxies. Without any,
+	// the header is ignored and the connection's address is used.
+	if err := router.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.Use(handlers.MetricsMiddleware())
+
+	// Rate Limiting: RATE_LIMIT requests per minute per IP
+	router.Use(rateLimit(cfg.HTTP.RateLimit))
+	router.Use(handlers.LimitBodySize(cfg.HTTP.MaxBodyBytes))
+
+	// CORS first so preflight requests are answered before auth
+	router.Use(handlers.CORS(cfg.HTTP.AllowedOrigins))
+
+	// Writes get a stricter limit, counted before the API key is checked so
+	// guessing the key is just as limited
+	writeLimit := rateLimit(cfg.HTTP.WriteRateLimit)
+	router.Use(handlers.OnlyWrites(writeLimit))
+
+	// Reads are public; writes need the API key
+	if cfg.HTTP.APIKey == "" {
+		log.Println("⚠️  API_KEY is not set: write and admin endpoints will reject every request")
+	}
+	router.Use(handlers.RequireAPIKeyForWrites(cfg.HTTP.APIKey))
+
+	routeLimits, err := routeRateLimits(cfg.HTTP.RouteRateLimits)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	api := handlers.NewAPI(st)
+	guards := routeGuards{
+		requireKey:  handlers.RequireAPIKey(cfg.HTTP.APIKey),
+		readLimit:   writeLimit,
+		allowedIP:   handlers.RequireAllowedIP(cfg.HTTP.AdminAllowed),
+		routeLimits: routeLimits,
+	}
+
+	// Prometheus scrapes the root path only; it isn't part of the versioned API
+	router.GET("/metrics", handlers.Metrics)
+
+	// Every route is served unprefixed (existing clients) and under /api/v1
+	registerRoutes(router, api, guards)
+	v1 := router.Group(APIVersionPrefix)
+	registerRoutes(v1, api, guards)
+
+	spec, err := OpenAPISpec().JSON()
+	if err != nil {
+		log.Fatalf("could not generate the OpenAPI spec: %v", err)
+	}
+	v1.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", spec)
+	})
+	if cfg.HTTP.SwaggerUI {
+		v1.GET("/docs", swaggerUI)
+	}
+
+	router.NoRoute(handlers.NoRoute)
+	return router
+}
+
+// rateLimit allows perMinute requests per minute per client IP, answering
+// the rest with 429. Each call counts separately.
+func rateLimit(perMinute int) gin.HandlerFunc {
+	rate := limiter.Rate{
+		Period: 1 * time.Minute,
+		Limit:  int64(perMinute),
+	}
+	return mgin.NewMiddleware(limiter.New(memory.NewStore(), rate),
+		mgin.WithLimitReachedHandler(func(c *gin.Context) {
+			handlers.RespondError(c, http.StatusTooManyRequests, handlers.CodeRateLimited, "too many requests", nil)
+		}))
+}
+
+// healthRouter serves only the health checks and metrics, so a
+// collector-only instance can still be probed and scraped
+func healthRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery(), handlers.MetricsMiddleware())
+	router.GET("/healthz", handlers.Healthz)
+	router.GET("/readyz", handlers.Readyz)
+	router.GET("/metrics", handlers.Metrics)
+	router.NoRoute(handlers.NoRoute)
+	return router
+}
+
+// Run migrates the database, then serves the API and starts the background
+// cron as cfg.Mode says, until the listener fails
+func Run(cfg Config) error {
+	Migrate()
+
+	handlers.SetScrobbling(cfg.Scrobble)
+	handlers.SetAPILog(cfg.APILog)
+	handlers.SetDebugCapture(cfg.Debug)
+
+	st := store.Postgres{}
+	router := healthRouter()
+	if cfg.Mode != ModeCollector {
+		router = NewRouter(cfg, st)
+	}
+	fmt.Printf("🧭 Run mode: %s\n", cfg.Mode)
+
+	/* NEW: start the background cron in its own goroutine */
+	if cfg.Mode != ModeServer {
+		go handlers.StartSpotifyCron(cfg.Cron, cfg.Notify, handlers.NewCollector(st, services.Live{}))
+	}
+
+	return router.Run(cfg.Addr)
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminAllowlistBehindProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, office, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name    string
+		proxies []string
+		remote  string
+		want403 bool
+	}{
+		// a spoofed X-Forwarded-For is ignored unless proxies are configured
+		{"no proxies, spoofed header", nil, "203.0.113.5:4000", true},
+		{"untrusted proxy", []string{"192.0.2.1/32"}, "203.0.113.5:4000", true},
+		{"trusted proxy", []string{"203.0.113.5/32"}, "203.0.113.5:4000", false},
+		{"no proxies, allowed address", nil, "10.1.2.3:4000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{HTTP: config.HTTPConfig{
+				RateLimit:      100,
+				WriteRateLimit: 100,
+				MaxBodyBytes:   1 << 20,
+				AdminAllowlist: []*net.IPNet{office},
+				TrustedProxies: tt.proxies,
+			}}
+			router := NewRouter(cfg, store.NewMemory())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if got := rec.Code == http.StatusForbidden; got != tt.want403 {
+				t.Errorf("status = %d, want 403: %v", rec.Code, tt.want403)
+			}
+		})
+	}
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := Config{HTTP: config.HTTPConfig{
+		RateLimit:       100,
+		WriteRateLimit:  100,
+		RouteRateLimits: map[string]int{"GET /graphql/schema": 2},
+		MaxBodyBytes:    1 << 20,
+	}}
+	router := NewRouter(cfg, store.NewMemory())
+
+	get := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.5:4000"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// both paths of the route share its limit
+	for _, path := range []string{"/graphql/schema", APIVersionPrefix + "/graphql/schema"} {
+		if code := get(path); code != http.StatusOK {
+			t.Fatalf("GET %s = %d within the limit, want 200", path, code)
+		}
+	}
+	if code := get("/graphql/schema"); code != http.StatusTooManyRequests {
+		t.Errorf("GET /graphql/schema = %d past the route's limit, want 429", code)
+	}
+	if code := get("/api/v1/openapi.json"); code != http.StatusOK {
+		t.Errorf("GET /api/v1/openapi.json = %d, want 200: other routes keep the global limit", code)
+	}
+}
+
+func TestRouteRateLimitUnknownRoute(t *testing.T) {
+	if _, err := routeRateLimits(map[string]int{"GET /no-such-route": 5}); err == nil {
+		t.Error("routeRateLimits() accepted a route that doesn't exist")
+	}
+}
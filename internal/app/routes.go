@@ -0,0 +1,556 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"example.com/spotifydb/internal/graphql"
+	"example.com/spotifydb/internal/handlers"
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/openapi"
+	"example.com/spotifydb/internal/reports"
+	"example.com/spotifydb/internal/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersionPrefix is where the versioned API is mounted. The same routes
+// stay available unprefixed for existing clients.
+const APIVersionPrefix = "/api/v1"
+
+// route is one endpoint: how to serve it and how to document it
+type route struct {
+	openapi.Operation
+	handler gin.HandlerFunc
+}
+
+/* -------- shared parameters -------- */
+
+var (
+	periodParams = []openapi.Param{
+		openapi.Query("period", "string", "day, week, month, year or all"),
+		openapi.Query("month", "string", "YYYY-MM; overrides period"),
+	}
+	dateRangeParams = []openapi.Param{
+		openapi.Query("from", "string", "YYYY-MM-DD, inclusive"),
+		openapi.Query("to", "string", "YYYY-MM-DD, inclusive"),
+	}
+	limitParam   = openapi.Query("limit", "integer", "maximum number of results")
+	groupByParam = openapi.Query("group_by", "string", "track or canonical")
+	sourceParam  = openapi.Query("source", "string",
+		"only rows stored by cron, catch-up, fetch-historical, recovery, gdpr_export, seed or api")
+	primaryOnlyParam = openapi.Query("primary_only", "boolean", "count only plays where the artist is the primary one, not featured")
+)
+
+func params(groups ...[]openapi.Param) []openapi.Param {
+	var out []openapi.Param
+	for _, g := range groups {
+		out = append(out, g...)
+	}
+	return out
+}
+
+// routes lists every documented endpoint. api may be nil when only the
+// documentation is needed.
+func routes(api *handlers.API) []route {
+	return []route{
+		/* -------- Health checks -------- */
+		{openapi.Operation{Method: http.MethodGet, Path: "/healthz", Tag: "health",
+			Summary: "Liveness: process up and database reachable", Response: handlers.HealthzResponse{}},
+			handlers.Healthz},
+		{openapi.Operation{Method: http.MethodGet, Path: "/readyz", Tag: "health",
+			Summary: "Readiness: database, refresh token and collector freshness", Response: handlers.ReadyzResponse{}},
+			handlers.Readyz},
+
+		/* -------- Tracks -------- */
+		{openapi.Operation{Method: http.MethodGet, Path: "/recently-played-tracks", Tag: "tracks",
+			Summary: "Latest collected plays", Response: handlers.RecentlyPlayedResponse{},
+			Params: []openapi.Param{sourceParam}},
+			handlers.PlaysConditional(api.RecentlyPlayedTracks)},
+		{openapi.Operation{Method: http.MethodGet, Path: "/now-listening-to", Tag: "tracks",
+			Summary: "Currently playing track, with device and progress ({\"is_playing\": false} when nothing is playing)", Response: handlers.NowPlayingResponse{}},
+			handlers.NowListeningToTrack},
+		{openapi.Operation{Method: http.MethodGet, Path: "/recently-liked", Tag: "tracks",
+			Summary: "Saved tracks, paged and filtered", Response: handlers.RecentlyLikedResponse{},
+			Params: []openapi.Param{
+				limitParam,
+				openapi.Query("offset", "integer", "number of tracks to skip"),
+				openapi.Query("sort", "string", "added_at, popularity or release_date"),
+				openapi.Query("order", "string", "asc or desc"),
+				openapi.Query("genre", "string", "only tracks by artists in this genre"),
+				openapi.Query("artist", "string", "only tracks by this artist"),
+				openapi.Query("album_type", "string", "album, single or compilation"),
+				sourceParam,
+				openapi.Query("include_removed", "boolean", "include tracks unliked since"),
+			}},
+			handlers.LikedConditional(handlers.RecentlyLiked)},
+		{openapi.Operation{Method: http.MethodGet, Path: "/top-tracks", Tag: "tracks",
+			Summary: "Most played tracks in a date range", Response: handlers.TopTracksResponse{},
+			Params: params(dateRangeParams, []openapi.Param{limitParam, groupByParam, sourceParam})},
+			api.GetTopTracks},
+		{openapi.Operation{Method: http.MethodGet, Path: "/tracks/:id", Tag: "tracks",
+			Summary:  "Everything known about a track: plays, saved state, genres, audio features and Spotify metadata",
+			Response: handlers.TrackDetailResponse{},
+			Params: []openapi.Param{
+				openapi.Path("id", "Spotify track ID"),
+				openapi.Query("limit", "integer", "latest plays to list (default 50, max 1000)"),
+			}},
+			handlers.GetTrackDetail},
+		{openapi.Operation{Method: http.MethodGet, Path: "/tracks/:id/streak", Tag: "tracks",
+			Summary: "Longest and current daily streak for a track", Response: handlers.TrackStreakResponse{},
+			Params: []openapi.Param{openapi.Path("id", "Spotify track ID")}},
+			handlers.GetTrackStreak},
+		{openapi.Operation{Method: http.MethodGet, Path: "/tracks/:id/stats", Tag: "tracks",
+			Summary: "Play totals for a track", Response: handlers.TrackStatsResponse{},
+			Params: params([]openapi.Param{openapi.Path("id", "Spotify track ID")}, dateRangeParams)},
+			handlers.GetTrackStats},
+		{openapi.Operation{Method: http.MethodGet, Path: "/tracks/:id/daily", Tag: "tracks",
+			Summary: "Per-day plays for a track", Response: handlers.TrackDailyResponse{},
+			Params: params([]openapi.Param{openapi.Path("id", "Spotify track ID")}, dateRangeParams)},
+			handlers.GetTrackDaily},
+		{openapi.Operation{Method: http.MethodPost, Path: "/tracks/:id/like", Tag: "tracks",
+			Summary: "Save a track to my Spotify library and store it as liked", Response: handlers.LikeResponse{},
+			Params: []openapi.Param{openapi.Path("id", "Spotify track ID")}, Auth: true},
+			handlers.LikeTrack},
+		{openapi.Operation{Method: http.MethodDelete, Path: "/tracks/:id/like", Tag: "tracks",
+			Summary: "Remove a track from my Spotify library and mark it unliked", Response: handlers.LikeResponse{},
+			Params: []openapi.Param{openapi.Path("id", "Spotify track ID")}, Auth: true},
+			handlers.UnlikeTrack},
+		{openapi.Operation{Method: http.MethodGet, Path: "/artwork/:spotify_song_id", Tag: "tracks",
+			Summary: "A track's album cover, cached by the server", Produces: "image/jpeg",
+			Params: []openapi.Param{
+				openapi.Path("spotify_song_id", "Spotify track ID"),
+				openapi.Query("w", "integer", "scale down to this width in pixels (16 to 640)"),
+			}},
+			handlers.GetArtwork},
+		{openapi.Operation{Method: http.MethodPatch, Path: "/mostPlayedTracks/track/:spotify_song_id", Tag: "tracks",
+			Summary: "Set the mood/activity tags of a track on repeat", Body: handlers.UpdateTrackRequest{},
+			Response: handlers.MessageResponse{}, Auth: true,
+			Params: []openapi.Param{openapi.Path("spotify_song_id", "Spotify track ID")}},
+			handlers.UpdateTrack},
+		{openapi.Operation{Method: http.MethodPatch, Path: "/mostPlayedTracks/bulk", Tag: "tracks",
+			Summary: "Set the mood/activity tags of many tracks on repeat in one transaction, with a result per item",
+			Body:    []handlers.BulkUpdateTrackItem{}, Response: handlers.BulkUpdateTracksResponse{}, Auth: true},
+			handlers.BulkUpdateTracks},
+
+		/* -------- Plays -------- */
+		{openapi.Operation{Method: http.MethodGet, Path: "/plays", Tag: "plays",
+			Summary: "Plays with their mood/activity annotations", Response: handlers.PlaysResponse{},
+			Params: params([]openapi.Param{
+				openapi.Query("mood", "string", "only plays tagged with this mood"),
+				openapi.Query("activity", "string", "only plays tagged with this activity"),
+				openapi.Query("annotated", "boolean", "only annotated plays"),
+				sourceParam,
+				limitParam,
+				openapi.Query("offset", "integer", "number of plays to skip"),
+			}, dateRangeParams)},
+			handlers.GetPlays},
+		{openapi.Operation{Method: http.MethodPost, Path: "/plays/:id/annotations", Tag: "plays",
+			Summary: "Tag a play, or a run of plays, with a mood/activity", Body: handlers.AnnotatePlayRequest{},
+			Response: handlers.AnnotatePlayResponse{}, Auth: true,
+			Params: []openapi.Param{openapi.Path("id", "play ID (recently_played.id)")}},
+			handlers.AnnotatePlay},
+
+		/* -------- Artists -------- */
+		{openapi.Operation{Method: http.MethodGet, Path: "/artists/:artist_id", Tag: "artists",
+			Summary: "Artist from Spotify merged with my plays and liked tracks", Response: handlers.ArtistResponse{},
+			Params: []openapi.Param{openapi.Path("artist_id", "Spotify artist ID"), primaryOnlyParam}},
+			handlers.GetArtist},
+		{openapi.Operation{Method: http.MethodGet, Path: "/artists/:artist_id/related", Tag: "artists",
+			Summary: "Spotify's related artists, marked with whether I've played them", Response: handlers.RelatedArtistsResponse{},
+			Params: []openapi.Param{openapi.Path("artist_id", "Spotify artist ID"),
+				openapi.Query("unplayed", "boolean", "only artists I haven't played"), limitParam}},
+			handlers.GetArtistRelated},
+
+		/* -------- Genres, search & discovery -------- */
+		{openapi.Operation{Method: http.MethodGet, Path: "/genre/:genre", Tag: "discovery",
+			Summary: "Liked artists in a genre", Response: handlers.GenreArtistsResponse{},
+			Params: []openapi.Param{openapi.Path("genre", "genre name"),
+				openapi.Query("match", "string", "exact (default) or contains")}},
+			handlers.GetUserGenre},
+		{openapi.Operation{Method: http.MethodGet, Path: "/genres", Tag: "discovery",
+			Summary: "Genres with artist counts", Response: handlers.GenresResponse{}},
+			handlers.ListGenres},
+		{openapi.Operation{Method: http.MethodGet, Path: "/search", Tag: "discovery",
+			Summary: "Spotify search annotated with my history", Response: handlers.SearchResponse{},
+			Params: []openapi.Param{
+				{Name: "q", In: "query", Type: "string", Description: "search text", Required: true},
+				openapi.Query("type", "string", "track and/or artist, comma-separated"),
+				limitParam,
+			}},
+			handlers.Search},
+		{openapi.Operation{Method: http.MethodGet, Path: "/discover", Tag: "discovery",
+			Summary: "Unheard tracks and albums from artists I play", Response: handlers.DiscoverResponse{},
+			Params: []openapi.Param{openapi.Query("type", "string", "track, album or all"), limitParam}},
+			handlers.GetDiscover},
+		{openapi.Operation{Method: http.MethodGet, Path: "/new-releases", Tag: "discovery",
+			Summary: "Releases by artists I play that I haven't played yet", Response: handlers.NewReleasesResponse{},
+			Params: []openapi.Param{
+				openapi.Query("days", "integer", "released in the last N days (default 90, max 3650)"),
+				openapi.Query("type", "string", "album, single or all"),
+				limitParam,
+			}},
+			handlers.GetNewReleases},
+		{openapi.Operation{Method: http.MethodGet, Path: "/recommendations", Tag: "discovery",
+			Summary: "Spotify recommendations seeded from my plays", Response: handlers.RecommendationsResponse{},
+			Params: []openapi.Param{
+				limitParam,
+				openapi.Query("days", "integer", "seed from plays in the last N days (1-365)"),
+				openapi.Query("target_energy", "number", "0-1"),
+				openapi.Query("target_valence", "number", "0-1"),
+				openapi.Query("seed_tracks", "string", "comma-separated track IDs"),
+				openapi.Query("seed_artists", "string", "comma-separated artist IDs"),
+				openapi.Query("seed_genres", "string", "comma-separated genres"),
+			}},
+			handlers.GetRecommendations},
+
+		/* -------- Analytics -------- */
+		{openapi.Operation{Method: http.MethodGet, Path: "/collection-stats", Tag: "stats",
+			Summary: "How much history has been collected", Response: handlers.CollectionStatsResponse{}},
+			handlers.GetCollectionStats},
+		{openapi.Operation{Method: http.MethodGet, Path: "/listening-stats", Tag: "stats",
+			Summary:  "Listening time by period (with song_id: SongListeningTimeResponse)",
+			Response: handlers.ListeningStatsResponse{},
+			Params: []openapi.Param{
+				openapi.Query("song_id", "string", "only this track"),
+				openapi.Query("since", "string", "with song_id: 24h, week, month or all"),
+			}},
+			handlers.GetListeningStats},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/most-played", Tag: "stats",
+			Summary: "Most played tracks", Response: handlers.MostPlayedResponse{},
+			Params: params(periodParams, []openapi.Param{limitParam, groupByParam, sourceParam})},
+			api.GetMostPlayed},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/skips", Tag: "stats",
+			Summary: "Most skipped tracks and artist skip rates", Response: handlers.SkipStatsResponse{},
+			Params: params(periodParams, []openapi.Param{limitParam,
+				openapi.Query("min_plays", "integer", "ignore tracks with fewer plays")})},
+			handlers.GetSkipStats},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/queue-dropoff", Tag: "stats",
+			Summary: "Queued tracks that never played", Response: handlers.QueueDropoffResponse{},
+			Params: params(periodParams, []openapi.Param{limitParam})},
+			handlers.GetQueueDropoff},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/devices", Tag: "stats",
+			Summary: "Plays by device", Response: handlers.DeviceStatsResponse{}, Params: periodParams},
+			handlers.GetDeviceStats},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/contexts", Tag: "stats",
+			Summary: "Plays by playlist, album and artist context", Response: handlers.ContextStatsResponse{},
+			Params: params(periodParams, []openapi.Param{limitParam})},
+			handlers.GetContextStats},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/calendar", Tag: "stats",
+			Summary: "Per-day plays and listening streaks", Response: handlers.CalendarResponse{},
+			Params: []openapi.Param{openapi.Query("years", "integer", "1-5")}},
+			handlers.GetCalendar},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/compare", Tag: "stats",
+			Summary: "Compare artists, genres and tracks between two windows", Response: handlers.CompareResponse{},
+			Params: []openapi.Param{
+				openapi.Query("period_a", "string", "YYYY-MM or YYYY (default: last month)"),
+				openapi.Query("period_b", "string", "YYYY-MM or YYYY (default: this month)"),
+				limitParam,
+				primaryOnlyParam,
+			}},
+			handlers.GetCompare},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/listening-patterns", Tag: "stats",
+			Summary: "Weekday by hour play matrix", Response: handlers.ListeningPatternsResponse{}, Params: periodParams},
+			handlers.GetListeningPatterns},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/diversity", Tag: "stats",
+			Summary: "Listening diversity per week, month or quarter and its trend", Response: handlers.DiversityResponse{},
+			Params: []openapi.Param{
+				openapi.Query("window", "string", "week, month (default) or quarter"),
+				openapi.Query("count", "integer", "windows to return, the current one included"),
+			}},
+			handlers.GetDiversity},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/top-albums", Tag: "stats",
+			Summary: "Most played albums", Response: handlers.TopAlbumsResponse{},
+			Params: params(periodParams, []openapi.Param{limitParam})},
+			handlers.GetTopAlbums},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/eras", Tag: "stats",
+			Summary: "Plays by album release decade or year, with a nostalgia index", Response: handlers.ErasResponse{},
+			Params: params(periodParams, []openapi.Param{
+				openapi.Query("group", "string", "decade (default) or year"),
+			})},
+			handlers.GetEras},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/genres/timeline", Tag: "stats",
+			Summary: "Plays and minutes per day, week or month for my top genres", Response: handlers.GenreTimelineResponse{},
+			Params: params(periodParams, []openapi.Param{
+				openapi.Query("granularity", "string", "day, week (default) or month"),
+				openapi.Query("limit", "integer", "number of genres (default 10)"),
+			})},
+			handlers.GetGenreTimeline},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/mood-timeline", Tag: "stats",
+			Summary: "Average valence, energy and danceability per day or week and per time of day", Response: handlers.MoodTimelineResponse{},
+			Params: params(periodParams, []openapi.Param{
+				openapi.Query("granularity", "string", "day (default) or week"),
+			})},
+			handlers.GetMoodTimeline},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/decades", Tag: "stats",
+			Summary:  "Plays by the decade each recording was first released (MusicBrainz)",
+			Response: handlers.DecadesResponse{}, Params: periodParams},
+			handlers.GetPlaysByDecade},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/labels", Tag: "stats",
+			Summary: "Most played record labels (MusicBrainz)", Response: handlers.LabelsResponse{},
+			Params: params(periodParams, []openapi.Param{limitParam})},
+			handlers.GetPlaysByLabel},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/discoveries", Tag: "stats",
+			Summary: "Tracks first played in a period", Response: handlers.DiscoveriesResponse{},
+			Params: params(periodParams, []openapi.Param{limitParam,
+				openapi.Query("sort", "string", "replays or first_played")})},
+			handlers.GetDiscoveries},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/gaps", Tag: "stats",
+			Summary:  "Stretches where plays are missing: long silences in active hours and failing collection",
+			Response: handlers.GapsResponse{},
+			Params: params([]openapi.Param{
+				openapi.Query("kind", "string", "no_plays or collection_failing"),
+				limitParam,
+			}, dateRangeParams)},
+			handlers.GetCollectionGaps},
+		{openapi.Operation{Method: http.MethodGet, Path: "/stats/liked-vs-played", Tag: "stats",
+			Summary:  "Saved tracks I never played, and tracks I play a lot but never saved",
+			Response: handlers.LikedVsPlayedResponse{},
+			Params: []openapi.Param{
+				openapi.Query("min_plays", "integer", "plays before an unsaved track is listed (default 10)"),
+				limitParam,
+			}},
+			handlers.GetLikedVsPlayed},
+		{openapi.Operation{Method: http.MethodPost, Path: "/stats/liked-vs-played/like", Tag: "stats",
+			Summary: "Save my most played unsaved tracks (or the given ones) to my Spotify library",
+			Body:    handlers.LikeTracksRequest{}, Response: handlers.LikeTracksResponse{}, Auth: true},
+			handlers.LikePlayedTracks},
+		{openapi.Operation{Method: http.MethodGet, Path: "/albums/:album_name/plays", Tag: "stats",
+			Summary: "Plays for one album", Response: handlers.AlbumPlaysResponse{},
+			Params: params([]openapi.Param{
+				openapi.Path("album_name", "album name"),
+				openapi.Query("artist", "string", "disambiguates albums sharing a name"),
+			}, dateRangeParams)},
+			handlers.GetAlbumPlays},
+		{openapi.Operation{Method: http.MethodGet, Path: "/reports/daily", Tag: "reports",
+			Summary: "Daily listening report", Response: reports.DailyReport{},
+			Params: []openapi.Param{openapi.Query("date", "string", "YYYY-MM-DD (default: yesterday)")}},
+			handlers.GetDailyReport},
+		{openapi.Operation{Method: http.MethodGet, Path: "/wrapped", Tag: "reports",
+			Summary: "Wrapped-style summary", Response: reports.Wrapped{},
+			Params: []openapi.Param{openapi.Query("period", "string", "YYYY, YYYY-MM or YYYY-Www (default: this month)")}},
+			handlers.GetWrapped},
+		{openapi.Operation{Method: http.MethodGet, Path: "/milestones", Tag: "reports",
+			Summary: "Listening milestones, newest first", Response: handlers.MilestonesResponse{},
+			Params: []openapi.Param{
+				openapi.Query("kind", "string", "plays, unique_artists, new_genre or track_plays"),
+				limitParam,
+			}},
+			handlers.GetMilestones},
+
+		/* -------- Writes -------- */
+		{openapi.Operation{Method: http.MethodPost, Path: "/save-refresh", Tag: "auth",
+			Summary: "Store or rotate the Spotify refresh token", Body: handlers.SaveRefreshRequest{},
+			Response: handlers.SaveRefreshResponse{}, Auth: true},
+			api.SaveRefresh},
+		{openapi.Operation{Method: http.MethodPost, Path: "/collect", Tag: "tracks",
+			Summary: "Run one recently-played and saved-tracks collection", Response: handlers.CollectResponse{}, Auth: true},
+			handlers.Collect},
+		{openapi.Operation{Method: http.MethodPost, Path: "/backfill-duration", Tag: "tracks",
+			Summary: "Fill missing track durations from Spotify", Response: handlers.BackfillDurationResponse{}, Auth: true},
+			handlers.BackfillDurationHandler},
+		{openapi.Operation{Method: http.MethodPost, Path: "/fetch-historical", Tag: "tracks",
+			Summary: "Deprecated: use POST /admin/fetch-historical", Response: jobs.Job{}, Status: http.StatusAccepted, Auth: true,
+			Params: []openapi.Param{openapi.Query("since", "string", "RFC3339 or YYYY-MM-DD (default: 24h ago)")}},
+			handlers.FetchHistoricalDeprecated},
+		{openapi.Operation{Method: http.MethodPost, Path: "/playlists/generate", Tag: "playlists",
+			Summary: "Create a Spotify playlist from my top or liked tracks", Body: handlers.GeneratePlaylistRequest{},
+			Response: handlers.GeneratedPlaylistResponse{}, Status: http.StatusCreated, Auth: true},
+			handlers.GeneratePlaylist},
+
+		/* -------- Admin -------- */
+		{openapi.Operation{Method: http.MethodPost, Path: "/admin/backfill", Tag: "admin",
+			Summary: "Start a backfill job", Body: handlers.BackfillRequest{},
+			Response: jobs.Job{}, Status: http.StatusAccepted, Auth: true},
+			handlers.StartBackfill},
+		{openapi.Operation{Method: http.MethodPost, Path: "/admin/fetch-historical", Tag: "admin",
+			Summary: "Start a job storing the plays Spotify still has since a time", Response: jobs.Job{},
+			Status: http.StatusAccepted, Auth: true,
+			Params: []openapi.Param{openapi.Query("since", "string", "RFC3339 or YYYY-MM-DD (default: 24h ago)")}},
+			handlers.FetchHistorical},
+		{openapi.Operation{Method: http.MethodGet, Path: "/admin/collector-runs", Tag: "admin",
+			Summary: "What each cron collector run did, newest first", Response: handlers.CollectorRunsResponse{}, Auth: true,
+			Params: []openapi.Param{
+				openapi.Query("collector", "string", "only runs of this collector"),
+				openapi.Query("limit", "integer", "number of runs (default 100, max 1000)"),
+			}},
+			handlers.GetCollectorRuns},
+		{openapi.Operation{Method: http.MethodGet, Path: "/admin/jobs", Tag: "admin",
+			Summary: "Recent background jobs", Response: handlers.JobsResponse{}, Auth: true,
+			Params: []openapi.Param{limitParam}},
+			handlers.ListJobs},
+		{openapi.Operation{Method: http.MethodGet, Path: "/admin/jobs/:id", Tag: "admin",
+			Summary: "One background job", Response: jobs.Job{}, Auth: true,
+			Params: []openapi.Param{openapi.Path("id", "job ID")}},
+			handlers.GetJob},
+		{openapi.Operation{Method: http.MethodPost, Path: "/admin/jobs/:id/cancel", Tag: "admin",
+			Summary: "Cancel a queued or running job", Response: jobs.Job{}, Auth: true,
+			Params: []openapi.Param{openapi.Path("id", "job ID")}},
+			handlers.CancelJob},
+		{openapi.Operation{Method: http.MethodDelete, Path: "/admin/history", Tag: "admin",
+			Summary:  "Delete or anonymize the plays or saved tracks in a time range (dry run first, then confirm)",
+			Response: handlers.PurgeHistoryResponse{}, Auth: true,
+			Params: []openapi.Param{
+				openapi.Query("table", "string", "recently_played (default) or recently_liked"),
+				openapi.Query("from", "string", "RFC3339 or YYYY-MM-DD, inclusive; required"),
+				openapi.Query("to", "string", "RFC3339 or YYYY-MM-DD, inclusive; required"),
+				openapi.Query("anonymize", "boolean", "blank track, artist and album names instead of deleting"),
+				openapi.Query("dry_run", "boolean", "only count the rows and return a confirm token"),
+				openapi.Query("confirm", "string", "token from the dry run; required to apply"),
+			}},
+			handlers.PurgeHistory},
+		{openapi.Operation{Method: http.MethodPost, Path: "/admin/plays/dedup", Tag: "admin",
+			Summary: "Start a job merging plays of the same track reported twice within seconds", Body: handlers.DedupPlaysRequest{},
+			Response: jobs.Job{}, Status: http.StatusAccepted, Auth: true},
+			handlers.DedupPlays},
+		{openapi.Operation{Method: http.MethodPost, Path: "/admin/plays/archive", Tag: "admin",
+			Summary: "Start a job moving plays older than N months to recently_played_archive", Body: handlers.ArchivePlaysRequest{},
+			Response: jobs.Job{}, Status: http.StatusAccepted, Auth: true},
+			handlers.ArchivePlays},
+
+		{openapi.Operation{Method: http.MethodGet, Path: "/admin/webhooks", Tag: "admin",
+			Summary: "Registered webhooks and their last delivery", Response: handlers.WebhooksResponse{}, Auth: true},
+			handlers.ListWebhooks},
+		{openapi.Operation{Method: http.MethodPost, Path: "/admin/webhooks", Tag: "admin",
+			Summary: "Register a webhook for collection events", Body: handlers.CreateWebhookRequest{},
+			Response: webhooks.Webhook{}, Status: http.StatusCreated, Auth: true},
+			handlers.CreateWebhook},
+		{openapi.Operation{Method: http.MethodDelete, Path: "/admin/webhooks/:id", Tag: "admin",
+			Summary: "Remove a webhook", Response: handlers.MessageResponse{}, Auth: true,
+			Params: []openapi.Param{openapi.Path("id", "webhook ID")}},
+			handlers.DeleteWebhook},
+		{openapi.Operation{Method: http.MethodPost, Path: "/admin/webhooks/:id/test", Tag: "admin",
+			Summary: "Send a ping event to a webhook", Response: handlers.MessageResponse{}, Auth: true,
+			Params: []openapi.Param{openapi.Path("id", "webhook ID")}},
+			handlers.TestWebhook},
+
+		{openapi.Operation{Method: http.MethodGet, Path: "/admin/scrobbles", Tag: "admin",
+			Summary: "Last.fm and ListenBrainz queues by status, with recent failures", Response: handlers.ScrobblesResponse{},
+			Auth: true, Params: []openapi.Param{limitParam}},
+			handlers.GetScrobbles},
+		{openapi.Operation{Method: http.MethodPost, Path: "/admin/scrobbles/retry", Tag: "admin",
+			Summary: "Requeue failed scrobbles", Response: handlers.MessageResponse{}, Auth: true,
+			Params: []openapi.Param{openapi.Query("service", "string", "lastfm or listenbrainz (default both)")}},
+			handlers.RetryScrobbles},
+		{openapi.Operation{Method: http.MethodGet, Path: "/admin/api-usage", Tag: "admin",
+			Summary:  "Logged Spotify API calls per hour or day and endpoint, with the latest rate limits",
+			Response: handlers.APIUsageResponse{}, Auth: true,
+			Params: []openapi.Param{
+				openapi.Query("bucket", "string", "hour (default) or day"),
+				openapi.Query("from", "string", "YYYY-MM-DD (default: 48 hours or 30 days before to)"),
+				openapi.Query("to", "string", "YYYY-MM-DD, inclusive (default: now)"),
+				openapi.Query("limit", "integer", "rate limits to list (default 20)"),
+			}},
+			handlers.GetAPIUsage},
+		{openapi.Operation{Method: http.MethodGet, Path: "/admin/debug/last-payloads", Tag: "admin",
+			Summary:  "The latest Spotify response bodies captured for debugging, newest first",
+			Response: handlers.LastPayloadsResponse{}, Auth: true,
+			Params: []openapi.Param{
+				openapi.Query("endpoint", "string", "only payloads of this Spotify endpoint, e.g. player"),
+				openapi.Query("limit", "integer", "number of payloads (default 10)"),
+			}},
+			handlers.GetLastPayloads},
+
+		/* -------- GraphQL -------- */
+		{openapi.Operation{Method: http.MethodGet, Path: "/graphql", Tag: "graphql",
+			Summary: "Run a GraphQL query given in the query string", Response: graphql.Response{},
+			Params: []openapi.Param{
+				openapi.Query("query", "string", "the GraphQL query"),
+				openapi.Query("operationName", "string", "operation to run when the query has several"),
+				openapi.Query("variables", "string", "JSON object of variables"),
+			}},
+			graphql.Handler()},
+		{openapi.Operation{Method: http.MethodPost, Path: "/graphql", Tag: "graphql",
+			Summary: "Run a GraphQL query", Body: graphql.Request{}, Response: graphql.Response{}, Auth: true},
+			graphql.Handler()},
+		{openapi.Operation{Method: http.MethodGet, Path: "/graphql/schema", Tag: "graphql",
+			Summary: "The GraphQL schema in SDL", Produces: "text/plain"},
+			graphql.Schema},
+
+		/* -------- Export -------- */
+		{openapi.Operation{Method: http.MethodGet, Path: "/export/recently-played", Tag: "export",
+			Summary: "Download listening history as CSV, JSON or NDJSON", Produces: "text/csv",
+			Params: params([]openapi.Param{openapi.Query("format", "string", "csv, json, ndjson or parquet"), sourceParam},
+				dateRangeParams)},
+			handlers.ExportRecentlyPlayed},
+	}
+}
+
+// routeGuards are the middleware registerRoutes puts in front of protected
+// routes, on top of the router-wide ones
+type routeGuards struct {
+	requireKey  gin.HandlerFunc            // API key, for reads of Auth routes
+	readLimit   gin.HandlerFunc            // the write rate limit, for reads of Auth routes
+	allowedIP   gin.HandlerFunc            // ADMIN_ALLOWED_IPS, for admin and auth routes
+	routeLimits map[string]gin.HandlerFunc // RATE_LIMIT_ROUTES, keyed "METHOD /path"
+}
+
+// registerRoutes mounts every route on r. Routes in RATE_LIMIT_ROUTES get
+// their own limit on top of the others. Admin routes and /save-refresh are
+// limited to the allowed IPs; routes marked Auth also require the API key,
+// under the write rate limit, for reads.
+func registerRoutes(r gin.IRoutes, api *handlers.API, g routeGuards) {
+	for _, rt := range routes(api) {
+		var chain []gin.HandlerFunc
+		if limit, ok := g.routeLimits[rt.Method+" "+rt.Path]; ok {
+			chain = append(chain, limit)
+		}
+		if rt.Tag == "admin" || rt.Tag == "auth" {
+			chain = append(chain, g.allowedIP)
+		}
+		if rt.Auth && rt.Method == http.MethodGet {
+			chain = append(chain, g.readLimit, g.requireKey)
+		}
+		r.Handle(rt.Method, rt.Path, append(chain, rt.handler)...)
+	}
+}
+
+// routeRateLimits builds a limiter for every route in limits, shared by its
+// unprefixed and /api/v1 paths. A route that doesn't exist is an error, so a
+// typo doesn't go unnoticed.
+func routeRateLimits(limits map[string]int) (map[string]gin.HandlerFunc, error) {
+	known := map[string]bool{}
+	for _, rt := range routes(handlers.NewAPI(nil)) {
+		known[rt.Method+" "+rt.Path] = true
+	}
+	guards := make(map[string]gin.HandlerFunc, len(limits))
+	for route, perMinute := range limits {
+		if !known[route] {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES: no route %s", route)
+		}
+		guards[route] = rateLimit(perMinute)
+	}
+	return guards, nil
+}
+
+// OpenAPISpec generates the OpenAPI document for the versioned API
+func OpenAPISpec() *openapi.Document {
+	ops := make([]openapi.Operation, 0)
+	for _, rt := range routes(handlers.NewAPI(nil)) {
+		ops = append(ops, rt.Operation)
+	}
+	return openapi.Build(openapi.Info{
+		Title:       "spotify-db",
+		Version:     "1",
+		Description: "My Spotify listening history, collected and queried.",
+	}, APIVersionPrefix, ops, handlers.ErrorResponse{})
+}
+
+/* -------- Swagger UI -------- */
+
+const swaggerUIPage = `<!doctype html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>spotify-db API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "` + APIVersionPrefix + `/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>`
+
+func swaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
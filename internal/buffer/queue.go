@@ -0,0 +1,187 @@
+package buffer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/store"
+)
+
+// entry is a buffered play and when it was buffered
+type entry struct {
+	store.Play
+	BufferedAt time.Time `json:"buffered_at"`
+}
+
+func key(p store.Play) string {
+	return p.SpotifyID + "@" + p.PlayedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// Queue is an append-only NDJSON file of plays waiting to be replayed.
+// Every append is fsynced so a crash during an outage doesn't lose plays.
+type Queue struct {
+	mu   sync.Mutex
+	path string
+}
+
+// DefaultPath is used when WRITE_BUFFER_PATH isn't set
+const DefaultPath = "data/write_buffer.ndjson"
+
+// Open returns a queue backed by path, creating its directory if needed
+func Open(path string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create write buffer directory: %v", err)
+	}
+	return &Queue{path: path}, nil
+}
+
+// OpenFromEnv opens the queue at WRITE_BUFFER_PATH (or DefaultPath)
+func OpenFromEnv() (*Queue, error) {
+	path := os.Getenv("WRITE_BUFFER_PATH")
+	if path == "" {
+		path = DefaultPath
+	}
+	return Open(path)
+}
+
+// Path returns the backing file's location
+func (q *Queue) Path() string {
+	return q.path
+}
+
+// Append durably adds plays to the end of the queue. Plays already queued
+// (same track and played_at) are skipped, since every cycle during an outage
+// re-fetches the same recently-played window.
+func (q *Queue) Append(plays ...store.Play) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queued, err := q.read()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(queued))
+	for _, e := range queued {
+		seen[key(e.Play)] = true
+	}
+
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open write buffer: %v", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	now := time.Now()
+	for _, p := range plays {
+		if seen[key(p)] {
+			continue
+		}
+		seen[key(p)] = true
+		if err := enc.Encode(entry{Play: p, BufferedAt: now}); err != nil {
+			return fmt.Errorf("failed to write to write buffer: %v", err)
+		}
+	}
+	return f.Sync()
+}
+
+// Len returns the number of plays waiting to be replayed
+func (q *Queue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := q.read()
+	return len(entries), err
+}
+
+// Replay hands each buffered play to insert in order. Replay stops at the first
+// error (the database is presumably still down); that play and everything after
+// it stay queued. Returns how many plays were replayed.
+func (q *Queue) Replay(insert func(store.Play) error) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := q.read()
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	done := 0
+	var insertErr error
+	for _, e := range entries {
+		if insertErr = insert(e.Play); insertErr != nil {
+			break
+		}
+		done++
+	}
+
+	if err := q.rewrite(entries[done:]); err != nil {
+		return done, err
+	}
+	return done, insertErr
+}
+
+// read loads the whole queue. Malformed lines (e.g. a write torn by a crash) are dropped.
+func (q *Queue) read() ([]entry, error) {
+	f, err := os.Open(q.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write buffer: %v", err)
+	}
+	defer f.Close()
+
+	var entries []entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			fmt.Printf("⚠️  write buffer: dropping malformed entry: %v\n", err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// rewrite atomically replaces the queue with the remaining plays
+func (q *Queue) rewrite(remaining []entry) error {
+	if len(remaining) == 0 {
+		if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear write buffer: %v", err)
+		}
+		return nil
+	}
+
+	tmp := q.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite write buffer: %v", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range remaining {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to rewrite write buffer: %v", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API log modes, from SPOTIFY_API_LOG
+const (
+	APILogOff     = "off"
+	APILogSampled = "sampled" // every failed call, and SampleRate of the rest
+	APILogFull    = "full"
+)
+
+// APILogConfig controls the spotify_api_log table of calls made to Spotify
+type APILogConfig struct {
+	Mode       string
+	SampleRate float64       // share of successful calls kept in sampled mode, 0-1
+	Retention  time.Duration // logged calls older than this are deleted
+}
+
+// DefaultAPILogConfig logs every call for 30 days
+func DefaultAPILogConfig() APILogConfig {
+	return APILogConfig{
+		Mode:       APILogFull,
+		SampleRate: 0.1,
+		Retention:  30 * 24 * time.Hour,
+	}
+}
+
+// LoadAPILogConfig reads the API log environment variables on top of the
+// defaults and validates the result.
+//
+//	SPOTIFY_API_LOG            full (default), sampled or off
+//	SPOTIFY_API_LOG_SAMPLE     share of successful calls logged when sampled (e.g. 0.1)
+//	SPOTIFY_API_LOG_RETENTION  how long logged calls are kept (e.g. 720h)
+func LoadAPILogConfig() (APILogConfig, error) {
+	cfg := DefaultAPILogConfig()
+	if v := os.Getenv("SPOTIFY_API_LOG"); v != "" {
+		cfg.Mode = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("SPOTIFY_API_LOG_SAMPLE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("SPOTIFY_API_LOG_SAMPLE: invalid number %q", v)
+		}
+		cfg.SampleRate = rate
+	}
+
+	var err error
+	if cfg.Retention, err = envDuration("SPOTIFY_API_LOG_RETENTION", cfg.Retention); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Enabled reports whether calls are logged at all
+func (c APILogConfig) Enabled() bool {
+	return c.Mode != APILogOff
+}
+
+// Validate reports the first invalid setting, if any
+func (c APILogConfig) Validate() error {
+	switch c.Mode {
+	case APILogOff, APILogSampled, APILogFull:
+	default:
+		return fmt.Errorf("SPOTIFY_API_LOG must be %s, %s or %s, got %q", APILogFull, APILogSampled, APILogOff, c.Mode)
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		return fmt.Errorf("SPOTIFY_API_LOG_SAMPLE must be in (0, 1], got %v", c.SampleRate)
+	}
+	if c.Retention < time.Hour {
+		return fmt.Errorf("SPOTIFY_API_LOG_RETENTION must be at least 1h, got %v", c.Retention)
+	}
+	return nil
+}
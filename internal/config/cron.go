@@ -0,0 +1,470 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Collector names used by CRON_DISABLED_COLLECTORS
+const (
+	CollectorRecentlyPlayed = "recently_played"
+	CollectorSavedTracks    = "saved_tracks"
+	CollectorNowPlaying     = "now_playing"
+	CollectorGenreBackfill  = "genre_backfill"
+	CollectorArtistRefresh  = "artist_refresh"
+	CollectorDailyReport    = "daily_report"
+	CollectorSkipInference  = "skip_inference"
+	CollectorCanonical      = "canonical_tracks"
+	CollectorLikedReconcile = "liked_reconcile"
+	CollectorDiscovery      = "discovery"
+	CollectorTrackBackfill  = "track_backfill"
+	CollectorTracksOnRepeat = "tracks_on_repeat"
+	CollectorMilestones     = "milestones"
+	CollectorGapDetection   = "gap_detection"
+	CollectorReleaseRadar   = "release_radar"
+	CollectorMusicBrainz    = "musicbrainz"
+	CollectorQueue          = "queue"
+)
+
+var knownCollectors = []string{
+	CollectorRecentlyPlayed,
+	CollectorSavedTracks,
+	CollectorNowPlaying,
+	CollectorGenreBackfill,
+	CollectorArtistRefresh,
+	CollectorDailyReport,
+	CollectorSkipInference,
+	CollectorCanonical,
+	CollectorLikedReconcile,
+	CollectorDiscovery,
+	CollectorTrackBackfill,
+	CollectorTracksOnRepeat,
+	CollectorMilestones,
+	CollectorGapDetection,
+	CollectorReleaseRadar,
+	CollectorMusicBrainz,
+	CollectorQueue,
+}
+
+// MaxDedupWindow bounds CRON_DEDUP_WINDOW and the historical cleanup's window
+const MaxDedupWindow = time.Minute
+
+// CronConfig controls how often the background collectors run
+type CronConfig struct {
+	ActiveInterval  time.Duration // poll interval during active hours
+	IdleInterval    time.Duration // poll interval outside active hours
+	ActiveStartHour int           // first active hour (0-23, inclusive)
+	ActiveEndHour   int           // last active hour (0-23, inclusive)
+
+	SavedTracksEvery   int // run the saved-tracks sync every N cycles
+	GenreBackfillEvery int // run the genre backfill every N cycles
+	GenreBatchSize     int // tracks per genre backfill run
+
+	ArtistRefreshEvery int           // re-fetch stale cached artists every N cycles
+	ArtistRefreshBatch int           // artists re-fetched per run
+	ArtistStaleAfter   time.Duration // cached artists older than this are re-fetched
+
+	ReportHour int // hour of day (TIMEZONE) after which yesterday's report is generated
+
+	CanonicalEvery int // resolve ISRCs for new tracks every N cycles
+
+	LikedReconcileEvery int // walk every saved track to detect unlikes every N cycles
+	DiscoveryEvery      int // refresh the discovery feed every N cycles
+
+	ReleaseRadarEvery   int           // look for new releases by my top artists every N cycles
+	ReleaseRadarArtists int           // how many top artists the release radar follows
+	ReleaseRadarAfter   time.Duration // how long before an artist's releases are checked again
+
+	MusicBrainzEvery int // look up new ISRCs on MusicBrainz every N cycles
+	MusicBrainzBatch int // ISRCs looked up per run (MusicBrainz allows one a second)
+
+	TrackBackfillEvery int // fill missing album covers/genres/release dates on plays every N cycles
+	TrackBackfillBatch int // tracks per play backfill run
+	BackfillMinSpare   int // requests the shared budget must have left for backfills to run
+
+	TracksOnRepeatEvery int // recompute tracks_on_repeat counters from plays every N cycles
+	MilestonesEvery     int // detect listening milestones every N cycles
+
+	GapsEvery int           // look for collection gaps every N cycles
+	GapAfter  time.Duration // active hours without plays before a stretch counts as a gap
+
+	FailureAlertAfter int // consecutive failed collections before collection.failing fires
+
+	DedupWindow time.Duration // a play of the track just played within this long is a duplicate; 0 disables
+
+	LeaderLock      bool   // collect only while holding the collector advisory lock
+	LockDatabaseURL string // direct (unpooled) connection the lock is held on
+
+	Disabled map[string]bool // collectors switched off via CRON_DISABLED_COLLECTORS
+}
+
+// DefaultCronConfig mirrors the schedule the cron used before it was configurable
+func DefaultCronConfig() CronConfig {
+	return CronConfig{
+		ActiveInterval:      5 * time.Minute,
+		IdleInterval:        15 * time.Minute,
+		ActiveStartHour:     6,
+		ActiveEndHour:       23,
+		SavedTracksEvery:    1,
+		GenreBackfillEvery:  6,
+		GenreBatchSize:      50,
+		ArtistRefreshEvery:  12,
+		ArtistRefreshBatch:  20,
+		ArtistStaleAfter:    7 * 24 * time.Hour,
+		ReportHour:          7,
+		CanonicalEvery:      6,
+		LikedReconcileEvery: 72,
+		DiscoveryEvery:      144,
+		ReleaseRadarEvery:   72,
+		ReleaseRadarArtists: 50,
+		ReleaseRadarAfter:   7 * 24 * time.Hour,
+		MusicBrainzEvery:    6,
+		MusicBrainzBatch:    20,
+		TrackBackfillEvery:  6,
+		TrackBackfillBatch:  20,
+		BackfillMinSpare:    5,
+		TracksOnRepeatEvery: 12,
+		MilestonesEvery:     3,
+		GapsEvery:           12,
+		GapAfter:            6 * time.Hour,
+		FailureAlertAfter:   3,
+		DedupWindow:         10 * time.Second,
+		LeaderLock:          true,
+		Disabled:            map[string]bool{},
+	}
+}
+
+// LoadCronConfig reads the CRON_* environment variables on top of the defaults
+// and validates the result.
+//
+//	CRON_ACTIVE_INTERVAL       poll interval during active hours (e.g. 90s, 5m)
+//	CRON_IDLE_INTERVAL         poll interval outside active hours
+//	CRON_ACTIVE_HOURS          active window as "start-end", e.g. 6-23 or 22-2
+//	CRON_SAVED_TRACKS_EVERY    sync saved tracks every N cycles
+//	CRON_GENRE_BACKFILL_EVERY  backfill genres every N cycles
+//	CRON_GENRE_BATCH_SIZE      tracks per genre backfill run
+//	CRON_ARTIST_REFRESH_EVERY  re-fetch stale cached artists every N cycles
+//	CRON_ARTIST_REFRESH_BATCH  artists re-fetched per run
+//	CRON_ARTIST_STALE_AFTER    age after which a cached artist is stale (e.g. 168h)
+//	CRON_REPORT_HOUR           hour after which yesterday's daily report is generated
+//	CRON_CANONICAL_EVERY       resolve ISRCs for canonical_tracks every N cycles
+//	CRON_LIKED_RECONCILE_EVERY detect tracks unliked on Spotify every N cycles
+//	CRON_DISCOVERY_EVERY       refresh the new-music discovery feed every N cycles
+//	CRON_RELEASE_RADAR_EVERY   look for new releases by my top artists every N cycles
+//	CRON_RELEASE_RADAR_ARTISTS top artists whose releases are followed
+//	CRON_RELEASE_RADAR_AFTER   age after which an artist's releases are checked again (e.g. 168h)
+//	CRON_MUSICBRAINZ_EVERY     look up release years/labels on MusicBrainz every N cycles
+//	CRON_MUSICBRAINZ_BATCH     ISRCs looked up per MusicBrainz run
+//	CRON_TRACK_BACKFILL_EVERY  fill missing album covers/genres/release dates on plays every N cycles
+//	CRON_TRACK_BACKFILL_BATCH  tracks per play backfill run
+//	CRON_BACKFILL_MIN_SPARE    spare Spotify requests needed before a backfill runs
+//	CRON_TRACKS_ON_REPEAT_EVERY recompute tracks_on_repeat counters every N cycles
+//	CRON_MILESTONES_EVERY      detect listening milestones every N cycles
+//	CRON_GAPS_EVERY            look for collection gaps every N cycles
+//	CRON_GAP_AFTER             active hours without plays before it's a gap (e.g. 6h)
+//	CRON_FAILURE_ALERT_AFTER   consecutive failed collections before webhooks are told
+//	CRON_DEDUP_WINDOW          ignore a play of the same track this soon after another (e.g. 10s, 0 to keep all)
+//	CRON_LEADER_LOCK           false to collect without taking the collector lock
+//	CRON_LOCK_DATABASE_URL     connection for the lock (default: DATABASE_URL, direct endpoint)
+//	CRON_DISABLED_COLLECTORS   comma-separated collector names to skip
+//
+// Active hours and the report hour are read in TIMEZONE (see LoadTimezone).
+func LoadCronConfig() (CronConfig, error) {
+	cfg := DefaultCronConfig()
+
+	var err error
+	if _, err = LoadTimezone(); err != nil {
+		return cfg, err
+	}
+	if cfg.ActiveInterval, err = envDuration("CRON_ACTIVE_INTERVAL", cfg.ActiveInterval); err != nil {
+		return cfg, err
+	}
+	if cfg.IdleInterval, err = envDuration("CRON_IDLE_INTERVAL", cfg.IdleInterval); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("CRON_ACTIVE_HOURS"); v != "" {
+		start, end, ok := strings.Cut(v, "-")
+		if !ok {
+			return cfg, fmt.Errorf("CRON_ACTIVE_HOURS must look like 6-23, got %q", v)
+		}
+		if cfg.ActiveStartHour, err = strconv.Atoi(strings.TrimSpace(start)); err != nil {
+			return cfg, fmt.Errorf("CRON_ACTIVE_HOURS: invalid start hour %q", start)
+		}
+		if cfg.ActiveEndHour, err = strconv.Atoi(strings.TrimSpace(end)); err != nil {
+			return cfg, fmt.Errorf("CRON_ACTIVE_HOURS: invalid end hour %q", end)
+		}
+	}
+	if cfg.SavedTracksEvery, err = envInt("CRON_SAVED_TRACKS_EVERY", cfg.SavedTracksEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.GenreBackfillEvery, err = envInt("CRON_GENRE_BACKFILL_EVERY", cfg.GenreBackfillEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.GenreBatchSize, err = envInt("CRON_GENRE_BATCH_SIZE", cfg.GenreBatchSize); err != nil {
+		return cfg, err
+	}
+	if cfg.ArtistRefreshEvery, err = envInt("CRON_ARTIST_REFRESH_EVERY", cfg.ArtistRefreshEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.ArtistRefreshBatch, err = envInt("CRON_ARTIST_REFRESH_BATCH", cfg.ArtistRefreshBatch); err != nil {
+		return cfg, err
+	}
+	if cfg.ArtistStaleAfter, err = envDuration("CRON_ARTIST_STALE_AFTER", cfg.ArtistStaleAfter); err != nil {
+		return cfg, err
+	}
+	if cfg.ReportHour, err = envInt("CRON_REPORT_HOUR", cfg.ReportHour); err != nil {
+		return cfg, err
+	}
+	if cfg.CanonicalEvery, err = envInt("CRON_CANONICAL_EVERY", cfg.CanonicalEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.LikedReconcileEvery, err = envInt("CRON_LIKED_RECONCILE_EVERY", cfg.LikedReconcileEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.DiscoveryEvery, err = envInt("CRON_DISCOVERY_EVERY", cfg.DiscoveryEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.ReleaseRadarEvery, err = envInt("CRON_RELEASE_RADAR_EVERY", cfg.ReleaseRadarEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.ReleaseRadarArtists, err = envInt("CRON_RELEASE_RADAR_ARTISTS", cfg.ReleaseRadarArtists); err != nil {
+		return cfg, err
+	}
+	if cfg.ReleaseRadarAfter, err = envDuration("CRON_RELEASE_RADAR_AFTER", cfg.ReleaseRadarAfter); err != nil {
+		return cfg, err
+	}
+	if cfg.MusicBrainzEvery, err = envInt("CRON_MUSICBRAINZ_EVERY", cfg.MusicBrainzEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.MusicBrainzBatch, err = envInt("CRON_MUSICBRAINZ_BATCH", cfg.MusicBrainzBatch); err != nil {
+		return cfg, err
+	}
+	if cfg.TrackBackfillEvery, err = envInt("CRON_TRACK_BACKFILL_EVERY", cfg.TrackBackfillEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.TrackBackfillBatch, err = envInt("CRON_TRACK_BACKFILL_BATCH", cfg.TrackBackfillBatch); err != nil {
+		return cfg, err
+	}
+	if cfg.BackfillMinSpare, err = envInt("CRON_BACKFILL_MIN_SPARE", cfg.BackfillMinSpare); err != nil {
+		return cfg, err
+	}
+	if cfg.TracksOnRepeatEvery, err = envInt("CRON_TRACKS_ON_REPEAT_EVERY", cfg.TracksOnRepeatEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.MilestonesEvery, err = envInt("CRON_MILESTONES_EVERY", cfg.MilestonesEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.GapsEvery, err = envInt("CRON_GAPS_EVERY", cfg.GapsEvery); err != nil {
+		return cfg, err
+	}
+	if cfg.GapAfter, err = envDuration("CRON_GAP_AFTER", cfg.GapAfter); err != nil {
+		return cfg, err
+	}
+	if cfg.FailureAlertAfter, err = envInt("CRON_FAILURE_ALERT_AFTER", cfg.FailureAlertAfter); err != nil {
+		return cfg, err
+	}
+	if cfg.DedupWindow, err = envDuration("CRON_DEDUP_WINDOW", cfg.DedupWindow); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("CRON_LEADER_LOCK"); v != "" {
+		if cfg.LeaderLock, err = strconv.ParseBool(v); err != nil {
+			return cfg, fmt.Errorf("CRON_LEADER_LOCK: invalid boolean %q", v)
+		}
+	}
+	cfg.LockDatabaseURL = os.Getenv("CRON_LOCK_DATABASE_URL")
+	if cfg.LockDatabaseURL == "" {
+		cfg.LockDatabaseURL = DirectURL(os.Getenv("DATABASE_URL"))
+	}
+	if v := os.Getenv("CRON_DISABLED_COLLECTORS"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.Disabled[name] = true
+			}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports the first invalid setting, if any
+func (c CronConfig) Validate() error {
+	if c.ActiveInterval < 10*time.Second {
+		return fmt.Errorf("cron active interval must be at least 10s, got %v", c.ActiveInterval)
+	}
+	if c.IdleInterval < 10*time.Second {
+		return fmt.Errorf("cron idle interval must be at least 10s, got %v", c.IdleInterval)
+	}
+	if c.ActiveStartHour < 0 || c.ActiveStartHour > 23 || c.ActiveEndHour < 0 || c.ActiveEndHour > 23 {
+		return fmt.Errorf("cron active hours must be between 0 and 23, got %d-%d", c.ActiveStartHour, c.ActiveEndHour)
+	}
+	if c.SavedTracksEvery < 1 {
+		return fmt.Errorf("CRON_SAVED_TRACKS_EVERY must be >= 1, got %d", c.SavedTracksEvery)
+	}
+	if c.GenreBackfillEvery < 1 {
+		return fmt.Errorf("CRON_GENRE_BACKFILL_EVERY must be >= 1, got %d", c.GenreBackfillEvery)
+	}
+	if c.GenreBatchSize < 1 {
+		return fmt.Errorf("CRON_GENRE_BATCH_SIZE must be >= 1, got %d", c.GenreBatchSize)
+	}
+	if c.ArtistRefreshEvery < 1 {
+		return fmt.Errorf("CRON_ARTIST_REFRESH_EVERY must be >= 1, got %d", c.ArtistRefreshEvery)
+	}
+	if c.ArtistRefreshBatch < 1 {
+		return fmt.Errorf("CRON_ARTIST_REFRESH_BATCH must be >= 1, got %d", c.ArtistRefreshBatch)
+	}
+	if c.ArtistStaleAfter < time.Hour {
+		return fmt.Errorf("CRON_ARTIST_STALE_AFTER must be at least 1h, got %v", c.ArtistStaleAfter)
+	}
+	if c.ReportHour < 0 || c.ReportHour > 23 {
+		return fmt.Errorf("CRON_REPORT_HOUR must be between 0 and 23, got %d", c.ReportHour)
+	}
+	if c.CanonicalEvery < 1 {
+		return fmt.Errorf("CRON_CANONICAL_EVERY must be >= 1, got %d", c.CanonicalEvery)
+	}
+	if c.LikedReconcileEvery < 1 {
+		return fmt.Errorf("CRON_LIKED_RECONCILE_EVERY must be >= 1, got %d", c.LikedReconcileEvery)
+	}
+	if c.DiscoveryEvery < 1 {
+		return fmt.Errorf("CRON_DISCOVERY_EVERY must be >= 1, got %d", c.DiscoveryEvery)
+	}
+	if c.ReleaseRadarEvery < 1 {
+		return fmt.Errorf("CRON_RELEASE_RADAR_EVERY must be >= 1, got %d", c.ReleaseRadarEvery)
+	}
+	if c.ReleaseRadarArtists < 1 {
+		return fmt.Errorf("CRON_RELEASE_RADAR_ARTISTS must be >= 1, got %d", c.ReleaseRadarArtists)
+	}
+	if c.ReleaseRadarAfter < time.Hour {
+		return fmt.Errorf("CRON_RELEASE_RADAR_AFTER must be at least 1h, got %v", c.ReleaseRadarAfter)
+	}
+	if c.MusicBrainzEvery < 1 {
+		return fmt.Errorf("CRON_MUSICBRAINZ_EVERY must be >= 1, got %d", c.MusicBrainzEvery)
+	}
+	if c.MusicBrainzBatch < 1 {
+		return fmt.Errorf("CRON_MUSICBRAINZ_BATCH must be >= 1, got %d", c.MusicBrainzBatch)
+	}
+	if c.TrackBackfillEvery < 1 {
+		return fmt.Errorf("CRON_TRACK_BACKFILL_EVERY must be >= 1, got %d", c.TrackBackfillEvery)
+	}
+	if c.TrackBackfillBatch < 1 {
+		return fmt.Errorf("CRON_TRACK_BACKFILL_BATCH must be >= 1, got %d", c.TrackBackfillBatch)
+	}
+	if c.BackfillMinSpare < 0 {
+		return fmt.Errorf("CRON_BACKFILL_MIN_SPARE must be >= 0, got %d", c.BackfillMinSpare)
+	}
+	if c.TracksOnRepeatEvery < 1 {
+		return fmt.Errorf("CRON_TRACKS_ON_REPEAT_EVERY must be >= 1, got %d", c.TracksOnRepeatEvery)
+	}
+	if c.MilestonesEvery < 1 {
+		return fmt.Errorf("CRON_MILESTONES_EVERY must be >= 1, got %d", c.MilestonesEvery)
+	}
+	if c.GapsEvery < 1 {
+		return fmt.Errorf("CRON_GAPS_EVERY must be >= 1, got %d", c.GapsEvery)
+	}
+	if c.GapAfter < time.Hour {
+		return fmt.Errorf("CRON_GAP_AFTER must be at least 1h, got %v", c.GapAfter)
+	}
+	if c.FailureAlertAfter < 1 {
+		return fmt.Errorf("CRON_FAILURE_ALERT_AFTER must be >= 1, got %d", c.FailureAlertAfter)
+	}
+	// Any real replay is a whole track apart; a longer window would eat those
+	if c.DedupWindow < 0 || c.DedupWindow > MaxDedupWindow {
+		return fmt.Errorf("CRON_DEDUP_WINDOW must be between 0 and %v, got %v", MaxDedupWindow, c.DedupWindow)
+	}
+	for name := range c.Disabled {
+		if !isKnownCollector(name) {
+			return fmt.Errorf("unknown collector %q in CRON_DISABLED_COLLECTORS (known: %s)",
+				name, strings.Join(knownCollectors, ", "))
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether the named collector should run
+func (c CronConfig) Enabled(collector string) bool {
+	return !c.Disabled[collector]
+}
+
+// IsActiveHour reports whether hour falls inside the active window.
+// Windows that wrap past midnight (e.g. 22-2) are supported.
+func (c CronConfig) IsActiveHour(hour int) bool {
+	if c.ActiveStartHour <= c.ActiveEndHour {
+		return hour >= c.ActiveStartHour && hour <= c.ActiveEndHour
+	}
+	return hour >= c.ActiveStartHour || hour <= c.ActiveEndHour
+}
+
+// ActiveDuration returns how much of [from, to) falls inside the active
+// window, read in TIMEZONE
+func (c CronConfig) ActiveDuration(from, to time.Time) time.Duration {
+	loc := Timezone()
+	var total time.Duration
+	for t := from.In(loc); t.Before(to); {
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		if !next.After(t) { // DST
+			next = t.Add(time.Hour)
+		}
+		if next.After(to) {
+			next = to
+		}
+		if c.IsActiveHour(t.Hour()) {
+			total += next.Sub(t)
+		}
+		t = next
+	}
+	return total
+}
+
+// IntervalAt returns the poll interval to use at time t
+func (c CronConfig) IntervalAt(t time.Time) time.Duration {
+	if c.IsActiveHour(t.In(Timezone()).Hour()) {
+		return c.ActiveInterval
+	}
+	return c.IdleInterval
+}
+
+// IsCollector reports whether name is a collector CRON_DISABLED_COLLECTORS accepts
+func IsCollector(name string) bool {
+	return isKnownCollector(name)
+}
+
+// Collectors returns every collector name
+func Collectors() []string {
+	return slices.Clone(knownCollectors)
+}
+
+func isKnownCollector(name string) bool {
+	for _, known := range knownCollectors {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def, fmt.Errorf("%s: invalid duration %q: %v", key, v, err)
+	}
+	return d, nil
+}
+
+func envInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def, fmt.Errorf("%s: invalid integer %q", key, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DBConfig tunes the Postgres connection pool
+type DBConfig struct {
+	MaxConns          int           // upper bound on open connections
+	MinConns          int           // connections kept open while idle; 0 lets Neon suspend
+	MaxConnLifetime   time.Duration // connections are recycled after this long
+	MaxConnIdleTime   time.Duration // idle connections are closed after this long
+	HealthCheckPeriod time.Duration // how often idle connections are checked
+	SimpleProtocol    bool          // skip prepared statements, for transaction-mode poolers
+}
+
+// DefaultDBConfig keeps the pool small; one cron and a handful of readers
+// never need more
+func DefaultDBConfig() DBConfig {
+	return DBConfig{
+		MaxConns:          10,
+		MinConns:          0,
+		MaxConnLifetime:   30 * time.Minute,
+		MaxConnIdleTime:   5 * time.Minute,
+		HealthCheckPeriod: time.Minute,
+	}
+}
+
+// LoadDBConfig reads the DB_* environment variables on top of the defaults
+// and validates the result.
+//
+//	DB_MAX_CONNS            maximum open connections (default 10)
+//	DB_MIN_CONNS            connections kept open while idle (default 0)
+//	DB_MAX_CONN_LIFETIME    recycle connections after this long (e.g. 30m)
+//	DB_MAX_CONN_IDLE_TIME   close idle connections after this long (e.g. 5m)
+//	DB_HEALTH_CHECK_PERIOD  how often idle connections are checked (e.g. 1m)
+//	DB_SIMPLE_PROTOCOL      true to avoid prepared statements; defaults to true
+//	                        when DATABASE_URL points at a Neon -pooler host,
+//	                        whose pgbouncer runs in transaction mode
+func LoadDBConfig() (DBConfig, error) {
+	cfg := DefaultDBConfig()
+	cfg.SimpleProtocol = isPoolerURL(os.Getenv("DATABASE_URL"))
+
+	var err error
+	if cfg.MaxConns, err = envInt("DB_MAX_CONNS", cfg.MaxConns); err != nil {
+		return cfg, err
+	}
+	if cfg.MinConns, err = envInt("DB_MIN_CONNS", cfg.MinConns); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxConnLifetime, err = envDuration("DB_MAX_CONN_LIFETIME", cfg.MaxConnLifetime); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxConnIdleTime, err = envDuration("DB_MAX_CONN_IDLE_TIME", cfg.MaxConnIdleTime); err != nil {
+		return cfg, err
+	}
+	if cfg.HealthCheckPeriod, err = envDuration("DB_HEALTH_CHECK_PERIOD", cfg.HealthCheckPeriod); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("DB_SIMPLE_PROTOCOL"); v != "" {
+		if cfg.SimpleProtocol, err = strconv.ParseBool(v); err != nil {
+			return cfg, fmt.Errorf("DB_SIMPLE_PROTOCOL: invalid boolean %q", v)
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports the first invalid setting, if any
+func (c DBConfig) Validate() error {
+	if c.MaxConns < 1 {
+		return fmt.Errorf("DB_MAX_CONNS must be >= 1, got %d", c.MaxConns)
+	}
+	if c.MinConns < 0 || c.MinConns > c.MaxConns {
+		return fmt.Errorf("DB_MIN_CONNS must be between 0 and DB_MAX_CONNS (%d), got %d", c.MaxConns, c.MinConns)
+	}
+	if c.MaxConnLifetime < time.Minute {
+		return fmt.Errorf("DB_MAX_CONN_LIFETIME must be at least 1m, got %v", c.MaxConnLifetime)
+	}
+	if c.MaxConnIdleTime < 10*time.Second {
+		return fmt.Errorf("DB_MAX_CONN_IDLE_TIME must be at least 10s, got %v", c.MaxConnIdleTime)
+	}
+	if c.HealthCheckPeriod < time.Second {
+		return fmt.Errorf("DB_HEALTH_CHECK_PERIOD must be at least 1s, got %v", c.HealthCheckPeriod)
+	}
+	return nil
+}
+
+// DirectURL returns dsn pointed at Neon's direct endpoint rather than its
+// pooler, for session state such as advisory locks that pgbouncer's
+// transaction mode can't keep. Other URLs are returned unchanged.
+func DirectURL(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || !isPoolerURL(dsn) {
+		return dsn
+	}
+	host := strings.Replace(u.Hostname(), "-pooler", "", 1)
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	return u.String()
+}
+
+// isPoolerURL reports whether dsn targets a Neon pooled endpoint
+func isPoolerURL(dsn string) bool {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(u.Hostname(), "-pooler")
+}
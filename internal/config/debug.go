@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DebugCaptureAll in SPOTIFY_DEBUG_CAPTURE captures every endpoint
+const DebugCaptureAll = "all"
+
+// DebugCaptureConfig controls keeping raw Spotify response bodies for
+// debugging. Nothing is captured unless Endpoints is set.
+type DebugCaptureConfig struct {
+	Endpoints []string // Spotify call labels to capture (as in the metrics), or DebugCaptureAll
+	Buffer    int      // payloads kept in memory for GET /admin/debug/last-payloads
+	MaxBytes  int      // longer bodies are cut to this many bytes
+	Dir       string   // when set, every captured payload is also written here
+}
+
+// DefaultDebugCaptureConfig captures nothing
+func DefaultDebugCaptureConfig() DebugCaptureConfig {
+	return DebugCaptureConfig{
+		Buffer:   50,
+		MaxBytes: 256 << 10,
+	}
+}
+
+// LoadDebugCaptureConfig reads the debug capture environment variables on
+// top of the defaults and validates the result.
+//
+//	SPOTIFY_DEBUG_CAPTURE     comma-separated endpoints to capture (e.g. player,recently_played) or all
+//	SPOTIFY_DEBUG_BUFFER      payloads kept in memory (default 50)
+//	SPOTIFY_DEBUG_MAX_BYTES   longest body kept, in bytes (default 262144)
+//	SPOTIFY_DEBUG_DIR         directory every captured payload is also written to
+func LoadDebugCaptureConfig() (DebugCaptureConfig, error) {
+	cfg := DefaultDebugCaptureConfig()
+	for _, e := range strings.Split(os.Getenv("SPOTIFY_DEBUG_CAPTURE"), ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			cfg.Endpoints = append(cfg.Endpoints, e)
+		}
+	}
+	cfg.Dir = strings.TrimSpace(os.Getenv("SPOTIFY_DEBUG_DIR"))
+
+	var err error
+	if cfg.Buffer, err = envInt("SPOTIFY_DEBUG_BUFFER", cfg.Buffer); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxBytes, err = envInt("SPOTIFY_DEBUG_MAX_BYTES", cfg.MaxBytes); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Enabled reports whether any endpoint is captured
+func (c DebugCaptureConfig) Enabled() bool {
+	return len(c.Endpoints) > 0
+}
+
+// Captures reports whether responses of endpoint are captured
+func (c DebugCaptureConfig) Captures(endpoint string) bool {
+	return slices.Contains(c.Endpoints, DebugCaptureAll) || slices.Contains(c.Endpoints, endpoint)
+}
+
+// Validate reports the first invalid setting, if any
+func (c DebugCaptureConfig) Validate() error {
+	if c.Buffer < 1 || c.Buffer > 1000 {
+		return fmt.Errorf("SPOTIFY_DEBUG_BUFFER must be between 1 and 1000, got %d", c.Buffer)
+	}
+	if c.MaxBytes < 1024 {
+		return fmt.Errorf("SPOTIFY_DEBUG_MAX_BYTES must be at least 1024, got %d", c.MaxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Variables without which nothing useful runs
+var (
+	EnvDatabase = []string{"DATABASE_URL"}
+	EnvSpotify  = []string{"SPOTIFY_CLIENT_ID", "SPOTIFY_CLIENT_SECRET"}
+)
+
+// MissingEnvError lists required variables that are unset
+type MissingEnvError struct {
+	Keys    []string
+	EnvFile string // the .env file consulted, empty if none was found
+}
+
+func (e *MissingEnvError) Error() string {
+	source := "no .env file found"
+	if e.EnvFile != "" {
+		source = "not in " + e.EnvFile + " either"
+	}
+	return fmt.Sprintf("missing required environment variables: %s (%s). "+
+		"Set them in the environment or in a .env file; see .env.example",
+		strings.Join(e.Keys, ", "), source)
+}
+
+// LoadEnv layers configuration: variables already in the environment win,
+// and unset ones are filled from the .env file (ENV_FILE, default .env) when
+// it exists. It then checks every required key is set.
+func LoadEnv(required ...[]string) error {
+	path := os.Getenv("ENV_FILE")
+	if path == "" {
+		path = ".env"
+	}
+
+	loaded := ""
+	if err := godotenv.Load(path); err == nil {
+		loaded = path
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var missing []string
+	for _, keys := range required {
+		for _, key := range keys {
+			if strings.TrimSpace(os.Getenv(key)) == "" {
+				missing = append(missing, key)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingEnvError{Keys: missing, EnvFile: loaded}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultAllowedOrigins are the frontends allowed when CORS_ALLOWED_ORIGINS is unset
+var DefaultAllowedOrigins = []string{
+	"http://localhost:3000",
+	"http://localhost:3001",
+	"https://mtejeda.co",
+	"https://www.mtejeda.co",
+}
+
+// HTTPConfig controls who may call the API
+type HTTPConfig struct {
+	AllowedOrigins []string // CORS origins; "*" allows any
+	APIKey         string   // required for write and admin endpoints
+	SwaggerUI      bool     // serve Swagger UI at /api/v1/docs
+
+	RateLimit       int            // requests per minute per IP, every route
+	WriteRateLimit  int            // requests per minute per IP for writes and admin reads
+	RouteRateLimits map[string]int // requests per minute per IP for single routes, keyed "METHOD /path"
+	MaxBodyBytes    int64          // largest request body accepted
+	AdminAllowlist  []*net.IPNet   // networks allowed on admin routes and /save-refresh; empty allows any
+	TrustedProxies  []string       // proxies whose X-Forwarded-For is believed; nil trusts none
+}
+
+// LoadHTTPConfig reads the HTTP settings from the environment.
+//
+//	CORS_ALLOWED_ORIGINS     comma-separated origins, or * for any (default: DefaultAllowedOrigins)
+//	API_KEY                  key expected in X-API-Key or Authorization: Bearer
+//	SWAGGER_UI               true to serve Swagger UI at /api/v1/docs (default: false)
+//	RATE_LIMIT               requests per minute per IP (default: 100)
+//	RATE_LIMIT_WRITES        requests per minute per IP for writes and admin reads (default: 20)
+//	RATE_LIMIT_ROUTES        comma-separated METHOD /path=N limits for single routes, e.g.
+//	                         "GET /export/recently-played=5,POST /graphql=30" (default: none)
+//	MAX_BODY_BYTES           largest request body in bytes (default: 1048576)
+//	ADMIN_ALLOWED_IPS        comma-separated IPs or CIDRs allowed on /admin and /save-refresh (default: any)
+//	TRUSTED_PROXIES          comma-separated IPs or CIDRs of reverse proxies (default: none)
+func LoadHTTPConfig() (HTTPConfig, error) {
+	cfg := HTTPConfig{
+		AllowedOrigins: DefaultAllowedOrigins,
+		APIKey:         os.Getenv("API_KEY"),
+		SwaggerUI:      os.Getenv("SWAGGER_UI") == "true",
+	}
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		cfg.AllowedOrigins = nil
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+				cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+			}
+		}
+	}
+
+	var err error
+	if cfg.RateLimit, err = envInt("RATE_LIMIT", 100); err != nil {
+		return cfg, err
+	}
+	if cfg.WriteRateLimit, err = envInt("RATE_LIMIT_WRITES", 20); err != nil {
+		return cfg, err
+	}
+	if cfg.RouteRateLimits, err = parseRouteLimits(os.Getenv("RATE_LIMIT_ROUTES")); err != nil {
+		return cfg, err
+	}
+	maxBody, err := envInt("MAX_BODY_BYTES", 1<<20)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.MaxBodyBytes = int64(maxBody)
+	if cfg.AdminAllowlist, err = envNetworks("ADMIN_ALLOWED_IPS"); err != nil {
+		return cfg, err
+	}
+	proxies, err := envNetworks("TRUSTED_PROXIES")
+	if err != nil {
+		return cfg, err
+	}
+	for _, n := range proxies {
+		cfg.TrustedProxies = append(cfg.TrustedProxies, n.String())
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate reports limits no request could pass
+func (cfg HTTPConfig) Validate() error {
+	if cfg.RateLimit < 1 {
+		return fmt.Errorf("RATE_LIMIT must be at least 1")
+	}
+	if cfg.WriteRateLimit < 1 {
+		return fmt.Errorf("RATE_LIMIT_WRITES must be at least 1")
+	}
+	if cfg.MaxBodyBytes < 1024 {
+		return fmt.Errorf("MAX_BODY_BYTES must be at least 1024")
+	}
+	return nil
+}
+
+// AdminAllowed reports whether ip may call admin routes
+func (cfg HTTPConfig) AdminAllowed(ip net.IP) bool {
+	if len(cfg.AdminAllowlist) == 0 {
+		return true
+	}
+	for _, n := range cfg.AdminAllowlist {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRouteLimits reads "METHOD /path=N" entries separated by commas. Paths
+// are the route patterns without /api/v1, e.g. /tracks/:id.
+func parseRouteLimits(v string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		route, n, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		path = strings.TrimSpace(path)
+		if !ok || !hasPath || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES: invalid entry %q (expected METHOD /path=N)", entry)
+		}
+		perMinute, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || perMinute < 1 {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES: invalid limit in %q (expected a positive integer)", entry)
+		}
+		limits[strings.ToUpper(method)+" "+path] = perMinute
+	}
+	return limits, nil
+}
+
+// envNetworks reads comma-separated IPs or CIDRs; a bare IP is a /32 (or /128)
+func envNetworks(key string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("%s: invalid IP %q", key, v)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid CIDR %q", key, v)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
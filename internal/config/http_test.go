@@ -0,0 +1,23 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRouteLimits(t *testing.T) {
+	got, err := parseRouteLimits(" get /export/recently-played=5, POST /graphql = 30 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"GET /export/recently-played": 5, "POST /graphql": 30}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRouteLimits() = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"/graphql=5", "GET graphql=5", "GET /graphql", "GET /graphql=0", "GET /graphql=x"} {
+		if _, err := parseRouteLimits(bad); err == nil {
+			t.Errorf("parseRouteLimits(%q) accepted it", bad)
+		}
+	}
+}
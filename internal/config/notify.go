@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NotifyConfig controls where alerts go and what triggers them
+type NotifyConfig struct {
+	DiscordURL string // Discord incoming webhook
+	SlackURL   string // Slack incoming webhook
+	HTTPURL    string // any endpoint accepting the JSON notification
+
+	TokenFailures int           // failed token refreshes in a row before alerting
+	NoTracksAfter time.Duration // alert when nothing has been collected for this long
+	DailyReport   bool          // also send the daily report summary
+	Milestones    bool          // also announce listening milestones
+}
+
+// DefaultNotifyConfig sends nothing until a URL is set
+func DefaultNotifyConfig() NotifyConfig {
+	return NotifyConfig{
+		TokenFailures: 3,
+		NoTracksAfter: 24 * time.Hour,
+	}
+}
+
+// LoadNotifyConfig reads the NOTIFY_* environment variables on top of the
+// defaults and validates the result.
+//
+//	NOTIFY_DISCORD_WEBHOOK_URL  Discord incoming webhook for alerts
+//	NOTIFY_SLACK_WEBHOOK_URL    Slack incoming webhook for alerts
+//	NOTIFY_HTTP_URL             generic endpoint that receives the JSON notification
+//	NOTIFY_TOKEN_FAILURES       failed refresh-token refreshes in a row before alerting
+//	NOTIFY_NO_TRACKS_AFTER      alert when no plays have been collected for this long (e.g. 24h)
+//	NOTIFY_DAILY_REPORT         true to also send the daily report summary
+//	NOTIFY_MILESTONES           true to also announce listening milestones
+func LoadNotifyConfig() (NotifyConfig, error) {
+	cfg := DefaultNotifyConfig()
+	cfg.DiscordURL = os.Getenv("NOTIFY_DISCORD_WEBHOOK_URL")
+	cfg.SlackURL = os.Getenv("NOTIFY_SLACK_WEBHOOK_URL")
+	cfg.HTTPURL = os.Getenv("NOTIFY_HTTP_URL")
+
+	var err error
+	if cfg.TokenFailures, err = envInt("NOTIFY_TOKEN_FAILURES", cfg.TokenFailures); err != nil {
+		return cfg, err
+	}
+	if cfg.NoTracksAfter, err = envDuration("NOTIFY_NO_TRACKS_AFTER", cfg.NoTracksAfter); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("NOTIFY_DAILY_REPORT"); v != "" {
+		if cfg.DailyReport, err = strconv.ParseBool(v); err != nil {
+			return cfg, fmt.Errorf("NOTIFY_DAILY_REPORT: invalid boolean %q", v)
+		}
+	}
+	if v := os.Getenv("NOTIFY_MILESTONES"); v != "" {
+		if cfg.Milestones, err = strconv.ParseBool(v); err != nil {
+			return cfg, fmt.Errorf("NOTIFY_MILESTONES: invalid boolean %q", v)
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports the first invalid setting, if any
+func (c NotifyConfig) Validate() error {
+	if c.TokenFailures < 1 {
+		return fmt.Errorf("NOTIFY_TOKEN_FAILURES must be >= 1, got %d", c.TokenFailures)
+	}
+	if c.NoTracksAfter < time.Hour {
+		return fmt.Errorf("NOTIFY_NO_TRACKS_AFTER must be at least 1h, got %v", c.NoTracksAfter)
+	}
+	return nil
+}
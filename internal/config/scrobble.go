@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ScrobbleConfig controls forwarding collected plays to Last.fm and
+// ListenBrainz
+type ScrobbleConfig struct {
+	LastFMAPIKey     string
+	LastFMAPISecret  string
+	LastFMSessionKey string // from `spotifydb lastfm-auth`
+
+	ListenBrainzToken string // user token from listenbrainz.org/settings
+	ListenBrainzURL   string // API root, for self-hosted servers
+
+	MaxAttempts int           // failed sends of a play before it's left as failed
+	MaxAge      time.Duration // plays older than this are never sent; Last.fm rejects them
+}
+
+// DefaultScrobbleConfig sends nothing until credentials are set
+func DefaultScrobbleConfig() ScrobbleConfig {
+	return ScrobbleConfig{
+		ListenBrainzURL: "https://api.listenbrainz.org",
+		MaxAttempts:     5,
+		MaxAge:          14 * 24 * time.Hour,
+	}
+}
+
+// LastFMEnabled reports whether plays are scrobbled to Last.fm
+func (c ScrobbleConfig) LastFMEnabled() bool {
+	return c.LastFMSessionKey != ""
+}
+
+// ListenBrainzEnabled reports whether plays are submitted to ListenBrainz
+func (c ScrobbleConfig) ListenBrainzEnabled() bool {
+	return c.ListenBrainzToken != ""
+}
+
+// LoadScrobbleConfig reads the scrobbling environment variables on top of the
+// defaults and validates the result.
+//
+//	LASTFM_API_KEY           Last.fm API account key
+//	LASTFM_API_SECRET        Last.fm API account shared secret
+//	LASTFM_SESSION_KEY       session key for my Last.fm user; scrobbling is off without it
+//	LISTENBRAINZ_TOKEN       ListenBrainz user token; submitting is off without it
+//	LISTENBRAINZ_API_URL     ListenBrainz API root (default https://api.listenbrainz.org)
+//	SCROBBLE_MAX_ATTEMPTS    failed sends of a play before giving up on it
+//	SCROBBLE_MAX_AGE         never send plays older than this to Last.fm (at most 336h)
+func LoadScrobbleConfig() (ScrobbleConfig, error) {
+	cfg := DefaultScrobbleConfig()
+	cfg.LastFMAPIKey = os.Getenv("LASTFM_API_KEY")
+	cfg.LastFMAPISecret = os.Getenv("LASTFM_API_SECRET")
+	cfg.LastFMSessionKey = os.Getenv("LASTFM_SESSION_KEY")
+	cfg.ListenBrainzToken = os.Getenv("LISTENBRAINZ_TOKEN")
+	if v := os.Getenv("LISTENBRAINZ_API_URL"); v != "" {
+		cfg.ListenBrainzURL = v
+	}
+
+	var err error
+	if cfg.MaxAttempts, err = envInt("SCROBBLE_MAX_ATTEMPTS", cfg.MaxAttempts); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAge, err = envDuration("SCROBBLE_MAX_AGE", cfg.MaxAge); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports the first invalid setting, if any
+func (c ScrobbleConfig) Validate() error {
+	if c.LastFMSessionKey != "" && (c.LastFMAPIKey == "" || c.LastFMAPISecret == "") {
+		return fmt.Errorf("LASTFM_SESSION_KEY needs LASTFM_API_KEY and LASTFM_API_SECRET")
+	}
+	if u, err := url.Parse(c.ListenBrainzURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("LISTENBRAINZ_API_URL must be an http(s) URL, got %q", c.ListenBrainzURL)
+	}
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("SCROBBLE_MAX_ATTEMPTS must be >= 1, got %d", c.MaxAttempts)
+	}
+	if c.MaxAge < time.Hour || c.MaxAge > 14*24*time.Hour {
+		return fmt.Errorf("SCROBBLE_MAX_AGE must be between 1h and 336h, got %v", c.MaxAge)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var timezone struct {
+	once sync.Once
+	loc  *time.Location
+}
+
+// LoadTimezone reads TIMEZONE, the IANA name (e.g. Europe/Madrid) that stats
+// are bucketed by and active hours are read in. Defaults to UTC.
+func LoadTimezone() (*time.Location, error) {
+	name := os.Getenv("TIMEZONE")
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC, fmt.Errorf("TIMEZONE: unknown timezone %q: %v", name, err)
+	}
+	return loc, nil
+}
+
+// Timezone returns the configured timezone. An invalid TIMEZONE falls back to
+// UTC here; LoadCronConfig reports it at startup.
+func Timezone() *time.Location {
+	timezone.once.Do(func() {
+		timezone.loc, _ = LoadTimezone()
+	})
+	return timezone.loc
+}
+
+// Today returns midnight of the current day in the configured timezone
+func Today() time.Time {
+	now := time.Now().In(Timezone())
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
@@ -0,0 +1,107 @@
+// Package diversity computes how varied listening is from play counts: how
+// evenly plays spread over genres, how much the favourites dominate and how
+// often tracks come back. Every function takes plain counts, so the numbers
+// don't depend on where they were aggregated.
+package diversity
+
+import (
+	"math"
+	"sort"
+)
+
+// Entropy is the Shannon entropy, in bits, of plays spread over categories
+// with the given counts. Zero counts are ignored.
+func Entropy(counts []int) float64 {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	h := 0.0
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(total)
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// Evenness is Entropy scaled to [0, 1] by its maximum for that many
+// categories: 1 when plays are spread evenly, 0 when they all fall in one.
+// It stays comparable between windows with a different number of genres.
+func Evenness(counts []int) float64 {
+	k := 0
+	for _, n := range counts {
+		if n > 0 {
+			k++
+		}
+	}
+	if k < 2 {
+		return 0
+	}
+	return Entropy(counts) / math.Log2(float64(k))
+}
+
+// TopShare is the share of all plays taken by the n largest counts
+func TopShare(counts []int, n int) float64 {
+	sorted := append([]int(nil), counts...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	total, top := 0, 0
+	for i, c := range sorted {
+		total += c
+		if i < n {
+			top += c
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(top) / float64(total)
+}
+
+// RepeatRatio is the share of plays that repeat a track already played in
+// the same window: 0 when every play is a different track
+func RepeatRatio(plays, uniqueTracks int) float64 {
+	if plays == 0 {
+		return 0
+	}
+	return 1 - float64(uniqueTracks)/float64(plays)
+}
+
+// Mean averages values, 0 for none
+func Mean(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return float64(sum) / float64(len(values))
+}
+
+// Slope is the least-squares change per step of evenly spaced values, e.g.
+// how much a metric moves per window; 0 for fewer than two values
+func Slope(values []float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	return (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+}
+
+// Round rounds to 3 decimals for responses
+func Round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,113 @@
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+//go:embed schema.graphqls
+var schemaSDL string
+
+// Queries are capped so one request can't run away with the database:
+// fields nest at most maxDepth deep, and weigh at most maxComplexity, each
+// root field (a database query) rootFieldCost and any other 1
+const (
+	maxDepth      = 10
+	maxComplexity = 500
+	rootFieldCost = 25
+)
+
+// Request and Response are the JSON bodies of /graphql, for the OpenAPI spec
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type Response struct {
+	Data   map[string]any `json:"data"`
+	Errors []ErrorEntry   `json:"errors,omitempty"`
+}
+
+type ErrorEntry struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// Handler runs a query POSTed as {"query", "operationName", "variables"},
+// or sent in the same query parameters with GET
+func Handler() gin.HandlerFunc {
+	cfg := Config{Resolvers: &Resolver{}}
+	rootCost := func(childComplexity int) int { return rootFieldCost + childComplexity }
+	cfg.Complexity.Query.RecentlyPlayed = func(c int, _ *PlayFilter, _ *Pagination) int { return rootCost(c) }
+	cfg.Complexity.Query.RecentlyLiked = func(c int, _ *LikedFilter, _, _ *string, _ *Pagination) int { return rootCost(c) }
+	cfg.Complexity.Query.Stats = rootCost
+	cfg.Complexity.Query.Artists = func(c int, _, _, _ *string, _ *int, _ *bool) int { return rootCost(c) }
+	cfg.Complexity.Query.Artist = func(c int, _ string, _ *int, _ *bool) int { return rootCost(c) }
+
+	srv := handler.New(NewExecutableSchema(cfg))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(maxComplexity))
+	srv.Use(depthLimit(maxDepth))
+
+	return func(c *gin.Context) {
+		srv.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// Schema serves the schema in the GraphQL schema language
+func Schema(c *gin.Context) {
+	c.String(http.StatusOK, schemaSDL)
+}
+
+// depthLimit rejects operations whose fields nest deeper than it, counting
+// through fragments
+type depthLimit int
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit(0)
+
+func (depthLimit) ExtensionName() string { return "DepthLimit" }
+
+func (depthLimit) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (d depthLimit) MutateOperationContext(ctx context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	if depth := selectionDepth(oc.Operation.SelectionSet); depth > int(d) {
+		return gqlerror.Errorf("query is nested %d fields deep, more than the %d allowed", depth, int(d))
+	}
+	return nil
+}
+
+// selectionDepth is how deep fields nest in set. Validation has already
+// turned away fragment cycles.
+func selectionDepth(set ast.SelectionSet) int {
+	deepest := 0
+	for _, sel := range set {
+		var depth int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			depth = 1 + selectionDepth(sel.SelectionSet)
+		case *ast.InlineFragment:
+			depth = selectionDepth(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			if sel.Definition != nil {
+				depth = selectionDepth(sel.Definition.SelectionSet)
+			}
+		}
+		deepest = max(deepest, depth)
+	}
+	return deepest
+}
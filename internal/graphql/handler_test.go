@@ -0,0 +1,116 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func query(t *testing.T, q string) (int, Response) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/graphql", Handler())
+
+	req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(q), nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	return rec.Code, resp
+}
+
+func wantError(t *testing.T, resp Response, substr string) {
+	t.Helper()
+	for _, e := range resp.Errors {
+		if strings.Contains(e.Message, substr) {
+			return
+		}
+	}
+	t.Errorf("errors %+v, want one containing %q", resp.Errors, substr)
+}
+
+func TestInvalidQuery(t *testing.T) {
+	code, resp := query(t, `{ recentlyPlayed { nope } }`)
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("status %d, want 422", code)
+	}
+	wantError(t, resp, "nope")
+}
+
+func TestArgumentsCheckedBeforeQuerying(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{ recentlyPlayed(filter: {source: "radio"}) { total } }`, "invalid 'source'"},
+		{`{ recentlyPlayed(filter: {from: "2024-02-01", to: "2024-01-01"}) { total } }`, "'to' is before 'from'"},
+		{`{ recentlyPlayed(pagination: {offset: -1}) { total } }`, "invalid 'offset'"},
+		{`{ recentlyLiked(sort: "title") { total } }`, "invalid 'sort'"},
+		{`{ recentlyLiked(order: "up") { total } }`, "invalid 'order'"},
+		{`{ artists(source: "radio") { artist_name } }`, "invalid 'source'"},
+	}
+	for _, tt := range tests {
+		code, resp := query(t, tt.query)
+		if code != http.StatusOK {
+			t.Errorf("%s: status %d, want 200 with the field null", tt.query, code)
+		}
+		wantError(t, resp, tt.want)
+	}
+}
+
+// nested returns an introspection query whose fields nest depth deep
+func nested(depth int) string {
+	fields := append([]string{"__schema", "types", "fields", "type"}, slices.Repeat([]string{"ofType"}, depth-5)...)
+	return "{ " + strings.Join(fields, " { ") + " { name " + strings.Repeat("} ", depth)
+}
+
+func TestDepthLimit(t *testing.T) {
+	if code, resp := query(t, nested(10)); code != http.StatusOK || len(resp.Errors) > 0 {
+		t.Errorf("10 deep: status %d, errors %+v", code, resp.Errors)
+	}
+	_, resp := query(t, nested(11))
+	if resp.Data != nil {
+		t.Errorf("11 deep: ran, want refused")
+	}
+	wantError(t, resp, "nested 11 fields deep")
+
+	// spreads count toward the depth
+	_, resp = query(t, `{ ...A } fragment A on Query { `+strings.TrimPrefix(nested(11), "{ "))
+	wantError(t, resp, "nested 11 fields deep")
+}
+
+func TestComplexityLimit(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i < 20; i++ {
+		b.WriteString(" s" + string(rune('a'+i)) + ": stats { cache_age }")
+	}
+	b.WriteString(" }")
+	// 20 root fields weigh 20 * 26 = 520
+	_, resp := query(t, b.String())
+	if resp.Data != nil {
+		t.Errorf("ran, want refused")
+	}
+	wantError(t, resp, "complexity 520")
+}
+
+func TestSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/graphql/schema", Schema)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql/schema", nil))
+	if !strings.Contains(rec.Body.String(), "recentlyPlayed(filter: PlayFilter, pagination: Pagination): PlaysResponse") {
+		t.Errorf("schema is missing recentlyPlayed:\n%s", rec.Body)
+	}
+}
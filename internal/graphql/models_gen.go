@@ -0,0 +1,34 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graphql
+
+// Narrows recentlyLiked, as GET /recently-liked's query parameters do
+type LikedFilter struct {
+	Genre *string `json:"genre,omitempty"`
+	// name or Spotify artist id
+	Artist         *string `json:"artist,omitempty"`
+	AlbumType      *string `json:"album_type,omitempty"`
+	Source         *string `json:"source,omitempty"`
+	IncludeRemoved *bool   `json:"include_removed,omitempty"`
+}
+
+type Pagination struct {
+	// at most 500
+	Limit  *int `json:"limit,omitempty"`
+	Offset *int `json:"offset,omitempty"`
+}
+
+// Narrows recentlyPlayed, as GET /plays' query parameters do
+type PlayFilter struct {
+	// YYYY-MM-DD or RFC 3339, inclusive
+	From *string `json:"from,omitempty"`
+	// YYYY-MM-DD or RFC 3339, inclusive
+	To        *string `json:"to,omitempty"`
+	Mood      *string `json:"mood,omitempty"`
+	Activity  *string `json:"activity,omitempty"`
+	Source    *string `json:"source,omitempty"`
+	Annotated *bool   `json:"annotated,omitempty"`
+}
+
+type Query struct {
+}
@@ -0,0 +1,74 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/handlers"
+	"example.com/spotifydb/internal/models"
+)
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+// Resolver resolves the root fields with the same models calls as the REST
+// handlers. Results are the REST response types, bound in gqlgen.yml.
+type Resolver struct{}
+
+// dateRange reads from/to arguments like ?from=/?to=, also accepting
+// RFC 3339 times
+func dateRange(fromArg, toArg *string) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+	if v := deref(fromArg, ""); v != "" {
+		t, err := handlers.ParseHistoryBound("from", v, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		from = &t
+	}
+	if v := deref(toArg, ""); v != "" {
+		t, err := handlers.ParseHistoryBound("to", v, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		to = &t
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, fmt.Errorf("'to' is before 'from'")
+	}
+	return from, to, nil
+}
+
+// sourceArg reads a source argument like ?source=
+func sourceArg(arg *string) (string, error) {
+	source := deref(arg, "")
+	if source != "" && !models.IsSource(source) {
+		return "", fmt.Errorf("invalid 'source' %q (expected %s)", source, strings.Join(models.Sources, ", "))
+	}
+	return source, nil
+}
+
+// page reads a Pagination, capping limit at 500 like the REST lists
+func page(p *Pagination) (limit, offset int, err error) {
+	if p == nil {
+		p = &Pagination{}
+	}
+	offset = deref(p.Offset, 0)
+	if offset < 0 {
+		return 0, 0, fmt.Errorf("invalid 'offset' (expected a non-negative integer)")
+	}
+	return clamp(deref(p.Limit, 50), 1, 500), offset, nil
+}
+
+// deref returns *p, or def for an argument left out or null
+func deref[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// clamp limits n to [lo, hi]
+func clamp(n, lo, hi int) int {
+	return max(lo, min(n, hi))
+}
@@ -0,0 +1,151 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.78
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"example.com/spotifydb/internal/handlers"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+)
+
+// TrackCountsByPeriod is the resolver for the track_counts_by_period field.
+func (r *collectionStatsResponseResolver) TrackCountsByPeriod(ctx context.Context, obj *handlers.CollectionStatsResponse) (any, error) {
+	return obj.TrackCountsByPeriod, nil
+}
+
+// RecentlyPlayed is the resolver for the recentlyPlayed field.
+func (r *queryResolver) RecentlyPlayed(ctx context.Context, filter *PlayFilter, pagination *Pagination) (*handlers.PlaysResponse, error) {
+	if filter == nil {
+		filter = &PlayFilter{}
+	}
+	from, to, err := dateRange(filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	source, err := sourceArg(filter.Source)
+	if err != nil {
+		return nil, err
+	}
+	limit, offset, err := page(pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	q := models.PlayQuery{
+		Mood:      strings.TrimSpace(deref(filter.Mood, "")),
+		Activity:  strings.TrimSpace(deref(filter.Activity, "")),
+		Source:    source,
+		Annotated: deref(filter.Annotated, false),
+		From:      from,
+		To:        to,
+		Limit:     limit,
+		Offset:    offset,
+	}
+	plays, total, totalMs, err := models.GetAnnotatedPlays(repository.Pool, q)
+	if err != nil {
+		return nil, err
+	}
+	return &handlers.PlaysResponse{
+		Mood:      q.Mood,
+		Activity:  q.Activity,
+		From:      from,
+		To:        to,
+		Plays:     plays,
+		Count:     len(plays),
+		Total:     total,
+		TotalMs:   totalMs,
+		Formatted: handlers.FormatDuration(totalMs),
+	}, nil
+}
+
+// RecentlyLiked is the resolver for the recentlyLiked field.
+func (r *queryResolver) RecentlyLiked(ctx context.Context, filter *LikedFilter, sort *string, order *string, pagination *Pagination) (*handlers.RecentlyLikedResponse, error) {
+	if filter == nil {
+		filter = &LikedFilter{}
+	}
+	source, err := sourceArg(filter.Source)
+	if err != nil {
+		return nil, err
+	}
+	limit, offset, err := page(pagination)
+	if err != nil {
+		return nil, err
+	}
+	q := models.LikedQuery{
+		Limit:          limit,
+		Offset:         offset,
+		Sort:           deref(sort, "added_at"),
+		Genre:          deref(filter.Genre, ""),
+		Artist:         deref(filter.Artist, ""),
+		AlbumType:      deref(filter.AlbumType, ""),
+		Source:         source,
+		IncludeRemoved: deref(filter.IncludeRemoved, false),
+	}
+	if _, ok := models.LikedSorts[q.Sort]; !ok {
+		return nil, fmt.Errorf("invalid 'sort' %q (expected added_at, popularity or release_date)", q.Sort)
+	}
+	switch o := deref(order, "desc"); o {
+	case "asc":
+		q.Ascending = true
+	case "desc":
+	default:
+		return nil, fmt.Errorf("invalid 'order' %q (expected asc or desc)", o)
+	}
+
+	data, total, err := models.ListRecentlyLiked(repository.Pool, q)
+	if err != nil {
+		return nil, err
+	}
+	return &handlers.RecentlyLikedResponse{
+		Data:    data,
+		Count:   len(data),
+		Total:   total,
+		Limit:   q.Limit,
+		Offset:  q.Offset,
+		HasMore: q.Offset+len(data) < total,
+		Message: "Successfully retrieved recently liked tracks",
+	}, nil
+}
+
+// Stats is the resolver for the stats field.
+func (r *queryResolver) Stats(ctx context.Context) (*handlers.CollectionStatsResponse, error) {
+	stats := handlers.CachedCollectionStats()
+	return &stats, nil
+}
+
+// Artists is the resolver for the artists field.
+func (r *queryResolver) Artists(ctx context.Context, from *string, to *string, source *string, limit *int, primaryOnly *bool) ([]models.TopArtist, error) {
+	fromT, toT, err := dateRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	src, err := sourceArg(source)
+	if err != nil {
+		return nil, err
+	}
+	return models.GetTopArtists(repository.Pool, fromT, toT, src, clamp(deref(limit, 20), 1, 500),
+		deref(primaryOnly, false))
+}
+
+// Artist is the resolver for the artist field.
+func (r *queryResolver) Artist(ctx context.Context, name string, topTracks *int, primaryOnly *bool) (*models.ArtistPlays, error) {
+	return models.GetArtistPlays(repository.Pool, name, clamp(deref(topTracks, 10), 1, 50),
+		deref(primaryOnly, false))
+}
+
+// CollectionStatsResponse returns CollectionStatsResponseResolver implementation.
+func (r *Resolver) CollectionStatsResponse() CollectionStatsResponseResolver {
+	return &collectionStatsResponseResolver{r}
+}
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type collectionStatsResponseResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
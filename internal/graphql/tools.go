@@ -0,0 +1,6 @@
+//go:build tools
+
+// tools.go keeps the gqlgen generator in go.mod for go:generate
+package graphql
+
+import _ "github.com/99designs/gqlgen"
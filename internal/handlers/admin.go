@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- on-demand backfills ---------- */
+
+type BackfillRequest struct {
+	Type      string `json:"type" binding:"required"`
+	BatchSize int    `json:"batch_size"`
+	DryRun    bool   `json:"dry_run"`
+}
+
+// backfillJob counts the pending work and, unless dryRun, processes up to batchSize items
+type backfillJob func(t *jobs.Task, batchSize int, dryRun bool) (any, error)
+
+var backfillJobs = map[string]backfillJob{
+	"genre":           backfillGenres,
+	"album_cover":     backfillAlbumCovers,
+	"audio_features":  backfillAudioFeatures,
+	"musicbrainz":     backfillMusicBrainz,
+	"related_artists": backfillRelatedArtists,
+}
+
+const (
+	defaultBackfillBatch = 50
+	maxBackfillBatch     = 1000
+)
+
+// StartBackfill runs a backfill job in the background
+// POST /admin/backfill {"type": "genre", "batch_size": 50, "dry_run": false}
+func StartBackfill(c *gin.Context) {
+	var req BackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, "invalid body: "+err.Error())
+		return
+	}
+	run, ok := backfillJobs[req.Type]
+	if !ok {
+		badRequest(c, fmt.Sprintf("unknown backfill type %q (expected %s)", req.Type, strings.Join(BackfillTypes(), ", ")))
+		return
+	}
+	if req.BatchSize == 0 {
+		req.BatchSize = defaultBackfillBatch
+	}
+	if req.BatchSize < 1 || req.BatchSize > maxBackfillBatch {
+		badRequest(c, fmt.Sprintf("batch_size must be between 1 and %d", maxBackfillBatch))
+		return
+	}
+
+	job := jobs.Start("backfill_"+req.Type, req, func(t *jobs.Task) (any, error) {
+		return run(t, req.BatchSize, req.DryRun)
+	})
+	c.JSON(http.StatusAccepted, job)
+}
+
+/* ---------- jobs ---------- */
+
+// ListJobs returns recent background jobs, newest first, including runs of
+// the command-line tools
+// GET /admin/jobs?limit=50
+func ListJobs(c *gin.Context) {
+	list := jobs.List(parseLimit(c, 50, 500))
+	c.JSON(http.StatusOK, JobsResponse{
+		Jobs:  list,
+		Count: len(list),
+	})
+}
+
+// GetJob returns the status of a background job
+// GET /admin/jobs/:id
+func GetJob(c *gin.Context) {
+	job, ok := jobs.Get(c.Param("id"))
+	if !ok {
+		notFound(c, "job not found")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob stops a queued or running job
+// POST /admin/jobs/:id/cancel
+func CancelJob(c *gin.Context) {
+	job, err := jobs.Cancel(c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		notFound(c, "job not found")
+	case err != nil:
+		RespondError(c, http.StatusConflict, CodeConflict, err.Error(), gin.H{"status": job.Status})
+	default:
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// BackfillTypes lists the backfills that can be run, sorted
+func BackfillTypes() []string {
+	types := make([]string, 0, len(backfillJobs))
+	for t := range backfillJobs {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// RunBackfill runs one backfill inside an existing job, for the command-line
+// tool; StartBackfill queues them on the server instead
+func RunBackfill(t *jobs.Task, kind string, batchSize int, dryRun bool) (any, error) {
+	run, ok := backfillJobs[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown backfill type %q (expected %s)", kind, strings.Join(BackfillTypes(), ", "))
+	}
+	return run(t, batchSize, dryRun)
+}
+
+// backfillResult is what every backfill job reports
+type backfillResult struct {
+	Pending   int  `json:"pending"`
+	BatchSize int  `json:"batch_size"`
+	DryRun    bool `json:"dry_run"`
+	Updated   int  `json:"updated"`
+}
+
+func backfillGenres(t *jobs.Task, batchSize int, dryRun bool) (any, error) {
+	pending, err := countMissingGenres()
+	if err != nil {
+		return nil, err
+	}
+	res := backfillResult{Pending: pending, BatchSize: batchSize, DryRun: dryRun}
+	if dryRun || pending == 0 {
+		return res, nil
+	}
+	res.Updated = GetGenreOfRecentlyLiked(batchSize)
+	return res, nil
+}
+
+func backfillAlbumCovers(t *jobs.Task, batchSize int, dryRun bool) (any, error) {
+	pending, err := models.CountMissingTrackData()
+	if err != nil {
+		return nil, err
+	}
+	res := backfillResult{Pending: pending, BatchSize: batchSize, DryRun: dryRun}
+	if dryRun || pending == 0 {
+		return res, nil
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		return res, err
+	}
+	res.Updated, err = models.BackfillMissingTrackData(t.Context(), accessTok, batchSize, cronRateLimiter)
+	return res, err
+}
+
+func backfillAudioFeatures(t *jobs.Task, batchSize int, dryRun bool) (any, error) {
+	pending, err := models.CountMissingAudioFeatures(repository.Pool)
+	if err != nil {
+		return nil, err
+	}
+	res := backfillResult{Pending: pending, BatchSize: batchSize, DryRun: dryRun}
+	if dryRun || pending == 0 {
+		return res, nil
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		return res, err
+	}
+	ids, err := models.GetTracksMissingAudioFeatures(repository.Pool, batchSize)
+	if err != nil {
+		return res, err
+	}
+
+	// /v1/audio-features takes up to 100 ids per call
+	for start := 0; start < len(ids); start += 100 {
+		if t.Canceled() {
+			return res, nil
+		}
+		end := min(start+100, len(ids))
+		var features []services.AudioFeatures
+		err := cronRateLimiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			features, fetchErr = services.GetAudioFeatures(t.Context(), accessTok, ids[start:end])
+			return fetchErr
+		}, 1)
+		if err != nil {
+			return res, err
+		}
+		saved, err := models.SaveAudioFeatures(repository.Pool, ids[start:end], features)
+		res.Updated += saved
+		if err != nil {
+			return res, err
+		}
+		t.Progress(end, len(ids))
+	}
+	return res, nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- top albums ---------- */
+
+// GetTopAlbums ranks albums by plays for ?period= (default: month)
+func GetTopAlbums(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "month")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	limit := parseLimit(c, 50, 500)
+
+	albums, err := models.GetTopAlbums(repository.Pool, from, to, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TopAlbumsResponse{
+		Period: period,
+		From:   from,
+		To:     to,
+		Albums: albums,
+		Count:  len(albums),
+	})
+}
+
+/* ---------- album plays ---------- */
+
+// GetAlbumPlays returns play totals and per-track plays for one album.
+// ?artist= disambiguates albums that share a name.
+func GetAlbumPlays(c *gin.Context) {
+	albumName := c.Param("album_name")
+	artist := c.Query("artist")
+
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	album, tracks, err := models.GetAlbumPlays(repository.Pool, albumName, artist, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if album == nil {
+		notFound(c, fmt.Sprintf("no plays found for album %q", albumName))
+		return
+	}
+
+	c.JSON(http.StatusOK, AlbumPlaysResponse{
+		Album:     album,
+		Tracks:    tracks,
+		Formatted: FormatDuration(album.TotalMs),
+	})
+}
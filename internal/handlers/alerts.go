@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/notifications"
+)
+
+// Alert settings and senders the cron was started with
+var (
+	notifyConfig = config.DefaultNotifyConfig()
+	notifier     = notifications.New()
+)
+
+// alertState remembers which alerts are open so each fires once per incident
+var alertState struct {
+	sync.Mutex
+	tokenFailures  int
+	tokenAlerted   bool
+	stalledAlerted bool
+}
+
+// notify sends in the background so a slow receiver never holds up the cron
+func notify(kind, text string, data any) {
+	if !notifier.Enabled() {
+		return
+	}
+	go func() {
+		if err := notifier.Notify(kind, text, data); err != nil {
+			fmt.Printf("⚠️  notify %s: %v\n", kind, err)
+		}
+	}()
+}
+
+// recordTokenRefresh tracks refresh-token refreshes, alerting once they've
+// failed NOTIFY_TOKEN_FAILURES times in a row and again when one succeeds
+func recordTokenRefresh(err error) {
+	alertState.Lock()
+	defer alertState.Unlock()
+
+	if err == nil {
+		if alertState.tokenAlerted {
+			notify(notifications.KindTokenRefreshOK, "✅ Spotify token refresh is working again", nil)
+		}
+		alertState.tokenFailures, alertState.tokenAlerted = 0, false
+		return
+	}
+
+	alertState.tokenFailures++
+	if alertState.tokenFailures >= notifyConfig.TokenFailures && !alertState.tokenAlerted {
+		alertState.tokenAlerted = true
+		notify(notifications.KindTokenRefreshFailing,
+			fmt.Sprintf("🔑 Spotify token refresh has failed %d times in a row: %v\nRe-authorize from the frontend if the refresh token was revoked.",
+				alertState.tokenFailures, err),
+			map[string]any{"failures": alertState.tokenFailures, "error": err.Error()})
+	}
+}
+
+// checkCollectionStalled alerts when the newest stored play is older than
+// NOTIFY_NO_TRACKS_AFTER, and again once plays arrive
+func (col *Collector) checkCollectionStalled() {
+	latest, err := col.store.LatestPlayedAt()
+	if err != nil || latest.Unix() <= 0 {
+		return // unknown, or nothing collected yet
+	}
+	since := time.Since(latest)
+
+	alertState.Lock()
+	defer alertState.Unlock()
+	switch {
+	case since >= notifyConfig.NoTracksAfter && !alertState.stalledAlerted:
+		alertState.stalledAlerted = true
+		notify(notifications.KindNoTracks,
+			fmt.Sprintf("🔇 No tracks collected for %s (last play %s)",
+				since.Round(time.Minute), latest.In(config.Timezone()).Format("2006-01-02 15:04")),
+			map[string]any{"last_played_at": latest})
+	case since < notifyConfig.NoTracksAfter && alertState.stalledAlerted:
+		alertState.stalledAlerted = false
+		notify(notifications.KindTracksResumed, "🎵 Tracks are being collected again", map[string]any{"last_played_at": latest})
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- play annotations ---------- */
+
+// AnnotatePlayRequest tags a play, or with through_play_id every play between
+// the two. Omitted fields are kept; an empty string clears one.
+type AnnotatePlayRequest struct {
+	Mood          *string `json:"mood" binding:"omitempty,max=50"`
+	Activity      *string `json:"activity" binding:"omitempty,max=50"`
+	Note          *string `json:"note"`
+	ThroughPlayID int     `json:"through_play_id" binding:"omitempty,gte=1"`
+}
+
+// AnnotatePlay handles POST /plays/:id/annotations
+func AnnotatePlay(c *gin.Context) {
+	playID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || playID < 1 {
+		badRequest(c, "invalid play id")
+		return
+	}
+
+	var req AnnotatePlayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, "invalid body: "+err.Error())
+		return
+	}
+	if req.Mood == nil && req.Activity == nil && req.Note == nil {
+		badRequest(c, "nothing to annotate (expected mood, activity and/or note)")
+		return
+	}
+	throughID := playID
+	if req.ThroughPlayID != 0 {
+		throughID = req.ThroughPlayID
+	}
+
+	n, err := models.AnnotatePlays(repository.Pool, playID, throughID, models.AnnotationUpdate{
+		Mood:     normalizeTag(req.Mood),
+		Activity: normalizeTag(req.Activity),
+		Note:     req.Note,
+	})
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if n == 0 {
+		notFound(c, "play not found")
+		return
+	}
+
+	annotation, err := models.GetPlayAnnotation(repository.Pool, playID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, AnnotatePlayResponse{Annotated: n, Annotation: annotation})
+}
+
+// normalizeTag trims and lowercases a mood/activity so "Gym" and "gym " match
+func normalizeTag(tag *string) *string {
+	if tag == nil {
+		return nil
+	}
+	t := strings.ToLower(strings.TrimSpace(*tag))
+	return &t
+}
+
+// GetPlays lists plays with their annotations, filtered by ?mood=,
+// ?activity=, ?source=, ?annotated=true and the ?from=/?to= date range
+func GetPlays(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	source, err := parseSource(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	offset := 0
+	if v := c.Query("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			badRequest(c, "invalid 'offset' (expected a non-negative integer)")
+			return
+		}
+	}
+
+	q := models.PlayQuery{
+		Mood:      strings.TrimSpace(c.Query("mood")),
+		Activity:  strings.TrimSpace(c.Query("activity")),
+		Source:    source,
+		Annotated: c.Query("annotated") == "true",
+		From:      from,
+		To:        to,
+		Limit:     parseLimit(c, 50, 500),
+		Offset:    offset,
+	}
+	plays, total, totalMs, err := models.GetAnnotatedPlays(repository.Pool, q)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PlaysResponse{
+		Mood:      q.Mood,
+		Activity:  q.Activity,
+		From:      from,
+		To:        to,
+		Plays:     plays,
+		Count:     len(plays),
+		Total:     total,
+		TotalMs:   totalMs,
+		Formatted: FormatDuration(totalMs),
+	})
+}
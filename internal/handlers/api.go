@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/store"
+)
+
+// API holds the HTTP handlers that read and write through a Store. Handlers
+// still on package-level functions query repository.Pool directly and move
+// over as they gain tests.
+type API struct {
+	store   store.Store
+	spotify services.Client
+}
+
+// NewAPI returns handlers backed by s, calling the live Spotify API
+func NewAPI(s store.Store) *API {
+	return &API{store: s, spotify: services.Live{}}
+}
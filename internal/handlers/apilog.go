@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- Spotify API call log ---------- */
+
+const (
+	apiLogQueue         = 1000 // calls waiting to be written; more are dropped
+	apiLogFlushSize     = 100
+	apiLogFlushInterval = 5 * time.Second
+	apiLogPurgeInterval = time.Hour
+)
+
+var apiLogConfig = config.DefaultAPILogConfig()
+
+// SetAPILog starts writing the calls made to Spotify to spotify_api_log, as
+// cfg says. Call it once, after Migrate.
+func SetAPILog(cfg config.APILogConfig) {
+	apiLogConfig = cfg
+	if !cfg.Enabled() {
+		return
+	}
+	queue := make(chan models.APICall, apiLogQueue)
+	services.SetCallLogger(func(call services.Call) {
+		weight := 1.0
+		ok := call.Status >= 200 && call.Status < 300
+		if cfg.Mode == config.APILogSampled && ok {
+			if rand.Float64() >= cfg.SampleRate {
+				return
+			}
+			weight = 1 / cfg.SampleRate
+		}
+		select {
+		case queue <- models.APICall{
+			Endpoint:    call.Endpoint,
+			Status:      call.Status,
+			DurationMs:  int(call.Duration.Milliseconds()),
+			RetryAfterS: int(call.RetryAfter.Seconds()),
+			Weight:      weight,
+			CalledAt:    call.At,
+		}:
+		default:
+		}
+	})
+	go writeAPILog(queue, cfg.Retention)
+}
+
+// writeAPILog inserts queued calls in batches and deletes the ones older
+// than retention
+func writeAPILog(queue <-chan models.APICall, retention time.Duration) {
+	flush := time.NewTicker(apiLogFlushInterval)
+	purge := time.NewTicker(apiLogPurgeInterval)
+	defer flush.Stop()
+	defer purge.Stop()
+
+	batch := make([]models.APICall, 0, apiLogFlushSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := models.InsertAPICalls(repository.Pool, batch); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case call := <-queue:
+			batch = append(batch, call)
+			if len(batch) >= apiLogFlushSize {
+				write()
+			}
+		case <-flush.C:
+			write()
+		case <-purge.C:
+			if n, err := models.PurgeAPICalls(repository.Pool, time.Now().Add(-retention)); err != nil {
+				fmt.Printf("⚠️  %v\n", err)
+			} else if n > 0 {
+				fmt.Printf("🧹 purged %d logged Spotify calls\n", n)
+			}
+		}
+	}
+}
+
+// GetAPIUsage summarizes the logged Spotify calls per hour or day, per
+// endpoint, and lists the latest rate limits. The window defaults to the
+// last 48 hours by hour and the last 30 days by day.
+// GET /admin/api-usage?bucket=hour&from=2024-01-01&to=2024-01-02
+func GetAPIUsage(c *gin.Context) {
+	bucket := c.DefaultQuery("bucket", "hour")
+	window := 48 * time.Hour
+	switch bucket {
+	case "hour":
+	case "day":
+		window = 30 * 24 * time.Hour
+	default:
+		badRequest(c, fmt.Sprintf("invalid 'bucket' %q (expected hour or day)", bucket))
+		return
+	}
+	fromDate, toDate, err := parseDateRange(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	to := time.Now()
+	if toDate != nil {
+		to = *toDate
+	}
+	from := to.Add(-window)
+	if fromDate != nil {
+		from = *fromDate
+	}
+
+	buckets, err := models.GetAPIUsage(repository.Pool, from, to, bucket)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	endpoints, err := models.GetAPIEndpointUsage(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	limits, err := models.GetRecentRateLimits(repository.Pool, from, to, parseLimit(c, 20, 200))
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, APIUsageResponse{
+		Mode:        apiLogConfig.Mode,
+		Bucket:      bucket,
+		From:        from,
+		To:          to,
+		Buckets:     buckets,
+		Endpoints:   endpoints,
+		RateLimited: limits,
+	})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- artist detail ---------- */
+
+// GetArtist merges Spotify's view of an artist (images, followers, genres,
+// top tracks; cached for an hour) with my history: plays, first/last played,
+// my most played tracks and the tracks I've liked. Plays they're featured on
+// count unless ?primary_only=true.
+// GET /artists/:artist_id
+func GetArtist(c *gin.Context) {
+	artistID := c.Param("artist_id")
+	if !validSpotifyID(artistID) {
+		badRequest(c, "invalid Spotify artist ID")
+		return
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	profile, err := services.GetArtistProfile(c.Request.Context(), accessTok, artistID)
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+
+	plays, err := models.GetArtistPlays(repository.Pool, profile.Name, 10, c.Query("primary_only") == "true")
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	liked, _, err := models.ListRecentlyLiked(repository.Pool, models.LikedQuery{
+		Limit:  50,
+		Sort:   "added_at",
+		Artist: artistID,
+	})
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	ids := make([]string, 0, len(profile.TopTracks))
+	for _, t := range profile.TopTracks {
+		ids = append(ids, t.ID)
+	}
+	history, err := models.GetTrackHistory(repository.Pool, ids)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	topTracks := make([]SearchTrack, 0, len(profile.TopTracks))
+	for _, t := range profile.TopTracks {
+		st := SearchTrack{
+			ID:           t.ID,
+			Name:         t.Name,
+			Artists:      []string{},
+			AlbumName:    t.Album.Name,
+			DurationMs:   t.DurationMs,
+			TrackHistory: history[t.ID],
+		}
+		for _, a := range t.Artists {
+			st.Artists = append(st.Artists, a.Name)
+		}
+		if len(t.Album.Images) > 0 {
+			st.AlbumCoverURL = t.Album.Images[0].URL
+		}
+		topTracks = append(topTracks, st)
+	}
+
+	resp := ArtistResponse{
+		ID:              profile.ID,
+		Name:            profile.Name,
+		Genres:          profile.Genres,
+		Followers:       profile.Followers.Total,
+		Popularity:      profile.Popularity,
+		TopTracks:       topTracks,
+		SpotifyCachedAt: profile.FetchedAt,
+		Plays:           plays,
+		Formatted:       FormatDuration(plays.TotalMs),
+		LikedTracks:     liked,
+		LikedCount:      len(liked),
+	}
+	if resp.Genres == nil {
+		resp.Genres = []string{}
+	}
+	if len(profile.Images) > 0 {
+		resp.ImageURL = profile.Images[0].URL
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+/* ---------- related artists ---------- */
+
+// GetArtistRelated returns the artists Spotify relates to an artist, as
+// stored by the related_artists backfill, each marked with whether (and how
+// much) I've played it. unplayed=true keeps only the ones I haven't heard.
+// GET /artists/:artist_id/related?unplayed=true&limit=20
+func GetArtistRelated(c *gin.Context) {
+	artistID := c.Param("artist_id")
+	limit := parseLimit(c, 20, 100)
+	unplayed := c.Query("unplayed") == "true"
+
+	related, checkedAt, err := models.GetRelatedArtists(repository.Pool, artistID, unplayed, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if checkedAt == nil {
+		notFound(c, "related artists of this artist haven't been fetched; run the related_artists backfill")
+		return
+	}
+
+	resp := RelatedArtistsResponse{
+		ArtistID:  artistID,
+		FetchedAt: *checkedAt,
+		Related:   related,
+		Count:     len(related),
+	}
+	for _, r := range related {
+		if !r.Played {
+			resp.Unplayed++
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// backfillRelatedArtists stores the related artists of up to batchSize of the
+// artists I've played whose relations are missing or older than
+// models.ArtistRelationsMaxAge
+func backfillRelatedArtists(t *jobs.Task, batchSize int, dryRun bool) (any, error) {
+	before := time.Now().Add(-models.ArtistRelationsMaxAge)
+	pending, err := models.CountArtistsMissingRelations(repository.Pool, before)
+	if err != nil {
+		return nil, err
+	}
+	res := backfillResult{Pending: pending, BatchSize: batchSize, DryRun: dryRun}
+	if dryRun || pending == 0 {
+		return res, nil
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		return res, err
+	}
+	ids, err := models.GetArtistsMissingRelations(repository.Pool, before, batchSize)
+	if err != nil {
+		return res, err
+	}
+	// An artist is marked checked on its artists row, so make sure it has one
+	resolved, err := models.FetchArtistsBatched(t.Context(), accessTok, ids, cronRateLimiter)
+	if err != nil {
+		return res, err
+	}
+
+	for i, id := range ids {
+		if t.Canceled() {
+			return res, nil
+		}
+		if resolved[id] == nil {
+			continue // Spotify doesn't know the artist
+		}
+		var related []services.Artist
+		err := cronRateLimiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			related, fetchErr = services.GetRelatedArtists(t.Context(), accessTok, id)
+			return fetchErr
+		}, 1)
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			related = nil
+		case err != nil:
+			return res, err
+		}
+		if err := models.SaveArtistRelations(repository.Pool, id, related); err != nil {
+			return res, err
+		}
+		res.Updated++
+		t.Progress(i+1, len(ids))
+	}
+	return res, nil
+}
+
+/* ---------- weekly artist refresh ---------- */
+
+// RefreshStaleArtists re-fetches cached artists whose metadata is older than the
+// configured staleness window so genre/image changes eventually show up.
+func RefreshStaleArtists() int {
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		fmt.Println("RefreshStaleArtists:", err)
+		return 0
+	}
+
+	ids, err := models.GetStaleArtistIDs(cronConfig.ArtistStaleAfter, cronConfig.ArtistRefreshBatch)
+	if err != nil {
+		fmt.Println("RefreshStaleArtists:", err)
+		return 0
+	}
+	if len(ids) == 0 {
+		return 0
+	}
+
+	refreshed := 0
+	for _, id := range ids {
+		var artistObj *services.Artist
+		err := cronRateLimiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			artistObj, fetchErr = services.GetArtistById(context.Background(), accessTok, id)
+			return fetchErr
+		}, 1)
+		if err != nil {
+			if services.IsRateLimited(err) {
+				fmt.Println("⚠️  RefreshStaleArtists: rate limited, stopping early")
+				break
+			}
+			log.Printf("RefreshStaleArtists: failed to fetch artist %s: %v", id, err)
+			continue
+		}
+		if err := models.UpsertArtist(artistObj); err != nil {
+			log.Printf("RefreshStaleArtists: %v", err)
+			continue
+		}
+		refreshed++
+	}
+
+	fmt.Printf("🎤 refreshed %d/%d stale artists\n", refreshed, len(ids))
+	return refreshed
+}
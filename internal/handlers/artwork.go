@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- artwork ---------- */
+
+const (
+	artworkMinWidth = 16
+	artworkMaxWidth = 640 // Spotify's largest cover
+	artworkMaxBytes = 5 << 20
+	artworkMaxAge   = 7 * 24 * time.Hour
+)
+
+var artworkClient = &http.Client{Timeout: 10 * time.Second}
+
+var artworkDir = sync.OnceValue(func() string {
+	if dir := os.Getenv("ARTWORK_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "spotifydb-artwork")
+})
+
+// GetArtwork serves a track's album cover from the on-disk cache, fetching it
+// on first use. When the stored image URL no longer resolves, the current one
+// is looked up on Spotify and saved on the track's rows. ?w= scales the cover
+// down to that width, keeping its aspect ratio.
+// GET /artwork/:spotify_song_id?w=300
+func GetArtwork(c *gin.Context) {
+	spotifyID := c.Param("spotify_song_id")
+	if !validSpotifyID(spotifyID) {
+		badRequest(c, "invalid Spotify track ID")
+		return
+	}
+	width := 0
+	if v := c.Query("w"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < artworkMinWidth || n > artworkMaxWidth {
+			badRequest(c, fmt.Sprintf("invalid 'w' (expected %d to %d)", artworkMinWidth, artworkMaxWidth))
+			return
+		}
+		width = n
+	}
+
+	resizedName := fmt.Sprintf("%s_%d.img", spotifyID, width)
+	if width > 0 {
+		if data, modified, ok := readArtworkCache(resizedName); ok {
+			serveArtwork(c, data, modified)
+			return
+		}
+	}
+
+	data, modified, err := artworkOriginal(c.Request.Context(), spotifyID)
+	switch {
+	case err == nil:
+	case errors.Is(err, errNoArtwork):
+		notFound(c, fmt.Sprintf("no artwork for track %s", spotifyID))
+		return
+	case errors.Is(err, errNoRefreshToken), errors.Is(err, services.ErrUnauthorized),
+		errors.Is(err, services.ErrMissingScopes), errors.Is(err, services.ErrNotFound):
+		spotifyError(c, err)
+		return
+	case repository.IsUnavailable(err):
+		internalError(c, err)
+		return
+	default:
+		RespondError(c, http.StatusBadGateway, CodeSpotifyUnavailable, "could not fetch the artwork", err.Error())
+		return
+	}
+
+	if width > 0 {
+		resized, err := resizeArtwork(data, width)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		if resized != nil {
+			data, modified = resized, time.Now()
+		}
+		writeArtworkCache(resizedName, data)
+	}
+	serveArtwork(c, data, modified)
+}
+
+var errNoArtwork = errors.New("no artwork known")
+
+// artworkOriginal returns the full-size cover, from the cache or else from
+// the stored URL, falling back to asking Spotify for the current one
+func artworkOriginal(ctx context.Context, spotifyID string) ([]byte, time.Time, error) {
+	name := spotifyID + ".img"
+	if data, modified, ok := readArtworkCache(name); ok {
+		return data, modified, nil
+	}
+
+	stored, err := models.GetAlbumCoverURL(repository.Pool, spotifyID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if stored != "" {
+		data, err := downloadArtwork(stored)
+		if err == nil {
+			writeArtworkCache(name, data)
+			return data, time.Now(), nil
+		}
+		fmt.Printf("🖼️  stored cover of %s failed (%v), asking Spotify\n", spotifyID, err)
+	}
+
+	accessToken, err := getCronAccessToken()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	track, err := services.GetCachedTrack(ctx, accessToken, spotifyID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(track.Album.Images) == 0 {
+		return nil, time.Time{}, errNoArtwork
+	}
+	current := track.Album.Images[0].URL // largest first
+	data, err := downloadArtwork(current)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if current != stored {
+		if err := models.SetAlbumCoverURL(repository.Pool, spotifyID, current); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to save the new cover URL of %s: %v\n", spotifyID, err)
+		}
+	}
+	writeArtworkCache(name, data)
+	return data, time.Now(), nil
+}
+
+// downloadArtwork fetches an image, refusing anything that isn't one
+func downloadArtwork(url string) ([]byte, error) {
+	res, err := artworkClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, artworkMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > artworkMaxBytes {
+		return nil, fmt.Errorf("GET %s: image larger than %d bytes", url, artworkMaxBytes)
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("GET %s: not an image: %v", url, err)
+	}
+	return data, nil
+}
+
+// resizeArtwork scales an image down to width, averaging the source pixels
+// under each output pixel. It returns nil when the image is already no wider,
+// in which case the original is cached and served for that width.
+func resizeArtwork(data []byte, width int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode artwork: %v", err)
+	}
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	if sw <= width {
+		return nil, nil
+	}
+	height := max(1, (sh*width+sw/2)/sw)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(rgba, rgba.Bounds(), src, sb.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+int(p[0]), g+int(p[1]), b+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+			o := dst.Pix[y*dst.Stride+x*4:]
+			o[0], o[1], o[2], o[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, fmt.Errorf("failed to encode artwork: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+// serveArtwork writes an image letting browsers and CDNs keep it, and answers
+// If-Modified-Since and Range requests
+func serveArtwork(c *gin.Context, data []byte, modified time.Time) {
+	c.Header("Content-Type", http.DetectContentType(data))
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(artworkMaxAge.Seconds())))
+	http.ServeContent(c.Writer, c.Request, "", modified, bytes.NewReader(data))
+}
+
+func readArtworkCache(name string) ([]byte, time.Time, bool) {
+	path := filepath.Join(artworkDir(), name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return data, info.ModTime(), true
+}
+
+// writeArtworkCache stores an image through a rename, so concurrent requests
+// never read half a file. A failure only costs a refetch next time.
+func writeArtworkCache(name string, data []byte) {
+	dir := artworkDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to create artwork cache %s: %v\n", dir, err)
+		return
+	}
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to cache artwork %s: %v\n", name, err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		fmt.Printf("⚠️  Warning: Failed to cache artwork %s: %v\n", name, err)
+	}
+}
+
+// validSpotifyID reports whether id looks like a base62 Spotify id, which
+// also keeps it safe to use as a file name
+func validSpotifyID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
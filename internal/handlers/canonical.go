@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+)
+
+/* ---------- canonical tracks (ISRC enrichment) ---------- */
+
+// canonicalBatch is one /v1/tracks call, the most Spotify accepts
+const canonicalBatch = 50
+
+// ResolveCanonicalTracks looks up the ISRC of played tracks not yet in
+// canonical_tracks and regroups variants of the same recording. Plays
+// collected with their ISRC are mapped without calling Spotify.
+func ResolveCanonicalTracks() {
+	seeded, err := models.SeedCanonicalFromPlays(repository.Pool)
+	if err != nil {
+		fmt.Println("ResolveCanonicalTracks:", err)
+		return
+	}
+
+	ids, err := models.GetUnresolvedTrackIDs(repository.Pool, canonicalBatch)
+	if err != nil {
+		fmt.Println("ResolveCanonicalTracks:", err)
+		return
+	}
+	if len(ids) > 0 {
+		if err := lookupISRCs(ids); err != nil {
+			fmt.Println("ResolveCanonicalTracks:", err)
+			return
+		}
+	}
+	if seeded == 0 && len(ids) == 0 {
+		return
+	}
+
+	merged, err := models.RecomputeCanonicalTracks(repository.Pool)
+	if err != nil {
+		fmt.Println("ResolveCanonicalTracks:", err)
+		return
+	}
+	fmt.Printf("🔗 resolved ISRCs for %d tracks (%d from stored plays), %d remapped to a canonical track\n",
+		int64(len(ids))+seeded, seeded, merged)
+}
+
+// lookupISRCs fetches the ISRC of each track from Spotify and saves it
+func lookupISRCs(ids []string) error {
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		return err
+	}
+
+	var tracks []services.TrackDetails
+	err = cronRateLimiter.RetryWithBackoff(func() error {
+		var fetchErr error
+		tracks, fetchErr = services.GetTracksByIds(context.Background(), accessTok, ids)
+		return fetchErr
+	}, 1)
+	if err != nil {
+		return err
+	}
+
+	// Tracks Spotify didn't return are stored without an ISRC so they aren't retried
+	isrcs := make(map[string]string, len(ids))
+	for _, id := range ids {
+		isrcs[id] = ""
+	}
+	for _, t := range tracks {
+		if _, ok := isrcs[t.ID]; ok {
+			isrcs[t.ID] = t.ExternalIDs.ISRC
+		}
+	}
+	return models.SaveTrackISRCs(repository.Pool, isrcs)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+)
+
+/* ---------- catch-up after downtime ---------- */
+
+const (
+	// recentlyPlayedBuffer is roughly how many plays Spotify keeps in
+	// recently played; older ones are gone for good
+	recentlyPlayedBuffer = 50
+	// lostPlaysBaseline is the history the lost-plays estimate is based on
+	lostPlaysBaseline = 28 * 24 * time.Hour
+)
+
+// CatchUp runs once at startup: it walks recently played back to the newest
+// stored play, syncs saved tracks added meanwhile, and logs an estimate of
+// the plays that fell out of Spotify's buffer while the server was down
+func (col *Collector) CatchUp() {
+	latest, err := col.store.LatestPlayedAt()
+	if err != nil || latest.Unix() <= 0 {
+		return // unknown, or nothing collected yet
+	}
+	accessTok, err := col.accessToken()
+	if err != nil {
+		fmt.Println("catch-up:", err)
+		return
+	}
+
+	// The before cursor walks back from the newest play until it reaches the
+	// stored one or runs out of buffer
+	items, err := col.spotify.GetRecentlyPlayedSince(context.Background(), accessTok, latest)
+	if err != nil {
+		fmt.Println("catch-up: recently-played error:", err)
+		col.collectionFailed(err)
+		return
+	}
+	recordCollectorSuccess(config.CollectorRecentlyPlayed)
+	col.collectionRecovered()
+
+	stored := col.storePlayedItems(accessTok, items, latest, models.SourceCatchUp).Inserted
+	fmt.Printf("⏪ Catch-up: %d new plays since %s\n", stored,
+		latest.In(config.Timezone()).Format("2006-01-02 15:04"))
+
+	// A full buffer of newer plays means it no longer reaches back to the
+	// stored one, so whatever was played in between is lost
+	if len(items) >= recentlyPlayedBuffer {
+		loc := config.Timezone()
+		oldest := items[len(items)-1].PlayedAt
+		estimate := "some"
+		if lost, ok := estimateLostPlays(latest, oldest); ok {
+			estimate = fmt.Sprintf("about %d", lost)
+		}
+		fmt.Printf("🕳️  Catch-up: Spotify's buffer only reaches back to %s; %s plays since %s were likely lost\n",
+			oldest.In(loc).Format("2006-01-02 15:04"), estimate, latest.In(loc).Format("2006-01-02 15:04"))
+	}
+
+	// Saved tracks stop at the newest one already stored, so this is cheap
+	if cronConfig.Enabled(config.CollectorSavedTracks) {
+		col.CollectSavedTracks()
+	}
+}
+
+// estimateLostPlays scales the plays per active hour of the four weeks
+// before from by the active hours in [from, to). ok is false without enough
+// history to go on.
+func estimateLostPlays(from, to time.Time) (lost int, ok bool) {
+	if repository.Pool == nil {
+		return 0, false
+	}
+	baselineFrom := from.Add(-lostPlaysBaseline)
+	plays, err := repository.GetTrackCountBetween(baselineFrom, from)
+	if err != nil {
+		fmt.Println("catch-up:", err)
+		return 0, false
+	}
+	activeHours := cronConfig.ActiveDuration(baselineFrom, from).Hours()
+	if plays == 0 || activeHours == 0 {
+		return 0, false
+	}
+	return int(float64(plays) / activeHours * cronConfig.ActiveDuration(from, to).Hours()), true
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- one-shot collection ---------- */
+
+// collectMu keeps POST /collect and the cron's collectors from running at
+// the same time
+var collectMu sync.Mutex
+
+// Collect runs one recently-played and saved-tracks collection and reports
+// what it did, so collection can be driven by an external scheduler
+// (a systemd timer, GitHub Actions) instead of the cron loop. Repeating it
+// only stores what's new since; a run already in progress gets 409, and a
+// failed collector 503 with the summary as details.
+// POST /collect
+func Collect(c *gin.Context) {
+	if !collectMu.TryLock() {
+		RespondError(c, http.StatusConflict, CodeConflict, "a collection is already running; try again shortly", nil)
+		return
+	}
+	defer collectMu.Unlock()
+
+	start, calls := time.Now(), services.RequestCount()
+	// Report a missing or rejected token with its usual status up front
+	if _, err := cronCollector.accessToken(); err != nil {
+		spotifyError(c, err)
+		return
+	}
+
+	resp := CollectResponse{
+		RecentlyPlayed: recordCollectorRun(config.CollectorRecentlyPlayed, cronCollector.CollectRecentTracks),
+		SavedTracks:    recordCollectorRun(config.CollectorSavedTracks, cronCollector.CollectSavedTracks),
+	}
+	resp.APICalls = services.RequestCount() - calls
+	resp.DurationMs = time.Since(start).Milliseconds()
+	if resp.RecentlyPlayed.Inserted+resp.SavedTracks.Inserted > 0 {
+		invalidateCollectionStats()
+	}
+
+	if resp.RecentlyPlayed.Error != "" || resp.SavedTracks.Error != "" {
+		resp.Message = "collection failed"
+		RespondError(c, http.StatusServiceUnavailable, CodeSpotifyUnavailable, resp.Message, resp)
+		return
+	}
+	resp.Message = fmt.Sprintf("Collected %d new plays and %d new saved tracks",
+		resp.RecentlyPlayed.Inserted, resp.SavedTracks.Inserted)
+	c.JSON(http.StatusOK, resp)
+}
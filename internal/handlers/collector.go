@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/store"
+	"example.com/spotifydb/internal/utils"
+	"example.com/spotifydb/internal/webhooks"
+)
+
+// Collector runs the recently-played and saved-tracks collectors against a
+// Store and a Spotify client, so both can be swapped for fakes in tests
+type Collector struct {
+	store   store.Store
+	spotify services.Client
+	limiter *utils.RateLimiter
+
+	failures     atomic.Int32 // consecutive failed recently-played collections
+	failingSince atomic.Int64 // unix nanos of the streak's first failure
+	recovered    atomic.Bool  // a collection has succeeded since startup
+}
+
+// NewCollector builds a collector with its own Spotify rate limiter
+func NewCollector(s store.Store, spotify services.Client) *Collector {
+	return &Collector{
+		store:   s,
+		spotify: spotify,
+		limiter: utils.NewRateLimiter(),
+	}
+}
+
+// cronCollector is the collector the cron runs; StartSpotifyCron replaces it
+var cronCollector = NewCollector(store.Postgres{}, services.Live{})
+
+// accessToken exchanges the stored refresh token for an access token,
+// persisting the refresh token if Spotify rotated it. While the database is
+// unreachable the last token seen is used instead.
+func (col *Collector) accessToken() (string, error) {
+	grant, err := col.grant()
+	return grant.AccessToken, err
+}
+
+// grant exchanges the stored refresh token like accessToken, failing with
+// services.ErrMissingScopes when the token can't read what the cron collects
+func (col *Collector) grant() (services.TokenGrant, error) {
+	refreshTok, err := col.store.GetRefreshToken()
+	if repository.IsUnavailable(err) {
+		refreshTok = cachedRefreshToken()
+	}
+	if refreshTok == "" {
+		return services.TokenGrant{}, errNoRefreshToken
+	}
+
+	grant, err := col.spotify.ExchangeRefreshToken(context.Background(), refreshTok)
+	if err == nil {
+		err = checkScopes(grant.Scopes)
+	}
+	recordTokenRefresh(err)
+	if err != nil {
+		return services.TokenGrant{}, fmt.Errorf("refresh error: %w", err)
+	}
+	if grant.RefreshToken != nil && *grant.RefreshToken != refreshTok {
+		refreshTok = *grant.RefreshToken
+		rememberRefreshToken(refreshTok, col.store.SaveRefreshToken(refreshTok) == nil)
+	} else if cachedRefreshToken() != refreshTok {
+		rememberRefreshToken(refreshTok, true)
+	}
+	return grant, nil
+}
+
+// checkScopes fails with services.ErrMissingScopes naming the required
+// scopes that weren't granted. Spotify always reports the granted scopes,
+// so an empty list is taken as unknown rather than as none.
+func checkScopes(granted []string) error {
+	if len(granted) == 0 {
+		return nil
+	}
+	if missing := services.MissingScopes(granted); len(missing) > 0 {
+		return fmt.Errorf("%w: %s; re-authorize the app with them",
+			services.ErrMissingScopes, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// verifyAccount checks the stored token at startup and records its
+// account's profile and scopes, so a token without the required scopes is
+// reported once up front rather than as a failure every cycle
+func (col *Collector) verifyAccount() {
+	grant, err := col.grant()
+	switch {
+	case errors.Is(err, errNoRefreshToken):
+		fmt.Println("🔑 No Spotify account connected yet; POST a refresh token to /save-refresh")
+		return
+	case errors.Is(err, services.ErrMissingScopes):
+		fmt.Printf("❌ The stored Spotify token can't be used for collection: %v\n", err)
+		return
+	case err != nil:
+		fmt.Printf("⚠️  Could not verify the Spotify account: %v\n", err)
+		return
+	}
+	account, err := saveAccount(context.Background(), col.store, col.spotify, grant)
+	if err != nil {
+		fmt.Printf("⚠️  Could not record the Spotify account: %v\n", err)
+		return
+	}
+	fmt.Printf("🔑 Collecting for Spotify user %s (%s, %s), scopes: %s\n",
+		account.DisplayName, account.UserID, account.Product, strings.Join(account.Scopes, " "))
+}
+
+// saveAccount fetches the profile of grant's account and stores it with the
+// granted scopes
+func saveAccount(ctx context.Context, st store.AuthStore, spotify services.Client, grant services.TokenGrant) (repository.SpotifyAccount, error) {
+	account := repository.SpotifyAccount{Scopes: grant.Scopes}
+	profile, err := spotify.GetCurrentUserProfile(ctx, grant.AccessToken)
+	if err != nil {
+		return account, err
+	}
+	account.UserID, account.DisplayName = profile.ID, profile.DisplayName
+	account.Country, account.Product = profile.Country, profile.Product
+	return account, st.SaveSpotifyAccount(account)
+}
+
+// artists resolves the given artist IDs from the artists table, only calling
+// Spotify (and caching the result) for artists we haven't seen before.
+// Unseen artists are fetched 50 per request. Artists that couldn't be
+// resolved are absent from the map.
+// Staleness is handled separately by RefreshStaleArtists.
+func (col *Collector) artists(accessTok string, artistIDs []string) map[string]*models.CachedArtist {
+	resolved, err := col.store.GetCachedArtists(artistIDs)
+	// Only a clean cache miss means we've never seen the artist before
+	cacheOK := err == nil
+	if err != nil {
+		// Cache read failed; fall through to Spotify rather than dropping the genres
+		log.Printf("artist cache: %v", err)
+		resolved = map[string]*models.CachedArtist{}
+	}
+
+	var missing []string
+	for _, id := range artistIDs {
+		if resolved[id] == nil {
+			missing = append(missing, id)
+		}
+	}
+
+	for start := 0; start < len(missing); start += services.MaxArtistsPerRequest {
+		chunk := missing[start:min(start+services.MaxArtistsPerRequest, len(missing))]
+		var fetched []services.Artist
+		err := col.limiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			fetched, fetchErr = col.spotify.GetArtistsByIds(context.Background(), accessTok, chunk)
+			return fetchErr
+		}, 1) // Only 1 retry for cron to avoid delays
+		if err != nil {
+			if services.IsRateLimited(err) {
+				log.Printf("Cron: Rate limited on %d artists, skipping genres", len(missing)-start)
+			} else {
+				log.Printf("Cron: Failed to fetch %d artists: %v", len(chunk), err)
+			}
+			break
+		}
+
+		for i := range fetched {
+			artistObj := &fetched[i]
+			if artistObj.ID == "" {
+				continue // Spotify returns null for unknown ids
+			}
+			if err := col.store.UpsertArtist(artistObj); err != nil {
+				log.Printf("artist cache: %v", err)
+			} else if cacheOK {
+				emitArtistDiscovered(artistObj)
+			}
+			resolved[artistObj.ID] = &models.CachedArtist{
+				ArtistID: artistObj.ID,
+				Name:     artistObj.Name,
+				Genres:   artistObj.Genres,
+			}
+		}
+	}
+	return resolved
+}
+
+func emitArtistDiscovered(a *services.Artist) {
+	summary := "🆕 New artist: " + a.Name
+	if len(a.Genres) > 0 {
+		summary += " (" + strings.Join(a.Genres, ", ") + ")"
+	}
+	webhooks.Emit(webhooks.EventArtistDiscovered, summary, webhooks.ArtistDiscovered{
+		ArtistID:   a.ID,
+		Name:       a.Name,
+		Genres:     a.Genres,
+		SpotifyURL: "https://open.spotify.com/artist/" + a.ID,
+	})
+}
+
+// primaryArtistIDs returns the distinct first-artist IDs across items
+func primaryArtistIDs(items []services.PlayedItem) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, it := range items {
+		if len(it.Track.Artists) == 0 {
+			continue
+		}
+		id := it.Track.Artists[0].ID
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ReconcileSavedTracks walks every saved track on Spotify and soft-deletes
+// liked rows that are no longer saved (CollectSavedTracks only ever appends).
+// Nothing is marked unless the walk completes, so a failed or short fetch
+// can't wipe the library.
+func (col *Collector) ReconcileSavedTracks() {
+	accessTok, err := col.accessToken()
+	if err != nil {
+		fmt.Println("ReconcileSavedTracks:", err)
+		return
+	}
+
+	present := []string{}
+	total := -1
+	const limit = 50
+	for offset := 0; ; offset += limit {
+		var page *services.UserSavedTracks
+		err := col.limiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			page, fetchErr = col.spotify.GetUserSavedTracksPage(context.Background(), accessTok, offset, limit)
+			return fetchErr
+		}, 2)
+		if err != nil {
+			fmt.Printf("❌ ReconcileSavedTracks: fetch failed at offset %d, skipping: %v\n", offset, err)
+			return
+		}
+		if total < 0 {
+			total = page.Total
+		}
+		for _, item := range page.Items {
+			if item.Track.ID != "" {
+				present = append(present, item.Track.ID)
+			}
+		}
+		if len(page.Items) < limit {
+			break
+		}
+	}
+
+	// The library changed mid-walk (offsets shifted); try again next time
+	if len(present) < total {
+		fmt.Printf("⚠️  ReconcileSavedTracks: got %d of %d saved tracks, skipping\n", len(present), total)
+		return
+	}
+
+	removed, restored, err := col.store.ReconcileLiked(present, time.Now())
+	if err != nil {
+		fmt.Println("ReconcileSavedTracks:", err)
+		return
+	}
+	recordCollectorSuccess(config.CollectorLikedReconcile)
+	fmt.Printf("🔁 reconciled %d saved tracks: %d unliked, %d re-liked\n", len(present), removed, restored)
+}
+
+// playFromItem converts a recently-played item into a Play, taking the
+// artist's name and genres from artists (see Collector.artists). Source is
+// left for the caller.
+func playFromItem(it services.PlayedItem, artists map[string]*models.CachedArtist) store.Play {
+	play := store.Play{
+		SpotifyID:  it.Track.ID,
+		TrackName:  it.Track.Name,
+		AlbumName:  it.Track.Album.Name,
+		DurationMs: it.Track.DurationMs,
+		PlayedAt:   it.PlayedAt,
+		Metadata:   it.Track.TrackMetadata,
+
+		AlbumReleaseDate:          it.Track.Album.ReleaseDate,
+		AlbumReleaseDatePrecision: it.Track.Album.ReleaseDatePrecision,
+	}
+
+	for _, a := range it.Track.Artists {
+		play.Artists = append(play.Artists, models.PlayArtist{ID: a.ID, Name: a.Name})
+	}
+	if len(it.Track.Artists) > 0 {
+		if artistObj := artists[it.Track.Artists[0].ID]; artistObj != nil {
+			play.ArtistName = artistObj.Name
+			play.Genres = artistObj.Genres
+			if len(artistObj.Genres) > 0 {
+				play.Genre = strings.Join(artistObj.Genres, ", ")
+			}
+		}
+	}
+
+	if len(it.Track.Album.Images) > 0 {
+		play.AlbumCoverURL = it.Track.Album.Images[0].URL
+	}
+	if it.Context != nil {
+		play.ContextType = it.Context.Type
+		play.ContextURI = it.Context.URI
+	}
+	return play
+}
+
+// collectionFailed counts a failed recently-played collection; once the
+// streak reaches CRON_FAILURE_ALERT_AFTER it tells webhooks and opens a
+// collection_failing gap
+func (col *Collector) collectionFailed(err error) {
+	n := int(col.failures.Add(1))
+	if n == 1 {
+		col.failingSince.Store(time.Now().UnixNano())
+	}
+	if n != cronConfig.FailureAlertAfter {
+		return
+	}
+	openFailingGap(time.Unix(0, col.failingSince.Load()), err)
+	webhooks.Emit(webhooks.EventCollectionFailing,
+		fmt.Sprintf("⚠️ %s collection has failed %d times in a row: %v", config.CollectorRecentlyPlayed, n, err),
+		webhooks.CollectionFailing{
+			Collector: config.CollectorRecentlyPlayed,
+			Failures:  n,
+			Error:     err.Error(),
+		})
+}
+
+// collectionRecovered resets the failure streak and closes the
+// collection_failing gap it opened. The first success after startup closes
+// one a previous run left open too.
+func (col *Collector) collectionRecovered() {
+	streak := int(col.failures.Swap(0))
+	if streak >= cronConfig.FailureAlertAfter || !col.recovered.Swap(true) {
+		closeFailingGap()
+	}
+}
+
+// emitTracksCollected sends one tracks.collected event for a cycle's new plays
+func emitTracksCollected(plays []store.Play) {
+	if len(plays) == 0 {
+		return
+	}
+	data := webhooks.TracksCollected{Count: len(plays), Tracks: make([]webhooks.CollectedTrack, len(plays))}
+	lines := make([]string, len(plays))
+	for i, p := range plays {
+		data.Tracks[i] = webhooks.CollectedTrack{
+			SpotifyID:  p.SpotifyID,
+			TrackName:  p.TrackName,
+			ArtistName: p.ArtistName,
+			AlbumName:  p.AlbumName,
+			PlayedAt:   p.PlayedAt,
+		}
+		lines[i] = fmt.Sprintf("• %s – %s", p.ArtistName, p.TrackName)
+	}
+	summary := fmt.Sprintf("🎵 %d new plays\n%s", len(plays), strings.Join(lines, "\n"))
+	webhooks.Emit(webhooks.EventTracksCollected, summary, data)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- collector run history ---------- */
+
+// collectorRunRetention is how long collector_runs keeps a run
+const collectorRunRetention = 30 * 24 * time.Hour
+
+// collectorRunsPurge throttles purging old runs to once an hour
+var collectorRunsPurge struct {
+	sync.Mutex
+	last time.Time
+}
+
+// recordCollectorRun runs a collector and stores what it did in
+// collector_runs, with the Spotify requests and 429s made meanwhile. Calls
+// made concurrently (a request to the API, the now-playing poll) are counted
+// too, so they're close, not exact.
+func recordCollectorRun(name string, collect func() CollectorRun) CollectorRun {
+	start, calls, limited := time.Now(), services.RequestCount(), services.RateLimitedCount()
+	run := collect()
+	err := models.InsertCollectorRun(repository.Pool, models.CollectorRun{
+		Collector:   name,
+		StartedAt:   start,
+		FinishedAt:  time.Now(),
+		Fetched:     run.Fetched,
+		Inserted:    run.Inserted,
+		Skipped:     run.Skipped,
+		APICalls:    int(services.RequestCount() - calls),
+		RateLimited: int(services.RateLimitedCount() - limited),
+		Error:       run.Error,
+	})
+	if err != nil {
+		fmt.Printf("⚠️  %s: %v\n", name, err)
+	}
+	purgeCollectorRuns()
+	return run
+}
+
+// recordCollectorTask records a run of a collector that reports neither
+// counts nor errors (it logs them), so only its timing and API usage are kept
+func recordCollectorTask(name string, collect func()) {
+	recordCollectorRun(name, func() CollectorRun {
+		collect()
+		return CollectorRun{}
+	})
+}
+
+// purgeCollectorRuns deletes runs older than collectorRunRetention, at most once an hour
+func purgeCollectorRuns() {
+	collectorRunsPurge.Lock()
+	defer collectorRunsPurge.Unlock()
+	if time.Since(collectorRunsPurge.last) < time.Hour {
+		return
+	}
+	collectorRunsPurge.last = time.Now()
+	if n, err := models.PurgeCollectorRuns(repository.Pool, time.Now().Add(-collectorRunRetention)); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("🧹 purged %d collector runs older than %v\n", n, collectorRunRetention)
+	}
+}
+
+// GetCollectorRuns lists the latest collector runs, newest first, optionally
+// of one collector
+// GET /admin/collector-runs?collector=recently_played&limit=100
+func GetCollectorRuns(c *gin.Context) {
+	collector := c.Query("collector")
+	if collector != "" && !config.IsCollector(collector) {
+		badRequest(c, fmt.Sprintf("invalid 'collector' %q (expected %s)", collector, strings.Join(config.Collectors(), ", ")))
+		return
+	}
+	runs, err := models.GetCollectorRuns(repository.Pool, collector, parseLimit(c, 100, 1000))
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, CollectorRunsResponse{Runs: runs, Count: len(runs)})
+}
@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/spotifydb/internal/buffer"
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/store"
+	"example.com/spotifydb/internal/utils"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeSpotify is a services.Client serving canned responses
+type fakeSpotify struct {
+	played  []services.PlayedItem // newest first, as Spotify sends them
+	saved   []services.UserSavedItems
+	total   int // reported saved-track total; len(saved) when 0
+	artists map[string]services.Artist
+
+	playedErr error
+	savedErr  error
+
+	savedCalls int
+}
+
+var _ services.Client = (*fakeSpotify)(nil)
+
+func (f *fakeSpotify) ExchangeRefreshToken(ctx context.Context, refreshToken string) (services.TokenGrant, error) {
+	return services.TokenGrant{AccessToken: "access", Scopes: services.RequiredScopes}, nil
+}
+
+func (f *fakeSpotify) GetCurrentUserProfile(ctx context.Context, accessToken string) (*services.UserProfile, error) {
+	return &services.UserProfile{ID: "me"}, nil
+}
+
+func (f *fakeSpotify) GetRecentlyPlayedAfter(ctx context.Context, accessToken string, after time.Time) ([]services.PlayedItem, error) {
+	if f.playedErr != nil {
+		return nil, f.playedErr
+	}
+	var items []services.PlayedItem
+	for _, it := range f.played {
+		if it.PlayedAt.After(after) {
+			items = append(items, it)
+		}
+	}
+	return items, nil
+}
+
+func (f *fakeSpotify) GetRecentlyPlayedSince(ctx context.Context, accessToken string, since time.Time) ([]services.PlayedItem, error) {
+	return f.GetRecentlyPlayedAfter(ctx, accessToken, since)
+}
+
+func (f *fakeSpotify) GetUserSavedTracksPage(ctx context.Context, accessToken string, offset, limit int) (*services.UserSavedTracks, error) {
+	f.savedCalls++
+	if f.savedErr != nil {
+		return nil, f.savedErr
+	}
+	total := f.total
+	if total == 0 {
+		total = len(f.saved)
+	}
+	page := &services.UserSavedTracks{Limit: limit, Offset: offset, Total: total}
+	if offset < len(f.saved) {
+		page.Items = f.saved[offset:min(offset+limit, len(f.saved))]
+	}
+	return page, nil
+}
+
+func (f *fakeSpotify) GetArtistById(ctx context.Context, accessToken, artistID string) (*services.Artist, error) {
+	a, ok := f.artists[artistID]
+	if !ok {
+		return nil, services.ErrNotFound
+	}
+	return &a, nil
+}
+
+func (f *fakeSpotify) GetArtistsByIds(ctx context.Context, accessToken string, artistIDs []string) ([]services.Artist, error) {
+	out := make([]services.Artist, 0, len(artistIDs))
+	for _, id := range artistIDs {
+		out = append(out, f.artists[id]) // unknown ids come back empty, like Spotify's nulls
+	}
+	return out, nil
+}
+
+// failingStore is a Memory store whose batch insert fails with batchErr and
+// whose single inserts fail for the tracks in rowErrs. Plays of the tracks in
+// racing are stored as the batch fails, as if by another writer.
+type failingStore struct {
+	*store.Memory
+	batchErr error
+	rowErrs  map[string]error
+	racing   map[string]bool
+}
+
+func (s *failingStore) InsertRecentlyPlayedBatch(plays []store.Play) ([]store.Play, error) {
+	if s.batchErr != nil {
+		for _, p := range plays {
+			if s.racing[p.SpotifyID] {
+				s.Memory.InsertRecentlyPlayed(p)
+			}
+		}
+		return nil, s.batchErr
+	}
+	return s.Memory.InsertRecentlyPlayedBatch(plays)
+}
+
+func (s *failingStore) InsertRecentlyPlayed(p store.Play) (bool, error) {
+	if err := s.rowErrs[p.SpotifyID]; err != nil {
+		return false, err
+	}
+	return s.Memory.InsertRecentlyPlayed(p)
+}
+
+var (
+	testArtist = services.Artist{ID: "artist1", Name: "Artist One", Genres: []string{"indie rock"}}
+	baseTime   = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func played(id string, at time.Time) services.PlayedItem {
+	var it services.PlayedItem
+	it.Track.ID = id
+	it.Track.Name = "Track " + id
+	it.Track.DurationMs = 180000
+	it.Track.Album.Name = "Album"
+	it.Track.Artists = append(it.Track.Artists, struct {
+		ID   string
+		Name string
+	}{testArtist.ID, testArtist.Name})
+	it.PlayedAt = at
+	return it
+}
+
+func saved(id string, at time.Time) services.UserSavedItems {
+	return services.UserSavedItems{
+		AddedAt: at.Format(time.RFC3339),
+		Track: services.Track{
+			ID:      id,
+			Name:    "Track " + id,
+			Album:   services.Album{Name: "Album", Images: []services.AlbumImage{{URL: "https://i.scdn.co/image/x"}}},
+			Artists: []services.SimplifiedArtist{{ID: testArtist.ID, Name: testArtist.Name}},
+		},
+	}
+}
+
+// newTestCollector returns a collector over st and spotify with a stored
+// refresh token and a limiter that never makes it wait
+func newTestCollector(t *testing.T, st store.Store, spotify *fakeSpotify) *Collector {
+	t.Helper()
+	if err := st.SaveRefreshToken("refresh"); err != nil {
+		t.Fatal(err)
+	}
+	if spotify.artists == nil {
+		spotify.artists = map[string]services.Artist{testArtist.ID: testArtist}
+	}
+	col := NewCollector(st, spotify)
+	col.limiter = utils.NewRateLimiterWithBudget(6000)
+	return col
+}
+
+func TestCollectRecentTracks(t *testing.T) {
+	unavailable := &pgconn.PgError{Code: "08006", Message: "connection failure"}
+	badRow := &pgconn.PgError{Code: "22P02", Message: "invalid input syntax"}
+
+	tests := []struct {
+		name     string
+		stored   []store.Play // already in the store
+		played   []services.PlayedItem
+		batchErr error
+		rowErrs  map[string]error
+		racing   map[string]bool
+		buffer   bool
+
+		want     CollectorRun
+		wantIDs  []string // stored plays afterwards, oldest first
+		buffered int      // plays left in the write buffer
+	}{
+		{
+			name: "stores new plays oldest first",
+			played: []services.PlayedItem{
+				played("b", baseTime.Add(4*time.Minute)),
+				played("a", baseTime),
+			},
+			want:    CollectorRun{Fetched: 2, Inserted: 2},
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name:   "skips plays already stored",
+			stored: []store.Play{{SpotifyID: "a", PlayedAt: baseTime}},
+			played: []services.PlayedItem{
+				played("b", baseTime.Add(4*time.Minute)),
+				played("a", baseTime),
+			},
+			want:    CollectorRun{Fetched: 1, Inserted: 1},
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name: "drops a near-duplicate within the batch",
+			played: []services.PlayedItem{
+				played("c", baseTime.Add(5*time.Minute)),
+				played("a", baseTime.Add(2*time.Second)),
+				played("a", baseTime),
+			},
+			want:    CollectorRun{Fetched: 3, Inserted: 2, Skipped: 1},
+			wantIDs: []string{"a", "c"},
+		},
+		{
+			name:   "drops a near-duplicate of a stored play",
+			stored: []store.Play{{SpotifyID: "a", PlayedAt: baseTime}},
+			played: []services.PlayedItem{
+				played("b", baseTime.Add(3*time.Minute)),
+				played("a", baseTime.Add(3*time.Second)),
+			},
+			want:    CollectorRun{Fetched: 2, Inserted: 1, Skipped: 1},
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name: "buffers plays while the database is unavailable",
+			played: []services.PlayedItem{
+				played("b", baseTime.Add(4*time.Minute)),
+				played("a", baseTime),
+			},
+			batchErr: unavailable,
+			buffer:   true,
+			want:     CollectorRun{Fetched: 2, Buffered: 2},
+			buffered: 2,
+		},
+		{
+			name: "retries row by row after a batch error",
+			played: []services.PlayedItem{
+				played("c", baseTime.Add(8*time.Minute)),
+				played("b", baseTime.Add(4*time.Minute)),
+				played("a", baseTime),
+			},
+			batchErr: badRow,
+			rowErrs:  map[string]error{"b": badRow},
+			buffer:   true,
+			want:     CollectorRun{Fetched: 3, Inserted: 2, Skipped: 1},
+			wantIDs:  []string{"a", "c"},
+		},
+		{
+			name: "row by row retry doesn't count plays stored meanwhile",
+			played: []services.PlayedItem{
+				played("b", baseTime.Add(4*time.Minute)),
+				played("a", baseTime),
+			},
+			batchErr: badRow,
+			racing:   map[string]bool{"a": true},
+			want:     CollectorRun{Fetched: 2, Inserted: 1, Skipped: 1},
+			wantIDs:  []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := store.NewMemory()
+			for _, p := range tt.stored {
+				if _, err := mem.InsertRecentlyPlayed(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			st := &failingStore{Memory: mem, batchErr: tt.batchErr, rowErrs: tt.rowErrs, racing: tt.racing}
+
+			prevBuffer := writeBuffer
+			defer func() { writeBuffer = prevBuffer }()
+			writeBuffer = nil
+			if tt.buffer {
+				q, err := buffer.Open(filepath.Join(t.TempDir(), "plays.jsonl"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeBuffer = q
+			}
+
+			col := newTestCollector(t, st, &fakeSpotify{played: tt.played})
+			run := col.CollectRecentTracks()
+			if run != tt.want {
+				t.Errorf("run = %+v, want %+v", run, tt.want)
+			}
+
+			var ids []string
+			for _, p := range mem.Plays() {
+				ids = append(ids, p.SpotifyID)
+			}
+			if !equalStrings(ids, tt.wantIDs) {
+				t.Errorf("stored plays = %v, want %v", ids, tt.wantIDs)
+			}
+
+			if writeBuffer != nil {
+				n, err := writeBuffer.Len()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != tt.buffered {
+					t.Errorf("buffered plays = %d, want %d", n, tt.buffered)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectRecentTracksGenres(t *testing.T) {
+	mem := store.NewMemory()
+	col := newTestCollector(t, mem, &fakeSpotify{played: []services.PlayedItem{played("a", baseTime)}})
+	col.CollectRecentTracks()
+
+	plays := mem.Plays()
+	if len(plays) != 1 {
+		t.Fatalf("got %d plays, want 1", len(plays))
+	}
+	if plays[0].ArtistName != testArtist.Name || plays[0].Genre != "indie rock" {
+		t.Errorf("play artist/genre = %q/%q, want %q/%q", plays[0].ArtistName, plays[0].Genre, testArtist.Name, "indie rock")
+	}
+	if a, _ := mem.GetCachedArtist(testArtist.ID); a == nil {
+		t.Error("artist wasn't cached")
+	}
+}
+
+func TestCollectRecentTracksFetchError(t *testing.T) {
+	col := newTestCollector(t, store.NewMemory(), &fakeSpotify{playedErr: errors.New("boom")})
+	run := col.CollectRecentTracks()
+	if run.Error == "" {
+		t.Error("expected the fetch error to be reported")
+	}
+	if col.failures.Load() != 1 {
+		t.Errorf("failure streak = %d, want 1", col.failures.Load())
+	}
+}
+
+func TestCollectSavedTracks(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   []store.LikedTrack
+		saved    []services.UserSavedItems // newest first
+		savedErr error
+
+		want      CollectorRun
+		wantIDs   []string
+		wantError bool
+	}{
+		{
+			name: "stores every saved track",
+			saved: []services.UserSavedItems{
+				saved("b", baseTime.Add(time.Hour)),
+				saved("a", baseTime),
+			},
+			want:    CollectorRun{Fetched: 2},
+			wantIDs: []string{"b", "a"},
+		},
+		{
+			name:   "stops at the newest stored track",
+			stored: []store.LikedTrack{{SpotifyID: "a", AddedAt: baseTime}},
+			saved: []services.UserSavedItems{
+				saved("c", baseTime.Add(2*time.Hour)),
+				saved("b", baseTime.Add(time.Hour)),
+				saved("a", baseTime),
+			},
+			want:    CollectorRun{Fetched: 3},
+			wantIDs: []string{"a", "c", "b"},
+		},
+		{
+			name: "skips tracks without an artist or cover",
+			saved: []services.UserSavedItems{
+				saved("b", baseTime.Add(time.Hour)),
+				{AddedAt: baseTime.Format(time.RFC3339), Track: services.Track{ID: "a"}},
+			},
+			want:    CollectorRun{Fetched: 2},
+			wantIDs: []string{"b"},
+		},
+		{
+			name:      "reports a fetch error",
+			savedErr:  errors.New("boom"),
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := store.NewMemory()
+			for _, l := range tt.stored {
+				if _, err := mem.InsertRecentlyLiked(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			col := newTestCollector(t, mem, &fakeSpotify{saved: tt.saved, savedErr: tt.savedErr})
+
+			run := col.CollectSavedTracks()
+			if tt.wantError {
+				if run.Error == "" {
+					t.Error("expected the fetch error to be reported")
+				}
+				return
+			}
+			if run.Error != "" || run.Fetched != tt.want.Fetched {
+				t.Errorf("run = %+v, want %+v", run, tt.want)
+			}
+
+			var ids []string
+			for _, l := range mem.Liked() {
+				ids = append(ids, l.SpotifyID)
+			}
+			if !equalStrings(ids, tt.wantIDs) {
+				t.Errorf("liked tracks = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestReconcileSavedTracks(t *testing.T) {
+	unliked := baseTime.Add(-time.Hour)
+	stored := []store.LikedTrack{
+		{SpotifyID: "kept", AddedAt: baseTime},
+		{SpotifyID: "removed", AddedAt: baseTime},
+		{SpotifyID: "restored", AddedAt: baseTime, UnlikedAt: &unliked},
+	}
+
+	tests := []struct {
+		name      string
+		saved     []services.UserSavedItems
+		total     int
+		savedErr  error
+		wantLiked map[string]bool // whether each stored track ends up liked
+	}{
+		{
+			name:      "marks unsaved tracks and restores re-saved ones",
+			saved:     []services.UserSavedItems{saved("kept", baseTime), saved("restored", baseTime)},
+			wantLiked: map[string]bool{"kept": true, "removed": false, "restored": true},
+		},
+		{
+			name:      "changes nothing when the walk comes up short",
+			saved:     []services.UserSavedItems{saved("kept", baseTime)},
+			total:     3,
+			wantLiked: map[string]bool{"kept": true, "removed": true, "restored": false},
+		},
+		{
+			name:      "changes nothing when a page fails",
+			savedErr:  errors.New("boom"),
+			wantLiked: map[string]bool{"kept": true, "removed": true, "restored": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := store.NewMemory()
+			for _, l := range stored {
+				if _, err := mem.InsertRecentlyLiked(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			col := newTestCollector(t, mem, &fakeSpotify{saved: tt.saved, total: tt.total, savedErr: tt.savedErr})
+			col.ReconcileSavedTracks()
+
+			for _, l := range mem.Liked() {
+				if liked := l.UnlikedAt == nil; liked != tt.wantLiked[l.SpotifyID] {
+					t.Errorf("%s liked = %v, want %v", l.SpotifyID, liked, tt.wantLiked[l.SpotifyID])
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileSavedTracksPages(t *testing.T) {
+	var items []services.UserSavedItems
+	for i := range 120 {
+		items = append(items, saved(string(rune('a'+i%26))+string(rune('a'+i/26)), baseTime))
+	}
+	spotify := &fakeSpotify{saved: items}
+	col := newTestCollector(t, store.NewMemory(), spotify)
+	col.ReconcileSavedTracks()
+	if spotify.savedCalls != 3 {
+		t.Errorf("fetched %d pages, want 3", spotify.savedCalls)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
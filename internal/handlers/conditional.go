@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/* ---------- conditional GETs ---------- */
+
+// versionFunc reports when the data behind a response last changed, plus a
+// fingerprint that changes whenever the response would
+type versionFunc func(pool *pgxpool.Pool) (modified time.Time, fingerprint string, err error)
+
+// PlaysConditional serves next with ETag / Last-Modified from recently_played
+func PlaysConditional(next gin.HandlerFunc) gin.HandlerFunc {
+	return conditional(models.PlaysVersion, next)
+}
+
+// LikedConditional serves next with ETag / Last-Modified from recently_liked
+func LikedConditional(next gin.HandlerFunc) gin.HandlerFunc {
+	return conditional(models.LikedVersion, next)
+}
+
+// conditional answers 304 Not Modified when the client's If-None-Match (or,
+// without one, If-Modified-Since) still matches, and otherwise runs next with
+// the validators set. The ETag covers the query string, so each page and
+// filter is cached separately.
+func conditional(version versionFunc, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if repository.Pool == nil {
+			next(c)
+			return
+		}
+		modified, fingerprint, err := version(repository.Pool)
+		if err != nil {
+			next(c) // the handler reports the database error
+			return
+		}
+
+		sum := sha1.Sum([]byte(c.Request.URL.RawQuery + "|" + modified.UTC().Format(time.RFC3339Nano) + "|" + fingerprint))
+		etag := `W/"` + hex.EncodeToString(sum[:10]) + `"`
+		h := c.Writer.Header()
+		h.Set("ETag", etag)
+		h.Set("Cache-Control", "no-cache") // always revalidate
+		if !modified.IsZero() {
+			h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		}
+
+		if notModified(c.Request, etag, modified) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+		next(c)
+	}
+}
+
+// notModified applies If-None-Match, falling back to If-Modified-Since
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- debug payload capture ---------- */
+
+// debugFileQueue is how many payloads can wait to be written to
+// SPOTIFY_DEBUG_DIR; more are only kept in memory
+const debugFileQueue = 100
+
+// CapturedPayload is a Spotify response body kept for debugging. Body is the
+// JSON Spotify sent, or a string when it isn't valid JSON (or was cut).
+type CapturedPayload struct {
+	services.Payload
+	Size int `json:"size"` // bytes kept
+	Body any `json:"body"`
+}
+
+// payloadRing keeps the latest captured payloads, oldest overwritten first
+type payloadRing struct {
+	mu    sync.Mutex
+	items []services.Payload
+	next  int
+}
+
+func (r *payloadRing) add(p services.Payload) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.items) < cap(r.items) {
+		r.items = append(r.items, p)
+		return
+	}
+	r.items[r.next] = p
+	r.next = (r.next + 1) % len(r.items)
+}
+
+// latest returns up to limit payloads, newest first, only those of endpoint
+// unless it's empty
+func (r *payloadRing) latest(endpoint string, limit int) []services.Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []services.Payload{}
+	for i := range r.items {
+		p := r.items[(r.next-1-i+2*len(r.items))%len(r.items)]
+		if endpoint != "" && p.Endpoint != endpoint {
+			continue
+		}
+		out = append(out, p)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+var (
+	debugConfig   = config.DefaultDebugCaptureConfig()
+	debugPayloads *payloadRing
+)
+
+// SetDebugCapture starts keeping the Spotify responses cfg picks in memory,
+// and in cfg.Dir when set. Call it once at startup.
+func SetDebugCapture(cfg config.DebugCaptureConfig) {
+	debugConfig = cfg
+	if !cfg.Enabled() {
+		return
+	}
+	ring := &payloadRing{items: make([]services.Payload, 0, cfg.Buffer)}
+	debugPayloads = ring
+
+	var files chan services.Payload
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+			fmt.Printf("⚠️  debug capture: %v; payloads are only kept in memory\n", err)
+		} else {
+			files = make(chan services.Payload, debugFileQueue)
+			go writeDebugPayloads(cfg.Dir, files)
+		}
+	}
+
+	services.SetPayloadCapture(cfg.Captures, cfg.MaxBytes, func(p services.Payload) {
+		ring.add(p)
+		if files != nil {
+			select {
+			case files <- p:
+			default:
+			}
+		}
+	})
+	fmt.Printf("🐞 Capturing Spotify payloads of %v\n", cfg.Endpoints)
+}
+
+// writeDebugPayloads writes each payload to dir as
+// <time>_<endpoint>_<status>.json
+func writeDebugPayloads(dir string, files <-chan services.Payload) {
+	for p := range files {
+		name := fmt.Sprintf("%s_%s_%d.json", p.At.UTC().Format("20060102T150405.000000000Z"), p.Endpoint, p.Status)
+		if err := os.WriteFile(filepath.Join(dir, name), p.Body, 0o644); err != nil {
+			fmt.Printf("⚠️  debug capture: %v\n", err)
+		}
+	}
+}
+
+// GetLastPayloads returns the latest captured Spotify responses, newest first
+// GET /admin/debug/last-payloads?endpoint=player&limit=10
+func GetLastPayloads(c *gin.Context) {
+	limit := parseLimit(c, 10, 1000)
+	endpoint := c.Query("endpoint")
+
+	payloads := []CapturedPayload{}
+	if debugPayloads != nil {
+		for _, p := range debugPayloads.latest(endpoint, limit) {
+			cp := CapturedPayload{Payload: p, Size: len(p.Body), Body: string(p.Body)}
+			if !p.Truncated && json.Valid(p.Body) {
+				cp.Body = json.RawMessage(p.Body)
+			}
+			payloads = append(payloads, cp)
+		}
+	}
+	c.JSON(http.StatusOK, LastPayloadsResponse{
+		Enabled:   debugConfig.Enabled(),
+		Endpoints: append([]string{}, debugConfig.Endpoints...),
+		Payloads:  payloads,
+		Count:     len(payloads),
+	})
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- discovery feed ---------- */
+
+const (
+	discoverySeedArtists = 5
+	discoverySeedGenres  = 2
+	discoveryPerSeed     = 20
+	discoveryNewReleases = 50
+	// candidates not seen again in this long drop out of the feed
+	discoveryMaxAge = 30 * 24 * time.Hour
+)
+
+// RefreshDiscoveryFeed collects new releases and recommendations seeded from
+// my top artists and genres into discovery_feed. Each seed gets its own
+// recommendations call so every suggestion can say which seed it came from.
+func RefreshDiscoveryFeed() {
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		fmt.Println("RefreshDiscoveryFeed:", err)
+		return
+	}
+
+	var candidates []models.DiscoveryCandidate
+	releases, err := newReleaseCandidates(accessTok)
+	if err != nil {
+		fmt.Println("RefreshDiscoveryFeed: new releases:", err)
+	}
+	candidates = append(candidates, releases...)
+
+	recs, err := recommendationCandidates(accessTok)
+	if err != nil {
+		fmt.Println("RefreshDiscoveryFeed: recommendations:", err)
+	}
+	candidates = append(candidates, recs...)
+
+	saved, err := models.SaveDiscoveryCandidates(repository.Pool, candidates)
+	if err != nil {
+		fmt.Println("RefreshDiscoveryFeed:", err)
+		return
+	}
+	pruned, err := models.PruneDiscoveryFeed(repository.Pool, time.Now().Add(-discoveryMaxAge))
+	if err != nil {
+		fmt.Println("RefreshDiscoveryFeed:", err)
+	}
+	fmt.Printf("🧭 discovery feed: %d candidates saved (%d already in history), %d expired\n",
+		saved, len(candidates)-saved, pruned)
+}
+
+// newReleaseCandidates ranks new releases by how much I play their artist
+func newReleaseCandidates(accessTok string) ([]models.DiscoveryCandidate, error) {
+	var albums []services.Album
+	err := cronRateLimiter.RetryWithBackoff(func() error {
+		var fetchErr error
+		albums, fetchErr = services.GetNewReleases(context.Background(), accessTok, discoveryNewReleases)
+		return fetchErr
+	}, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(albums))
+	names := make([]string, 0, len(albums))
+	for _, a := range albums {
+		if len(a.Artists) > 0 {
+			ids = append(ids, a.Artists[0].ID)
+			names = append(names, a.Artists[0].Name)
+		}
+	}
+	history, err := models.GetArtistHistory(repository.Pool, ids, names)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]models.DiscoveryCandidate, 0, len(albums))
+	for _, a := range albums {
+		if a.ID == "" || len(a.Artists) == 0 {
+			continue
+		}
+		artist := a.Artists[0]
+		c := models.DiscoveryCandidate{
+			Kind:        "album",
+			SpotifyID:   a.ID,
+			Name:        a.Name,
+			ArtistName:  artist.Name,
+			ArtistID:    artist.ID,
+			ReleaseDate: a.ReleaseDate,
+			Source:      "new_releases",
+			Reason:      "new release",
+			Score:       history[artist.ID].PlayCount,
+		}
+		if c.Score > 0 {
+			c.Reason = fmt.Sprintf("new release; because you played %s %d times", artist.Name, c.Score)
+		}
+		if len(a.Images) > 0 {
+			c.ImageURL = a.Images[0].URL
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// recommendationCandidates asks Spotify for tracks like each of my top artists
+// and genres, scored by how much I play the seed
+func recommendationCandidates(accessTok string) ([]models.DiscoveryCandidate, error) {
+	artists, err := models.GetTopArtistSeeds(repository.Pool, nil, discoverySeedArtists)
+	if err != nil {
+		return nil, err
+	}
+	genres, err := models.GetTopGenreSeeds(repository.Pool, nil, discoverySeedGenres)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []models.DiscoveryCandidate
+	fetch := func(seed models.DiscoverySeed, reason string) error {
+		var seeds services.RecommendationSeeds
+		if seed.ID != "" {
+			seeds.Artists = []string{seed.ID}
+		} else {
+			seeds.Genres = []string{seed.Name}
+		}
+		var tracks []services.Track
+		err := cronRateLimiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			tracks, fetchErr = services.GetRecommendations(context.Background(), accessTok, seeds, discoveryPerSeed)
+			return fetchErr
+		}, 1)
+		if err != nil {
+			return err
+		}
+		for _, t := range tracks {
+			if t.ID == "" || len(t.Artists) == 0 {
+				continue
+			}
+			c := models.DiscoveryCandidate{
+				Kind:        "track",
+				SpotifyID:   t.ID,
+				Name:        t.Name,
+				ArtistName:  t.Artists[0].Name,
+				ArtistID:    t.Artists[0].ID,
+				AlbumName:   t.Album.Name,
+				ReleaseDate: t.Album.ReleaseDate,
+				Source:      "recommendations",
+				Reason:      reason,
+				Score:       seed.Plays,
+			}
+			if len(t.Album.Images) > 0 {
+				c.ImageURL = t.Album.Images[0].URL
+			}
+			candidates = append(candidates, c)
+		}
+		return nil
+	}
+
+	for _, seed := range artists {
+		if err := fetch(seed, fmt.Sprintf("because you played %s %d times", seed.Name, seed.Plays)); err != nil {
+			// Every seed hits the same endpoint, so one failure means they all will
+			return candidates, err
+		}
+	}
+	for _, seed := range genres {
+		if err := fetch(seed, fmt.Sprintf("because you played %d %s tracks", seed.Plays, seed.Name)); err != nil {
+			return candidates, err
+		}
+	}
+	return candidates, nil
+}
+
+// GetDiscover returns ranked suggestions I haven't played yet.
+// ?type=track|album|all (default all), ?limit= (default 20, max 100)
+func GetDiscover(c *gin.Context) {
+	kind := c.DefaultQuery("type", "all")
+	switch kind {
+	case "all":
+		kind = ""
+	case "track", "album":
+	default:
+		badRequest(c, fmt.Sprintf("invalid 'type' %q (expected track, album or all)", kind))
+		return
+	}
+	limit := parseLimit(c, 20, 100)
+
+	feed, err := models.GetDiscoveryFeed(repository.Pool, kind, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DiscoverResponse{
+		Suggestions: feed,
+		Count:       len(feed),
+	})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIError is the body of every error response, wrapped as {"error": {...}}
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Error codes returned in APIError.Code
+const (
+	CodeBadRequest          = "bad_request"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodePayloadTooLarge     = "payload_too_large"
+	CodeRateLimited         = "rate_limited"
+	CodeInternal            = "internal_error"
+	CodeDatabaseUnavailable = "database_unavailable"
+	CodeSpotifyUnauthorized = "spotify_unauthorized"
+	CodeSpotifyUnavailable  = "spotify_unavailable"
+	CodeWebhookFailed       = "webhook_failed"
+)
+
+// RespondError writes the shared error envelope and aborts the request
+func RespondError(c *gin.Context, status int, code, message string, details any) {
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: APIError{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}})
+}
+
+func badRequest(c *gin.Context, message string) {
+	RespondError(c, http.StatusBadRequest, CodeBadRequest, message, nil)
+}
+
+func notFound(c *gin.Context, message string) {
+	RespondError(c, http.StatusNotFound, CodeNotFound, message, nil)
+}
+
+// internalError reports a failed query, as 503 when the database is unreachable
+func internalError(c *gin.Context, err error) {
+	if repository.IsUnavailable(err) {
+		RespondError(c, http.StatusServiceUnavailable, CodeDatabaseUnavailable, "database unavailable", err.Error())
+		return
+	}
+	RespondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), nil)
+}
+
+// spotifyError maps a failed token refresh or Spotify call to 401, 404 or 503
+func spotifyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errNoRefreshToken):
+		RespondError(c, http.StatusUnauthorized, CodeSpotifyUnauthorized,
+			"no Spotify account connected; POST a refresh token to /save-refresh", nil)
+	case errors.Is(err, services.ErrUnauthorized):
+		RespondError(c, http.StatusUnauthorized, CodeSpotifyUnauthorized,
+			"Spotify rejected the stored credentials; re-authenticate", err.Error())
+	case errors.Is(err, services.ErrMissingScopes):
+		RespondError(c, http.StatusUnauthorized, CodeSpotifyUnauthorized,
+			"the stored Spotify token is missing required scopes; re-authorize", err.Error())
+	case errors.Is(err, services.ErrNotFound):
+		notFound(c, err.Error())
+	case services.IsRateLimited(err):
+		RespondError(c, http.StatusServiceUnavailable, CodeRateLimited,
+			"rate limited by Spotify, try again shortly", err.Error())
+	default:
+		RespondError(c, http.StatusServiceUnavailable, CodeSpotifyUnavailable,
+			"Spotify request failed", err.Error())
+	}
+}
+
+// NoRoute answers unknown paths with the error envelope
+func NoRoute(c *gin.Context) {
+	notFound(c, "no route for "+c.Request.Method+" "+c.Request.URL.Path)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rows fetched per round trip while streaming an export
+const exportChunkSize = 1000
+
+var exportContentTypes = map[string]string{
+	"csv":     "text/csv; charset=utf-8",
+	"json":    "application/json",
+	"ndjson":  "application/x-ndjson",
+	"parquet": "application/vnd.apache.parquet",
+}
+
+var exportCSVHeader = []string{
+	"id", "spotify_song_id", "track_name", "artist_name", "album_name",
+	"album_cover_url", "genre", "duration_ms", "played_at", "source",
+}
+
+/* ---------- export listening history ---------- */
+
+// ExportRecentlyPlayed streams recently_played as a file download in
+// chunks so the full history never has to sit in memory.
+func ExportRecentlyPlayed(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		badRequest(c, "invalid 'format' (expected csv, json, ndjson or parquet)")
+		return
+	}
+
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	source, err := parseSource(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("recently_played_%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	written, err := WriteHistory(c.Writer, format, from, to, source)
+	if err != nil {
+		// Headers are already sent, so all we can do is stop the stream
+		log.Printf("ExportRecentlyPlayed: aborting after %d rows: %v", written, err)
+		return
+	}
+	log.Printf("ExportRecentlyPlayed: streamed %d rows as %s", written, format)
+}
+
+// WriteHistory writes plays between from and to (nil for open-ended), only
+// those stored by source unless it's empty, to w as csv, json, ndjson or
+// parquet, flushing after every chunk when w is an http.Flusher. Returns how
+// many rows were written.
+func WriteHistory(w io.Writer, format string, from, to *time.Time, source string) (int, error) {
+	if _, ok := exportContentTypes[format]; !ok {
+		return 0, fmt.Errorf("unknown export format %q", format)
+	}
+	if format == "parquet" {
+		return WriteParquet(w, models.RecentlyPlayedExport, from, to, source)
+	}
+	bw := bufio.NewWriter(w)
+	csvWriter := csv.NewWriter(bw)
+	encoder := json.NewEncoder(bw)
+	flush := func() error {
+		if format == "csv" {
+			csvWriter.Flush()
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	switch format {
+	case "csv":
+		csvWriter.Write(exportCSVHeader)
+	case "json":
+		bw.WriteString("[")
+	}
+
+	var cursorTime time.Time
+	cursorID := 0
+	written := 0
+	for {
+		chunk, err := models.GetRecentlyPlayedChunk(repository.Pool, from, to, source, cursorTime, cursorID, exportChunkSize)
+		if err != nil {
+			return written, err
+		}
+
+		for _, t := range chunk {
+			switch format {
+			case "csv":
+				csvWriter.Write([]string{
+					strconv.Itoa(t.ID), t.SpotifySongID, t.TrackName, t.ArtistName, t.AlbumName,
+					t.AlbumCoverUrl, t.Genre, strconv.Itoa(t.DurationMS),
+					t.PlayedAt.Format(time.RFC3339), t.Source,
+				})
+			case "json":
+				if written > 0 {
+					bw.WriteString(",")
+				}
+				encoder.Encode(t)
+			case "ndjson":
+				encoder.Encode(t)
+			}
+			written++
+		}
+		if err := flush(); err != nil {
+			return written, err
+		}
+
+		if len(chunk) < exportChunkSize {
+			break
+		}
+		last := chunk[len(chunk)-1]
+		cursorTime, cursorID = last.PlayedAt, last.ID
+	}
+
+	if format == "json" {
+		bw.WriteString("]")
+	}
+	return written, flush()
+}
+
+// WriteParquet writes the rows of table between from and to (nil for
+// open-ended), only those stored by source unless it's empty, to w as a
+// Parquet file with every column of the table typed. Returns how many rows
+// were written.
+func WriteParquet(w io.Writer, table models.ExportTable, from, to *time.Time, source string) (int, error) {
+	return table.WriteParquet(context.Background(), repository.Pool, w, from, to, source)
+}
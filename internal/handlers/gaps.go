@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/notifications"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- collection gaps ---------- */
+
+// gapLookback is how far back each gap_detection run looks for silences
+const gapLookback = 7 * 24 * time.Hour
+
+// DetectCollectionGaps records every silence in the last week longer than
+// CRON_GAP_AFTER of active hours, extends the one still running, and says
+// why plays are missing when it can tell
+func DetectCollectionGaps() {
+	now := time.Now()
+	since := now.Add(-gapLookback)
+	silences, err := models.FindPlayGaps(repository.Pool, since, cronConfig.GapAfter)
+	if err != nil {
+		fmt.Println("cron:", err)
+		return
+	}
+	failing, err := models.GetCollectionGaps(repository.Pool, models.GapCollectionFailing, &since, nil, 100)
+	if err != nil {
+		fmt.Println("cron:", err)
+		return
+	}
+
+	for _, s := range silences {
+		end := now
+		if s.End != nil {
+			end = *s.End
+		}
+		active := cronConfig.ActiveDuration(s.Start, end)
+		if active < cronConfig.GapAfter {
+			continue // mostly outside active hours
+		}
+		gap := models.CollectionGap{
+			Kind:          models.GapNoPlays,
+			StartedAt:     s.Start,
+			EndedAt:       s.End,
+			ActiveMinutes: int(active.Minutes()),
+			Reason:        gapReason(s.Start, end, failing),
+		}
+		inserted, err := models.UpsertCollectionGap(repository.Pool, gap)
+		if err != nil {
+			fmt.Println("cron:", err)
+			return
+		}
+		if inserted {
+			announceGap(gap)
+		}
+	}
+
+	// Keep the running collection_failing gap's length current
+	open, err := models.OpenCollectionGaps(repository.Pool, models.GapCollectionFailing)
+	if err != nil {
+		fmt.Println("cron:", err)
+		return
+	}
+	for _, g := range open {
+		g.ActiveMinutes = int(cronConfig.ActiveDuration(g.StartedAt, now).Minutes())
+		if _, err := models.UpsertCollectionGap(repository.Pool, g); err != nil {
+			fmt.Println("cron:", err)
+			return
+		}
+	}
+	recordCollectorSuccess(config.CollectorGapDetection)
+}
+
+// gapReason explains a silence: collection failing during it, the server
+// not running at its start, or otherwise nothing played
+func gapReason(start, end time.Time, failing []models.CollectionGap) string {
+	for _, f := range failing {
+		fEnd := end
+		if f.EndedAt != nil {
+			fEnd = *f.EndedAt
+		}
+		if f.StartedAt.Before(end) && fEnd.After(start) {
+			return "recently-played collection was failing: " + f.Reason
+		}
+	}
+	if startedAt.After(start) && startedAt.Before(end) {
+		return fmt.Sprintf("server was down or restarted; running again since %s",
+			startedAt.In(config.Timezone()).Format("2006-01-02 15:04"))
+	}
+	return "no plays during active hours"
+}
+
+// announceGap notifies about a newly found gap if it's recent; older ones
+// found on the first run are only recorded
+func announceGap(g models.CollectionGap) {
+	if g.EndedAt != nil && time.Since(*g.EndedAt) > 24*time.Hour {
+		return
+	}
+	loc := config.Timezone()
+	span := "since " + g.StartedAt.In(loc).Format("2006-01-02 15:04")
+	if g.EndedAt != nil {
+		span = fmt.Sprintf("from %s to %s", g.StartedAt.In(loc).Format("2006-01-02 15:04"),
+			g.EndedAt.In(loc).Format("2006-01-02 15:04"))
+	}
+	notify(notifications.KindCollectionGap,
+		fmt.Sprintf("🕳️ No plays %s (%dh of active hours): %s", span, g.ActiveMinutes/60, g.Reason), g)
+}
+
+// openFailingGap records that recently-played collection has been failing
+// since the streak began
+func openFailingGap(since time.Time, err error) {
+	if repository.Pool == nil {
+		return
+	}
+	gap := models.CollectionGap{
+		Kind:          models.GapCollectionFailing,
+		StartedAt:     since.Truncate(time.Second),
+		ActiveMinutes: int(cronConfig.ActiveDuration(since, time.Now()).Minutes()),
+		Reason:        err.Error(),
+	}
+	if _, err := models.UpsertCollectionGap(repository.Pool, gap); err != nil {
+		fmt.Println("cron:", err)
+	}
+}
+
+// closeFailingGap ends the open collection_failing gap, if there is one
+func closeFailingGap() {
+	if repository.Pool == nil {
+		return
+	}
+	open, err := models.OpenCollectionGaps(repository.Pool, models.GapCollectionFailing)
+	if err != nil {
+		fmt.Println("cron:", err)
+		return
+	}
+	now := time.Now()
+	for _, g := range open {
+		g.EndedAt = &now
+		g.ActiveMinutes = int(cronConfig.ActiveDuration(g.StartedAt, now).Minutes())
+		if _, err := models.UpsertCollectionGap(repository.Pool, g); err != nil {
+			fmt.Println("cron:", err)
+		}
+	}
+}
+
+// GetCollectionGaps lists recorded gaps overlapping ?from= to ?to=, newest
+// first, filtered by ?kind=
+func GetCollectionGaps(c *gin.Context) {
+	kind := c.Query("kind")
+	if kind != "" && kind != models.GapNoPlays && kind != models.GapCollectionFailing {
+		badRequest(c, fmt.Sprintf("invalid 'kind' %q (expected %s)", kind, strings.Join(models.GapKinds, " or ")))
+		return
+	}
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	limit := parseLimit(c, 50, 500)
+
+	gaps, err := models.GetCollectionGaps(repository.Pool, kind, from, to, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	resp := GapsResponse{Gaps: gaps, Count: len(gaps)}
+	for _, g := range gaps {
+		resp.ActiveMinutes += g.ActiveMinutes
+		if g.EndedAt == nil {
+			resp.Open++
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- genre list ---------- */
+
+func ListGenres(c *gin.Context) {
+	genres, err := repository.ListGenres()
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if genres == nil {
+		genres = []repository.GenreCount{}
+	}
+
+	c.JSON(http.StatusOK, GenresResponse{
+		Genres: genres,
+		Count:  len(genres),
+	})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+var startedAt = time.Now()
+
+// cronRunning is set once StartSpotifyCron runs. An API-only replica
+// (RUN_MODE=server) never collects, so its readiness ignores freshness.
+var cronRunning atomic.Bool
+
+// collectorStatus remembers when each collector last finished successfully
+var collectorStatus = struct {
+	sync.RWMutex
+	lastSuccess map[string]time.Time
+}{lastSuccess: map[string]time.Time{}}
+
+func recordCollectorSuccess(collector string) {
+	collectorStatus.Lock()
+	collectorStatus.lastSuccess[collector] = time.Now()
+	collectorStatus.Unlock()
+}
+
+func lastCollectorSuccess(collector string) (time.Time, bool) {
+	collectorStatus.RLock()
+	defer collectorStatus.RUnlock()
+	t, ok := collectorStatus.lastSuccess[collector]
+	return t, ok
+}
+
+/* ---------- liveness ---------- */
+
+// Healthz reports whether the process is up and the database is reachable
+func Healthz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := repository.Ping(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, HealthzResponse{
+			Status:   "unhealthy",
+			Database: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, HealthzResponse{
+		Status:        "ok",
+		UptimeSeconds: int(time.Since(startedAt).Seconds()),
+	})
+}
+
+/* ---------- readiness ---------- */
+
+// Readyz checks every dependency collection needs: the database, a stored
+// refresh token, and a recent successful run of the recently-played collector.
+func Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	ready := true
+	var checks ReadyzChecks
+
+	if err := repository.Ping(ctx); err != nil {
+		ready = false
+		checks.Database = DependencyCheck{OK: false, Error: err.Error()}
+	} else {
+		checks.Database = DependencyCheck{OK: true}
+	}
+
+	refreshTok, err := repository.GetRefreshToken()
+	hasToken := err == nil && refreshTok != ""
+	if !hasToken {
+		ready = false
+	}
+	checks.RefreshToken = DependencyCheck{OK: hasToken}
+
+	// Collection is stale once we've missed a few idle-hour cycles in a row
+	staleAfter := 3 * cronConfig.IdleInterval
+	collection := CollectionCheck{StaleAfter: staleAfter.String()}
+	if !cronRunning.Load() {
+		collection.OK = true
+		collection.Status = "not_collecting"
+	} else if !collectorLeading.Load() {
+		// Another instance holds the collector lock and collects
+		collection.OK = true
+		collection.Status = "standby"
+	} else if !cronConfig.Enabled(config.CollectorRecentlyPlayed) {
+		collection.OK = true
+		collection.Status = "disabled"
+	} else if last, ok := lastCollectorSuccess(config.CollectorRecentlyPlayed); ok {
+		age := time.Since(last)
+		ageSeconds := int(age.Seconds())
+		collection.LastSuccess = &last
+		collection.AgeSeconds = &ageSeconds
+		collection.OK = age <= staleAfter
+		if age > staleAfter {
+			ready = false
+			collection.Status = "stale"
+		} else {
+			collection.Status = "ok"
+		}
+	} else if time.Since(startedAt) <= staleAfter {
+		// First cycle hasn't run yet; give it a grace period after startup
+		collection.OK = true
+		collection.Status = "pending"
+	} else {
+		ready = false
+		collection.OK = false
+		collection.Status = "never_succeeded"
+	}
+	checks.Collection = collection
+
+	// Buffered plays are informational: they'll replay once the database is back
+	if writeBuffer != nil {
+		if n, err := writeBuffer.Len(); err == nil {
+			checks.WriteBuffer = &WriteBufferCheck{Pending: n}
+		}
+	}
+
+	status := http.StatusOK
+	statusText := "ready"
+	if !ready {
+		status = http.StatusServiceUnavailable
+		statusText = "not_ready"
+	}
+	c.JSON(status, ReadyzResponse{
+		Status: statusText,
+		Checks: checks,
+	})
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- purge / anonymize history ---------- */
+
+// PurgeHistory deletes or anonymizes the rows of ?table= dated between ?from=
+// and ?to=, e.g. someone else's session played from this account.
+// ?dry_run=true only counts them and returns a confirm token; the real run
+// needs it back as ?confirm=, and is refused if the rows changed meanwhile.
+// DELETE /admin/history?table=recently_played&from=2024-06-01T20:00:00Z&to=2024-06-01T23:30:00Z&dry_run=true
+func PurgeHistory(c *gin.Context) {
+	table := c.DefaultQuery("table", "recently_played")
+	if _, ok := models.HistoryTables[table]; !ok {
+		tables := make([]string, 0, len(models.HistoryTables))
+		for t := range models.HistoryTables {
+			tables = append(tables, t)
+		}
+		sort.Strings(tables)
+		badRequest(c, fmt.Sprintf("invalid 'table' %q (expected %s)", table, strings.Join(tables, " or ")))
+		return
+	}
+	if c.Query("from") == "" || c.Query("to") == "" {
+		badRequest(c, "'from' and 'to' are both required")
+		return
+	}
+	from, err := ParseHistoryBound("from", c.Query("from"), false)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	to, err := ParseHistoryBound("to", c.Query("to"), true)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	if to.Before(from) {
+		badRequest(c, "'to' is before 'from'")
+		return
+	}
+	anonymize := c.Query("anonymize") == "true"
+	action := "delete"
+	if anonymize {
+		action = "anonymize"
+	}
+
+	matched, err := models.GetHistoryRange(repository.Pool, table, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	token := purgeToken(table, action, from, to, matched)
+	resp := PurgeHistoryResponse{
+		Table:   table,
+		Action:  action,
+		From:    from,
+		To:      to,
+		Matched: matched,
+	}
+
+	if c.Query("dry_run") == "true" {
+		resp.DryRun = true
+		resp.Confirm = token
+		resp.Message = fmt.Sprintf("%d rows would be %sd; send the same request without dry_run and with confirm=%s",
+			matched.Rows, action, token)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	switch confirm := c.Query("confirm"); {
+	case confirm == "":
+		badRequest(c, "'confirm' is required; run with dry_run=true first to get it")
+		return
+	case confirm != token:
+		RespondError(c, http.StatusConflict, CodeConflict,
+			"'confirm' doesn't match this range: the parameters or the rows changed since the dry run; run it again",
+			matched)
+		return
+	}
+
+	// The rows are counted again in the transaction that changes them, so
+	// plays collected since the check above can't slip in
+	confirmed := func(r models.HistoryRange) bool {
+		return purgeToken(table, action, from, to, r) == token
+	}
+	if anonymize {
+		resp.Affected, resp.Matched, err = models.AnonymizeHistoryRange(repository.Pool, table, from, to, confirmed)
+	} else {
+		resp.Affected, resp.Matched, err = models.DeleteHistoryRange(repository.Pool, table, from, to, confirmed)
+	}
+	if errors.Is(err, models.ErrHistoryChanged) {
+		RespondError(c, http.StatusConflict, CodeConflict,
+			"the rows changed while being confirmed; run the dry run again", resp.Matched)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	invalidateCollectionStats()
+
+	loc := config.Timezone()
+	fmt.Printf("🧹 %sd %d %s rows from %s to %s\n", action, resp.Affected, table,
+		from.In(loc).Format("2006-01-02 15:04"), to.In(loc).Format("2006-01-02 15:04"))
+	resp.Message = fmt.Sprintf("%d rows %sd", resp.Affected, action)
+	c.JSON(http.StatusOK, resp)
+}
+
+// ParseHistoryBound reads an RFC3339 time or a YYYY-MM-DD day in TIMEZONE;
+// a day as the upper bound includes all of it
+func ParseHistoryBound(name, v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, config.Timezone())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid '%s' (expected RFC3339 or YYYY-MM-DD)", name)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+// purgeToken ties a confirmation to the request and to the rows it matched,
+// so a dry run can't confirm a different range or one that has changed since
+func purgeToken(table, action string, from, to time.Time, r models.HistoryRange) string {
+	key := fmt.Sprintf("%s|%s|%d|%d|%d", table, action, from.UnixNano(), to.UnixNano(), r.Rows)
+	for _, t := range []*time.Time{r.First, r.Last} {
+		if t != nil {
+			key += fmt.Sprintf("|%d", t.UnixNano())
+		}
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+/* ---------- near-duplicate plays ---------- */
+
+type DedupPlaysRequest struct {
+	Window string `json:"window"` // e.g. "10s"; default CRON_DEDUP_WINDOW
+	DryRun bool   `json:"dry_run"`
+}
+
+// DedupPlaysResult is what a dedup_plays job found and did
+type DedupPlaysResult struct {
+	Window     string                 `json:"window"`
+	DryRun     bool                   `json:"dry_run"`
+	Duplicates int                    `json:"duplicates"` // near-duplicate plays found
+	Merged     int                    `json:"merged"`     // of those, deleted after merging
+	Examples   []models.NearDuplicate `json:"examples"`   // the latest ones, newest first
+}
+
+// DedupPlays starts a job that finds stored plays repeating the previous play
+// of the same track within the window (the ones CRON_DEDUP_WINDOW keeps out
+// of new collections) and, unless dry_run, merges each into the earliest play
+// of its run
+// POST /admin/plays/dedup {"window": "10s", "dry_run": true}
+func DedupPlays(c *gin.Context) {
+	var req DedupPlaysRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(c, "invalid body: "+err.Error())
+		return
+	}
+	window := cronConfig.DedupWindow
+	if req.Window != "" {
+		d, err := time.ParseDuration(req.Window)
+		if err != nil {
+			badRequest(c, fmt.Sprintf("invalid 'window' %q (expected a duration like 10s)", req.Window))
+			return
+		}
+		window = d
+	}
+	if window <= 0 || window > config.MaxDedupWindow {
+		badRequest(c, fmt.Sprintf("window must be between 1s and %v, got %v", config.MaxDedupWindow, window))
+		return
+	}
+
+	job := jobs.Start("dedup_plays", req, func(t *jobs.Task) (any, error) {
+		res := DedupPlaysResult{Window: window.String(), DryRun: req.DryRun}
+		var err error
+		res.Examples, res.Duplicates, err = models.FindNearDuplicatePlays(repository.Pool, window, 20)
+		if err != nil || req.DryRun || res.Duplicates == 0 {
+			return res, err
+		}
+		if res.Merged, err = models.MergeNearDuplicatePlays(t.Context(), repository.Pool, window); err != nil {
+			return res, err
+		}
+		invalidateCollectionStats()
+		fmt.Printf("🧹 merged %d near-duplicate plays (window %v)\n", res.Merged, window)
+		return res, nil
+	})
+	c.JSON(http.StatusAccepted, job)
+}
+
+/* ---------- archive old plays ---------- */
+
+// plays moved per transaction by the archive job
+const archiveBatchSize = 5000
+
+type ArchivePlaysRequest struct {
+	OlderThanMonths int  `json:"older_than_months"` // default 12
+	DryRun          bool `json:"dry_run"`
+}
+
+// ArchivePlaysResult is what an archive_plays job found and did
+type ArchivePlaysResult struct {
+	Before   time.Time               `json:"before"` // plays before this were archived
+	DryRun   bool                    `json:"dry_run"`
+	Eligible int                     `json:"eligible"`
+	Archived int                     `json:"archived"`
+	Totals   models.PlayArchiveStats `json:"totals"` // after the run
+}
+
+// ArchivePlays starts a job moving plays older than N whole months (in
+// TIMEZONE) from recently_played to recently_played_archive, keeping the live
+// table small. Stats keep counting archived plays, as the archive inherits
+// recently_played.
+// POST /admin/plays/archive {"older_than_months": 12, "dry_run": true}
+func ArchivePlays(c *gin.Context) {
+	req := ArchivePlaysRequest{OlderThanMonths: 12}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(c, "invalid body: "+err.Error())
+		return
+	}
+	if req.OlderThanMonths < 1 {
+		badRequest(c, "older_than_months must be at least 1")
+		return
+	}
+	now := time.Now().In(config.Timezone())
+	before := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -req.OlderThanMonths, 0)
+
+	job := jobs.Start("archive_plays", req, func(t *jobs.Task) (any, error) {
+		res := ArchivePlaysResult{Before: before, DryRun: req.DryRun}
+		var err error
+		if res.Eligible, err = models.CountPlaysToArchive(repository.Pool, before); err != nil {
+			return res, err
+		}
+		if !req.DryRun && res.Eligible > 0 {
+			res.Archived, err = models.ArchivePlays(t.Context(), repository.Pool, before, archiveBatchSize, func(moved int) {
+				t.Progress(moved, res.Eligible)
+			})
+			if err != nil {
+				return res, err
+			}
+			fmt.Printf("🗄️  archived %d plays from before %s\n", res.Archived, before.Format("2006-01-02"))
+		}
+		res.Totals, err = models.GetPlayArchiveStats(repository.Pool)
+		return res, err
+	})
+	c.JSON(http.StatusAccepted, job)
+}
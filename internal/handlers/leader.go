@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"example.com/spotifydb/internal/metrics"
+	"example.com/spotifydb/internal/repository"
+)
+
+/* ---------- collector leader election ---------- */
+
+// collectorLockKey is the advisory lock key the collecting process holds
+const collectorLockKey int64 = 0x73706f7469667964 // "spotifyd"
+
+// collectorLeading reports whether this process's cron is the one
+// collecting; it stays true when the lock is disabled
+var collectorLeading atomic.Bool
+
+// leaderElection lets only one process collect against a database at a
+// time: each cycle the cron checks it holds the collector lock, taking it
+// over once the leader dies. A nil lock always leads.
+type leaderElection struct {
+	lock  *repository.AdvisoryLock
+	known atomic.Bool // whether leadership has been logged yet
+}
+
+func newLeaderElection(enabled bool, dsn string) *leaderElection {
+	if !enabled || dsn == "" {
+		collectorLeading.Store(true)
+		metrics.CollectorLeader.Set(1)
+		return &leaderElection{}
+	}
+	return &leaderElection{lock: repository.NewAdvisoryLock(dsn, collectorLockKey)}
+}
+
+// lead reports whether this process should collect this cycle
+func (e *leaderElection) lead() bool {
+	if e.lock == nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	acquired, err := e.lock.TryAcquire(ctx)
+	was := collectorLeading.Load()
+	switch {
+	case err != nil && was:
+		// Can't tell while the database is unreachable; keep collecting into
+		// the write buffer rather than lose plays
+		fmt.Printf("⚠️  collector lock: %v; collecting anyway\n", err)
+		return true
+	case err != nil:
+		fmt.Printf("⚠️  collector lock: %v\n", err)
+		return false
+	case acquired && !was:
+		fmt.Println("👑 Took the collector lock: this instance collects")
+	case !acquired && (was || !e.known.Load()):
+		fmt.Println("⏸️  Another instance holds the collector lock: standing by")
+	}
+	e.known.Store(true)
+	collectorLeading.Store(acquired)
+	if acquired {
+		metrics.CollectorLeader.Set(1)
+	} else {
+		metrics.CollectorLeader.Set(0)
+	}
+	return acquired
+}
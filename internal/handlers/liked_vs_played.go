@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- liked vs played ---------- */
+
+const (
+	defaultLikedVsPlayedMinPlays = 10
+	maxLikeTracks                = 500
+)
+
+// GetLikedVsPlayed diffs my library against my history: saved tracks I've
+// never played, and tracks I've played at least ?min_plays= times (default
+// 10) but never saved. ?limit= caps each list (default 50, max 500).
+func GetLikedVsPlayed(c *gin.Context) {
+	minPlays := defaultLikedVsPlayedMinPlays
+	if v := c.Query("min_plays"); v != "" {
+		var err error
+		if minPlays, err = strconv.Atoi(v); err != nil || minPlays < 1 {
+			badRequest(c, "invalid 'min_plays' (expected a positive integer)")
+			return
+		}
+	}
+
+	diff, err := models.GetLikedVsPlayed(repository.Pool, minPlays, parseLimit(c, 50, 500))
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, LikedVsPlayedResponse{*diff})
+}
+
+// LikeTracksRequest picks tracks to save. Without track_ids it takes the
+// played_never_liked list of GET /stats/liked-vs-played for min_plays/limit.
+type LikeTracksRequest struct {
+	TrackIDs []string `json:"track_ids"`
+	MinPlays int      `json:"min_plays"` // default 10
+	Limit    int      `json:"limit"`     // default 50, max 500
+}
+
+// LikePlayedTracks saves tracks to my Spotify library, by default the ones I
+// play a lot but never saved. The saved-tracks collector stores them on its
+// next run.
+// POST /stats/liked-vs-played/like {"min_plays": 20}
+func LikePlayedTracks(c *gin.Context) {
+	var req LikeTracksRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			badRequest(c, "invalid body: "+err.Error())
+			return
+		}
+	}
+	if req.MinPlays == 0 {
+		req.MinPlays = defaultLikedVsPlayedMinPlays
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+	if req.MinPlays < 1 {
+		badRequest(c, "min_plays must be at least 1")
+		return
+	}
+	if req.Limit < 1 || req.Limit > maxLikeTracks || len(req.TrackIDs) > maxLikeTracks {
+		badRequest(c, fmt.Sprintf("limit and track_ids must be between 1 and %d", maxLikeTracks))
+		return
+	}
+
+	trackIDs := req.TrackIDs
+	for _, id := range trackIDs {
+		if !validSpotifyID(id) {
+			badRequest(c, fmt.Sprintf("invalid track id %q", id))
+			return
+		}
+	}
+	if len(trackIDs) == 0 {
+		diff, err := models.GetLikedVsPlayed(repository.Pool, req.MinPlays, req.Limit)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		for _, t := range diff.PlayedNeverLiked {
+			trackIDs = append(trackIDs, t.SpotifySongID)
+		}
+	}
+	if len(trackIDs) == 0 {
+		c.JSON(http.StatusOK, LikeTracksResponse{TrackIDs: []string{}, Message: "nothing to save"})
+		return
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	saved, err := services.SaveTracks(c.Request.Context(), accessTok, trackIDs)
+	if err != nil && saved == 0 {
+		spotifyError(c, err)
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ LikePlayedTracks: %d/%d tracks saved: %v\n", saved, len(trackIDs), err)
+		RespondError(c, http.StatusBadGateway, CodeSpotifyUnavailable, "saving tracks failed part way",
+			gin.H{"saved": saved, "track_ids": trackIDs[:saved], "error": err.Error()})
+		return
+	}
+
+	fmt.Printf("💚 Saved %d played tracks to the library\n", saved)
+	c.JSON(http.StatusOK, LikeTracksResponse{
+		Saved:    saved,
+		TrackIDs: trackIDs,
+		Message:  fmt.Sprintf("Saved %d tracks to your library", saved),
+	})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- like / unlike ---------- */
+
+// likedTrackFrom builds the recently_liked row for a saved track. It reports
+// false for tracks without an artist or cover, which aren't stored.
+func likedTrackFrom(track services.Track, addedAt time.Time) (store.LikedTrack, bool) {
+	if len(track.Artists) == 0 || len(track.Album.Images) == 0 {
+		return store.LikedTrack{}, false
+	}
+	artist := track.Artists[0]
+	album := track.Album
+	image := album.Images[0]
+	return store.LikedTrack{
+		SpotifyID:                 track.ID,
+		TrackName:                 track.Name,
+		TrackPopularity:           strconv.Itoa(track.Popularity),
+		AlbumName:                 album.Name,
+		AlbumType:                 album.AlbumType,
+		AlbumCoverURL:             image.URL,
+		AlbumReleaseDate:          album.ReleaseDate,
+		AlbumReleaseDatePrecision: album.ReleaseDatePrecision,
+		ArtistName:                artist.Name,
+		ArtistID:                  artist.ID,
+		ArtistHref:                artist.Href,
+		ArtistURI:                 artist.URI,
+		AlbumTotalTracks:          album.TotalTracks,
+		AlbumCoverWidth:           image.Width,
+		AlbumCoverHeight:          image.Height,
+		AddedAt:                   addedAt,
+		Metadata:                  track.TrackMetadata,
+	}, true
+}
+
+// LikeTrack saves a track to my Spotify library and stores it in
+// recently_liked right away, restoring it if it had been unliked
+// POST /tracks/:id/like
+func LikeTrack(c *gin.Context) {
+	spotifyID := c.Param("id")
+	if !validSpotifyID(spotifyID) {
+		badRequest(c, "invalid Spotify track ID")
+		return
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	track, err := services.GetFullTrack(c.Request.Context(), accessTok, spotifyID)
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	alreadyLiked, err := models.IsLiked(repository.Pool, spotifyID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if err := services.SaveTrack(c.Request.Context(), accessTok, spotifyID); err != nil {
+		spotifyError(c, err)
+		return
+	}
+
+	now := time.Now()
+	resp := LikeResponse{SpotifySongID: spotifyID, TrackName: track.Name, Liked: true}
+	switch liked, ok := likedTrackFrom(*track, now); {
+	case alreadyLiked:
+		resp.Message = "Already in your library"
+	case !ok:
+		resp.Message = "Saved on Spotify; the track has no artist or cover, so it isn't stored"
+	default:
+		liked.Source = models.SourceAPI
+		if _, err := cronCollector.store.InsertRecentlyLiked(liked); err != nil {
+			RespondError(c, http.StatusInternalServerError, CodeInternal,
+				"saved on Spotify but not stored; the saved-tracks collector will pick it up", err.Error())
+			return
+		}
+		invalidateCollectionStats()
+		resp.AddedAt = &now
+		resp.Message = "Saved to your library"
+	}
+	fmt.Printf("💚 Liked %s (%s)\n", track.Name, spotifyID)
+	c.JSON(http.StatusOK, resp)
+}
+
+// UnlikeTrack removes a track from my Spotify library and marks its
+// recently_liked row unliked, keeping the row
+// DELETE /tracks/:id/like
+func UnlikeTrack(c *gin.Context) {
+	spotifyID := c.Param("id")
+	if !validSpotifyID(spotifyID) {
+		badRequest(c, "invalid Spotify track ID")
+		return
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	if err := services.RemoveSavedTrack(c.Request.Context(), accessTok, spotifyID); err != nil {
+		spotifyError(c, err)
+		return
+	}
+
+	now := time.Now()
+	marked, err := models.MarkUnliked(repository.Pool, spotifyID, now)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, CodeInternal,
+			"removed on Spotify but not marked unliked; the liked_reconcile collector will catch it", err.Error())
+		return
+	}
+
+	resp := LikeResponse{SpotifySongID: spotifyID, Liked: false, Message: "Removed from your library"}
+	if marked {
+		invalidateCollectionStats()
+		resp.UnlikedAt = &now
+	} else {
+		resp.Message = "Removed on Spotify; it wasn't stored as liked"
+	}
+	fmt.Printf("💔 Unliked %s\n", spotifyID)
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/listenbrainz"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+)
+
+/* ---------- ListenBrainz ---------- */
+
+// listenBrainzBatch is how many plays one cycle submits; a backfill uses
+// its own batch size
+const listenBrainzBatch = 500
+
+// listenBrainzUser is the account LISTENBRAINZ_TOKEN belongs to, looked up
+// once; reading listens back needs it
+var listenBrainzUser struct {
+	sync.Mutex
+	name string
+}
+
+func listenBrainzUserName() (string, error) {
+	listenBrainzUser.Lock()
+	defer listenBrainzUser.Unlock()
+	if listenBrainzUser.name == "" {
+		name, err := listenBrainz.ValidateToken()
+		if err != nil {
+			return "", err
+		}
+		listenBrainzUser.name = name
+	}
+	return listenBrainzUser.name, nil
+}
+
+// listenKey identifies a listen the way ListenBrainz dedups them: the
+// second it was played and the track
+type listenKey struct {
+	at    int64
+	track string
+}
+
+func keyOf(at time.Time, track string) listenKey {
+	return listenKey{at.Unix(), strings.ToLower(track)}
+}
+
+// listensBetween returns the recording MSIDs of the listens ListenBrainz has
+// from from to to, inclusive
+func listensBetween(user string, from, to time.Time) (map[listenKey]string, error) {
+	msids := map[listenKey]string{}
+	before := to.Add(time.Second)
+	for {
+		page, err := listenBrainz.GetListens(user, before, listenbrainz.MaxListens)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range page {
+			if l.ListenedAt.Before(from.Truncate(time.Second)) {
+				return msids, nil
+			}
+			msids[keyOf(l.ListenedAt, l.TrackName)] = l.RecordingMSID
+		}
+		if len(page) < listenbrainz.MaxListens {
+			return msids, nil
+		}
+		before = page[len(page)-1].ListenedAt
+	}
+}
+
+// listenBrainzSync is what one submitting pass did
+type listenBrainzSync struct {
+	Submitted      int `json:"submitted"`
+	AlreadyPresent int `json:"already_present"` // found in ListenBrainz, not resubmitted
+	Failed         int `json:"failed"`
+}
+
+// syncListens submits plays ListenBrainz doesn't have yet. Plays already in
+// ListenBrainz (sent before, or by Spotify's own connector) are matched by
+// time and track and just get their recording MSID recorded. A batch
+// ListenBrainz rejects is retried play by play so one bad listen doesn't
+// hold back the rest. Temporary failures are returned with nothing marked.
+func syncListens(user string, plays []models.PendingScrobble) (listenBrainzSync, error) {
+	var result listenBrainzSync
+	if len(plays) == 0 {
+		return result, nil
+	}
+	existing, err := listensBetween(user, plays[0].PlayedAt, plays[len(plays)-1].PlayedAt)
+	if err != nil {
+		return result, err
+	}
+
+	found := map[int]string{}
+	var fresh []models.PendingScrobble
+	for _, p := range plays {
+		if msid, ok := existing[keyOf(p.PlayedAt, p.TrackName)]; ok {
+			found[p.ID] = msid
+			continue
+		}
+		fresh = append(fresh, p)
+	}
+	if err := models.SetListenMSIDs(repository.Pool, found); err != nil {
+		return result, err
+	}
+	result.AlreadyPresent = len(found)
+
+	err = submitListens(fresh)
+	if err == nil {
+		result.Submitted = len(fresh)
+		return result, markListens(fresh, models.ScrobbleSubmitted, "")
+	}
+	var apiErr *listenbrainz.Error
+	if !errors.As(err, &apiErr) || apiErr.Temporary() {
+		return result, err
+	}
+	if len(fresh) == 1 {
+		result.Failed++
+		return result, markListens(fresh, models.ScrobbleFailed, err.Error())
+	}
+
+	fmt.Printf("⚠️  ListenBrainz rejected a batch of %d, submitting one by one: %v\n", len(fresh), err)
+	for _, p := range fresh {
+		one := []models.PendingScrobble{p}
+		if err := submitListens(one); err != nil {
+			if errors.As(err, &apiErr) && apiErr.Temporary() {
+				return result, err
+			}
+			result.Failed++
+			if err := markListens(one, models.ScrobbleFailed, err.Error()); err != nil {
+				return result, err
+			}
+			continue
+		}
+		result.Submitted++
+		if err := markListens(one, models.ScrobbleSubmitted, ""); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func submitListens(plays []models.PendingScrobble) error {
+	if len(plays) == 0 {
+		return nil
+	}
+	listens := make([]listenbrainz.Listen, len(plays))
+	for i, p := range plays {
+		listens[i] = listenbrainz.Listen{
+			ListenedAt:  p.PlayedAt,
+			ArtistName:  p.ArtistName,
+			TrackName:   p.TrackName,
+			ReleaseName: p.AlbumName,
+			DurationMs:  p.DurationMs,
+			SpotifyID:   p.SpotifySongID,
+		}
+	}
+	listenType := listenbrainz.ListenTypeImport
+	if len(listens) == 1 {
+		listenType = listenbrainz.ListenTypeSingle
+	}
+	return listenBrainz.SubmitListens(listenType, listens)
+}
+
+func markListens(plays []models.PendingScrobble, status, reason string) error {
+	ids := make([]int, len(plays))
+	for i, p := range plays {
+		ids[i] = p.ID
+	}
+	return models.SetScrobbleStatus(repository.Pool, models.ScrobbleListenBrainz, ids, status, reason)
+}
+
+// resolveListens looks up the recording MSIDs of submitted plays. Plays
+// ListenBrainz still doesn't list are queued again, so a listen it dropped
+// is resent, until they run out of attempts.
+func resolveListens(user string) (resolved, requeued int, err error) {
+	submitted, err := models.GetPendingScrobbles(repository.Pool, models.ScrobbleListenBrainz,
+		models.ScrobbleSubmitted, scrobbleConfig.MaxAttempts, listenbrainz.MaxListens)
+	if err != nil || len(submitted) == 0 {
+		return 0, 0, err
+	}
+	existing, err := listensBetween(user, submitted[0].PlayedAt, submitted[len(submitted)-1].PlayedAt)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	found := map[int]string{}
+	var missing []int
+	for _, p := range submitted {
+		if msid, ok := existing[keyOf(p.PlayedAt, p.TrackName)]; ok {
+			found[p.ID] = msid
+		} else {
+			missing = append(missing, p.ID)
+		}
+	}
+	if err := models.SetListenMSIDs(repository.Pool, found); err != nil {
+		return 0, 0, err
+	}
+	if err := models.RequeueListens(repository.Pool, missing, scrobbleConfig.MaxAttempts); err != nil {
+		return len(found), 0, err
+	}
+	return len(found), len(missing), nil
+}
+
+// SubmitListens runs once per cycle: it records the MSIDs of the plays
+// submitted last cycle, then submits up to listenBrainzBatch queued plays
+func SubmitListens() {
+	if listenBrainz == nil {
+		return
+	}
+	user, err := listenBrainzUserName()
+	if err != nil {
+		fmt.Println("SubmitListens:", err)
+		return
+	}
+
+	if resolved, requeued, err := resolveListens(user); err != nil {
+		fmt.Println("SubmitListens:", err)
+		return
+	} else if requeued > 0 {
+		fmt.Printf("⚠️  %d submitted listens missing from ListenBrainz, requeued (%d confirmed)\n", requeued, resolved)
+	}
+
+	pending, err := models.GetPendingScrobbles(repository.Pool, models.ScrobbleListenBrainz,
+		models.ScrobblePending, scrobbleConfig.MaxAttempts, listenBrainzBatch)
+	if err != nil {
+		fmt.Println("SubmitListens:", err)
+		return
+	}
+	result, err := syncListens(user, pending)
+	if err != nil {
+		fmt.Println("SubmitListens:", err)
+	}
+	if result.Submitted > 0 || result.Failed > 0 {
+		fmt.Printf("🧠 ListenBrainz: submitted %d listens, %d already there, %d failed\n",
+			result.Submitted, result.AlreadyPresent, result.Failed)
+	}
+}
+
+// listenBrainzBackfillResult is the result of a ListenBrainz backfill job
+type listenBrainzBackfillResult struct {
+	Plays  int  `json:"plays"` // never sent when the job started
+	DryRun bool `json:"dry_run"`
+	listenBrainzSync
+	Resolved int `json:"resolved"` // submitted plays whose MSID was confirmed
+}
+
+// RunListenBrainzBackfill sends every play ListenBrainz hasn't received,
+// oldest first, batchSize (at most listenbrainz.MaxListens) at a time, skipping
+// listens it already has. Rate limits are waited out. Call SetScrobbling with
+// a token first.
+func RunListenBrainzBackfill(t *jobs.Task, batchSize int, dryRun bool) (any, error) {
+	if listenBrainz == nil {
+		return nil, errors.New("LISTENBRAINZ_TOKEN is not set")
+	}
+	batchSize = min(batchSize, listenbrainz.MaxListens)
+	total, err := models.CountUnsentPlays(repository.Pool, models.ScrobbleListenBrainz)
+	if err != nil {
+		return nil, err
+	}
+	result := listenBrainzBackfillResult{Plays: total, DryRun: dryRun}
+	if dryRun || total == 0 {
+		return result, nil
+	}
+	user, err := listenBrainzUserName()
+	if err != nil {
+		return nil, err
+	}
+
+	var afterPlayedAt time.Time
+	afterID, done := 0, 0
+	for !t.Canceled() {
+		plays, err := models.GetUnsentPlays(repository.Pool, models.ScrobbleListenBrainz, afterPlayedAt, afterID, batchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(plays) == 0 {
+			break
+		}
+
+		batch, err := syncListens(user, plays)
+		for attempt := 0; err != nil && attempt < 5; attempt++ {
+			var apiErr *listenbrainz.Error
+			if !errors.As(err, &apiErr) || !apiErr.Temporary() {
+				break
+			}
+			wait := max(apiErr.RetryAfter, 10*time.Second)
+			fmt.Printf("⏳ ListenBrainz: %v, waiting %v\n", err, wait)
+			select {
+			case <-time.After(wait):
+			case <-t.Context().Done():
+				return result, t.Context().Err()
+			}
+			batch, err = syncListens(user, plays)
+		}
+		result.Submitted += batch.Submitted
+		result.AlreadyPresent += batch.AlreadyPresent
+		result.Failed += batch.Failed
+		if err != nil {
+			return result, err
+		}
+
+		last := plays[len(plays)-1]
+		afterPlayedAt, afterID = last.PlayedAt, last.ID
+		done += len(plays)
+		t.Progress(done, total)
+		fmt.Printf("🧠 ListenBrainz backfill: %d/%d plays (%d submitted, %d already there)\n",
+			done, total, result.Submitted, result.AlreadyPresent)
+	}
+	if t.Canceled() {
+		return result, t.Context().Err()
+	}
+
+	// Give ListenBrainz a moment to store the listens, then record their MSIDs
+	if result.Submitted > 0 {
+		time.Sleep(10 * time.Second)
+		for {
+			resolved, requeued, err := resolveListens(user)
+			if err != nil {
+				return result, err
+			}
+			result.Resolved += resolved
+			if resolved+requeued < listenbrainz.MaxListens {
+				break
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- CORS ---------- */
+
+// CORS answers preflight requests and sets Access-Control-Allow-Origin for
+// the allowed origins. An origin of "*" allows any.
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[origin] = true
+	}
+
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		switch {
+		case allowAll:
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+/* ---------- API key auth ---------- */
+
+// RequireAPIKeyForWrites lets reads through and requires the API key for
+// everything else (POST, PATCH, DELETE), including /save-refresh. With no key
+// configured every write is rejected.
+func RequireAPIKeyForWrites(apiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if !validAPIKey(c, apiKey) {
+			RespondError(c, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid API key", nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAPIKey guards admin routes for every method, including GET
+func RequireAPIKey(apiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validAPIKey(c, apiKey) {
+			RespondError(c, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid API key", nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// validAPIKey accepts the key in X-API-Key or as an Authorization bearer token
+func validAPIKey(c *gin.Context, apiKey string) bool {
+	if apiKey == "" {
+		return false
+	}
+	given := c.GetHeader("X-API-Key")
+	if given == "" {
+		if auth := c.GetHeader("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			given = strings.TrimSpace(auth[7:])
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(apiKey)) == 1
+}
+
+/* ---------- abuse protection ---------- */
+
+// LimitBodySize rejects bodies over max bytes with 413 up front when the
+// Content-Length says so, and cuts off longer streamed bodies while reading
+func LimitBodySize(max int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > max {
+			RespondError(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+				fmt.Sprintf("request body is larger than %d bytes", max), nil)
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		}
+		c.Next()
+	}
+}
+
+// OnlyWrites runs limit for POST, PATCH and DELETE and lets reads through, so
+// a stricter rate limit can apply to writes before the API key is checked
+func OnlyWrites(limit gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		limit(c)
+	}
+}
+
+// RequireAllowedIP rejects clients whose IP isn't allowed with 403; it guards
+// the admin routes and /save-refresh
+func RequireAllowedIP(allowed func(ip net.IP) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !allowed(net.ParseIP(c.ClientIP())) {
+			RespondError(c, http.StatusForbidden, CodeForbidden, "not allowed from this address", nil)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/notifications"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- milestones ---------- */
+
+const (
+	// Milestones are only announced when reached this recently, so the first
+	// run, or genres backfilled onto old plays, don't flood the notifications
+	milestoneNotifyWindow = 24 * time.Hour
+	// at most this many are announced per run
+	milestoneNotifyMax = 10
+)
+
+// DetectMilestones records newly reached listening milestones and, with
+// NOTIFY_MILESTONES, announces the recent ones
+func DetectMilestones() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	found, err := models.DetectMilestones(ctx, repository.Pool)
+	if err != nil {
+		fmt.Println("cron:", err)
+		return
+	}
+	recordCollectorSuccess(config.CollectorMilestones)
+	if len(found) == 0 {
+		return
+	}
+	fmt.Printf("🏆 %d new milestones\n", len(found))
+
+	if !notifyConfig.Milestones {
+		return
+	}
+	announced := 0
+	for _, m := range found {
+		if time.Since(m.AchievedAt) > milestoneNotifyWindow || announced == milestoneNotifyMax {
+			continue
+		}
+		notify(notifications.KindMilestone, "🏆 "+m.Title, m)
+		announced++
+	}
+}
+
+// GetMilestones lists recorded milestones newest first, filtered by ?kind=
+func GetMilestones(c *gin.Context) {
+	kind := c.Query("kind")
+	if kind != "" && !isMilestoneKind(kind) {
+		badRequest(c, fmt.Sprintf("invalid 'kind' %q (expected %s)", kind, strings.Join(models.MilestoneKinds, ", ")))
+		return
+	}
+	limit := parseLimit(c, 50, 500)
+
+	milestones, err := models.GetMilestones(repository.Pool, kind, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MilestonesResponse{
+		Milestones: milestones,
+		Count:      len(milestones),
+	})
+}
+
+func isMilestoneKind(kind string) bool {
+	for _, k := range models.MilestoneKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/musicbrainz"
+	"example.com/spotifydb/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- MusicBrainz enrichment ---------- */
+
+// defaultMusicBrainzUserAgent identifies us to MusicBrainz, which asks every
+// client for a name and a contact; MUSICBRAINZ_USER_AGENT overrides it
+const defaultMusicBrainzUserAgent = "spotifydb/1.0 ( https://github.com/tejedamiguel6/spotify-db-GO )"
+
+// musicBrainzRetryAfter is how long an ISRC MusicBrainz didn't know waits
+// before it's looked up again
+const musicBrainzRetryAfter = 30 * 24 * time.Hour
+
+var musicBrainzClient = sync.OnceValue(func() *musicbrainz.Client {
+	ua := os.Getenv("MUSICBRAINZ_USER_AGENT")
+	if ua == "" {
+		ua = defaultMusicBrainzUserAgent
+	}
+	return musicbrainz.New(ua)
+})
+
+// lookupMetadata resolves one ISRC: the earliest released recording gives the
+// artists and original release year, its first release the label
+func lookupMetadata(isrc string) (models.ExternalMetadata, error) {
+	meta := models.ExternalMetadata{ISRC: isrc}
+	mb := musicBrainzClient()
+	recordings, err := mb.LookupISRC(isrc)
+	if errors.Is(err, musicbrainz.ErrNotFound) || (err == nil && len(recordings) == 0) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+
+	rec := recordings[0]
+	meta.Found = true
+	meta.RecordingMBID = rec.ID
+	meta.ReleaseYear = musicbrainz.Year(rec.FirstReleaseDate)
+	for _, a := range rec.Artists {
+		meta.ArtistMBIDs = append(meta.ArtistMBIDs, a.ID)
+		meta.ArtistNames = append(meta.ArtistNames, a.Name)
+	}
+
+	release, ok := rec.FirstRelease()
+	if !ok {
+		return meta, nil
+	}
+	meta.ReleaseMBID = release.ID
+	meta.ReleaseTitle = release.Title
+	if meta.ReleaseYear == 0 {
+		meta.ReleaseYear = musicbrainz.Year(release.Date)
+	}
+	labels, err := mb.ReleaseLabels(release.ID)
+	if err != nil && !errors.Is(err, musicbrainz.ErrNotFound) {
+		return meta, err
+	}
+	if len(labels) > 0 {
+		meta.Label = labels[0].Name
+		meta.LabelMBID = labels[0].ID
+	}
+	return meta, nil
+}
+
+// EnrichFromMusicBrainz looks up to limit played ISRCs on MusicBrainz and
+// stores what it knows about them, stopping early when canceled or when
+// MusicBrainz is unavailable. It returns how many ISRCs were looked up.
+func EnrichFromMusicBrainz(limit int, canceled func() bool) (int, error) {
+	isrcs, err := models.GetISRCsMissingMetadata(repository.Pool, limit, time.Now().Add(-musicBrainzRetryAfter))
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, isrc := range isrcs {
+		if canceled() {
+			break
+		}
+		meta, err := lookupMetadata(isrc)
+		if err != nil {
+			var mbErr *musicbrainz.Error
+			if errors.As(err, &mbErr) && mbErr.Temporary() {
+				return done, err
+			}
+			// Anything else is about this ISRC; record it as unknown so it
+			// doesn't block the queue, and retry it with the not-found ones
+			fmt.Printf("⚠️  MusicBrainz lookup of %s failed: %v\n", isrc, err)
+			meta = models.ExternalMetadata{ISRC: isrc}
+		}
+		if err := models.SaveExternalMetadata(repository.Pool, meta); err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+// RefreshExternalMetadata is the cron's MusicBrainz collector
+func RefreshExternalMetadata(batchSize int) {
+	done, err := EnrichFromMusicBrainz(batchSize, func() bool { return false })
+	if err != nil {
+		fmt.Println("RefreshExternalMetadata:", err)
+	}
+	if done > 0 {
+		fmt.Printf("🎼 looked up %d ISRCs on MusicBrainz\n", done)
+	}
+}
+
+func backfillMusicBrainz(t *jobs.Task, batchSize int, dryRun bool) (any, error) {
+	pending, err := models.CountISRCsMissingMetadata(repository.Pool)
+	if err != nil {
+		return nil, err
+	}
+	res := backfillResult{Pending: pending, BatchSize: batchSize, DryRun: dryRun}
+	if dryRun || pending == 0 {
+		return res, nil
+	}
+	res.Updated, err = EnrichFromMusicBrainz(batchSize, t.Canceled)
+	return res, err
+}
+
+/* ---------- release decades & labels ---------- */
+
+// GetPlaysByDecade breaks plays down by the decade each recording was first
+// released, per MusicBrainz
+// GET /stats/decades?period=all
+func GetPlaysByDecade(c *gin.Context) {
+	from, to, label, err := parsePeriod(c, "all")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	decades, err := models.GetPlaysByDecade(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	coverage, err := models.GetMetadataCoverage(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, DecadesResponse{
+		Period:   label,
+		From:     from,
+		To:       to,
+		Decades:  decades,
+		Coverage: coverage,
+	})
+}
+
+// GetPlaysByLabel returns the record labels I listen to most, per MusicBrainz
+// GET /stats/labels?period=all&limit=20
+func GetPlaysByLabel(c *gin.Context) {
+	from, to, label, err := parsePeriod(c, "all")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	labels, err := models.GetPlaysByLabel(repository.Pool, from, to, parseLimit(c, 20, 100))
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	coverage, err := models.GetMetadataCoverage(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, LabelsResponse{
+		Period:   label,
+		From:     from,
+		To:       to,
+		Labels:   labels,
+		Count:    len(labels),
+		Coverage: coverage,
+	})
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- generate playlists from my data ---------- */
+
+// GeneratePlaylistRequest picks the tracks for a new playlist. Source "top"
+// takes the most played tracks for period/month; "liked" takes saved tracks,
+// newest first, optionally narrowed by genre and artist.
+type GeneratePlaylistRequest struct {
+	Source      string `json:"source" binding:"required"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+	Limit       int    `json:"limit"`
+
+	Period string `json:"period"` // top: day, week, month, year, all
+	Month  string `json:"month"`  // top: YYYY-MM, overrides period
+	Genre  string `json:"genre"`  // liked
+	Artist string `json:"artist"` // liked
+}
+
+const (
+	defaultPlaylistTracks = 50
+	maxPlaylistTracks     = 1000
+)
+
+// GeneratePlaylist creates a playlist on my Spotify account from a query over
+// my history and adds its tracks in batches of 100
+// POST /playlists/generate {"source": "top", "period": "month", "limit": 50}
+func GeneratePlaylist(c *gin.Context) {
+	var req GeneratePlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, "invalid body: "+err.Error())
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultPlaylistTracks
+	}
+	if req.Limit < 1 || req.Limit > maxPlaylistTracks {
+		badRequest(c, fmt.Sprintf("limit must be between 1 and %d", maxPlaylistTracks))
+		return
+	}
+
+	var trackIDs []string
+	var defaultName string
+	switch req.Source {
+	case "top":
+		if req.Period == "" {
+			req.Period = "month"
+		}
+		from, to, label, err := periodWindow(req.Period, req.Month)
+		if err != nil {
+			badRequest(c, err.Error())
+			return
+		}
+		tracks, err := models.GetMostPlayedFromHistory(repository.Pool, from, to, req.Limit, false, "", cronConfig.DedupWindow)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		for _, t := range tracks {
+			trackIDs = append(trackIDs, t.SpotifySongID)
+		}
+		defaultName = fmt.Sprintf("Top %d: %s", req.Limit, label)
+
+	case "liked":
+		tracks, _, err := models.ListRecentlyLiked(repository.Pool, models.LikedQuery{
+			Limit:  req.Limit,
+			Sort:   "added_at",
+			Genre:  req.Genre,
+			Artist: req.Artist,
+		})
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		for _, t := range tracks {
+			trackIDs = append(trackIDs, t.SpotifyID)
+		}
+		defaultName = "Liked"
+		if req.Genre != "" {
+			defaultName += " " + req.Genre
+		}
+		if req.Artist != "" {
+			defaultName += " by " + req.Artist
+		}
+
+	default:
+		badRequest(c, fmt.Sprintf("unknown source %q (expected top or liked)", req.Source))
+		return
+	}
+
+	if len(trackIDs) == 0 {
+		notFound(c, "the query matched no tracks; no playlist was created")
+		return
+	}
+	if req.Name == "" {
+		req.Name = defaultName + " (" + config.Today().Format("2006-01-02") + ")"
+	}
+	if req.Description == "" {
+		req.Description = "Generated by spotify-db"
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	userID, err := services.GetCurrentUserID(c.Request.Context(), accessTok)
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	playlist, err := services.CreatePlaylist(c.Request.Context(), accessTok, userID, req.Name, req.Description, req.Public)
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+
+	added, err := services.AddTracksToPlaylist(c.Request.Context(), accessTok, playlist.ID, trackIDs)
+	if err != nil {
+		// The playlist exists now; report what made it in rather than hiding it
+		fmt.Printf("❌ GeneratePlaylist: %d/%d tracks added to %s: %v\n", added, len(trackIDs), playlist.ID, err)
+		RespondError(c, http.StatusBadGateway, CodeSpotifyUnavailable, "playlist created but adding tracks failed",
+			gin.H{"playlist_id": playlist.ID, "url": playlist.ExternalURLs.Spotify, "added": added, "error": err.Error()})
+		return
+	}
+
+	fmt.Printf("📝 Created playlist %q with %d tracks\n", playlist.Name, added)
+	c.JSON(http.StatusCreated, GeneratedPlaylistResponse{
+		PlaylistID: playlist.ID,
+		Name:       playlist.Name,
+		URI:        playlist.URI,
+		URL:        playlist.ExternalURLs.Spotify,
+		Source:     req.Source,
+		TrackCount: added,
+	})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- queue capture ---------- */
+
+// CaptureQueue records what's queued in the player to queue_log, so what
+// was queued can later be compared with what played. Podcast episodes are
+// left out.
+func CaptureQueue() (run CollectorRun) {
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+
+	var queue *services.Queue
+	err = cronRateLimiter.RetryWithBackoff(func() error {
+		queue, err = services.GetQueue(context.Background(), accessTok)
+		return err
+	}, 1)
+	if err != nil {
+		fmt.Println("cron: queue error:", err)
+		run.Error = err.Error()
+		return run
+	}
+	recordCollectorSuccess(config.CollectorQueue)
+	if queue.CurrentlyPlaying == nil {
+		return run // nothing playing, so nothing will play from the queue
+	}
+
+	var queued []models.QueuedTrack
+	for i, item := range queue.Queue {
+		if item.Type == "episode" || item.ID == "" {
+			continue
+		}
+		artist := ""
+		if len(item.Artists) > 0 {
+			artist = item.Artists[0].Name
+		}
+		queued = append(queued, models.QueuedTrack{
+			SpotifySongID: item.ID,
+			TrackName:     item.Name,
+			ArtistName:    artist,
+			Position:      i + 1,
+		})
+	}
+	run.Fetched = len(queued)
+
+	added, err := models.RecordQueue(repository.Pool, queue.CurrentlyPlaying.ID, queued, time.Now().UTC())
+	if err != nil {
+		fmt.Println("cron:", err)
+		run.Error = err.Error()
+		return run
+	}
+	run.Inserted = added
+	run.Skipped = len(queued) - added
+	return run
+}
+
+// GetQueueDropoff returns how many queued tracks never played, and which.
+// A queued track counts as played when it plays (even briefly) before it's
+// been out of the queue for an hour.
+// GET /stats/queue-dropoff?period=month&limit=20
+func GetQueueDropoff(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "month")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	limit := parseLimit(c, 20, 200)
+
+	summary, err := models.GetQueueDropoffSummary(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	tracks, err := models.GetQueueDropoffTracks(repository.Pool, from, to, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, QueueDropoffResponse{
+		Period:              period,
+		From:                from,
+		To:                  to,
+		QueueDropoffSummary: summary,
+		Tracks:              tracks,
+	})
+}
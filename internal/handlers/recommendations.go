@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- recommendations (seeded from my plays) ---------- */
+
+// recommendationTargets are the tunable attributes accepted as ?target_<name>=
+var recommendationTargets = []string{"energy", "valence"}
+
+// GetRecommendations asks Spotify for tracks like my top tracks, artists and
+// genres of the last ?days= (default 28), optionally steered with
+// ?target_energy= and ?target_valence= (0-1). Explicit ?seed_tracks=,
+// ?seed_artists= and ?seed_genres= (comma-separated) replace the automatic
+// seeds. Each result says whether I've already played it.
+func GetRecommendations(c *gin.Context) {
+	limit := parseLimit(c, 20, 100)
+
+	days := 28
+	if v := c.Query("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 365 {
+			badRequest(c, "invalid 'days' (expected 1-365)")
+			return
+		}
+		days = parsed
+	}
+
+	targets := map[string]float64{}
+	for _, attr := range recommendationTargets {
+		v := c.Query("target_" + attr)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			badRequest(c, fmt.Sprintf("invalid 'target_%s' (expected 0-1)", attr))
+			return
+		}
+		targets[attr] = parsed
+	}
+
+	seeds := explicitSeeds(c)
+	if len(seeds.Tracks)+len(seeds.Artists)+len(seeds.Genres) == 0 {
+		since := time.Now().AddDate(0, 0, -days)
+		var err error
+		if seeds, err = topSeeds(&since); err != nil {
+			internalError(c, err)
+			return
+		}
+	}
+	n := len(seeds.Tracks) + len(seeds.Artists) + len(seeds.Genres)
+	if n == 0 {
+		notFound(c, fmt.Sprintf("no plays in the last %d days to seed recommendations from", days))
+		return
+	}
+	if n > 5 {
+		badRequest(c, fmt.Sprintf("at most 5 seeds in total, got %d", n))
+		return
+	}
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	tracks, err := services.GetRecommendations(c.Request.Context(), accessTok, services.RecommendationSeeds{
+		Tracks:  seedValues(seeds.Tracks, true),
+		Artists: seedValues(seeds.Artists, true),
+		Genres:  seedValues(seeds.Genres, false),
+		Targets: targets,
+	}, limit)
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+
+	ids := make([]string, 0, len(tracks))
+	for _, t := range tracks {
+		ids = append(ids, t.ID)
+	}
+	history, err := models.GetTrackHistory(repository.Pool, ids)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	results := make([]RecommendedTrack, 0, len(tracks))
+	unplayed := 0
+	for _, t := range tracks {
+		rt := RecommendedTrack{SearchTrack: SearchTrack{
+			ID:           t.ID,
+			Name:         t.Name,
+			Artists:      []string{},
+			AlbumName:    t.Album.Name,
+			TrackHistory: history[t.ID],
+		}}
+		for _, a := range t.Artists {
+			rt.Artists = append(rt.Artists, a.Name)
+		}
+		if len(t.Album.Images) > 0 {
+			rt.AlbumCoverURL = t.Album.Images[0].URL
+		}
+		rt.AlreadyPlayed = rt.PlayCount > 0
+		if !rt.AlreadyPlayed {
+			unplayed++
+		}
+		results = append(results, rt)
+	}
+
+	c.JSON(http.StatusOK, RecommendationsResponse{
+		Seeds:    seeds,
+		Targets:  targets,
+		Tracks:   results,
+		Count:    len(results),
+		Unplayed: unplayed,
+	})
+}
+
+// explicitSeeds reads ?seed_tracks=, ?seed_artists= and ?seed_genres=
+func explicitSeeds(c *gin.Context) RecommendationSeeds {
+	split := func(param string, byID bool) []models.DiscoverySeed {
+		seeds := []models.DiscoverySeed{}
+		for _, v := range strings.Split(c.Query(param), ",") {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if byID {
+				seeds = append(seeds, models.DiscoverySeed{ID: v})
+			} else {
+				seeds = append(seeds, models.DiscoverySeed{Name: v})
+			}
+		}
+		return seeds
+	}
+	return RecommendationSeeds{
+		Tracks:  split("seed_tracks", true),
+		Artists: split("seed_artists", true),
+		Genres:  split("seed_genres", false),
+	}
+}
+
+// topSeeds picks five seeds from my plays since the given time: two tracks,
+// two artists and a genre, topping up from the other kinds when one is short
+func topSeeds(since *time.Time) (RecommendationSeeds, error) {
+	tracks, err := models.GetTopTrackSeeds(repository.Pool, since, 5)
+	if err != nil {
+		return RecommendationSeeds{}, err
+	}
+	artists, err := models.GetTopArtistSeeds(repository.Pool, since, 5)
+	if err != nil {
+		return RecommendationSeeds{}, err
+	}
+	genres, err := models.GetTopGenreSeeds(repository.Pool, since, 5)
+	if err != nil {
+		return RecommendationSeeds{}, err
+	}
+
+	pools := [][]models.DiscoverySeed{tracks, artists, genres}
+	picked := make([][]models.DiscoverySeed, 3)
+	remaining := 5
+	for i, quota := range []int{2, 2, 1} {
+		take := min(quota, len(pools[i]))
+		picked[i], pools[i] = append([]models.DiscoverySeed{}, pools[i][:take]...), pools[i][take:]
+		remaining -= take
+	}
+	for i := range pools {
+		take := min(remaining, len(pools[i]))
+		picked[i] = append(picked[i], pools[i][:take]...)
+		remaining -= take
+	}
+
+	return RecommendationSeeds{Tracks: picked[0], Artists: picked[1], Genres: picked[2]}, nil
+}
+
+func seedValues(seeds []models.DiscoverySeed, byID bool) []string {
+	values := make([]string, 0, len(seeds))
+	for _, s := range seeds {
+		if byID {
+			values = append(values, s.ID)
+		} else {
+			values = append(values, s.Name)
+		}
+	}
+	return values
+}
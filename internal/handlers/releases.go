@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- release radar ---------- */
+
+// releaseRadarPage is how many of an artist's albums and singles are read
+// per check; Spotify lists the newest first within each group
+const releaseRadarPage = 50
+
+// RefreshReleaseRadar checks the albums and singles of my top artists whose
+// releases haven't been looked at in the last after, storing any new ones in
+// artist_releases
+func RefreshReleaseRadar(topArtists int, after time.Duration) {
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		fmt.Println("RefreshReleaseRadar:", err)
+		return
+	}
+	artists, err := models.GetReleaseRadarArtists(repository.Pool, topArtists, time.Now().Add(-after))
+	if err != nil {
+		fmt.Println("RefreshReleaseRadar:", err)
+		return
+	}
+
+	checked, found := 0, 0
+	for _, artist := range artists {
+		var albums []services.Album
+		err := cronRateLimiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			albums, fetchErr = services.GetArtistAlbums(context.Background(), accessTok, artist.ID, releaseRadarPage)
+			return fetchErr
+		}, 1)
+		if err != nil && !errors.Is(err, services.ErrNotFound) {
+			// The rest are checked on a later run
+			fmt.Printf("RefreshReleaseRadar: %s: %v\n", artist.Name, err)
+			break
+		}
+
+		releases := make([]models.ArtistRelease, 0, len(albums))
+		for _, a := range albums {
+			if a.ID == "" {
+				continue
+			}
+			r := models.ArtistRelease{
+				AlbumID:              a.ID,
+				ArtistID:             artist.ID,
+				ArtistName:           artist.Name,
+				Name:                 a.Name,
+				AlbumType:            a.AlbumType,
+				ReleaseDate:          a.ReleaseDate,
+				ReleaseDatePrecision: a.ReleaseDatePrecision,
+				TotalTracks:          a.TotalTracks,
+			}
+			if len(a.Images) > 0 {
+				r.ImageURL = a.Images[0].URL
+			}
+			releases = append(releases, r)
+		}
+		saved, err := models.SaveArtistReleases(repository.Pool, artist.ID, releases)
+		if err != nil {
+			fmt.Println("RefreshReleaseRadar:", err)
+			return
+		}
+		checked++
+		found += saved
+	}
+	if checked > 0 {
+		recordCollectorSuccess(config.CollectorReleaseRadar)
+		fmt.Printf("📻 release radar: checked %d artists, %d new releases\n", checked, found)
+	}
+}
+
+// GetNewReleases lists albums and singles by artists I play that I haven't
+// played yet, newest first.
+// ?days= released in the last N days (default 90, max 3650),
+// ?type=album|single|all (default all), ?limit= (default 50, max 200)
+func GetNewReleases(c *gin.Context) {
+	days := 90
+	if v := c.Query("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 3650 {
+			badRequest(c, "invalid 'days' (expected 1-3650)")
+			return
+		}
+		days = parsed
+	}
+	albumType := c.DefaultQuery("type", "all")
+	switch albumType {
+	case "all":
+		albumType = ""
+	case "album", "single":
+	default:
+		badRequest(c, fmt.Sprintf("invalid 'type' %q (expected album, single or all)", albumType))
+		return
+	}
+
+	q := models.ReleaseQuery{
+		Since:     config.Today().AddDate(0, 0, -(days - 1)),
+		AlbumType: albumType,
+		Limit:     parseLimit(c, 50, 200),
+	}
+	releases, err := models.GetNewReleases(repository.Pool, q)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, NewReleasesResponse{
+		Since:    q.Since.Format("2006-01-02"),
+		Releases: releases,
+		Count:    len(releases),
+	})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/notifications"
+	"example.com/spotifydb/internal/reports"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lastReportDate is the most recent day the cron finished a report for,
+// so the check doesn't hit the database on every cycle
+var lastReportDate string
+
+/* ---------- daily report ---------- */
+
+// GetDailyReport returns the stored report for ?date= (default: yesterday),
+// generating it on demand for past days that haven't been reported yet.
+func GetDailyReport(c *gin.Context) {
+	today := config.Today()
+	day := today.AddDate(0, 0, -1)
+	if v := c.Query("date"); v != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", v, config.Timezone())
+		if err != nil {
+			badRequest(c, fmt.Sprintf("invalid 'date': %v", err))
+			return
+		}
+		day = parsed
+	}
+
+	report, err := reports.GetDaily(day)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	if report == nil {
+		report, err = reports.GenerateDaily(day)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		// Only persist complete days; today's numbers are still changing
+		if day.Before(today) {
+			if err := reports.SaveDaily(report); err != nil {
+				fmt.Println("GetDailyReport:", err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// RunDailyReport generates (and optionally pushes) yesterday's report once the
+// configured report hour has passed. Safe to call every cycle.
+func RunDailyReport() {
+	now := time.Now().In(config.Timezone())
+	if now.Hour() < cronConfig.ReportHour {
+		return
+	}
+
+	yesterday := config.Today().AddDate(0, 0, -1)
+	date := yesterday.Format("2006-01-02")
+	if lastReportDate == date {
+		return
+	}
+
+	webhookURL := reports.WebhookURL()
+	push := webhookURL != "" || (notifyConfig.DailyReport && notifier.Enabled())
+	existing, err := reports.GetDaily(yesterday)
+	if err != nil {
+		fmt.Println("RunDailyReport:", err)
+		return
+	}
+	if existing != nil && !push {
+		lastReportDate = date
+		return
+	}
+	if existing != nil {
+		delivered, err := reports.IsDelivered(yesterday)
+		if err != nil {
+			fmt.Println("RunDailyReport:", err)
+			return
+		}
+		if delivered {
+			lastReportDate = date
+			return
+		}
+	}
+
+	report := existing
+	if report == nil {
+		report, err = reports.GenerateDaily(yesterday)
+		if err != nil {
+			fmt.Println("RunDailyReport:", err)
+			return
+		}
+		if err := reports.SaveDaily(report); err != nil {
+			fmt.Println("RunDailyReport:", err)
+			return
+		}
+		fmt.Printf("📰 generated daily report for %s (%d plays)\n", date, report.TracksPlayed)
+	}
+
+	if push {
+		if err := pushDailyReport(webhookURL, report); err != nil {
+			// Leave lastReportDate unset so the next cycle retries delivery
+			fmt.Println("RunDailyReport:", err)
+			return
+		}
+		if err := reports.MarkDelivered(date); err != nil {
+			fmt.Println("RunDailyReport:", err)
+		}
+		fmt.Printf("📬 pushed daily report for %s\n", date)
+	}
+	lastReportDate = date
+}
+
+// pushDailyReport sends the report to REPORT_WEBHOOK_URL and, with
+// NOTIFY_DAILY_REPORT, to the notification senders. Delivery is retried
+// next cycle unless both succeed.
+func pushDailyReport(webhookURL string, report *reports.DailyReport) error {
+	if webhookURL != "" {
+		if err := reports.Push(webhookURL, report); err != nil {
+			return err
+		}
+	}
+	if notifyConfig.DailyReport {
+		return notifier.Notify(notifications.KindDailyReport, report.Summary(), report)
+	}
+	return nil
+}
+
+/* ---------- wrapped ---------- */
+
+// GetWrapped summarizes ?period=YYYY, YYYY-MM or YYYY-Www (default: this month)
+func GetWrapped(c *gin.Context) {
+	period := c.DefaultQuery("period", time.Now().In(config.Timezone()).Format("2006-01"))
+	from, to, err := reports.ParseWrappedPeriod(period)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	wrapped, err := reports.GenerateWrapped(period, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, wrapped)
+}
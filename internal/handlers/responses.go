@@ -0,0 +1,671 @@
+package handlers
+
+import (
+	"time"
+
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/webhooks"
+)
+
+// Response bodies for every JSON endpoint. The OpenAPI spec is generated from
+// these types, so a field added here shows up in the frontend's client too.
+
+/* ---------- errors ---------- */
+
+// ErrorResponse wraps every error body
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+/* ---------- health ---------- */
+
+type HealthzResponse struct {
+	Status        string `json:"status"`
+	UptimeSeconds int    `json:"uptime_seconds,omitempty"`
+	Database      string `json:"database,omitempty"` // set when unhealthy
+}
+
+type ReadyzResponse struct {
+	Status string       `json:"status"` // ready or not_ready
+	Checks ReadyzChecks `json:"checks"`
+}
+
+type ReadyzChecks struct {
+	Database     DependencyCheck   `json:"database"`
+	RefreshToken DependencyCheck   `json:"refresh_token"`
+	Collection   CollectionCheck   `json:"collection"`
+	WriteBuffer  *WriteBufferCheck `json:"write_buffer,omitempty"`
+}
+
+type DependencyCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type CollectionCheck struct {
+	OK          bool       `json:"ok"`
+	Status      string     `json:"status"` // ok, stale, pending, disabled, never_succeeded, not_collecting or standby
+	StaleAfter  string     `json:"stale_after"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	AgeSeconds  *int       `json:"age_seconds,omitempty"`
+}
+
+type WriteBufferCheck struct {
+	Pending int `json:"pending"`
+}
+
+/* ---------- tracks ---------- */
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type SaveRefreshResponse struct {
+	Msg     string                     `json:"msg"`
+	Account *repository.SpotifyAccount `json:"account,omitempty"`
+	Warning string                     `json:"warning,omitempty"`
+}
+
+type RecentlyPlayedResponse struct {
+	Tracks  []models.RecentlyPlayedTrack `json:"tracks"`
+	Count   int                          `json:"count"`
+	Message string                       `json:"message"`
+}
+
+// NowPlayingResponse is {"is_playing": false} when nothing is playing; the
+// other fields are only set when the player has a track (is_playing is false
+// while it's paused)
+type NowPlayingResponse struct {
+	IsPlaying    bool                      `json:"is_playing"`
+	Track        *NowPlayingTrack          `json:"track,omitempty"`
+	ProgressMs   int                       `json:"progress_ms,omitempty"`
+	DurationMs   int                       `json:"duration_ms,omitempty"`
+	Device       *services.Device          `json:"device,omitempty"`
+	Context      *services.PlaybackContext `json:"context,omitempty"`
+	ShuffleState bool                      `json:"shuffle_state,omitempty"`
+	RepeatState  string                    `json:"repeat_state,omitempty"`
+
+	Data    *services.CurrentlyPlaying `json:"data,omitempty"` // Spotify's player object as returned
+	Message string                     `json:"message,omitempty"`
+}
+
+type NowPlayingTrack struct {
+	SpotifySongID string   `json:"spotify_song_id"`
+	TrackName     string   `json:"track_name"`
+	Artists       []string `json:"artists"`
+	AlbumName     string   `json:"album_name"`
+	AlbumCoverURL string   `json:"album_cover_url,omitempty"`
+}
+
+type RecentlyLikedResponse struct {
+	Data    []models.RecentlyLikedTracks `json:"data"`
+	Count   int                          `json:"count"`
+	Total   int                          `json:"total"`
+	Limit   int                          `json:"limit"`
+	Offset  int                          `json:"offset"`
+	HasMore bool                         `json:"has_more"`
+	Message string                       `json:"message"`
+}
+
+type GenreArtistsResponse struct {
+	Genre   string                   `json:"genre"`
+	Artists []repository.GenreArtist `json:"artists"`
+	Count   int                      `json:"count"`
+	Message string                   `json:"message"`
+}
+
+type TrackStreakResponse struct {
+	SongID        string                  `json:"song_id"`
+	TrackName     string                  `json:"track_name"`
+	ArtistName    string                  `json:"artist_name"`
+	LongestStreak *repository.TrackStreak `json:"longest_streak"`
+	CurrentStreak *repository.TrackStreak `json:"current_streak"`
+}
+
+type TrackStatsResponse struct {
+	SongID      string    `json:"song_id"`
+	TrackName   string    `json:"track_name"`
+	ArtistName  string    `json:"artist_name"`
+	PlayCount   int       `json:"play_count"`
+	TotalMs     int64     `json:"total_ms"`
+	Formatted   string    `json:"formatted"`
+	FirstListen time.Time `json:"first_listen"`
+	LastListen  time.Time `json:"last_listen"`
+}
+
+type TrackDailyResponse struct {
+	SongID     string                 `json:"song_id"`
+	TrackName  string                 `json:"track_name"`
+	ArtistName string                 `json:"artist_name"`
+	Days       []repository.DailyPlay `json:"days"`
+}
+
+type RankedTrack struct {
+	Rank          int    `json:"rank"`
+	SongID        string `json:"song_id"`
+	TrackName     string `json:"track_name"`
+	ArtistName    string `json:"artist_name"`
+	AlbumName     string `json:"album_name"`
+	AlbumCoverURL string `json:"album_cover_url"`
+	PlayCount     int    `json:"play_count"`
+	TotalMs       int64  `json:"total_ms"`
+	Formatted     string `json:"formatted"`
+}
+
+type TopTracksResponse struct {
+	From   string        `json:"from"` // YYYY-MM-DD, empty when open-ended
+	To     string        `json:"to"`
+	Tracks []RankedTrack `json:"tracks"`
+	Count  int           `json:"count"`
+}
+
+// CollectorRun is what one run of a collector did. Skipped counts tracks
+// already stored and ones too incomplete to store.
+type CollectorRun struct {
+	Fetched  int    `json:"fetched"`
+	Inserted int    `json:"inserted"`
+	Skipped  int    `json:"skipped"`
+	Buffered int    `json:"buffered,omitempty"` // written to the disk buffer while Postgres was down
+	Error    string `json:"error,omitempty"`
+}
+
+type CollectResponse struct {
+	RecentlyPlayed CollectorRun `json:"recently_played"`
+	SavedTracks    CollectorRun `json:"saved_tracks"`
+	APICalls       int64        `json:"api_calls"`
+	DurationMs     int64        `json:"duration_ms"`
+	Message        string       `json:"message"`
+}
+
+type BackfillDurationResponse struct {
+	Message string `json:"message"`
+	Updated int    `json:"updated"`
+}
+
+/* ---------- collection & listening time ---------- */
+
+type CollectionSummary struct {
+	TotalTracksCollected  int    `json:"total_tracks_collected"`
+	LatestTrackTime       string `json:"latest_track_time"`
+	ProgressToward6Months string `json:"progress_toward_6_months"`
+}
+
+type CollectionStatsResponse struct {
+	CollectionSummary    CollectionSummary       `json:"collection_summary"`
+	TrackCountsByPeriod  map[string]int          `json:"track_counts_by_period"`
+	DailyBreakdownLast30 []repository.DailyCount `json:"daily_breakdown_last_30_days"`
+	CollectionTips       []string                `json:"collection_tips"`
+	Gaps                 CollectionGapSummary    `json:"collection_gaps"`
+	Health               CollectorHealthSummary  `json:"collector_health"`
+	CacheAge             int                     `json:"cache_age"` // seconds since the stats were computed
+}
+
+// CollectionGapSummary is the gap part of /collection-stats; Open are the
+// gaps still running
+type CollectionGapSummary struct {
+	Last30Days int                    `json:"last_30_days"`
+	Open       []models.CollectionGap `json:"open"`
+}
+
+// CollectorHealthSummary is the collector part of /collection-stats, from
+// the runs the cron records. API calls and 429s are the collectors' own.
+type CollectorHealthSummary struct {
+	Collectors         []models.CollectorHealth `json:"collectors"`
+	APICallsLastHour   int                      `json:"api_calls_last_hour"`
+	RateLimitedLast24h int                      `json:"rate_limited_last_24_hours"`
+	RecentCycles       []models.CollectorRun    `json:"recent_cycles"` // the latest recently_played runs, newest first
+}
+
+// CollectorRunsResponse is /admin/collector-runs
+type CollectorRunsResponse struct {
+	Runs  []models.CollectorRun `json:"runs"`
+	Count int                   `json:"count"`
+}
+
+// GapsResponse is /stats/gaps
+type GapsResponse struct {
+	Gaps          []models.CollectionGap `json:"gaps"`
+	Count         int                    `json:"count"`
+	Open          int                    `json:"open"`
+	ActiveMinutes int                    `json:"active_minutes"`
+}
+
+type DurationTotal struct {
+	TotalMs   int64  `json:"total_ms"`
+	Formatted string `json:"formatted"`
+}
+
+type DailyListeningTime struct {
+	Date      string `json:"date"`
+	TotalMs   int64  `json:"total_ms"`
+	Formatted string `json:"formatted"`
+	Count     int    `json:"count"`
+}
+
+type ListeningStatsResponse struct {
+	ListeningTime        map[string]DurationTotal `json:"listening_time"`
+	DailyBreakdownLast30 []DailyListeningTime     `json:"daily_breakdown_last_30_days"`
+}
+
+// SongListeningTimeResponse is /listening-stats?song_id=
+type SongListeningTimeResponse struct {
+	SongID    string `json:"song_id"`
+	PlayCount int    `json:"play_count"`
+	TotalMs   int64  `json:"total_ms"`
+	Formatted string `json:"formatted"`
+}
+
+/* ---------- stats ---------- */
+
+type MostPlayedResponse struct {
+	Period string                   `json:"period"`
+	From   *time.Time               `json:"from"`
+	To     *time.Time               `json:"to"`
+	Tracks []models.MostPlayedTrack `json:"tracks"`
+	Count  int                      `json:"count"`
+}
+
+type CompareWindow struct {
+	Label string    `json:"label"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Plays int       `json:"plays"`
+}
+
+type CompareResponse struct {
+	PeriodA     CompareWindow      `json:"period_a"`
+	PeriodB     CompareWindow      `json:"period_b"`
+	PrimaryOnly bool               `json:"primary_only"` // featured artists left out of Artists
+	Artists     *models.Comparison `json:"artists"`
+	Genres      *models.Comparison `json:"genres"`
+	Tracks      *models.Comparison `json:"tracks"`
+}
+
+type SkipStatsResponse struct {
+	Period        string                  `json:"period"`
+	From          *time.Time              `json:"from"`
+	To            *time.Time              `json:"to"`
+	SkipThreshold float64                 `json:"skip_threshold"`
+	MinPlays      int                     `json:"min_plays"`
+	MostSkipped   []models.SkippedTrack   `json:"most_skipped"`
+	Artists       []models.ArtistSkipRate `json:"artists"`
+}
+
+// QueueDropoffResponse is /stats/queue-dropoff
+type QueueDropoffResponse struct {
+	Period string     `json:"period"`
+	From   *time.Time `json:"from"`
+	To     *time.Time `json:"to"`
+	models.QueueDropoffSummary
+	Tracks []models.QueueDropoffTrack `json:"tracks"` // queued and then not played, most often first
+}
+
+type DeviceStatsResponse struct {
+	Period  string              `json:"period"`
+	From    *time.Time          `json:"from"`
+	To      *time.Time          `json:"to"`
+	Devices []models.DeviceStat `json:"devices"`
+}
+
+type ContextStatsResponse struct {
+	Period      string                   `json:"period"`
+	From        *time.Time               `json:"from"`
+	To          *time.Time               `json:"to"`
+	ByType      []models.ContextTypeStat `json:"by_type"`
+	TopContexts []models.ContextStat     `json:"top_contexts"`
+}
+
+type ErasResponse struct {
+	Period  string             `json:"period"`
+	From    *time.Time         `json:"from"`
+	To      *time.Time         `json:"to"`
+	Group   string             `json:"group"`
+	Buckets []models.EraBucket `json:"buckets"`
+	models.EraSummary
+}
+
+type GenreTimelineResponse struct {
+	Period      string     `json:"period"`
+	From        *time.Time `json:"from"`
+	To          *time.Time `json:"to"`
+	Granularity string     `json:"granularity"`
+	models.GenreTimeline
+}
+
+type MoodTimelineResponse struct {
+	Period      string                 `json:"period"`
+	From        *time.Time             `json:"from"`
+	To          *time.Time             `json:"to"`
+	Granularity string                 `json:"granularity"`
+	Summary     models.MoodAverages    `json:"summary"`
+	Timeline    []models.MoodBucket    `json:"timeline"`
+	TimeOfDay   []models.MoodTimeOfDay `json:"time_of_day"`
+}
+
+type DecadesResponse struct {
+	Period   string                  `json:"period"`
+	From     *time.Time              `json:"from"`
+	To       *time.Time              `json:"to"`
+	Decades  []models.DecadePlays    `json:"decades"`
+	Coverage models.MetadataCoverage `json:"coverage"`
+}
+
+type LabelsResponse struct {
+	Period   string                  `json:"period"`
+	From     *time.Time              `json:"from"`
+	To       *time.Time              `json:"to"`
+	Labels   []models.LabelPlays     `json:"labels"`
+	Count    int                     `json:"count"`
+	Coverage models.MetadataCoverage `json:"coverage"`
+}
+
+type CalendarResponse struct {
+	Years         int                     `json:"years"`
+	From          string                  `json:"from"`
+	To            string                  `json:"to"`
+	TotalPlays    int                     `json:"total_plays"`
+	ActiveDays    int                     `json:"active_days"`
+	Days          []models.CalendarDay    `json:"days"`
+	LongestStreak *models.ListeningStreak `json:"longest_streak"`
+	CurrentStreak *models.ListeningStreak `json:"current_streak"`
+}
+
+type ListeningPatternsResponse struct {
+	Period     string       `json:"period"`
+	From       *time.Time   `json:"from"`
+	To         *time.Time   `json:"to"`
+	Timezone   string       `json:"timezone"`
+	CachedAt   time.Time    `json:"cached_at"`
+	Weekdays   []string     `json:"weekdays"`
+	Plays      [7][24]int   `json:"plays"`
+	Minutes    [7][24]int64 `json:"minutes"`
+	TotalPlays int          `json:"total_plays"`
+	PeakDay    string       `json:"peak_day"`
+	PeakHour   *int         `json:"peak_hour"`
+}
+
+type DiscoveriesResponse struct {
+	Period      string             `json:"period"`
+	From        *time.Time         `json:"from"`
+	To          *time.Time         `json:"to"`
+	Discoveries []models.Discovery `json:"discoveries"`
+	Count       int                `json:"count"`
+	Total       int                `json:"total"`
+	Replayed    int                `json:"replayed"`
+	OneOffs     int                `json:"one_offs"`
+}
+
+// DiversityWindow is one window of /stats/diversity. The ratios are 0-1.
+type DiversityWindow struct {
+	Start                string  `json:"start"` // first day, YYYY-MM-DD
+	End                  string  `json:"end"`   // first day of the next window
+	Plays                int     `json:"plays"`
+	UniqueTracks         int     `json:"unique_tracks"`
+	UniqueArtists        int     `json:"unique_artists"`
+	UniqueArtistsPerWeek float64 `json:"unique_artists_per_week"`
+	GenreEntropy         float64 `json:"genre_entropy"`  // bits
+	GenreEvenness        float64 `json:"genre_evenness"` // entropy over its maximum for that many genres
+	Top10Share           float64 `json:"top10_share"`    // share of plays taken by the 10 most played tracks
+	RepeatRatio          float64 `json:"repeat_ratio"`   // share of plays of a track already played in the window
+}
+
+// DiversityTrend is the least-squares change per window of each metric,
+// over the windows with plays
+type DiversityTrend struct {
+	UniqueArtistsPerWeek float64 `json:"unique_artists_per_week"`
+	GenreEvenness        float64 `json:"genre_evenness"`
+	Top10Share           float64 `json:"top10_share"`
+	RepeatRatio          float64 `json:"repeat_ratio"`
+	Narrowing            bool    `json:"narrowing"` // fewer artists per week and less even genres
+}
+
+type DiversityResponse struct {
+	Window   string            `json:"window"`
+	Count    int               `json:"count"`
+	Timezone string            `json:"timezone"`
+	Windows  []DiversityWindow `json:"windows"`
+	Trend    DiversityTrend    `json:"trend"`
+}
+
+/* ---------- albums ---------- */
+
+type TopAlbumsResponse struct {
+	Period string            `json:"period"`
+	From   *time.Time        `json:"from"`
+	To     *time.Time        `json:"to"`
+	Albums []models.TopAlbum `json:"albums"`
+	Count  int               `json:"count"`
+}
+
+type AlbumPlaysResponse struct {
+	Album     *models.TopAlbum         `json:"album"`
+	Tracks    []models.AlbumTrackPlays `json:"tracks"`
+	Formatted string                   `json:"formatted"`
+}
+
+/* ---------- plays ---------- */
+
+type AnnotatePlayResponse struct {
+	Annotated  int                    `json:"annotated"`
+	Annotation *models.PlayAnnotation `json:"annotation"`
+}
+
+type PlaysResponse struct {
+	Mood      string                 `json:"mood,omitempty"`
+	Activity  string                 `json:"activity,omitempty"`
+	From      *time.Time             `json:"from"`
+	To        *time.Time             `json:"to"`
+	Plays     []models.AnnotatedPlay `json:"plays"`
+	Count     int                    `json:"count"`
+	Total     int                    `json:"total"`
+	TotalMs   int64                  `json:"total_ms"`
+	Formatted string                 `json:"formatted"`
+}
+
+/* ---------- milestones ---------- */
+
+// TrackDetailResponse is /tracks/:id. InLibrary is false for tracks never
+// played or saved; FromSpotify is false when Spotify couldn't be reached.
+type TrackDetailResponse struct {
+	models.TrackDetail
+	Artists     []TrackArtist `json:"artists"`
+	InLibrary   bool          `json:"in_library"`
+	FromSpotify bool          `json:"from_spotify"`
+}
+
+// TrackArtist is one of a track's artists with their cached genres
+type TrackArtist struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Genres []string `json:"genres"`
+}
+
+type MilestonesResponse struct {
+	Milestones []models.Milestone `json:"milestones"`
+	Count      int                `json:"count"`
+}
+
+/* ---------- artists ---------- */
+
+type ArtistResponse struct {
+	ID              string                       `json:"id"`
+	Name            string                       `json:"name"`
+	Genres          []string                     `json:"genres"`
+	ImageURL        string                       `json:"image_url"`
+	Followers       int                          `json:"followers"`
+	Popularity      int                          `json:"popularity"`
+	TopTracks       []SearchTrack                `json:"top_tracks"` // Spotify's, with my history
+	SpotifyCachedAt time.Time                    `json:"spotify_cached_at"`
+	Plays           *models.ArtistPlays          `json:"plays"`
+	Formatted       string                       `json:"formatted"`
+	LikedTracks     []models.RecentlyLikedTracks `json:"liked_tracks"`
+	LikedCount      int                          `json:"liked_count"`
+}
+
+type RelatedArtistsResponse struct {
+	ArtistID  string                 `json:"artist_id"`
+	FetchedAt time.Time              `json:"fetched_at"`
+	Related   []models.RelatedArtist `json:"related"`
+	Count     int                    `json:"count"`
+	Unplayed  int                    `json:"unplayed"` // of those returned
+}
+
+/* ---------- discovery, search & recommendations ---------- */
+
+type GenresResponse struct {
+	Genres []repository.GenreCount `json:"genres"`
+	Count  int                     `json:"count"`
+}
+
+type DiscoverResponse struct {
+	Suggestions []models.DiscoveryCandidate `json:"suggestions"`
+	Count       int                         `json:"count"`
+}
+
+type NewReleasesResponse struct {
+	Since    string                 `json:"since"`
+	Releases []models.ArtistRelease `json:"releases"`
+	Count    int                    `json:"count"`
+}
+
+type SearchTrack struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Artists       []string `json:"artists"`
+	AlbumName     string   `json:"album_name"`
+	AlbumCoverURL string   `json:"album_cover_url"`
+	DurationMs    int      `json:"duration_ms"`
+	models.TrackHistory
+}
+
+type SearchArtist struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Genres   []string `json:"genres"`
+	ImageURL string   `json:"image_url"`
+	models.ArtistHistory
+}
+
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Tracks  []SearchTrack  `json:"tracks"`
+	Artists []SearchArtist `json:"artists"`
+}
+
+type RecommendedTrack struct {
+	SearchTrack
+	AlreadyPlayed bool `json:"already_played"`
+}
+
+type RecommendationSeeds struct {
+	Tracks  []models.DiscoverySeed `json:"tracks"`
+	Artists []models.DiscoverySeed `json:"artists"`
+	Genres  []models.DiscoverySeed `json:"genres"`
+}
+
+type RecommendationsResponse struct {
+	Seeds    RecommendationSeeds `json:"seeds"`
+	Targets  map[string]float64  `json:"targets"`
+	Tracks   []RecommendedTrack  `json:"tracks"`
+	Count    int                 `json:"count"`
+	Unplayed int                 `json:"unplayed"`
+}
+
+type LikeResponse struct {
+	SpotifySongID string     `json:"spotify_song_id"`
+	TrackName     string     `json:"track_name,omitempty"`
+	Liked         bool       `json:"liked"`
+	AddedAt       *time.Time `json:"added_at,omitempty"`
+	UnlikedAt     *time.Time `json:"unliked_at,omitempty"`
+	Message       string     `json:"message"`
+}
+
+type LikedVsPlayedResponse struct {
+	models.LikedVsPlayed
+}
+
+type LikeTracksResponse struct {
+	Saved    int      `json:"saved"`
+	TrackIDs []string `json:"track_ids"`
+	Message  string   `json:"message"`
+}
+
+type GeneratedPlaylistResponse struct {
+	PlaylistID string `json:"playlist_id"`
+	Name       string `json:"name"`
+	URI        string `json:"uri"`
+	URL        string `json:"url"`
+	Source     string `json:"source"`
+	TrackCount int    `json:"track_count"`
+}
+
+/* ---------- admin ---------- */
+
+type JobsResponse struct {
+	Jobs  []jobs.Job `json:"jobs"`
+	Count int        `json:"count"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []webhooks.Webhook `json:"webhooks"`
+	Count    int                `json:"count"`
+	Events   []string           `json:"events"` // types a webhook can subscribe to
+}
+
+// PurgeHistoryResponse is DELETE /admin/history
+type PurgeHistoryResponse struct {
+	Table    string              `json:"table"`
+	Action   string              `json:"action"` // delete or anonymize
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	DryRun   bool                `json:"dry_run"`
+	Matched  models.HistoryRange `json:"matched"`
+	Affected int64               `json:"affected"`
+	Confirm  string              `json:"confirm,omitempty"` // send back as ?confirm= to apply the dry run
+	Message  string              `json:"message"`
+}
+
+type ScrobbleServiceStatus struct {
+	Enabled bool `json:"enabled"`
+	models.ScrobbleSummary
+}
+
+type ScrobblesResponse struct {
+	LastFM       ScrobbleServiceStatus `json:"lastfm"`
+	ListenBrainz ScrobbleServiceStatus `json:"listenbrainz"`
+}
+
+type APIUsageResponse struct {
+	Mode        string                    `json:"mode"` // SPOTIFY_API_LOG; calls are estimates when sampled
+	Bucket      string                    `json:"bucket"`
+	From        time.Time                 `json:"from"`
+	To          time.Time                 `json:"to"`
+	Buckets     []models.APIUsageBucket   `json:"buckets"`
+	Endpoints   []models.APIEndpointUsage `json:"endpoints"`
+	RateLimited []models.APIRateLimit     `json:"rate_limited"` // latest 429s, newest first
+}
+
+// LastPayloadsResponse is /admin/debug/last-payloads
+type LastPayloadsResponse struct {
+	Enabled   bool              `json:"enabled"`   // false when SPOTIFY_DEBUG_CAPTURE is unset
+	Endpoints []string          `json:"endpoints"` // endpoints being captured
+	Payloads  []CapturedPayload `json:"payloads"`  // newest first
+	Count     int               `json:"count"`
+}
+
+// BulkTrackResult is the outcome of one item of a bulk track update, in request order
+type BulkTrackResult struct {
+	SpotifySongID string `json:"spotify_song_id"`
+	Status        string `json:"status"` // updated, not_found or invalid
+	Error         string `json:"error,omitempty"`
+}
+
+type BulkUpdateTracksResponse struct {
+	Results  []BulkTrackResult `json:"results"`
+	Updated  int               `json:"updated"`
+	NotFound int               `json:"not_found"`
+	Invalid  int               `json:"invalid"`
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/lastfm"
+	"example.com/spotifydb/internal/listenbrainz"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- Last.fm scrobbling ---------- */
+
+// lastFM sends new plays to Last.fm; nil unless LASTFM_SESSION_KEY is set.
+// listenBrainz likewise needs LISTENBRAINZ_TOKEN (see listenbrainz.go).
+var (
+	scrobbleConfig config.ScrobbleConfig
+	lastFM         *lastfm.Client
+	listenBrainz   *listenbrainz.Client
+)
+
+// scrobbleBatches caps how many track.scrobble requests one cycle makes
+const scrobbleBatches = 4
+
+// minScrobbleDuration is the shortest track Last.fm accepts
+const minScrobbleDuration = 30 * time.Second
+
+// SetScrobbling configures where new plays are scrobbled; call it before
+// StartSpotifyCron
+func SetScrobbling(cfg config.ScrobbleConfig) {
+	scrobbleConfig = cfg
+	if cfg.LastFMEnabled() {
+		lastFM = lastfm.New(cfg.LastFMAPIKey, cfg.LastFMAPISecret, cfg.LastFMSessionKey)
+	}
+	if cfg.ListenBrainzEnabled() {
+		listenBrainz = listenbrainz.New(cfg.ListenBrainzURL, cfg.ListenBrainzToken)
+	}
+}
+
+// queueScrobbles marks newly stored plays for sending to Last.fm and
+// ListenBrainz
+func queueScrobbles(plays []store.Play) {
+	if len(plays) == 0 || (lastFM == nil && listenBrainz == nil) {
+		return
+	}
+	ids := make([]string, len(plays))
+	playedAt := make([]time.Time, len(plays))
+	for i, p := range plays {
+		ids[i] = p.SpotifyID
+		playedAt[i] = p.PlayedAt
+	}
+	for service, enabled := range map[string]bool{
+		models.ScrobbleLastFM:       lastFM != nil,
+		models.ScrobbleListenBrainz: listenBrainz != nil,
+	} {
+		if !enabled {
+			continue
+		}
+		if _, err := models.QueueScrobbles(repository.Pool, service, ids, playedAt); err != nil {
+			fmt.Println("queueScrobbles:", err)
+		}
+	}
+}
+
+// ScrobblePending sends queued plays to Last.fm, oldest first. Plays Last.fm
+// rejects are marked ignored; a failed request marks its batch failed so it's
+// retried next cycle, up to SCROBBLE_MAX_ATTEMPTS times. Outages and rate
+// limits don't count as attempts.
+func ScrobblePending() {
+	if lastFM == nil {
+		return
+	}
+	if n, err := models.ExpireScrobbles(repository.Pool, models.ScrobbleLastFM, time.Now().Add(-scrobbleConfig.MaxAge)); err != nil {
+		fmt.Println("ScrobblePending:", err)
+		return
+	} else if n > 0 {
+		fmt.Printf("📡 gave up on %d plays too old to scrobble\n", n)
+	}
+
+	pending, err := models.GetPendingScrobbles(repository.Pool, models.ScrobbleLastFM, models.ScrobblePending,
+		scrobbleConfig.MaxAttempts, scrobbleBatches*lastfm.MaxBatch)
+	if err != nil {
+		fmt.Println("ScrobblePending:", err)
+		return
+	}
+
+	var tooShort []int
+	batch := make([]models.PendingScrobble, 0, lastfm.MaxBatch)
+	for _, p := range pending {
+		if p.DurationMs > 0 && time.Duration(p.DurationMs)*time.Millisecond <= minScrobbleDuration {
+			tooShort = append(tooShort, p.ID)
+			continue
+		}
+		batch = append(batch, p)
+		if len(batch) < lastfm.MaxBatch {
+			continue
+		}
+		if !sendScrobbles(batch) {
+			batch = nil
+			break
+		}
+		batch = batch[:0]
+	}
+	if len(batch) > 0 {
+		sendScrobbles(batch)
+	}
+	if err := models.SetScrobbleStatus(repository.Pool, models.ScrobbleLastFM, tooShort, models.ScrobbleIgnored,
+		"shorter than 30 seconds"); err != nil {
+		fmt.Println("ScrobblePending:", err)
+	}
+}
+
+// sendScrobbles submits one batch and records the outcome. It reports false
+// when Last.fm is unavailable and nothing more should be sent this cycle.
+func sendScrobbles(batch []models.PendingScrobble) bool {
+	scrobbles := make([]lastfm.Scrobble, len(batch))
+	ids := make([]int, len(batch))
+	for i, p := range batch {
+		scrobbles[i] = lastfm.Scrobble{
+			Artist:     p.ArtistName,
+			Track:      p.TrackName,
+			Album:      p.AlbumName,
+			PlayedAt:   p.PlayedAt,
+			DurationMs: p.DurationMs,
+		}
+		ids[i] = p.ID
+	}
+
+	results, err := lastFM.Scrobble(scrobbles)
+	if err != nil {
+		var apiErr *lastfm.Error
+		if errors.As(err, &apiErr) && apiErr.Temporary() {
+			fmt.Printf("⚠️  Last.fm unavailable, %d plays stay queued: %v\n", len(batch), err)
+			return false
+		}
+		fmt.Printf("❌ scrobbling %d plays failed: %v\n", len(batch), err)
+		if err := models.SetScrobbleStatus(repository.Pool, models.ScrobbleLastFM, ids, models.ScrobbleFailed, err.Error()); err != nil {
+			fmt.Println("ScrobblePending:", err)
+		}
+		// A bad session or key fails every batch the same way
+		return !errors.As(err, &apiErr) || (apiErr.Code != lastfm.ErrInvalidSession &&
+			apiErr.Code != lastfm.ErrInvalidAPIKey && apiErr.Code != lastfm.ErrSuspendedAPIKey)
+	}
+
+	var sent []int
+	for i, r := range results {
+		if r.Accepted {
+			sent = append(sent, ids[i])
+			continue
+		}
+		reason := fmt.Sprintf("ignored by Last.fm (code %d)", r.IgnoredCode)
+		if r.IgnoredReason != "" {
+			reason = "ignored by Last.fm: " + r.IgnoredReason
+		}
+		if err := models.SetScrobbleStatus(repository.Pool, models.ScrobbleLastFM, ids[i:i+1],
+			models.ScrobbleIgnored, reason); err != nil {
+			fmt.Println("ScrobblePending:", err)
+		}
+	}
+	if err := models.SetScrobbleStatus(repository.Pool, models.ScrobbleLastFM, sent, models.ScrobbleSent, ""); err != nil {
+		fmt.Println("ScrobblePending:", err)
+	}
+	if len(sent) > 0 {
+		fmt.Printf("📡 scrobbled %d plays to Last.fm\n", len(sent))
+	}
+	return true
+}
+
+// GetScrobbles reports the Last.fm and ListenBrainz queues: plays by status
+// and the most recent failures
+// GET /admin/scrobbles
+func GetScrobbles(c *gin.Context) {
+	limit := parseLimit(c, 20, 200)
+	resp := ScrobblesResponse{}
+	for _, service := range models.ScrobbleServices {
+		summary, err := models.GetScrobbleSummary(repository.Pool, service, limit)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		status := ScrobbleServiceStatus{ScrobbleSummary: *summary}
+		switch service {
+		case models.ScrobbleLastFM:
+			status.Enabled = scrobbleConfig.LastFMEnabled()
+			resp.LastFM = status
+		case models.ScrobbleListenBrainz:
+			status.Enabled = scrobbleConfig.ListenBrainzEnabled()
+			resp.ListenBrainz = status
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetryScrobbles requeues every failed play with its attempts reset; they're
+// sent on the next collection cycle. ?service=lastfm|listenbrainz (default both)
+// POST /admin/scrobbles/retry
+func RetryScrobbles(c *gin.Context) {
+	services := models.ScrobbleServices
+	if v := c.Query("service"); v != "" {
+		if !slices.Contains(models.ScrobbleServices, v) {
+			badRequest(c, fmt.Sprintf("invalid 'service' %q (expected %s)", v, strings.Join(models.ScrobbleServices, " or ")))
+			return
+		}
+		services = []string{v}
+	}
+
+	total := 0
+	for _, service := range services {
+		n, err := models.RetryFailedScrobbles(repository.Pool, service)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		total += n
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: fmt.Sprintf("Requeued %d failed scrobbles", total)})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- search (Spotify + my history) ---------- */
+
+var searchTypes = map[string]bool{"track": true, "artist": true}
+
+// Search proxies Spotify search and annotates each result with my play count,
+// last play and liked status.
+// GET /search?q=&type=track,artist&limit=10
+func Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		badRequest(c, "missing 'q'")
+		return
+	}
+
+	var types []string
+	for _, t := range strings.Split(c.DefaultQuery("type", "track,artist"), ",") {
+		t = strings.TrimSpace(t)
+		if !searchTypes[t] {
+			badRequest(c, "invalid 'type' (expected track and/or artist): "+t)
+			return
+		}
+		types = append(types, t)
+	}
+	limit := parseLimit(c, 10, 50)
+
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+	res, err := services.Search(c.Request.Context(), accessTok, q, types, limit)
+	if err != nil {
+		spotifyError(c, err)
+		return
+	}
+
+	trackIDs := make([]string, 0, len(res.Tracks.Items))
+	for _, t := range res.Tracks.Items {
+		trackIDs = append(trackIDs, t.ID)
+	}
+	trackHistory, err := models.GetTrackHistory(repository.Pool, trackIDs)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	artistIDs := make([]string, 0, len(res.Artists.Items))
+	artistNames := make([]string, 0, len(res.Artists.Items))
+	for _, a := range res.Artists.Items {
+		artistIDs = append(artistIDs, a.ID)
+		artistNames = append(artistNames, a.Name)
+	}
+	artistHistory, err := models.GetArtistHistory(repository.Pool, artistIDs, artistNames)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	tracks := make([]SearchTrack, 0, len(res.Tracks.Items))
+	for _, t := range res.Tracks.Items {
+		st := SearchTrack{
+			ID:           t.ID,
+			Name:         t.Name,
+			Artists:      []string{},
+			AlbumName:    t.Album.Name,
+			DurationMs:   t.DurationMs,
+			TrackHistory: trackHistory[t.ID],
+		}
+		for _, a := range t.Artists {
+			st.Artists = append(st.Artists, a.Name)
+		}
+		if len(t.Album.Images) > 0 {
+			st.AlbumCoverURL = t.Album.Images[0].URL
+		}
+		tracks = append(tracks, st)
+	}
+
+	artists := make([]SearchArtist, 0, len(res.Artists.Items))
+	for _, a := range res.Artists.Items {
+		sa := SearchArtist{
+			ID:            a.ID,
+			Name:          a.Name,
+			Genres:        a.Genres,
+			ArtistHistory: artistHistory[a.ID],
+		}
+		if sa.Genres == nil {
+			sa.Genres = []string{}
+		}
+		if len(a.Images) > 0 {
+			sa.ImageURL = a.Images[0].URL
+		}
+		artists = append(artists, sa)
+	}
+
+	c.JSON(http.StatusOK, SearchResponse{
+		Query:   q,
+		Tracks:  tracks,
+		Artists: artists,
+	})
+}
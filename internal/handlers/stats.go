@@ -0,0 +1,660 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/diversity"
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- shared period parser ---------- */
+
+// parsePeriod turns ?period=week|month|year|all (optionally with ?month=YYYY-MM)
+// into a half-open [from, to) window. A nil bound means open-ended.
+func parsePeriod(c *gin.Context, defaultPeriod string) (from, to *time.Time, label string, err error) {
+	return periodWindow(c.DefaultQuery("period", defaultPeriod), c.Query("month"))
+}
+
+// periodWindow is parsePeriod for values that don't come from the query string
+func periodWindow(period, month string) (from, to *time.Time, label string, err error) {
+	now := time.Now()
+
+	if month != "" {
+		start, err := time.ParseInLocation("2006-01", month, config.Timezone())
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("invalid 'month' (expected YYYY-MM): %v", err)
+		}
+		end := start.AddDate(0, 1, 0)
+		return &start, &end, month, nil
+	}
+
+	var start time.Time
+	switch period {
+	case "day":
+		start = now.AddDate(0, 0, -1)
+	case "week":
+		start = now.AddDate(0, 0, -7)
+	case "month":
+		start = now.AddDate(0, -1, 0)
+	case "year":
+		start = now.AddDate(-1, 0, 0)
+	case "all":
+		return nil, nil, period, nil
+	default:
+		return nil, nil, "", fmt.Errorf("invalid 'period' %q (expected day, week, month, year or all)", period)
+	}
+	return &start, nil, period, nil
+}
+
+// parseGroupBy reads ?group_by=track|canonical; canonical counts remasters and
+// re-releases of the same recording as one track
+func parseGroupBy(c *gin.Context) (canonical bool, err error) {
+	switch g := c.DefaultQuery("group_by", "track"); g {
+	case "track":
+		return false, nil
+	case "canonical":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid 'group_by' %q (expected track or canonical)", g)
+	}
+}
+
+// parseSource reads ?source=, which keeps only rows stored by that source
+// (see models.Sources); empty means all of them
+func parseSource(c *gin.Context) (string, error) {
+	source := c.Query("source")
+	if source != "" && !models.IsSource(source) {
+		return "", fmt.Errorf("invalid 'source' %q (expected %s)", source, strings.Join(models.Sources, ", "))
+	}
+	return source, nil
+}
+
+// parseLimit reads ?limit= clamped to [1, max]
+func parseLimit(c *gin.Context, def, max int) int {
+	limit := def
+	if v := c.Query("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	if limit > max {
+		limit = max
+	}
+	return limit
+}
+
+/* ---------- most played (derived from history) ---------- */
+
+func (a *API) GetMostPlayed(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "month")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	limit := parseLimit(c, 50, 500)
+	canonical, err := parseGroupBy(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	source, err := parseSource(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	tracks, err := a.store.MostPlayed(from, to, limit, canonical, source, cronConfig.DedupWindow)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if tracks == nil {
+		tracks = []models.MostPlayedTrack{}
+	}
+
+	c.JSON(http.StatusOK, MostPlayedResponse{
+		Period: period,
+		From:   from,
+		To:     to,
+		Tracks: tracks,
+		Count:  len(tracks),
+	})
+}
+
+/* ---------- comparison between two windows ---------- */
+
+// parseWindow turns YYYY-MM or YYYY into a half-open [from, to) window in
+// the configured timezone
+func parseWindow(name, v string) (from, to time.Time, err error) {
+	if from, err = time.ParseInLocation("2006-01", v, config.Timezone()); err == nil {
+		return from, from.AddDate(0, 1, 0), nil
+	}
+	if from, err = time.ParseInLocation("2006", v, config.Timezone()); err == nil {
+		return from, from.AddDate(1, 0, 0), nil
+	}
+	return from, to, fmt.Errorf("invalid '%s' %q (expected YYYY-MM or YYYY)", name, v)
+}
+
+// GetCompare diffs artists, genres and tracks between ?period_a= and
+// ?period_b= (default: last month vs this month). Featured artists count
+// unless ?primary_only=true.
+func GetCompare(c *gin.Context) {
+	now := time.Now().In(config.Timezone())
+	thisMonth := now.Format("2006-01")
+	lastMonth := now.AddDate(0, -1, 0).Format("2006-01")
+	periodA := c.DefaultQuery("period_a", lastMonth)
+	periodB := c.DefaultQuery("period_b", thisMonth)
+
+	fromA, toA, err := parseWindow("period_a", periodA)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	fromB, toB, err := parseWindow("period_b", periodB)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	limit := parseLimit(c, 10, 50)
+
+	playsA, err := models.CountPlaysBetween(repository.Pool, fromA, toA)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	playsB, err := models.CountPlaysBetween(repository.Pool, fromB, toB)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	result := CompareResponse{
+		PeriodA:     CompareWindow{Label: periodA, From: fromA, To: toA, Plays: playsA},
+		PeriodB:     CompareWindow{Label: periodB, From: fromB, To: toB, Plays: playsB},
+		PrimaryOnly: c.Query("primary_only") == "true",
+	}
+	dimensions := map[string]**models.Comparison{
+		"artists": &result.Artists,
+		"genres":  &result.Genres,
+		"tracks":  &result.Tracks,
+	}
+	for dimension, dst := range dimensions {
+		cmp, err := models.CompareWindows(repository.Pool, dimension, fromA, toA, fromB, toB, limit, result.PrimaryOnly)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		*dst = cmp
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+/* ---------- skips (inferred from played_at gaps) ---------- */
+
+// skipInferenceBatch caps how many plays one cron cycle post-processes
+const skipInferenceBatch = 500
+
+// InferSkips fills completion ratios for newly collected plays
+func InferSkips() {
+	n, err := models.InferCompletion(repository.Pool, skipInferenceBatch)
+	if err != nil {
+		fmt.Println("cron:", err)
+		return
+	}
+	if n > 0 {
+		fmt.Printf("⏭️  inferred completion for %d plays\n", n)
+	}
+}
+
+func GetSkipStats(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "all")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	limit := parseLimit(c, 20, 200)
+	minPlays := 3
+	if v := c.Query("min_plays"); v != "" {
+		if minPlays, err = strconv.Atoi(v); err != nil || minPlays < 1 {
+			badRequest(c, "invalid 'min_plays' (expected a positive integer)")
+			return
+		}
+	}
+
+	tracks, err := models.GetMostSkipped(repository.Pool, from, to, minPlays, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	artists, err := models.GetArtistSkipRates(repository.Pool, from, to, minPlays, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SkipStatsResponse{
+		Period:        period,
+		From:          from,
+		To:            to,
+		SkipThreshold: models.SkipThreshold,
+		MinPlays:      minPlays,
+		MostSkipped:   tracks,
+		Artists:       artists,
+	})
+}
+
+/* ---------- devices & contexts ---------- */
+
+func GetDeviceStats(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "month")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	devices, err := models.GetDeviceStats(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DeviceStatsResponse{
+		Period:  period,
+		From:    from,
+		To:      to,
+		Devices: devices,
+	})
+}
+
+func GetContextStats(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "month")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	limit := parseLimit(c, 20, 200)
+
+	types, top, err := models.GetContextStats(repository.Pool, from, to, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ContextStatsResponse{
+		Period:      period,
+		From:        from,
+		To:          to,
+		ByType:      types,
+		TopContexts: top,
+	})
+}
+
+/* ---------- calendar heatmap & streaks ---------- */
+
+// GetCalendar returns per-day play counts for the last ?years= (default 1,
+// max 5) plus the current and longest listening streaks
+func GetCalendar(c *gin.Context) {
+	years := 1
+	if v := c.Query("years"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 5 {
+			badRequest(c, "invalid 'years' (expected 1-5)")
+			return
+		}
+		years = parsed
+	}
+
+	today := config.Today()
+	from := today.AddDate(-years, 0, 1)
+
+	days, err := models.GetCalendar(repository.Pool, from)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	longest, current, err := models.GetListeningStreaks(repository.Pool)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	totalPlays, activeDays := 0, 0
+	for _, d := range days {
+		totalPlays += d.Plays
+		if d.Plays > 0 {
+			activeDays++
+		}
+	}
+
+	c.JSON(http.StatusOK, CalendarResponse{
+		Years:         years,
+		From:          from.Format("2006-01-02"),
+		To:            today.Format("2006-01-02"),
+		TotalPlays:    totalPlays,
+		ActiveDays:    activeDays,
+		Days:          days,
+		LongestStreak: longest,
+		CurrentStreak: current,
+	})
+}
+
+/* ---------- listening patterns ---------- */
+
+// patternsTTL is how long a computed matrix is served before re-querying
+const patternsTTL = 5 * time.Minute
+
+type cachedPatterns struct {
+	patterns *models.ListeningPatterns
+	at       time.Time
+}
+
+var patternsCache = struct {
+	sync.Mutex
+	entries map[string]cachedPatterns
+}{entries: map[string]cachedPatterns{}}
+
+// GetListeningPatterns returns a 7x24 weekday/hour matrix of plays and
+// minutes for ?period= (default: all), cached for a few minutes
+func GetListeningPatterns(c *gin.Context) {
+	from, to, label, err := parsePeriod(c, "all")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	patternsCache.Lock()
+	entry, ok := patternsCache.entries[label]
+	patternsCache.Unlock()
+
+	if !ok || time.Since(entry.at) > patternsTTL {
+		patterns, err := models.GetListeningPatterns(repository.Pool, from, to)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		entry = cachedPatterns{patterns: patterns, at: time.Now()}
+		patternsCache.Lock()
+		patternsCache.entries[label] = entry
+		patternsCache.Unlock()
+	}
+
+	c.JSON(http.StatusOK, ListeningPatternsResponse{
+		Period:     label,
+		From:       from,
+		To:         to,
+		Timezone:   config.Timezone().String(),
+		CachedAt:   entry.at,
+		Weekdays:   entry.patterns.Weekdays,
+		Plays:      entry.patterns.Plays,
+		Minutes:    entry.patterns.Minutes,
+		TotalPlays: entry.patterns.TotalPlays,
+		PeakDay:    entry.patterns.PeakDay,
+		PeakHour:   entry.patterns.PeakHour,
+	})
+}
+
+/* ---------- discoveries (first-ever plays) ---------- */
+
+// GetDiscoveries lists tracks first played in ?period= (default month) and how
+// many times each was replayed since. ?sort=replays|first_played, ?limit=
+func GetDiscoveries(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "month")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	sort := c.DefaultQuery("sort", "replays")
+	if _, ok := models.DiscoverySorts[sort]; !ok {
+		badRequest(c, fmt.Sprintf("invalid 'sort' %q (expected replays or first_played)", sort))
+		return
+	}
+	limit := parseLimit(c, 50, 500)
+
+	tracks, total, replayed, err := models.GetDiscoveries(repository.Pool, from, to, sort, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DiscoveriesResponse{
+		Period:      period,
+		From:        from,
+		To:          to,
+		Discoveries: tracks,
+		Count:       len(tracks),
+		Total:       total,
+		Replayed:    replayed,
+		OneOffs:     total - replayed,
+	})
+}
+
+/* ---------- listening diversity ---------- */
+
+// diversityWindows are the ?window= sizes for /stats/diversity, with how
+// many of each are returned by default and at most
+var diversityWindows = map[string]struct{ def, max int }{
+	"week":    {12, 104},
+	"month":   {12, 36},
+	"quarter": {8, 20},
+}
+
+// windowStart is the first day of the week (Monday), month or quarter that
+// contains day
+func windowStart(unit string, day time.Time) time.Time {
+	switch unit {
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "quarter":
+		return time.Date(day.Year(), (day.Month()-1)/3*3+1, 1, 0, 0, 0, 0, day.Location())
+	default:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	}
+}
+
+// addWindows moves a window start n windows later (earlier when negative)
+func addWindows(unit string, start time.Time, n int) time.Time {
+	switch unit {
+	case "week":
+		return start.AddDate(0, 0, 7*n)
+	case "quarter":
+		return start.AddDate(0, 3*n, 0)
+	default:
+		return start.AddDate(0, n, 0)
+	}
+}
+
+// GetDiversity measures how varied listening is in each of the last ?count=
+// windows of ?window=week|month|quarter (default 12 months, the current one
+// included): unique artists per week, genre entropy, the top 10 tracks'
+// share of plays and how often tracks repeat, plus the trend of each
+func GetDiversity(c *gin.Context) {
+	unit := c.DefaultQuery("window", "month")
+	bounds, ok := diversityWindows[unit]
+	if !ok {
+		badRequest(c, fmt.Sprintf("invalid 'window' %q (expected week, month or quarter)", unit))
+		return
+	}
+	count := bounds.def
+	if v := c.Query("count"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > bounds.max {
+			badRequest(c, fmt.Sprintf("invalid 'count' (expected 1-%d for %s windows)", bounds.max, unit))
+			return
+		}
+		count = parsed
+	}
+
+	first := addWindows(unit, windowStart(unit, config.Today()), 1-count)
+	counts, err := models.GetDiversityCounts(repository.Pool, unit, first)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	resp := DiversityResponse{
+		Window:   unit,
+		Count:    count,
+		Timezone: config.Timezone().String(),
+		Windows:  make([]DiversityWindow, 0, count),
+	}
+	var artistsPerWeek, evenness, top10, repeats []float64
+	for i := 0; i < count; i++ {
+		start := addWindows(unit, first, i)
+		w := DiversityWindow{
+			Start: start.Format("2006-01-02"),
+			End:   addWindows(unit, start, 1).Format("2006-01-02"),
+		}
+		if n, ok := counts[w.Start]; ok {
+			w.Plays = n.Plays
+			w.UniqueTracks = n.UniqueTracks
+			w.UniqueArtists = n.UniqueArtists
+			w.UniqueArtistsPerWeek = diversity.Round(diversity.Mean(n.WeeklyArtists))
+			w.GenreEntropy = diversity.Round(diversity.Entropy(n.GenrePlays))
+			w.GenreEvenness = diversity.Round(diversity.Evenness(n.GenrePlays))
+			w.Top10Share = diversity.Round(diversity.TopShare(n.TrackPlays, 10))
+			w.RepeatRatio = diversity.Round(diversity.RepeatRatio(n.Plays, n.UniqueTracks))
+
+			// Windows without plays say nothing about the trend
+			artistsPerWeek = append(artistsPerWeek, w.UniqueArtistsPerWeek)
+			evenness = append(evenness, w.GenreEvenness)
+			top10 = append(top10, w.Top10Share)
+			repeats = append(repeats, w.RepeatRatio)
+		}
+		resp.Windows = append(resp.Windows, w)
+	}
+
+	resp.Trend = DiversityTrend{
+		UniqueArtistsPerWeek: diversity.Round(diversity.Slope(artistsPerWeek)),
+		GenreEvenness:        diversity.Round(diversity.Slope(evenness)),
+		Top10Share:           diversity.Round(diversity.Slope(top10)),
+		RepeatRatio:          diversity.Round(diversity.Slope(repeats)),
+	}
+	resp.Trend.Narrowing = resp.Trend.UniqueArtistsPerWeek < 0 && resp.Trend.GenreEvenness < 0
+	c.JSON(http.StatusOK, resp)
+}
+
+/* ---------- release eras ---------- */
+
+// GetEras buckets plays by the release date of their album, by decade or
+// year, with a nostalgia index: the share of dated plays whose album was over
+// 10 years old when played
+// GET /stats/eras?period=all&group=decade
+func GetEras(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "all")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	group := c.DefaultQuery("group", "decade")
+	var bucketSize int
+	switch group {
+	case "decade":
+		bucketSize = 10
+	case "year":
+		bucketSize = 1
+	default:
+		badRequest(c, fmt.Sprintf("invalid 'group' %q (expected decade or year)", group))
+		return
+	}
+
+	buckets, err := models.GetEraBuckets(repository.Pool, from, to, bucketSize)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	summary, err := models.GetEraSummary(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ErasResponse{
+		Period:     period,
+		From:       from,
+		To:         to,
+		Group:      group,
+		Buckets:    buckets,
+		EraSummary: summary,
+	})
+}
+
+// GetGenreTimeline charts how my genre mix shifts: plays and minutes per
+// day, week or month for my top genres
+// GET /stats/genres/timeline?period=year&granularity=week&limit=10
+func GetGenreTimeline(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "year")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	granularity := c.DefaultQuery("granularity", "week")
+	if !slices.Contains(models.TimelineGranularities, granularity) {
+		badRequest(c, fmt.Sprintf("invalid 'granularity' %q (expected %s)", granularity, strings.Join(models.TimelineGranularities, ", ")))
+		return
+	}
+
+	timeline, err := models.GetGenreTimeline(repository.Pool, from, to, granularity, parseLimit(c, 10, 50))
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, GenreTimelineResponse{
+		Period:        period,
+		From:          from,
+		To:            to,
+		Granularity:   granularity,
+		GenreTimeline: timeline,
+	})
+}
+
+// GetMoodTimeline charts the mood of my listening from audio features: average
+// valence, energy and danceability per day or week and per time of day
+// GET /stats/mood-timeline?period=month&granularity=day
+func GetMoodTimeline(c *gin.Context) {
+	from, to, period, err := parsePeriod(c, "month")
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	granularity := c.DefaultQuery("granularity", "day")
+	if granularity != "day" && granularity != "week" {
+		badRequest(c, fmt.Sprintf("invalid 'granularity' %q (expected day or week)", granularity))
+		return
+	}
+
+	summary, err := models.GetMoodSummary(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	timeline, err := models.GetMoodTimeline(repository.Pool, from, to, granularity)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	byTime, err := models.GetMoodByTimeOfDay(repository.Pool, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, MoodTimelineResponse{
+		Period:      period,
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Summary:     summary,
+		Timeline:    timeline,
+		TimeOfDay:   byTime,
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- collection stats cache ---------- */
+
+// collectionStatsMaxAge is how old cached stats may get before a request
+// recomputes them, e.g. while the recently_played collector is disabled
+const collectionStatsMaxAge = 30 * time.Minute
+
+// collectionStatsCache holds the last computed /collection-stats, refreshed
+// by the cron after each cycle
+var collectionStatsCache struct {
+	sync.Mutex
+	stats CollectionStatsResponse
+	at    time.Time
+}
+
+// refreshCollectionStats recomputes the cached stats and returns them
+func refreshCollectionStats() (CollectionStatsResponse, time.Time) {
+	stats, at := collectionStats(), time.Now()
+	collectionStatsCache.Lock()
+	collectionStatsCache.stats, collectionStatsCache.at = stats, at
+	collectionStatsCache.Unlock()
+	return stats, at
+}
+
+// invalidateCollectionStats makes the next request recompute the stats, after
+// history was changed outside the collectors
+func invalidateCollectionStats() {
+	collectionStatsCache.Lock()
+	collectionStatsCache.at = time.Time{}
+	collectionStatsCache.Unlock()
+}
+
+// CachedCollectionStats returns the cached collection stats, computing them
+// first when there are none yet or they're older than collectionStatsMaxAge
+func CachedCollectionStats() CollectionStatsResponse {
+	collectionStatsCache.Lock()
+	stats, at := collectionStatsCache.stats, collectionStatsCache.at
+	collectionStatsCache.Unlock()
+
+	if at.IsZero() || time.Since(at) > collectionStatsMaxAge {
+		stats, at = refreshCollectionStats()
+	}
+
+	stats.CacheAge = int(time.Since(at).Seconds())
+	return stats
+}
+
+// GetCollectionStats serves the cached collection stats
+func GetCollectionStats(c *gin.Context) {
+	c.JSON(http.StatusOK, CachedCollectionStats())
+}
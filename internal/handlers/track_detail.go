@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- track detail ---------- */
+
+// GetTrackDetail returns everything known about a track: its plays, saved
+// state, genres, audio features and canonical id from the database, plus
+// Spotify's catalogue data and artist genres fetched and cached on demand.
+// When Spotify can't be reached the stored data is still returned.
+// GET /tracks/:id
+func GetTrackDetail(c *gin.Context) {
+	spotifyID := c.Param("id")
+	if !validSpotifyID(spotifyID) {
+		badRequest(c, "invalid Spotify track ID")
+		return
+	}
+	limit := parseLimit(c, 50, 1000)
+
+	detail, err := models.GetTrackDetail(repository.Pool, spotifyID, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	var track *services.TrackDetails
+	accessTok, tokErr := getCronAccessToken()
+	if tokErr == nil {
+		track, err = services.GetCachedTrack(c.Request.Context(), accessTok, spotifyID)
+	} else {
+		err = tokErr
+	}
+	switch {
+	case err == nil:
+	case detail == nil && errors.Is(err, services.ErrNotFound):
+		notFound(c, fmt.Sprintf("track %s not found", spotifyID))
+		return
+	case detail == nil:
+		spotifyError(c, err)
+		return
+	default:
+		fmt.Printf("track detail: Spotify lookup failed, serving stored data: %v\n", err)
+	}
+
+	resp := TrackDetailResponse{Artists: []TrackArtist{}}
+	if detail != nil {
+		resp.TrackDetail = *detail
+	} else {
+		resp.TrackDetail = models.TrackDetail{
+			SpotifySongID: spotifyID,
+			Genres:        []string{},
+			Plays:         models.TrackPlaySummary{Recent: []models.TrackPlay{}},
+		}
+	}
+	resp.InLibrary = detail != nil
+
+	var artistIDs []string
+	if track != nil {
+		resp.FromSpotify = true
+		mergeSpotifyTrack(&resp.TrackDetail, track)
+		for _, a := range track.Artists {
+			resp.Artists = append(resp.Artists, TrackArtist{ID: a.ID, Name: a.Name, Genres: []string{}})
+			artistIDs = append(artistIDs, a.ID)
+		}
+	} else if detail.Liked != nil && detail.Liked.ArtistID != "" {
+		resp.Artists = append(resp.Artists, TrackArtist{ID: detail.Liked.ArtistID, Name: detail.ArtistName, Genres: []string{}})
+		artistIDs = append(artistIDs, detail.Liked.ArtistID)
+	}
+
+	// Artist genres come from the artist cache, fetching unseen artists
+	if len(artistIDs) > 0 && tokErr == nil {
+		artists := cronCollector.artists(accessTok, artistIDs)
+		for i, a := range resp.Artists {
+			if cached := artists[a.ID]; cached != nil {
+				resp.Artists[i].Genres = cached.Genres
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// mergeSpotifyTrack fills what the database doesn't have from Spotify
+func mergeSpotifyTrack(d *models.TrackDetail, t *services.TrackDetails) {
+	if d.TrackName == "" {
+		d.TrackName = t.Name
+	}
+	if d.ArtistName == "" && len(t.Artists) > 0 {
+		d.ArtistName = t.Artists[0].Name
+	}
+	if d.AlbumName == "" {
+		d.AlbumName = t.Album.Name
+	}
+	if d.AlbumCoverURL == "" && len(t.Album.Images) > 0 {
+		d.AlbumCoverURL = t.Album.Images[0].URL
+	}
+	if d.DurationMs == 0 {
+		d.DurationMs = t.DurationMs
+	}
+	if d.ISRC == "" {
+		d.ISRC = t.ExternalIDs.ISRC
+	}
+	if d.Explicit == nil {
+		explicit := t.Explicit
+		d.Explicit = &explicit
+	}
+}
@@ -2,28 +2,42 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/jobs"
+	"example.com/spotifydb/internal/metrics"
 	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/notifications"
 	"example.com/spotifydb/internal/repository"
 	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/store"
 	"example.com/spotifydb/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 // Global rate limiter for cron jobs
-var cronRateLimiter *utils.RateLimiter
+var cronRateLimiter = cronCollector.limiter
+
+// Schedule the cron was started with (defaults until StartSpotifyCron runs)
+var cronConfig = config.DefaultCronConfig()
 
 /* ---------- collection statistics ---------- */
-func GetCollectionStats(c *gin.Context) {
+
+// collectionStats computes /collection-stats from scratch; requests are
+// served from collectionStatsCache instead
+func collectionStats() CollectionStatsResponse {
 	now := time.Now()
 
 	// Get counts for different time periods
@@ -64,6 +78,15 @@ func GetCollectionStats(c *gin.Context) {
 		fmt.Printf("Error getting daily stats: %v\n", err)
 	}
 
+	// Gaps in collection over the last 30 days, and any still running
+	var gaps CollectionGapSummary
+	if gaps.Last30Days, err = models.CountCollectionGaps(repository.Pool, now.AddDate(0, 0, -30)); err != nil {
+		fmt.Printf("Error counting collection gaps: %v\n", err)
+	}
+	if gaps.Open, err = models.OpenCollectionGaps(repository.Pool, ""); err != nil {
+		fmt.Printf("Error getting open collection gaps: %v\n", err)
+	}
+
 	// Calculate collection progress toward 6 months
 	sixMonthsTarget := 6 * 30 * 24 * 2 // Rough estimate: 2 songs per hour for 6 months
 	progressPercent := float64(counts["all_time"]) / float64(sixMonthsTarget) * 100
@@ -71,32 +94,73 @@ func GetCollectionStats(c *gin.Context) {
 		progressPercent = 100
 	}
 
-	c.JSON(200, gin.H{
-		"collection_summary": gin.H{
-			"total_tracks_collected":   counts["all_time"],
-			"latest_track_time":        latestTrackInfo,
-			"progress_toward_6_months": fmt.Sprintf("%.1f%%", progressPercent),
+	// Collector health from collector_runs, which the cron writes
+	health := CollectorHealthSummary{Collectors: []models.CollectorHealth{}, RecentCycles: []models.CollectorRun{}}
+	if collectors, err := models.GetCollectorHealth(repository.Pool); err != nil {
+		fmt.Printf("Error getting collector health: %v\n", err)
+	} else {
+		health.Collectors = collectors
+	}
+	if health.APICallsLastHour, health.RateLimitedLast24h, err = models.GetCollectorUsage(repository.Pool, now.Add(-time.Hour), now.AddDate(0, 0, -1)); err != nil {
+		fmt.Printf("Error getting collector API usage: %v\n", err)
+	}
+	if cycles, err := models.GetCollectorRuns(repository.Pool, config.CollectorRecentlyPlayed, 10); err != nil {
+		fmt.Printf("Error getting recent collector runs: %v\n", err)
+	} else {
+		health.RecentCycles = cycles
+	}
+
+	return CollectionStatsResponse{
+		CollectionSummary: CollectionSummary{
+			TotalTracksCollected:  counts["all_time"],
+			LatestTrackTime:       latestTrackInfo,
+			ProgressToward6Months: fmt.Sprintf("%.1f%%", progressPercent),
 		},
-		"track_counts_by_period":       counts,
-		"daily_breakdown_last_30_days": dailyStats,
-		"collection_tips": []string{
+		TrackCountsByPeriod:  counts,
+		DailyBreakdownLast30: dailyStats,
+		CollectionTips: []string{
 			"Keep the app running to continuously collect tracks",
-			"The system checks every 1.5 minutes during active hours (6 AM - 11 PM)",
+			fmt.Sprintf("The system checks every %v during active hours (%d:00 - %d:59)",
+				cronConfig.ActiveInterval, cronConfig.ActiveStartHour, cronConfig.ActiveEndHour),
 			"Spotify only stores ~50 recent tracks, so continuous collection is essential",
 			"You'll have meaningful 6-month data after running for a few months",
 		},
-	})
+		Gaps:   gaps,
+		Health: health,
+	}
 }
 
 /* ---------- enhanced background ticker ---------- */
-func StartSpotifyCron() {
-	// Initialize rate limiter for cron jobs
-	cronRateLimiter = utils.NewRateLimiter()
+func StartSpotifyCron(cfg config.CronConfig, notify config.NotifyConfig, collector *Collector) {
+	cronConfig = cfg
+	cronCollector = collector
+	cronRunning.Store(true)
+	election := newLeaderElection(cfg.LeaderLock, cfg.LockDatabaseURL)
+	notifyConfig = notify
+	notifier = notifications.FromConfig(notify)
+	// Share the collector's rate limiter with the other cron jobs
+	cronRateLimiter = collector.limiter
+	// Let the Spotify client refresh and retry once when a token expires mid-cycle
+	services.SetTokenRefresher(collector.accessToken)
+	initWriteBuffer()
 	fmt.Println("🚀 Starting Spotify cron with rate limiting protection")
+	fmt.Printf("⏰ Schedule: every %v during active hours (%d:00-%d:59), every %v otherwise\n",
+		cfg.ActiveInterval, cfg.ActiveStartHour, cfg.ActiveEndHour, cfg.IdleInterval)
+	if notifier.Enabled() {
+		fmt.Println("🔔 Alerts enabled")
+	}
+	if lastFM != nil {
+		fmt.Println("📡 Scrobbling new plays to Last.fm")
+	}
+	if listenBrainz != nil {
+		fmt.Println("🧠 Submitting new plays to ListenBrainz")
+	}
 	// Check if we need to do initial historical fetch
 	go func() {
 		time.Sleep(5 * time.Second) // Wait for server to start up
 
+		collector.verifyAccount()
+
 		hasData, err := repository.HasHistoricalData()
 		if err != nil {
 			fmt.Printf("Error checking historical data: %v\n", err)
@@ -106,7 +170,7 @@ func StartSpotifyCron() {
 		if !hasData {
 			fmt.Println("🎵 No historical data found. Starting continuous collection...")
 			fmt.Println("📊 Spotify's recently played API only stores ~50 tracks at a time.")
-			fmt.Println("⏰ Running every 2 minutes to ensure we don't miss any tracks.")
+			fmt.Printf("⏰ Running every %v to ensure we don't miss any tracks.\n", cfg.ActiveInterval)
 			fmt.Println("📈 Your listening history will build up over time!")
 		} else {
 			// Show some stats about existing data
@@ -117,83 +181,225 @@ func StartSpotifyCron() {
 				}
 			}
 		}
+
+		// Recover what Spotify still has from while the server was down
+		if hasData && cfg.Enabled(config.CollectorRecentlyPlayed) && election.lead() {
+			collectMu.Lock()
+			collector.CatchUp()
+			collectMu.Unlock()
+		}
 	}()
 
 	// Adaptive frequency: run more often during likely listening hours
 	go func() {
+		cycle := 0
+		// Low-priority jobs that came due but were deferred for lack of budget
+		due := map[string]bool{}
+		runLowPriority := func(name string, every int, run func()) {
+			if !cfg.Enabled(name) {
+				return
+			}
+			if cycle%every == 0 {
+				due[name] = true
+			}
+			if due[name] && hasSpareBudget(cfg, name) {
+				run()
+				delete(due, name)
+			}
+		}
+
 		for {
-			now := time.Now()
-			hour := now.Hour()
+			time.Sleep(cfg.IntervalAt(time.Now()))
+			cycle++
+			cycleStart := time.Now()
 
-			var interval time.Duration
+			// Flush anything buffered during a database outage before collecting more
+			ReplayWriteBuffer()
 
-			// More frequent during typical listening hours (6 AM - 11 PM)
-			if hour >= 6 && hour <= 23 {
-				interval = 5 * time.Minute // Every 5 minutes during active hours (reduced to save data transfer)
-			} else {
-				interval = 15 * time.Minute // Every 15 minutes during sleep hours
+			// Only the instance holding the collector lock polls Spotify
+			if !election.lead() {
+				continue
 			}
 
-			time.Sleep(interval)
-			CollectRecentTracks()
-			CollectSavedTracks()
-			GetCurrentlyPLaying()
+			if cfg.Enabled(config.CollectorRecentlyPlayed) {
+				collectMu.Lock()
+				recordCollectorRun(config.CollectorRecentlyPlayed, collector.CollectRecentTracks)
+				collectMu.Unlock()
+				collector.checkCollectionStalled()
+			}
+			if cfg.Enabled(config.CollectorSkipInference) {
+				recordCollectorTask(config.CollectorSkipInference, InferSkips)
+			}
+			ScrobblePending()
+			SubmitListens()
+			if cfg.Enabled(config.CollectorSavedTracks) && cycle%cfg.SavedTracksEvery == 0 {
+				collectMu.Lock()
+				recordCollectorRun(config.CollectorSavedTracks, collector.CollectSavedTracks)
+				collectMu.Unlock()
+			}
+			if cfg.Enabled(config.CollectorLikedReconcile) && cycle%cfg.LikedReconcileEvery == 0 {
+				recordCollectorTask(config.CollectorLikedReconcile, collector.ReconcileSavedTracks)
+			}
+			if cfg.Enabled(config.CollectorNowPlaying) {
+				recordCollectorRun(config.CollectorNowPlaying, func() (run CollectorRun) {
+					state, err := GetCurrentlyPLaying()
+					if err != nil {
+						run.Error = err.Error()
+					} else if state != nil && state.Item.ID != "" {
+						run.Fetched = 1
+					}
+					return run
+				})
+			}
+			if cfg.Enabled(config.CollectorQueue) {
+				recordCollectorRun(config.CollectorQueue, CaptureQueue)
+			}
 
-			// Only update genres every 6th run (every 30 minutes instead of every 5 minutes)
-			// This significantly reduces database queries
-			if time.Now().Minute()%30 == 0 {
-				GetGenreOfRecentlyLiked(50) // Reduced from 150 to 50 tracks per run
+			if cfg.Enabled(config.CollectorArtistRefresh) && cycle%cfg.ArtistRefreshEvery == 0 {
+				recordCollectorRun(config.CollectorArtistRefresh, func() CollectorRun {
+					return CollectorRun{Inserted: RefreshStaleArtists()}
+				})
 			}
 
+			if cfg.Enabled(config.CollectorCanonical) && cycle%cfg.CanonicalEvery == 0 {
+				recordCollectorTask(config.CollectorCanonical, ResolveCanonicalTracks)
+			}
+			if cfg.Enabled(config.CollectorMusicBrainz) && cycle%cfg.MusicBrainzEvery == 0 {
+				recordCollectorTask(config.CollectorMusicBrainz, func() { RefreshExternalMetadata(cfg.MusicBrainzBatch) })
+			}
+
+			if cfg.Enabled(config.CollectorDiscovery) && cycle%cfg.DiscoveryEvery == 0 {
+				recordCollectorTask(config.CollectorDiscovery, RefreshDiscoveryFeed)
+			}
+
+			if cfg.Enabled(config.CollectorTracksOnRepeat) && cycle%cfg.TracksOnRepeatEvery == 0 {
+				recordCollectorTask(config.CollectorTracksOnRepeat, SyncTracksOnRepeat)
+			}
+
+			if cfg.Enabled(config.CollectorMilestones) && cycle%cfg.MilestonesEvery == 0 {
+				recordCollectorTask(config.CollectorMilestones, DetectMilestones)
+			}
+			if cfg.Enabled(config.CollectorGapDetection) && cycle%cfg.GapsEvery == 0 {
+				recordCollectorTask(config.CollectorGapDetection, DetectCollectionGaps)
+			}
+
+			if cfg.Enabled(config.CollectorDailyReport) {
+				RunDailyReport()
+			}
+
+			// Backfills go last and only run with budget left over, so they never
+			// delay collection; a deferred one retries every cycle until it runs
+			runLowPriority(config.CollectorGenreBackfill, cfg.GenreBackfillEvery, func() {
+				recordCollectorRun(config.CollectorGenreBackfill, func() CollectorRun {
+					return CollectorRun{Inserted: GetGenreOfRecentlyLiked(cfg.GenreBatchSize)}
+				})
+			})
+			runLowPriority(config.CollectorTrackBackfill, cfg.TrackBackfillEvery, func() {
+				recordCollectorTask(config.CollectorTrackBackfill, func() { BackfillTrackData(cfg.TrackBackfillBatch) })
+			})
+			runLowPriority(config.CollectorReleaseRadar, cfg.ReleaseRadarEvery, func() {
+				recordCollectorTask(config.CollectorReleaseRadar, func() {
+					RefreshReleaseRadar(cfg.ReleaseRadarArtists, cfg.ReleaseRadarAfter)
+				})
+			})
+
+			// Recompute /collection-stats once per cycle rather than per request
+			refreshCollectionStats()
+
+			metrics.CronCycleDuration.Observe(time.Since(cycleStart).Seconds())
 		}
 	}()
 }
 
-func CollectRecentTracks() {
-	refreshTok, err := repository.GetRefreshToken()
-	if err != nil || refreshTok == "" {
-		// Only log this once per hour to avoid spam
-		if time.Now().Minute() == 0 {
-			fmt.Println("cron: no refresh token stored yet")
-		}
-		return
+var errNoRefreshToken = errors.New("no refresh token stored yet")
+
+// hasSpareBudget reports whether the shared Spotify budget can take a
+// low-priority job right now, logging when it can't
+func hasSpareBudget(cfg config.CronConfig, job string) bool {
+	spare := services.SpareRequests()
+	if spare >= cfg.BackfillMinSpare {
+		return true
 	}
+	fmt.Printf("⏸️  %s deferred: %d spare requests, need %d\n", job, spare, cfg.BackfillMinSpare)
+	return false
+}
 
-	accessTok, newRefresh, err := services.RefreshAccessToken(refreshTok)
+// BackfillTrackData fills missing album covers and genres on plays for up to
+// batchSize tracks, sharing the cron's rate limiter
+func BackfillTrackData(batchSize int) {
+	accessTok, err := getCronAccessToken()
 	if err != nil {
-		fmt.Println("cron: refresh error:", err)
+		fmt.Println("BackfillTrackData:", err)
 		return
 	}
-	if newRefresh != nil && *newRefresh != refreshTok {
-		_ = repository.SaveOrUpdateRefreshToken(*newRefresh)
+	updated, err := models.BackfillMissingTrackData(context.Background(), accessTok, batchSize, cronRateLimiter)
+	if err != nil {
+		fmt.Println("BackfillTrackData:", err)
+		return
+	}
+	if updated > 0 {
+		fmt.Printf("🖼️  Backfilled covers/genres for %d tracks\n", updated)
+	}
+}
+
+// getCronAccessToken returns an access token via the cron's collector
+func getCronAccessToken() (string, error) {
+	return cronCollector.accessToken()
+}
+
+// CollectRecentTracks stores the plays newer than the newest stored one and
+// returns what it did
+func (col *Collector) CollectRecentTracks() (run CollectorRun) {
+	accessTok, err := col.accessToken()
+	if err != nil {
+		run.Error = err.Error()
+		// Only log a missing token once per hour to avoid spam
+		if !errors.Is(err, errNoRefreshToken) || time.Now().Minute() == 0 {
+			fmt.Println("cron:", err)
+		}
+		// Nothing is stored yet on a fresh install; that isn't a failure
+		if !errors.Is(err, errNoRefreshToken) {
+			col.collectionFailed(err)
+		}
+		return run
 	}
 
 	// Get the latest timestamp from our database to avoid duplicates
-	latestTime, err := repository.GetLatestPlayedAt()
+	latestTime, err := col.store.LatestPlayedAt()
 	if err != nil {
 		fmt.Printf("cron: error getting latest timestamp: %v\n", err)
 		latestTime = time.Time{} // Start from beginning if error
 	}
 
-	// Get recent tracks from Spotify with rate limiting
+	// Only fetch plays newer than the latest stored one (Spotify's after cursor)
 	var items []services.PlayedItem
-	err = cronRateLimiter.RetryWithBackoff(func() error {
-		items, err = services.GetRecentlyPlayed(accessTok, 50)
+	err = col.limiter.RetryWithBackoff(func() error {
+		items, err = col.spotify.GetRecentlyPlayedAfter(context.Background(), accessTok, latestTime)
 		return err
 	}, 2) // Max 2 retries for cron job
 	if err != nil {
 		fmt.Println("cron: recently-played error after retries:", err)
-		return
+		col.collectionFailed(err)
+		run.Error = err.Error()
+		return run
 	}
+	recordCollectorSuccess(config.CollectorRecentlyPlayed)
+	col.collectionRecovered()
 
+	return col.storePlayedItems(accessTok, items, latestTime, models.SourceCron)
+}
+
+// storePlayedItems stores the plays newer than latestTime, tagged with
+// source, and returns how many were fetched, new and already stored
+func (col *Collector) storePlayedItems(accessTok string, items []services.PlayedItem, latestTime time.Time, source string) CollectorRun {
 	if len(items) == 0 {
-		return // No tracks to process
+		return CollectorRun{} // No tracks to process
 	}
 
-	success := 0
 	skipped := 0
 	var newestTrack, oldestTrack time.Time
 
+	fresh := make([]services.PlayedItem, 0, len(items))
 	for i, it := range items {
 		// Track the range of tracks we're processing
 		if i == 0 {
@@ -208,58 +414,39 @@ func CollectRecentTracks() {
 			skipped++
 			continue
 		}
+		fresh = append(fresh, it)
+	}
+	fresh, duplicates := col.dropNearDuplicates(fresh, latestTime)
+	skipped += duplicates
 
-		artist := ""
-		genre := ""
-		albumCoverURL := ""
+	// Resolve every artist up front so the cycle costs one cache read and at
+	// most a couple of Spotify calls instead of one per play
+	artists := col.artists(accessTok, primaryArtistIDs(fresh))
+	plays := make([]store.Play, len(fresh))
+	for i, it := range fresh {
+		plays[i] = playFromItem(it, artists)
+		plays[i].Source = source
+	}
 
-		if len(it.Track.Artists) > 0 {
-			artistID := it.Track.Artists[0].ID
-			var artistObj *services.Artist
-			err := cronRateLimiter.RetryWithBackoff(func() error {
-				artistObj, err = services.GetArtistById(accessTok, artistID)
-				return err
-			}, 1) // Only 1 retry for cron to avoid delays
-			
-			if err != nil {
-				if utils.IsRateLimitError(err) {
-					log.Printf("Cron: Rate limited on artist %s, skipping genre", artistID)
-				} else {
-					log.Printf("Cron: Failed to fetch artist %s: %v", artistID, err)
-				}
-			} else if artistObj != nil {
-				artist = artistObj.Name
-				if len(artistObj.Genres) > 0 {
-					genre = strings.Join(artistObj.Genres, ", ")
-				}
-			}
-		}
+	stored, buffered := col.insertPlays(plays)
+	success := len(stored)
+	emitTracksCollected(stored)
+	queueScrobbles(stored)
 
-		if len(it.Track.Album.Images) > 0 {
-			albumCoverURL = it.Track.Album.Images[0].URL
+	// Devices only show up in player snapshots, so match new plays against now_playing_log
+	if success > 0 {
+		if _, err := col.store.AttachPlaybackState(); err != nil {
+			fmt.Printf("cron: %v\n", err)
 		}
+	}
 
-		// checks for existing track
-
-		err = models.InsertRecentlyPlayed(
-			it.Track.ID,
-			it.Track.Name,
-			artist,
-			it.Track.Album.Name,
-			albumCoverURL,
-			genre,
-			it.Track.DurationMs,
-			it.PlayedAt,
-		)
-		if err != nil {
-			// Only log errors that aren't duplicate key violations
-			if !strings.Contains(err.Error(), "duplicate") && !strings.Contains(err.Error(), "unique") {
-				fmt.Printf("cron: insert error for %s: %v\n", it.Track.Name, err)
-			}
-		} else {
-			success++
-		}
+	if buffered > 0 {
+		fmt.Printf("📼 database unavailable, buffered %d plays to %s\n", buffered, writeBuffer.Path())
 	}
+	metrics.TracksCollected.WithLabelValues(config.CollectorRecentlyPlayed, "buffered").Add(float64(buffered))
+
+	metrics.TracksCollected.WithLabelValues(config.CollectorRecentlyPlayed, "inserted").Add(float64(success))
+	metrics.TracksCollected.WithLabelValues(config.CollectorRecentlyPlayed, "skipped").Add(float64(skipped))
 
 	// Only log when we actually find new tracks
 	if success > 0 {
@@ -269,22 +456,144 @@ func CollectRecentTracks() {
 			newestTrack.Format("15:04:05"),
 			time.Now().Format(time.Kitchen))
 	}
+	return CollectorRun{
+		Fetched:  len(items),
+		Inserted: success,
+		Buffered: buffered,
+		Skipped:  len(items) - success - buffered,
+	}
 }
 
-/* ---------- route to accept refresh token from Next.js ---------- */
-func SaveRefresh(c *gin.Context) {
-	var body struct {
-		RefreshToken string `json:"refresh_token"`
+// dropNearDuplicates removes plays of a track within CRON_DEDUP_WINDOW of
+// the previous play of it, in items or already stored, keeping the earliest.
+// Spotify sometimes reports one listen twice, a second or two apart, after a
+// reconnect. items are newest first, and so is the result.
+func (col *Collector) dropNearDuplicates(items []services.PlayedItem, latestTime time.Time) ([]services.PlayedItem, int) {
+	window := cronConfig.DedupWindow
+	if window <= 0 || len(items) == 0 {
+		return items, 0
+	}
+
+	kept := make([]services.PlayedItem, 0, len(items))
+	previous := map[string]time.Time{}
+	for i := len(items) - 1; i >= 0; i-- {
+		it := items[i]
+		prev, seen := previous[it.Track.ID]
+		previous[it.Track.ID] = it.PlayedAt
+		if seen && it.PlayedAt.Sub(prev) <= window {
+			continue
+		}
+		// Only the oldest new plays can be that close to a stored one
+		if !seen && !latestTime.IsZero() && it.PlayedAt.Sub(latestTime) <= window {
+			near, err := col.store.HasPlayNear(it.Track.ID, it.PlayedAt, window)
+			if err != nil {
+				fmt.Printf("cron: duplicate check failed for %s: %v\n", it.Track.Name, err)
+			} else if near {
+				continue
+			}
+		}
+		kept = append(kept, it)
+	}
+	slices.Reverse(kept)
+
+	dropped := len(items) - len(kept)
+	if dropped > 0 {
+		fmt.Printf("🧹 ignored %d near-duplicate plays\n", dropped)
+	}
+	return kept, dropped
+}
+
+// insertPlays writes plays in one batch, buffering them to disk while
+// Postgres is unreachable. Any other batch error is retried row by row so a
+// single bad play doesn't cost the rest. Returns the plays that were new.
+func (col *Collector) insertPlays(plays []store.Play) (stored []store.Play, buffered int) {
+	if len(plays) == 0 {
+		return nil, 0
+	}
+
+	stored, err := col.store.InsertRecentlyPlayedBatch(plays)
+	if err == nil {
+		return stored, 0
+	}
+
+	if repository.IsUnavailable(err) && writeBuffer != nil {
+		// Postgres is unreachable: keep the plays on disk until it comes back
+		for _, play := range plays {
+			if bufErr := writeBuffer.Append(play); bufErr != nil {
+				fmt.Printf("cron: insert error for %s: %v (buffering failed: %v)\n", play.TrackName, err, bufErr)
+			} else {
+				buffered++
+			}
+		}
+		return nil, buffered
+	}
+
+	fmt.Printf("cron: batch insert error, retrying one by one: %v\n", err)
+	stored = nil
+	for _, play := range plays {
+		// inserted can be true with an error about the play's details: the
+		// play itself is stored, so it still counts
+		inserted, err := col.store.InsertRecentlyPlayed(play)
+		if err != nil && !repository.IsUniqueViolation(err) {
+			fmt.Printf("cron: insert error for %s: %v\n", play.TrackName, err)
+		}
+		if inserted {
+			stored = append(stored, play)
+		}
 	}
+	return stored, 0
+}
+
+/* ---------- route to accept refresh token from Next.js ---------- */
+type SaveRefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// SaveRefresh stores a refresh token after checking it with Spotify: a token
+// Spotify rejects, or one without services.RequiredScopes, is refused with
+// 400. The account's profile and scopes are recorded alongside it.
+func (a *API) SaveRefresh(c *gin.Context) {
+	var body SaveRefreshRequest
 	if err := c.ShouldBindJSON(&body); err != nil || body.RefreshToken == "" {
-		c.JSON(400, gin.H{"msg": "no token"})
+		badRequest(c, "refresh_token is required")
 		return
 	}
-	if err := repository.SaveOrUpdateRefreshToken(body.RefreshToken); err != nil {
-		c.JSON(500, gin.H{"msg": "db error"})
+
+	grant, err := a.spotify.ExchangeRefreshToken(c.Request.Context(), body.RefreshToken)
+	if errors.Is(err, services.ErrUnauthorized) {
+		RespondError(c, http.StatusBadRequest, CodeBadRequest,
+			"Spotify rejected this refresh token; authorize again", err.Error())
+		return
+	}
+	if err != nil {
+		spotifyError(c, err)
 		return
 	}
-	c.JSON(200, gin.H{"msg": "saved"})
+	if err := checkScopes(grant.Scopes); err != nil {
+		RespondError(c, http.StatusBadRequest, CodeBadRequest, err.Error(),
+			gin.H{"granted": grant.Scopes, "required": services.RequiredScopes})
+		return
+	}
+
+	token := body.RefreshToken
+	if grant.RefreshToken != nil {
+		token = *grant.RefreshToken
+	}
+	if err := a.store.SaveRefreshToken(token); err != nil {
+		internalError(c, err)
+		return
+	}
+	rememberRefreshToken(token, true)
+
+	resp := SaveRefreshResponse{Msg: "saved"}
+	account, err := saveAccount(c.Request.Context(), a.store, a.spotify, grant)
+	if err != nil {
+		fmt.Printf("⚠️  Saved the refresh token but not its account: %v\n", err)
+		resp.Warning = "the token was saved, but its profile couldn't be recorded: " + err.Error()
+	} else {
+		resp.Account = &account
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // depricating due to getting rid of that table
@@ -313,121 +622,305 @@ func SaveRefresh(c *gin.Context) {
 // 	context.JSON(http.StatusCreated, gin.H{"message": "created track", "track": track})
 // }
 
-// update tracks
+type UpdateTrackRequest struct {
+	Mood     *string `json:"mood"`
+	Activity *string `json:"activity"`
+	// Deprecated: play counts are computed from recently_played; ignored
+	PlayCount *int `json:"play_count,omitempty"`
+}
+
+// update the mood/activity tags of a track on repeat. play_count, first_played
+// and last_played are recomputed from recently_played by SyncTracksOnRepeat,
+// so a play_count in the body is ignored.
 func UpdateTrack(context *gin.Context) {
 	spotifyID := context.Param("spotify_song_id")
 
-	var updateData struct {
-		PlayCount int `json:"play_count"`
-	}
+	var updateData UpdateTrackRequest
 
 	if err := context.ShouldBindJSON(&updateData); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "invalid JSON"})
+		badRequest(context, "invalid JSON")
 		return
 	}
-	fmt.Printf("PATCH request received for song ID: %s\n", spotifyID)
-	fmt.Printf("Incoming update data: %+v\n", updateData)
+	if updateData.PlayCount != nil {
+		context.Header("Deprecation", "true")
+		context.Header("Link", `</stats/most-played>; rel="successor-version"`)
+		log.Printf("UpdateTrack: PATCH play_count is deprecated and ignored, use GET /stats/most-played (song %s)", spotifyID)
+	}
+	if updateData.Mood == nil && updateData.Activity == nil {
+		badRequest(context, "nothing to update (expected mood and/or activity)")
+		return
+	}
+
 	// get the existing track from db
 	existingTrack, err := models.GetSingleTrack(repository.Pool, spotifyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		notFound(context, "track not found")
+		return
+	}
 	if err != nil {
-		context.JSON(http.StatusNotFound, gin.H{"message:": "track not found!"})
+		internalError(context, err)
 		return
 	}
 
 	// update the fields
-	existingTrack.PlayCount = updateData.PlayCount
+	if updateData.Mood != nil {
+		existingTrack.Mood = strings.TrimSpace(*updateData.Mood)
+	}
+	if updateData.Activity != nil {
+		existingTrack.Activity = strings.TrimSpace(*updateData.Activity)
+	}
 
 	// Save back to DB
 	if err := existingTrack.UpdateTrackDB(repository.Pool); err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "could not update track"})
+		internalError(context, fmt.Errorf("could not update track: %v", err))
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"message": "track updated"})
+	context.JSON(http.StatusOK, MessageResponse{Message: "track updated"})
 }
 
-func RecentlyPlayedTracks(context *gin.Context) {
-	recentPlayedTracks, err := models.GetAllRecentPlayedHistory(repository.Pool)
-	if err != nil {
-		fmt.Println("ERROR HERE IN HANDLERS:", err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to fetch recently played tracks",
-			"details": err.Error(),
-		})
-		return
-	}
-	context.JSON(http.StatusOK, gin.H{
-		"tracks":  recentPlayedTracks,
-		"count":   len(recentPlayedTracks),
-		"message": "succescfully retrieved tracks",
-	})
-
+// BulkUpdateTrackItem is one track of a bulk update: UpdateTrackRequest plus the track ID
+type BulkUpdateTrackItem struct {
+	SpotifySongID string `json:"spotify_song_id"`
+	UpdateTrackRequest
 }
 
-// this is the now listeing to endpoint call
-func NowListeningToTrack(context *gin.Context) {
-	refreshTok, err := repository.GetRefreshToken()
-	if err != nil || refreshTok == "" {
-		fmt.Println("NowListeningToTrack: no refresh token stored yet")
+// maxBulkTrackUpdates caps the items of one bulk update
+const maxBulkTrackUpdates = 500
+
+// Per-item results of a bulk update
+const (
+	bulkUpdated  = "updated"
+	bulkNotFound = "not_found"
+	bulkInvalid  = "invalid"
+)
 
+// BulkUpdateTracks sets the mood/activity tags of many tracks on repeat in one
+// transaction. Invalid items and unknown tracks are reported per item and
+// don't stop the others; a database error rolls back every item.
+// PATCH /mostPlayedTracks/bulk [{"spotify_song_id": "...", "mood": "calm"}, ...]
+func BulkUpdateTracks(context *gin.Context) {
+	var items []BulkUpdateTrackItem
+	if err := context.ShouldBindJSON(&items); err != nil {
+		badRequest(context, "invalid JSON (expected an array of updates)")
+		return
+	}
+	if len(items) == 0 || len(items) > maxBulkTrackUpdates {
+		badRequest(context, fmt.Sprintf("expected 1 to %d updates, got %d", maxBulkTrackUpdates, len(items)))
+		return
 	}
 
-	// Exchange for access token
-	accessTok, newRefresh, err := services.RefreshAccessToken(refreshTok)
+	results := make([]BulkTrackResult, len(items))
+	var updates []models.TrackTagUpdate
+	var indexes []int
+	deprecated := false
+	for i, item := range items {
+		id := strings.TrimSpace(item.SpotifySongID)
+		results[i] = BulkTrackResult{SpotifySongID: id}
+		if item.PlayCount != nil {
+			deprecated = true
+		}
+		switch {
+		case id == "":
+			results[i].Status, results[i].Error = bulkInvalid, "spotify_song_id is required"
+			continue
+		case item.Mood == nil && item.Activity == nil:
+			results[i].Status, results[i].Error = bulkInvalid, "nothing to update (expected mood and/or activity)"
+			continue
+		}
+		u := models.TrackTagUpdate{SpotifySongID: id}
+		if item.Mood != nil {
+			mood := strings.TrimSpace(*item.Mood)
+			u.Mood = &mood
+		}
+		if item.Activity != nil {
+			activity := strings.TrimSpace(*item.Activity)
+			u.Activity = &activity
+		}
+		updates = append(updates, u)
+		indexes = append(indexes, i)
+	}
+	if deprecated {
+		context.Header("Deprecation", "true")
+		context.Header("Link", `</stats/most-played>; rel="successor-version"`)
+	}
 
-	if err != nil {
-		fmt.Print("error retrieving currently RefreshAccessToken ")
+	if len(updates) > 0 {
+		found, err := models.UpdateTrackTags(context.Request.Context(), repository.Pool, updates)
+		if err != nil {
+			internalError(context, fmt.Errorf("could not update tracks: %v", err))
+			return
+		}
+		for j, i := range indexes {
+			if found[j] {
+				results[i].Status = bulkUpdated
+			} else {
+				results[i].Status, results[i].Error = bulkNotFound, "track not found"
+			}
+		}
 	}
 
-	if newRefresh != nil && *newRefresh != refreshTok {
-		_ = repository.SaveOrUpdateRefreshToken(*newRefresh)
+	resp := BulkUpdateTracksResponse{Results: results}
+	for _, r := range results {
+		switch r.Status {
+		case bulkUpdated:
+			resp.Updated++
+		case bulkNotFound:
+			resp.NotFound++
+		case bulkInvalid:
+			resp.Invalid++
+		}
 	}
+	context.JSON(http.StatusOK, resp)
+}
 
-	listeingTrack, err := services.GetCurrentlyListening(accessTok)
+// SyncTracksOnRepeat recomputes the tracks_on_repeat counters from plays
+func SyncTracksOnRepeat() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	n, err := models.SyncTracksOnRepeat(ctx)
 	if err != nil {
-		fmt.Printf("error getting currently listening to: %v\n", err)
+		fmt.Println("cron:", err)
+		return
 	}
+	recordCollectorSuccess(config.CollectorTracksOnRepeat)
+	if n > 0 {
+		fmt.Printf("🔁 updated %d tracks on repeat\n", n)
+	}
+}
 
-	context.JSON(http.StatusOK, gin.H{
-		"data":    listeingTrack,
-		"message": "success",
+func (a *API) RecentlyPlayedTracks(context *gin.Context) {
+	source, err := parseSource(context)
+	if err != nil {
+		badRequest(context, err.Error())
+		return
+	}
+	recentPlayedTracks, err := a.store.RecentlyPlayed(source)
+	if err != nil {
+		internalError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, RecentlyPlayedResponse{
+		Tracks:  recentPlayedTracks,
+		Count:   len(recentPlayedTracks),
+		Message: "succescfully retrieved tracks",
 	})
 
 }
 
-// function to get all of the recentlyLIked tracks endpoint
+// this is the now listeing to endpoint call
+// Responds {"is_playing": false} when nothing is playing, 401 when no usable
+// Spotify credentials are stored and 503 when Spotify can't be reached.
+func NowListeningToTrack(context *gin.Context) {
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		spotifyError(context, err)
+		return
+	}
 
-func RecentlyLiked(context *gin.Context) {
-	data := models.CollectRecentlyLiked()
+	state, err := services.GetCurrentlyListening(context.Request.Context(), accessTok)
+	if err != nil {
+		spotifyError(context, err)
+		return
+	}
+	if !state.HasTrack() {
+		context.JSON(http.StatusOK, NowPlayingResponse{})
+		return
+	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"data":    data,
-		"count":   len(data),
-		"message": "Successfully retrieved recently liked tracks",
+	track := &NowPlayingTrack{
+		SpotifySongID: state.Item.ID,
+		TrackName:     state.Item.Name,
+		Artists:       []string{},
+		AlbumName:     state.Item.Album.Name,
+	}
+	for _, a := range state.Item.Artists {
+		track.Artists = append(track.Artists, a.Name)
+	}
+	if len(state.Item.Album.Images) > 0 {
+		track.AlbumCoverURL = state.Item.Album.Images[0].URL
+	}
+	context.JSON(http.StatusOK, NowPlayingResponse{
+		IsPlaying:    state.IsPlaying,
+		Track:        track,
+		ProgressMs:   state.ProgressMS,
+		DurationMs:   state.Item.DurationMs,
+		Device:       state.Device,
+		Context:      state.Context,
+		ShuffleState: state.ShuffleState,
+		RepeatState:  state.RepeatState,
+		Data:         state,
+		Message:      "success",
 	})
 }
 
-// function to get all saved tracks
-func CollectSavedTracks() {
-	// Get refresh token
-	refreshTok, err := repository.GetRefreshToken()
-	if err != nil || refreshTok == "" {
-		fmt.Println("CollectSavedTracks: no refresh token stored yet")
+// RecentlyLiked pages through saved tracks; sorting and the genre/artist/album
+// type/source filters are applied in SQL
+func RecentlyLiked(context *gin.Context) {
+	q := models.LikedQuery{
+		Limit:     parseLimit(context, 50, 500),
+		Sort:      context.DefaultQuery("sort", "added_at"),
+		Genre:     context.Query("genre"),
+		Artist:    context.Query("artist"),
+		AlbumType: context.Query("album_type"),
+
+		IncludeRemoved: context.Query("include_removed") == "true",
+	}
+	var err error
+	if q.Source, err = parseSource(context); err != nil {
+		badRequest(context, err.Error())
+		return
+	}
+	if _, ok := models.LikedSorts[q.Sort]; !ok {
+		badRequest(context, fmt.Sprintf("invalid 'sort' %q (expected added_at, popularity or release_date)", q.Sort))
 		return
 	}
+	switch order := context.DefaultQuery("order", "desc"); order {
+	case "asc":
+		q.Ascending = true
+	case "desc":
+	default:
+		badRequest(context, fmt.Sprintf("invalid 'order' %q (expected asc or desc)", order))
+		return
+	}
+	if v := context.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			badRequest(context, "invalid 'offset' (expected a non-negative integer)")
+			return
+		}
+		q.Offset = offset
+	}
 
-	// Exchange for access token
-	accessTok, newRefresh, err := services.RefreshAccessToken(refreshTok)
+	data, total, err := models.ListRecentlyLiked(repository.Pool, q)
 	if err != nil {
-		fmt.Println("CollectSavedTracks: refresh error:", err)
+		internalError(context, err)
 		return
 	}
-	if newRefresh != nil && *newRefresh != refreshTok {
-		_ = repository.SaveOrUpdateRefreshToken(*newRefresh)
+
+	context.JSON(http.StatusOK, RecentlyLikedResponse{
+		Data:    data,
+		Count:   len(data),
+		Total:   total,
+		Limit:   q.Limit,
+		Offset:  q.Offset,
+		HasMore: q.Offset+len(data) < total,
+		Message: "Successfully retrieved recently liked tracks",
+	})
+}
+
+// CollectSavedTracks stores the tracks saved since the newest stored one and
+// returns what it did
+func (col *Collector) CollectSavedTracks() (run CollectorRun) {
+	accessTok, err := col.accessToken()
+	if err != nil {
+		fmt.Println("CollectSavedTracks:", err)
+		run.Error = err.Error()
+		return run
 	}
 
 	// Get latest added_at timestamp from DB
-	latestAddedAt, err := repository.GetLatestAddedAt()
+	latestAddedAt, err := col.store.LatestAddedAt()
 	if err != nil {
 		fmt.Printf("⚠️ Error getting latest added_at: %v\n", err)
 		latestAddedAt = time.Time{}
@@ -435,24 +928,28 @@ func CollectSavedTracks() {
 
 	success := 0
 	skipped := 0
+	existing := 0
 	var newest, oldest time.Time
 
 	offset := 0
 	limit := 50
+	fetchFailed := false
 
 	for {
 		var page *services.UserSavedTracks
-		err := cronRateLimiter.RetryWithBackoff(func() error {
-			page, err = services.GetUserSavedTracksPage(accessTok, offset, limit)
+		err := col.limiter.RetryWithBackoff(func() error {
+			page, err = col.spotify.GetUserSavedTracksPage(context.Background(), accessTok, offset, limit)
 			return err
 		}, 2) // Max 2 retries for cron
-		
+
 		if err != nil {
-			if utils.IsRateLimitError(err) {
+			if services.IsRateLimited(err) {
 				fmt.Printf("⚠️  Cron: Rate limited on saved tracks, pausing collection\n")
 			} else {
 				fmt.Printf("❌ Failed to fetch saved tracks after retries: %v\n", err)
 			}
+			run.Error = err.Error()
+			fetchFailed = true
 			break
 		}
 
@@ -461,6 +958,7 @@ func CollectSavedTracks() {
 		}
 
 		for _, item := range page.Items {
+			run.Fetched++
 			parsedAddedAt, err := time.Parse(time.RFC3339, item.AddedAt)
 			if err != nil {
 				continue
@@ -472,38 +970,19 @@ func CollectSavedTracks() {
 				goto DONE
 			}
 
-			track := item.Track
-			if len(track.Artists) == 0 || len(track.Album.Images) == 0 {
+			liked, ok := likedTrackFrom(item.Track, parsedAddedAt)
+			if !ok {
+				skipped++
 				continue // skip incomplete data
 			}
 
-			artist := track.Artists[0]
-			album := track.Album
-			image := album.Images[0]
-
-			err = models.InsertRecentlyLiked(
-				track.ID,
-				track.Name,
-				strconv.Itoa(track.Popularity),
-				album.Name,
-				album.AlbumType,
-				image.URL,
-				album.ReleaseDate,
-				album.ReleaseDatePrecision,
-				artist.Name,
-				artist.ID,
-				artist.Href,
-				artist.URI,
-				album.TotalTracks,
-				image.Width,
-				image.Height,
-				parsedAddedAt,
-			)
-			if err != nil {
-				if !strings.Contains(err.Error(), "duplicate") {
-					fmt.Printf("InsertRecentlyLiked error: %v\n", err)
-				}
-			} else {
+			inserted, err := col.store.InsertRecentlyLiked(liked)
+			switch {
+			case err != nil:
+				fmt.Printf("InsertRecentlyLiked error: %v\n", err)
+			case !inserted:
+				existing++
+			default:
 				success++
 				if success == 1 {
 					newest = parsedAddedAt
@@ -517,9 +996,15 @@ func CollectSavedTracks() {
 	}
 
 DONE:
+	if !fetchFailed {
+		recordCollectorSuccess(config.CollectorSavedTracks)
+	}
+	metrics.TracksCollected.WithLabelValues(config.CollectorSavedTracks, "inserted").Add(float64(success))
+	metrics.TracksCollected.WithLabelValues(config.CollectorSavedTracks, "existing").Add(float64(existing))
+	metrics.TracksCollected.WithLabelValues(config.CollectorSavedTracks, "skipped").Add(float64(skipped))
 	if success > 0 {
-		fmt.Printf("💚 saved %d new liked tracks (skipped %d) | range: %s to %s | %s\n",
-			success, skipped,
+		fmt.Printf("💚 saved %d new liked tracks (%d already stored, skipped %d) | range: %s to %s | %s\n",
+			success, existing, skipped,
 			oldest.Format("15:04:05"),
 			newest.Format("15:04:05"),
 			time.Now().Format(time.Kitchen))
@@ -528,62 +1013,72 @@ DONE:
 			skipped,
 			time.Now().Format(time.Kitchen))
 	}
+	run.Inserted = success
+	run.Skipped = existing + skipped
+	return run
 }
 
 // GET CURRENTLY PLAYIN
+// GetCurrentlyPLaying snapshots the player into now_playing_log. Returns nil
+// when nothing is playing.
 func GetCurrentlyPLaying() (*services.CurrentlyPlaying, error) {
-	refreshTok, err := repository.GetRefreshToken()
+	accessTok, err := getCronAccessToken()
 	if err != nil {
-		fmt.Print("failed to get refresh token ")
+		return nil, err
 	}
 
-	accessTok, newRefresh, err := services.RefreshAccessToken(refreshTok)
-
-	if newRefresh != nil && *newRefresh != refreshTok {
-		_ = repository.SaveOrUpdateRefreshToken(*newRefresh)
-	}
-
-	currentlyListening, err := services.GetCurrentlyListening(accessTok)
-	if err != nil {
-		fmt.Print(err)
-	}
-	jsonData, err := json.Marshal(currentlyListening)
+	var state *services.CurrentlyPlaying
+	err = cronRateLimiter.RetryWithBackoff(func() error {
+		state, err = services.GetPlaybackState(context.Background(), accessTok)
+		return err
+	}, 1)
 	if err != nil {
-		fmt.Printf("Error serializing userTracks: %v\n", err)
-		fmt.Print(err, "error here ")
+		fmt.Println("cron: playback state error:", err)
+		return nil, err
 	}
-	os.WriteFile("DEBUG_current_listening_track.json", jsonData, 0644)
+	recordCollectorSuccess(config.CollectorNowPlaying)
 
-	// fmt.Print(currentlyListening, "YAYYYYY")
+	if err := models.InsertNowPlaying(state, time.Now().UTC()); err != nil {
+		fmt.Println("cron:", err)
+		return state, err
+	}
+	return state, nil
+}
 
-	return currentlyListening, nil
+// missingGenreWhere selects saved tracks GetGenreOfRecentlyLiked still has to fill
+// (saved tracks marked "no genre" before the fallback chain existed get one more try)
+const missingGenreWhere = `genre IS NULL OR genre = '' OR genre = 'rate-limited' OR (genre = 'no genre' AND genre_source IS NULL)`
 
+// countMissingGenres returns how many saved tracks are waiting for a genre
+func countMissingGenres() (int, error) {
+	var n int
+	err := repository.Pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM recently_liked WHERE `+missingGenreWhere).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tracks missing genres: %v", err)
+	}
+	return n, nil
 }
 
 // call get artists genre by calling the get artist function and add genre to table
+// Artists are resolved through the artists cache and /v1/artists?ids= in batches
+// of 50, so a batch of liked tracks costs at most a couple of API calls. Tracks
+// whose artist has no genres go through models.GenreResolver's fallbacks, and
+// genre_source records which one answered.
 func GetGenreOfRecentlyLiked(batchSize int) int {
 	fmt.Println("🎶 Updating genres for recently_liked table...")
 
-	refreshTok, err := repository.GetRefreshToken()
-	if err != nil {
-		fmt.Println("Failed to get refresh token")
-		return 0
-	}
-
-	accessTok, newRefresh, err := services.RefreshAccessToken(refreshTok)
+	accessTok, err := getCronAccessToken()
 	if err != nil {
-		fmt.Printf("Failed to refresh token: %v\n", err)
+		fmt.Printf("GetGenreOfRecentlyLiked: %v\n", err)
 		return 0
 	}
-	if newRefresh != nil && *newRefresh != refreshTok {
-		_ = repository.SaveOrUpdateRefreshToken(*newRefresh)
-	}
 
 	// Fetch only a batch, including rate-limited entries to retry
 	query := `
-        SELECT id, artist_id
+        SELECT id, spotify_song_id, artist_id
         FROM recently_liked
-        WHERE genre IS NULL OR genre = '' OR genre = 'rate-limited'
+        WHERE ` + missingGenreWhere + `
         ORDER BY 
             CASE WHEN genre = 'rate-limited' THEN 1 ELSE 0 END,
             id
@@ -594,64 +1089,110 @@ func GetGenreOfRecentlyLiked(batchSize int) int {
 		fmt.Printf("Failed to fetch rows: %v\n", err)
 		return 0
 	}
-	defer rows.Close()
 
-	updated := 0
+	// Group rows by artist so each artist is looked up once
+	rowsByTrack := map[string][]int{}
+	tracksByArtist := map[string][]string{}
+	var artistIDs []string
 	for rows.Next() {
 		var id int
-		var artistID string
-		if err := rows.Scan(&id, &artistID); err != nil {
+		var spotifyID, artistID string
+		if err := rows.Scan(&id, &spotifyID, &artistID); err != nil {
 			fmt.Printf("Row scan error: %v\n", err)
 			continue
 		}
+		if _, seen := tracksByArtist[artistID]; !seen {
+			artistIDs = append(artistIDs, artistID)
+		}
+		if _, seen := rowsByTrack[spotifyID]; !seen {
+			tracksByArtist[artistID] = append(tracksByArtist[artistID], spotifyID)
+		}
+		rowsByTrack[spotifyID] = append(rowsByTrack[spotifyID], id)
+	}
+	rows.Close()
 
-		var artistObj *services.Artist
-		err := cronRateLimiter.RetryWithBackoff(func() error {
-			artistObj, err = services.GetArtistById(accessTok, artistID)
-			return err
-		}, 2) // Max 2 retries for genre fetching
-		
-		if err != nil {
-			if utils.IsRateLimitError(err) {
-				fmt.Printf("⚠️ Rate limited on genre fetch for artist %s, marking as 'rate-limited'\n", artistID)
-				// Mark as rate-limited instead of unknown to retry later
-				if _, err := repository.Pool.Exec(context.Background(),
-					"UPDATE recently_liked SET genre = $1 WHERE id = $2", "rate-limited", id); err != nil {
-					fmt.Printf("Failed to update genre for ID %d: %v\n", id, err)
+	if len(artistIDs) == 0 {
+		return 0
+	}
+
+	ctx := context.Background()
+	resolver := models.NewGenreResolver(ctx, accessTok, cronRateLimiter)
+	fetchErr := resolver.Prefetch(artistIDs)
+	if fetchErr != nil {
+		fmt.Printf("⚠️ Artist batch fetch stopped early: %v\n", fetchErr)
+	}
+
+	// Tracks whose artist has no genres fall back to their album's artist
+	var fallbackTracks []string
+	for _, artistID := range artistIDs {
+		if a := resolver.Artist(artistID); a != nil && len(a.Genres) == 0 {
+			fallbackTracks = append(fallbackTracks, tracksByArtist[artistID]...)
+		}
+	}
+	albumArtists, err := models.GetAlbumArtists(ctx, accessTok, fallbackTracks, cronRateLimiter)
+	if err != nil {
+		fmt.Printf("⚠️ Album artist lookup stopped early: %v\n", err)
+	}
+	var albumArtistIDs []string
+	for _, id := range albumArtists {
+		albumArtistIDs = append(albumArtistIDs, id)
+	}
+	if err := resolver.Prefetch(albumArtistIDs); err != nil {
+		fmt.Printf("⚠️ Album artist fetch stopped early: %v\n", err)
+	}
+
+	updated := 0
+	for _, artistID := range artistIDs {
+		for _, spotifyID := range tracksByArtist[artistID] {
+			var genre string
+			var source *string
+			if resolver.Artist(artistID) != nil {
+				genres, src, err := resolver.Resolve(artistID, albumArtists[spotifyID])
+				switch {
+				case err != nil && services.IsRateLimited(err):
+					genre = "rate-limited"
+				case err != nil:
+					fmt.Printf("GetGenreOfRecentlyLiked: %s: %v\n", spotifyID, err)
+					genre = "unknown"
+				case len(genres) > 0:
+					genre = strings.Join(genres, ", ")
+					source = &src
+					if err := models.SetTrackGenres(spotifyID, genres); err != nil {
+						fmt.Println(err)
+					}
+				default:
+					genre = "no genre"
+					none := models.GenreSourceNone
+					source = &none
 				}
+			} else if fetchErr != nil && services.IsRateLimited(fetchErr) {
+				// Mark as rate-limited instead of unknown to retry later
+				genre = "rate-limited"
 			} else {
-				// For other errors, mark as unknown and move on
-				if _, err := repository.Pool.Exec(context.Background(),
-					"UPDATE recently_liked SET genre = $1 WHERE id = $2", "unknown", id); err != nil {
-					fmt.Printf("Failed to update genre for ID %d: %v\n", id, err)
-				}
+				// For other errors or unknown artists, mark as unknown and move on
+				genre = "unknown"
 			}
-			continue
-		}
 
-		genre := ""
-		if artistObj != nil && len(artistObj.Genres) > 0 {
-			genre = strings.Join(artistObj.Genres, ", ")
-		} else {
-			genre = "no genre"
-		}
-		fmt.Printf("DEBUG Artist--->>> %s Genres: %+v\n", artistID, artistObj.Genres)
-
-		if _, err := repository.Pool.Exec(context.Background(),
-			"UPDATE recently_liked SET genre = $1 WHERE id = $2", genre, id); err != nil {
-			fmt.Printf("Failed to update genre for ID %d: %v\n", id, err)
-		} else {
-			updated++
-			fmt.Printf("Updated ID %d (Artist: %s) → Genre: %s\n", id, artistID, genre)
+			tag, err := repository.Pool.Exec(ctx,
+				"UPDATE recently_liked SET genre = $1, genre_source = $2 WHERE id = ANY($3)",
+				genre, source, rowsByTrack[spotifyID])
+			if err != nil {
+				fmt.Printf("Failed to update genre for track %s: %v\n", spotifyID, err)
+				continue
+			}
+			if genre != "rate-limited" && genre != "unknown" {
+				updated += int(tag.RowsAffected())
+			}
 		}
 	}
-	fmt.Printf("✅ Updated %d tracks in this batch.\n", updated)
+	fmt.Printf("✅ Updated %d tracks (%d artists) in this batch.\n", updated, len(artistIDs))
 	return updated
 }
 
 /* ---------- listening time stats ---------- */
 
-func formatDuration(ms int64) string {
+// FormatDuration renders milliseconds as "3h 25m"
+func FormatDuration(ms int64) string {
 	hours := ms / 3600000
 	minutes := (ms % 3600000) / 60000
 	return fmt.Sprintf("%dh %dm", hours, minutes)
@@ -678,14 +1219,14 @@ func GetListeningStats(c *gin.Context) {
 
 		totalMs, playCount, err := repository.GetListeningTimePerSong(songID, since)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			internalError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"song_id":    songID,
-			"play_count": playCount,
-			"total_ms":   totalMs,
-			"formatted":  formatDuration(totalMs),
+		c.JSON(http.StatusOK, SongListeningTimeResponse{
+			SongID:    songID,
+			PlayCount: playCount,
+			TotalMs:   totalMs,
+			Formatted: FormatDuration(totalMs),
 		})
 		return
 	}
@@ -699,16 +1240,16 @@ func GetListeningStats(c *gin.Context) {
 		"all_time":      {},
 	}
 
-	listeningTime := make(map[string]gin.H)
+	listeningTime := make(map[string]DurationTotal)
 	for period, since := range periods {
 		totalMs, err := repository.GetListeningTimeSince(since)
 		if err != nil {
 			fmt.Printf("Error getting listening time for %s: %v\n", period, err)
 			totalMs = 0
 		}
-		listeningTime[period] = gin.H{
-			"total_ms":  totalMs,
-			"formatted": formatDuration(totalMs),
+		listeningTime[period] = DurationTotal{
+			TotalMs:   totalMs,
+			Formatted: FormatDuration(totalMs),
 		}
 	}
 
@@ -718,55 +1259,122 @@ func GetListeningStats(c *gin.Context) {
 	}
 
 	// Format daily breakdown with human-readable durations
-	type dailyEntry struct {
-		Date      string `json:"date"`
-		TotalMs   int64  `json:"total_ms"`
-		Formatted string `json:"formatted"`
-		Count     int    `json:"count"`
-	}
-	var formattedDaily []dailyEntry
+	var formattedDaily []DailyListeningTime
 	for _, d := range dailyBreakdown {
-		formattedDaily = append(formattedDaily, dailyEntry{
+		formattedDaily = append(formattedDaily, DailyListeningTime{
 			Date:      d.Date,
 			TotalMs:   d.TotalMs,
-			Formatted: formatDuration(d.TotalMs),
+			Formatted: FormatDuration(d.TotalMs),
 			Count:     d.Count,
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"listening_time":              listeningTime,
-		"daily_breakdown_last_30_days": formattedDaily,
+	c.JSON(http.StatusOK, ListeningStatsResponse{
+		ListeningTime:        listeningTime,
+		DailyBreakdownLast30: formattedDaily,
 	})
 }
 
-/* ---------- backfill duration ---------- */
+/* ---------- fetch historical ---------- */
 
-func BackfillDurationHandler(c *gin.Context) {
-	refreshTok, err := repository.GetRefreshToken()
-	if err != nil || refreshTok == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "no refresh token available"})
+// FetchHistoricalResult is what a fetch_historical job did
+type FetchHistoricalResult struct {
+	Since    time.Time `json:"since"`
+	Fetched  int       `json:"fetched"`
+	Stored   int       `json:"stored"`   // plays that weren't stored yet
+	Buffered int       `json:"buffered"` // kept in the write buffer while Postgres was down
+}
+
+// FetchHistorical starts a job that pulls every play Spotify still has after
+// ?since= (RFC3339 or YYYY-MM-DD, default 24h ago) and stores the ones we're
+// missing, page by page. Progress is how far back towards since it has got.
+// POST /admin/fetch-historical?since=2024-11-03
+func FetchHistorical(c *gin.Context) {
+	since := time.Now().Add(-24 * time.Hour)
+	if v := c.Query("since"); v != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			if since, err = time.ParseInLocation("2006-01-02", v, config.Timezone()); err != nil {
+				badRequest(c, "invalid 'since' (expected RFC3339 or YYYY-MM-DD)")
+				return
+			}
+		}
+	}
+
+	// Report a missing or rejected token with its usual status up front
+	if _, err := getCronAccessToken(); err != nil {
+		spotifyError(c, err)
 		return
 	}
 
-	accessTok, newRefresh, err := services.RefreshAccessToken(refreshTok)
+	params := map[string]any{"since": since}
+	job := jobs.Start("fetch_historical", params, func(t *jobs.Task) (any, error) {
+		return fetchHistorical(t, since)
+	})
+	c.JSON(http.StatusAccepted, job)
+}
+
+// FetchHistoricalDeprecated serves the old POST /fetch-historical, which now
+// starts the same job as POST /admin/fetch-historical
+func FetchHistoricalDeprecated(c *gin.Context) {
+	c.Header("Deprecation", "true")
+	c.Header("Link", `</admin/fetch-historical>; rel="successor-version"`)
+	FetchHistorical(c)
+}
+
+func fetchHistorical(t *jobs.Task, since time.Time) (any, error) {
+	res := FetchHistoricalResult{Since: since}
+	accessTok, err := getCronAccessToken()
+	if err != nil {
+		return res, err
+	}
+
+	start := time.Now()
+	window := int(start.Sub(since).Seconds())
+	err = services.WalkRecentlyPlayedSince(t.Context(), accessTok, since, func(page []services.PlayedItem) error {
+		artists := cronCollector.artists(accessTok, primaryArtistIDs(page))
+		plays := make([]store.Play, len(page))
+		for i, it := range page {
+			plays[i] = playFromItem(it, artists)
+			plays[i].Source = models.SourceFetchHistorical
+		}
+		stored, buffered := cronCollector.insertPlays(plays)
+		res.Fetched += len(page)
+		res.Stored += len(stored)
+		res.Buffered += buffered
+
+		// Pages come newest first, so the last play is how far back we are
+		t.Progress(int(start.Sub(page[len(page)-1].PlayedAt).Seconds()), window)
+		return nil
+	})
+	if res.Stored > 0 {
+		if _, err := cronCollector.store.AttachPlaybackState(); err != nil {
+			fmt.Printf("fetch-historical: %v\n", err)
+		}
+		invalidateCollectionStats()
+		fmt.Printf("📜 fetch-historical stored %d of %d plays since %s\n", res.Stored, res.Fetched, since.Format(time.RFC3339))
+	}
+	return res, err
+}
+
+/* ---------- backfill duration ---------- */
+
+func BackfillDurationHandler(c *gin.Context) {
+	accessTok, err := getCronAccessToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token: " + err.Error()})
+		spotifyError(c, err)
 		return
 	}
-	if newRefresh != nil && *newRefresh != refreshTok {
-		_ = repository.SaveOrUpdateRefreshToken(*newRefresh)
-	}
 
-	updated, err := models.BackfillDuration(accessTok, utils.NewRateLimiter())
+	updated, err := models.BackfillDuration(c.Request.Context(), accessTok, utils.NewRateLimiter())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Backfilled duration for %d unique tracks", updated),
-		"updated": updated,
+	c.JSON(http.StatusOK, BackfillDurationResponse{
+		Message: fmt.Sprintf("Backfilled duration for %d unique tracks", updated),
+		Updated: updated,
 	})
 }
 
@@ -775,44 +1383,32 @@ func BackfillDurationHandler(c *gin.Context) {
 func GetTrackStreak(c *gin.Context) {
 	spotifyID := c.Param("id")
 	if spotifyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "track ID is required"})
+		badRequest(c, "track ID is required")
 		return
 	}
 
 	trackName, artistName, err := repository.GetTrackInfo(spotifyID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "track not found in listening history"})
+		notFound(c, "track not found in listening history")
 		return
 	}
 
 	longest, current, err := repository.GetTrackStreak(spotifyID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 
-	if longest.LongestStreak == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"song_id":        spotifyID,
-			"track_name":     trackName,
-			"artist_name":    artistName,
-			"longest_streak": nil,
-			"current_streak": nil,
-		})
-		return
+	resp := TrackStreakResponse{
+		SongID:     spotifyID,
+		TrackName:  trackName,
+		ArtistName: artistName,
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"song_id":     spotifyID,
-		"track_name":  trackName,
-		"artist_name": artistName,
-		"longest_streak": gin.H{
-			"days":  longest.LongestStreak,
-			"start": longest.LongestStart,
-			"end":   longest.LongestEnd,
-		},
-		"current_streak": current,
-	})
+	if longest.LongestStreak > 0 {
+		resp.LongestStreak = &longest
+		resp.CurrentStreak = current
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 /* ---------- shared date-range parser ---------- */
@@ -820,14 +1416,14 @@ func GetTrackStreak(c *gin.Context) {
 func parseDateRange(c *gin.Context) (*time.Time, *time.Time, error) {
 	var from, to *time.Time
 	if v := c.Query("from"); v != "" {
-		t, err := time.Parse("2006-01-02", v)
+		t, err := time.ParseInLocation("2006-01-02", v, config.Timezone())
 		if err != nil {
 			return nil, nil, fmt.Errorf("invalid 'from' date: %v", err)
 		}
 		from = &t
 	}
 	if v := c.Query("to"); v != "" {
-		t, err := time.Parse("2006-01-02", v)
+		t, err := time.ParseInLocation("2006-01-02", v, config.Timezone())
 		if err != nil {
 			return nil, nil, fmt.Errorf("invalid 'to' date: %v", err)
 		}
@@ -843,37 +1439,37 @@ func parseDateRange(c *gin.Context) (*time.Time, *time.Time, error) {
 func GetTrackStats(c *gin.Context) {
 	spotifyID := c.Param("id")
 	if spotifyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "track ID is required"})
+		badRequest(c, "track ID is required")
 		return
 	}
 
 	from, to, err := parseDateRange(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
 	trackName, artistName, err := repository.GetTrackInfo(spotifyID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "track not found in listening history"})
+		notFound(c, "track not found in listening history")
 		return
 	}
 
 	stats, err := repository.GetTrackStats(spotifyID, from, to)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"song_id":      spotifyID,
-		"track_name":   trackName,
-		"artist_name":  artistName,
-		"play_count":   stats.PlayCount,
-		"total_ms":     stats.TotalMs,
-		"formatted":    formatDuration(stats.TotalMs),
-		"first_listen": stats.FirstListen,
-		"last_listen":  stats.LastListen,
+	c.JSON(http.StatusOK, TrackStatsResponse{
+		SongID:      spotifyID,
+		TrackName:   trackName,
+		ArtistName:  artistName,
+		PlayCount:   stats.PlayCount,
+		TotalMs:     stats.TotalMs,
+		Formatted:   FormatDuration(stats.TotalMs),
+		FirstListen: stats.FirstListen,
+		LastListen:  stats.LastListen,
 	})
 }
 
@@ -882,45 +1478,45 @@ func GetTrackStats(c *gin.Context) {
 func GetTrackDaily(c *gin.Context) {
 	spotifyID := c.Param("id")
 	if spotifyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "track ID is required"})
+		badRequest(c, "track ID is required")
 		return
 	}
 
 	from, to, err := parseDateRange(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
 	trackName, artistName, err := repository.GetTrackInfo(spotifyID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "track not found in listening history"})
+		notFound(c, "track not found in listening history")
 		return
 	}
 
 	days, err := repository.GetTrackDaily(spotifyID, from, to)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	if days == nil {
 		days = []repository.DailyPlay{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"song_id":     spotifyID,
-		"track_name":  trackName,
-		"artist_name": artistName,
-		"days":        days,
+	c.JSON(http.StatusOK, TrackDailyResponse{
+		SongID:     spotifyID,
+		TrackName:  trackName,
+		ArtistName: artistName,
+		Days:       days,
 	})
 }
 
 /* ---------- top tracks ---------- */
 
-func GetTopTracks(c *gin.Context) {
+func (a *API) GetTopTracks(c *gin.Context) {
 	from, to, err := parseDateRange(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
@@ -933,29 +1529,27 @@ func GetTopTracks(c *gin.Context) {
 	if limit > 100 {
 		limit = 100
 	}
+	canonical, err := parseGroupBy(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	source, err := parseSource(c)
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
 
-	tracks, err := repository.GetTopTracks(from, to, limit)
+	tracks, err := a.store.TopTracks(from, to, limit, canonical, source)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 
 	// Build response with rank and formatted duration
-	type rankedTrack struct {
-		Rank          int    `json:"rank"`
-		SongID        string `json:"song_id"`
-		TrackName     string `json:"track_name"`
-		ArtistName    string `json:"artist_name"`
-		AlbumName     string `json:"album_name"`
-		AlbumCoverURL string `json:"album_cover_url"`
-		PlayCount     int    `json:"play_count"`
-		TotalMs       int64  `json:"total_ms"`
-		Formatted     string `json:"formatted"`
-	}
-
-	ranked := make([]rankedTrack, len(tracks))
+	ranked := make([]RankedTrack, len(tracks))
 	for i, t := range tracks {
-		ranked[i] = rankedTrack{
+		ranked[i] = RankedTrack{
 			Rank:          i + 1,
 			SongID:        t.SpotifyID,
 			TrackName:     t.TrackName,
@@ -964,7 +1558,7 @@ func GetTopTracks(c *gin.Context) {
 			AlbumCoverURL: t.AlbumCoverURL,
 			PlayCount:     t.PlayCount,
 			TotalMs:       t.TotalMs,
-			Formatted:     formatDuration(t.TotalMs),
+			Formatted:     FormatDuration(t.TotalMs),
 		}
 	}
 
@@ -978,37 +1572,69 @@ func GetTopTracks(c *gin.Context) {
 		toStr = to.Format("2006-01-02")
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"from":   fromStr,
-		"to":     toStr,
-		"tracks": ranked,
-		"count":  len(ranked),
+	c.JSON(http.StatusOK, TopTracksResponse{
+		From:   fromStr,
+		To:     toStr,
+		Tracks: ranked,
+		Count:  len(ranked),
 	})
 }
 
+// maxGenreLength bounds /genre/:genre; Spotify's longest genres are well under it
+const maxGenreLength = 100
+
+// validateGenre trims a genre name and explains what's wrong with it, if anything.
+// Spotify genres are lowercase words with spaces, hyphens, apostrophes, ampersands
+// and the odd accent or digit ("r&b", "children's music", "rock en español", "8-bit")
+func validateGenre(genre string) (string, error) {
+	genre = strings.TrimSpace(genre)
+	switch {
+	case genre == "":
+		return "", fmt.Errorf("genre is required, e.g. /genre/shoegaze (see /genres for the list)")
+	case !utf8.ValidString(genre):
+		return "", fmt.Errorf("genre is not valid UTF-8")
+	case utf8.RuneCountInString(genre) > maxGenreLength:
+		return "", fmt.Errorf("genre is longer than %d characters", maxGenreLength)
+	}
+	for _, r := range genre {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r):
+		case strings.ContainsRune(" -'&+.:/!", r):
+		default:
+			return "", fmt.Errorf("genre contains %q; only letters, digits, spaces and - ' & + . : / ! are allowed", r)
+		}
+	}
+	return genre, nil
+}
+
+// GetUserGenre returns liked artists tagged with a genre; ?match=contains
+// also finds genres containing it ("rock" finds "post-rock")
+// GET /genre/shoegaze?match=contains
 func GetUserGenre(c *gin.Context) {
-	genre := c.Param("genre")
-	
-	if genre == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Genre parameter is required"})
+	genre, err := validateGenre(c.Param("genre"))
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	match := c.DefaultQuery("match", repository.GenreMatchExact)
+	if match != repository.GenreMatchExact && match != repository.GenreMatchContains {
+		badRequest(c, fmt.Sprintf("invalid 'match' %q (expected %s or %s)",
+			match, repository.GenreMatchExact, repository.GenreMatchContains))
 		return
 	}
 
 	// Query recently_liked table for artists with the specified genre
-	likedArtists, err := repository.GetArtistsByGenre("recently_liked", genre)
+	likedArtists, err := repository.GetArtistsByGenre("recently_liked", genre, match)
 	if err != nil {
 		fmt.Printf("Error fetching artists by genre: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch artists by genre",
-			"details": err.Error(),
-		})
+		internalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"genre":        genre,
-		"artists":      likedArtists,
-		"count":        len(likedArtists),
-		"message":      "Successfully retrieved liked artists by genre",
+	c.JSON(http.StatusOK, GenreArtistsResponse{
+		Genre:   genre,
+		Artists: likedArtists,
+		Count:   len(likedArtists),
+		Message: "Successfully retrieved liked artists by genre",
 	})
 }
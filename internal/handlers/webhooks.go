@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"example.com/spotifydb/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------- webhooks ---------- */
+
+// CreateWebhookRequest registers a URL for some events (all when empty)
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// ListWebhooks returns every registered webhook with its last delivery
+// GET /admin/webhooks
+func ListWebhooks(c *gin.Context) {
+	hooks, err := webhooks.List()
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, WebhooksResponse{
+		Webhooks: hooks,
+		Count:    len(hooks),
+		Events:   webhooks.Events,
+	})
+}
+
+// CreateWebhook registers a webhook
+// POST /admin/webhooks {"url": "https://discord.com/api/webhooks/...", "events": ["artist.discovered"]}
+func CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, "invalid body: "+err.Error())
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		badRequest(c, "url must be an absolute http(s) URL")
+		return
+	}
+	for _, e := range req.Events {
+		if !webhooks.IsEvent(e) {
+			badRequest(c, fmt.Sprintf("unknown event %q (expected %s)", e, strings.Join(webhooks.Events, ", ")))
+			return
+		}
+	}
+
+	hook, err := webhooks.Create(req.URL, req.Events, req.Secret)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, hook)
+}
+
+// DeleteWebhook removes a webhook
+// DELETE /admin/webhooks/:id
+func DeleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		badRequest(c, "invalid webhook id")
+		return
+	}
+	found, err := webhooks.Delete(id)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if !found {
+		notFound(c, "webhook not found")
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "webhook deleted"})
+}
+
+// TestWebhook sends a ping event and reports how the endpoint answered
+// POST /admin/webhooks/:id/test
+func TestWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		badRequest(c, "invalid webhook id")
+		return
+	}
+	hook, err := webhooks.Get(id)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if hook == nil {
+		notFound(c, "webhook not found")
+		return
+	}
+
+	event := webhooks.NewEvent(webhooks.EventPing, "👋 spotify-db webhook test", nil)
+	if err := webhooks.Deliver(*hook, event); err != nil {
+		RespondError(c, http.StatusBadGateway, CodeWebhookFailed, "webhook delivery failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "ping delivered"})
+}
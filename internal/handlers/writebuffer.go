@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/buffer"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/store"
+)
+
+// writeBuffer holds plays fetched while Postgres was unreachable. Spotify only
+// keeps the last 50 plays, so dropping them during an outage loses them for good.
+// nil when the buffer file couldn't be opened.
+var writeBuffer *buffer.Queue
+
+// lastRefreshToken lets the cron keep talking to Spotify while the database is
+// down; pendingSave is set when a rotated token couldn't be persisted.
+var lastRefreshToken struct {
+	sync.Mutex
+	token       string
+	pendingSave bool
+}
+
+func initWriteBuffer() {
+	q, err := buffer.OpenFromEnv()
+	if err != nil {
+		fmt.Printf("⚠️  write buffer disabled: %v\n", err)
+		return
+	}
+	writeBuffer = q
+	if n, err := q.Len(); err == nil && n > 0 {
+		fmt.Printf("📼 write buffer has %d plays waiting for replay (%s)\n", n, q.Path())
+	}
+}
+
+// ReplayWriteBuffer flushes buffered plays once the database is reachable again
+func ReplayWriteBuffer() {
+	if writeBuffer == nil {
+		return
+	}
+	if n, err := writeBuffer.Len(); err != nil || n == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := repository.Ping(ctx); err != nil {
+		return // still down; try again next cycle
+	}
+
+	persistPendingRefreshToken()
+
+	var stored []store.Play
+	replayed, err := writeBuffer.Replay(func(p store.Play) error {
+		inserted, err := cronCollector.store.InsertRecentlyPlayed(p)
+		if inserted {
+			stored = append(stored, p)
+		}
+		if err != nil && !repository.IsUnavailable(err) {
+			// A row Postgres rejects will never succeed; don't let it block the queue
+			fmt.Printf("❌ write buffer: dropping %s @ %s: %v\n", p.TrackName, p.PlayedAt.Format(time.RFC3339), err)
+			return nil
+		}
+		return err
+	})
+	if replayed > 0 {
+		fmt.Printf("📼 replayed %d buffered plays\n", replayed)
+	}
+	queueScrobbles(stored)
+	if err != nil {
+		fmt.Printf("⚠️  write buffer replay stopped: %v\n", err)
+	}
+}
+
+func rememberRefreshToken(tok string, saved bool) {
+	lastRefreshToken.Lock()
+	lastRefreshToken.token = tok
+	lastRefreshToken.pendingSave = !saved
+	lastRefreshToken.Unlock()
+}
+
+// cachedRefreshToken returns the last token seen, for use while the DB is down
+func cachedRefreshToken() string {
+	lastRefreshToken.Lock()
+	defer lastRefreshToken.Unlock()
+	return lastRefreshToken.token
+}
+
+func persistPendingRefreshToken() {
+	lastRefreshToken.Lock()
+	defer lastRefreshToken.Unlock()
+	if !lastRefreshToken.pendingSave {
+		return
+	}
+	if err := repository.SaveOrUpdateRefreshToken(lastRefreshToken.token); err == nil {
+		lastRefreshToken.pendingSave = false
+	}
+}
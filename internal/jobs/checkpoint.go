@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Checkpoint is how far a long paged run (e.g. a recovery over every saved
+// track) got, so an interrupted run can resume instead of starting over
+type Checkpoint struct {
+	Name       string
+	Offset     int        // next offset to fetch
+	LastItemAt *time.Time // timestamp of the last item handled
+	UpdatedAt  time.Time
+}
+
+// LoadCheckpoint returns the named checkpoint, or nil if there is none
+func LoadCheckpoint(name string) (*Checkpoint, error) {
+	cp := Checkpoint{Name: name}
+	err := repository.Pool.QueryRow(context.Background(), `
+		SELECT next_offset, last_item_at, updated_at
+		FROM recovery_checkpoints
+		WHERE job_name = $1`, name).Scan(&cp.Offset, &cp.LastItemAt, &cp.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint %s: %v", name, err)
+	}
+	return &cp, nil
+}
+
+// SaveCheckpoint records that the run got up to offset; lastItemAt may be zero
+func SaveCheckpoint(name string, offset int, lastItemAt time.Time) error {
+	var last *time.Time
+	if !lastItemAt.IsZero() {
+		last = &lastItemAt
+	}
+	_, err := repository.Pool.Exec(context.Background(), `
+		INSERT INTO recovery_checkpoints (job_name, next_offset, last_item_at, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (job_name) DO UPDATE SET
+			next_offset = EXCLUDED.next_offset,
+			last_item_at = COALESCE(EXCLUDED.last_item_at, recovery_checkpoints.last_item_at),
+			updated_at = NOW()`, name, offset, last)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint %s: %v", name, err)
+	}
+	return nil
+}
+
+// ClearCheckpoint forgets the named checkpoint once its run has finished
+func ClearCheckpoint(name string) error {
+	if _, err := repository.Pool.Exec(context.Background(),
+		`DELETE FROM recovery_checkpoints WHERE job_name = $1`, name); err != nil {
+		return fmt.Errorf("failed to clear checkpoint %s: %v", name, err)
+	}
+	return nil
+}
+
+// Throughput estimates how long a paged run has left from the pace of the
+// pages fetched so far in this run
+type Throughput struct {
+	start time.Time
+	pages int
+}
+
+// NewThroughput starts timing now
+func NewThroughput() *Throughput {
+	return &Throughput{start: time.Now()}
+}
+
+// Page records one more page handled
+func (t *Throughput) Page() { t.pages++ }
+
+// ETA returns the estimated time for the remaining pages, or 0 before the
+// first page
+func (t *Throughput) ETA(remainingPages int) time.Duration {
+	if t.pages == 0 || remainingPages <= 0 {
+		return 0
+	}
+	perPage := time.Since(t.start) / time.Duration(t.pages)
+	return (perPage * time.Duration(remainingPages)).Round(time.Second)
+}
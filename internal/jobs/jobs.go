@@ -0,0 +1,374 @@
+// Package jobs runs long-lived work (backfills, recoveries, imports) on a
+// small worker pool, tracks progress and cancellation, and records every job
+// in the jobs table so it can be polled over HTTP.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Status is where a job is in its lifecycle
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Finished reports whether the job has stopped for good
+func (s Status) Finished() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCanceled
+}
+
+var (
+	// ErrNotFound means no job has the given id
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished means the job can no longer be canceled
+	ErrFinished = errors.New("job already finished")
+	// ErrNotLocal means the job runs in another process (e.g. a CLI tool)
+	ErrNotLocal = errors.New("job is running in another process")
+)
+
+// Job is a snapshot of one background job
+type Job struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	Params     any        `json:"params,omitempty"`
+	Status     Status     `json:"status"`
+	Progress   float64    `json:"progress"` // percent, 0-100
+	Result     any        `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// RunFunc does the work of a job and returns its result. Long jobs should
+// report progress and return early once t.Context() is canceled.
+type RunFunc func(t *Task) (any, error)
+
+// Task is the handle a running job uses to report progress and notice cancellation
+type Task struct {
+	ctx    context.Context
+	runner *Runner
+	entry  *entry
+}
+
+// Context is canceled when the job is canceled
+func (t *Task) Context() context.Context { return t.ctx }
+
+// Canceled reports whether the job has been asked to stop
+func (t *Task) Canceled() bool { return t.ctx.Err() != nil }
+
+// Progress records done out of total units of work
+func (t *Task) Progress(done, total int) {
+	if total <= 0 {
+		return
+	}
+	pct := float64(done) / float64(total) * 100
+	if pct > 100 {
+		pct = 100
+	}
+	t.runner.setProgress(t.entry, pct)
+}
+
+type entry struct {
+	job       Job
+	fn        RunFunc
+	ctx       context.Context
+	cancel    context.CancelFunc
+	lastSaved time.Time
+}
+
+// Runner executes jobs on a fixed number of workers
+type Runner struct {
+	mu    sync.Mutex
+	jobs  map[string]*entry
+	queue chan *entry
+}
+
+// progressSaveInterval throttles progress writes to the jobs table
+const progressSaveInterval = 2 * time.Second
+
+// NewRunner starts a runner with the given number of workers
+func NewRunner(workers int) *Runner {
+	if workers < 1 {
+		workers = 1
+	}
+	r := &Runner{
+		jobs:  map[string]*entry{},
+		queue: make(chan *entry, 256),
+	}
+	for i := 0; i < workers; i++ {
+		go r.work()
+	}
+	return r
+}
+
+// Submit queues fn and returns the job tracking it
+func (r *Runner) Submit(jobType string, params any, fn RunFunc) Job {
+	e := r.newEntry(jobType, params, fn)
+	r.queue <- e
+	return r.snapshot(e)
+}
+
+// Run executes fn in the calling goroutine and returns the finished job.
+// Command-line tools use it so their runs show up next to the server's jobs.
+func (r *Runner) Run(jobType string, params any, fn RunFunc) Job {
+	e := r.newEntry(jobType, params, fn)
+	r.execute(e)
+	return r.snapshot(e)
+}
+
+// Get returns the job with the given id, including jobs from earlier runs
+func (r *Runner) Get(id string) (Job, bool) {
+	r.mu.Lock()
+	e, ok := r.jobs[id]
+	if ok {
+		job := e.job
+		r.mu.Unlock()
+		return job, true
+	}
+	r.mu.Unlock()
+
+	job, err := load(id)
+	if err != nil || job == nil {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns the most recent jobs, newest first
+func (r *Runner) List(limit int) []Job {
+	byID := map[string]Job{}
+	if stored, err := loadRecent(limit); err != nil {
+		fmt.Printf("⚠️  jobs: %v\n", err)
+	} else {
+		for _, job := range stored {
+			byID[job.ID] = job
+		}
+	}
+
+	// In-memory state is fresher than the last throttled write
+	r.mu.Lock()
+	for id, e := range r.jobs {
+		byID[id] = e.job
+	}
+	r.mu.Unlock()
+
+	list := make([]Job, 0, len(byID))
+	for _, job := range byID {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// Cancel stops a queued or running job. Running jobs stop at their next
+// cancellation check.
+func (r *Runner) Cancel(id string) (Job, error) {
+	r.mu.Lock()
+	e, ok := r.jobs[id]
+	if !ok {
+		r.mu.Unlock()
+		job, found := r.Get(id)
+		if !found {
+			return Job{}, ErrNotFound
+		}
+		if job.Status.Finished() {
+			return job, ErrFinished
+		}
+		return job, ErrNotLocal
+	}
+	if e.job.Status.Finished() {
+		job := e.job
+		r.mu.Unlock()
+		return job, ErrFinished
+	}
+	e.cancel()
+	if e.job.Status == StatusQueued {
+		// The worker skips it when it comes up
+		r.finishLocked(e, nil, context.Canceled)
+	}
+	job := e.job
+	r.mu.Unlock()
+
+	save(job)
+	return job, nil
+}
+
+func (r *Runner) newEntry(jobType string, params any, fn RunFunc) *entry {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &entry{
+		job: Job{
+			ID:        newID(),
+			Type:      jobType,
+			Params:    params,
+			Status:    StatusQueued,
+			CreatedAt: time.Now().UTC(),
+		},
+		fn:     fn,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	r.mu.Lock()
+	r.jobs[e.job.ID] = e
+	r.mu.Unlock()
+
+	save(e.job)
+	return e
+}
+
+func (r *Runner) work() {
+	for e := range r.queue {
+		r.execute(e)
+	}
+}
+
+func (r *Runner) execute(e *entry) {
+	r.mu.Lock()
+	if e.job.Status != StatusQueued {
+		r.mu.Unlock()
+		return
+	}
+	started := time.Now().UTC()
+	e.job.Status = StatusRunning
+	e.job.StartedAt = &started
+	job := e.job
+	r.mu.Unlock()
+	save(job)
+
+	result, err := call(e.fn, &Task{ctx: e.ctx, runner: r, entry: e})
+
+	r.mu.Lock()
+	r.finishLocked(e, result, err)
+	job = e.job
+	r.mu.Unlock()
+	e.cancel()
+	save(job)
+
+	switch job.Status {
+	case StatusFailed:
+		fmt.Printf("❌ job %s (%s) failed: %s\n", job.ID, job.Type, job.Error)
+	case StatusCanceled:
+		fmt.Printf("🛑 job %s (%s) canceled\n", job.ID, job.Type)
+	default:
+		fmt.Printf("✅ job %s (%s) finished\n", job.ID, job.Type)
+	}
+}
+
+// finishLocked records the outcome; callers hold r.mu
+func (r *Runner) finishLocked(e *entry, result any, err error) {
+	finished := time.Now().UTC()
+	e.job.FinishedAt = &finished
+	e.job.Result = result
+	switch {
+	case e.ctx.Err() != nil:
+		e.job.Status = StatusCanceled
+	case err != nil:
+		e.job.Status = StatusFailed
+		e.job.Error = err.Error()
+	default:
+		e.job.Status = StatusSucceeded
+		e.job.Progress = 100
+	}
+}
+
+func (r *Runner) setProgress(e *entry, pct float64) {
+	r.mu.Lock()
+	e.job.Progress = pct
+	due := time.Since(e.lastSaved) >= progressSaveInterval
+	if due {
+		e.lastSaved = time.Now()
+	}
+	job := e.job
+	r.mu.Unlock()
+
+	if due {
+		save(job)
+	}
+}
+
+func (r *Runner) snapshot(e *entry) Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return e.job
+}
+
+// call runs fn, turning a panic into a failed job instead of a crashed process
+func call(fn RunFunc, t *Task) (result any, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	return fn(t)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+/* ---------- default runner ---------- */
+
+var (
+	defaultRunner *Runner
+	defaultOnce   sync.Once
+)
+
+// Default is the process-wide runner, sized by JOB_WORKERS (default 2)
+func Default() *Runner {
+	defaultOnce.Do(func() {
+		workers := 2
+		if v, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && v > 0 {
+			workers = v
+		}
+		defaultRunner = NewRunner(workers)
+	})
+	return defaultRunner
+}
+
+// Start queues fn on the default runner
+func Start(jobType string, params any, fn RunFunc) Job {
+	return Default().Submit(jobType, params, fn)
+}
+
+// Run executes fn synchronously on the default runner
+func Run(jobType string, params any, fn RunFunc) Job {
+	return Default().Run(jobType, params, fn)
+}
+
+// Get looks up a job on the default runner
+func Get(id string) (Job, bool) {
+	return Default().Get(id)
+}
+
+// List returns recent jobs from the default runner
+func List(limit int) []Job {
+	return Default().List(limit)
+}
+
+// Cancel cancels a job on the default runner
+func Cancel(id string) (Job, error) {
+	return Default().Cancel(id)
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// save upserts the job into the jobs table. Failures are logged, not
+// returned: a job keeps running even while Postgres is unreachable.
+func save(job Job) {
+	if repository.Pool == nil {
+		return
+	}
+
+	params, _ := json.Marshal(job.Params)
+	var result []byte
+	if job.Result != nil {
+		result, _ = json.Marshal(job.Result)
+	}
+	var jobErr *string
+	if job.Error != "" {
+		jobErr = &job.Error
+	}
+
+	_, err := repository.Pool.Exec(context.Background(), `
+		INSERT INTO jobs (id, type, params, status, progress, result, error, created_at, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			progress = EXCLUDED.progress,
+			result = EXCLUDED.result,
+			error = EXCLUDED.error,
+			started_at = EXCLUDED.started_at,
+			finished_at = EXCLUDED.finished_at`,
+		job.ID, job.Type, params, string(job.Status), job.Progress, result, jobErr,
+		job.CreatedAt, job.StartedAt, job.FinishedAt)
+	if err != nil {
+		fmt.Printf("⚠️  jobs: failed to save job %s: %v\n", job.ID, err)
+	}
+}
+
+const jobColumns = `id, type, params, status, progress, result, COALESCE(error, ''), created_at, started_at, finished_at`
+
+func scanJob(row pgx.Row) (*Job, error) {
+	var job Job
+	var status string
+	var params, result []byte
+	if err := row.Scan(&job.ID, &job.Type, &params, &status, &job.Progress, &result, &job.Error,
+		&job.CreatedAt, &job.StartedAt, &job.FinishedAt); err != nil {
+		return nil, err
+	}
+	job.Status = Status(status)
+	if len(params) > 0 && string(params) != "null" {
+		job.Params = json.RawMessage(params)
+	}
+	if len(result) > 0 {
+		job.Result = json.RawMessage(result)
+	}
+	return &job, nil
+}
+
+// load reads one job from the jobs table; nil when it doesn't exist
+func load(id string) (*Job, error) {
+	if repository.Pool == nil {
+		return nil, nil
+	}
+	job, err := scanJob(repository.Pool.QueryRow(context.Background(),
+		`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job %s: %v", id, err)
+	}
+	return job, nil
+}
+
+// loadRecent reads the newest jobs from the jobs table
+func loadRecent(limit int) ([]Job, error) {
+	if repository.Pool == nil {
+		return nil, nil
+	}
+	rows, err := repository.Pool.Query(context.Background(),
+		`SELECT `+jobColumns+` FROM jobs ORDER BY created_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %v", err)
+	}
+	defer rows.Close()
+
+	var list []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *job)
+	}
+	return list, rows.Err()
+}
+
+// MarkInterrupted fails jobs a previous server process left queued or
+// running; call it once at startup before submitting new jobs
+func MarkInterrupted() {
+	if repository.Pool == nil {
+		return
+	}
+	tag, err := repository.Pool.Exec(context.Background(), `
+		UPDATE jobs
+		SET status = 'failed', error = 'interrupted by restart', finished_at = NOW()
+		WHERE status IN ('queued', 'running')`)
+	if err != nil {
+		fmt.Printf("⚠️  jobs: %v\n", err)
+		return
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		fmt.Printf("⚠️  jobs: marked %d interrupted jobs as failed\n", n)
+	}
+}
@@ -0,0 +1,239 @@
+// Package lastfm is a small client for the parts of the Last.fm API used to
+// scrobble plays: track.scrobble and the desktop authentication flow that
+// yields a session key.
+package lastfm
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiURL = "https://ws.audioscrobbler.com/2.0/"
+
+// MaxBatch is how many plays track.scrobble accepts per request
+const MaxBatch = 50
+
+// Error codes Last.fm returns that callers act on
+const (
+	ErrOperationFailed   = 8
+	ErrInvalidSession    = 9
+	ErrInvalidAPIKey     = 10
+	ErrServiceOffline    = 11
+	ErrTokenUnauthorized = 14
+	ErrTemporary         = 16
+	ErrSuspendedAPIKey   = 26
+	ErrRateLimitExceeded = 29
+)
+
+// Error is an error reported by the Last.fm API, or an HTTP failure (Code 0)
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code == 0 {
+		return "lastfm: " + e.Message
+	}
+	return fmt.Sprintf("lastfm: error %d: %s", e.Code, e.Message)
+}
+
+// Temporary reports whether the same request may succeed later unchanged
+func (e *Error) Temporary() bool {
+	switch e.Code {
+	case 0, ErrOperationFailed, ErrServiceOffline, ErrTemporary, ErrRateLimitExceeded:
+		return true
+	}
+	return false
+}
+
+// Client calls the Last.fm API as one user
+type Client struct {
+	APIKey     string
+	Secret     string
+	SessionKey string
+	HTTP       *http.Client
+}
+
+// New returns a client; sessionKey may be empty for the auth calls
+func New(apiKey, secret, sessionKey string) *Client {
+	return &Client{
+		APIKey:     apiKey,
+		Secret:     secret,
+		SessionKey: sessionKey,
+		HTTP:       &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Scrobble is one play to submit
+type Scrobble struct {
+	Artist     string
+	Track      string
+	Album      string
+	PlayedAt   time.Time // when the track started
+	DurationMs int
+}
+
+// Result is what Last.fm did with one scrobble. Ignored scrobbles were
+// received but not recorded (filtered artist, timestamp too old, ...).
+type Result struct {
+	Accepted      bool
+	IgnoredCode   int
+	IgnoredReason string
+}
+
+// Scrobble submits up to MaxBatch plays and returns one Result per play, in
+// order. An error means none of them were recorded.
+func (c *Client) Scrobble(plays []Scrobble) ([]Result, error) {
+	if len(plays) == 0 {
+		return nil, nil
+	}
+	if len(plays) > MaxBatch {
+		return nil, fmt.Errorf("lastfm: at most %d scrobbles per request, got %d", MaxBatch, len(plays))
+	}
+
+	params := url.Values{"method": {"track.scrobble"}, "sk": {c.SessionKey}}
+	for i, p := range plays {
+		params.Set(fmt.Sprintf("artist[%d]", i), p.Artist)
+		params.Set(fmt.Sprintf("track[%d]", i), p.Track)
+		params.Set(fmt.Sprintf("timestamp[%d]", i), strconv.FormatInt(p.PlayedAt.Unix(), 10))
+		if p.Album != "" {
+			params.Set(fmt.Sprintf("album[%d]", i), p.Album)
+		}
+		if p.DurationMs > 0 {
+			params.Set(fmt.Sprintf("duration[%d]", i), strconv.Itoa(p.DurationMs/1000))
+		}
+	}
+
+	var resp struct {
+		Scrobbles struct {
+			Scrobble json.RawMessage `json:"scrobble"`
+		} `json:"scrobbles"`
+	}
+	if err := c.call(params, &resp); err != nil {
+		return nil, err
+	}
+
+	// A single scrobble comes back as an object rather than a one-element array
+	type scrobbleResult struct {
+		IgnoredMessage struct {
+			Code string `json:"code"`
+			Text string `json:"#text"`
+		} `json:"ignoredMessage"`
+	}
+	var items []scrobbleResult
+	raw := resp.Scrobbles.Scrobble
+	if len(raw) > 0 && raw[0] == '{' {
+		var one scrobbleResult
+		if err := json.Unmarshal(raw, &one); err != nil {
+			return nil, fmt.Errorf("lastfm: decoding scrobble response: %v", err)
+		}
+		items = append(items, one)
+	} else if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("lastfm: decoding scrobble response: %v", err)
+	}
+	if len(items) != len(plays) {
+		return nil, fmt.Errorf("lastfm: sent %d scrobbles, got %d results", len(plays), len(items))
+	}
+
+	results := make([]Result, len(items))
+	for i, it := range items {
+		code, _ := strconv.Atoi(it.IgnoredMessage.Code)
+		results[i] = Result{Accepted: code == 0, IgnoredCode: code, IgnoredReason: it.IgnoredMessage.Text}
+	}
+	return results, nil
+}
+
+// GetToken starts the desktop auth flow. The user approves the token at
+// AuthURL, then GetSession exchanges it for a session key.
+func (c *Client) GetToken() (string, error) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := c.call(url.Values{"method": {"auth.getToken"}}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// AuthURL is where the user approves a token from GetToken
+func (c *Client) AuthURL(token string) string {
+	return "https://www.last.fm/api/auth/?" + url.Values{"api_key": {c.APIKey}, "token": {token}}.Encode()
+}
+
+// GetSession exchanges an approved token for the user's name and a session
+// key, which doesn't expire
+func (c *Client) GetSession(token string) (user, key string, err error) {
+	var resp struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+	if err := c.call(url.Values{"method": {"auth.getSession"}, "token": {token}}, &resp); err != nil {
+		return "", "", err
+	}
+	return resp.Session.Name, resp.Session.Key, nil
+}
+
+// call signs params and POSTs them, decoding the JSON response into v
+func (c *Client) call(params url.Values, v any) error {
+	params.Set("api_key", c.APIKey)
+	params.Set("api_sig", c.sign(params))
+	params.Set("format", "json")
+
+	res, err := c.HTTP.PostForm(apiURL, params)
+	if err != nil {
+		return &Error{Message: err.Error()}
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return &Error{Message: err.Error()}
+	}
+
+	var apiErr struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != 0 {
+		return &Error{Code: apiErr.Error, Message: apiErr.Message}
+	}
+	if res.StatusCode != http.StatusOK {
+		return &Error{Message: fmt.Sprintf("HTTP %d", res.StatusCode)}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("lastfm: decoding response: %v", err)
+	}
+	return nil
+}
+
+// sign computes api_sig: the md5 of every parameter name and value sorted by
+// name, followed by the shared secret
+func (c *Client) sign(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "format" && k != "callback" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(params.Get(k))
+	}
+	b.WriteString(c.Secret)
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
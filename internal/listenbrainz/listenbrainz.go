@@ -0,0 +1,239 @@
+// Package listenbrainz is a small client for the ListenBrainz API: submitting
+// listens, and reading a user's listens back to learn the recording MSID
+// ListenBrainz assigned each one.
+package listenbrainz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the public ListenBrainz server
+const DefaultURL = "https://api.listenbrainz.org"
+
+// MaxListens is how many listens one submit-listens or listens request takes
+const MaxListens = 1000
+
+// Listen types accepted by submit-listens
+const (
+	ListenTypeSingle = "single"
+	ListenTypeImport = "import"
+)
+
+// Error is a failed request. RetryAfter is set when rate limited.
+type Error struct {
+	Status     int // HTTP status, 0 when the request never got an answer
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Status == 0 {
+		return "listenbrainz: " + e.Message
+	}
+	return fmt.Sprintf("listenbrainz: HTTP %d: %s", e.Status, e.Message)
+}
+
+// Temporary reports whether the same request may succeed later unchanged
+func (e *Error) Temporary() bool {
+	return e.Status == 0 || e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+// Client calls the ListenBrainz API with a user token
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// New returns a client for the server at baseURL (DefaultURL when empty)
+func New(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Listen is one play to submit
+type Listen struct {
+	ListenedAt  time.Time
+	ArtistName  string
+	TrackName   string
+	ReleaseName string
+	DurationMs  int
+	SpotifyID   string
+}
+
+// SubmittedListen is a listen as ListenBrainz stored it
+type SubmittedListen struct {
+	ListenedAt    time.Time
+	ArtistName    string
+	TrackName     string
+	RecordingMSID string
+}
+
+// ValidateToken checks the token and returns the user it belongs to
+func (c *Client) ValidateToken() (string, error) {
+	var resp struct {
+		Valid    bool   `json:"valid"`
+		UserName string `json:"user_name"`
+		Message  string `json:"message"`
+	}
+	if err := c.do(http.MethodGet, "/1/validate-token", nil, &resp); err != nil {
+		return "", err
+	}
+	if !resp.Valid {
+		return "", &Error{Status: http.StatusUnauthorized, Message: resp.Message}
+	}
+	return resp.UserName, nil
+}
+
+// SubmitListens submits up to MaxListens listens. ListenBrainz takes them
+// all or none. Use ListenTypeImport for more than one.
+func (c *Client) SubmitListens(listenType string, listens []Listen) error {
+	if len(listens) == 0 {
+		return nil
+	}
+	if len(listens) > MaxListens {
+		return fmt.Errorf("listenbrainz: at most %d listens per request, got %d", MaxListens, len(listens))
+	}
+
+	type additionalInfo struct {
+		DurationMs       int    `json:"duration_ms,omitempty"`
+		SpotifyID        string `json:"spotify_id,omitempty"`
+		MusicService     string `json:"music_service"`
+		SubmissionClient string `json:"submission_client"`
+	}
+	type trackMetadata struct {
+		ArtistName     string         `json:"artist_name"`
+		TrackName      string         `json:"track_name"`
+		ReleaseName    string         `json:"release_name,omitempty"`
+		AdditionalInfo additionalInfo `json:"additional_info"`
+	}
+	type payload struct {
+		ListenedAt    int64         `json:"listened_at"`
+		TrackMetadata trackMetadata `json:"track_metadata"`
+	}
+	body := struct {
+		ListenType string    `json:"listen_type"`
+		Payload    []payload `json:"payload"`
+	}{ListenType: listenType, Payload: make([]payload, len(listens))}
+	for i, l := range listens {
+		info := additionalInfo{
+			DurationMs:       l.DurationMs,
+			MusicService:     "spotify.com",
+			SubmissionClient: "spotifydb",
+		}
+		if l.SpotifyID != "" {
+			info.SpotifyID = "https://open.spotify.com/track/" + l.SpotifyID
+		}
+		body.Payload[i] = payload{
+			ListenedAt: l.ListenedAt.Unix(),
+			TrackMetadata: trackMetadata{
+				ArtistName:     l.ArtistName,
+				TrackName:      l.TrackName,
+				ReleaseName:    l.ReleaseName,
+				AdditionalInfo: info,
+			},
+		}
+	}
+	return c.do(http.MethodPost, "/1/submit-listens", body, nil)
+}
+
+// GetListens returns up to count (at most MaxListens) of user's listens from
+// before the given time, newest first
+func (c *Client) GetListens(user string, before time.Time, count int) ([]SubmittedListen, error) {
+	q := url.Values{
+		"max_ts": {strconv.FormatInt(before.Unix(), 10)},
+		"count":  {strconv.Itoa(min(count, MaxListens))},
+	}
+	var resp struct {
+		Payload struct {
+			Listens []struct {
+				ListenedAt    int64  `json:"listened_at"`
+				RecordingMSID string `json:"recording_msid"`
+				TrackMetadata struct {
+					ArtistName string `json:"artist_name"`
+					TrackName  string `json:"track_name"`
+				} `json:"track_metadata"`
+			} `json:"listens"`
+		} `json:"payload"`
+	}
+	path := "/1/user/" + url.PathEscape(user) + "/listens?" + q.Encode()
+	if err := c.do(http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	listens := make([]SubmittedListen, len(resp.Payload.Listens))
+	for i, l := range resp.Payload.Listens {
+		listens[i] = SubmittedListen{
+			ListenedAt:    time.Unix(l.ListenedAt, 0),
+			ArtistName:    l.TrackMetadata.ArtistName,
+			TrackName:     l.TrackMetadata.TrackName,
+			RecordingMSID: l.RecordingMSID,
+		}
+	}
+	return listens, nil
+}
+
+// do sends body as JSON (when set) and decodes the response into v (when set)
+func (c *Client) do(method, path string, body, v any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+c.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return &Error{Message: err.Error()}
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 10<<20))
+	if err != nil {
+		return &Error{Message: err.Error()}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		apiErr := &Error{Status: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &msg) == nil && msg.Error != "" {
+			apiErr.Message = msg.Error
+		}
+		if secs, err := strconv.Atoi(res.Header.Get("X-RateLimit-Reset-In")); err == nil {
+			apiErr.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return apiErr
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("listenbrainz: decoding response: %v", err)
+	}
+	return nil
+}
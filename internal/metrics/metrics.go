@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "spotifydb"
+
+var (
+	// SpotifyRequests counts outbound Spotify API calls by endpoint and HTTP status
+	SpotifyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spotify_requests_total",
+		Help:      "Spotify API calls by endpoint and HTTP status code.",
+	}, []string{"endpoint", "status"})
+
+	// SpotifyRequestDuration tracks Spotify API latency by endpoint
+	SpotifyRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "spotify_request_duration_seconds",
+		Help:      "Spotify API call latency by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	// SpotifyRateLimited counts 429 responses from Spotify
+	SpotifyRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spotify_rate_limited_total",
+		Help:      "429 Too Many Requests responses from Spotify by endpoint.",
+	}, []string{"endpoint"})
+
+	// TracksCollected counts items handled by a collector, split by result (inserted/skipped/failed)
+	TracksCollected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tracks_collected_total",
+		Help:      "Tracks processed by the collectors by result.",
+	}, []string{"collector", "result"})
+
+	// CronCycleDuration tracks how long a full cron cycle takes
+	CronCycleDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cron_cycle_duration_seconds",
+		Help:      "Duration of a full background collection cycle.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	})
+
+	// DBQueryDuration tracks Postgres query latency by statement type
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Postgres query latency by statement type.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"operation", "status"})
+
+	// HTTPRequests counts API requests served by route and status
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration tracks API latency by route
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// CollectorLeader is 1 while this process holds the collector lock
+	CollectorLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "collector_leader",
+		Help:      "1 while this process holds the collector lock and collects, 0 on standby.",
+	})
+)
+
+// Handler serves the Prometheus text exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// albumColumns aggregates the plays of one (album_name, artist_name) group
+const albumColumns = `
+	rp.album_name,
+	COALESCE(rp.artist_name, '') AS artist_name,
+	COALESCE(MAX(rp.album_cover_url), '') AS album_cover_url,
+	COUNT(*) AS play_count,
+	COUNT(DISTINCT rp.spotify_song_id) AS distinct_tracks,
+	COALESCE(SUM(rp.duration_ms), 0) AS total_ms,
+	MIN(rp.played_at) AS first_played,
+	MAX(rp.played_at) AS last_played`
+
+func scanTopAlbum(row interface{ Scan(...any) error }) (TopAlbum, error) {
+	var a TopAlbum
+	err := row.Scan(&a.AlbumName, &a.ArtistName, &a.AlbumCoverUrl, &a.PlayCount,
+		&a.DistinctTracks, &a.TotalMs, &a.FirstPlayed, &a.LastPlayed)
+	return a, err
+}
+
+// GetTopAlbums ranks albums by plays within [from, to). Nil bounds are
+// open-ended. Albums are keyed by name and artist since names aren't unique.
+func GetTopAlbums(pool *pgxpool.Pool, from, to *time.Time, limit int) ([]TopAlbum, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT `+albumColumns+`
+		FROM recently_played rp
+		WHERE rp.album_name IS NOT NULL AND rp.album_name <> ''
+		  AND ($1::timestamptz IS NULL OR rp.played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR rp.played_at < $2)
+		GROUP BY rp.album_name, COALESCE(rp.artist_name, '')
+		ORDER BY play_count DESC, last_played DESC
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top albums: %v", err)
+	}
+	defer rows.Close()
+
+	albums := []TopAlbum{}
+	for rows.Next() {
+		a, err := scanTopAlbum(rows)
+		if err != nil {
+			return nil, err
+		}
+		albums = append(albums, a)
+	}
+	return albums, rows.Err()
+}
+
+// GetAlbumPlays returns the totals and per-track plays for one album, matched
+// case-insensitively by name and, when artist isn't empty, by artist. Albums
+// sharing a name across artists are merged unless artist is given. Returns a
+// nil album when there are no plays.
+func GetAlbumPlays(pool *pgxpool.Pool, albumName, artist string, from, to *time.Time) (*TopAlbum, []AlbumTrackPlays, error) {
+	ctx := context.Background()
+	const match = `
+		FROM recently_played rp
+		WHERE LOWER(rp.album_name) = LOWER($1)
+		  AND ($2 = '' OR LOWER(rp.artist_name) = LOWER($2))
+		  AND ($3::timestamptz IS NULL OR rp.played_at >= $3)
+		  AND ($4::timestamptz IS NULL OR rp.played_at < $4)`
+
+	// The album name/artist come back as stored rather than as queried
+	row := pool.QueryRow(ctx, `
+		SELECT MIN(rp.album_name), COALESCE(MIN(rp.artist_name), ''),
+			COALESCE(MAX(rp.album_cover_url), ''), COUNT(*), COUNT(DISTINCT rp.spotify_song_id),
+			COALESCE(SUM(rp.duration_ms), 0), MIN(rp.played_at), MAX(rp.played_at)
+		`+match+`
+		HAVING COUNT(*) > 0`, albumName, artist, from, to)
+	album, err := scanTopAlbum(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get album plays: %v", err)
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT rp.spotify_song_id, MAX(rp.track_name), COUNT(*),
+			COALESCE(SUM(rp.duration_ms), 0), MAX(rp.played_at)
+		`+match+`
+		GROUP BY rp.spotify_song_id
+		ORDER BY COUNT(*) DESC, MAX(rp.played_at) DESC`, albumName, artist, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get album tracks: %v", err)
+	}
+	defer rows.Close()
+
+	tracks := []AlbumTrackPlays{}
+	for rows.Next() {
+		var t AlbumTrackPlays
+		if err := rows.Scan(&t.SpotifySongID, &t.TrackName, &t.PlayCount, &t.TotalMs, &t.LastPlayed); err != nil {
+			return nil, nil, err
+		}
+		tracks = append(tracks, t)
+	}
+	return &album, tracks, rows.Err()
+}
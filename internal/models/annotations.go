@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AnnotationUpdate is a partial annotation; nil fields are left as they are
+// and empty strings clear them
+type AnnotationUpdate struct {
+	Mood     *string
+	Activity *string
+	Note     *string
+}
+
+// AnnotatePlays applies update to every play from playID through throughID
+// by played_at, inclusive, so a whole listening session can be tagged at
+// once. Pass throughID == playID for a single play. Returns how many plays
+// were annotated; 0 means one of the plays doesn't exist.
+func AnnotatePlays(pool *pgxpool.Pool, playID, throughID int, update AnnotationUpdate) (int, error) {
+	tag, err := pool.Exec(context.Background(), `
+		WITH bounds AS (
+			SELECT LEAST(a.played_at, b.played_at) AS lo, GREATEST(a.played_at, b.played_at) AS hi
+			FROM recently_played a, recently_played b
+			WHERE a.id = $1 AND b.id = $2
+		)
+		INSERT INTO play_annotations (play_id, mood, activity, note)
+		SELECT rp.id, NULLIF($3::text, ''), NULLIF($4::text, ''), NULLIF($5::text, '')
+		FROM recently_played rp, bounds
+		WHERE rp.played_at BETWEEN bounds.lo AND bounds.hi
+		ON CONFLICT (play_id) DO UPDATE SET
+			mood       = CASE WHEN $3::text IS NULL THEN play_annotations.mood ELSE EXCLUDED.mood END,
+			activity   = CASE WHEN $4::text IS NULL THEN play_annotations.activity ELSE EXCLUDED.activity END,
+			note       = CASE WHEN $5::text IS NULL THEN play_annotations.note ELSE EXCLUDED.note END,
+			updated_at = NOW()`,
+		playID, throughID, update.Mood, update.Activity, update.Note)
+	if err != nil {
+		return 0, fmt.Errorf("failed to annotate plays: %v", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// GetPlayAnnotation returns the annotation of one play, or nil if it has none
+func GetPlayAnnotation(pool *pgxpool.Pool, playID int) (*PlayAnnotation, error) {
+	var a PlayAnnotation
+	err := pool.QueryRow(context.Background(), `
+		SELECT play_id, COALESCE(mood, ''), COALESCE(activity, ''), COALESCE(note, ''), updated_at
+		FROM play_annotations
+		WHERE play_id = $1`, playID).Scan(&a.PlayID, &a.Mood, &a.Activity, &a.Note, &a.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get annotation for play %d: %v", playID, err)
+	}
+	return &a, nil
+}
+
+// PlayQuery filters GetAnnotatedPlays. Empty strings and nil bounds don't
+// filter; Annotated keeps only plays that have an annotation.
+type PlayQuery struct {
+	Mood      string
+	Activity  string
+	Source    string
+	Annotated bool
+	From, To  *time.Time
+	Limit     int
+	Offset    int
+}
+
+// GetAnnotatedPlays returns plays newest first with their annotations, plus
+// the number of matching plays and their total listening time
+func GetAnnotatedPlays(pool *pgxpool.Pool, q PlayQuery) (plays []AnnotatedPlay, total int, totalMs int64, err error) {
+	ctx := context.Background()
+	const match = `
+		FROM recently_played rp
+		LEFT JOIN play_annotations pa ON pa.play_id = rp.id
+		WHERE ($1 = '' OR LOWER(pa.mood) = LOWER($1))
+		  AND ($2 = '' OR LOWER(pa.activity) = LOWER($2))
+		  AND (NOT $3 OR pa.play_id IS NOT NULL)
+		  AND ($4::timestamptz IS NULL OR rp.played_at >= $4)
+		  AND ($5::timestamptz IS NULL OR rp.played_at < $5)
+		  AND ($6 = '' OR rp.source = $6)`
+	args := []any{q.Mood, q.Activity, q.Annotated, q.From, q.To, q.Source}
+
+	if err := pool.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(rp.duration_ms), 0)
+		`+match, args...).Scan(&total, &totalMs); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to count plays: %v", err)
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT
+			rp.id,
+			rp.spotify_song_id,
+			rp.track_name,
+			COALESCE(rp.artist_name, ''),
+			COALESCE(rp.album_name, ''),
+			COALESCE(rp.album_cover_url, ''),
+			COALESCE(rp.duration_ms, 0),
+			rp.played_at,
+			COALESCE(pa.mood, ''),
+			COALESCE(pa.activity, ''),
+			COALESCE(pa.note, '')
+		`+match+`
+		ORDER BY rp.played_at DESC
+		LIMIT $7 OFFSET $8`, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to get plays: %v", err)
+	}
+	defer rows.Close()
+
+	plays = []AnnotatedPlay{}
+	for rows.Next() {
+		var p AnnotatedPlay
+		if err := rows.Scan(&p.ID, &p.SpotifySongID, &p.TrackName, &p.ArtistName, &p.AlbumName,
+			&p.AlbumCoverUrl, &p.DurationMS, &p.PlayedAt, &p.Mood, &p.Activity, &p.Note); err != nil {
+			return nil, 0, 0, err
+		}
+		plays = append(plays, p)
+	}
+	return plays, total, totalMs, rows.Err()
+}
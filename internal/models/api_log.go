@@ -0,0 +1,179 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// APICall is one spotify_api_log row. Status is 0 when no response came back.
+type APICall struct {
+	Endpoint    string
+	Status      int
+	DurationMs  int
+	RetryAfterS int // 0 unless rate limited with a Retry-After
+	Weight      float64
+	CalledAt    time.Time
+}
+
+// APIUsageBucket sums the calls of one hour or day. Counts are estimates
+// when sampled calls were logged.
+type APIUsageBucket struct {
+	Start         time.Time      `json:"start"`
+	Calls         int            `json:"calls"`
+	Errors        int            `json:"errors"` // no response, or a status other than 2xx/304
+	RateLimited   int            `json:"rate_limited"`
+	AvgDurationMs int            `json:"avg_duration_ms"`
+	ByEndpoint    map[string]int `json:"by_endpoint"`
+}
+
+// APIEndpointUsage sums the calls to one endpoint label
+type APIEndpointUsage struct {
+	Endpoint      string `json:"endpoint"`
+	Calls         int    `json:"calls"`
+	Errors        int    `json:"errors"`
+	RateLimited   int    `json:"rate_limited"`
+	AvgDurationMs int    `json:"avg_duration_ms"`
+	P95DurationMs int    `json:"p95_duration_ms"`
+}
+
+// APIRateLimit is one 429 Spotify answered
+type APIRateLimit struct {
+	At          time.Time `json:"at"`
+	Endpoint    string    `json:"endpoint"`
+	RetryAfterS *int      `json:"retry_after_s"`
+}
+
+// apiCallError counts a call as failed
+const apiCallError = `(status < 200 OR status >= 300) AND status <> 304`
+
+// InsertAPICalls writes calls in one statement
+func InsertAPICalls(pool *pgxpool.Pool, calls []APICall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	endpoints := make([]string, len(calls))
+	statuses := make([]int, len(calls))
+	durations := make([]int, len(calls))
+	retryAfter := make([]*int, len(calls))
+	weights := make([]float64, len(calls))
+	at := make([]time.Time, len(calls))
+	for i, c := range calls {
+		endpoints[i], statuses[i], durations[i] = c.Endpoint, c.Status, c.DurationMs
+		if c.RetryAfterS > 0 {
+			retryAfter[i] = &calls[i].RetryAfterS
+		}
+		weights[i], at[i] = c.Weight, c.CalledAt
+	}
+	_, err := pool.Exec(context.Background(), `
+		INSERT INTO spotify_api_log (endpoint, status, duration_ms, retry_after_s, weight, called_at)
+		SELECT * FROM unnest($1::text[], $2::int[], $3::int[], $4::int[], $5::float8[], $6::timestamptz[])`,
+		endpoints, statuses, durations, retryAfter, weights, at)
+	if err != nil {
+		return fmt.Errorf("failed to log %d Spotify calls: %v", len(calls), err)
+	}
+	return nil
+}
+
+// PurgeAPICalls deletes calls logged before the given time
+func PurgeAPICalls(pool *pgxpool.Pool, before time.Time) (int64, error) {
+	tag, err := pool.Exec(context.Background(), `DELETE FROM spotify_api_log WHERE called_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge the Spotify call log: %v", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetAPIUsage sums the calls in [from, to) per hour or day (in TIMEZONE),
+// oldest first, leaving out buckets without calls
+func GetAPIUsage(pool *pgxpool.Pool, from, to time.Time, bucket string) ([]APIUsageBucket, error) {
+	rows, err := pool.Query(context.Background(), `
+		WITH calls AS (
+			SELECT date_trunc($3, called_at AT TIME ZONE $4) AT TIME ZONE $4 AS start, *
+			FROM spotify_api_log
+			WHERE called_at >= $1 AND called_at < $2
+		),
+		endpoints AS (
+			SELECT start, jsonb_object_agg(endpoint, calls) AS by_endpoint
+			FROM (SELECT start, endpoint, ROUND(SUM(weight))::int AS calls FROM calls GROUP BY start, endpoint) e
+			GROUP BY start
+		)
+		SELECT c.start, ROUND(SUM(c.weight))::int,
+			ROUND(COALESCE(SUM(c.weight) FILTER (WHERE `+apiCallError+`), 0))::int,
+			COUNT(*) FILTER (WHERE c.status = 429),
+			ROUND(AVG(c.duration_ms))::int,
+			e.by_endpoint
+		FROM calls c
+		JOIN endpoints e ON e.start = c.start
+		GROUP BY c.start, e.by_endpoint
+		ORDER BY c.start`, from, to, bucket, config.Timezone().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Spotify API usage: %v", err)
+	}
+	defer rows.Close()
+
+	buckets := []APIUsageBucket{}
+	for rows.Next() {
+		var b APIUsageBucket
+		if err := rows.Scan(&b.Start, &b.Calls, &b.Errors, &b.RateLimited, &b.AvgDurationMs, &b.ByEndpoint); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// GetAPIEndpointUsage sums the calls in [from, to) per endpoint, busiest first
+func GetAPIEndpointUsage(pool *pgxpool.Pool, from, to time.Time) ([]APIEndpointUsage, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT endpoint, ROUND(SUM(weight))::int,
+			ROUND(COALESCE(SUM(weight) FILTER (WHERE `+apiCallError+`), 0))::int,
+			COUNT(*) FILTER (WHERE status = 429),
+			ROUND(AVG(duration_ms))::int,
+			PERCENTILE_DISC(0.95) WITHIN GROUP (ORDER BY duration_ms)
+		FROM spotify_api_log
+		WHERE called_at >= $1 AND called_at < $2
+		GROUP BY endpoint
+		ORDER BY SUM(weight) DESC, endpoint`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Spotify API usage by endpoint: %v", err)
+	}
+	defer rows.Close()
+
+	endpoints := []APIEndpointUsage{}
+	for rows.Next() {
+		var e APIEndpointUsage
+		if err := rows.Scan(&e.Endpoint, &e.Calls, &e.Errors, &e.RateLimited, &e.AvgDurationMs, &e.P95DurationMs); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, rows.Err()
+}
+
+// GetRecentRateLimits returns the last limit 429s in [from, to), newest first
+func GetRecentRateLimits(pool *pgxpool.Pool, from, to time.Time, limit int) ([]APIRateLimit, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT called_at, endpoint, retry_after_s
+		FROM spotify_api_log
+		WHERE status = 429 AND called_at >= $1 AND called_at < $2
+		ORDER BY called_at DESC
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Spotify rate limits: %v", err)
+	}
+	defer rows.Close()
+
+	limits := []APIRateLimit{}
+	for rows.Next() {
+		var l APIRateLimit
+		if err := rows.Scan(&l.At, &l.Endpoint, &l.RetryAfterS); err != nil {
+			return nil, err
+		}
+		limits = append(limits, l)
+	}
+	return limits, rows.Err()
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PlayArchiveStats counts the plays in the live table and in the archive
+type PlayArchiveStats struct {
+	Live           int        `json:"live"`
+	Archived       int        `json:"archived"`
+	OldestLive     *time.Time `json:"oldest_live"`
+	NewestArchived *time.Time `json:"newest_archived"`
+}
+
+// GetPlayArchiveStats counts live and archived plays
+func GetPlayArchiveStats(pool *pgxpool.Pool) (PlayArchiveStats, error) {
+	var s PlayArchiveStats
+	err := pool.QueryRow(context.Background(), `
+		SELECT (SELECT COUNT(*) FROM ONLY recently_played),
+			(SELECT COUNT(*) FROM recently_played_archive),
+			(SELECT MIN(played_at) FROM ONLY recently_played),
+			(SELECT MAX(played_at) FROM recently_played_archive)`).
+		Scan(&s.Live, &s.Archived, &s.OldestLive, &s.NewestArchived)
+	if err != nil {
+		return s, fmt.Errorf("failed to count archived plays: %v", err)
+	}
+	return s, nil
+}
+
+// CountPlaysToArchive counts the live plays played before cutoff
+func CountPlaysToArchive(pool *pgxpool.Pool, before time.Time) (int, error) {
+	var n int
+	err := pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM ONLY recently_played WHERE played_at < $1`, before).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count plays to archive: %v", err)
+	}
+	return n, nil
+}
+
+// ArchivePlays moves live plays played before cutoff to
+// recently_played_archive, oldest first, batchSize per transaction so a long
+// run holds no lock for long and can be canceled between batches. Annotations
+// and artists stay with their plays. progress gets the running total after
+// each batch. Returns how many plays were moved.
+func ArchivePlays(ctx context.Context, pool *pgxpool.Pool, before time.Time, batchSize int, progress func(moved int)) (int, error) {
+	dropped, err := dropArchivedCopies(ctx, pool, before)
+	if err != nil {
+		return 0, err
+	}
+	if dropped > 0 {
+		fmt.Printf("🧹 dropped %d live copies of archived plays\n", dropped)
+	}
+
+	moved := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return moved, err
+		}
+		n, err := archiveBatch(ctx, pool, before, batchSize)
+		if err != nil {
+			return moved, err
+		}
+		moved += n
+		if progress != nil {
+			progress(moved)
+		}
+		if n < batchSize {
+			return moved, nil
+		}
+	}
+}
+
+// dropArchivedCopies deletes live plays before cutoff that are already in
+// the archive, stored again before recently_played_skip_archived existed.
+// Their annotations go to the archived play unless it has its own; the
+// delete trigger removes the rest of their details.
+func dropArchivedCopies(ctx context.Context, pool *pgxpool.Pool, before time.Time) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE play_annotations pa SET play_id = arc.id
+		FROM ONLY recently_played rp
+		JOIN recently_played_archive arc
+			ON arc.spotify_song_id = rp.spotify_song_id AND arc.played_at = rp.played_at
+		WHERE pa.play_id = rp.id AND rp.played_at < $1
+		  AND NOT EXISTS (SELECT 1 FROM play_annotations own WHERE own.play_id = arc.id)`, before); err != nil {
+		return 0, fmt.Errorf("failed to move annotations of archived copies: %v", err)
+	}
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM ONLY recently_played rp
+		USING recently_played_archive arc
+		WHERE arc.spotify_song_id = rp.spotify_song_id AND arc.played_at = rp.played_at
+		  AND rp.played_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to drop archived copies: %v", err)
+	}
+	return int(tag.RowsAffected()), tx.Commit(ctx)
+}
+
+func archiveBatch(ctx context.Context, pool *pgxpool.Pool, before time.Time, batchSize int) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Tells the delete trigger the play lives on, so its details stay
+	if _, err := tx.Exec(ctx, `SELECT set_config('spotifydb.archiving', 'on', true)`); err != nil {
+		return 0, err
+	}
+	// The archive inherits recently_played, so SELECT * lines up column for
+	// column. dropArchivedCopies already removed plays that are in both.
+	var n int
+	err = tx.QueryRow(ctx, `
+		WITH moved AS (
+			DELETE FROM ONLY recently_played
+			WHERE id IN (
+				SELECT id FROM ONLY recently_played
+				WHERE played_at < $1
+				ORDER BY played_at, id
+				LIMIT $2
+			)
+			RETURNING *
+		),
+		archived AS (
+			INSERT INTO recently_played_archive
+			SELECT * FROM moved
+			ON CONFLICT DO NOTHING
+			RETURNING 1
+		)
+		SELECT COUNT(*) FROM moved`, before, batchSize).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive plays: %v", err)
+	}
+	return n, tx.Commit(ctx)
+}
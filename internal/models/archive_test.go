@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var initTestDB sync.Once
+
+// testPool connects to TEST_DATABASE_URL and migrates it, skipping the test
+// when it isn't set. Tests share the database, so they only touch rows they
+// created, and date plays before 2000 to stay clear of real history.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	initTestDB.Do(func() {
+		os.Setenv("DATABASE_URL", dsn)
+		repository.InitDB()
+	})
+	return repository.Pool
+}
+
+// testTrackID returns an id no real play has, deleting its plays (archived
+// ones included) when the test ends
+func testTrackID(t *testing.T, pool *pgxpool.Pool) string {
+	id := fmt.Sprintf("test%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), `DELETE FROM recently_played WHERE spotify_song_id = $1`, id)
+	})
+	return id
+}
+
+func countPlays(t *testing.T, pool *pgxpool.Pool, from, id string) int {
+	t.Helper()
+	var n int
+	if err := pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM `+from+` WHERE spotify_song_id = $1`, id).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestArchivedPlayCollectedAgain(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	id := testTrackID(t, pool)
+	playedAt := time.Date(1990, 1, 1, 12, 0, 0, 0, time.UTC)
+	play := RecentlyPlayedTrack{
+		SpotifySongID: id, TrackName: "Archived", ArtistName: "Someone", AlbumName: "Old",
+		DurationMS: 180_000, PlayedAt: playedAt, Source: SourceCron,
+	}
+
+	if _, err := InsertRecentlyPlayed(ctx, play); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ArchivePlays(ctx, pool, playedAt.Add(time.Second), 100, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := countPlays(t, pool, "recently_played_archive", id); n != 1 {
+		t.Fatalf("%d archived plays, want 1", n)
+	}
+
+	// collected again, one at a time and in a batch
+	if inserted, err := InsertRecentlyPlayed(ctx, play); err != nil || inserted {
+		t.Fatalf("InsertRecentlyPlayed() = %v, %v for an archived play, want false, nil", inserted, err)
+	}
+	inserted, err := InsertRecentlyPlayedBatch([]RecentlyPlayedRow{{
+		SpotifyID: id, TrackName: play.TrackName, ArtistName: play.ArtistName, PlayedAt: playedAt,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted[0] {
+		t.Error("batch insert reported the archived play as new")
+	}
+
+	if n := countPlays(t, pool, "recently_played", id); n != 1 {
+		t.Errorf("%d plays counted after collecting an archived play again, want 1", n)
+	}
+	if n := countPlays(t, pool, "ONLY recently_played", id); n != 0 {
+		t.Errorf("%d live copies of the archived play, want 0", n)
+	}
+
+	// the next archive run still finds its artists
+	if _, err := ArchivePlays(ctx, pool, playedAt.Add(time.Second), 100, nil); err != nil {
+		t.Fatal(err)
+	}
+	var artists int
+	if err := pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM play_artists pa
+		JOIN recently_played_archive arc ON arc.id = pa.play_id
+		WHERE arc.spotify_song_id = $1`, id).Scan(&artists); err != nil {
+		t.Fatal(err)
+	}
+	if artists != 1 {
+		t.Errorf("archived play has %d artists, want 1", artists)
+	}
+}
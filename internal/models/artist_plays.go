@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// artistPlaysFilter matches the plays crediting artist $1 (by name,
+// case-insensitively), only as the primary artist when $2 is true
+const artistPlaysFilter = `
+		EXISTS (SELECT 1 FROM play_artists pa
+		        WHERE pa.play_id = rp.id AND LOWER(pa.artist_name) = LOWER($1)
+		          AND (NOT $2 OR pa.position = 0))`
+
+// GetArtistPlays aggregates my plays of one artist, matched case-insensitively
+// by name, with their most played tracks (up to limit). Plays the artist is
+// featured on count too unless primaryOnly.
+func GetArtistPlays(pool *pgxpool.Pool, artistName string, limit int, primaryOnly bool) (*ArtistPlays, error) {
+	ctx := context.Background()
+
+	plays := ArtistPlays{TopTracks: []AlbumTrackPlays{}}
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE NOT EXISTS (
+				SELECT 1 FROM play_artists p0
+				WHERE p0.play_id = rp.id AND p0.position = 0 AND LOWER(p0.artist_name) = LOWER($1))),
+			COUNT(DISTINCT spotify_song_id), COALESCE(SUM(duration_ms), 0),
+			MIN(played_at), MAX(played_at)
+		FROM recently_played rp
+		WHERE`+artistPlaysFilter, artistName, primaryOnly).
+		Scan(&plays.PlayCount, &plays.FeaturedPlays, &plays.DistinctTracks, &plays.TotalMs, &plays.FirstPlayed, &plays.LastPlayed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artist plays: %v", err)
+	}
+	if plays.PlayCount == 0 {
+		return &plays, nil
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT spotify_song_id, MAX(track_name), COUNT(*),
+			COALESCE(SUM(duration_ms), 0), MAX(played_at)
+		FROM recently_played rp
+		WHERE`+artistPlaysFilter+`
+		GROUP BY spotify_song_id
+		ORDER BY COUNT(*) DESC, MAX(played_at) DESC
+		LIMIT $3`, artistName, primaryOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artist top tracks: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t AlbumTrackPlays
+		if err := rows.Scan(&t.SpotifySongID, &t.TrackName, &t.PlayCount, &t.TotalMs, &t.LastPlayed); err != nil {
+			return nil, err
+		}
+		plays.TopTracks = append(plays.TopTracks, t)
+	}
+	return &plays, rows.Err()
+}
+
+// GetTopArtists ranks artists by my plays between from and to (nil for
+// unbounded), optionally only plays stored by source. Every artist credited
+// on a play counts it, unless primaryOnly.
+func GetTopArtists(pool *pgxpool.Pool, from, to *time.Time, source string, limit int, primaryOnly bool) ([]TopArtist, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT t.artist_name,
+			COALESCE(t.artist_id, (SELECT MIN(a.artist_id) FROM artists a WHERE a.name = t.artist_name)),
+			t.plays, t.featured, t.tracks, t.total_ms, t.last_played
+		FROM (
+			SELECT pa.artist_name, MIN(pa.artist_id) AS artist_id,
+				COUNT(DISTINCT rp.id) AS plays,
+				COUNT(DISTINCT rp.id) FILTER (WHERE pa.position > 0) AS featured,
+				COUNT(DISTINCT rp.spotify_song_id) AS tracks,
+				COALESCE(SUM(rp.duration_ms), 0) AS total_ms, MAX(rp.played_at) AS last_played
+			FROM recently_played rp
+			JOIN play_artists pa ON pa.play_id = rp.id
+			WHERE ($1::timestamptz IS NULL OR rp.played_at >= $1)
+			  AND ($2::timestamptz IS NULL OR rp.played_at <= $2)
+			  AND ($3 = '' OR rp.source = $3)
+			  AND (NOT $5 OR pa.position = 0)
+			GROUP BY pa.artist_name
+			ORDER BY plays DESC, pa.artist_name
+			LIMIT $4
+		) t
+		ORDER BY t.plays DESC, t.artist_name`, from, to, source, limit, primaryOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top artists: %v", err)
+	}
+	defer rows.Close()
+
+	artists := []TopArtist{}
+	for rows.Next() {
+		var a TopArtist
+		if err := rows.Scan(&a.ArtistName, &a.ArtistID, &a.PlayCount, &a.FeaturedPlays, &a.DistinctTracks, &a.TotalMs, &a.LastPlayed); err != nil {
+			return nil, err
+		}
+		artists = append(artists, a)
+	}
+	return artists, rows.Err()
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/services"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ArtistRelationsMaxAge is how long an artist's stored related artists are
+// trusted before the related_artists backfill fetches them again
+const ArtistRelationsMaxAge = 30 * 24 * time.Hour
+
+// RelatedArtist is an artist Spotify relates to another, with my plays of it
+type RelatedArtist struct {
+	ArtistID     string     `json:"artist_id"`
+	Name         string     `json:"name"`
+	Genres       []string   `json:"genres"`
+	ImageURL     string     `json:"image_url,omitempty"`
+	Rank         int        `json:"rank"` // Spotify's order, 1 is the most related
+	Played       bool       `json:"played"`
+	Plays        int        `json:"plays"`
+	LastPlayedAt *time.Time `json:"last_played_at,omitempty"`
+}
+
+// staleRelationsSQL lists the artists credited on my plays whose related
+// artists weren't fetched since $1, with their plays
+const staleRelationsSQL = `
+	WITH stale AS (
+		SELECT pa.artist_id, COUNT(*) AS plays, MIN(a.related_checked_at) AS checked_at
+		FROM play_artists pa
+		LEFT JOIN artists a ON a.artist_id = pa.artist_id
+		WHERE pa.artist_id IS NOT NULL
+		  AND (a.related_checked_at IS NULL OR a.related_checked_at < $1)
+		GROUP BY pa.artist_id
+	)`
+
+// CountArtistsMissingRelations counts the artists I've played whose related
+// artists weren't fetched since before
+func CountArtistsMissingRelations(pool *pgxpool.Pool, before time.Time) (int, error) {
+	var n int
+	err := pool.QueryRow(context.Background(), staleRelationsSQL+`
+		SELECT COUNT(*) FROM stale`, before).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count artists missing relations: %v", err)
+	}
+	return n, nil
+}
+
+// GetArtistsMissingRelations returns up to limit artists I've played whose
+// related artists weren't fetched since before: never fetched first, then
+// the most played
+func GetArtistsMissingRelations(pool *pgxpool.Pool, before time.Time, limit int) ([]string, error) {
+	rows, err := pool.Query(context.Background(), staleRelationsSQL+`
+		SELECT artist_id FROM stale
+		ORDER BY checked_at NULLS FIRST, plays DESC
+		LIMIT $2`, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artists missing relations: %v", err)
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// SaveArtistRelations replaces the related artists stored for artistID with
+// related, in Spotify's order, and marks the artist as checked. The related
+// artists are cached in artists, since Spotify sends them in full.
+func SaveArtistRelations(pool *pgxpool.Pool, artistID string, related []services.Artist) error {
+	for i := range related {
+		if err := UpsertArtist(&related[i]); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	}
+
+	ids := make([]string, 0, len(related))
+	for _, a := range related {
+		ids = append(ids, a.ID)
+	}
+
+	ctx := context.Background()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM artist_relations WHERE artist_id = $1`, artistID); err != nil {
+		return fmt.Errorf("failed to clear relations of %s: %v", artistID, err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO artist_relations (artist_id, related_artist_id, rank)
+		SELECT $1, r.id, MIN(r.ord)
+		FROM UNNEST($2::text[]) WITH ORDINALITY AS r(id, ord)
+		WHERE r.id <> '' AND r.id <> $1
+		GROUP BY r.id`, artistID, ids); err != nil {
+		return fmt.Errorf("failed to save relations of %s: %v", artistID, err)
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE artists SET related_checked_at = NOW() WHERE artist_id = $1`, artistID); err != nil {
+		return fmt.Errorf("failed to mark relations of %s checked: %v", artistID, err)
+	}
+	return tx.Commit(ctx)
+}
+
+// GetRelatedArtists returns the stored related artists of artistID in
+// Spotify's order, each with my plays of it, matched by artist ID or, for
+// plays recorded without one, by name. With unplayedOnly only the artists I
+// haven't played are returned. checkedAt is when they were fetched, nil when
+// they never were.
+func GetRelatedArtists(pool *pgxpool.Pool, artistID string, unplayedOnly bool, limit int) ([]RelatedArtist, *time.Time, error) {
+	ctx := context.Background()
+	var checkedAt *time.Time
+	err := pool.QueryRow(ctx,
+		`SELECT related_checked_at FROM artists WHERE artist_id = $1`, artistID).Scan(&checkedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, fmt.Errorf("failed to get artist %s: %v", artistID, err)
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT r.related_artist_id, COALESCE(a.name, ''), COALESCE(a.genres, '{}'),
+			COALESCE(a.image_url, ''), r.rank, COALESCE(p.plays, 0), p.last_played_at
+		FROM artist_relations r
+		LEFT JOIN artists a ON a.artist_id = r.related_artist_id
+		LEFT JOIN LATERAL (
+			SELECT COUNT(DISTINCT rp.id) AS plays, MAX(rp.played_at) AS last_played_at
+			FROM play_artists pa
+			JOIN recently_played rp ON rp.id = pa.play_id
+			WHERE pa.artist_id = r.related_artist_id
+			   OR (pa.artist_id IS NULL AND LOWER(pa.artist_name) = LOWER(a.name))
+		) p ON TRUE
+		WHERE r.artist_id = $1
+		  AND (NOT $2::bool OR COALESCE(p.plays, 0) = 0)
+		ORDER BY r.rank
+		LIMIT $3`, artistID, unplayedOnly, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get related artists of %s: %v", artistID, err)
+	}
+	related, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (RelatedArtist, error) {
+		var r RelatedArtist
+		err := row.Scan(&r.ArtistID, &r.Name, &r.Genres, &r.ImageURL, &r.Rank, &r.Plays, &r.LastPlayedAt)
+		r.Played = r.Plays > 0
+		return r, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if related == nil {
+		related = []RelatedArtist{}
+	}
+	return related, checkedAt, nil
+}
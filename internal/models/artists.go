@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CachedArtist is a row from the artists table
+type CachedArtist struct {
+	ArtistID      string    `json:"artist_id"`
+	Name          string    `json:"name"`
+	Genres        []string  `json:"genres"`
+	ImageURL      string    `json:"image_url"`
+	LastRefreshed time.Time `json:"last_refreshed"`
+}
+
+// GetCachedArtist returns the cached artist, or nil if we've never fetched it
+func GetCachedArtist(artistID string) (*CachedArtist, error) {
+	var a CachedArtist
+	err := repository.Pool.QueryRow(context.Background(), `
+		SELECT artist_id, name, genres, COALESCE(image_url, ''), last_refreshed
+		FROM artists
+		WHERE artist_id = $1`, artistID).
+		Scan(&a.ArtistID, &a.Name, &a.Genres, &a.ImageURL, &a.LastRefreshed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached artist %s: %v", artistID, err)
+	}
+	return &a, nil
+}
+
+// UpsertArtist stores a freshly fetched Spotify artist and bumps last_refreshed
+func UpsertArtist(artist *services.Artist) error {
+	imageURL := ""
+	if len(artist.Images) > 0 {
+		imageURL = artist.Images[0].URL
+	}
+	images, err := json.Marshal(artist.Images)
+	if err != nil {
+		return err
+	}
+	genres := artist.Genres
+	if genres == nil {
+		genres = []string{}
+	}
+
+	_, err = repository.Pool.Exec(context.Background(), `
+		INSERT INTO artists (artist_id, name, genres, image_url, images, last_refreshed)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (artist_id) DO UPDATE
+		  SET name           = EXCLUDED.name,
+		      genres         = EXCLUDED.genres,
+		      image_url      = EXCLUDED.image_url,
+		      images         = EXCLUDED.images,
+		      last_refreshed = NOW()`,
+		artist.ID, artist.Name, genres, imageURL, string(images))
+	if err != nil {
+		return fmt.Errorf("failed to upsert artist %s: %v", artist.ID, err)
+	}
+	return nil
+}
+
+// GetStaleArtistIDs returns up to limit artists not refreshed within maxAge, oldest first
+func GetStaleArtistIDs(maxAge time.Duration, limit int) ([]string, error) {
+	rows, err := repository.Pool.Query(context.Background(), `
+		SELECT artist_id
+		FROM artists
+		WHERE last_refreshed < $1
+		ORDER BY last_refreshed
+		LIMIT $2`, time.Now().Add(-maxAge), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stale artists: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetCachedArtists returns the cached rows for the given IDs keyed by artist ID.
+// Missing artists are simply absent from the map.
+func GetCachedArtists(artistIDs []string) (map[string]*CachedArtist, error) {
+	cached := make(map[string]*CachedArtist, len(artistIDs))
+	if len(artistIDs) == 0 {
+		return cached, nil
+	}
+
+	rows, err := repository.Pool.Query(context.Background(), `
+		SELECT artist_id, name, genres, COALESCE(image_url, ''), last_refreshed
+		FROM artists
+		WHERE artist_id = ANY($1)`, artistIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached artists: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a CachedArtist
+		if err := rows.Scan(&a.ArtistID, &a.Name, &a.Genres, &a.ImageURL, &a.LastRefreshed); err != nil {
+			return nil, err
+		}
+		cached[a.ArtistID] = &a
+	}
+	return cached, rows.Err()
+}
+
+// FetchArtistsBatched resolves artists by ID, serving cached ones from the
+// artists table and fetching the rest from Spotify 50 per request. On error
+// (e.g. a 429) it returns whatever was resolved so far along with the error.
+func FetchArtistsBatched(ctx context.Context, accessToken string, artistIDs []string, rateLimiter *utils.RateLimiter) (map[string]*CachedArtist, error) {
+	resolved, err := GetCachedArtists(artistIDs)
+	if err != nil {
+		// Treat a cache failure as a cold cache rather than giving up
+		fmt.Printf("⚠️  %v\n", err)
+		resolved = map[string]*CachedArtist{}
+	}
+
+	var missing []string
+	seen := map[string]bool{}
+	for _, id := range artistIDs {
+		if id == "" || seen[id] || resolved[id] != nil {
+			continue
+		}
+		seen[id] = true
+		missing = append(missing, id)
+	}
+
+	for start := 0; start < len(missing); start += services.MaxArtistsPerRequest {
+		end := min(start+services.MaxArtistsPerRequest, len(missing))
+		chunk := missing[start:end]
+
+		var artists []services.Artist
+		err := rateLimiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			artists, fetchErr = services.GetArtistsByIds(ctx, accessToken, chunk)
+			return fetchErr
+		}, 2)
+		if err != nil {
+			return resolved, err
+		}
+
+		for i := range artists {
+			a := &artists[i]
+			if err := UpsertArtist(a); err != nil {
+				fmt.Printf("⚠️  %v\n", err)
+			}
+			resolved[a.ID] = &CachedArtist{ArtistID: a.ID, Name: a.Name, Genres: a.Genres}
+		}
+	}
+
+	return resolved, nil
+}
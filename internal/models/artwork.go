@@ -0,0 +1,40 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetAlbumCoverURL returns the stored cover of a track, preferring the saved
+// track's over the latest play's. It returns "" when none is stored.
+func GetAlbumCoverURL(pool *pgxpool.Pool, spotifyID string) (string, error) {
+	var url string
+	err := pool.QueryRow(context.Background(), `
+		SELECT COALESCE(
+			(SELECT NULLIF(album_cover_url, '') FROM recently_liked WHERE spotify_song_id = $1),
+			(SELECT NULLIF(album_cover_url, '') FROM recently_played
+			 WHERE spotify_song_id = $1 AND COALESCE(album_cover_url, '') <> ''
+			 ORDER BY played_at DESC LIMIT 1),
+			'')`, spotifyID).Scan(&url)
+	if err != nil {
+		return "", fmt.Errorf("failed to get album cover of %s: %v", spotifyID, err)
+	}
+	return url, nil
+}
+
+// SetAlbumCoverURL replaces a track's stored cover on its plays and saved
+// track, once Spotify has moved the image
+func SetAlbumCoverURL(pool *pgxpool.Pool, spotifyID, url string) error {
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx,
+		`UPDATE recently_played SET album_cover_url = $2 WHERE spotify_song_id = $1`, spotifyID, url); err != nil {
+		return fmt.Errorf("failed to update album cover of %s: %v", spotifyID, err)
+	}
+	if _, err := pool.Exec(ctx,
+		`UPDATE recently_liked SET album_cover_url = $2 WHERE spotify_song_id = $1`, spotifyID, url); err != nil {
+		return fmt.Errorf("failed to update album cover of %s: %v", spotifyID, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/spotifydb/internal/services"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CountMissingAudioFeatures returns how many played tracks have not been
+// looked up in audio_features yet
+func CountMissingAudioFeatures(pool *pgxpool.Pool) (int, error) {
+	var n int
+	err := pool.QueryRow(context.Background(), `
+		SELECT COUNT(DISTINCT rp.spotify_song_id)
+		FROM recently_played rp
+		LEFT JOIN audio_features af ON af.spotify_song_id = rp.spotify_song_id
+		WHERE af.spotify_song_id IS NULL`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tracks missing audio features: %v", err)
+	}
+	return n, nil
+}
+
+// GetTracksMissingAudioFeatures returns up to limit played track ids not yet
+// looked up, most recently played first
+func GetTracksMissingAudioFeatures(pool *pgxpool.Pool, limit int) ([]string, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT rp.spotify_song_id
+		FROM recently_played rp
+		LEFT JOIN audio_features af ON af.spotify_song_id = rp.spotify_song_id
+		WHERE af.spotify_song_id IS NULL
+		GROUP BY rp.spotify_song_id
+		ORDER BY MAX(rp.played_at) DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tracks missing audio features: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SaveAudioFeatures stores the features Spotify returned. Requested ids
+// without features get an empty row so they aren't looked up again.
+func SaveAudioFeatures(pool *pgxpool.Pool, ids []string, features []services.AudioFeatures) (int, error) {
+	ctx := context.Background()
+	found := make(map[string]bool, len(features))
+
+	for _, f := range features {
+		_, err := pool.Exec(ctx, `
+			INSERT INTO audio_features (
+				spotify_song_id, danceability, energy, valence, tempo, acousticness,
+				instrumentalness, speechiness, liveness, loudness, key, mode, time_signature
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT (spotify_song_id) DO UPDATE SET
+				danceability = EXCLUDED.danceability,
+				energy = EXCLUDED.energy,
+				valence = EXCLUDED.valence,
+				tempo = EXCLUDED.tempo,
+				acousticness = EXCLUDED.acousticness,
+				instrumentalness = EXCLUDED.instrumentalness,
+				speechiness = EXCLUDED.speechiness,
+				liveness = EXCLUDED.liveness,
+				loudness = EXCLUDED.loudness,
+				key = EXCLUDED.key,
+				mode = EXCLUDED.mode,
+				time_signature = EXCLUDED.time_signature,
+				fetched_at = NOW()`,
+			f.ID, f.Danceability, f.Energy, f.Valence, f.Tempo, f.Acousticness,
+			f.Instrumentalness, f.Speechiness, f.Liveness, f.Loudness, f.Key, f.Mode, f.TimeSignature)
+		if err != nil {
+			return len(found), fmt.Errorf("failed to save audio features for %s: %v", f.ID, err)
+		}
+		found[f.ID] = true
+	}
+
+	for _, id := range ids {
+		if found[id] {
+			continue
+		}
+		if _, err := pool.Exec(ctx, `
+			INSERT INTO audio_features (spotify_song_id) VALUES ($1)
+			ON CONFLICT (spotify_song_id) DO NOTHING`, id); err != nil {
+			return len(found), fmt.Errorf("failed to mark audio features missing for %s: %v", id, err)
+		}
+	}
+	return len(found), nil
+}
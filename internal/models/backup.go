@@ -0,0 +1,181 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BackupTables are the tables a backup holds, in restore order: parents
+// before the tables referencing them, and the archive before
+// recently_played, whose id sequence must end up past both. Credentials
+// (spotify_auth) and operational state (jobs, recovery_checkpoints,
+// spotify_api_log) stay behind.
+var BackupTables = []string{
+	"recently_played_archive",
+	"recently_played",
+	"recently_liked",
+	"tracks_on_repeat",
+	"artists",
+	"genres",
+	"track_genres",
+	"play_artists",
+	"play_annotations",
+	"canonical_tracks",
+	"external_metadata",
+	"audio_features",
+	"listening_reports",
+	"now_playing_log",
+	"queue_log",
+	"discovery_feed",
+	"artist_releases",
+	"artist_relations",
+	"milestones",
+	"collection_gaps",
+	"webhooks",
+}
+
+// DumpTable streams every row of table as a JSON object, Postgres' own
+// row_to_json, so any column type round-trips through RestoreRows. Rows of
+// inheriting tables (the archive) are left to their own dump. Returns how
+// many rows fn took.
+func DumpTable(ctx context.Context, pool *pgxpool.Pool, table string, fn func(row []byte) error) (int, error) {
+	if !slices.Contains(BackupTables, table) {
+		return 0, fmt.Errorf("%s is not a backup table", table)
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(`SELECT row_to_json(t)::text FROM ONLY %s t`, table))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	dumped := 0
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return dumped, err
+		}
+		if err := fn(row); err != nil {
+			return dumped, err
+		}
+		dumped++
+	}
+	return dumped, rows.Err()
+}
+
+// TableColumns returns the columns of table in this database, in order
+func TableColumns(ctx context.Context, pool *pgxpool.Pool, table string) ([]string, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %v", table, err)
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s doesn't exist", table)
+	}
+	return columns, nil
+}
+
+// primaryKey returns the primary key columns of table, none when it has no primary key
+func primaryKey(ctx context.Context, pool *pgxpool.Pool, table string) ([]string, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT a.attname
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = $1::regclass AND i.indisprimary
+		ORDER BY array_position(i.indkey, a.attnum)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the primary key of %s: %v", table, err)
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// RestoreRows upserts rows (JSON objects as DumpTable writes them) into table,
+// setting only columns: a row whose primary key exists is overwritten, so
+// restoring the same backup twice changes nothing. Returns how many rows were
+// written.
+func RestoreRows(ctx context.Context, pool *pgxpool.Pool, table string, columns []string, rows []json.RawMessage) (int, error) {
+	if !slices.Contains(BackupTables, table) {
+		return 0, fmt.Errorf("%s is not a backup table", table)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	key, err := primaryKey(ctx, pool, table)
+	if err != nil {
+		return 0, err
+	}
+
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = pgx.Identifier{c}.Sanitize()
+	}
+	conflict := "ON CONFLICT DO NOTHING"
+	if len(key) > 0 {
+		var set []string
+		for _, c := range columns {
+			if !slices.Contains(key, c) {
+				set = append(set, fmt.Sprintf("%s = EXCLUDED.%[1]s", pgx.Identifier{c}.Sanitize()))
+			}
+		}
+		keyCols := make([]string, len(key))
+		for i, c := range key {
+			keyCols[i] = pgx.Identifier{c}.Sanitize()
+		}
+		if len(set) > 0 {
+			conflict = fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keyCols, ", "), strings.Join(set, ", "))
+		} else {
+			conflict = fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(keyCols, ", "))
+		}
+	}
+
+	batch, err := json.Marshal(rows)
+	if err != nil {
+		return 0, err
+	}
+	cols := strings.Join(quoted, ", ")
+	tag, err := pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %[1]s (%[2]s)
+		SELECT %[2]s FROM json_populate_recordset(NULL::%[1]s, $1::json)
+		%[3]s`, table, cols, conflict), string(batch))
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore %s: %v", table, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ResetSequences moves the id sequences of table past its highest id, so rows
+// inserted after a restore don't collide with restored ones
+func ResetSequences(ctx context.Context, pool *pgxpool.Pool, table string) error {
+	columns, err := TableColumns(ctx, pool, table)
+	if err != nil {
+		return err
+	}
+	for _, c := range columns {
+		var seq *string
+		if err := pool.QueryRow(ctx, `SELECT pg_get_serial_sequence($1, $2)`, table, c).Scan(&seq); err != nil {
+			return err
+		}
+		if seq == nil {
+			continue
+		}
+		col := pgx.Identifier{c}.Sanitize()
+		if _, err := pool.Exec(ctx, fmt.Sprintf(
+			`SELECT setval($1, COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)`, col, table), *seq); err != nil {
+			return fmt.Errorf("failed to reset %s: %v", *seq, err)
+		}
+	}
+	return nil
+}
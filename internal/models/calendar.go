@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetCalendar returns one entry per day (in the configured timezone) from
+// `from` through today, including days without plays, for a GitHub-style heatmap
+func GetCalendar(pool *pgxpool.Pool, from time.Time) ([]CalendarDay, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT
+			to_char(d, 'YYYY-MM-DD'),
+			COUNT(rp.id),
+			COALESCE(SUM(rp.duration_ms), 0)
+		FROM generate_series($1::date, (NOW() AT TIME ZONE $2)::date, INTERVAL '1 day') AS d
+		LEFT JOIN recently_played rp
+			ON rp.played_at >= d AT TIME ZONE $2 AND rp.played_at < (d + INTERVAL '1 day') AT TIME ZONE $2
+		GROUP BY d
+		ORDER BY d`, from.Format("2006-01-02"), config.Timezone().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get listening calendar: %v", err)
+	}
+	defer rows.Close()
+
+	days := []CalendarDay{}
+	for rows.Next() {
+		var day CalendarDay
+		if err := rows.Scan(&day.Date, &day.Plays, &day.TotalMs); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// GetListeningStreaks returns the longest run of consecutive listening days
+// and the run still going (ending today or yesterday in the configured
+// timezone). Either is nil when there is no such streak.
+func GetListeningStreaks(pool *pgxpool.Pool) (longest, current *ListeningStreak, err error) {
+	rows, err := pool.Query(context.Background(), `
+		WITH play_dates AS (
+			SELECT DISTINCT (played_at AT TIME ZONE $1)::date AS d
+			FROM recently_played
+		),
+		streaks AS (
+			SELECT MIN(d) AS streak_start, MAX(d) AS streak_end, COUNT(*) AS days
+			FROM (
+				SELECT d, d - (ROW_NUMBER() OVER (ORDER BY d))::int AS grp
+				FROM play_dates
+			) grouped
+			GROUP BY grp
+		)
+		(SELECT 'longest', streak_start, streak_end, days
+		 FROM streaks
+		 ORDER BY days DESC, streak_end DESC
+		 LIMIT 1)
+		UNION ALL
+		(SELECT 'current', streak_start, streak_end, days
+		 FROM streaks
+		 WHERE streak_end >= (NOW() AT TIME ZONE $1)::date - 1
+		 ORDER BY streak_end DESC
+		 LIMIT 1)`, config.Timezone().String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get listening streaks: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var start, end time.Time
+		var days int
+		if err := rows.Scan(&kind, &start, &end, &days); err != nil {
+			return nil, nil, err
+		}
+		streak := &ListeningStreak{
+			Days:  days,
+			Start: start.Format("2006-01-02"),
+			End:   end.Format("2006-01-02"),
+		}
+		if kind == "longest" {
+			longest = streak
+		} else {
+			current = streak
+		}
+	}
+	return longest, current, rows.Err()
+}
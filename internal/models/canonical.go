@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetUnresolvedTrackIDs returns played track ids that have no canonical_tracks
+// row yet, most recently played first
+func GetUnresolvedTrackIDs(pool *pgxpool.Pool, limit int) ([]string, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT rp.spotify_song_id
+		FROM recently_played rp
+		LEFT JOIN canonical_tracks ct ON ct.spotify_song_id = rp.spotify_song_id
+		WHERE ct.spotify_song_id IS NULL
+		GROUP BY rp.spotify_song_id
+		ORDER BY MAX(rp.played_at) DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unresolved tracks: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SeedCanonicalFromPlays adds canonical_tracks rows for plays whose ISRC was
+// captured at collection time, so they don't need a Spotify lookup
+func SeedCanonicalFromPlays(pool *pgxpool.Pool) (int64, error) {
+	tag, err := pool.Exec(context.Background(), `
+		INSERT INTO canonical_tracks (spotify_song_id, isrc, canonical_id)
+		SELECT DISTINCT ON (rp.spotify_song_id) rp.spotify_song_id, rp.isrc, rp.spotify_song_id
+		FROM recently_played rp
+		WHERE rp.isrc IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM canonical_tracks ct WHERE ct.spotify_song_id = rp.spotify_song_id)
+		ORDER BY rp.spotify_song_id, rp.played_at DESC
+		ON CONFLICT (spotify_song_id) DO NOTHING`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to seed canonical tracks: %v", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SaveTrackISRCs records the ISRC of each track (empty when Spotify has none
+// or no longer knows the track). Each track starts out as its own canonical id.
+func SaveTrackISRCs(pool *pgxpool.Pool, isrcs map[string]string) error {
+	for id, isrc := range isrcs {
+		var isrcArg *string
+		if isrc != "" {
+			isrcArg = &isrc
+		}
+		if _, err := pool.Exec(context.Background(), `
+			INSERT INTO canonical_tracks (spotify_song_id, isrc, canonical_id)
+			VALUES ($1, $2, $1)
+			ON CONFLICT (spotify_song_id) DO UPDATE
+				SET isrc = EXCLUDED.isrc, resolved_at = NOW()`, id, isrcArg); err != nil {
+			return fmt.Errorf("failed to save isrc for %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// RecomputeCanonicalTracks points every track sharing an ISRC at the variant
+// that was played first, returning how many mappings changed
+func RecomputeCanonicalTracks(pool *pgxpool.Pool) (int64, error) {
+	tag, err := pool.Exec(context.Background(), `
+		WITH first_plays AS (
+			SELECT ct.spotify_song_id, ct.isrc, MIN(rp.played_at) AS first_played
+			FROM canonical_tracks ct
+			LEFT JOIN recently_played rp ON rp.spotify_song_id = ct.spotify_song_id
+			WHERE ct.isrc IS NOT NULL
+			GROUP BY ct.spotify_song_id, ct.isrc
+		),
+		winners AS (
+			SELECT DISTINCT ON (isrc) isrc, spotify_song_id AS canonical_id
+			FROM first_plays
+			ORDER BY isrc, first_played NULLS LAST, spotify_song_id
+		)
+		UPDATE canonical_tracks ct
+		SET canonical_id = w.canonical_id
+		FROM winners w
+		WHERE ct.isrc = w.isrc AND ct.canonical_id <> w.canonical_id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recompute canonical tracks: %v", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CollectorRun is one stored run of a cron collector. APICalls and
+// RateLimited are the Spotify requests and 429s made while it ran.
+type CollectorRun struct {
+	ID          int64     `json:"id"`
+	Collector   string    `json:"collector"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	Fetched     int       `json:"fetched"`
+	Inserted    int       `json:"inserted"`
+	Skipped     int       `json:"skipped"`
+	APICalls    int       `json:"api_calls"`
+	RateLimited int       `json:"rate_limited"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// CollectorHealth sums up one collector's recent runs
+type CollectorHealth struct {
+	Collector     string     `json:"collector"`
+	LastRunAt     time.Time  `json:"last_run_at"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	LastError     string     `json:"last_error,omitempty"` // set when the last run failed
+	Runs24h       int        `json:"runs_24h"`
+	Failures24h   int        `json:"failures_24h"`
+	Inserted24h   int        `json:"inserted_24h"`
+	Skipped24h    int        `json:"skipped_24h"`
+	APICalls24h   int        `json:"api_calls_24h"`
+}
+
+// InsertCollectorRun stores one collector run
+func InsertCollectorRun(pool *pgxpool.Pool, r CollectorRun) error {
+	_, err := pool.Exec(context.Background(), `
+		INSERT INTO collector_runs
+			(collector, started_at, finished_at, fetched, inserted, skipped, api_calls, rate_limited, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))`,
+		r.Collector, r.StartedAt, r.FinishedAt, r.Fetched, r.Inserted, r.Skipped,
+		r.APICalls, r.RateLimited, r.Error)
+	if err != nil {
+		return fmt.Errorf("failed to store collector run: %v", err)
+	}
+	return nil
+}
+
+// PurgeCollectorRuns deletes runs started before the given time and returns how many
+func PurgeCollectorRuns(pool *pgxpool.Pool, before time.Time) (int, error) {
+	tag, err := pool.Exec(context.Background(), `DELETE FROM collector_runs WHERE started_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge collector runs: %v", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// GetCollectorHealth sums up every collector that has run, by name
+func GetCollectorHealth(pool *pgxpool.Pool) ([]CollectorHealth, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT DISTINCT ON (r.collector) r.collector, r.started_at, COALESCE(r.error, ''),
+			(SELECT MAX(s.finished_at) FROM collector_runs s WHERE s.collector = r.collector AND s.error IS NULL),
+			d.runs, d.failures, d.inserted, d.skipped, d.api_calls
+		FROM collector_runs r
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS runs, COUNT(*) FILTER (WHERE error IS NOT NULL) AS failures,
+				COALESCE(SUM(inserted), 0) AS inserted, COALESCE(SUM(skipped), 0) AS skipped,
+				COALESCE(SUM(api_calls), 0) AS api_calls
+			FROM collector_runs
+			WHERE collector = r.collector AND started_at >= NOW() - INTERVAL '24 hours'
+		) d
+		ORDER BY r.collector, r.started_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collector health: %v", err)
+	}
+	defer rows.Close()
+
+	health := []CollectorHealth{}
+	for rows.Next() {
+		var h CollectorHealth
+		if err := rows.Scan(&h.Collector, &h.LastRunAt, &h.LastError, &h.LastSuccessAt,
+			&h.Runs24h, &h.Failures24h, &h.Inserted24h, &h.Skipped24h, &h.APICalls24h); err != nil {
+			return nil, err
+		}
+		health = append(health, h)
+	}
+	return health, rows.Err()
+}
+
+// GetCollectorUsage sums the Spotify requests the collectors made since
+// callsSince and the 429s they got since rateLimitedSince
+func GetCollectorUsage(pool *pgxpool.Pool, callsSince, rateLimitedSince time.Time) (calls, rateLimited int, err error) {
+	err = pool.QueryRow(context.Background(), `
+		SELECT COALESCE(SUM(api_calls) FILTER (WHERE started_at >= $1), 0),
+			COALESCE(SUM(rate_limited) FILTER (WHERE started_at >= $2), 0)
+		FROM collector_runs
+		WHERE started_at >= LEAST($1, $2)`, callsSince, rateLimitedSince).Scan(&calls, &rateLimited)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum collector API usage: %v", err)
+	}
+	return calls, rateLimited, nil
+}
+
+// GetCollectorRuns returns the latest limit runs, newest first, only those of
+// collector unless it's empty
+func GetCollectorRuns(pool *pgxpool.Pool, collector string, limit int) ([]CollectorRun, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT id, collector, started_at, finished_at, fetched, inserted, skipped,
+			api_calls, rate_limited, COALESCE(error, '')
+		FROM collector_runs
+		WHERE $1 = '' OR collector = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2`, collector, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collector runs: %v", err)
+	}
+	runs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (CollectorRun, error) {
+		var r CollectorRun
+		err := row.Scan(&r.ID, &r.Collector, &r.StartedAt, &r.FinishedAt, &r.Fetched, &r.Inserted,
+			&r.Skipped, &r.APICalls, &r.RateLimited, &r.Error)
+		return r, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []CollectorRun{}
+	}
+	return runs, nil
+}
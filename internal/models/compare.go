@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// compareDimensions holds, per dimension, a query producing (key, name,
+// artist) for every play between $1 and $2. Artists count every artist
+// credited on a play; comparePrimaryArtists only the primary one.
+var compareDimensions = map[string]string{
+	"artists": `
+		SELECT pa.artist_name AS key, pa.artist_name AS name, NULL::text AS artist
+		FROM recently_played rp
+		JOIN play_artists pa ON pa.play_id = rp.id
+		WHERE rp.played_at >= $1 AND rp.played_at < $2`,
+	"tracks": `
+		SELECT spotify_song_id AS key, track_name AS name, artist_name AS artist
+		FROM recently_played
+		WHERE played_at >= $1 AND played_at < $2`,
+	"genres": `
+		SELECT g.name AS key, g.name AS name, NULL::text AS artist
+		FROM recently_played rp
+		JOIN track_genres tg ON tg.spotify_song_id = rp.spotify_song_id
+		JOIN genres g ON g.id = tg.genre_id
+		WHERE rp.played_at >= $1 AND rp.played_at < $2`,
+}
+
+const comparePrimaryArtists = `
+		SELECT pa.artist_name AS key, pa.artist_name AS name, NULL::text AS artist
+		FROM recently_played rp
+		JOIN play_artists pa ON pa.play_id = rp.id AND pa.position = 0
+		WHERE rp.played_at >= $1 AND rp.played_at < $2`
+
+// CompareWindows diffs play counts for one dimension (artists, tracks or
+// genres) between window A [fromA, toA) and window B [fromB, toB). Each
+// category keeps the limit entries with the largest change. primaryOnly
+// leaves featured artists out of the artists dimension.
+func CompareWindows(pool *pgxpool.Pool, dimension string, fromA, toA, fromB, toB time.Time, limit int, primaryOnly bool) (*Comparison, error) {
+	plays, ok := compareDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown comparison dimension %q", dimension)
+	}
+	if dimension == "artists" && primaryOnly {
+		plays = comparePrimaryArtists
+	}
+
+	query := fmt.Sprintf(`
+		WITH a AS (
+			SELECT key, MIN(name) AS name, MIN(artist) AS artist, COUNT(*) AS plays
+			FROM (%[1]s) p GROUP BY key
+		),
+		b AS (
+			SELECT key, MIN(name) AS name, MIN(artist) AS artist, COUNT(*) AS plays
+			FROM (%[2]s) p GROUP BY key
+		),
+		joined AS (
+			SELECT
+				COALESCE(b.key, a.key) AS key,
+				COALESCE(b.name, a.name) AS name,
+				COALESCE(b.artist, a.artist) AS artist,
+				COALESCE(a.plays, 0) AS plays_a,
+				COALESCE(b.plays, 0) AS plays_b
+			FROM a FULL OUTER JOIN b ON a.key = b.key
+		),
+		classified AS (
+			SELECT *,
+				plays_b - plays_a AS delta,
+				CASE
+					WHEN plays_a = 0 THEN 'new'
+					WHEN plays_b = 0 THEN 'dropped'
+					WHEN plays_b > plays_a THEN 'rose'
+					WHEN plays_b < plays_a THEN 'fell'
+				END AS change
+			FROM joined
+		)
+		SELECT change, key, name, artist, plays_a, plays_b, delta
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY change ORDER BY ABS(delta) DESC, key) AS rank
+			FROM classified
+			WHERE change IS NOT NULL
+		) ranked
+		WHERE rank <= $5
+		ORDER BY change, rank`,
+		plays, replacePlaceholders(plays))
+
+	rows, err := pool.Query(context.Background(), query, fromA, toA, fromB, toB, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare %s: %v", dimension, err)
+	}
+	defer rows.Close()
+
+	cmp := &Comparison{
+		New:     []ComparisonEntry{},
+		Dropped: []ComparisonEntry{},
+		Rose:    []ComparisonEntry{},
+		Fell:    []ComparisonEntry{},
+	}
+	for rows.Next() {
+		var change string
+		var e ComparisonEntry
+		if err := rows.Scan(&change, &e.Key, &e.Name, &e.Artist, &e.PlaysA, &e.PlaysB, &e.Delta); err != nil {
+			return nil, err
+		}
+		switch change {
+		case "new":
+			cmp.New = append(cmp.New, e)
+		case "dropped":
+			cmp.Dropped = append(cmp.Dropped, e)
+		case "rose":
+			cmp.Rose = append(cmp.Rose, e)
+		case "fell":
+			cmp.Fell = append(cmp.Fell, e)
+		}
+	}
+	return cmp, rows.Err()
+}
+
+// CountPlaysBetween counts plays in [from, to)
+func CountPlaysBetween(pool *pgxpool.Pool, from, to time.Time) (int, error) {
+	var n int
+	err := pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM recently_played WHERE played_at >= $1 AND played_at < $2`,
+		from, to).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count plays: %v", err)
+	}
+	return n, nil
+}
+
+// replacePlaceholders points a window query at $3/$4 (window B)
+func replacePlaceholders(query string) string {
+	return strings.NewReplacer("$1", "$3", "$2", "$4").Replace(query)
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NearDuplicate is a stored play that repeats the play before it of the same
+// track within the dedup window; merging folds it into KeepID
+type NearDuplicate struct {
+	ID            int       `json:"id"`
+	KeepID        int       `json:"keep_id"`
+	SpotifySongID string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	ArtistName    string    `json:"artist_name"`
+	PlayedAt      time.Time `json:"played_at"`
+	KeptPlayedAt  time.Time `json:"kept_played_at"`
+}
+
+// nearDuplicatesSQL pairs each near-duplicate play with the earliest play of
+// its run: plays of a track each within $1 seconds of the previous one form a run,
+// so a play reported three times is kept once
+const nearDuplicatesSQL = `
+	WITH ordered AS (
+		SELECT id, spotify_song_id, played_at,
+			CASE WHEN played_at - LAG(played_at) OVER w <= $1::float8 * INTERVAL '1 second' THEN 0 ELSE 1 END AS starts_run
+		FROM recently_played
+		WINDOW w AS (PARTITION BY spotify_song_id ORDER BY played_at, id)
+	),
+	runs AS (
+		SELECT id, spotify_song_id, played_at,
+			SUM(starts_run) OVER (PARTITION BY spotify_song_id ORDER BY played_at, id) AS run
+		FROM ordered
+	),
+	keepers AS (
+		SELECT DISTINCT ON (spotify_song_id, run) spotify_song_id, run, id AS keep_id, played_at AS kept_played_at
+		FROM runs
+		ORDER BY spotify_song_id, run, played_at, id
+	)
+	SELECT r.id, k.keep_id, r.spotify_song_id, r.played_at, k.kept_played_at
+	FROM runs r
+	JOIN keepers k ON k.spotify_song_id = r.spotify_song_id AND k.run = r.run
+	WHERE r.id <> k.keep_id`
+
+// FindNearDuplicatePlays returns up to limit stored plays that repeat the
+// previous play of their track within window, newest first, and how many
+// there are in all
+func FindNearDuplicatePlays(pool *pgxpool.Pool, window time.Duration, limit int) ([]NearDuplicate, int, error) {
+	ctx := context.Background()
+	var total int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM (`+nearDuplicatesSQL+`) d`, window.Seconds()).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count near-duplicate plays: %v", err)
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT d.id, d.keep_id, d.spotify_song_id, rp.track_name, COALESCE(rp.artist_name, ''),
+			d.played_at, d.kept_played_at
+		FROM (`+nearDuplicatesSQL+`) d
+		JOIN recently_played rp ON rp.id = d.id
+		ORDER BY d.played_at DESC
+		LIMIT $2`, window.Seconds(), limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find near-duplicate plays: %v", err)
+	}
+	defer rows.Close()
+
+	dups := []NearDuplicate{}
+	for rows.Next() {
+		var d NearDuplicate
+		if err := rows.Scan(&d.ID, &d.KeepID, &d.SpotifySongID, &d.TrackName, &d.ArtistName,
+			&d.PlayedAt, &d.KeptPlayedAt); err != nil {
+			return nil, 0, err
+		}
+		dups = append(dups, d)
+	}
+	return dups, total, rows.Err()
+}
+
+// MergeNearDuplicatePlays folds every near-duplicate play into the earliest
+// play of its run in one transaction: details the kept play lacks (context,
+// device, metadata, release date) and annotations are copied over, then the
+// duplicates are deleted. It returns how many plays were deleted.
+func MergeNearDuplicatePlays(ctx context.Context, pool *pgxpool.Pool, window time.Duration) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE play_duplicates (
+			id INTEGER PRIMARY KEY,
+			keep_id INTEGER NOT NULL,
+			spotify_song_id VARCHAR(255),
+			played_at TIMESTAMPTZ,
+			kept_played_at TIMESTAMPTZ
+		) ON COMMIT DROP`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO play_duplicates `+nearDuplicatesSQL, window.Seconds()); err != nil {
+		return 0, fmt.Errorf("failed to find near-duplicate plays: %v", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE recently_played k SET
+			context_type = COALESCE(k.context_type, d.context_type),
+			context_uri = COALESCE(k.context_uri, d.context_uri),
+			device_name = COALESCE(k.device_name, d.device_name),
+			device_type = COALESCE(k.device_type, d.device_type),
+			shuffle_state = COALESCE(k.shuffle_state, d.shuffle_state),
+			repeat_state = COALESCE(k.repeat_state, d.repeat_state),
+			isrc = COALESCE(k.isrc, d.isrc),
+			explicit = COALESCE(k.explicit, d.explicit),
+			album_release_date = COALESCE(k.album_release_date, d.album_release_date),
+			album_release_date_precision = COALESCE(k.album_release_date_precision, d.album_release_date_precision),
+			album_cover_url = COALESCE(NULLIF(k.album_cover_url, ''), d.album_cover_url),
+			genre = COALESCE(NULLIF(k.genre, ''), d.genre)
+		FROM play_duplicates pd
+		JOIN recently_played d ON d.id = pd.id
+		WHERE k.id = pd.keep_id`); err != nil {
+		return 0, fmt.Errorf("failed to merge near-duplicate plays: %v", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO play_annotations (play_id, mood, activity, note, created_at, updated_at)
+		SELECT pd.keep_id, a.mood, a.activity, a.note, a.created_at, a.updated_at
+		FROM play_duplicates pd
+		JOIN play_annotations a ON a.play_id = pd.id
+		ON CONFLICT (play_id) DO NOTHING`); err != nil {
+		return 0, fmt.Errorf("failed to move annotations of near-duplicate plays: %v", err)
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM recently_played WHERE id IN (SELECT id FROM play_duplicates)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete near-duplicate plays: %v", err)
+	}
+	return int(tag.RowsAffected()), tx.Commit(ctx)
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DiscoverySorts maps the ?sort= values accepted for discoveries to SQL
+var DiscoverySorts = map[string]string{
+	"replays":      "replays DESC, f.first_played_at DESC",
+	"first_played": "f.first_played_at DESC",
+}
+
+// GetDiscoveries returns tracks whose first-ever play (see track_first_plays)
+// falls in [from, to), with replays counted up to now, plus the total number
+// of discoveries and how many were replayed at least once. Nil bounds are
+// open-ended.
+func GetDiscoveries(pool *pgxpool.Pool, from, to *time.Time, sort string, limit int) (tracks []Discovery, total, replayed int, err error) {
+	orderBy, ok := DiscoverySorts[sort]
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("unknown sort %q", sort)
+	}
+
+	rows, err := pool.Query(context.Background(), `
+		SELECT
+			f.spotify_song_id,
+			MAX(rp.track_name),
+			COALESCE(MAX(rp.artist_name), ''),
+			COALESCE(MAX(rp.album_cover_url), ''),
+			f.first_played_at,
+			MAX(rp.played_at),
+			COUNT(*) - 1 AS replays,
+			COUNT(*) OVER () AS total,
+			SUM(CASE WHEN COUNT(*) > 1 THEN 1 ELSE 0 END) OVER () AS replayed
+		FROM track_first_plays f
+		JOIN recently_played rp ON rp.spotify_song_id = f.spotify_song_id
+		WHERE ($1::timestamptz IS NULL OR f.first_played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR f.first_played_at < $2)
+		GROUP BY f.spotify_song_id, f.first_played_at
+		ORDER BY `+orderBy+`
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to get discoveries: %v", err)
+	}
+	defer rows.Close()
+
+	tracks = []Discovery{}
+	for rows.Next() {
+		var d Discovery
+		if err := rows.Scan(&d.SpotifySongID, &d.TrackName, &d.ArtistName, &d.AlbumCoverUrl,
+			&d.FirstPlayed, &d.LastPlayed, &d.Replays, &total, &replayed); err != nil {
+			return nil, 0, 0, err
+		}
+		tracks = append(tracks, d)
+	}
+	return tracks, total, replayed, rows.Err()
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DiscoveryCandidate is one suggestion in the discovery feed. Kind is "track"
+// (from recommendations) or "album" (from new releases).
+type DiscoveryCandidate struct {
+	Kind         string    `json:"kind"`
+	SpotifyID    string    `json:"spotify_id"`
+	Name         string    `json:"name"`
+	ArtistName   string    `json:"artist_name"`
+	ArtistID     string    `json:"artist_id"`
+	AlbumName    string    `json:"album_name,omitempty"`
+	ImageURL     string    `json:"image_url,omitempty"`
+	ReleaseDate  string    `json:"release_date,omitempty"`
+	Source       string    `json:"source"` // new_releases or recommendations
+	Reason       string    `json:"reason"`
+	Score        int       `json:"score"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+}
+
+// DiscoverySeed is a top track, artist or genre used to seed recommendations
+type DiscoverySeed struct {
+	ID    string `json:"id,omitempty"` // track or artist id; empty for genres
+	Name  string `json:"name"`
+	Plays int    `json:"plays"`
+}
+
+// notInHistory matches discovery rows I've already played or liked: tracks by
+// id, albums by album and primary artist name
+const notInHistory = `
+	CASE d.kind
+		WHEN 'track' THEN NOT EXISTS (SELECT 1 FROM recently_played rp WHERE rp.spotify_song_id = d.spotify_id)
+			AND NOT EXISTS (SELECT 1 FROM recently_liked rl WHERE rl.spotify_song_id = d.spotify_id)
+		ELSE NOT EXISTS (SELECT 1 FROM recently_played rp
+			WHERE LOWER(rp.album_name) = LOWER(d.name) AND LOWER(rp.artist_name) = LOWER(d.artist_name))
+	END`
+
+// GetTopTrackSeeds returns my most played tracks since the given time (nil
+// for all time)
+func GetTopTrackSeeds(pool *pgxpool.Pool, since *time.Time, limit int) ([]DiscoverySeed, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT spotify_song_id, MAX(track_name), COUNT(*) AS plays
+		FROM recently_played
+		WHERE ($2::timestamptz IS NULL OR played_at >= $2)
+		GROUP BY spotify_song_id
+		ORDER BY plays DESC, MAX(played_at) DESC
+		LIMIT $1`, limit, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top tracks: %v", err)
+	}
+	defer rows.Close()
+
+	var seeds []DiscoverySeed
+	for rows.Next() {
+		var s DiscoverySeed
+		if err := rows.Scan(&s.ID, &s.Name, &s.Plays); err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, rows.Err()
+}
+
+// GetTopArtistSeeds returns my most played artists since the given time (nil
+// for all time) that have a cached Spotify id (plays only store the artist name)
+func GetTopArtistSeeds(pool *pgxpool.Pool, since *time.Time, limit int) ([]DiscoverySeed, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT MIN(a.artist_id), rp.artist_name, COUNT(*) AS plays
+		FROM recently_played rp
+		JOIN artists a ON a.name = rp.artist_name
+		WHERE ($2::timestamptz IS NULL OR rp.played_at >= $2)
+		GROUP BY rp.artist_name
+		ORDER BY plays DESC
+		LIMIT $1`, limit, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top artists: %v", err)
+	}
+	defer rows.Close()
+
+	var seeds []DiscoverySeed
+	for rows.Next() {
+		var s DiscoverySeed
+		if err := rows.Scan(&s.ID, &s.Name, &s.Plays); err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, rows.Err()
+}
+
+// GetTopGenreSeeds returns the genres I play most since the given time (nil
+// for all time)
+func GetTopGenreSeeds(pool *pgxpool.Pool, since *time.Time, limit int) ([]DiscoverySeed, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT g.name, COUNT(*) AS plays
+		FROM recently_played rp
+		JOIN track_genres tg ON tg.spotify_song_id = rp.spotify_song_id
+		JOIN genres g ON g.id = tg.genre_id
+		WHERE ($2::timestamptz IS NULL OR rp.played_at >= $2)
+		GROUP BY g.name
+		ORDER BY plays DESC
+		LIMIT $1`, limit, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top genres: %v", err)
+	}
+	defer rows.Close()
+
+	var seeds []DiscoverySeed
+	for rows.Next() {
+		var s DiscoverySeed
+		if err := rows.Scan(&s.Name, &s.Plays); err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, rows.Err()
+}
+
+// SaveDiscoveryCandidates upserts candidates, skipping anything already in my
+// history, and returns how many were stored
+func SaveDiscoveryCandidates(pool *pgxpool.Pool, candidates []DiscoveryCandidate) (int, error) {
+	ctx := context.Background()
+	saved := 0
+	for _, c := range candidates {
+		tag, err := pool.Exec(ctx, `
+			INSERT INTO discovery_feed (kind, spotify_id, name, artist_name, artist_id,
+				album_name, image_url, release_date, source, reason, score, discovered_at)
+			SELECT d.kind, d.spotify_id, d.name, d.artist_name, $5::varchar, $6::text,
+				$7::text, $8::varchar, $9::varchar, $10::text, $11::int, NOW()
+			FROM (SELECT $1::varchar AS kind, $2::varchar AS spotify_id,
+				$3::text AS name, $4::text AS artist_name) d
+			WHERE `+notInHistory+`
+			ON CONFLICT (kind, spotify_id) DO UPDATE SET
+				reason = EXCLUDED.reason,
+				score = GREATEST(discovery_feed.score, EXCLUDED.score),
+				discovered_at = EXCLUDED.discovered_at`,
+			c.Kind, c.SpotifyID, c.Name, c.ArtistName, nullIfEmpty(c.ArtistID),
+			nullIfEmpty(c.AlbumName), nullIfEmpty(c.ImageURL), nullIfEmpty(c.ReleaseDate),
+			c.Source, c.Reason, c.Score)
+		if err != nil {
+			return saved, fmt.Errorf("failed to save discovery candidate %s: %v", c.SpotifyID, err)
+		}
+		saved += int(tag.RowsAffected())
+	}
+	return saved, nil
+}
+
+// PruneDiscoveryFeed drops candidates not seen again since before
+func PruneDiscoveryFeed(pool *pgxpool.Pool, before time.Time) (int64, error) {
+	tag, err := pool.Exec(context.Background(),
+		`DELETE FROM discovery_feed WHERE discovered_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune discovery feed: %v", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetDiscoveryFeed returns the highest ranked candidates I still haven't
+// played. kind filters to "track" or "album"; empty returns both.
+func GetDiscoveryFeed(pool *pgxpool.Pool, kind string, limit int) ([]DiscoveryCandidate, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT d.kind, d.spotify_id, d.name,
+			COALESCE(d.artist_name, ''), COALESCE(d.artist_id, ''), COALESCE(d.album_name, ''),
+			COALESCE(d.image_url, ''), COALESCE(d.release_date, ''),
+			d.source, d.reason, d.score, d.discovered_at
+		FROM discovery_feed d
+		WHERE ($1 = '' OR d.kind = $1) AND `+notInHistory+`
+		ORDER BY d.score DESC, d.discovered_at DESC
+		LIMIT $2`, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discovery feed: %v", err)
+	}
+	defer rows.Close()
+
+	feed := []DiscoveryCandidate{}
+	for rows.Next() {
+		var c DiscoveryCandidate
+		if err := rows.Scan(&c.Kind, &c.SpotifyID, &c.Name, &c.ArtistName, &c.ArtistID, &c.AlbumName,
+			&c.ImageURL, &c.ReleaseDate, &c.Source, &c.Reason, &c.Score, &c.DiscoveredAt); err != nil {
+			return nil, err
+		}
+		feed = append(feed, c)
+	}
+	return feed, rows.Err()
+}
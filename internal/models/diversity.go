@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DiversityCounts are the play counts of one window that the diversity
+// metrics are computed from
+type DiversityCounts struct {
+	Plays         int
+	UniqueTracks  int
+	UniqueArtists int
+	WeeklyArtists []int // distinct artists in each week of the window with plays
+	TrackPlays    []int // plays of each track
+	GenrePlays    []int // plays of each genre, via track_genres
+}
+
+// diversityPlays buckets plays since $3 into windows of unit $1 (week, month
+// or quarter), keyed by their first day in the timezone $2
+const diversityPlays = `
+		WITH plays AS (
+			SELECT to_char(date_trunc($1, played_at AT TIME ZONE $2), 'YYYY-MM-DD') AS w,
+			       date_trunc('week', played_at AT TIME ZONE $2) AS wk,
+			       spotify_song_id, NULLIF(artist_name, '') AS artist_name
+			FROM recently_played
+			WHERE played_at >= $3
+		)`
+
+// GetDiversityCounts returns the counts of every window of unit with plays
+// since from, keyed by the window's first day (YYYY-MM-DD)
+func GetDiversityCounts(pool *pgxpool.Pool, unit string, from time.Time) (map[string]*DiversityCounts, error) {
+	ctx := context.Background()
+	args := []any{unit, config.Timezone().String(), from}
+	windows := map[string]*DiversityCounts{}
+
+	rows, err := pool.Query(ctx, diversityPlays+`
+		SELECT w, COUNT(*), COUNT(DISTINCT spotify_song_id), COUNT(DISTINCT artist_name)
+		FROM plays
+		GROUP BY w`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count plays per window: %v", err)
+	}
+	for rows.Next() {
+		var w string
+		c := &DiversityCounts{}
+		if err := rows.Scan(&w, &c.Plays, &c.UniqueTracks, &c.UniqueArtists); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		windows[w] = c
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Each of these yields one count per window and week, track or genre
+	breakdowns := []struct {
+		query string
+		into  func(c *DiversityCounts, n int)
+	}{
+		{`SELECT w, COUNT(DISTINCT artist_name) FROM plays GROUP BY w, wk`,
+			func(c *DiversityCounts, n int) { c.WeeklyArtists = append(c.WeeklyArtists, n) }},
+		{`SELECT w, COUNT(*) FROM plays GROUP BY w, spotify_song_id`,
+			func(c *DiversityCounts, n int) { c.TrackPlays = append(c.TrackPlays, n) }},
+		{`SELECT p.w, COUNT(*) FROM plays p
+		  JOIN track_genres tg ON tg.spotify_song_id = p.spotify_song_id
+		  GROUP BY p.w, tg.genre_id`,
+			func(c *DiversityCounts, n int) { c.GenrePlays = append(c.GenrePlays, n) }},
+	}
+	for _, b := range breakdowns {
+		rows, err := pool.Query(ctx, diversityPlays+b.query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get diversity counts: %v", err)
+		}
+		for rows.Next() {
+			var w string
+			var n int
+			if err := rows.Scan(&w, &n); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if c, ok := windows[w]; ok {
+				b.into(c, n)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return windows, nil
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NostalgiaAge is how many years before a play its album must have come out
+// for the play to count towards the nostalgia index
+const NostalgiaAge = 10
+
+// EraBucket is my listening to music released in one decade or year
+type EraBucket struct {
+	Start         int     `json:"start"` // first release year in the bucket, e.g. 1990
+	Plays         int     `json:"plays"`
+	Tracks        int     `json:"tracks"`
+	MinutesPlayed float64 `json:"minutes_played"`
+	Percentage    float64 `json:"percentage"` // of plays with a known release date
+	TopArtist     string  `json:"top_artist"`
+}
+
+// EraSummary sums up how old the music I play is
+type EraSummary struct {
+	Plays          int     `json:"plays"`
+	DatedPlays     int     `json:"dated_plays"`     // plays whose album release date is known
+	NostalgiaPlays int     `json:"nostalgia_plays"` // dated plays of albums over NostalgiaAge years old
+	NostalgiaIndex float64 `json:"nostalgia_index"` // NostalgiaPlays / DatedPlays, 0-1
+	AverageAge     float64 `json:"average_age"`     // mean years between release and play
+	MedianYear     int     `json:"median_year"`     // median release year over dated plays; 0 without any
+}
+
+// datedPlays gives every play in [$1, $2) its album's release year, from the
+// play or, for plays collected before release dates were stored, from the
+// saved track. Spotify uses "0000" for unknown dates.
+const datedPlays = `
+	SELECT rp.spotify_song_id, rp.artist_name, rp.played_at,
+		COALESCE(rp.duration_ms, 0) AS duration_ms,
+		CASE WHEN d.date ~ '^[0-9]{4}' AND LEFT(d.date, 4)::int > 0 THEN LEFT(d.date, 4)::int END AS release_year
+	FROM recently_played rp
+	LEFT JOIN recently_liked rl ON rl.spotify_song_id = rp.spotify_song_id
+	CROSS JOIN LATERAL (SELECT COALESCE(NULLIF(rp.album_release_date, ''), rl.album_release_date) AS date) d
+	WHERE ($1::timestamptz IS NULL OR rp.played_at >= $1)
+	  AND ($2::timestamptz IS NULL OR rp.played_at < $2)`
+
+// GetEraBuckets groups plays in [from, to) by release year, bucketSize years
+// at a time (10 for decades, 1 for years), oldest first. Nil bounds are open.
+func GetEraBuckets(pool *pgxpool.Pool, from, to *time.Time, bucketSize int) ([]EraBucket, error) {
+	rows, err := pool.Query(context.Background(), `
+		WITH plays AS (
+			SELECT (release_year / $3) * $3 AS start, spotify_song_id, artist_name, duration_ms
+			FROM (`+datedPlays+`) dp
+			WHERE release_year IS NOT NULL
+		),
+		top_artists AS (
+			SELECT DISTINCT ON (start) start, artist_name
+			FROM plays
+			WHERE artist_name IS NOT NULL
+			GROUP BY start, artist_name
+			ORDER BY start, COUNT(*) DESC, artist_name
+		)
+		SELECT p.start, COUNT(*), COUNT(DISTINCT p.spotify_song_id),
+			ROUND(SUM(p.duration_ms) / 60000.0, 1),
+			ROUND(100.0 * COUNT(*) / SUM(COUNT(*)) OVER (), 1),
+			COALESCE(MIN(ta.artist_name), '')
+		FROM plays p
+		LEFT JOIN top_artists ta ON ta.start = p.start
+		GROUP BY p.start
+		ORDER BY p.start`, from, to, bucketSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get era buckets: %v", err)
+	}
+	defer rows.Close()
+
+	buckets := []EraBucket{}
+	for rows.Next() {
+		var b EraBucket
+		if err := rows.Scan(&b.Start, &b.Plays, &b.Tracks, &b.MinutesPlayed, &b.Percentage, &b.TopArtist); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// GetEraSummary returns the nostalgia index and release year spread of the
+// plays in [from, to); nil bounds are open
+func GetEraSummary(pool *pgxpool.Pool, from, to *time.Time) (EraSummary, error) {
+	var s EraSummary
+	err := pool.QueryRow(context.Background(), `
+		WITH plays AS (
+			SELECT release_year, EXTRACT(YEAR FROM played_at)::int - release_year AS age
+			FROM (`+datedPlays+`) dp
+		)
+		SELECT COUNT(*), COUNT(release_year),
+			COUNT(*) FILTER (WHERE age > $3),
+			COALESCE(ROUND(AVG(age), 1), 0)::float8,
+			COALESCE(PERCENTILE_DISC(0.5) WITHIN GROUP (ORDER BY release_year), 0)
+		FROM plays`, from, to, NostalgiaAge).
+		Scan(&s.Plays, &s.DatedPlays, &s.NostalgiaPlays, &s.AverageAge, &s.MedianYear)
+	if err != nil {
+		return s, fmt.Errorf("failed to get era summary: %v", err)
+	}
+	if s.DatedPlays > 0 {
+		s.NostalgiaIndex = float64(s.NostalgiaPlays) / float64(s.DatedPlays)
+	}
+	return s, nil
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/parquet-go/parquet-go"
+)
+
+// PlayExportRow is every column of recently_played worth analyzing; the
+// scrobbling bookkeeping is left out. Pointers are the nullable columns.
+type PlayExportRow struct {
+	ID                        int32     `db:"id" parquet:"id"`
+	SpotifySongID             string    `db:"spotify_song_id" parquet:"spotify_song_id"`
+	TrackName                 string    `db:"track_name" parquet:"track_name"`
+	ArtistName                *string   `db:"artist_name" parquet:"artist_name"`
+	AlbumName                 *string   `db:"album_name" parquet:"album_name"`
+	AlbumCoverURL             *string   `db:"album_cover_url" parquet:"album_cover_url"`
+	Genre                     *string   `db:"genre" parquet:"genre"`
+	GenreSource               *string   `db:"genre_source" parquet:"genre_source"`
+	DurationMS                *int32    `db:"duration_ms" parquet:"duration_ms"`
+	PlayedAt                  time.Time `db:"played_at" parquet:"played_at"`
+	Source                    *string   `db:"source" parquet:"source"`
+	ContextType               *string   `db:"context_type" parquet:"context_type"`
+	ContextURI                *string   `db:"context_uri" parquet:"context_uri"`
+	DeviceName                *string   `db:"device_name" parquet:"device_name"`
+	DeviceType                *string   `db:"device_type" parquet:"device_type"`
+	ShuffleState              *bool     `db:"shuffle_state" parquet:"shuffle_state"`
+	RepeatState               *string   `db:"repeat_state" parquet:"repeat_state"`
+	ISRC                      *string   `db:"isrc" parquet:"isrc"`
+	Explicit                  *bool     `db:"explicit" parquet:"explicit"`
+	DiscNumber                *int32    `db:"disc_number" parquet:"disc_number"`
+	TrackNumber               *int32    `db:"track_number" parquet:"track_number"`
+	AlbumReleaseDate          *string   `db:"album_release_date" parquet:"album_release_date"`
+	AlbumReleaseDatePrecision *string   `db:"album_release_date_precision" parquet:"album_release_date_precision"`
+	CompletionRatio           *float64  `db:"completion_ratio" parquet:"completion_ratio"`
+	Skipped                   *bool     `db:"skipped" parquet:"skipped"`
+}
+
+// LikedExportRow is every column of recently_liked, unliked tracks included
+type LikedExportRow struct {
+	ID                        int32      `db:"id" parquet:"id"`
+	SpotifySongID             string     `db:"spotify_song_id" parquet:"spotify_song_id"`
+	TrackName                 string     `db:"track_name" parquet:"track_name"`
+	TrackPopularity           *int32     `db:"track_popularity" parquet:"track_popularity"`
+	AlbumName                 *string    `db:"album_name" parquet:"album_name"`
+	AlbumType                 *string    `db:"album_type" parquet:"album_type"`
+	AlbumCoverURL             *string    `db:"album_cover_url" parquet:"album_cover_url"`
+	AlbumReleaseDate          *string    `db:"album_release_date" parquet:"album_release_date"`
+	AlbumReleaseDatePrecision *string    `db:"album_release_date_precision" parquet:"album_release_date_precision"`
+	ArtistName                *string    `db:"artist_name" parquet:"artist_name"`
+	ArtistID                  *string    `db:"artist_id" parquet:"artist_id"`
+	ArtistHref                *string    `db:"artist_href" parquet:"artist_href"`
+	ArtistURI                 *string    `db:"artist_uri" parquet:"artist_uri"`
+	AlbumTotalTracks          *int32     `db:"album_total_tracks" parquet:"album_total_tracks"`
+	AlbumCoverWidth           *int32     `db:"album_cover_width" parquet:"album_cover_width"`
+	AlbumCoverHeight          *int32     `db:"album_cover_height" parquet:"album_cover_height"`
+	Genre                     *string    `db:"genre" parquet:"genre"`
+	GenreSource               *string    `db:"genre_source" parquet:"genre_source"`
+	ISRC                      *string    `db:"isrc" parquet:"isrc"`
+	Explicit                  *bool      `db:"explicit" parquet:"explicit"`
+	DiscNumber                *int32     `db:"disc_number" parquet:"disc_number"`
+	TrackNumber               *int32     `db:"track_number" parquet:"track_number"`
+	AddedAt                   time.Time  `db:"added_at" parquet:"added_at"`
+	UnlikedAt                 *time.Time `db:"unliked_at" parquet:"unliked_at"`
+	Source                    *string    `db:"source" parquet:"source"`
+}
+
+// ExportTable is a table exported with its full schema, rows ordered by
+// TimeColumn, which from/to filter on
+type ExportTable struct {
+	Name       string
+	TimeColumn string
+	// exprs reads the columns not stored as their row field's type
+	exprs map[string]string
+	write func(ctx context.Context, pool *pgxpool.Pool, t ExportTable, w io.Writer, from, to *time.Time, source string) (int, error)
+}
+
+// RecentlyPlayedExport exports recently_played as PlayExportRows
+var RecentlyPlayedExport = ExportTable{
+	Name:       "recently_played",
+	TimeColumn: "played_at",
+	exprs:      map[string]string{"completion_ratio": "completion_ratio::float8"},
+	write:      writeParquet[PlayExportRow],
+}
+
+// RecentlyLikedExport exports recently_liked as LikedExportRows
+var RecentlyLikedExport = ExportTable{
+	Name:       "recently_liked",
+	TimeColumn: "added_at",
+	exprs:      map[string]string{"track_popularity": "NULLIF(track_popularity, '')::int"},
+	write:      writeParquet[LikedExportRow],
+}
+
+// WriteParquet writes the rows of the table with TimeColumn between from and
+// to (nil for open-ended), only those stored by source unless it's empty, to
+// w as a gzip compressed Parquet file. Returns how many rows were written.
+func (t ExportTable) WriteParquet(ctx context.Context, pool *pgxpool.Pool, w io.Writer, from, to *time.Time, source string) (int, error) {
+	return t.write(ctx, pool, t, w, from, to, source)
+}
+
+func writeParquet[T any](ctx context.Context, pool *pgxpool.Pool, t ExportTable, w io.Writer, from, to *time.Time, source string) (int, error) {
+	pw := parquet.NewGenericWriter[T](w, parquet.Compression(&parquet.Gzip))
+	written, err := forEachExportRow(ctx, pool, t, from, to, source, func(row T) error {
+		_, err := pw.Write([]T{row})
+		return err
+	})
+	if err != nil {
+		return written, err
+	}
+	return written, pw.Close()
+}
+
+// forEachExportRow streams the rows of table oldest first, calling fn with
+// each one read into a T by its db tags. Returns how many rows fn took.
+func forEachExportRow[T any](ctx context.Context, pool *pgxpool.Pool, table ExportTable, from, to *time.Time, source string, fn func(T) error) (int, error) {
+	rt := reflect.TypeFor[T]()
+	exprs := make([]string, rt.NumField())
+	for i := range exprs {
+		name := rt.Field(i).Tag.Get("db")
+		exprs[i] = name
+		if expr, ok := table.exprs[name]; ok {
+			exprs[i] = expr + " AS " + name
+		}
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE ($1::timestamptz IS NULL OR %[3]s >= $1)
+		  AND ($2::timestamptz IS NULL OR %[3]s <= $2)
+		  AND ($3 = '' OR source = $3)
+		ORDER BY %[3]s, id`, strings.Join(exprs, ", "), table.Name, table.TimeColumn), from, to, source)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", table.Name, err)
+	}
+	defer rows.Close()
+
+	written := 0
+	for rows.Next() {
+		row, err := pgx.RowToStructByName[T](rows)
+		if err != nil {
+			return written, err
+		}
+		if err := fn(row); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, rows.Err()
+}
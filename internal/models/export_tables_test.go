@@ -0,0 +1,89 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestExportRowTags(t *testing.T) {
+	for _, rt := range []reflect.Type{reflect.TypeFor[PlayExportRow](), reflect.TypeFor[LikedExportRow]()} {
+		for i := 0; i < rt.NumField(); i++ {
+			f := rt.Field(i)
+			if db, pq := f.Tag.Get("db"), f.Tag.Get("parquet"); db == "" || db != strings.Split(pq, ",")[0] {
+				t.Errorf("%s.%s: db column %q, parquet column %q", rt.Name(), f.Name, db, pq)
+			}
+		}
+	}
+}
+
+func TestExportRowSchema(t *testing.T) {
+	schema := parquet.SchemaOf(LikedExportRow{})
+	tests := []struct {
+		column   string
+		optional bool
+		logical  string
+	}{
+		{"id", false, "INT(32,true)"},
+		{"track_popularity", true, "INT(32,true)"},
+		{"added_at", false, "TIMESTAMP(isAdjustedToUTC=true,unit=NANOS)"},
+		{"unliked_at", true, "TIMESTAMP(isAdjustedToUTC=true,unit=NANOS)"},
+		{"explicit", true, ""},
+	}
+	for _, tt := range tests {
+		col, ok := schema.Lookup(tt.column)
+		if !ok {
+			t.Errorf("no column %s", tt.column)
+			continue
+		}
+		if col.Node.Optional() != tt.optional {
+			t.Errorf("%s: optional %v, want %v", tt.column, col.Node.Optional(), tt.optional)
+		}
+		var logical string
+		if lt := col.Node.Type().LogicalType(); lt != nil {
+			logical = lt.String()
+		}
+		if logical != tt.logical {
+			t.Errorf("%s: logical type %q, want %q", tt.column, logical, tt.logical)
+		}
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	id := testTrackID(t, pool)
+	playedAt := time.Date(1990, 1, 2, 12, 0, 0, 0, time.UTC)
+	if _, err := InsertRecentlyPlayed(ctx, RecentlyPlayedTrack{
+		SpotifySongID: id, TrackName: "Exported", ArtistName: "Someone", DurationMS: 180_000,
+		PlayedAt: playedAt, Source: SourceCron,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	from, to := playedAt.Add(-time.Second), playedAt.Add(time.Second)
+	written, err := RecentlyPlayedExport.WriteParquet(ctx, pool, &buf, &from, &to, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := parquet.Read[PlayExportRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 1 || len(rows) != 1 {
+		t.Fatalf("wrote %d rows, read back %d, want 1", written, len(rows))
+	}
+	got := rows[0]
+	if got.SpotifySongID != id || !got.PlayedAt.Equal(playedAt) || got.DurationMS == nil || *got.DurationMS != 180_000 {
+		t.Errorf("read back %+v", got)
+	}
+	if got.ContextURI != nil {
+		t.Errorf("context_uri = %q, want NULL", *got.ContextURI)
+	}
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ExternalMetadata is what MusicBrainz knows about the recording behind an
+// ISRC. Found is false when it doesn't know the ISRC.
+type ExternalMetadata struct {
+	ISRC          string
+	Found         bool
+	RecordingMBID string
+	ArtistMBIDs   []string
+	ArtistNames   []string
+	ReleaseMBID   string
+	ReleaseTitle  string
+	ReleaseYear   int // original release year of the recording; 0 when unknown
+	Label         string
+	LabelMBID     string
+}
+
+// DecadePlays is my listening to music from one release decade
+type DecadePlays struct {
+	Decade        int     `json:"decade"` // e.g. 1990
+	Plays         int     `json:"plays"`
+	Tracks        int     `json:"tracks"`
+	MinutesPlayed float64 `json:"minutes_played"`
+	Percentage    float64 `json:"percentage"` // of plays with a known release year
+	TopArtist     string  `json:"top_artist"`
+}
+
+// LabelPlays is my listening to one record label
+type LabelPlays struct {
+	Label         string  `json:"label"`
+	LabelMBID     string  `json:"label_mbid,omitempty"`
+	Plays         int     `json:"plays"`
+	Tracks        int     `json:"tracks"`
+	Artists       int     `json:"artists"`
+	MinutesPlayed float64 `json:"minutes_played"`
+	TopArtist     string  `json:"top_artist"`
+}
+
+// MetadataCoverage says how many plays in a window MusicBrainz data covers
+type MetadataCoverage struct {
+	Plays        int `json:"plays"`
+	WithISRC     int `json:"with_isrc"`
+	WithMetadata int `json:"with_metadata"` // looked up and found in MusicBrainz
+}
+
+// playISRC joins a play to its ISRC, taken from the play or, for plays
+// collected before ISRCs were stored, from canonical_tracks
+const playISRC = `
+	FROM recently_played rp
+	LEFT JOIN canonical_tracks ct ON ct.spotify_song_id = rp.spotify_song_id
+	LEFT JOIN external_metadata em ON em.isrc = COALESCE(rp.isrc, ct.isrc) AND em.found
+	WHERE ($1::timestamptz IS NULL OR rp.played_at >= $1)
+	  AND ($2::timestamptz IS NULL OR rp.played_at < $2)`
+
+// GetISRCsMissingMetadata returns up to limit ISRCs of played tracks that
+// haven't been looked up, plus ones MusicBrainz didn't know before
+// retryBefore, most played first
+func GetISRCsMissingMetadata(pool *pgxpool.Pool, limit int, retryBefore time.Time) ([]string, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT ct.isrc
+		FROM canonical_tracks ct
+		JOIN recently_played rp ON rp.spotify_song_id = ct.spotify_song_id
+		LEFT JOIN external_metadata em ON em.isrc = ct.isrc
+		WHERE ct.isrc IS NOT NULL
+		  AND (em.isrc IS NULL OR (NOT em.found AND em.looked_up_at < $2))
+		GROUP BY ct.isrc
+		ORDER BY COUNT(*) DESC
+		LIMIT $1`, limit, retryBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ISRCs missing metadata: %v", err)
+	}
+	defer rows.Close()
+
+	var isrcs []string
+	for rows.Next() {
+		var isrc string
+		if err := rows.Scan(&isrc); err != nil {
+			return nil, err
+		}
+		isrcs = append(isrcs, isrc)
+	}
+	return isrcs, rows.Err()
+}
+
+// CountISRCsMissingMetadata counts ISRCs of played tracks never looked up
+func CountISRCsMissingMetadata(pool *pgxpool.Pool) (int, error) {
+	var n int
+	err := pool.QueryRow(context.Background(), `
+		SELECT COUNT(DISTINCT ct.isrc)
+		FROM canonical_tracks ct
+		WHERE ct.isrc IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM external_metadata em WHERE em.isrc = ct.isrc)`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count ISRCs missing metadata: %v", err)
+	}
+	return n, nil
+}
+
+// SaveExternalMetadata stores or replaces the metadata of an ISRC
+func SaveExternalMetadata(pool *pgxpool.Pool, m ExternalMetadata) error {
+	var year *int
+	if m.ReleaseYear > 0 {
+		year = &m.ReleaseYear
+	}
+	_, err := pool.Exec(context.Background(), `
+		INSERT INTO external_metadata (isrc, found, recording_mbid, artist_mbids, artist_names,
+			release_mbid, release_title, release_year, label, label_mbid)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (isrc) DO UPDATE SET
+			found = EXCLUDED.found,
+			recording_mbid = EXCLUDED.recording_mbid,
+			artist_mbids = EXCLUDED.artist_mbids,
+			artist_names = EXCLUDED.artist_names,
+			release_mbid = EXCLUDED.release_mbid,
+			release_title = EXCLUDED.release_title,
+			release_year = EXCLUDED.release_year,
+			label = EXCLUDED.label,
+			label_mbid = EXCLUDED.label_mbid,
+			looked_up_at = NOW()`,
+		m.ISRC, m.Found, nullIfEmpty(m.RecordingMBID), m.ArtistMBIDs, m.ArtistNames,
+		nullIfEmpty(m.ReleaseMBID), nullIfEmpty(m.ReleaseTitle), year,
+		nullIfEmpty(m.Label), nullIfEmpty(m.LabelMBID))
+	if err != nil {
+		return fmt.Errorf("failed to save metadata for %s: %v", m.ISRC, err)
+	}
+	return nil
+}
+
+// GetMetadataCoverage counts the plays in [from, to) with an ISRC and with
+// MusicBrainz metadata; nil bounds are open
+func GetMetadataCoverage(pool *pgxpool.Pool, from, to *time.Time) (MetadataCoverage, error) {
+	var c MetadataCoverage
+	err := pool.QueryRow(context.Background(), `
+		SELECT COUNT(*), COUNT(COALESCE(rp.isrc, ct.isrc)), COUNT(em.isrc)`+playISRC, from, to).
+		Scan(&c.Plays, &c.WithISRC, &c.WithMetadata)
+	if err != nil {
+		return c, fmt.Errorf("failed to get metadata coverage: %v", err)
+	}
+	return c, nil
+}
+
+// GetPlaysByDecade groups plays in [from, to) by the decade their recording
+// was first released, per MusicBrainz, oldest decade first
+func GetPlaysByDecade(pool *pgxpool.Pool, from, to *time.Time) ([]DecadePlays, error) {
+	rows, err := pool.Query(context.Background(), `
+		WITH plays AS (
+			SELECT (em.release_year / 10) * 10 AS decade, rp.spotify_song_id, rp.artist_name,
+				COALESCE(rp.duration_ms, 0) AS duration_ms
+			`+playISRC+` AND em.release_year IS NOT NULL
+		),
+		top_artists AS (
+			SELECT DISTINCT ON (decade) decade, artist_name
+			FROM plays
+			WHERE artist_name IS NOT NULL
+			GROUP BY decade, artist_name
+			ORDER BY decade, COUNT(*) DESC, artist_name
+		)
+		SELECT p.decade, COUNT(*), COUNT(DISTINCT p.spotify_song_id),
+			ROUND(SUM(p.duration_ms) / 60000.0, 1),
+			ROUND(100.0 * COUNT(*) / SUM(COUNT(*)) OVER (), 1),
+			COALESCE(MIN(ta.artist_name), '')
+		FROM plays p
+		LEFT JOIN top_artists ta ON ta.decade = p.decade
+		GROUP BY p.decade
+		ORDER BY p.decade`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plays by decade: %v", err)
+	}
+	defer rows.Close()
+
+	decades := []DecadePlays{}
+	for rows.Next() {
+		var d DecadePlays
+		if err := rows.Scan(&d.Decade, &d.Plays, &d.Tracks, &d.MinutesPlayed, &d.Percentage, &d.TopArtist); err != nil {
+			return nil, err
+		}
+		decades = append(decades, d)
+	}
+	return decades, rows.Err()
+}
+
+// GetPlaysByLabel returns the limit labels with the most plays in [from, to),
+// per the label MusicBrainz lists on each recording's first release
+func GetPlaysByLabel(pool *pgxpool.Pool, from, to *time.Time, limit int) ([]LabelPlays, error) {
+	rows, err := pool.Query(context.Background(), `
+		WITH plays AS (
+			SELECT em.label, em.label_mbid, rp.spotify_song_id, rp.artist_name,
+				COALESCE(rp.duration_ms, 0) AS duration_ms
+			`+playISRC+` AND em.label IS NOT NULL
+		),
+		top_artists AS (
+			SELECT DISTINCT ON (label) label, artist_name
+			FROM plays
+			WHERE artist_name IS NOT NULL
+			GROUP BY label, artist_name
+			ORDER BY label, COUNT(*) DESC, artist_name
+		)
+		SELECT p.label, COALESCE(MIN(p.label_mbid), ''), COUNT(*), COUNT(DISTINCT p.spotify_song_id),
+			COUNT(DISTINCT p.artist_name), ROUND(SUM(p.duration_ms) / 60000.0, 1),
+			COALESCE(MIN(ta.artist_name), '')
+		FROM plays p
+		LEFT JOIN top_artists ta ON ta.label = p.label
+		GROUP BY p.label
+		ORDER BY COUNT(*) DESC, p.label
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plays by label: %v", err)
+	}
+	defer rows.Close()
+
+	labels := []LabelPlays{}
+	for rows.Next() {
+		var l LabelPlays
+		if err := rows.Scan(&l.Label, &l.LabelMBID, &l.Plays, &l.Tracks, &l.Artists, &l.MinutesPlayed,
+			&l.TopArtist); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
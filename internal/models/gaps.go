@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Collection gap kinds
+const (
+	GapNoPlays           = "no_plays"           // no plays for too long during active hours
+	GapCollectionFailing = "collection_failing" // recently-played collection kept failing
+)
+
+// GapKinds lists the kinds accepted by ?kind=
+var GapKinds = []string{GapNoPlays, GapCollectionFailing}
+
+// PlayGap is a silence between two stored plays. End is nil when nothing
+// has been played since Start.
+type PlayGap struct {
+	Start time.Time
+	End   *time.Time
+}
+
+// FindPlayGaps returns the silences of at least minGap that end after
+// since, including the one running now, oldest first
+func FindPlayGaps(pool *pgxpool.Pool, since time.Time, minGap time.Duration) ([]PlayGap, error) {
+	rows, err := pool.Query(context.Background(), `
+		WITH plays AS (
+			SELECT played_at FROM recently_played WHERE played_at >= $1
+			UNION ALL
+			(SELECT played_at FROM recently_played WHERE played_at < $1
+			 ORDER BY played_at DESC LIMIT 1)
+		), ordered AS (
+			SELECT played_at, LEAD(played_at) OVER (ORDER BY played_at) AS next_at
+			FROM plays
+		)
+		SELECT played_at, next_at FROM ordered
+		WHERE COALESCE(next_at, NOW()) - played_at >= make_interval(secs => $2)
+		ORDER BY played_at`, since, minGap.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find play gaps: %v", err)
+	}
+	defer rows.Close()
+
+	gaps := []PlayGap{}
+	for rows.Next() {
+		var g PlayGap
+		if err := rows.Scan(&g.Start, &g.End); err != nil {
+			return nil, err
+		}
+		gaps = append(gaps, g)
+	}
+	return gaps, rows.Err()
+}
+
+// UpsertCollectionGap records a gap, or updates the end, active minutes and
+// reason of the one already recorded with the same kind and start. inserted
+// reports whether the gap is new.
+func UpsertCollectionGap(pool *pgxpool.Pool, g CollectionGap) (inserted bool, err error) {
+	err = pool.QueryRow(context.Background(), `
+		INSERT INTO collection_gaps (kind, started_at, ended_at, active_minutes, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (kind, started_at) DO UPDATE SET
+			ended_at = EXCLUDED.ended_at,
+			active_minutes = EXCLUDED.active_minutes,
+			reason = EXCLUDED.reason,
+			updated_at = NOW()
+		RETURNING (xmax = 0)`,
+		g.Kind, g.StartedAt, g.EndedAt, g.ActiveMinutes, g.Reason).Scan(&inserted)
+	if err != nil {
+		return false, fmt.Errorf("failed to save %s gap: %v", g.Kind, err)
+	}
+	return inserted, nil
+}
+
+// OpenCollectionGaps returns the gaps still running, optionally of one kind
+func OpenCollectionGaps(pool *pgxpool.Pool, kind string) ([]CollectionGap, error) {
+	rows, err := pool.Query(context.Background(), collectionGapSelect+`
+		WHERE ($1 = '' OR kind = $1) AND ended_at IS NULL
+		ORDER BY started_at DESC`, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open collection gaps: %v", err)
+	}
+	return scanCollectionGaps(rows)
+}
+
+// GetCollectionGaps returns gaps overlapping [from, to), newest first,
+// optionally of one kind. Open gaps count as running until now.
+func GetCollectionGaps(pool *pgxpool.Pool, kind string, from, to *time.Time, limit int) ([]CollectionGap, error) {
+	rows, err := pool.Query(context.Background(), collectionGapSelect+`
+		WHERE ($1 = '' OR kind = $1)
+		  AND ($2::timestamptz IS NULL OR COALESCE(ended_at, NOW()) > $2)
+		  AND ($3::timestamptz IS NULL OR started_at < $3)
+		ORDER BY started_at DESC, id DESC
+		LIMIT $4`, kind, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection gaps: %v", err)
+	}
+	return scanCollectionGaps(rows)
+}
+
+// CountCollectionGaps counts gaps that started since the given time
+func CountCollectionGaps(pool *pgxpool.Pool, since time.Time) (int, error) {
+	var n int
+	err := pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM collection_gaps WHERE started_at >= $1`, since).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count collection gaps: %v", err)
+	}
+	return n, nil
+}
+
+const collectionGapSelect = `
+		SELECT id, kind, started_at, ended_at, active_minutes, COALESCE(reason, ''), detected_at
+		FROM collection_gaps`
+
+func scanCollectionGaps(rows pgx.Rows) ([]CollectionGap, error) {
+	defer rows.Close()
+	gaps := []CollectionGap{}
+	for rows.Next() {
+		var g CollectionGap
+		if err := rows.Scan(&g.ID, &g.Kind, &g.StartedAt, &g.EndedAt, &g.ActiveMinutes,
+			&g.Reason, &g.DetectedAt); err != nil {
+			return nil, err
+		}
+		gaps = append(gaps, g)
+	}
+	return gaps, rows.Err()
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"example.com/spotifydb/internal/services"
+	"example.com/spotifydb/internal/utils"
+)
+
+// Where a track's genres came from, stored in genre_source next to genre
+const (
+	GenreSourceArtist         = "artist"          // the track's primary artist
+	GenreSourceAlbumArtist    = "album_artist"    // the album's primary artist, for features and compilations
+	GenreSourceRelatedArtists = "related_artists" // the genre most of the artist's related artists share
+	GenreSourceNone           = "none"            // every fallback came up empty
+)
+
+// minRelatedGenreVotes is how many related artists must share a genre for it
+// to stand in for the artist's own
+const minRelatedGenreVotes = 2
+
+// GenreResolver runs the genre fallback chain for a batch of tracks: the
+// artist's own genres, else the album artist's, else the dominant genre of
+// the artist's related artists. Artists and related-artist lookups are
+// cached for the batch, so each costs at most one request.
+type GenreResolver struct {
+	ctx         context.Context
+	accessToken string
+	rateLimiter *utils.RateLimiter
+
+	artists map[string]*CachedArtist
+	related map[string][]string // artist ID -> dominant related genre, nil when there's none
+
+	// relatedUnavailable is set once Spotify refuses related-artists to this
+	// app, so the rest of the batch doesn't keep asking
+	relatedUnavailable bool
+}
+
+// NewGenreResolver returns a resolver for one batch
+func NewGenreResolver(ctx context.Context, accessToken string, rateLimiter *utils.RateLimiter) *GenreResolver {
+	return &GenreResolver{
+		ctx:         ctx,
+		accessToken: accessToken,
+		rateLimiter: rateLimiter,
+		artists:     map[string]*CachedArtist{},
+		related:     map[string][]string{},
+	}
+}
+
+// Prefetch resolves artists through the cache and /v1/artists in batches of
+// 50, so Resolve needn't fetch them one by one. Like FetchArtistsBatched it
+// keeps what it got before an error.
+func (r *GenreResolver) Prefetch(artistIDs []string) error {
+	var missing []string
+	for _, id := range artistIDs {
+		if _, ok := r.artists[id]; !ok && id != "" {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	fetched, err := FetchArtistsBatched(r.ctx, r.accessToken, missing, r.rateLimiter)
+	for id, a := range fetched {
+		r.artists[id] = a
+	}
+	if err == nil {
+		// Spotify doesn't know the rest; don't ask again
+		for _, id := range missing {
+			r.artists[id] = fetched[id]
+		}
+	}
+	return err
+}
+
+// Artist returns a prefetched artist, nil when it couldn't be resolved
+func (r *GenreResolver) Artist(artistID string) *CachedArtist {
+	return r.artists[artistID]
+}
+
+// Resolve returns the genres for a track by artistID on an album by
+// albumArtistID (empty when unknown) and which source they came from. No
+// genres and an empty source mean the whole chain came up empty. Errors
+// (a 429 included) mean the chain couldn't be completed and the track should
+// be retried later.
+func (r *GenreResolver) Resolve(artistID, albumArtistID string) ([]string, string, error) {
+	if err := r.Prefetch([]string{artistID, albumArtistID}); err != nil {
+		return nil, "", err
+	}
+	artist := r.artists[artistID]
+	if artist == nil {
+		return nil, "", fmt.Errorf("artist %s couldn't be resolved", artistID)
+	}
+	if len(artist.Genres) > 0 {
+		return artist.Genres, GenreSourceArtist, nil
+	}
+	if albumArtist := r.artists[albumArtistID]; albumArtistID != artistID && albumArtist != nil && len(albumArtist.Genres) > 0 {
+		return albumArtist.Genres, GenreSourceAlbumArtist, nil
+	}
+
+	genres, err := r.relatedGenre(artistID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(genres) > 0 {
+		return genres, GenreSourceRelatedArtists, nil
+	}
+	return nil, "", nil
+}
+
+// GetAlbumArtists returns the primary artist of each track's album, looking
+// tracks up 50 per request. On error it returns what it found so far.
+func GetAlbumArtists(ctx context.Context, accessToken string, trackIDs []string, rateLimiter *utils.RateLimiter) (map[string]string, error) {
+	albumArtists := make(map[string]string, len(trackIDs))
+	for start := 0; start < len(trackIDs); start += 50 {
+		chunk := trackIDs[start:min(start+50, len(trackIDs))]
+		var tracks []services.TrackDetails
+		err := rateLimiter.RetryWithBackoff(func() error {
+			var fetchErr error
+			tracks, fetchErr = services.GetTracksByIds(ctx, accessToken, chunk)
+			return fetchErr
+		}, 1)
+		if err != nil {
+			return albumArtists, err
+		}
+		for _, t := range tracks {
+			if len(t.Album.Artists) > 0 {
+				albumArtists[t.ID] = t.Album.Artists[0].ID
+			}
+		}
+	}
+	return albumArtists, nil
+}
+
+// relatedGenre returns the genre most of the artist's related artists share,
+// if at least minRelatedGenreVotes do
+func (r *GenreResolver) relatedGenre(artistID string) ([]string, error) {
+	if genres, ok := r.related[artistID]; ok {
+		return genres, nil
+	}
+	if r.relatedUnavailable {
+		return nil, nil
+	}
+
+	var related []services.Artist
+	err := r.rateLimiter.RetryWithBackoff(func() error {
+		var fetchErr error
+		related, fetchErr = services.GetRelatedArtists(r.ctx, r.accessToken, artistID)
+		return fetchErr
+	}, 1)
+	switch {
+	case errors.Is(err, services.ErrUnavailable):
+		fmt.Println("⚠️  related artists aren't available to this app; skipping that genre fallback")
+		r.relatedUnavailable = true
+		return nil, nil
+	case errors.Is(err, services.ErrNotFound):
+		r.related[artistID] = nil
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	votes := map[string]int{}
+	for i := range related {
+		// Related artists come with their genres, so cache them while here
+		if err := UpsertArtist(&related[i]); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+		for _, g := range normalizeGenres(related[i].Genres) {
+			votes[g]++
+		}
+	}
+	var genres []string
+	if g, n := dominantGenre(votes); n >= minRelatedGenreVotes {
+		genres = []string{g}
+	}
+	r.related[artistID] = genres
+	return genres, nil
+}
+
+// dominantGenre returns the genre with the most votes, the alphabetically
+// first on a tie, and its votes
+func dominantGenre(votes map[string]int) (string, int) {
+	names := make([]string, 0, len(votes))
+	for g := range votes {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	best, most := "", 0
+	for _, g := range names {
+		if votes[g] > most {
+			best, most = g, votes[g]
+		}
+	}
+	return best, most
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TimelineGranularities are the bucket sizes of a genre timeline, as date_trunc names them
+var TimelineGranularities = []string{"day", "week", "month"}
+
+// GenreSeries is one genre's plays and minutes per bucket of a GenreTimeline,
+// aligned with its Buckets
+type GenreSeries struct {
+	Genre      string    `json:"genre"`
+	TotalPlays int       `json:"total_plays"`
+	Plays      []int     `json:"plays"`
+	Minutes    []float64 `json:"minutes"`
+}
+
+// GenreTimeline is my top genres' listening over time. A play counts towards
+// every genre of its track, so the series overlap.
+type GenreTimeline struct {
+	Buckets []string      `json:"buckets"` // bucket start dates (YYYY-MM-DD), oldest first
+	Plays   []int         `json:"plays"`   // all plays per bucket, with or without a genre
+	Series  []GenreSeries `json:"series"`  // most played genre first
+}
+
+// truncateBucket returns the start of the day, week (Monday) or month holding t, in t's location
+func truncateBucket(t time.Time, granularity string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch granularity {
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+func nextBucket(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// GetGenreTimeline returns plays and minutes per day, week or month (in
+// TIMEZONE) for the limit most played genres in [from, to), with every
+// bucket of the window present. Nil bounds are open: the timeline then starts
+// at the first play and ends today.
+func GetGenreTimeline(pool *pgxpool.Pool, from, to *time.Time, granularity string, limit int) (GenreTimeline, error) {
+	tl := GenreTimeline{Buckets: []string{}, Plays: []int{}, Series: []GenreSeries{}}
+	tz := config.Timezone()
+
+	rows, err := pool.Query(context.Background(), `
+		WITH plays AS (
+			SELECT rp.spotify_song_id, COALESCE(rp.duration_ms, 0) AS duration_ms,
+				date_trunc($3, rp.played_at AT TIME ZONE $4)::date AS bucket
+			FROM recently_played rp
+			WHERE ($1::timestamptz IS NULL OR rp.played_at >= $1)
+			  AND ($2::timestamptz IS NULL OR rp.played_at < $2)
+		),
+		genre_plays AS (
+			SELECT p.bucket, g.name AS genre, p.duration_ms
+			FROM plays p
+			JOIN track_genres tg ON tg.spotify_song_id = p.spotify_song_id
+			JOIN genres g ON g.id = tg.genre_id
+		),
+		top_genres AS (
+			SELECT genre, COUNT(*) AS plays
+			FROM genre_plays
+			GROUP BY genre
+			ORDER BY COUNT(*) DESC, genre
+			LIMIT $5
+		)
+		SELECT bucket, '', COUNT(*), 0 FROM plays GROUP BY bucket
+		UNION ALL
+		SELECT gp.bucket, gp.genre, COUNT(*), ROUND(SUM(gp.duration_ms) / 60000.0, 1)::float8
+		FROM genre_plays gp
+		JOIN top_genres t ON t.genre = gp.genre
+		GROUP BY gp.bucket, gp.genre`, from, to, granularity, tz.String(), limit)
+	if err != nil {
+		return tl, fmt.Errorf("failed to get genre timeline: %v", err)
+	}
+	defer rows.Close()
+
+	type cell struct {
+		plays   int
+		minutes float64
+	}
+	totals := map[string]int{} // bucket -> all plays
+	cells := map[string]cell{} // genre + "\x00" + bucket -> plays of the genre
+	genreTotals := map[string]int{}
+	var first time.Time
+	for rows.Next() {
+		var bucket time.Time
+		var genre string
+		var c cell
+		if err := rows.Scan(&bucket, &genre, &c.plays, &c.minutes); err != nil {
+			return tl, err
+		}
+		day := bucket.Format("2006-01-02")
+		if genre == "" {
+			totals[day] = c.plays
+			if first.IsZero() || bucket.Before(first) {
+				first = bucket
+			}
+			continue
+		}
+		cells[genre+"\x00"+day] = c
+		genreTotals[genre] += c.plays
+	}
+	if err := rows.Err(); err != nil {
+		return tl, err
+	}
+
+	// Every bucket from the window's start (or the first play) through its end
+	// (or today), so empty weeks chart as zeros instead of being skipped
+	start := first
+	if from != nil {
+		start = from.In(tz)
+	}
+	if start.IsZero() {
+		return tl, nil
+	}
+	start = truncateBucket(time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, tz), granularity)
+	end := time.Now().In(tz)
+	if to != nil {
+		end = to.In(tz).Add(-time.Nanosecond)
+	}
+	for b := start; !b.After(end); b = nextBucket(b, granularity) {
+		day := b.Format("2006-01-02")
+		tl.Buckets = append(tl.Buckets, day)
+		tl.Plays = append(tl.Plays, totals[day])
+	}
+
+	for genre, total := range genreTotals {
+		s := GenreSeries{
+			Genre:      genre,
+			TotalPlays: total,
+			Plays:      make([]int, len(tl.Buckets)),
+			Minutes:    make([]float64, len(tl.Buckets)),
+		}
+		for i, day := range tl.Buckets {
+			c := cells[genre+"\x00"+day]
+			s.Plays[i], s.Minutes[i] = c.plays, c.minutes
+		}
+		tl.Series = append(tl.Series, s)
+	}
+	sort.Slice(tl.Series, func(i, j int) bool {
+		if tl.Series[i].TotalPlays != tl.Series[j].TotalPlays {
+			return tl.Series[i].TotalPlays > tl.Series[j].TotalPlays
+		}
+		return tl.Series[i].Genre < tl.Series[j].Genre
+	})
+	return tl, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"example.com/spotifydb/internal/repository"
+)
+
+// normalizeGenres lowercases, trims and de-duplicates genre names
+func normalizeGenres(genres []string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, g := range genres {
+		name := strings.ToLower(strings.TrimSpace(g))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+const (
+	insertGenresSQL = `
+		INSERT INTO genres (name)
+		SELECT UNNEST($1::text[])
+		ON CONFLICT (name) DO NOTHING`
+	linkTrackGenresSQL = `
+		INSERT INTO track_genres (spotify_song_id, genre_id)
+		SELECT $1, id FROM genres WHERE name = ANY($2::text[])
+		ON CONFLICT DO NOTHING`
+)
+
+// SetTrackGenres links a track to its genres, creating any genres we haven't seen
+func SetTrackGenres(spotifyID string, genres []string) error {
+	names := normalizeGenres(genres)
+	if spotifyID == "" || len(names) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	if _, err := repository.Pool.Exec(ctx, insertGenresSQL, names); err != nil {
+		return fmt.Errorf("failed to insert genres for %s: %v", spotifyID, err)
+	}
+
+	if _, err := repository.Pool.Exec(ctx, linkTrackGenresSQL, spotifyID, names); err != nil {
+		return fmt.Errorf("failed to link genres for %s: %v", spotifyID, err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LikedSorts maps the ?sort= values accepted for saved tracks to SQL
+var LikedSorts = map[string]string{
+	"added_at":     "added_at",
+	"popularity":   "NULLIF(track_popularity, '')::int",
+	"release_date": "album_release_date",
+}
+
+// LikedQuery selects a page of saved tracks
+type LikedQuery struct {
+	Limit     int
+	Offset    int
+	Sort      string // key of LikedSorts
+	Ascending bool
+	Genre     string // exact genre name (via track_genres)
+	Artist    string // artist name (case-insensitive) or artist id
+	AlbumType string // album, single, compilation
+	Source    string // one of Sources
+
+	IncludeRemoved bool // include tracks unliked on Spotify (unliked_at set)
+}
+
+// ListRecentlyLiked returns one page of saved tracks plus the total number
+// of tracks matching the filters
+func ListRecentlyLiked(pool *pgxpool.Pool, q LikedQuery) ([]RecentlyLikedTracks, int, error) {
+	orderBy, ok := LikedSorts[q.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort %q", q.Sort)
+	}
+	direction := "DESC"
+	if q.Ascending {
+		direction = "ASC"
+	}
+
+	var where []string
+	var args []any
+	if !q.IncludeRemoved {
+		where = append(where, "unliked_at IS NULL")
+	}
+	if q.Genre != "" {
+		args = append(args, q.Genre)
+		where = append(where, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM track_genres tg
+			JOIN genres g ON g.id = tg.genre_id
+			WHERE tg.spotify_song_id = recently_liked.spotify_song_id AND g.name = $%d)`, len(args)))
+	}
+	if q.Artist != "" {
+		args = append(args, q.Artist)
+		where = append(where, fmt.Sprintf("(LOWER(artist_name) = LOWER($%d) OR artist_id = $%[1]d)", len(args)))
+	}
+	if q.AlbumType != "" {
+		args = append(args, q.AlbumType)
+		where = append(where, fmt.Sprintf("album_type = $%d", len(args)))
+	}
+	if q.Source != "" {
+		args = append(args, q.Source)
+		where = append(where, fmt.Sprintf("source = $%d", len(args)))
+	}
+	whereSQL := ""
+	if len(where) > 0 {
+		whereSQL = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	args = append(args, q.Limit, q.Offset)
+	query := fmt.Sprintf(`
+		SELECT id, spotify_song_id, track_name, NULLIF(track_popularity, '')::int,
+			album_name, album_type, album_cover_url,
+			album_release_date, album_release_date_precision,
+			artist_name, artist_id, artist_href, artist_uri,
+			album_total_tracks, album_cover_width, album_cover_height,
+			genre, added_at, unliked_at, COALESCE(source, ''),
+			COUNT(*) OVER () AS total
+		FROM recently_liked
+		%s
+		ORDER BY %s %s NULLS LAST, id %[3]s
+		LIMIT $%d OFFSET $%d`, whereSQL, orderBy, direction, len(args)-1, len(args))
+
+	rows, err := pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list recently liked: %v", err)
+	}
+	defer rows.Close()
+
+	tracks := []RecentlyLikedTracks{}
+	total := 0
+	for rows.Next() {
+		var track RecentlyLikedTracks
+		if err := rows.Scan(
+			&track.ID, &track.SpotifyID, &track.TrackName, &track.TrackPopularity,
+			&track.AlbumName, &track.AlbumType, &track.AlbumCoverURL,
+			&track.AlbumReleaseDate, &track.AlbumReleaseDatePrecision,
+			&track.ArtistName, &track.ArtistID, &track.ArtistHref, &track.ArtistURI,
+			&track.AlbumTotalTracks, &track.AlbumCoverWidth, &track.AlbumCoverHeight,
+			&track.Genre, &track.AddedAt, &track.UnlikedAt, &track.Source, &total,
+		); err != nil {
+			return nil, 0, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, total, rows.Err()
+}
+
+// IsLiked reports whether a track is stored as saved and not since removed
+func IsLiked(pool *pgxpool.Pool, spotifyID string) (bool, error) {
+	var liked bool
+	err := pool.QueryRow(context.Background(), `
+		SELECT EXISTS (SELECT 1 FROM recently_liked WHERE spotify_song_id = $1 AND unliked_at IS NULL)`,
+		spotifyID).Scan(&liked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check whether %s is liked: %v", spotifyID, err)
+	}
+	return liked, nil
+}
+
+// MarkUnliked soft-deletes a saved track as removed at at. It reports false
+// when the track wasn't stored or was already removed.
+func MarkUnliked(pool *pgxpool.Pool, spotifyID string, at time.Time) (bool, error) {
+	tag, err := pool.Exec(context.Background(), `
+		UPDATE recently_liked SET unliked_at = $2
+		WHERE spotify_song_id = $1 AND unliked_at IS NULL`, spotifyID, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark %s unliked: %v", spotifyID, err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ReconcileRecentlyLiked syncs soft deletes with the full set of track ids
+// currently saved on Spotify: rows missing from present get unliked_at = at,
+// and previously removed rows that are saved again are restored
+func ReconcileRecentlyLiked(pool *pgxpool.Pool, present []string, at time.Time) (removed, restored int, err error) {
+	ctx := context.Background()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE recently_liked SET unliked_at = $2
+		WHERE unliked_at IS NULL AND NOT (spotify_song_id = ANY($1))`, present, at)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to mark unliked tracks: %v", err)
+	}
+	removed = int(tag.RowsAffected())
+
+	tag, err = tx.Exec(ctx, `
+		UPDATE recently_liked SET unliked_at = NULL
+		WHERE unliked_at IS NOT NULL AND spotify_song_id = ANY($1)`, present)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to restore re-liked tracks: %v", err)
+	}
+	restored = int(tag.RowsAffected())
+
+	return removed, restored, tx.Commit(ctx)
+}
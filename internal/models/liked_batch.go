@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecentlyLikedRow is one saved track plus its catalogue metadata
+type RecentlyLikedRow struct {
+	Source                    string // SourceCron when empty
+	SpotifyID                 string
+	TrackName                 string
+	TrackPopularity           string
+	AlbumName                 string
+	AlbumType                 string
+	AlbumCoverURL             string
+	AlbumReleaseDate          string
+	AlbumReleaseDatePrecision string
+	ArtistName                string
+	ArtistID                  string
+	ArtistHref                string
+	ArtistURI                 string
+	AlbumTotalTracks          int
+	AlbumCoverWidth           int
+	AlbumCoverHeight          int
+	AddedAt                   time.Time
+	Metadata                  services.TrackMetadata
+}
+
+// InsertRecentlyLikedBatch stores saved tracks and their metadata in a
+// single round trip, all or nothing, with the same upsert as
+// InsertRecentlyLiked. inserted[i] reports whether rows[i] was new.
+func InsertRecentlyLikedBatch(rows []RecentlyLikedRow) (inserted []bool, err error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	b := &pgx.Batch{}
+	for _, r := range rows {
+		source := r.Source
+		if source == "" {
+			source = SourceCron
+		}
+		b.Queue(insertRecentlyLikedSQL,
+			r.SpotifyID, r.TrackName, r.TrackPopularity, r.AlbumName, r.AlbumType, r.AlbumCoverURL,
+			r.AlbumReleaseDate, r.AlbumReleaseDatePrecision, r.ArtistName, r.ArtistID, r.ArtistHref,
+			r.ArtistURI, r.AlbumTotalTracks, r.AlbumCoverWidth, r.AlbumCoverHeight, r.AddedAt, source)
+		if hasMetadata(r.Metadata) {
+			b.Queue(setLikedMetadataSQL, r.SpotifyID, nullIfEmpty(r.Metadata.ExternalIDs.ISRC),
+				r.Metadata.Explicit, r.Metadata.DiscNumber, r.Metadata.TrackNumber, r.Metadata.AvailableMarkets)
+		}
+	}
+
+	results := repository.Pool.SendBatch(context.Background(), b)
+	defer results.Close()
+
+	inserted = make([]bool, len(rows))
+	for i, r := range rows {
+		// No row back means the stored save is as new, so nothing changed
+		err := results.QueryRow().Scan(&inserted[i])
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to insert liked track %s: %w", r.SpotifyID, err)
+		}
+		if hasMetadata(r.Metadata) {
+			if _, err := results.Exec(); err != nil {
+				return nil, fmt.Errorf("failed to store metadata for liked track %s: %w", r.SpotifyID, err)
+			}
+		}
+	}
+	if err := results.Close(); err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
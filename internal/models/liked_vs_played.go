@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LikedNotPlayed is a saved track I've never played
+type LikedNotPlayed struct {
+	SpotifySongID string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	ArtistName    string    `json:"artist_name"`
+	AlbumName     string    `json:"album_name"`
+	AlbumCoverURL string    `json:"album_cover_url"`
+	AddedAt       time.Time `json:"added_at"`
+}
+
+// PlayedNotLiked is a track I play a lot but never saved
+type PlayedNotLiked struct {
+	SpotifySongID string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	ArtistName    string    `json:"artist_name"`
+	AlbumName     string    `json:"album_name"`
+	AlbumCoverURL string    `json:"album_cover_url"`
+	Plays         int       `json:"plays"`
+	LastPlayed    time.Time `json:"last_played"`
+}
+
+// LikedVsPlayed compares my library with my listening
+type LikedVsPlayed struct {
+	MinPlays              int              `json:"min_plays"`
+	LikedNeverPlayed      []LikedNotPlayed `json:"liked_never_played"`
+	LikedNeverPlayedTotal int              `json:"liked_never_played_total"`
+	PlayedNeverLiked      []PlayedNotLiked `json:"played_never_liked"`
+	PlayedNeverLikedTotal int              `json:"played_never_liked_total"`
+}
+
+// Tracks are compared by canonical id, so a saved single counts as played
+// when I play the album cut of the same recording
+const (
+	canonicalPlayed = `
+		SELECT DISTINCT COALESCE(ct.canonical_id, rp.spotify_song_id) AS cid
+		FROM recently_played rp
+		LEFT JOIN canonical_tracks ct ON ct.spotify_song_id = rp.spotify_song_id`
+	canonicalLiked = `
+		SELECT DISTINCT COALESCE(ct.canonical_id, rl.spotify_song_id) AS cid
+		FROM recently_liked rl
+		LEFT JOIN canonical_tracks ct ON ct.spotify_song_id = rl.spotify_song_id
+		WHERE rl.unliked_at IS NULL`
+)
+
+// GetLikedVsPlayed lists up to limit saved tracks never played, newest saved
+// first, and up to limit tracks played at least minPlays times but not saved,
+// most played first
+func GetLikedVsPlayed(pool *pgxpool.Pool, minPlays, limit int) (*LikedVsPlayed, error) {
+	ctx := context.Background()
+	result := &LikedVsPlayed{
+		MinPlays:         minPlays,
+		LikedNeverPlayed: []LikedNotPlayed{},
+		PlayedNeverLiked: []PlayedNotLiked{},
+	}
+
+	rows, err := pool.Query(ctx, `
+		WITH played AS (`+canonicalPlayed+`)
+		SELECT rl.spotify_song_id, rl.track_name, COALESCE(rl.artist_name, ''),
+			COALESCE(rl.album_name, ''), COALESCE(rl.album_cover_url, ''), rl.added_at,
+			COUNT(*) OVER ()
+		FROM recently_liked rl
+		LEFT JOIN canonical_tracks ct ON ct.spotify_song_id = rl.spotify_song_id
+		WHERE rl.unliked_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM played p WHERE p.cid = COALESCE(ct.canonical_id, rl.spotify_song_id))
+		ORDER BY rl.added_at DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get liked tracks never played: %v", err)
+	}
+	for rows.Next() {
+		var t LikedNotPlayed
+		if err := rows.Scan(&t.SpotifySongID, &t.TrackName, &t.ArtistName, &t.AlbumName,
+			&t.AlbumCoverURL, &t.AddedAt, &result.LikedNeverPlayedTotal); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.LikedNeverPlayed = append(result.LikedNeverPlayed, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get liked tracks never played: %v", err)
+	}
+
+	rows, err = pool.Query(ctx, `
+		WITH liked AS (`+canonicalLiked+`),
+		played AS (
+			SELECT COALESCE(ct.canonical_id, rp.spotify_song_id) AS cid,
+				(ARRAY_AGG(rp.spotify_song_id ORDER BY rp.played_at DESC))[1] AS spotify_song_id,
+				(ARRAY_AGG(rp.track_name ORDER BY rp.played_at DESC))[1] AS track_name,
+				(ARRAY_AGG(COALESCE(rp.artist_name, '') ORDER BY rp.played_at DESC))[1] AS artist_name,
+				(ARRAY_AGG(COALESCE(rp.album_name, '') ORDER BY rp.played_at DESC))[1] AS album_name,
+				COALESCE(MAX(rp.album_cover_url), '') AS album_cover_url,
+				COUNT(*) AS plays,
+				MAX(rp.played_at) AS last_played
+			FROM recently_played rp
+			LEFT JOIN canonical_tracks ct ON ct.spotify_song_id = rp.spotify_song_id
+			GROUP BY 1
+		)
+		SELECT p.spotify_song_id, p.track_name, p.artist_name, p.album_name, p.album_cover_url,
+			p.plays, p.last_played, COUNT(*) OVER ()
+		FROM played p
+		WHERE p.plays >= $1 AND NOT EXISTS (SELECT 1 FROM liked l WHERE l.cid = p.cid)
+		ORDER BY p.plays DESC, p.last_played DESC
+		LIMIT $2`, minPlays, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get played tracks never liked: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t PlayedNotLiked
+		if err := rows.Scan(&t.SpotifySongID, &t.TrackName, &t.ArtistName, &t.AlbumName,
+			&t.AlbumCoverURL, &t.Plays, &t.LastPlayed, &result.PlayedNeverLikedTotal); err != nil {
+			return nil, err
+		}
+		result.PlayedNeverLiked = append(result.PlayedNeverLiked, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get played tracks never liked: %v", err)
+	}
+	return result, nil
+}
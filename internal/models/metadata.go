@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+)
+
+// hasMetadata reports whether Spotify sent any catalogue metadata (simplified
+// track objects and older buffered plays have none)
+func hasMetadata(meta services.TrackMetadata) bool {
+	return meta.ExternalIDs.ISRC != "" || meta.TrackNumber > 0 || len(meta.AvailableMarkets) > 0
+}
+
+// nullIfEmpty turns "" into NULL
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+const setPlayMetadataSQL = `
+		UPDATE recently_played
+		SET isrc = $3, explicit = $4, disc_number = $5, track_number = $6, available_markets = $7
+		WHERE spotify_song_id = $1 AND played_at = $2`
+
+const setPlayReleaseDateSQL = `
+		UPDATE recently_played
+		SET album_release_date = $3, album_release_date_precision = $4
+		WHERE spotify_song_id = $1 AND played_at = $2`
+
+// SetPlayReleaseDate stores the release date of the album a play came from
+func SetPlayReleaseDate(spotifyID string, playedAt time.Time, date, precision string) error {
+	if date == "" {
+		return nil
+	}
+	_, err := repository.Pool.Exec(context.Background(), setPlayReleaseDateSQL,
+		spotifyID, playedAt, date, nullIfEmpty(precision))
+	if err != nil {
+		return fmt.Errorf("failed to set play release date: %v", err)
+	}
+	return nil
+}
+
+// SetPlayMetadata stores ISRC, explicit flag, disc/track number and markets on a play
+func SetPlayMetadata(spotifyID string, playedAt time.Time, meta services.TrackMetadata) error {
+	if !hasMetadata(meta) {
+		return nil
+	}
+	_, err := repository.Pool.Exec(context.Background(), setPlayMetadataSQL,
+		spotifyID, playedAt, nullIfEmpty(meta.ExternalIDs.ISRC), meta.Explicit,
+		meta.DiscNumber, meta.TrackNumber, meta.AvailableMarkets)
+	if err != nil {
+		return fmt.Errorf("failed to set play metadata: %v", err)
+	}
+	return nil
+}
+
+const setLikedMetadataSQL = `
+		UPDATE recently_liked
+		SET isrc = $2, explicit = $3, disc_number = $4, track_number = $5, available_markets = $6
+		WHERE spotify_song_id = $1`
+
+// SetLikedMetadata stores the same metadata on a saved track
+func SetLikedMetadata(spotifyID string, meta services.TrackMetadata) error {
+	if !hasMetadata(meta) {
+		return nil
+	}
+	_, err := repository.Pool.Exec(context.Background(), setLikedMetadataSQL,
+		spotifyID, nullIfEmpty(meta.ExternalIDs.ISRC), meta.Explicit,
+		meta.DiscNumber, meta.TrackNumber, meta.AvailableMarkets)
+	if err != nil {
+		return fmt.Errorf("failed to set liked track metadata: %v", err)
+	}
+	return nil
+}
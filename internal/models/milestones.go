@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Milestone kinds
+const (
+	MilestonePlays         = "plays"          // Nth play overall
+	MilestoneUniqueArtists = "unique_artists" // Nth distinct artist played
+	MilestoneNewGenre      = "new_genre"      // first play of a genre
+	MilestoneTrackPlays    = "track_plays"    // a track reaching N plays
+)
+
+// MilestoneKinds lists the kinds accepted by ?kind=
+var MilestoneKinds = []string{MilestonePlays, MilestoneUniqueArtists, MilestoneNewGenre, MilestoneTrackPlays}
+
+// Thresholds that count as a milestone
+var (
+	PlayMilestones       = []int{100, 500, 1000, 2500, 5000, 10000, 25000, 50000, 100000, 250000, 500000}
+	ArtistMilestones     = []int{10, 50, 100, 250, 500, 1000, 2500, 5000}
+	TrackPlaysMilestones = []int{25, 50, 100, 250, 500, 1000}
+)
+
+const milestoneReturning = `
+	RETURNING id, kind, value, COALESCE(label, ''), COALESCE(spotify_song_id, ''), achieved_at, detected_at`
+
+// Each detection query inserts what has been reached but not yet recorded.
+// achieved_at is when the play that reached it happened, so milestones found
+// late (or on the first run) keep their real date.
+var detectMilestoneSQL = []struct {
+	kind, sql string
+	args      []int
+}{
+	{MilestonePlays, `
+		WITH numbered AS (
+			SELECT spotify_song_id, track_name, artist_name, played_at,
+				ROW_NUMBER() OVER (ORDER BY played_at, id) AS n
+			FROM recently_played
+		)
+		INSERT INTO milestones (kind, subject, value, label, spotify_song_id, achieved_at)
+		SELECT 'plays', t::text, t,
+			p.track_name || COALESCE(' by ' || NULLIF(p.artist_name, ''), ''),
+			p.spotify_song_id, p.played_at
+		FROM numbered p
+		JOIN UNNEST($1::int[]) AS t ON p.n = t
+		ON CONFLICT (kind, subject) DO NOTHING`, PlayMilestones},
+
+	{MilestoneUniqueArtists, `
+		WITH firsts AS (
+			SELECT artist_name, MIN(played_at) AS first_played
+			FROM recently_played
+			WHERE COALESCE(artist_name, '') <> ''
+			GROUP BY artist_name
+		), numbered AS (
+			SELECT artist_name, first_played,
+				ROW_NUMBER() OVER (ORDER BY first_played, artist_name) AS n
+			FROM firsts
+		)
+		INSERT INTO milestones (kind, subject, value, label, achieved_at)
+		SELECT 'unique_artists', t::text, t, a.artist_name, a.first_played
+		FROM numbered a
+		JOIN UNNEST($1::int[]) AS t ON a.n = t
+		ON CONFLICT (kind, subject) DO NOTHING`, ArtistMilestones},
+
+	{MilestoneNewGenre, `
+		INSERT INTO milestones (kind, subject, value, label, spotify_song_id, achieved_at)
+		SELECT DISTINCT ON (g.id) 'new_genre', g.name, 0, g.name, rp.spotify_song_id, rp.played_at
+		FROM genres g
+		JOIN track_genres tg ON tg.genre_id = g.id
+		JOIN recently_played rp ON rp.spotify_song_id = tg.spotify_song_id
+		WHERE NOT EXISTS (
+			SELECT 1 FROM milestones m WHERE m.kind = 'new_genre' AND m.subject = g.name
+		)
+		ORDER BY g.id, rp.played_at
+		ON CONFLICT (kind, subject) DO NOTHING`, nil},
+
+	{MilestoneTrackPlays, `
+		WITH counts AS (
+			SELECT spotify_song_id FROM recently_played
+			GROUP BY spotify_song_id
+			HAVING COUNT(*) >= (SELECT MIN(t) FROM UNNEST($1::int[]) AS t)
+		), numbered AS (
+			SELECT rp.spotify_song_id, rp.track_name, rp.artist_name, rp.played_at,
+				ROW_NUMBER() OVER (PARTITION BY rp.spotify_song_id ORDER BY rp.played_at, rp.id) AS n
+			FROM recently_played rp
+			JOIN counts USING (spotify_song_id)
+		)
+		INSERT INTO milestones (kind, subject, value, label, spotify_song_id, achieved_at)
+		SELECT 'track_plays', p.spotify_song_id || ':' || t, t,
+			p.track_name || COALESCE(' by ' || NULLIF(p.artist_name, ''), ''),
+			p.spotify_song_id, p.played_at
+		FROM numbered p
+		JOIN UNNEST($1::int[]) AS t ON p.n = t
+		ON CONFLICT (kind, subject) DO NOTHING`, TrackPlaysMilestones},
+}
+
+// DetectMilestones records every milestone reached since the last run and
+// returns the new ones. Already recorded milestones are never repeated.
+func DetectMilestones(ctx context.Context, pool *pgxpool.Pool) ([]Milestone, error) {
+	found := []Milestone{}
+	for _, d := range detectMilestoneSQL {
+		var args []any
+		if d.args != nil {
+			args = append(args, d.args)
+		}
+		rows, err := pool.Query(ctx, d.sql+milestoneReturning, args...)
+		if err != nil {
+			return found, fmt.Errorf("failed to detect %s milestones: %v", d.kind, err)
+		}
+		ms, err := scanMilestones(rows)
+		if err != nil {
+			return found, fmt.Errorf("failed to detect %s milestones: %v", d.kind, err)
+		}
+		found = append(found, ms...)
+	}
+	return found, nil
+}
+
+// GetMilestones returns recorded milestones newest first, optionally of one kind
+func GetMilestones(pool *pgxpool.Pool, kind string, limit int) ([]Milestone, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT id, kind, value, COALESCE(label, ''), COALESCE(spotify_song_id, ''), achieved_at, detected_at
+		FROM milestones
+		WHERE ($1 = '' OR kind = $1)
+		ORDER BY achieved_at DESC, id DESC
+		LIMIT $2`, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get milestones: %v", err)
+	}
+	return scanMilestones(rows)
+}
+
+func scanMilestones(rows pgx.Rows) ([]Milestone, error) {
+	defer rows.Close()
+	ms := []Milestone{}
+	for rows.Next() {
+		var m Milestone
+		if err := rows.Scan(&m.ID, &m.Kind, &m.Value, &m.Label, &m.SpotifySongID,
+			&m.AchievedAt, &m.DetectedAt); err != nil {
+			return nil, err
+		}
+		m.Title = milestoneTitle(m)
+		ms = append(ms, m)
+	}
+	return ms, rows.Err()
+}
+
+func milestoneTitle(m Milestone) string {
+	switch m.Kind {
+	case MilestonePlays:
+		return fmt.Sprintf("%s play: %s", ordinal(m.Value), m.Label)
+	case MilestoneUniqueArtists:
+		return fmt.Sprintf("%s unique artist: %s", ordinal(m.Value), m.Label)
+	case MilestoneNewGenre:
+		return "First play of a new genre: " + m.Label
+	case MilestoneTrackPlays:
+		return fmt.Sprintf("%s reached %s plays", m.Label, thousands(m.Value))
+	}
+	return m.Label
+}
+
+// ordinal formats n as 1st, 2nd, 1,000th...
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return thousands(n) + suffix
+}
+
+// thousands formats n with comma separators
+func thousands(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
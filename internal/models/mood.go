@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Mood labels, from a window's average energy and valence (see MoodLabel)
+const (
+	MoodEnergetic   = "energetic"
+	MoodMelancholic = "melancholic"
+	MoodMellow      = "mellow"
+	MoodBalanced    = "balanced"
+)
+
+// Thresholds MoodLabel applies to average energy and valence (0-1)
+const (
+	EnergeticAbove   = 0.65
+	MellowBelow      = 0.45
+	MelancholicBelow = 0.35
+)
+
+// MoodLabel names the mood of average energy and valence: energetic when
+// energy is high, otherwise melancholic when valence is low, mellow when
+// energy is low, and balanced in between
+func MoodLabel(energy, valence float64) string {
+	switch {
+	case energy >= EnergeticAbove:
+		return MoodEnergetic
+	case valence < MelancholicBelow:
+		return MoodMelancholic
+	case energy < MellowBelow:
+		return MoodMellow
+	}
+	return MoodBalanced
+}
+
+// MoodAverages are the mean audio features of the analyzed plays in a window.
+// The averages are nil and Label empty when no play has features.
+type MoodAverages struct {
+	Plays        int      `json:"plays"`
+	Analyzed     int      `json:"analyzed"` // plays whose track has audio features
+	Valence      *float64 `json:"valence"`
+	Energy       *float64 `json:"energy"`
+	Danceability *float64 `json:"danceability"`
+	Label        string   `json:"label,omitempty"`
+}
+
+// MoodBucket is the mood of one day or week
+type MoodBucket struct {
+	Start string `json:"start"` // YYYY-MM-DD, in TIMEZONE
+	MoodAverages
+}
+
+// MoodTimeOfDay is the mood of my plays at one time of day
+type MoodTimeOfDay struct {
+	TimeOfDay string `json:"time_of_day"` // morning, afternoon, evening or night
+	Hours     string `json:"hours"`       // e.g. "05-11", in TIMEZONE
+	MoodAverages
+}
+
+// timesOfDay splits the day for MoodTimeOfDay: [from, to) hours
+var timesOfDay = []struct {
+	name     string
+	from, to int
+}{
+	{"morning", 5, 12},
+	{"afternoon", 12, 17},
+	{"evening", 17, 22},
+	{"night", 22, 5},
+}
+
+// moodPlays gives every play in [$1, $2) its local time and its track's
+// audio features, NULL when they're unknown
+const moodPlays = `
+	SELECT rp.played_at AT TIME ZONE $3 AS local_at, af.valence, af.energy, af.danceability
+	FROM recently_played rp
+	LEFT JOIN audio_features af ON af.spotify_song_id = rp.spotify_song_id AND af.energy IS NOT NULL
+	WHERE ($1::timestamptz IS NULL OR rp.played_at >= $1)
+	  AND ($2::timestamptz IS NULL OR rp.played_at < $2)`
+
+const moodAggregates = `COUNT(*), COUNT(energy),
+	ROUND(AVG(valence)::numeric, 3)::float8, ROUND(AVG(energy)::numeric, 3)::float8,
+	ROUND(AVG(danceability)::numeric, 3)::float8`
+
+func (m *MoodAverages) label() {
+	if m.Energy != nil && m.Valence != nil {
+		m.Label = MoodLabel(*m.Energy, *m.Valence)
+	}
+}
+
+// GetMoodTimeline averages valence, energy and danceability per day or week
+// (granularity, in TIMEZONE) over the plays in [from, to), oldest first,
+// leaving out buckets without plays. Nil bounds are open.
+func GetMoodTimeline(pool *pgxpool.Pool, from, to *time.Time, granularity string) ([]MoodBucket, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT to_char(date_trunc($4, local_at), 'YYYY-MM-DD') AS start, `+moodAggregates+`
+		FROM (`+moodPlays+`) p
+		GROUP BY start
+		ORDER BY start`, from, to, config.Timezone().String(), granularity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mood timeline: %v", err)
+	}
+	defer rows.Close()
+
+	buckets := []MoodBucket{}
+	for rows.Next() {
+		var b MoodBucket
+		if err := rows.Scan(&b.Start, &b.Plays, &b.Analyzed, &b.Valence, &b.Energy, &b.Danceability); err != nil {
+			return nil, err
+		}
+		b.label()
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// GetMoodByTimeOfDay averages the same features per time of day (in
+// TIMEZONE) over the plays in [from, to), morning first
+func GetMoodByTimeOfDay(pool *pgxpool.Pool, from, to *time.Time) ([]MoodTimeOfDay, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT EXTRACT(HOUR FROM local_at)::int AS hour, `+moodAggregates+`,
+			SUM(valence), SUM(energy), SUM(danceability)
+		FROM (`+moodPlays+`) p
+		GROUP BY hour`, from, to, config.Timezone().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mood by time of day: %v", err)
+	}
+	defer rows.Close()
+
+	// Sum the hours into their time of day, then average
+	type sums struct {
+		plays, analyzed               int
+		valence, energy, danceability float64
+	}
+	slots := make([]sums, len(timesOfDay))
+	for rows.Next() {
+		var hour, plays, analyzed int
+		var avgV, avgE, avgD, sumV, sumE, sumD *float64
+		if err := rows.Scan(&hour, &plays, &analyzed, &avgV, &avgE, &avgD, &sumV, &sumE, &sumD); err != nil {
+			return nil, err
+		}
+		i := timeOfDay(hour)
+		slots[i].plays += plays
+		slots[i].analyzed += analyzed
+		if analyzed > 0 {
+			slots[i].valence += *sumV
+			slots[i].energy += *sumE
+			slots[i].danceability += *sumD
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]MoodTimeOfDay, len(timesOfDay))
+	for i, t := range timesOfDay {
+		s := slots[i]
+		out[i] = MoodTimeOfDay{
+			TimeOfDay:    t.name,
+			Hours:        fmt.Sprintf("%02d-%02d", t.from, (t.to+23)%24),
+			MoodAverages: MoodAverages{Plays: s.plays, Analyzed: s.analyzed},
+		}
+		if s.analyzed > 0 {
+			n := float64(s.analyzed)
+			v, e, d := round3(s.valence/n), round3(s.energy/n), round3(s.danceability/n)
+			out[i].Valence, out[i].Energy, out[i].Danceability = &v, &e, &d
+			out[i].label()
+		}
+	}
+	return out, nil
+}
+
+// GetMoodSummary averages the same features over all plays in [from, to)
+func GetMoodSummary(pool *pgxpool.Pool, from, to *time.Time) (MoodAverages, error) {
+	var m MoodAverages
+	err := pool.QueryRow(context.Background(), `SELECT `+moodAggregates+` FROM (`+moodPlays+`) p`,
+		from, to, config.Timezone().String()).
+		Scan(&m.Plays, &m.Analyzed, &m.Valence, &m.Energy, &m.Danceability)
+	if err != nil {
+		return m, fmt.Errorf("failed to get mood summary: %v", err)
+	}
+	m.label()
+	return m, nil
+}
+
+// timeOfDay returns the index in timesOfDay of the slot holding hour
+func timeOfDay(hour int) int {
+	for i, t := range timesOfDay {
+		if (t.from < t.to && hour >= t.from && hour < t.to) || (t.from > t.to && (hour >= t.from || hour < t.to)) {
+			return i
+		}
+	}
+	return len(timesOfDay) - 1
+}
+
+func round3(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
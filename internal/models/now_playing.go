@@ -0,0 +1,188 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// InsertNowPlaying records a player snapshot in now_playing_log. Snapshots
+// without a track (podcast episodes, ads) are ignored.
+func InsertNowPlaying(state *services.CurrentlyPlaying, capturedAt time.Time) error {
+	if state == nil || state.Item.ID == "" {
+		return nil
+	}
+
+	artistName := ""
+	if len(state.Item.Artists) > 0 {
+		artistName = state.Item.Artists[0].Name
+	}
+	var deviceName, deviceType, contextType, contextURI, repeatState *string
+	if state.Device != nil {
+		deviceName = &state.Device.Name
+		deviceType = &state.Device.Type
+	}
+	if state.Context != nil {
+		contextType = &state.Context.Type
+		contextURI = &state.Context.URI
+	}
+	if state.RepeatState != "" {
+		repeatState = &state.RepeatState
+	}
+
+	_, err := repository.Pool.Exec(context.Background(), `
+		INSERT INTO now_playing_log
+		      (spotify_song_id, track_name, artist_name, progress_ms, duration_ms,
+		       is_playing, device_name, device_type, shuffle_state, repeat_state,
+		       context_type, context_uri, captured_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		state.Item.ID, state.Item.Name, artistName, state.ProgressMS, state.Item.DurationMs,
+		state.IsPlaying, deviceName, deviceType, state.ShuffleState, repeatState,
+		contextType, contextURI, capturedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert now playing snapshot: %v", err)
+	}
+	return nil
+}
+
+const setPlayContextSQL = `
+		UPDATE recently_played
+		SET context_type = $3, context_uri = $4
+		WHERE spotify_song_id = $1 AND played_at = $2`
+
+// SetPlayContext records what a play was started from (playlist, album, ...)
+func SetPlayContext(spotifyID string, playedAt time.Time, contextType, contextURI string) error {
+	if contextType == "" && contextURI == "" {
+		return nil
+	}
+	_, err := repository.Pool.Exec(context.Background(), setPlayContextSQL,
+		spotifyID, playedAt, contextType, contextURI)
+	if err != nil {
+		return fmt.Errorf("failed to set play context: %v", err)
+	}
+	return nil
+}
+
+// AttachPlaybackState copies device, shuffle and repeat state onto recent plays
+// from the now_playing_log snapshot of the same track taken while it played.
+// The recently-played API doesn't report devices, so this is the only source.
+func AttachPlaybackState(pool *pgxpool.Pool) (int, error) {
+	tag, err := pool.Exec(context.Background(), `
+		UPDATE recently_played rp
+		SET (device_name, device_type, shuffle_state, repeat_state) = (
+			SELECT n.device_name, n.device_type, n.shuffle_state, n.repeat_state
+			FROM now_playing_log n
+			WHERE n.spotify_song_id = rp.spotify_song_id
+			  AND n.captured_at BETWEEN rp.played_at - INTERVAL '15 minutes' AND rp.played_at + INTERVAL '1 minute'
+			ORDER BY n.captured_at DESC
+			LIMIT 1
+		)
+		WHERE rp.device_name IS NULL
+		  AND rp.played_at > NOW() - INTERVAL '1 day'
+		  AND EXISTS (
+			SELECT 1 FROM now_playing_log n
+			WHERE n.spotify_song_id = rp.spotify_song_id
+			  AND n.device_name IS NOT NULL
+			  AND n.captured_at BETWEEN rp.played_at - INTERVAL '15 minutes' AND rp.played_at + INTERVAL '1 minute'
+		  )`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to attach playback state: %v", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// GetDeviceStats aggregates plays in [from, to) by the device they were played on
+func GetDeviceStats(pool *pgxpool.Pool, from, to *time.Time) ([]DeviceStat, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT
+			COALESCE(device_name, 'unknown'),
+			COALESCE(device_type, 'unknown'),
+			COUNT(*) AS plays,
+			COALESCE(SUM(duration_ms), 0),
+			COUNT(*) FILTER (WHERE shuffle_state),
+			COUNT(*) FILTER (WHERE shuffle_state IS NOT NULL)
+		FROM recently_played
+		WHERE ($1::timestamptz IS NULL OR played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR played_at < $2)
+		GROUP BY 1, 2
+		ORDER BY plays DESC`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device stats: %v", err)
+	}
+	defer rows.Close()
+
+	results := []DeviceStat{}
+	for rows.Next() {
+		var d DeviceStat
+		var shuffled, known int
+		if err := rows.Scan(&d.DeviceName, &d.DeviceType, &d.Plays, &d.TotalMs, &shuffled, &known); err != nil {
+			return nil, err
+		}
+		if known > 0 {
+			rate := float64(shuffled) / float64(known)
+			d.ShuffleRate = &rate
+		}
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+// GetContextStats aggregates plays in [from, to) by context type and returns the
+// most played individual contexts (playlists, albums, ...)
+func GetContextStats(pool *pgxpool.Pool, from, to *time.Time, limit int) ([]ContextTypeStat, []ContextStat, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `
+		SELECT COALESCE(context_type, 'none'), COUNT(*) AS plays, COALESCE(SUM(duration_ms), 0)
+		FROM recently_played
+		WHERE ($1::timestamptz IS NULL OR played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR played_at < $2)
+		GROUP BY 1
+		ORDER BY plays DESC`, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get context stats: %v", err)
+	}
+	types := []ContextTypeStat{}
+	for rows.Next() {
+		var t ContextTypeStat
+		if err := rows.Scan(&t.ContextType, &t.Plays, &t.TotalMs); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		types = append(types, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = pool.Query(ctx, `
+		SELECT COALESCE(context_type, ''), context_uri, COUNT(*) AS plays,
+		       COUNT(DISTINCT spotify_song_id), MAX(played_at)
+		FROM recently_played
+		WHERE context_uri IS NOT NULL
+		  AND ($1::timestamptz IS NULL OR played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR played_at < $2)
+		GROUP BY context_type, context_uri
+		ORDER BY plays DESC
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get top contexts: %v", err)
+	}
+	defer rows.Close()
+
+	top := []ContextStat{}
+	for rows.Next() {
+		var c ContextStat
+		if err := rows.Scan(&c.ContextType, &c.ContextURI, &c.Plays, &c.UniqueTracks, &c.LastPlayed); err != nil {
+			return nil, nil, err
+		}
+		top = append(top, c)
+	}
+	return types, top, rows.Err()
+}
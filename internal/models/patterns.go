@@ -0,0 +1,52 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetListeningPatterns buckets plays in [from, to) by weekday and hour in the
+// configured timezone. A nil bound means open-ended.
+func GetListeningPatterns(pool *pgxpool.Pool, from, to *time.Time) (*ListeningPatterns, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT
+			EXTRACT(DOW FROM played_at AT TIME ZONE $1)::int AS dow,
+			EXTRACT(HOUR FROM played_at AT TIME ZONE $1)::int AS hour,
+			COUNT(*),
+			COALESCE(SUM(duration_ms), 0)
+		FROM recently_played
+		WHERE ($2::timestamptz IS NULL OR played_at >= $2)
+		  AND ($3::timestamptz IS NULL OR played_at < $3)
+		GROUP BY dow, hour`, config.Timezone().String(), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get listening patterns: %v", err)
+	}
+	defer rows.Close()
+
+	p := &ListeningPatterns{Weekdays: make([]string, 7)}
+	for d := range p.Weekdays {
+		p.Weekdays[d] = time.Weekday(d).String()
+	}
+
+	best := 0
+	for rows.Next() {
+		var dow, hour, plays int
+		var ms int64
+		if err := rows.Scan(&dow, &hour, &plays, &ms); err != nil {
+			return nil, err
+		}
+		p.Plays[dow][hour] = plays
+		p.Minutes[dow][hour] = ms / 60000
+		p.TotalPlays += plays
+		if plays > best {
+			best = plays
+			p.PeakDay = p.Weekdays[dow]
+			p.PeakHour = &hour
+		}
+	}
+	return p, rows.Err()
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/repository"
+)
+
+// PlayArtist is one artist credited on a play, in Spotify's order: the
+// first is the primary artist, the rest are featured
+type PlayArtist struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// setPlayArtistsSQL credits $3/$4 (ids, names) to the play of $1 at $2,
+// numbering them from position 0
+const setPlayArtistsSQL = `
+		INSERT INTO play_artists (play_id, position, artist_id, artist_name)
+		SELECT rp.id, a.ord - 1, NULLIF(a.id, ''), a.name
+		FROM recently_played rp,
+			UNNEST($3::text[], $4::text[]) WITH ORDINALITY AS a(id, name, ord)
+		WHERE rp.spotify_song_id = $1 AND rp.played_at = $2 AND a.name <> ''
+		ON CONFLICT (play_id, position) DO UPDATE
+		  SET artist_id   = COALESCE(EXCLUDED.artist_id, play_artists.artist_id),
+		      artist_name = EXCLUDED.artist_name`
+
+// playArtistArgs splits artists into the id and name arrays
+// setPlayArtistsSQL takes, falling back to primary alone when the play's
+// other artists aren't known
+func playArtistArgs(artists []PlayArtist, primary string) (ids, names []string) {
+	if len(artists) == 0 && primary != "" {
+		artists = []PlayArtist{{Name: primary}}
+	}
+	for _, a := range artists {
+		ids = append(ids, a.ID)
+		names = append(names, a.Name)
+	}
+	return ids, names
+}
+
+// SetPlayArtists records every artist credited on a play. Without artists,
+// primary (the play's artist_name) is recorded on its own.
+func SetPlayArtists(spotifyID string, playedAt time.Time, artists []PlayArtist, primary string) error {
+	ids, names := playArtistArgs(artists, primary)
+	if len(names) == 0 {
+		return nil
+	}
+	_, err := repository.Pool.Exec(context.Background(), setPlayArtistsSQL, spotifyID, playedAt, ids, names)
+	if err != nil {
+		return fmt.Errorf("failed to set play artists: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecentlyPlayedRow is one play plus everything stored alongside it
+type RecentlyPlayedRow struct {
+	Source        string
+	SpotifyID     string
+	TrackName     string
+	ArtistName    string
+	AlbumName     string
+	AlbumCoverURL string
+	Genre         string
+	Genres        []string
+	DurationMs    int
+	PlayedAt      time.Time
+	ContextType   string
+	ContextURI    string
+	Artists       []PlayArtist // every credited artist; ArtistName alone when empty
+	Metadata      services.TrackMetadata
+
+	AlbumReleaseDate          string
+	AlbumReleaseDatePrecision string
+}
+
+// InsertRecentlyPlayedBatch writes plays with their genres, playback context,
+// release date and metadata in a single round trip. The batch runs as one implicit
+// transaction, so either every row is written or none is. Plays already
+// stored are ignored; inserted[i] reports whether rows[i] was new.
+func InsertRecentlyPlayedBatch(rows []RecentlyPlayedRow) (inserted []bool, err error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	// queued[i] is how many statements row i added after its insert
+	b := &pgx.Batch{}
+	queued := make([]int, len(rows))
+	for i, r := range rows {
+		source := r.Source
+		if source == "" {
+			source = SourceCron
+		}
+		b.Queue(insertRecentlyPlayedSQL,
+			r.SpotifyID, r.TrackName, r.ArtistName, r.AlbumName, r.AlbumCoverURL, r.Genre,
+			r.DurationMs, r.PlayedAt, source)
+
+		if names := normalizeGenres(r.Genres); r.SpotifyID != "" && len(names) > 0 {
+			b.Queue(insertGenresSQL, names)
+			b.Queue(linkTrackGenresSQL, r.SpotifyID, names)
+			queued[i] += 2
+		}
+		if ids, names := playArtistArgs(r.Artists, r.ArtistName); len(names) > 0 {
+			b.Queue(setPlayArtistsSQL, r.SpotifyID, r.PlayedAt, ids, names)
+			queued[i]++
+		}
+		if r.ContextType != "" || r.ContextURI != "" {
+			b.Queue(setPlayContextSQL, r.SpotifyID, r.PlayedAt, r.ContextType, r.ContextURI)
+			queued[i]++
+		}
+		if r.AlbumReleaseDate != "" {
+			b.Queue(setPlayReleaseDateSQL, r.SpotifyID, r.PlayedAt, r.AlbumReleaseDate, nullIfEmpty(r.AlbumReleaseDatePrecision))
+			queued[i]++
+		}
+		if hasMetadata(r.Metadata) {
+			b.Queue(setPlayMetadataSQL, r.SpotifyID, r.PlayedAt, nullIfEmpty(r.Metadata.ExternalIDs.ISRC),
+				r.Metadata.Explicit, r.Metadata.DiscNumber, r.Metadata.TrackNumber, r.Metadata.AvailableMarkets)
+			queued[i]++
+		}
+	}
+
+	results := repository.Pool.SendBatch(context.Background(), b)
+	defer results.Close()
+
+	inserted = make([]bool, len(rows))
+	for i, r := range rows {
+		tag, err := results.Exec()
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert play %s at %s: %w", r.SpotifyID, r.PlayedAt.Format(time.RFC3339), err)
+		}
+		inserted[i] = tag.RowsAffected() > 0
+
+		for j := 0; j < queued[i]; j++ {
+			if _, err := results.Exec(); err != nil {
+				return nil, fmt.Errorf("failed to store details for play %s: %w", r.SpotifyID, err)
+			}
+		}
+	}
+	if err := results.Close(); err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// HistoryTables maps the tables DELETE /admin/history can purge to the
+// column that dates their rows
+var HistoryTables = map[string]string{
+	"recently_played": "played_at",
+	"recently_liked":  "added_at",
+}
+
+// HistoryRange describes the rows of a table dated within a range
+type HistoryRange struct {
+	Rows  int        `json:"rows"`
+	First *time.Time `json:"first,omitempty"`
+	Last  *time.Time `json:"last,omitempty"`
+}
+
+// anonymizeSets blanks what identifies a row, keeping ids, durations and
+// dates so counts and listening time stay as they were
+var anonymizeSets = map[string]string{
+	"recently_played": `track_name = '', artist_name = '', album_name = '',
+		context_uri = NULL, device_name = NULL`,
+	"recently_liked": `track_name = '', artist_name = '', album_name = ''`,
+}
+
+// ErrHistoryChanged means the rows of a range no longer match what was
+// confirmed, so they were left alone
+var ErrHistoryChanged = errors.New("history range changed since it was counted")
+
+// GetHistoryRange counts the rows of table dated within [from, to]
+func GetHistoryRange(pool *pgxpool.Pool, table string, from, to time.Time) (HistoryRange, error) {
+	col, ok := HistoryTables[table]
+	if !ok {
+		return HistoryRange{}, fmt.Errorf("unknown history table %q", table)
+	}
+	return countHistoryRange(context.Background(), pool, table, col, from, to)
+}
+
+func countHistoryRange(ctx context.Context, q interface {
+	QueryRow(context.Context, string, ...any) pgx.Row
+}, table, col string, from, to time.Time) (HistoryRange, error) {
+	var r HistoryRange
+	err := q.QueryRow(ctx, fmt.Sprintf(`
+		SELECT COUNT(*), MIN(%[2]s), MAX(%[2]s)
+		FROM %[1]s
+		WHERE %[2]s >= $1 AND %[2]s <= $2`, table, col), from, to).Scan(&r.Rows, &r.First, &r.Last)
+	if err != nil {
+		return HistoryRange{}, fmt.Errorf("failed to count %s rows: %v", table, err)
+	}
+	return r, nil
+}
+
+// changeHistoryRange runs change on the rows of table dated within [from, to]
+// in one repeatable read transaction, once confirm accepts them as counted
+// there. Every statement sees the same rows: plays collected meanwhile are
+// neither counted nor touched. The range is returned as counted; when confirm
+// rejects it, nothing changes and the error is ErrHistoryChanged.
+func changeHistoryRange(pool *pgxpool.Pool, table string, from, to time.Time, confirm func(HistoryRange) bool,
+	change func(ctx context.Context, tx pgx.Tx, col string) (int64, error)) (int64, HistoryRange, error) {
+	col, ok := HistoryTables[table]
+	if !ok {
+		return 0, HistoryRange{}, fmt.Errorf("unknown history table %q", table)
+	}
+
+	ctx := context.Background()
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return 0, HistoryRange{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := countHistoryRange(ctx, tx, table, col, from, to)
+	if err != nil {
+		return 0, HistoryRange{}, err
+	}
+	if !confirm(current) {
+		return 0, current, ErrHistoryChanged
+	}
+	n, err := change(ctx, tx, col)
+	if err != nil {
+		return 0, current, err
+	}
+	return n, current, tx.Commit(ctx)
+}
+
+// DeleteHistoryRange deletes the rows of table dated within [from, to], if
+// confirm accepts them as counted by GetHistoryRange; annotations and
+// credited artists of deleted plays go with them
+func DeleteHistoryRange(pool *pgxpool.Pool, table string, from, to time.Time, confirm func(HistoryRange) bool) (int64, HistoryRange, error) {
+	return changeHistoryRange(pool, table, from, to, confirm, func(ctx context.Context, tx pgx.Tx, col string) (int64, error) {
+		tag, err := tx.Exec(ctx, fmt.Sprintf(
+			`DELETE FROM %[1]s WHERE %[2]s >= $1 AND %[2]s <= $2`, table, col), from, to)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete %s rows: %v", table, err)
+		}
+		return tag.RowsAffected(), nil
+	})
+}
+
+// AnonymizeHistoryRange blanks the track, artist and album names (and for
+// plays, the context, device and credited artists) of the rows of table
+// dated within [from, to], if confirm accepts them as counted by
+// GetHistoryRange
+func AnonymizeHistoryRange(pool *pgxpool.Pool, table string, from, to time.Time, confirm func(HistoryRange) bool) (int64, HistoryRange, error) {
+	return changeHistoryRange(pool, table, from, to, confirm, func(ctx context.Context, tx pgx.Tx, col string) (int64, error) {
+		if table == "recently_played" {
+			_, err := tx.Exec(ctx, `
+				DELETE FROM play_artists
+				WHERE play_id IN (SELECT id FROM recently_played WHERE played_at >= $1 AND played_at <= $2)`, from, to)
+			if err != nil {
+				return 0, fmt.Errorf("failed to remove credited artists: %v", err)
+			}
+		}
+		tag, err := tx.Exec(ctx, fmt.Sprintf(
+			`UPDATE %[1]s SET %[3]s WHERE %[2]s >= $1 AND %[2]s <= $2`, table, col, anonymizeSets[table]), from, to)
+		if err != nil {
+			return 0, fmt.Errorf("failed to anonymize %s rows: %v", table, err)
+		}
+		return tag.RowsAffected(), nil
+	})
+}
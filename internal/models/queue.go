@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// QueueGap is how long a track can be missing from the queue and still be the
+// same stretch in queue_log when it shows up again
+const QueueGap = 30 * time.Minute
+
+// QueueDropoffGrace is how long after a track was last seen queued a play of
+// it still counts; stretches newer than this aren't judged yet
+const QueueDropoffGrace = time.Hour
+
+// QueuedTrack is a track seen in the player's queue
+type QueuedTrack struct {
+	SpotifySongID string
+	TrackName     string
+	ArtistName    string
+	Position      int // 1 is next up
+}
+
+// RecordQueue stores a queue snapshot taken at seenAt: a track already queued
+// within QueueGap extends its stretch (keeping the closest position to the
+// front it got), any other starts a new one. playing is the track playing at
+// the time. Returns how many tracks were newly queued.
+func RecordQueue(pool *pgxpool.Pool, playing string, queued []QueuedTrack, seenAt time.Time) (int, error) {
+	if len(queued) == 0 {
+		return 0, nil
+	}
+	// A track queued twice keeps its first position
+	seen := map[string]bool{}
+	var ids, names, artists []string
+	var positions []int32
+	for _, q := range queued {
+		if q.SpotifySongID == "" || seen[q.SpotifySongID] {
+			continue
+		}
+		seen[q.SpotifySongID] = true
+		ids = append(ids, q.SpotifySongID)
+		names = append(names, q.TrackName)
+		artists = append(artists, q.ArtistName)
+		positions = append(positions, int32(q.Position))
+	}
+
+	var added int
+	err := pool.QueryRow(context.Background(), `
+		WITH snapshot AS (
+			SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::int[]) AS s(id, name, artist, position)
+		),
+		extended AS (
+			UPDATE queue_log q
+			SET last_seen_at = $5, position = LEAST(q.position, s.position)
+			FROM snapshot s
+			WHERE q.spotify_song_id = s.id
+			  AND q.last_seen_at >= $5::timestamptz - $6::float8 * INTERVAL '1 second'
+			RETURNING q.spotify_song_id
+		),
+		added AS (
+			INSERT INTO queue_log
+				(spotify_song_id, track_name, artist_name, position, queued_behind, first_seen_at, last_seen_at)
+			SELECT s.id, s.name, NULLIF(s.artist, ''), s.position, NULLIF($7, ''), $5, $5
+			FROM snapshot s
+			WHERE s.id NOT IN (SELECT spotify_song_id FROM extended)
+			RETURNING 1
+		)
+		SELECT COUNT(*) FROM added`,
+		ids, names, artists, positions, seenAt, QueueGap.Seconds(), playing).Scan(&added)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record queue: %v", err)
+	}
+	return added, nil
+}
+
+// QueueDropoffSummary counts queued stretches in a period and how many of
+// them ended without the track playing
+type QueueDropoffSummary struct {
+	Queued      int     `json:"queued"`
+	Played      int     `json:"played"`
+	Dropped     int     `json:"dropped"`
+	DropoffRate float64 `json:"dropoff_rate"` // dropped / queued, 0-1
+}
+
+// QueueDropoffTrack is a track that was queued and then never played
+type QueueDropoffTrack struct {
+	SpotifySongID string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	ArtistName    string    `json:"artist_name"`
+	TimesQueued   int       `json:"times_queued"`
+	TimesDropped  int       `json:"times_dropped"`
+	BestPosition  int       `json:"best_position"` // closest to the front it got while dropped
+	LastQueuedAt  time.Time `json:"last_queued_at"`
+}
+
+// settledQueueSQL lists the queue stretches first seen in [$1, $2) that are
+// over QueueDropoffGrace old, with whether the track played meanwhile: as a
+// collected play or in a now-playing snapshot, so a play skipped too fast to
+// reach recently-played still counts. $3 is the grace in seconds.
+const settledQueueSQL = `
+	WITH settled AS (
+		SELECT q.*,
+			EXISTS (
+				SELECT 1 FROM recently_played rp
+				WHERE rp.spotify_song_id = q.spotify_song_id
+				  AND rp.played_at BETWEEN q.first_seen_at AND q.last_seen_at + $3::float8 * INTERVAL '1 second'
+			) OR EXISTS (
+				SELECT 1 FROM now_playing_log n
+				WHERE n.spotify_song_id = q.spotify_song_id
+				  AND n.captured_at BETWEEN q.first_seen_at AND q.last_seen_at + $3::float8 * INTERVAL '1 second'
+			) AS played
+		FROM queue_log q
+		WHERE ($1::timestamptz IS NULL OR q.first_seen_at >= $1)
+		  AND ($2::timestamptz IS NULL OR q.first_seen_at < $2)
+		  AND q.last_seen_at < NOW() - $3::float8 * INTERVAL '1 second'
+	)`
+
+// GetQueueDropoffSummary counts the settled queue stretches first seen in [from, to)
+func GetQueueDropoffSummary(pool *pgxpool.Pool, from, to *time.Time) (QueueDropoffSummary, error) {
+	var s QueueDropoffSummary
+	err := pool.QueryRow(context.Background(), settledQueueSQL+`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE played), COUNT(*) FILTER (WHERE NOT played)
+		FROM settled`, from, to, QueueDropoffGrace.Seconds()).Scan(&s.Queued, &s.Played, &s.Dropped)
+	if err != nil {
+		return s, fmt.Errorf("failed to count queued tracks: %v", err)
+	}
+	if s.Queued > 0 {
+		s.DropoffRate = round3(float64(s.Dropped) / float64(s.Queued))
+	}
+	return s, nil
+}
+
+// GetQueueDropoffTracks returns the tracks queued in [from, to) that then
+// didn't play, most often dropped first
+func GetQueueDropoffTracks(pool *pgxpool.Pool, from, to *time.Time, limit int) ([]QueueDropoffTrack, error) {
+	rows, err := pool.Query(context.Background(), settledQueueSQL+`
+		SELECT spotify_song_id, MAX(track_name), COALESCE(MAX(artist_name), ''),
+			COUNT(*), COUNT(*) FILTER (WHERE NOT played),
+			MIN(position) FILTER (WHERE NOT played), MAX(first_seen_at)
+		FROM settled
+		GROUP BY spotify_song_id
+		HAVING COUNT(*) FILTER (WHERE NOT played) > 0
+		ORDER BY COUNT(*) FILTER (WHERE NOT played) DESC, MAX(first_seen_at) DESC
+		LIMIT $4`, from, to, QueueDropoffGrace.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dropped queued tracks: %v", err)
+	}
+	tracks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (QueueDropoffTrack, error) {
+		var t QueueDropoffTrack
+		err := row.Scan(&t.SpotifySongID, &t.TrackName, &t.ArtistName,
+			&t.TimesQueued, &t.TimesDropped, &t.BestPosition, &t.LastQueuedAt)
+		return t, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tracks == nil {
+		tracks = []QueueDropoffTrack{}
+	}
+	return tracks, nil
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ArtistRelease is an album or single by one of my top artists, found by the
+// release radar
+type ArtistRelease struct {
+	AlbumID              string    `json:"album_id"`
+	ArtistID             string    `json:"artist_id"`
+	ArtistName           string    `json:"artist_name"`
+	Name                 string    `json:"name"`
+	AlbumType            string    `json:"album_type"`
+	ReleaseDate          string    `json:"release_date"`
+	ReleaseDatePrecision string    `json:"release_date_precision"`
+	TotalTracks          int       `json:"total_tracks"`
+	ImageURL             string    `json:"image_url,omitempty"`
+	FirstSeenAt          time.Time `json:"first_seen_at"`
+	ArtistPlays          int       `json:"artist_plays"` // my plays of the artist
+}
+
+// ReleaseQuery narrows GetNewReleases
+type ReleaseQuery struct {
+	Since     time.Time // released on or after this day
+	AlbumType string    // album or single; empty for both
+	Limit     int
+}
+
+// GetReleaseRadarArtists returns which of my limit most played artists (with
+// a cached Spotify id) haven't had their releases checked since before
+func GetReleaseRadarArtists(pool *pgxpool.Pool, limit int, before time.Time) ([]DiscoverySeed, error) {
+	rows, err := pool.Query(context.Background(), `
+		WITH top AS (
+			SELECT MIN(a.artist_id) AS artist_id, rp.artist_name, COUNT(*) AS plays
+			FROM recently_played rp
+			JOIN artists a ON a.name = rp.artist_name
+			GROUP BY rp.artist_name
+			ORDER BY plays DESC
+			LIMIT $1
+		)
+		SELECT t.artist_id, t.artist_name, t.plays
+		FROM top t
+		JOIN artists a ON a.artist_id = t.artist_id
+		WHERE a.releases_checked_at IS NULL OR a.releases_checked_at < $2
+		ORDER BY a.releases_checked_at NULLS FIRST, t.plays DESC`, limit, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release radar artists: %v", err)
+	}
+	defer rows.Close()
+
+	var seeds []DiscoverySeed
+	for rows.Next() {
+		var s DiscoverySeed
+		if err := rows.Scan(&s.ID, &s.Name, &s.Plays); err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, rows.Err()
+}
+
+// SaveArtistReleases stores releases not seen before and marks the artist as
+// checked. It returns how many releases were new.
+func SaveArtistReleases(pool *pgxpool.Pool, artistID string, releases []ArtistRelease) (int, error) {
+	ctx := context.Background()
+	saved := 0
+	for _, r := range releases {
+		tag, err := pool.Exec(ctx, `
+			INSERT INTO artist_releases (album_id, artist_id, artist_name, name, album_type,
+				release_date, release_date_precision, total_tracks, image_url)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (album_id) DO NOTHING`,
+			r.AlbumID, r.ArtistID, r.ArtistName, r.Name, nullIfEmpty(r.AlbumType),
+			nullIfEmpty(r.ReleaseDate), nullIfEmpty(r.ReleaseDatePrecision), r.TotalTracks,
+			nullIfEmpty(r.ImageURL))
+		if err != nil {
+			return saved, fmt.Errorf("failed to save release %s: %v", r.AlbumID, err)
+		}
+		saved += int(tag.RowsAffected())
+	}
+	if _, err := pool.Exec(ctx,
+		`UPDATE artists SET releases_checked_at = NOW() WHERE artist_id = $1`, artistID); err != nil {
+		return saved, fmt.Errorf("failed to mark releases of %s checked: %v", artistID, err)
+	}
+	return saved, nil
+}
+
+// GetNewReleases returns stored releases I haven't played a track of, newest
+// first. Albums are matched to plays by album and artist name, as in the
+// discovery feed. Release dates of year or month precision count from their
+// first day.
+func GetNewReleases(pool *pgxpool.Pool, q ReleaseQuery) ([]ArtistRelease, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT r.album_id, r.artist_id, r.artist_name, r.name, COALESCE(r.album_type, ''),
+			COALESCE(r.release_date, ''), COALESCE(r.release_date_precision, ''),
+			COALESCE(r.total_tracks, 0), COALESCE(r.image_url, ''), r.first_seen_at,
+			(SELECT COUNT(*) FROM recently_played rp WHERE rp.artist_name = r.artist_name)
+		FROM artist_releases r
+		WHERE r.release_date >= LEFT($1, LENGTH(r.release_date))
+		  AND ($2 = '' OR r.album_type = $2)
+		  AND NOT EXISTS (SELECT 1 FROM recently_played rp
+			WHERE LOWER(rp.album_name) = LOWER(r.name) AND LOWER(rp.artist_name) = LOWER(r.artist_name))
+		ORDER BY r.release_date DESC, r.first_seen_at DESC
+		LIMIT $3`, q.Since.Format("2006-01-02"), q.AlbumType, q.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new releases: %v", err)
+	}
+	defer rows.Close()
+
+	releases := []ArtistRelease{}
+	for rows.Next() {
+		var r ArtistRelease
+		if err := rows.Scan(&r.AlbumID, &r.ArtistID, &r.ArtistName, &r.Name, &r.AlbumType,
+			&r.ReleaseDate, &r.ReleaseDatePrecision, &r.TotalTracks, &r.ImageURL, &r.FirstSeenAt,
+			&r.ArtistPlays); err != nil {
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+	return releases, rows.Err()
+}
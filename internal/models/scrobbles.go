@@ -0,0 +1,282 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Services plays are scrobbled to. Each keeps its own status, attempts and
+// error columns in recently_played.
+const (
+	ScrobbleLastFM       = "lastfm"
+	ScrobbleListenBrainz = "listenbrainz"
+)
+
+// ScrobbleServices lists every service, in the order they're reported
+var ScrobbleServices = []string{ScrobbleLastFM, ScrobbleListenBrainz}
+
+// scrobbleColumns is the column prefix of each service
+var scrobbleColumns = map[string]string{
+	ScrobbleLastFM:       "scrobble",
+	ScrobbleListenBrainz: "listenbrainz",
+}
+
+// Scrobble statuses of a play. NULL means it was never queued.
+const (
+	ScrobblePending   = "pending"
+	ScrobbleSubmitted = "submitted" // accepted by ListenBrainz, its recording MSID not yet seen
+	ScrobbleSent      = "sent"
+	ScrobbleFailed    = "failed"  // retried until it has used up its attempts
+	ScrobbleIgnored   = "ignored" // rejected by the service, or never sendable
+)
+
+// scrobbleSQL fills the status, attempts and error column names of service
+// into query as %[1]s, %[2]s and %[3]s
+func scrobbleSQL(service, query string) string {
+	prefix, ok := scrobbleColumns[service]
+	if !ok {
+		panic("unknown scrobble service " + service)
+	}
+	return fmt.Sprintf(query, prefix+"_status", prefix+"_attempts", prefix+"_error")
+}
+
+// PendingScrobble is a queued play waiting to be sent
+type PendingScrobble struct {
+	ID            int
+	SpotifySongID string
+	TrackName     string
+	ArtistName    string
+	AlbumName     string
+	PlayedAt      time.Time
+	DurationMs    int
+	Attempts      int
+}
+
+// FailedScrobble is a play whose last send failed
+type FailedScrobble struct {
+	ID         int       `json:"id"`
+	TrackName  string    `json:"track_name"`
+	ArtistName string    `json:"artist_name"`
+	PlayedAt   time.Time `json:"played_at"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error"`
+}
+
+// ScrobbleSummary counts the plays queued for one service by status
+type ScrobbleSummary struct {
+	Counts map[string]int   `json:"counts"`
+	Failed []FailedScrobble `json:"failed"` // most recent first
+}
+
+// QueueScrobbles marks the plays identified by song id and played_at as
+// pending for service. Plays already queued keep their status.
+func QueueScrobbles(pool *pgxpool.Pool, service string, songIDs []string, playedAt []time.Time) (int, error) {
+	if len(songIDs) == 0 {
+		return 0, nil
+	}
+	tag, err := pool.Exec(context.Background(), scrobbleSQL(service, `
+		UPDATE recently_played rp SET %[1]s = 'pending'
+		FROM UNNEST($1::text[], $2::timestamptz[]) AS q(spotify_song_id, played_at)
+		WHERE rp.spotify_song_id = q.spotify_song_id AND rp.played_at = q.played_at
+		  AND rp.%[1]s IS NULL`), songIDs, playedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue %s scrobbles: %v", service, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// GetPendingScrobbles returns up to limit plays in status with fewer than
+// maxAttempts tries (pending plays also include failed ones), oldest first
+func GetPendingScrobbles(pool *pgxpool.Pool, service, status string, maxAttempts, limit int) ([]PendingScrobble, error) {
+	statuses := []string{status}
+	if status == ScrobblePending {
+		statuses = append(statuses, ScrobbleFailed)
+	}
+	return scanPendingScrobbles(pool.Query(context.Background(), scrobbleSQL(service, `
+		SELECT id, spotify_song_id, track_name, COALESCE(artist_name, ''), COALESCE(album_name, ''),
+			played_at, COALESCE(duration_ms, 0), %[2]s
+		FROM recently_played
+		WHERE %[1]s = ANY($1) AND %[2]s < $2
+		ORDER BY played_at
+		LIMIT $3`), statuses, maxAttempts, limit))
+}
+
+// GetUnsentPlays returns up to limit plays after (afterPlayedAt, afterID)
+// that were never sent to service, queued or not, oldest first. It pages a
+// backfill.
+func GetUnsentPlays(pool *pgxpool.Pool, service string, afterPlayedAt time.Time, afterID, limit int) ([]PendingScrobble, error) {
+	return scanPendingScrobbles(pool.Query(context.Background(), scrobbleSQL(service, `
+		SELECT id, spotify_song_id, track_name, COALESCE(artist_name, ''), COALESCE(album_name, ''),
+			played_at, COALESCE(duration_ms, 0), %[2]s
+		FROM recently_played
+		WHERE (%[1]s IS NULL OR %[1]s IN ('pending', 'failed')) AND (played_at, id) > ($1, $2)
+		ORDER BY played_at, id
+		LIMIT $3`), afterPlayedAt, afterID, limit))
+}
+
+// CountUnsentPlays counts what GetUnsentPlays would page through
+func CountUnsentPlays(pool *pgxpool.Pool, service string) (int, error) {
+	var n int
+	err := pool.QueryRow(context.Background(), scrobbleSQL(service, `
+		SELECT COUNT(*) FROM recently_played
+		WHERE %[1]s IS NULL OR %[1]s IN ('pending', 'failed')`)).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count plays not sent to %s: %v", service, err)
+	}
+	return n, nil
+}
+
+func scanPendingScrobbles(rows pgx.Rows, err error) ([]PendingScrobble, error) {
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending scrobbles: %v", err)
+	}
+	defer rows.Close()
+
+	var pending []PendingScrobble
+	for rows.Next() {
+		var p PendingScrobble
+		if err := rows.Scan(&p.ID, &p.SpotifySongID, &p.TrackName, &p.ArtistName, &p.AlbumName,
+			&p.PlayedAt, &p.DurationMs, &p.Attempts); err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// ExpireScrobbles gives up on plays queued for service from before cutoff,
+// which it would no longer accept
+func ExpireScrobbles(pool *pgxpool.Pool, service string, cutoff time.Time) (int, error) {
+	tag, err := pool.Exec(context.Background(), scrobbleSQL(service, `
+		UPDATE recently_played
+		SET %[1]s = 'ignored', %[3]s = 'played too long ago'
+		WHERE %[1]s IN ('pending', 'failed') AND played_at < $1`), cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire %s scrobbles: %v", service, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// SetScrobbleStatus records the outcome of sending plays to service. A
+// failed status also counts an attempt; reason is kept for failed and
+// ignored plays.
+func SetScrobbleStatus(pool *pgxpool.Pool, service string, ids []int, status, reason string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := pool.Exec(context.Background(), scrobbleSQL(service, `
+		UPDATE recently_played
+		SET %[1]s = $2,
+			%[3]s = NULLIF($3, ''),
+			%[2]s = %[2]s + CASE WHEN $2 = 'failed' THEN 1 ELSE 0 END
+		WHERE id = ANY($1)`), ids, status, reason)
+	if err != nil {
+		return fmt.Errorf("failed to mark %d %s scrobbles %s: %v", len(ids), service, status, err)
+	}
+	return nil
+}
+
+// RetryFailedScrobbles puts every play that failed for service back in the
+// queue with its attempts reset
+func RetryFailedScrobbles(pool *pgxpool.Pool, service string) (int, error) {
+	tag, err := pool.Exec(context.Background(), scrobbleSQL(service, `
+		UPDATE recently_played
+		SET %[1]s = 'pending', %[2]s = 0, %[3]s = NULL
+		WHERE %[1]s = 'failed'`))
+	if err != nil {
+		return 0, fmt.Errorf("failed to retry %s scrobbles: %v", service, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// GetScrobbleSummary counts the plays queued for service by status and lists
+// up to limit of the most recent failures
+func GetScrobbleSummary(pool *pgxpool.Pool, service string, limit int) (*ScrobbleSummary, error) {
+	ctx := context.Background()
+	summary := &ScrobbleSummary{Counts: map[string]int{}, Failed: []FailedScrobble{}}
+
+	rows, err := pool.Query(ctx, scrobbleSQL(service, `
+		SELECT %[1]s, COUNT(*) FROM recently_played
+		WHERE %[1]s IS NOT NULL
+		GROUP BY %[1]s`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count %s scrobbles: %v", service, err)
+	}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		summary.Counts[status] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count %s scrobbles: %v", service, err)
+	}
+
+	rows, err = pool.Query(ctx, scrobbleSQL(service, `
+		SELECT id, track_name, COALESCE(artist_name, ''), played_at, %[2]s, COALESCE(%[3]s, '')
+		FROM recently_played
+		WHERE %[1]s = 'failed'
+		ORDER BY played_at DESC
+		LIMIT $1`), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failed %s scrobbles: %v", service, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f FailedScrobble
+		if err := rows.Scan(&f.ID, &f.TrackName, &f.ArtistName, &f.PlayedAt, &f.Attempts, &f.Error); err != nil {
+			return nil, err
+		}
+		summary.Failed = append(summary.Failed, f)
+	}
+	return summary, rows.Err()
+}
+
+// SetListenMSIDs marks plays found in ListenBrainz as sent, recording the
+// recording MSID ListenBrainz gave each one (by play id)
+func SetListenMSIDs(pool *pgxpool.Pool, msids map[int]string) error {
+	if len(msids) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(msids))
+	values := make([]string, 0, len(msids))
+	for id, msid := range msids {
+		ids = append(ids, id)
+		values = append(values, msid)
+	}
+	_, err := pool.Exec(context.Background(), `
+		UPDATE recently_played rp
+		SET listenbrainz_status = 'sent', listenbrainz_msid = q.msid, listenbrainz_error = NULL
+		FROM UNNEST($1::int[], $2::text[]) AS q(id, msid)
+		WHERE rp.id = q.id`, ids, values)
+	if err != nil {
+		return fmt.Errorf("failed to record %d ListenBrainz MSIDs: %v", len(msids), err)
+	}
+	return nil
+}
+
+// RequeueListens puts submitted plays that never showed up in ListenBrainz
+// back in the queue, counting an attempt; plays out of attempts fail
+func RequeueListens(pool *pgxpool.Pool, ids []int, maxAttempts int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := pool.Exec(context.Background(), `
+		UPDATE recently_played
+		SET listenbrainz_attempts = listenbrainz_attempts + 1,
+			listenbrainz_status = CASE WHEN listenbrainz_attempts + 1 >= $2 THEN 'failed' ELSE 'pending' END,
+			listenbrainz_error = 'submitted but not found in ListenBrainz'
+		WHERE id = ANY($1)`, ids, maxAttempts)
+	if err != nil {
+		return fmt.Errorf("failed to requeue %d listens: %v", len(ids), err)
+	}
+	return nil
+}
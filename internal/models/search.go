@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetTrackHistory returns play count, last play and liked status for each
+// Spotify track id. Every requested id is present in the result.
+func GetTrackHistory(pool *pgxpool.Pool, ids []string) (map[string]TrackHistory, error) {
+	history := make(map[string]TrackHistory, len(ids))
+	if len(ids) == 0 {
+		return history, nil
+	}
+
+	rows, err := pool.Query(context.Background(), `
+		SELECT
+			t.id,
+			COUNT(rp.id),
+			MAX(rp.played_at),
+			EXISTS (SELECT 1 FROM recently_liked rl WHERE rl.spotify_song_id = t.id)
+		FROM unnest($1::text[]) AS t(id)
+		LEFT JOIN recently_played rp ON rp.spotify_song_id = t.id
+		GROUP BY t.id`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track history: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var h TrackHistory
+		if err := rows.Scan(&id, &h.PlayCount, &h.LastPlayed, &h.Liked); err != nil {
+			return nil, err
+		}
+		history[id] = h
+	}
+	return history, rows.Err()
+}
+
+// GetArtistHistory returns play count, last play and liked track count for
+// each artist. Plays are matched on the primary artist name stored with the
+// play, liked tracks on the artist id.
+func GetArtistHistory(pool *pgxpool.Pool, ids, names []string) (map[string]ArtistHistory, error) {
+	history := make(map[string]ArtistHistory, len(ids))
+	if len(ids) == 0 {
+		return history, nil
+	}
+
+	rows, err := pool.Query(context.Background(), `
+		SELECT
+			a.id,
+			COUNT(rp.id),
+			MAX(rp.played_at),
+			(SELECT COUNT(*) FROM recently_liked rl WHERE rl.artist_id = a.id)
+		FROM unnest($1::text[], $2::text[]) AS a(id, name)
+		LEFT JOIN recently_played rp ON rp.artist_name = a.name
+		GROUP BY a.id`, ids, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artist history: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var h ArtistHistory
+		if err := rows.Scan(&id, &h.PlayCount, &h.LastPlayed, &h.LikedTracks); err != nil {
+			return nil, err
+		}
+		history[id] = h
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CountUnseededPlays returns how many plays weren't written by cmd/seed, so
+// it can refuse to mix synthetic rows into real history
+func CountUnseededPlays(pool *pgxpool.Pool) (int, error) {
+	var n int
+	err := pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM recently_played WHERE source IS DISTINCT FROM $1`, SourceSeed).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count plays: %v", err)
+	}
+	return n, nil
+}
+
+// DeleteSeeded removes every play and saved track written by cmd/seed
+func DeleteSeeded(pool *pgxpool.Pool) (plays, liked int64, err error) {
+	ctx := context.Background()
+	tag, err := pool.Exec(ctx, `DELETE FROM recently_played WHERE source = $1`, SourceSeed)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete seeded plays: %v", err)
+	}
+	plays = tag.RowsAffected()
+	tag, err = pool.Exec(ctx, `DELETE FROM recently_liked WHERE source = $1`, SourceSeed)
+	if err != nil {
+		return plays, 0, fmt.Errorf("failed to delete seeded saved tracks: %v", err)
+	}
+	return plays, tag.RowsAffected(), nil
+}
+
+// SetLikedGenre sets the genre column of a saved track, as the genre
+// backfill does
+func SetLikedGenre(pool *pgxpool.Pool, spotifyID, genre string) error {
+	_, err := pool.Exec(context.Background(),
+		`UPDATE recently_liked SET genre = $1 WHERE spotify_song_id = $2`, genre, spotifyID)
+	if err != nil {
+		return fmt.Errorf("failed to set genre of %s: %v", spotifyID, err)
+	}
+	return nil
+}
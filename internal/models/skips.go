@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SkipThreshold is the completion ratio below which a play counts as skipped
+const SkipThreshold = 0.8
+
+// InferCompletion fills completion_ratio and skipped for up to batchSize plays.
+//
+// Spotify stamps played_at when a track stops, so the time listened is roughly
+// the gap since the previous play. The ratio is that gap over duration_ms,
+// capped at 1 (a long gap means a pause or a new session, not a skip). Plays
+// without a duration are left alone until BackfillDuration fills them in.
+func InferCompletion(pool *pgxpool.Pool, batchSize int) (int, error) {
+	tag, err := pool.Exec(context.Background(), `
+		UPDATE recently_played rp
+		SET completion_ratio = x.ratio,
+		    skipped          = x.ratio < $1
+		FROM (
+			SELECT id,
+			       LEAST(EXTRACT(EPOCH FROM (played_at - prev_played_at)) * 1000 / duration_ms, 1) AS ratio
+			FROM (
+				SELECT cur.id, cur.played_at, cur.duration_ms,
+				       (SELECT MAX(p.played_at) FROM recently_played p
+				        WHERE p.played_at < cur.played_at) AS prev_played_at
+				FROM recently_played cur
+				WHERE cur.completion_ratio IS NULL
+				  AND cur.duration_ms > 0
+				  AND cur.played_at > (SELECT MIN(played_at) FROM recently_played)
+				ORDER BY cur.played_at
+				LIMIT $2
+			) pending
+			WHERE prev_played_at IS NOT NULL
+		) x
+		WHERE rp.id = x.id`, SkipThreshold, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to infer completion: %v", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// GetMostSkipped returns tracks with at least minPlays inferred plays in [from, to),
+// ordered by number of skips
+func GetMostSkipped(pool *pgxpool.Pool, from, to *time.Time, minPlays, limit int) ([]SkippedTrack, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT
+			spotify_song_id,
+			MAX(track_name),
+			COALESCE(MAX(artist_name), ''),
+			COUNT(*) AS plays,
+			COUNT(*) FILTER (WHERE skipped) AS skips,
+			AVG(completion_ratio)
+		FROM recently_played
+		WHERE completion_ratio IS NOT NULL
+		  AND ($1::timestamptz IS NULL OR played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR played_at < $2)
+		GROUP BY spotify_song_id
+		HAVING COUNT(*) >= $3 AND COUNT(*) FILTER (WHERE skipped) > 0
+		ORDER BY skips DESC, COUNT(*) FILTER (WHERE skipped)::float / COUNT(*) DESC
+		LIMIT $4`, from, to, minPlays, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get most skipped tracks: %v", err)
+	}
+	defer rows.Close()
+
+	results := []SkippedTrack{}
+	for rows.Next() {
+		var t SkippedTrack
+		if err := rows.Scan(&t.SpotifySongID, &t.TrackName, &t.ArtistName,
+			&t.Plays, &t.Skips, &t.AvgCompletion); err != nil {
+			return nil, err
+		}
+		t.SkipRate = float64(t.Skips) / float64(t.Plays)
+		results = append(results, t)
+	}
+	return results, rows.Err()
+}
+
+// GetArtistSkipRates returns skip rates for artists with at least minPlays
+// inferred plays in [from, to), highest rate first
+func GetArtistSkipRates(pool *pgxpool.Pool, from, to *time.Time, minPlays, limit int) ([]ArtistSkipRate, error) {
+	rows, err := pool.Query(context.Background(), `
+		SELECT
+			artist_name,
+			COUNT(*) AS plays,
+			COUNT(*) FILTER (WHERE skipped) AS skips,
+			AVG(completion_ratio)
+		FROM recently_played
+		WHERE completion_ratio IS NOT NULL
+		  AND artist_name IS NOT NULL AND artist_name <> ''
+		  AND ($1::timestamptz IS NULL OR played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR played_at < $2)
+		GROUP BY artist_name
+		HAVING COUNT(*) >= $3
+		ORDER BY COUNT(*) FILTER (WHERE skipped)::float / COUNT(*) DESC, plays DESC
+		LIMIT $4`, from, to, minPlays, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artist skip rates: %v", err)
+	}
+	defer rows.Close()
+
+	results := []ArtistSkipRate{}
+	for rows.Next() {
+		var a ArtistSkipRate
+		if err := rows.Scan(&a.ArtistName, &a.Plays, &a.Skips, &a.AvgCompletion); err != nil {
+			return nil, err
+		}
+		a.SkipRate = float64(a.Skips) / float64(a.Plays)
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,25 @@
+package models
+
+// Sources tag every recently_played and recently_liked row with what wrote it
+const (
+	SourceCron            = "cron"             // the recently-played and saved-tracks collectors
+	SourceCatchUp         = "catch-up"         // the startup catch-up after downtime
+	SourceFetchHistorical = "fetch-historical" // POST /fetch-historical
+	SourceRecovery        = "recovery"         // spotifydb recover
+	SourceGDPRExport      = "gdpr_export"      // spotifydb import
+	SourceSeed            = "seed"             // synthetic rows from cmd/seed
+	SourceAPI             = "api"              // POST /tracks/:id/like
+)
+
+// Sources lists the values accepted by ?source=
+var Sources = []string{SourceCron, SourceCatchUp, SourceFetchHistorical, SourceRecovery, SourceGDPRExport, SourceSeed, SourceAPI}
+
+// IsSource reports whether s is one of Sources
+func IsSource(s string) bool {
+	for _, known := range Sources {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
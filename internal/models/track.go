@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -10,56 +11,55 @@ import (
 	"example.com/spotifydb/internal/repository"
 	"example.com/spotifydb/internal/services"
 	"example.com/spotifydb/internal/utils"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // RecentlyLikedTracks represents a track from the recently_liked table
 type RecentlyLikedTracks struct {
-	ID                        int       `json:"id"`
-	SpotifyID                 string    `json:"spotify_song_id"`
-	TrackName                 string    `json:"track_name"`
-	TrackPopularity           *int      `json:"track_popularity"`
-	AlbumName                 *string   `json:"album_name"`
-	AlbumType                 *string   `json:"album_type"`
-	AlbumCoverURL             *string   `json:"album_cover_url"`
-	AlbumReleaseDate          *string   `json:"album_release_date"`
-	AlbumReleaseDatePrecision *string   `json:"album_release_date_precision"`
-	ArtistName                *string   `json:"artist_name"`
-	ArtistID                  *string   `json:"artist_id"`
-	ArtistHref                *string   `json:"artist_href"`
-	ArtistURI                 *string   `json:"artist_uri"`
-	AlbumTotalTracks          *int      `json:"album_total_tracks"`
-	AlbumCoverWidth           *int      `json:"album_cover_width"`
-	AlbumCoverHeight          *int      `json:"album_cover_height"`
-	Genre                     *string   `json:"genre"`
-	AddedAt                   time.Time `json:"added_at"`
+	ID                        int        `json:"id"`
+	SpotifyID                 string     `json:"spotify_song_id"`
+	TrackName                 string     `json:"track_name"`
+	TrackPopularity           *int       `json:"track_popularity"`
+	AlbumName                 *string    `json:"album_name"`
+	AlbumType                 *string    `json:"album_type"`
+	AlbumCoverURL             *string    `json:"album_cover_url"`
+	AlbumReleaseDate          *string    `json:"album_release_date"`
+	AlbumReleaseDatePrecision *string    `json:"album_release_date_precision"`
+	ArtistName                *string    `json:"artist_name"`
+	ArtistID                  *string    `json:"artist_id"`
+	ArtistHref                *string    `json:"artist_href"`
+	ArtistURI                 *string    `json:"artist_uri"`
+	AlbumTotalTracks          *int       `json:"album_total_tracks"`
+	AlbumCoverWidth           *int       `json:"album_cover_width"`
+	AlbumCoverHeight          *int       `json:"album_cover_height"`
+	Genre                     *string    `json:"genre"`
+	AddedAt                   time.Time  `json:"added_at"`
+	UnlikedAt                 *time.Time `json:"unliked_at,omitempty"`
+	Source                    string     `json:"source"`
 }
 
-// Cron writes one row per item; no touch on tracks_on_repeat
-func InsertRecentlyPlayed(
-	spotifyID, name, artist, album string, albumCoverURL string, genre string,
-	durationMs int, playedAt time.Time,
-) error {
-
-	_, err := repository.Pool.Exec(context.Background(), `
+const insertRecentlyPlayedSQL = `
 		INSERT INTO recently_played
 		      (spotify_song_id, track_name, artist_name, album_name, album_cover_url, genre,
-		       duration_ms, played_at, source)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		ON CONFLICT DO NOTHING`,
-		spotifyID, name, artist, album, albumCoverURL, genre, durationMs, playedAt, "cron")
-	return err
+		       duration_ms, played_at, source, genre_source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CASE WHEN $6 <> '' THEN 'artist' END)
+		ON CONFLICT DO NOTHING`
+
+// InsertRecentlyPlayed writes one play tagged with t.Source, the source that
+// collected it (see Sources), crediting t.ArtistName as its only artist until
+// SetPlayArtists says otherwise; no touch on tracks_on_repeat. t.ID is ignored.
+func InsertRecentlyPlayed(ctx context.Context, t RecentlyPlayedTrack) (inserted bool, err error) {
+	tag, err := repository.Pool.Exec(ctx, insertRecentlyPlayedSQL,
+		t.SpotifySongID, t.TrackName, t.ArtistName, t.AlbumName, t.AlbumCoverUrl, t.Genre,
+		t.DurationMS, t.PlayedAt, t.Source)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, SetPlayArtists(t.SpotifySongID, t.PlayedAt, nil, t.ArtistName)
 }
 
-// inserts into DATABSE Recently LIKED table
-func InsertRecentlyLiked(
-	spotifyID, trackName, trackPopularity, albumName,
-	albumType, albumCoverURL, albumReleaseDate, albumReleaseDatePrecision,
-	artistName, artistID, href, artistURI string,
-	albumTotalTracks, width, height int,
-	addedAt time.Time,
-) error {
-
-	query := `
+const insertRecentlyLikedSQL = `
 		INSERT INTO recently_liked (
 			spotify_song_id,
 			track_name,
@@ -76,15 +76,35 @@ func InsertRecentlyLiked(
 			album_total_tracks,
 			album_cover_width,
 			album_cover_height,
-			added_at
+			added_at,
+			source
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, 
-			$8, $9, $10, $11, $12, $13, $14, $15, $16
-		);
-	`
-
-	_, err := repository.Pool.Exec(
-		context.Background(), query,
+			$1, $2, $3, $4, $5, $6, $7,
+			$8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+		)
+		ON CONFLICT (spotify_song_id) DO UPDATE SET
+			added_at         = EXCLUDED.added_at,
+			track_popularity = EXCLUDED.track_popularity,
+			unliked_at       = NULL
+		WHERE recently_liked.added_at < EXCLUDED.added_at
+		RETURNING (xmax = 0) AS inserted`
+
+// InsertRecentlyLiked stores a saved track. A track that's already stored
+// is updated only when this save is newer (e.g. unliked and liked again):
+// added_at and popularity are refreshed and the track is un-soft-deleted.
+// The genre isn't known yet at this point and is left for the genre backfill.
+// The source of the first save is kept. inserted is false for an existing
+// track, updated or not.
+func InsertRecentlyLiked(
+	source string,
+	spotifyID, trackName, trackPopularity, albumName,
+	albumType, albumCoverURL, albumReleaseDate, albumReleaseDatePrecision,
+	artistName, artistID, href, artistURI string,
+	albumTotalTracks, width, height int,
+	addedAt time.Time,
+) (inserted bool, err error) {
+	err = repository.Pool.QueryRow(
+		context.Background(), insertRecentlyLikedSQL,
 		spotifyID,
 		trackName,
 		trackPopularity,
@@ -101,70 +121,145 @@ func InsertRecentlyLiked(
 		width,
 		height,
 		addedAt,
-	)
+		source,
+	).Scan(&inserted)
 
+	// No row back means the stored save is as new, so nothing changed
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
 	if err != nil {
 		fmt.Printf("InsertRecentlyLiked error: %v\n", err)
+		return false, err
 	}
+	return inserted, nil
+}
+
+// backfilling
 
-	return err
+// missingTrackDataWhere selects plays BackfillMissingTrackData can fill
+// A blank genre not yet run through the GenreResolver fallbacks counts as missing
+const missingTrackDataWhere = `album_cover_url IS NULL OR genre IS NULL OR album_release_date IS NULL OR (genre = '' AND genre_source IS NULL)`
 
+// CountMissingTrackData returns how many distinct tracks have plays missing an
+// album cover, genre or release date
+func CountMissingTrackData() (int, error) {
+	var n int
+	err := repository.Pool.QueryRow(context.Background(),
+		`SELECT COUNT(DISTINCT spotify_song_id) FROM recently_played WHERE `+missingTrackDataWhere).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tracks missing data: %v", err)
+	}
+	return n, nil
 }
 
-// backfilling
-func BackfillMissingTrackData(accessToken string) error {
+// BackfillMissingTrackData fills album covers, release dates and genres for up to limit
+// tracks whose plays are missing them, returning how many tracks it updated.
+// Tracks are fetched one by one, but their artists are resolved in batches of 50;
+// a track whose artist has no genres goes through GenreResolver's fallbacks.
+// Calls are paced by rateLimiter so the backfill shares the cron's budget.
+func BackfillMissingTrackData(ctx context.Context, accessToken string, limit int, rateLimiter *utils.RateLimiter) (int, error) {
 	rows, err := repository.Pool.Query(context.Background(), `
 	SELECT DISTINCT spotify_song_id
 	FROM recently_played
-	WHERE album_cover_url IS NULL OR genre IS NULL
-	`)
+	WHERE `+missingTrackDataWhere+`
+	LIMIT $1
+	`, limit)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer rows.Close()
 
+	var trackIDs []string
 	for rows.Next() {
 		var trackID string
-		if err := rows.Scan(&trackID); err != nil { //will need a syntax explanation
+		if err := rows.Scan(&trackID); err != nil {
 			continue
 		}
+		trackIDs = append(trackIDs, trackID)
+	}
+	rows.Close()
 
-		track, err := services.GetTrack(trackID, accessToken)
-
+	// First pass: album cover and primary artist per track
+	type trackInfo struct {
+		coverURL      string
+		releaseDate   string
+		datePrecision string
+		artistID      string
+		albumArtistID string
+	}
+	infos := make(map[string]trackInfo, len(trackIDs))
+	var artistIDs []string
+	for _, trackID := range trackIDs {
+		rateLimiter.Wait()
+		track, err := services.GetTrack(ctx, accessToken, trackID)
 		if err != nil {
-			log.Printf("error getting track id")
+			log.Printf("error getting track id %s: %v", trackID, err)
 			continue
 		}
-
-		artistID := track.Artists[0].ID
-		artist, err := services.GetArtistById(artistID, accessToken)
-		if err != nil {
-			log.Printf("Error fetching artist %s: %v", artistID, err)
+		if len(track.Artists) == 0 {
 			continue
 		}
 
-		coverURL := ""
+		info := trackInfo{
+			artistID:      track.Artists[0].ID,
+			releaseDate:   track.Album.ReleaseDate,
+			datePrecision: track.Album.ReleaseDatePrecision,
+		}
 		if len(track.Album.Images) > 0 {
-			coverURL = track.Album.Images[0].URL
+			info.coverURL = track.Album.Images[0].URL
+		}
+		if len(track.Album.Artists) > 0 {
+			info.albumArtistID = track.Album.Artists[0].ID
+		}
+		infos[trackID] = info
+		artistIDs = append(artistIDs, info.artistID, info.albumArtistID)
+	}
+
+	// Second pass: every distinct artist in as few calls as possible
+	resolver := NewGenreResolver(ctx, accessToken, rateLimiter)
+	if err := resolver.Prefetch(artistIDs); err != nil {
+		log.Printf("BackfillMissingTrackData: artist batch fetch stopped early: %v", err)
+	}
+
+	updated := 0
+	for trackID, info := range infos {
+		// Leave genre NULL for unresolved artists so the next run retries them
+		var genre, source *string
+		if resolver.Artist(info.artistID) != nil {
+			genres, src, err := resolver.Resolve(info.artistID, info.albumArtistID)
+			if err != nil {
+				log.Printf("BackfillMissingTrackData: genres of %s: %v", trackID, err)
+			} else {
+				if src == "" {
+					src = GenreSourceNone
+				}
+				joined := strings.Join(genres, ", ")
+				genre, source = &joined, &src
+				if err := SetTrackGenres(trackID, genres); err != nil {
+					log.Println(err)
+				}
+			}
 		}
-		genre := strings.Join(artist.Genres, ", ")
 
 		_, err = repository.Pool.Exec(context.Background(), `
 			UPDATE recently_played
-			SET album_cover_url = $1, genre = $2
+			SET album_cover_url = $1, genre = COALESCE($2, genre), genre_source = COALESCE($6, genre_source),
+				album_release_date = $4, album_release_date_precision = $5
 			WHERE spotify_song_id = $3
-		`, coverURL, genre, trackID)
+		`, info.coverURL, genre, trackID, info.releaseDate, nullIfEmpty(info.datePrecision), source)
 		if err != nil {
 			log.Printf("Failed to update %s: %v", trackID, err)
+			continue
 		}
+		updated++
 	}
 
-	return nil
+	return updated, nil
 
 }
 
 // BackfillDuration fetches duration_ms from Spotify for tracks missing it
-func BackfillDuration(accessToken string, rateLimiter *utils.RateLimiter) (int, error) {
+func BackfillDuration(ctx context.Context, accessToken string, rateLimiter *utils.RateLimiter) (int, error) {
 	// First collect all track IDs so we know the total
 	rows, err := repository.Pool.Query(context.Background(), `
 		SELECT DISTINCT spotify_song_id
@@ -199,14 +294,14 @@ func BackfillDuration(accessToken string, rateLimiter *utils.RateLimiter) (int,
 		// Rate limit protection
 		rateLimiter.Wait()
 
-		track, err := services.GetTrack(accessToken, trackID)
+		track, err := services.GetTrack(ctx, accessToken, trackID)
 		if err != nil {
-			if utils.IsRateLimitError(err) {
+			if services.IsRateLimited(err) {
 				// Wait and retry once on rate limit
-				waitTime := rateLimiter.HandleRateLimit("", 0)
+				waitTime := rateLimiter.HandleRateLimit(utils.RetryAfterHeader(err), 0)
 				time.Sleep(waitTime)
 				rateLimiter.Wait()
-				track, err = services.GetTrack(accessToken, trackID)
+				track, err = services.GetTrack(ctx, accessToken, trackID)
 			}
 			if err != nil {
 				log.Printf("BackfillDuration: error fetching track %s: %v", trackID, err)
@@ -240,76 +335,3 @@ func BackfillDuration(accessToken string, rateLimiter *utils.RateLimiter) (int,
 	log.Printf("BackfillDuration: complete — updated: %d, failed: %d, total: %d", updated, failed, total)
 	return updated, nil
 }
-
-// CollectRecentlyLiked gets all recently liked tracks from the database
-func CollectRecentlyLiked() []RecentlyLikedTracks {
-	fmt.Println("🔍 CollectRecentlyLiked called")
-
-	ctx := context.Background()
-
-	// First, let's check if the table has any data using a fresh context
-	var count int
-	countQuery := "SELECT COUNT(*) FROM recently_liked"
-	err := repository.Pool.QueryRow(ctx, countQuery).Scan(&count)
-	if err != nil {
-		fmt.Printf("❌ Error counting rows: %v\n", err)
-		return []RecentlyLikedTracks{}
-	}
-	fmt.Printf("📊 Found %d rows in recently_liked table\n", count)
-
-	if count == 0 {
-		fmt.Println("📭 No tracks found in recently_liked table")
-		return []RecentlyLikedTracks{}
-	}
-
-	// Use a simple query without complex formatting to avoid prepared statement conflicts
-	query := `
-		SELECT id, spotify_song_id, track_name, track_popularity,
-			album_name, album_type, album_cover_url,
-			album_release_date, album_release_date_precision,
-			artist_name, artist_id, artist_href, artist_uri,
-			album_total_tracks, album_cover_width, album_cover_height,
-			genre, added_at
-		FROM recently_liked
-		ORDER BY added_at DESC
-	`
-
-	rows, err := repository.Pool.Query(ctx, query)
-	if err != nil {
-		fmt.Printf("❌ Error executing CollectRecentlyLiked query: %v\n", err)
-		return []RecentlyLikedTracks{}
-	}
-	defer rows.Close()
-
-	var tracks []RecentlyLikedTracks
-	rowCount := 0
-	for rows.Next() {
-		var track RecentlyLikedTracks
-		err := rows.Scan(
-			&track.ID, &track.SpotifyID, &track.TrackName, &track.TrackPopularity,
-			&track.AlbumName, &track.AlbumType, &track.AlbumCoverURL,
-			&track.AlbumReleaseDate, &track.AlbumReleaseDatePrecision,
-			&track.ArtistName, &track.ArtistID, &track.ArtistHref, &track.ArtistURI,
-			&track.AlbumTotalTracks, &track.AlbumCoverWidth, &track.AlbumCoverHeight,
-			&track.Genre, &track.AddedAt,
-		)
-		if err != nil {
-			fmt.Printf("❌ Error scanning row %d: %v\n", rowCount+1, err)
-			continue
-		}
-		tracks = append(tracks, track)
-		rowCount++
-
-		// Log first track for debugging
-		if rowCount == 1 {
-			fmt.Printf("📀 First track: %s by %v (Genre: %v)\n", track.TrackName, track.ArtistName, track.Genre)
-		}
-	}
-
-	if err = rows.Err(); err != nil {
-		fmt.Printf("❌ Error iterating rows: %v\n", err)
-	}
-
-	fmt.Printf("✅ Returning %d tracks from CollectRecentlyLiked\n", len(tracks))
-	return tracks
-}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetTrackDetail gathers everything stored about a track, with up to
+// recentPlays of its latest plays. It returns nil when the track was never
+// played or saved.
+func GetTrackDetail(pool *pgxpool.Pool, spotifyID string, recentPlays int) (*TrackDetail, error) {
+	ctx := context.Background()
+	d := &TrackDetail{SpotifySongID: spotifyID, Genres: []string{}, Plays: TrackPlaySummary{Recent: []TrackPlay{}}}
+
+	// Names, cover and catalogue metadata from the latest play
+	var isrc *string
+	err := pool.QueryRow(ctx, `
+		SELECT track_name, COALESCE(artist_name, ''), COALESCE(album_name, ''),
+			COALESCE(album_cover_url, ''), COALESCE(duration_ms, 0), isrc, explicit
+		FROM recently_played
+		WHERE spotify_song_id = $1
+		ORDER BY played_at DESC
+		LIMIT 1`, spotifyID).
+		Scan(&d.TrackName, &d.ArtistName, &d.AlbumName, &d.AlbumCoverURL, &d.DurationMs, &isrc, &d.Explicit)
+	played := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get track %s: %v", spotifyID, err)
+	}
+
+	// Saved state, which also fills in the names for tracks never played
+	var like TrackLike
+	var name, artist, album, cover string
+	var likedISRC *string
+	var likedExplicit *bool
+	err = pool.QueryRow(ctx, `
+		SELECT added_at, unliked_at, COALESCE(artist_id, ''), track_name, COALESCE(artist_name, ''),
+			COALESCE(album_name, ''), COALESCE(album_cover_url, ''), isrc, explicit
+		FROM recently_liked
+		WHERE spotify_song_id = $1`, spotifyID).
+		Scan(&like.AddedAt, &like.UnlikedAt, &like.ArtistID, &name, &artist, &album, &cover,
+			&likedISRC, &likedExplicit)
+	switch {
+	case err == nil:
+		d.Liked = &like
+		if !played {
+			d.TrackName, d.ArtistName, d.AlbumName, d.AlbumCoverURL = name, artist, album, cover
+		}
+		if isrc == nil {
+			isrc = likedISRC
+		}
+		if d.Explicit == nil {
+			d.Explicit = likedExplicit
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, fmt.Errorf("failed to get liked track %s: %v", spotifyID, err)
+	case !played:
+		return nil, nil
+	}
+	if isrc != nil {
+		d.ISRC = *isrc
+	}
+
+	if err := pool.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(duration_ms), 0), COUNT(*) FILTER (WHERE skipped),
+			MIN(played_at), MAX(played_at)
+		FROM recently_played
+		WHERE spotify_song_id = $1`, spotifyID).
+		Scan(&d.Plays.Count, &d.Plays.TotalMs, &d.Plays.Skipped, &d.Plays.FirstPlayed, &d.Plays.LastPlayed); err != nil {
+		return nil, fmt.Errorf("failed to count plays of %s: %v", spotifyID, err)
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, played_at, COALESCE(source, ''), COALESCE(context_uri, ''), skipped
+		FROM recently_played
+		WHERE spotify_song_id = $1
+		ORDER BY played_at DESC
+		LIMIT $2`, spotifyID, recentPlays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plays of %s: %v", spotifyID, err)
+	}
+	for rows.Next() {
+		var p TrackPlay
+		if err := rows.Scan(&p.ID, &p.PlayedAt, &p.Source, &p.ContextURI, &p.Skipped); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Plays.Recent = append(d.Plays.Recent, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := pool.QueryRow(ctx, `
+		SELECT COALESCE(ARRAY_AGG(g.name ORDER BY g.name), '{}')
+		FROM track_genres tg
+		JOIN genres g ON g.id = tg.genre_id
+		WHERE tg.spotify_song_id = $1`, spotifyID).Scan(&d.Genres); err != nil {
+		return nil, fmt.Errorf("failed to get genres of %s: %v", spotifyID, err)
+	}
+
+	err = pool.QueryRow(ctx, `SELECT canonical_id FROM canonical_tracks WHERE spotify_song_id = $1`, spotifyID).
+		Scan(&d.CanonicalID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get canonical id of %s: %v", spotifyID, err)
+	}
+
+	// A row with NULL features means Spotify has no analysis for the track
+	var f TrackAudioFeatures
+	err = pool.QueryRow(ctx, `
+		SELECT danceability, energy, valence, tempo, acousticness, instrumentalness,
+			speechiness, liveness, loudness, key, mode, time_signature
+		FROM audio_features
+		WHERE spotify_song_id = $1 AND danceability IS NOT NULL`, spotifyID).
+		Scan(&f.Danceability, &f.Energy, &f.Valence, &f.Tempo, &f.Acousticness, &f.Instrumentalness,
+			&f.Speechiness, &f.Liveness, &f.Loudness, &f.Key, &f.Mode, &f.TimeSignature)
+	switch {
+	case err == nil:
+		d.AudioFeatures = &f
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, fmt.Errorf("failed to get audio features of %s: %v", spotifyID, err)
+	}
+	return d, nil
+}
@@ -138,8 +138,25 @@ import (
 // }
 
 func GetSingleTrack(pool *pgxpool.Pool, spotifyID string) (*Track, error) {
-	// might need to edit this serialization since im using postgres
-	query := "SELECT * FROM tracks_on_repeat WHERE spotify_song_id  = $1"
+	query := `
+		SELECT
+			id,
+			spotify_song_id,
+			track_name,
+			COALESCE(artist_name, ''),
+			COALESCE(album_name, ''),
+			COALESCE(genre, ''),
+			COALESCE(preview_url, ''),
+			COALESCE(album_cover_url, ''),
+			COALESCE(play_count, 0),
+			COALESCE(first_played, 'epoch'),
+			COALESCE(last_played, 'epoch'),
+			COALESCE(month_year, ''),
+			COALESCE(time_of_day, ''),
+			COALESCE(mood, ''),
+			COALESCE(activity, '')
+		FROM tracks_on_repeat
+		WHERE spotify_song_id = $1`
 
 	row := pool.QueryRow(context.Background(), query, spotifyID)
 	var t Track
@@ -167,23 +184,21 @@ func GetSingleTrack(pool *pgxpool.Pool, spotifyID string) (*Track, error) {
 
 }
 
-// update track in DB
+// UpdateTrackDB saves the manually tagged fields (mood, activity). The
+// counters are owned by SyncTracksOnRepeat.
 func (t Track) UpdateTrackDB(pool *pgxpool.Pool) error {
-
-	fmt.Print("ABOUT TO UPDATE!")
-
 	query := `
 	UPDATE tracks_on_repeat
-	SET play_count = $1,
-		last_played = $2
+	SET mood = NULLIF($1, ''),
+		activity = NULLIF($2, '')
 	WHERE spotify_song_id = $3;
 `
 
 	_, err := pool.Exec(
 		context.Background(),
 		query,
-		t.PlayCount,
-		time.Now(), // set last_played to now
+		t.Mood,
+		t.Activity,
 		t.SpotifySongID,
 	)
 	if err != nil {
@@ -196,8 +211,9 @@ func (t Track) UpdateTrackDB(pool *pgxpool.Pool) error {
 
 }
 
-// function that gets most recent plays from db
-func GetAllRecentPlayedHistory(pool *pgxpool.Pool) ([]RecentlyPlayedTrack, error) {
+// function that gets most recent plays from db, only those stored by source
+// unless it's empty
+func GetAllRecentPlayedHistory(pool *pgxpool.Pool, source string) ([]RecentlyPlayedTrack, error) {
 	query := `
 		SELECT
 			id,
@@ -211,10 +227,11 @@ func GetAllRecentPlayedHistory(pool *pgxpool.Pool) ([]RecentlyPlayedTrack, error
 			genre,
 			COALESCE(duration_ms, 0) AS duration_ms
 		FROM recently_played
+		WHERE ($1 = '' OR source = $1)
 		ORDER BY played_at DESC
 	`
 
-	rows, err := pool.Query(context.Background(), query)
+	rows, err := pool.Query(context.Background(), query, source)
 	if err != nil {
 		fmt.Println("Failed to query recently_played:", err)
 		return nil, err
@@ -248,3 +265,156 @@ func GetAllRecentPlayedHistory(pool *pgxpool.Pool) ([]RecentlyPlayedTrack, error
 	return results, nil
 
 }
+
+// GetMostPlayedFromHistory aggregates plays per track from recently_played
+// within [from, to). Nil bounds are open-ended. This replaces the manually
+// maintained play_count in tracks_on_repeat. With canonical set, variants of
+// the same recording (see canonical_tracks) are counted as one track. A
+// non-empty source counts only plays stored by it. A play of a track within
+// dedupWindow of its previous play is a near-duplicate (see
+// FindNearDuplicatePlays) and isn't counted; 0 counts every play.
+func GetMostPlayedFromHistory(pool *pgxpool.Pool, from, to *time.Time, limit int, canonical bool, source string, dedupWindow time.Duration) ([]MostPlayedTrack, error) {
+	query := `
+		WITH plays AS (
+			SELECT rp.*,
+				$6::float8 > 0 AND rp.played_at - LAG(rp.played_at) OVER (PARTITION BY rp.spotify_song_id ORDER BY rp.played_at, rp.id)
+					<= $6::float8 * INTERVAL '1 second' AS repeated
+			FROM recently_played rp
+			WHERE ($1::timestamptz IS NULL OR rp.played_at >= $1::timestamptz - $6::float8 * INTERVAL '1 second')
+			  AND ($2::timestamptz IS NULL OR rp.played_at < $2)
+		)
+		SELECT
+			CASE WHEN $4 THEN COALESCE(ct.canonical_id, rp.spotify_song_id) ELSE rp.spotify_song_id END AS track_id,
+			MAX(rp.track_name) AS track_name,
+			COALESCE(MAX(rp.artist_name), '') AS artist_name,
+			COALESCE(MAX(rp.album_name), '') AS album_name,
+			COALESCE(MAX(rp.album_cover_url), '') AS album_cover_url,
+			COALESCE(MAX(rp.genre), '') AS genre,
+			COUNT(*) AS play_count,
+			COALESCE(SUM(rp.duration_ms), 0) AS total_ms,
+			MIN(rp.played_at) AS first_played,
+			MAX(rp.played_at) AS last_played
+		FROM plays rp
+		LEFT JOIN canonical_tracks ct ON ct.spotify_song_id = rp.spotify_song_id
+		WHERE ($1::timestamptz IS NULL OR rp.played_at >= $1)
+		  AND ($5 = '' OR rp.source = $5)
+		  AND rp.repeated IS NOT TRUE
+		GROUP BY 1
+		ORDER BY play_count DESC, last_played DESC
+		LIMIT $3
+	`
+
+	rows, err := pool.Query(context.Background(), query, from, to, limit, canonical, source, dedupWindow.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get most played tracks: %v", err)
+	}
+	defer rows.Close()
+
+	var results []MostPlayedTrack
+	for rows.Next() {
+		var t MostPlayedTrack
+		if err := rows.Scan(
+			&t.SpotifySongID,
+			&t.TrackName,
+			&t.ArtistName,
+			&t.AlbumName,
+			&t.AlbumCoverUrl,
+			&t.Genre,
+			&t.PlayCount,
+			&t.TotalMs,
+			&t.FirstPlayed,
+			&t.LastPlayed,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, t)
+	}
+	return results, rows.Err()
+}
+
+// GetRecentlyPlayedChunk returns up to limit plays ordered by (played_at, id)
+// that come strictly after the given cursor, for streaming large exports
+// without loading the whole table. Pass a zero cursor to start at the beginning.
+// A non-empty source keeps only plays stored by it.
+func GetRecentlyPlayedChunk(pool *pgxpool.Pool, from, to *time.Time, source string, afterPlayedAt time.Time, afterID, limit int) ([]RecentlyPlayedTrack, error) {
+	query := `
+		SELECT
+			id,
+			spotify_song_id,
+			track_name,
+			COALESCE(artist_name, '') AS artist_name,
+			COALESCE(album_name, '') AS album_name,
+			played_at,
+			COALESCE(source, '') AS source,
+			COALESCE(album_cover_url, '') AS album_cover_url,
+			COALESCE(genre, '') AS genre,
+			COALESCE(duration_ms, 0) AS duration_ms
+		FROM recently_played
+		WHERE ($1::timestamptz IS NULL OR played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR played_at <= $2)
+		  AND ($6 = '' OR source = $6)
+		  AND (played_at, id) > ($3, $4)
+		ORDER BY played_at, id
+		LIMIT $5
+	`
+
+	rows, err := pool.Query(context.Background(), query, from, to, afterPlayedAt, afterID, limit, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recently_played chunk: %v", err)
+	}
+	defer rows.Close()
+
+	var results []RecentlyPlayedTrack
+	for rows.Next() {
+		var rpt RecentlyPlayedTrack
+		if err := rows.Scan(
+			&rpt.ID,
+			&rpt.SpotifySongID,
+			&rpt.TrackName,
+			&rpt.ArtistName,
+			&rpt.AlbumName,
+			&rpt.PlayedAt,
+			&rpt.Source,
+			&rpt.AlbumCoverUrl,
+			&rpt.Genre,
+			&rpt.DurationMS,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, rpt)
+	}
+	return results, rows.Err()
+}
+
+// TrackTagUpdate sets the mood and/or activity of a track on repeat; nil
+// fields are left alone and empty ones cleared
+type TrackTagUpdate struct {
+	SpotifySongID string
+	Mood          *string
+	Activity      *string
+}
+
+// UpdateTrackTags applies updates in order in one transaction and reports
+// which tracks were found. Nothing is written when any update fails.
+func UpdateTrackTags(ctx context.Context, pool *pgxpool.Pool, updates []TrackTagUpdate) ([]bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	found := make([]bool, len(updates))
+	for i, u := range updates {
+		tag, err := tx.Exec(ctx, `
+			UPDATE tracks_on_repeat
+			SET mood = CASE WHEN $2::text IS NULL THEN mood ELSE NULLIF($2, '') END,
+				activity = CASE WHEN $3::text IS NULL THEN activity ELSE NULLIF($3, '') END
+			WHERE spotify_song_id = $1`,
+			u.SpotifySongID, u.Mood, u.Activity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update track %s: %v", u.SpotifySongID, err)
+		}
+		found[i] = tag.RowsAffected() > 0
+	}
+	return found, tx.Commit(ctx)
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMostPlayedFractionalDedupWindow(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	id := testTrackID(t, pool)
+	base := time.Date(1990, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{0, time.Second, 2500 * time.Millisecond} {
+		if _, err := InsertRecentlyPlayed(ctx, RecentlyPlayedTrack{
+			SpotifySongID: id, TrackName: "Repeated", ArtistName: "Someone",
+			PlayedAt: base.Add(offset), Source: SourceCron,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the reports are 1s and 1.5s apart
+	from, to := base.Add(-time.Minute), base.Add(time.Minute)
+	for _, tt := range []struct {
+		window time.Duration
+		want   int
+	}{
+		{1500 * time.Millisecond, 1},
+		{1400 * time.Millisecond, 2},
+		{0, 3},
+	} {
+		tracks, err := GetMostPlayedFromHistory(pool, &from, &to, 10, false, "", tt.window)
+		if err != nil {
+			t.Fatalf("window %v: %v", tt.window, err)
+		}
+		got := 0
+		for _, tr := range tracks {
+			if tr.SpotifySongID == id {
+				got = tr.PlayCount
+			}
+		}
+		if got != tt.want {
+			t.Errorf("window %v: %d plays, want %d", tt.window, got, tt.want)
+		}
+	}
+}
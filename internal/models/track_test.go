@@ -0,0 +1,25 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInsertRecentlyPlayedReportsNewRows(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	play := RecentlyPlayedTrack{
+		SpotifySongID: testTrackID(t, pool), TrackName: "Once", ArtistName: "Someone",
+		PlayedAt: time.Date(1990, 2, 1, 12, 0, 0, 0, time.UTC), Source: SourceCron,
+	}
+	for i, want := range []bool{true, false} {
+		inserted, err := InsertRecentlyPlayed(ctx, play)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inserted != want {
+			t.Errorf("insert %d: inserted = %v, want %v", i+1, inserted, want)
+		}
+	}
+}
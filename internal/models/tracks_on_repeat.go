@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SyncTracksOnRepeat recomputes play_count, first_played, last_played and
+// month_year in tracks_on_repeat from recently_played, adding a row for every
+// track played. mood, activity and preview_url are left for the frontend to
+// edit. Tracks whose plays have all gone are zeroed rather than deleted so
+// their manual tags survive. Returns how many rows changed.
+func SyncTracksOnRepeat(ctx context.Context) (changed int64, err error) {
+	err = repository.InTx(ctx, func(tx pgx.Tx) error {
+		changed = 0
+
+		// Rows are upserted in key order to keep lock order stable; unchanged
+		// rows are skipped to keep the write small.
+		// first_played/last_played are legacy TIMESTAMP columns holding UTC.
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO tracks_on_repeat (
+				spotify_song_id, track_name, artist_name, album_name, genre, album_cover_url,
+				play_count, first_played, last_played, month_year
+			)
+			SELECT
+				spotify_song_id,
+				MAX(track_name),
+				MAX(artist_name),
+				MAX(album_name),
+				MAX(NULLIF(genre, '')),
+				MAX(NULLIF(album_cover_url, '')),
+				COUNT(*),
+				MIN(played_at) AT TIME ZONE 'UTC',
+				MAX(played_at) AT TIME ZONE 'UTC',
+				TO_CHAR(MAX(played_at) AT TIME ZONE $1, 'YYYY-MM')
+			FROM recently_played
+			GROUP BY spotify_song_id
+			ORDER BY spotify_song_id
+			ON CONFLICT (spotify_song_id) DO UPDATE SET
+				track_name      = EXCLUDED.track_name,
+				artist_name     = COALESCE(EXCLUDED.artist_name, tracks_on_repeat.artist_name),
+				album_name      = COALESCE(EXCLUDED.album_name, tracks_on_repeat.album_name),
+				genre           = COALESCE(EXCLUDED.genre, tracks_on_repeat.genre),
+				album_cover_url = COALESCE(EXCLUDED.album_cover_url, tracks_on_repeat.album_cover_url),
+				play_count      = EXCLUDED.play_count,
+				first_played    = EXCLUDED.first_played,
+				last_played     = EXCLUDED.last_played,
+				month_year      = EXCLUDED.month_year
+			WHERE tracks_on_repeat.play_count IS DISTINCT FROM EXCLUDED.play_count
+			   OR tracks_on_repeat.first_played IS DISTINCT FROM EXCLUDED.first_played
+			   OR tracks_on_repeat.last_played IS DISTINCT FROM EXCLUDED.last_played`,
+			config.Timezone().String())
+		if err != nil {
+			return fmt.Errorf("failed to upsert tracks_on_repeat: %w", err)
+		}
+		changed += tag.RowsAffected()
+
+		tag, err = tx.Exec(ctx, `
+			UPDATE tracks_on_repeat tor
+			SET play_count = 0, first_played = NULL, last_played = NULL
+			WHERE tor.play_count <> 0
+			  AND NOT EXISTS (
+				SELECT 1 FROM recently_played rp WHERE rp.spotify_song_id = tor.spotify_song_id
+			  )`)
+		if err != nil {
+			return fmt.Errorf("failed to reset orphaned tracks_on_repeat rows: %w", err)
+		}
+		changed += tag.RowsAffected()
+		return nil
+	})
+	return changed, err
+}
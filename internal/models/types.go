@@ -33,3 +33,286 @@ type RecentlyPlayedTrack struct {
 	AlbumCoverUrl string    `json:"album_cover_url"`
 	Genre         string    `json:"genre"`
 }
+
+// MostPlayedTrack is a per-track play aggregate computed from recently_played
+type MostPlayedTrack struct {
+	SpotifySongID string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	ArtistName    string    `json:"artist_name"`
+	AlbumName     string    `json:"album_name"`
+	AlbumCoverUrl string    `json:"album_cover_url"`
+	Genre         string    `json:"genre"`
+	PlayCount     int       `json:"play_count"`
+	TotalMs       int64     `json:"total_ms"`
+	FirstPlayed   time.Time `json:"first_played"`
+	LastPlayed    time.Time `json:"last_played"`
+}
+
+// SkippedTrack is a per-track skip aggregate over plays with an inferred completion
+type SkippedTrack struct {
+	SpotifySongID string  `json:"spotify_song_id"`
+	TrackName     string  `json:"track_name"`
+	ArtistName    string  `json:"artist_name"`
+	Plays         int     `json:"plays"`
+	Skips         int     `json:"skips"`
+	SkipRate      float64 `json:"skip_rate"`
+	AvgCompletion float64 `json:"avg_completion"`
+}
+
+// ArtistSkipRate is the share of an artist's plays that were skipped
+type ArtistSkipRate struct {
+	ArtistName    string  `json:"artist_name"`
+	Plays         int     `json:"plays"`
+	Skips         int     `json:"skips"`
+	SkipRate      float64 `json:"skip_rate"`
+	AvgCompletion float64 `json:"avg_completion"`
+}
+
+// DeviceStat is listening aggregated by playback device
+type DeviceStat struct {
+	DeviceName  string   `json:"device_name"`
+	DeviceType  string   `json:"device_type"`
+	Plays       int      `json:"plays"`
+	TotalMs     int64    `json:"total_ms"`
+	ShuffleRate *float64 `json:"shuffle_rate"` // nil when shuffle state is unknown
+}
+
+// ContextTypeStat is listening aggregated by context type (playlist, album, ...)
+type ContextTypeStat struct {
+	ContextType string `json:"context_type"`
+	Plays       int    `json:"plays"`
+	TotalMs     int64  `json:"total_ms"`
+}
+
+// ContextStat is one playlist/album/artist context and how much it was played
+type ContextStat struct {
+	ContextType  string    `json:"context_type"`
+	ContextURI   string    `json:"context_uri"`
+	Plays        int       `json:"plays"`
+	UniqueTracks int       `json:"unique_tracks"`
+	LastPlayed   time.Time `json:"last_played"`
+}
+
+// TrackHistory is my listening history for one track, used to annotate search results
+type TrackHistory struct {
+	PlayCount  int        `json:"play_count"`
+	LastPlayed *time.Time `json:"last_played"`
+	Liked      bool       `json:"liked"`
+}
+
+// ArtistHistory is my listening history for one artist
+type ArtistHistory struct {
+	PlayCount   int        `json:"play_count"`
+	LastPlayed  *time.Time `json:"last_played"`
+	LikedTracks int        `json:"liked_tracks"`
+}
+
+// CalendarDay is one cell of the listening heatmap
+type CalendarDay struct {
+	Date    string `json:"date"`
+	Plays   int    `json:"plays"`
+	TotalMs int64  `json:"total_ms"`
+}
+
+// ListeningStreak is a run of consecutive days with at least one play
+type ListeningStreak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ComparisonEntry is one artist, track or genre's plays in two windows
+type ComparisonEntry struct {
+	Key    string  `json:"key"` // artist name, spotify_song_id or genre
+	Name   string  `json:"name"`
+	Artist *string `json:"artist,omitempty"` // tracks only
+	PlaysA int     `json:"plays_a"`
+	PlaysB int     `json:"plays_b"`
+	Delta  int     `json:"delta"`
+}
+
+// Comparison splits entries by how they changed from window A to window B
+type Comparison struct {
+	New     []ComparisonEntry `json:"new"`     // played in B only
+	Dropped []ComparisonEntry `json:"dropped"` // played in A only
+	Rose    []ComparisonEntry `json:"rose"`
+	Fell    []ComparisonEntry `json:"fell"`
+}
+
+// ListeningPatterns is a weekday x hour matrix of plays, in the configured
+// timezone. Rows start on Sunday, matching Postgres' extract(dow).
+type ListeningPatterns struct {
+	Weekdays   []string     `json:"weekdays"`
+	Plays      [7][24]int   `json:"plays"`
+	Minutes    [7][24]int64 `json:"minutes"`
+	TotalPlays int          `json:"total_plays"`
+	PeakDay    string       `json:"peak_day,omitempty"`
+	PeakHour   *int         `json:"peak_hour,omitempty"`
+}
+
+// TopAlbum is one album's aggregated plays from recently_played
+type TopAlbum struct {
+	AlbumName      string    `json:"album_name"`
+	ArtistName     string    `json:"artist_name"`
+	AlbumCoverUrl  string    `json:"album_cover_url"`
+	PlayCount      int       `json:"play_count"`
+	DistinctTracks int       `json:"distinct_tracks"`
+	TotalMs        int64     `json:"total_ms"`
+	FirstPlayed    time.Time `json:"first_played"`
+	LastPlayed     time.Time `json:"last_played"`
+}
+
+// AlbumTrackPlays is one track's plays within an album (or by an artist)
+type AlbumTrackPlays struct {
+	SpotifySongID string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	PlayCount     int       `json:"play_count"`
+	TotalMs       int64     `json:"total_ms"`
+	LastPlayed    time.Time `json:"last_played"`
+}
+
+// TopArtist is an artist ranked by my plays; ArtistID is set when the
+// artist is cached (plays only store the primary artist's name)
+type TopArtist struct {
+	ArtistName     string    `json:"artist_name"`
+	ArtistID       *string   `json:"artist_id"`
+	PlayCount      int       `json:"play_count"`
+	FeaturedPlays  int       `json:"featured_plays"` // of PlayCount, plays where they weren't the primary artist
+	DistinctTracks int       `json:"distinct_tracks"`
+	TotalMs        int64     `json:"total_ms"`
+	LastPlayed     time.Time `json:"last_played"`
+}
+
+// ArtistPlays is my listening history for one artist. First/last played are
+// nil when I've never played them.
+type ArtistPlays struct {
+	PlayCount      int               `json:"play_count"`
+	FeaturedPlays  int               `json:"featured_plays"` // of PlayCount, plays where they weren't the primary artist
+	DistinctTracks int               `json:"distinct_tracks"`
+	TotalMs        int64             `json:"total_ms"`
+	FirstPlayed    *time.Time        `json:"first_played"`
+	LastPlayed     *time.Time        `json:"last_played"`
+	TopTracks      []AlbumTrackPlays `json:"top_tracks"`
+}
+
+// Discovery is a track first played within a period, with how often it has
+// been played again since
+type Discovery struct {
+	SpotifySongID string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	ArtistName    string    `json:"artist_name"`
+	AlbumCoverUrl string    `json:"album_cover_url"`
+	FirstPlayed   time.Time `json:"first_played"`
+	LastPlayed    time.Time `json:"last_played"`
+	Replays       int       `json:"replays"`
+}
+
+// PlayAnnotation is my mood/activity tagging of one play
+type PlayAnnotation struct {
+	PlayID    int       `json:"play_id"`
+	Mood      string    `json:"mood,omitempty"`
+	Activity  string    `json:"activity,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AnnotatedPlay is a play with its annotation, if any
+type AnnotatedPlay struct {
+	ID            int       `json:"id"`
+	SpotifySongID string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	ArtistName    string    `json:"artist_name"`
+	AlbumName     string    `json:"album_name"`
+	AlbumCoverUrl string    `json:"album_cover_url"`
+	DurationMS    int       `json:"duration_ms"`
+	PlayedAt      time.Time `json:"played_at"`
+	Mood          string    `json:"mood,omitempty"`
+	Activity      string    `json:"activity,omitempty"`
+	Note          string    `json:"note,omitempty"`
+}
+
+// Milestone is a listening milestone, e.g. the 1,000th play or a track's
+// 50th play. Title is built from the kind, value and label.
+type Milestone struct {
+	ID            int       `json:"id"`
+	Kind          string    `json:"kind"`
+	Title         string    `json:"title"`
+	Value         int       `json:"value"`
+	Label         string    `json:"label"`
+	SpotifySongID string    `json:"spotify_song_id,omitempty"`
+	AchievedAt    time.Time `json:"achieved_at"`
+	DetectedAt    time.Time `json:"detected_at"`
+}
+
+// CollectionGap is a stretch where plays are missing: either no plays for
+// longer than expected during active hours, or collection failing outright.
+// EndedAt is nil while the gap is still open.
+type CollectionGap struct {
+	ID            int        `json:"id"`
+	Kind          string     `json:"kind"`
+	StartedAt     time.Time  `json:"started_at"`
+	EndedAt       *time.Time `json:"ended_at"`
+	ActiveMinutes int        `json:"active_minutes"`
+	Reason        string     `json:"reason"`
+	DetectedAt    time.Time  `json:"detected_at"`
+}
+
+// TrackDetail is everything stored about one track across plays, saved
+// tracks, genres, canonical ids and audio features
+type TrackDetail struct {
+	SpotifySongID string              `json:"spotify_song_id"`
+	TrackName     string              `json:"track_name"`
+	ArtistName    string              `json:"artist_name"`
+	AlbumName     string              `json:"album_name"`
+	AlbumCoverURL string              `json:"album_cover_url"`
+	DurationMs    int                 `json:"duration_ms"`
+	ISRC          string              `json:"isrc,omitempty"`
+	Explicit      *bool               `json:"explicit,omitempty"`
+	CanonicalID   string              `json:"canonical_id,omitempty"`
+	Genres        []string            `json:"genres"`
+	Plays         TrackPlaySummary    `json:"plays"`
+	Liked         *TrackLike          `json:"liked"`
+	AudioFeatures *TrackAudioFeatures `json:"audio_features"`
+}
+
+// TrackPlaySummary totals a track's plays and lists the latest ones
+type TrackPlaySummary struct {
+	Count       int         `json:"count"`
+	TotalMs     int64       `json:"total_ms"`
+	Skipped     int         `json:"skipped"`
+	FirstPlayed *time.Time  `json:"first_played"`
+	LastPlayed  *time.Time  `json:"last_played"`
+	Recent      []TrackPlay `json:"recent"`
+}
+
+// TrackPlay is one play in a TrackDetail
+type TrackPlay struct {
+	ID         int       `json:"id"`
+	PlayedAt   time.Time `json:"played_at"`
+	Source     string    `json:"source"`
+	ContextURI string    `json:"context_uri,omitempty"`
+	Skipped    *bool     `json:"skipped,omitempty"`
+}
+
+// TrackLike is a track's saved state; UnlikedAt is set once it was unliked
+type TrackLike struct {
+	AddedAt   time.Time  `json:"added_at"`
+	UnlikedAt *time.Time `json:"unliked_at,omitempty"`
+	ArtistID  string     `json:"-"`
+}
+
+// TrackAudioFeatures is Spotify's audio analysis of a track
+type TrackAudioFeatures struct {
+	Danceability     float64 `json:"danceability"`
+	Energy           float64 `json:"energy"`
+	Valence          float64 `json:"valence"`
+	Tempo            float64 `json:"tempo"`
+	Acousticness     float64 `json:"acousticness"`
+	Instrumentalness float64 `json:"instrumentalness"`
+	Speechiness      float64 `json:"speechiness"`
+	Liveness         float64 `json:"liveness"`
+	Loudness         float64 `json:"loudness"`
+	Key              int     `json:"key"`
+	Mode             int     `json:"mode"`
+	TimeSignature    int     `json:"time_signature"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// A version identifies the state of a table cheaply enough to compute on
+// every request: when it last changed, and a fingerprint of row counts that
+// also moves when rows are deleted or backfilled without a newer timestamp.
+
+// PlaysVersion versions recently_played by its newest play, row count, how
+// many plays have a genre and album cover so far and how many are named
+// (anonymized plays aren't)
+func PlaysVersion(pool *pgxpool.Pool) (modified time.Time, fingerprint string, err error) {
+	var newest *time.Time
+	var rows, genres, covers, named int
+	err = pool.QueryRow(context.Background(), `
+		SELECT MAX(played_at), COUNT(*),
+			COUNT(NULLIF(genre, '')), COUNT(NULLIF(album_cover_url, '')), COUNT(NULLIF(track_name, ''))
+		FROM recently_played`).Scan(&newest, &rows, &genres, &covers, &named)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("failed to version recently_played: %v", err)
+	}
+	if newest != nil {
+		modified = *newest
+	}
+	return modified, fmt.Sprintf("%d.%d.%d.%d", rows, genres, covers, named), nil
+}
+
+// LikedVersion versions recently_liked by its newest save or unlike, row
+// count, unliked count, how many tracks have a genre so far and how many are
+// named
+func LikedVersion(pool *pgxpool.Pool) (modified time.Time, fingerprint string, err error) {
+	var newest *time.Time
+	var rows, unliked, genres, named int
+	err = pool.QueryRow(context.Background(), `
+		SELECT GREATEST(MAX(added_at), MAX(unliked_at)), COUNT(*),
+			COUNT(unliked_at), COUNT(NULLIF(genre, '')), COUNT(NULLIF(track_name, ''))
+		FROM recently_liked`).Scan(&newest, &rows, &unliked, &genres, &named)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("failed to version recently_liked: %v", err)
+	}
+	if newest != nil {
+		modified = *newest
+	}
+	return modified, fmt.Sprintf("%d.%d.%d.%d", rows, unliked, genres, named), nil
+}
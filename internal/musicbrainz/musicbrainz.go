@@ -0,0 +1,229 @@
+// Package musicbrainz is a small client for the MusicBrainz web service:
+// looking recordings up by ISRC and reading a release's labels. Requests are
+// spaced a second apart, as MusicBrainz asks of every client.
+package musicbrainz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const apiURL = "https://musicbrainz.org/ws/2"
+
+// NoLabelID is the placeholder label MusicBrainz uses for self-released records
+const NoLabelID = "157afde4-4bf5-4039-8ad2-5a15acc85176"
+
+// ErrNotFound means MusicBrainz has no entity for the ISRC or id
+var ErrNotFound = errors.New("musicbrainz: not found")
+
+// Error is a failed request other than a 404
+type Error struct {
+	Status  int // HTTP status, 0 when the request never got an answer
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Status == 0 {
+		return "musicbrainz: " + e.Message
+	}
+	return fmt.Sprintf("musicbrainz: HTTP %d: %s", e.Status, e.Message)
+}
+
+// Temporary reports whether the same request may succeed later; MusicBrainz
+// answers 503 when a client goes over its rate limit
+func (e *Error) Temporary() bool {
+	return e.Status == 0 || e.Status >= 500
+}
+
+// Client calls MusicBrainz. UserAgent must identify the application and a
+// contact, or MusicBrainz may block it.
+type Client struct {
+	UserAgent string
+	HTTP      *http.Client
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// New returns a client identifying itself as userAgent
+func New(userAgent string) *Client {
+	return &Client{UserAgent: userAgent, HTTP: &http.Client{Timeout: 20 * time.Second}}
+}
+
+// Artist is one credited artist of a recording
+type Artist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Release is a release a recording appears on
+type Release struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Date   string `json:"date"` // YYYY, YYYY-MM or YYYY-MM-DD; may be empty
+}
+
+// Recording is a recording found for an ISRC
+type Recording struct {
+	ID               string
+	Title            string
+	FirstReleaseDate string
+	Artists          []Artist
+	Releases         []Release
+}
+
+// Label is a label a release came out on
+type Label struct {
+	ID   string
+	Name string
+}
+
+// Year returns the year of a MusicBrainz date, or 0 when it has none
+func Year(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	y, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return y
+}
+
+// FirstRelease returns the earliest dated official release of the recording,
+// falling back to the earliest dated release of any status
+func (r Recording) FirstRelease() (Release, bool) {
+	dated := make([]Release, 0, len(r.Releases))
+	for _, rel := range r.Releases {
+		if rel.Date != "" {
+			dated = append(dated, rel)
+		}
+	}
+	if len(dated) == 0 {
+		return Release{}, false
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		oi, oj := dated[i].Status == "Official", dated[j].Status == "Official"
+		if oi != oj {
+			return oi
+		}
+		return dated[i].Date < dated[j].Date
+	})
+	return dated[0], true
+}
+
+// LookupISRC returns the recordings MusicBrainz has for an ISRC, earliest
+// released first. It returns ErrNotFound for an unknown ISRC.
+func (c *Client) LookupISRC(isrc string) ([]Recording, error) {
+	var resp struct {
+		Recordings []struct {
+			ID               string `json:"id"`
+			Title            string `json:"title"`
+			FirstReleaseDate string `json:"first-release-date"`
+			ArtistCredit     []struct {
+				Artist Artist `json:"artist"`
+			} `json:"artist-credit"`
+			Releases []Release `json:"releases"`
+		} `json:"recordings"`
+	}
+	path := "/isrc/" + url.PathEscape(isrc) + "?inc=artists+releases&fmt=json"
+	if err := c.get(path, &resp); err != nil {
+		return nil, err
+	}
+
+	recordings := make([]Recording, 0, len(resp.Recordings))
+	for _, r := range resp.Recordings {
+		rec := Recording{ID: r.ID, Title: r.Title, FirstReleaseDate: r.FirstReleaseDate, Releases: r.Releases}
+		for _, ac := range r.ArtistCredit {
+			rec.Artists = append(rec.Artists, ac.Artist)
+		}
+		recordings = append(recordings, rec)
+	}
+	// Undated recordings go last
+	sort.SliceStable(recordings, func(i, j int) bool {
+		di, dj := recordings[i].FirstReleaseDate, recordings[j].FirstReleaseDate
+		if (di == "") != (dj == "") {
+			return di != ""
+		}
+		return di < dj
+	})
+	return recordings, nil
+}
+
+// ReleaseLabels returns the labels of a release, without the "[no label]"
+// placeholder
+func (c *Client) ReleaseLabels(releaseID string) ([]Label, error) {
+	var resp struct {
+		LabelInfo []struct {
+			Label *struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"label"`
+		} `json:"label-info"`
+	}
+	if err := c.get("/release/"+url.PathEscape(releaseID)+"?inc=labels&fmt=json", &resp); err != nil {
+		return nil, err
+	}
+
+	var labels []Label
+	for _, li := range resp.LabelInfo {
+		if li.Label != nil && li.Label.ID != NoLabelID {
+			labels = append(labels, Label{ID: li.Label.ID, Name: li.Label.Name})
+		}
+	}
+	return labels, nil
+}
+
+// get waits out the one-request-per-second limit, then fetches path
+func (c *Client) get(path string, v any) error {
+	c.mu.Lock()
+	if wait := time.Second - time.Since(c.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	c.last = time.Now()
+	c.mu.Unlock()
+
+	req, err := http.NewRequest(http.MethodGet, apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return &Error{Message: err.Error()}
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 5<<20))
+	if err != nil {
+		return &Error{Message: err.Error()}
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case res.StatusCode != http.StatusOK:
+		msg := http.StatusText(res.StatusCode)
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			msg = apiErr.Error
+		}
+		return &Error{Status: res.StatusCode, Message: msg}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("musicbrainz: decoding response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package notifications sends alerts and summaries to chat apps and other
+// HTTP endpoints through pluggable senders.
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+)
+
+// Kinds of notification the cron sends
+const (
+	KindTokenRefreshFailing = "token_refresh_failing"
+	KindTokenRefreshOK      = "token_refresh_recovered"
+	KindNoTracks            = "no_tracks_collected"
+	KindTracksResumed       = "tracks_collected_again"
+	KindDailyReport         = "daily_report"
+	KindMilestone           = "milestone"
+	KindCollectionGap       = "collection_gap"
+)
+
+// Notification is one message. Text is what chat apps show; Data is passed
+// through to generic HTTP receivers.
+type Notification struct {
+	Kind string    `json:"kind"`
+	Text string    `json:"text"`
+	Data any       `json:"data,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// Sender delivers notifications to one destination
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// Notifier fans notifications out to every configured sender
+type Notifier struct {
+	senders []Sender
+}
+
+// New builds a notifier; with no senders it drops everything
+func New(senders ...Sender) *Notifier {
+	return &Notifier{senders: senders}
+}
+
+// FromConfig builds a notifier with a sender per configured URL
+func FromConfig(cfg config.NotifyConfig) *Notifier {
+	var senders []Sender
+	if cfg.DiscordURL != "" {
+		senders = append(senders, Discord{URL: cfg.DiscordURL})
+	}
+	if cfg.SlackURL != "" {
+		senders = append(senders, Slack{URL: cfg.SlackURL})
+	}
+	if cfg.HTTPURL != "" {
+		senders = append(senders, HTTP{URL: cfg.HTTPURL})
+	}
+	return New(senders...)
+}
+
+// Enabled reports whether any sender is configured
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.senders) > 0
+}
+
+// Notify sends to every sender, returning the failures joined together.
+// One sender failing doesn't stop the others.
+func (n *Notifier) Notify(kind, text string, data any) error {
+	if !n.Enabled() {
+		return nil
+	}
+	msg := Notification{Kind: kind, Text: text, Data: data, At: time.Now().UTC()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var errs []error
+	for _, s := range n.senders {
+		if err := s.Send(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/* ---------- senders ---------- */
+
+// Discord posts to a Discord incoming webhook
+type Discord struct{ URL string }
+
+func (Discord) Name() string { return "discord" }
+
+func (d Discord) Send(ctx context.Context, n Notification) error {
+	text := n.Text
+	// Discord rejects messages over 2000 characters
+	if r := []rune(text); len(r) > 2000 {
+		text = string(r[:1999]) + "…"
+	}
+	return postJSON(ctx, d.URL, map[string]string{"content": text})
+}
+
+// Slack posts to a Slack incoming webhook
+type Slack struct{ URL string }
+
+func (Slack) Name() string { return "slack" }
+
+func (s Slack) Send(ctx context.Context, n Notification) error {
+	return postJSON(ctx, s.URL, map[string]string{"text": n.Text})
+}
+
+// HTTP posts the whole notification as JSON to any endpoint
+type HTTP struct{ URL string }
+
+func (HTTP) Name() string { return "http" }
+
+func (h HTTP) Send(ctx context.Context, n Notification) error {
+	return postJSON(ctx, h.URL, n)
+}
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+func postJSON(ctx context.Context, url string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("returned %s", res.Status)
+	}
+	return nil
+}
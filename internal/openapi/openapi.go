@@ -0,0 +1,352 @@
+// Package openapi builds an OpenAPI 3.0 document from route descriptions and
+// the Go types handlers respond with, so the spec can't drift from the code.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Param is a path or query parameter
+type Param struct {
+	Name        string
+	In          string // "query" or "path"
+	Type        string // string, integer, number or boolean
+	Description string
+	Required    bool
+}
+
+// Query is an optional query parameter
+func Query(name, typ, description string) Param {
+	return Param{Name: name, In: "query", Type: typ, Description: description}
+}
+
+// Path is a path parameter
+func Path(name, description string) Param {
+	return Param{Name: name, In: "path", Type: "string", Description: description, Required: true}
+}
+
+// Operation describes one route. Response is a value of the type returned on
+// success (nil for an empty body); Produces overrides the JSON content type
+// for streamed responses.
+type Operation struct {
+	Method   string
+	Path     string // gin syntax, e.g. /tracks/:id/stats
+	Summary  string
+	Tag      string
+	Params   []Param
+	Body     any
+	Response any
+	Status   int // success status, default 200
+	Produces string
+	Auth     bool // needs the API key
+}
+
+// Info is the document's title and version
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Schema is the subset of the OpenAPI schema object the generator emits
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Document is a generated OpenAPI document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []map[string]string             `json:"servers,omitempty"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components components                      `json:"components"`
+}
+
+type components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]map[string]any `json:"securitySchemes,omitempty"`
+}
+
+type operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *body                 `json:"requestBody,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type body struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Build generates the document. errorBody is the type every error response
+// uses; serverURL is the base path the operations are served under.
+func Build(info Info, serverURL string, ops []Operation, errorBody any) *Document {
+	g := &generator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Servers: []map[string]string{{"url": serverURL}},
+		Paths:   map[string]map[string]operation{},
+		Components: components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]map[string]any{
+				"apiKey": {"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+	errSchema := g.schemaFor(reflect.TypeOf(errorBody))
+
+	for _, op := range ops {
+		p := ginPathToOpenAPI(op.Path)
+		out := operation{
+			Summary:     op.Summary,
+			OperationID: operationID(op.Method, op.Path),
+			Responses: map[string]response{
+				"default": {Description: "error", Content: jsonContent(errSchema)},
+			},
+		}
+		if op.Tag != "" {
+			out.Tags = []string{op.Tag}
+		}
+		if op.Auth {
+			out.Security = []map[string][]string{{"apiKey": {}}}
+		}
+		for _, p := range op.Params {
+			out.Parameters = append(out.Parameters, parameter{
+				Name:        p.Name,
+				In:          p.In,
+				Description: p.Description,
+				Required:    p.Required,
+				Schema:      &Schema{Type: p.Type},
+			})
+		}
+		if op.Body != nil {
+			out.RequestBody = &body{Required: true, Content: jsonContent(g.schemaFor(reflect.TypeOf(op.Body)))}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := response{Description: http.StatusText(status)}
+		switch {
+		case op.Produces != "":
+			ok.Content = map[string]mediaType{op.Produces: {Schema: &Schema{Type: "string"}}}
+		case op.Response != nil:
+			ok.Content = jsonContent(g.schemaFor(reflect.TypeOf(op.Response)))
+		}
+		out.Responses[strconv.Itoa(status)] = ok
+
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = map[string]operation{}
+		}
+		doc.Paths[p][strings.ToLower(op.Method)] = out
+	}
+	return doc
+}
+
+// JSON encodes the document with indentation
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func jsonContent(s *Schema) map[string]mediaType {
+	return map[string]mediaType{"application/json": {Schema: s}}
+}
+
+// ginPathToOpenAPI turns /tracks/:id into /tracks/{id}
+func ginPathToOpenAPI(p string) string {
+	parts := strings.Split(p, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// operationID derives a stable camelCase id, e.g. GET /stats/most-played ->
+// getStatsMostPlayed
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range path {
+		switch {
+		case r == '/' || r == '-' || r == '_' || r == ':':
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+/* ---------- schemas from Go types ---------- */
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// schemaFor returns an inline schema or a $ref to a named component
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		s = &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	case t.Kind() == reflect.Struct:
+		s = g.structSchema(t)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case t.Kind() == reflect.Map:
+		s = &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case t.Kind() == reflect.Bool:
+		s = &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = &Schema{Type: "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			s.Format = "int64"
+		}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = &Schema{Type: "number"}
+	case t.Kind() == reflect.String:
+		s = &Schema{Type: "string"}
+	default:
+		// interfaces and anything else: any JSON value
+		s = &Schema{}
+	}
+
+	// A $ref can't carry siblings in 3.0, so nullable refs stay plain refs
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+// component registers a named struct under components/schemas
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t] = name
+	g.schemas[name] = &Schema{} // placeholder so recursive types terminate
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t, isRequestBody(t))
+	return s
+}
+
+// isRequestBody reports whether t is bound from a request, i.e. uses gin's
+// binding tags. Request fields are only required when tagged so; response
+// fields are always present unless omitempty.
+func isRequestBody(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("binding"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// addFields adds t's JSON fields to s, flattening embedded structs the way
+// encoding/json does
+func (g *generator) addFields(s *Schema, t reflect.Type, request bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft, request)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schemaFor(ft)
+		if strings.Contains(opts, "string") && fs.Ref == "" {
+			fs = &Schema{Type: "string", Nullable: fs.Nullable}
+		}
+		s.Properties[name] = fs
+		required := !strings.Contains(opts, "omitempty") && ft.Kind() != reflect.Pointer
+		if request {
+			required = strings.Contains(f.Tag.Get("binding"), "required")
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
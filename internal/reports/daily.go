@@ -0,0 +1,227 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DailyReport summarizes one day of listening
+type DailyReport struct {
+	Date           string        `json:"date"`
+	TracksPlayed   int           `json:"tracks_played"`
+	UniqueTracks   int           `json:"unique_tracks"`
+	TotalMs        int64         `json:"total_ms"`
+	Minutes        int64         `json:"minutes"`
+	TopArtist      *RankedName   `json:"top_artist"`
+	TopGenre       *RankedName   `json:"top_genre"`
+	TopTracks      []ReportTrack `json:"top_tracks"`
+	NewDiscoveries int           `json:"new_discoveries"`
+	Discoveries    []ReportTrack `json:"discoveries"`
+	GeneratedAt    time.Time     `json:"generated_at"`
+}
+
+// RankedName is an artist or genre with its play count for the day
+type RankedName struct {
+	Name  string `json:"name"`
+	Plays int    `json:"plays"`
+}
+
+// ReportTrack is a track listed in a report
+type ReportTrack struct {
+	SpotifySongID string `json:"spotify_song_id"`
+	TrackName     string `json:"track_name"`
+	ArtistName    string `json:"artist_name"`
+	Plays         int    `json:"plays"`
+}
+
+// GenerateDaily computes the report for the given calendar day, in the
+// configured timezone, from recently_played
+func GenerateDaily(day time.Time) (*DailyReport, error) {
+	ctx := context.Background()
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, config.Timezone())
+	end := start.AddDate(0, 0, 1)
+
+	report := &DailyReport{
+		Date:        start.Format("2006-01-02"),
+		TopTracks:   []ReportTrack{},
+		Discoveries: []ReportTrack{},
+		GeneratedAt: time.Now(),
+	}
+
+	err := repository.Pool.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT spotify_song_id), COALESCE(SUM(duration_ms), 0)
+		FROM recently_played
+		WHERE played_at >= $1 AND played_at < $2`, start, end).
+		Scan(&report.TracksPlayed, &report.UniqueTracks, &report.TotalMs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count plays for %s: %v", report.Date, err)
+	}
+	report.Minutes = report.TotalMs / 60000
+
+	if report.TracksPlayed == 0 {
+		return report, nil
+	}
+
+	var artist RankedName
+	err = repository.Pool.QueryRow(ctx, `
+		SELECT artist_name, COUNT(*) AS plays
+		FROM recently_played
+		WHERE played_at >= $1 AND played_at < $2
+		  AND artist_name IS NOT NULL AND artist_name <> ''
+		GROUP BY artist_name
+		ORDER BY plays DESC, artist_name
+		LIMIT 1`, start, end).Scan(&artist.Name, &artist.Plays)
+	if err == nil {
+		report.TopArtist = &artist
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get top artist for %s: %v", report.Date, err)
+	}
+
+	var genre RankedName
+	err = repository.Pool.QueryRow(ctx, `
+		SELECT g.name, COUNT(*) AS plays
+		FROM recently_played rp
+		JOIN track_genres tg ON tg.spotify_song_id = rp.spotify_song_id
+		JOIN genres g ON g.id = tg.genre_id
+		WHERE rp.played_at >= $1 AND rp.played_at < $2
+		GROUP BY g.name
+		ORDER BY plays DESC, g.name
+		LIMIT 1`, start, end).Scan(&genre.Name, &genre.Plays)
+	if err == nil {
+		report.TopGenre = &genre
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get top genre for %s: %v", report.Date, err)
+	}
+
+	report.TopTracks, err = queryReportTracks(ctx, `
+		SELECT spotify_song_id, MAX(track_name), COALESCE(MAX(artist_name), ''), COUNT(*) AS plays
+		FROM recently_played
+		WHERE played_at >= $1 AND played_at < $2
+		GROUP BY spotify_song_id
+		ORDER BY plays DESC, MAX(track_name)
+		LIMIT 5`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top tracks for %s: %v", report.Date, err)
+	}
+
+	// A discovery is a track whose first-ever play falls on this day
+	report.Discoveries, err = queryReportTracks(ctx, `
+		SELECT rp.spotify_song_id, MAX(rp.track_name), COALESCE(MAX(rp.artist_name), ''), COUNT(*) AS plays
+		FROM recently_played rp
+		WHERE rp.played_at >= $1 AND rp.played_at < $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM recently_played earlier
+			WHERE earlier.spotify_song_id = rp.spotify_song_id
+			  AND earlier.played_at < $1
+		  )
+		GROUP BY rp.spotify_song_id
+		ORDER BY plays DESC, MAX(rp.track_name)`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discoveries for %s: %v", report.Date, err)
+	}
+	report.NewDiscoveries = len(report.Discoveries)
+	if len(report.Discoveries) > 10 {
+		report.Discoveries = report.Discoveries[:10]
+	}
+
+	return report, nil
+}
+
+func queryReportTracks(ctx context.Context, query string, args ...any) ([]ReportTrack, error) {
+	rows, err := repository.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []ReportTrack{}
+	for rows.Next() {
+		var t ReportTrack
+		if err := rows.Scan(&t.SpotifySongID, &t.TrackName, &t.ArtistName, &t.Plays); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, t)
+	}
+	return tracks, rows.Err()
+}
+
+// SaveDaily upserts the report into listening_reports
+func SaveDaily(report *DailyReport) error {
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	var topArtist, topGenre *string
+	if report.TopArtist != nil {
+		topArtist = &report.TopArtist.Name
+	}
+	if report.TopGenre != nil {
+		topGenre = &report.TopGenre.Name
+	}
+
+	_, err = repository.Pool.Exec(context.Background(), `
+		INSERT INTO listening_reports
+		      (report_date, tracks_played, unique_tracks, total_ms, top_artist, top_genre, new_discoveries, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (report_date) DO UPDATE
+		  SET tracks_played   = EXCLUDED.tracks_played,
+		      unique_tracks   = EXCLUDED.unique_tracks,
+		      total_ms        = EXCLUDED.total_ms,
+		      top_artist      = EXCLUDED.top_artist,
+		      top_genre       = EXCLUDED.top_genre,
+		      new_discoveries = EXCLUDED.new_discoveries,
+		      payload         = EXCLUDED.payload`,
+		report.Date, report.TracksPlayed, report.UniqueTracks, report.TotalMs,
+		topArtist, topGenre, report.NewDiscoveries, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to save report for %s: %v", report.Date, err)
+	}
+	return nil
+}
+
+// GetDaily loads a stored report, returning nil if none exists for the day
+func GetDaily(day time.Time) (*DailyReport, error) {
+	var payload []byte
+	err := repository.Pool.QueryRow(context.Background(),
+		`SELECT payload FROM listening_reports WHERE report_date = $1`,
+		day.Format("2006-01-02")).Scan(&payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load report: %v", err)
+	}
+
+	var report DailyReport
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// MarkDelivered records that the report was pushed to the webhook
+func MarkDelivered(date string) error {
+	_, err := repository.Pool.Exec(context.Background(),
+		`UPDATE listening_reports SET delivered_at = NOW() WHERE report_date = $1`, date)
+	return err
+}
+
+// IsDelivered reports whether the day's report has already been pushed
+func IsDelivered(day time.Time) (bool, error) {
+	var delivered bool
+	err := repository.Pool.QueryRow(context.Background(), `
+		SELECT EXISTS (
+			SELECT 1 FROM listening_reports
+			WHERE report_date = $1 AND delivered_at IS NOT NULL
+		)`, day.Format("2006-01-02")).Scan(&delivered)
+	return delivered, err
+}
@@ -0,0 +1,60 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookURL returns the configured REPORT_WEBHOOK_URL, or "" if reports aren't pushed
+func WebhookURL() string {
+	return os.Getenv("REPORT_WEBHOOK_URL")
+}
+
+// Summary renders the report as a short human-readable message
+func (r *DailyReport) Summary() string {
+	if r.TracksPlayed == 0 {
+		return fmt.Sprintf("🎧 %s: nothing played", r.Date)
+	}
+	msg := fmt.Sprintf("🎧 %s: %d plays (%d unique), %d minutes", r.Date, r.TracksPlayed, r.UniqueTracks, r.Minutes)
+	if r.TopArtist != nil {
+		msg += fmt.Sprintf("\nTop artist: %s (%d plays)", r.TopArtist.Name, r.TopArtist.Plays)
+	}
+	if r.TopGenre != nil {
+		msg += fmt.Sprintf("\nTop genre: %s", r.TopGenre.Name)
+	}
+	if r.NewDiscoveries > 0 {
+		msg += fmt.Sprintf("\nNew discoveries: %d", r.NewDiscoveries)
+	}
+	return msg
+}
+
+// Push POSTs the report to the webhook. The body carries both "content" (Discord)
+// and "text" (Slack) so either works without extra configuration.
+func Push(webhookURL string, report *DailyReport) error {
+	summary := report.Summary()
+	body, err := json.Marshal(map[string]any{
+		"content": summary,
+		"text":    summary,
+		"report":  report,
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := webhookClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("report webhook failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("report webhook returned %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/config"
+	"example.com/spotifydb/internal/repository"
+)
+
+// Wrapped is a shareable summary of one week, month or year of listening
+type Wrapped struct {
+	Period        string         `json:"period"`
+	From          string         `json:"from"`
+	To            string         `json:"to"` // inclusive
+	TotalPlays    int            `json:"total_plays"`
+	UniqueTracks  int            `json:"unique_tracks"`
+	UniqueArtists int            `json:"unique_artists"`
+	TotalMinutes  int64          `json:"total_minutes"`
+	DaysListened  int            `json:"days_listened"`
+	TopTracks     []WrappedTrack `json:"top_tracks"`
+	TopArtists    []WrappedRank  `json:"top_artists"`
+	TopGenres     []WrappedRank  `json:"top_genres"`
+	Streak        *Streak        `json:"listening_streak"`
+	TrackStreak   *TrackStreak   `json:"track_streak"`
+	MostActiveDay *ActiveDay     `json:"most_active_day"`
+	BusiestDay    string         `json:"busiest_weekday"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+}
+
+// WrappedTrack is one of the period's top tracks
+type WrappedTrack struct {
+	Rank          int    `json:"rank"`
+	SpotifySongID string `json:"spotify_song_id"`
+	TrackName     string `json:"track_name"`
+	ArtistName    string `json:"artist_name"`
+	AlbumCoverURL string `json:"album_cover_url"`
+	Plays         int    `json:"plays"`
+	Minutes       int64  `json:"minutes"`
+}
+
+// WrappedRank is a top artist or genre
+type WrappedRank struct {
+	Rank    int    `json:"rank"`
+	Name    string `json:"name"`
+	Plays   int    `json:"plays"`
+	Minutes int64  `json:"minutes"`
+}
+
+// Streak is a run of consecutive days with at least one play
+type Streak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// TrackStreak is the longest run of consecutive days a single track was played
+type TrackStreak struct {
+	Streak
+	SpotifySongID string `json:"spotify_song_id"`
+	TrackName     string `json:"track_name"`
+	ArtistName    string `json:"artist_name"`
+}
+
+// ActiveDay is the day with the most listening
+type ActiveDay struct {
+	Date    string `json:"date"`
+	Plays   int    `json:"plays"`
+	Minutes int64  `json:"minutes"`
+}
+
+// ParseWrappedPeriod turns "2024", "2024-11" or "2024-W45" into a half-open
+// window [from, to) in the configured timezone
+func ParseWrappedPeriod(period string) (from, to time.Time, err error) {
+	loc := config.Timezone()
+	switch {
+	case len(period) == 4:
+		year, err := strconv.Atoi(period)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid year %q", period)
+		}
+		from = time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+		return from, from.AddDate(1, 0, 0), nil
+
+	case strings.Contains(period, "-W"):
+		yearStr, weekStr, _ := strings.Cut(period, "-W")
+		year, yerr := strconv.Atoi(yearStr)
+		week, werr := strconv.Atoi(weekStr)
+		if yerr != nil || werr != nil || week < 1 || week > 53 {
+			return from, to, fmt.Errorf("invalid ISO week %q (expected YYYY-Www)", period)
+		}
+		// ISO week 1 contains January 4th; weeks start on Monday
+		jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, loc)
+		offset := (int(jan4.Weekday()) + 6) % 7
+		from = jan4.AddDate(0, 0, -offset+(week-1)*7)
+		return from, from.AddDate(0, 0, 7), nil
+
+	default:
+		start, err := time.ParseInLocation("2006-01", period, loc)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid period %q (expected YYYY, YYYY-MM or YYYY-Www)", period)
+		}
+		return start, start.AddDate(0, 1, 0), nil
+	}
+}
+
+// GenerateWrapped computes the summary for [from, to) from recently_played
+func GenerateWrapped(period string, from, to time.Time) (*Wrapped, error) {
+	ctx := context.Background()
+	w := &Wrapped{
+		Period:      period,
+		From:        from.Format("2006-01-02"),
+		To:          to.AddDate(0, 0, -1).Format("2006-01-02"),
+		TopTracks:   []WrappedTrack{},
+		TopArtists:  []WrappedRank{},
+		TopGenres:   []WrappedRank{},
+		GeneratedAt: time.Now(),
+	}
+
+	var totalMs int64
+	err := repository.Pool.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT spotify_song_id), COUNT(DISTINCT artist_name),
+		       COALESCE(SUM(duration_ms), 0)
+		FROM recently_played
+		WHERE played_at >= $1 AND played_at < $2`, from, to).
+		Scan(&w.TotalPlays, &w.UniqueTracks, &w.UniqueArtists, &totalMs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wrapped totals: %v", err)
+	}
+	w.TotalMinutes = totalMs / 60000
+	if w.TotalPlays == 0 {
+		return w, nil
+	}
+
+	rows, err := repository.Pool.Query(ctx, `
+		SELECT spotify_song_id, MAX(track_name), COALESCE(MAX(artist_name), ''),
+		       COALESCE(MAX(album_cover_url), ''), COUNT(*) AS plays, COALESCE(SUM(duration_ms), 0)
+		FROM recently_played
+		WHERE played_at >= $1 AND played_at < $2
+		GROUP BY spotify_song_id
+		ORDER BY plays DESC, MAX(played_at) DESC
+		LIMIT 10`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wrapped top tracks: %v", err)
+	}
+	for rows.Next() {
+		var t WrappedTrack
+		var ms int64
+		if err := rows.Scan(&t.SpotifySongID, &t.TrackName, &t.ArtistName, &t.AlbumCoverURL, &t.Plays, &ms); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.Rank = len(w.TopTracks) + 1
+		t.Minutes = ms / 60000
+		w.TopTracks = append(w.TopTracks, t)
+	}
+	rows.Close()
+
+	if w.TopArtists, err = queryWrappedRanks(ctx, `
+		SELECT artist_name, COUNT(*) AS plays, COALESCE(SUM(duration_ms), 0)
+		FROM recently_played
+		WHERE played_at >= $1 AND played_at < $2
+		  AND artist_name IS NOT NULL AND artist_name <> ''
+		GROUP BY artist_name
+		ORDER BY plays DESC, artist_name
+		LIMIT 10`, from, to); err != nil {
+		return nil, fmt.Errorf("failed to get wrapped top artists: %v", err)
+	}
+
+	if w.TopGenres, err = queryWrappedRanks(ctx, `
+		SELECT g.name, COUNT(*) AS plays, COALESCE(SUM(rp.duration_ms), 0)
+		FROM recently_played rp
+		JOIN track_genres tg ON tg.spotify_song_id = rp.spotify_song_id
+		JOIN genres g ON g.id = tg.genre_id
+		WHERE rp.played_at >= $1 AND rp.played_at < $2
+		GROUP BY g.name
+		ORDER BY plays DESC, g.name
+		LIMIT 10`, from, to); err != nil {
+		return nil, fmt.Errorf("failed to get wrapped top genres: %v", err)
+	}
+
+	if err := fillDailyStats(ctx, w, from, to); err != nil {
+		return nil, err
+	}
+
+	var ts TrackStreak
+	var start, end time.Time
+	err = repository.Pool.QueryRow(ctx, `
+		WITH days AS (
+			SELECT DISTINCT spotify_song_id, (played_at AT TIME ZONE $3)::date AS d
+			FROM recently_played
+			WHERE played_at >= $1 AND played_at < $2
+		), islands AS (
+			SELECT spotify_song_id, d,
+			       d - (ROW_NUMBER() OVER (PARTITION BY spotify_song_id ORDER BY d))::int AS grp
+			FROM days
+		)
+		SELECT i.spotify_song_id, COUNT(*) AS len, MIN(i.d), MAX(i.d),
+		       (SELECT track_name FROM recently_played WHERE spotify_song_id = i.spotify_song_id LIMIT 1),
+		       (SELECT COALESCE(artist_name, '') FROM recently_played WHERE spotify_song_id = i.spotify_song_id LIMIT 1)
+		FROM islands i
+		GROUP BY i.spotify_song_id, i.grp
+		ORDER BY len DESC, MAX(i.d) DESC
+		LIMIT 1`, from, to, config.Timezone().String()).Scan(&ts.SpotifySongID, &ts.Days, &start, &end, &ts.TrackName, &ts.ArtistName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wrapped track streak: %v", err)
+	}
+	ts.Start, ts.End = start.Format("2006-01-02"), end.Format("2006-01-02")
+	w.TrackStreak = &ts
+
+	return w, nil
+}
+
+func queryWrappedRanks(ctx context.Context, query string, args ...any) ([]WrappedRank, error) {
+	rows, err := repository.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranks := []WrappedRank{}
+	for rows.Next() {
+		var r WrappedRank
+		var ms int64
+		if err := rows.Scan(&r.Name, &r.Plays, &ms); err != nil {
+			return nil, err
+		}
+		r.Rank = len(ranks) + 1
+		r.Minutes = ms / 60000
+		ranks = append(ranks, r)
+	}
+	return ranks, rows.Err()
+}
+
+// fillDailyStats derives the listening streak, most active day and busiest
+// weekday from per-day totals
+func fillDailyStats(ctx context.Context, w *Wrapped, from, to time.Time) error {
+	rows, err := repository.Pool.Query(ctx, `
+		SELECT (played_at AT TIME ZONE $3)::date AS d, COUNT(*), COALESCE(SUM(duration_ms), 0)
+		FROM recently_played
+		WHERE played_at >= $1 AND played_at < $2
+		GROUP BY d
+		ORDER BY d`, from, to, config.Timezone().String())
+	if err != nil {
+		return fmt.Errorf("failed to get wrapped daily totals: %v", err)
+	}
+	defer rows.Close()
+
+	var weekdayMs [7]int64
+	var bestDayMs int64
+	var best, current Streak
+	var prev time.Time
+	for rows.Next() {
+		var day time.Time
+		var plays int
+		var ms int64
+		if err := rows.Scan(&day, &plays, &ms); err != nil {
+			return err
+		}
+		w.DaysListened++
+		weekdayMs[day.Weekday()] += ms
+
+		if ms > bestDayMs {
+			bestDayMs = ms
+			w.MostActiveDay = &ActiveDay{Date: day.Format("2006-01-02"), Plays: plays, Minutes: ms / 60000}
+		}
+
+		if !prev.IsZero() && day.Sub(prev) == 24*time.Hour {
+			current.Days++
+			current.End = day.Format("2006-01-02")
+		} else {
+			current = Streak{Days: 1, Start: day.Format("2006-01-02"), End: day.Format("2006-01-02")}
+		}
+		if current.Days > best.Days {
+			best = current
+		}
+		prev = day
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if best.Days > 0 {
+		w.Streak = &best
+	}
+	busiest := time.Sunday
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if weekdayMs[d] > weekdayMs[busiest] {
+			busiest = d
+		}
+	}
+	w.BusiestDay = busiest.String()
+	return nil
+}
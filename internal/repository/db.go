@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"example.com/spotifydb/internal/config"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
 )
 
 var Pool *pgxpool.Pool
@@ -18,13 +20,21 @@ func InitDB() {
 
 	fmt.Println("🔌  Connecting to  database…")
 
-	// Load .env for DATABASE_URL (optional - may not exist in production)
-	if err := godotenv.Load(); err != nil {
-		log.Println("Note: .env file not found (this is normal in production environments)")
+	dsn := os.Getenv("DATABASE_URL")
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		log.Fatalf("Invalid DATABASE_URL: %v\n", err)
 	}
+	// Record query latency for /metrics
+	poolConfig.ConnConfig.Tracer = queryTracer{}
 
-	dsn := os.Getenv("DATABASE_URL")
-	pool, err := pgxpool.New(context.Background(), dsn)
+	dbConfig, err := config.LoadDBConfig()
+	if err != nil {
+		log.Fatalf("Invalid pool configuration: %v\n", err)
+	}
+	applyPoolConfig(poolConfig, dbConfig)
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		log.Fatalf("Unable to connect to database: %v\n", err)
 	}
@@ -38,6 +48,8 @@ func InitDB() {
 		log.Fatalf("Failed to check DB and user: %v", err)
 	}
 	fmt.Printf("🧠 Connected to DB: %s as user: %s\n", currentDB, currentUser)
+	fmt.Printf("🏊 Pool: %d-%d conns, lifetime %v, simple protocol %v\n",
+		dbConfig.MinConns, dbConfig.MaxConns, dbConfig.MaxConnLifetime, dbConfig.SimpleProtocol)
 
 	var greeting string
 	if err := pool.QueryRow(context.Background(), "SELECT 'Connected to Neon DB!'").Scan(&greeting); err != nil {
@@ -53,6 +65,20 @@ func InitDB() {
 	}
 }
 
+// applyPoolConfig copies the DB_* settings onto the parsed DATABASE_URL
+func applyPoolConfig(poolConfig *pgxpool.Config, cfg config.DBConfig) {
+	poolConfig.MaxConns = int32(cfg.MaxConns)
+	poolConfig.MinConns = int32(cfg.MinConns)
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	if cfg.SimpleProtocol {
+		// pgbouncer in transaction mode hands each statement to whichever
+		// server connection is free, so prepared statements go missing
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	}
+}
+
 // ensureTablesExist creates all required tables if they don't exist
 func ensureTablesExist() error {
 	ctx := context.Background()
@@ -77,7 +103,7 @@ func ensureTablesExist() error {
 		album_cover_width INTEGER,
 		album_cover_height INTEGER,
 		genre TEXT,
-		added_at TIMESTAMP NOT NULL,
+		added_at TIMESTAMPTZ NOT NULL,
 		created_at TIMESTAMP DEFAULT NOW()
 	);`
 
@@ -98,6 +124,18 @@ func ensureTablesExist() error {
 		return fmt.Errorf("failed to create spotify_auth table: %v", err)
 	}
 
+	// Migration: the connected account's profile and granted scopes
+	if _, err := Pool.Exec(ctx, `
+		ALTER TABLE spotify_auth
+			ADD COLUMN IF NOT EXISTS user_id TEXT,
+			ADD COLUMN IF NOT EXISTS display_name TEXT,
+			ADD COLUMN IF NOT EXISTS country TEXT,
+			ADD COLUMN IF NOT EXISTS product TEXT,
+			ADD COLUMN IF NOT EXISTS scopes TEXT[],
+			ADD COLUMN IF NOT EXISTS profile_updated_at TIMESTAMPTZ`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add spotify_auth profile columns: %v\n", err)
+	}
+
 	// Create recently_played table if it doesn't exist
 	recentlyPlayedTable := `
 	CREATE TABLE IF NOT EXISTS recently_played (
@@ -109,7 +147,7 @@ func ensureTablesExist() error {
 		album_cover_url TEXT,
 		genre TEXT,
 		duration_ms INTEGER DEFAULT 0,
-		played_at TIMESTAMP NOT NULL,
+		played_at TIMESTAMPTZ NOT NULL,
 		source VARCHAR(50) DEFAULT 'cron',
 		created_at TIMESTAMP DEFAULT NOW(),
 		UNIQUE(spotify_song_id, played_at)
@@ -124,11 +162,663 @@ func ensureTablesExist() error {
 		fmt.Printf("⚠️  Warning: Failed to add duration_ms column: %v\n", err)
 	}
 
+	// Migration: inferred listen completion (see models.InferCompletion)
+	if _, err := Pool.Exec(ctx, `
+		ALTER TABLE recently_played
+			ADD COLUMN IF NOT EXISTS completion_ratio REAL,
+			ADD COLUMN IF NOT EXISTS skipped BOOLEAN`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add completion columns: %v\n", err)
+	}
+
+	// Migration: Last.fm scrobble queue (see handlers.ScrobblePending). NULL
+	// means the play was never queued; pending, sent, failed or ignored otherwise
+	if _, err := Pool.Exec(ctx, `
+		ALTER TABLE recently_played
+			ADD COLUMN IF NOT EXISTS scrobble_status VARCHAR(10),
+			ADD COLUMN IF NOT EXISTS scrobble_attempts SMALLINT NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS scrobble_error TEXT`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add scrobble columns: %v\n", err)
+	}
+
+	// Migration: ListenBrainz submissions, tracked like the Last.fm queue plus
+	// the recording MSID ListenBrainz assigned each listen
+	if _, err := Pool.Exec(ctx, `
+		ALTER TABLE recently_played
+			ADD COLUMN IF NOT EXISTS listenbrainz_status VARCHAR(10),
+			ADD COLUMN IF NOT EXISTS listenbrainz_attempts SMALLINT NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS listenbrainz_error TEXT,
+			ADD COLUMN IF NOT EXISTS listenbrainz_msid VARCHAR(36)`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add listenbrainz columns: %v\n", err)
+	}
+
+	// tracks_on_repeat predates recently_played; its counters are now
+	// recomputed from plays (see models.SyncTracksOnRepeat)
+	tracksOnRepeatTable := `
+	CREATE TABLE IF NOT EXISTS tracks_on_repeat (
+		id SERIAL PRIMARY KEY,
+		spotify_song_id VARCHAR(255) UNIQUE NOT NULL,
+		track_name TEXT NOT NULL,
+		artist_name TEXT,
+		album_name TEXT,
+		genre TEXT,
+		preview_url TEXT,
+		album_cover_url TEXT,
+		play_count INTEGER DEFAULT 1,
+		first_played TIMESTAMP,
+		last_played TIMESTAMP,
+		month_year VARCHAR(10),
+		time_of_day VARCHAR(20),
+		mood VARCHAR(50),
+		activity VARCHAR(50),
+		created_at TIMESTAMP DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, tracksOnRepeatTable); err != nil {
+		return fmt.Errorf("failed to create tracks_on_repeat table: %v", err)
+	}
+
+	// Create artists table: cached artist metadata so genres aren't re-fetched per play
+	artistsTable := `
+	CREATE TABLE IF NOT EXISTS artists (
+		artist_id VARCHAR(255) PRIMARY KEY,
+		name TEXT NOT NULL,
+		genres TEXT[] NOT NULL DEFAULT '{}',
+		image_url TEXT,
+		images JSONB,
+		last_refreshed TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_at TIMESTAMP DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, artistsTable); err != nil {
+		return fmt.Errorf("failed to create artists table: %v", err)
+	}
+
+	// Create genre taxonomy: one row per genre plus a track <-> genre join table
+	genreTables := []string{
+		`CREATE TABLE IF NOT EXISTS genres (
+			id SERIAL PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS track_genres (
+			spotify_song_id VARCHAR(255) NOT NULL,
+			genre_id INTEGER NOT NULL REFERENCES genres(id) ON DELETE CASCADE,
+			PRIMARY KEY (spotify_song_id, genre_id)
+		);`,
+	}
+	for _, tableSQL := range genreTables {
+		if _, err := Pool.Exec(ctx, tableSQL); err != nil {
+			return fmt.Errorf("failed to create genre tables: %v", err)
+		}
+	}
+
+	// Migration: split existing comma-joined genre columns into the join table (first run only)
+	existingGenres := `
+		SELECT DISTINCT spotify_song_id, LOWER(TRIM(g)) AS name
+		FROM (
+			SELECT spotify_song_id, genre FROM recently_liked
+			UNION ALL
+			SELECT spotify_song_id, genre FROM recently_played
+		) t, UNNEST(STRING_TO_ARRAY(t.genre, ',')) AS g
+		WHERE t.genre IS NOT NULL
+		  AND t.genre NOT IN ('', 'unknown', 'no genre', 'rate-limited')
+		  AND TRIM(g) <> ''
+		  AND NOT EXISTS (SELECT 1 FROM track_genres)`
+	genreBackfill := []string{
+		`INSERT INTO genres (name)
+		SELECT DISTINCT name FROM (` + existingGenres + `) e
+		ON CONFLICT (name) DO NOTHING`,
+		`INSERT INTO track_genres (spotify_song_id, genre_id)
+		SELECT e.spotify_song_id, g.id
+		FROM (` + existingGenres + `) e
+		JOIN genres g ON g.name = e.name
+		ON CONFLICT DO NOTHING`,
+	}
+	for _, backfillSQL := range genreBackfill {
+		if _, err := Pool.Exec(ctx, backfillSQL); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to backfill track_genres: %v\n", err)
+			break
+		}
+	}
+
+	// Create listening_reports table: one generated summary per day
+	reportsTable := `
+	CREATE TABLE IF NOT EXISTS listening_reports (
+		report_date DATE PRIMARY KEY,
+		tracks_played INTEGER NOT NULL DEFAULT 0,
+		unique_tracks INTEGER NOT NULL DEFAULT 0,
+		total_ms BIGINT NOT NULL DEFAULT 0,
+		top_artist TEXT,
+		top_genre TEXT,
+		new_discoveries INTEGER NOT NULL DEFAULT 0,
+		payload JSONB NOT NULL,
+		delivered_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, reportsTable); err != nil {
+		return fmt.Errorf("failed to create listening_reports table: %v", err)
+	}
+
+	// Create now_playing_log table: one player snapshot per cron tick, so plays
+	// too short to reach recently-played can still be reconstructed
+	nowPlayingLogTable := `
+	CREATE TABLE IF NOT EXISTS now_playing_log (
+		id BIGSERIAL PRIMARY KEY,
+		spotify_song_id VARCHAR(255) NOT NULL,
+		track_name TEXT,
+		artist_name TEXT,
+		progress_ms INTEGER NOT NULL DEFAULT 0,
+		duration_ms INTEGER,
+		is_playing BOOLEAN NOT NULL,
+		device_name TEXT,
+		device_type VARCHAR(50),
+		captured_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, nowPlayingLogTable); err != nil {
+		return fmt.Errorf("failed to create now_playing_log table: %v", err)
+	}
+
+	// Migration: playback context and player state, on snapshots and on plays
+	if _, err := Pool.Exec(ctx, `
+		ALTER TABLE now_playing_log
+			ADD COLUMN IF NOT EXISTS shuffle_state BOOLEAN,
+			ADD COLUMN IF NOT EXISTS repeat_state VARCHAR(20),
+			ADD COLUMN IF NOT EXISTS context_type VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS context_uri TEXT`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add context columns to now_playing_log: %v\n", err)
+	}
+	if _, err := Pool.Exec(ctx, `
+		ALTER TABLE recently_played
+			ADD COLUMN IF NOT EXISTS context_type VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS context_uri TEXT,
+			ADD COLUMN IF NOT EXISTS device_name TEXT,
+			ADD COLUMN IF NOT EXISTS device_type VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS shuffle_state BOOLEAN,
+			ADD COLUMN IF NOT EXISTS repeat_state VARCHAR(20)`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add context columns to recently_played: %v\n", err)
+	}
+
+	// Migration: catalogue metadata (ISRC etc.) captured at collection time
+	for _, table := range []string{"recently_played", "recently_liked"} {
+		if _, err := Pool.Exec(ctx, fmt.Sprintf(`
+			ALTER TABLE %s
+				ADD COLUMN IF NOT EXISTS isrc VARCHAR(20),
+				ADD COLUMN IF NOT EXISTS explicit BOOLEAN,
+				ADD COLUMN IF NOT EXISTS disc_number INTEGER,
+				ADD COLUMN IF NOT EXISTS track_number INTEGER,
+				ADD COLUMN IF NOT EXISTS available_markets TEXT[]`, table)); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to add metadata columns to %s: %v\n", table, err)
+		}
+	}
+
+	// Migration: where a genre came from (models.GenreSource*), as the genre
+	// backfills fall back to the album artist or related artists. Genres set
+	// before this all came from the track's artist.
+	for _, table := range []string{"recently_played", "recently_liked"} {
+		if _, err := Pool.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS genre_source VARCHAR(20)`, table)); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to add genre_source to %s: %v\n", table, err)
+			continue
+		}
+		if _, err := Pool.Exec(ctx, fmt.Sprintf(`
+			UPDATE %s SET genre_source = 'artist'
+			WHERE genre_source IS NULL AND genre <> '' AND genre NOT IN ('unknown', 'no genre', 'rate-limited')`, table)); err != nil {
+			fmt.Printf("⚠️  Warning: Failed to set genre_source on %s: %v\n", table, err)
+		}
+	}
+
+	// Migration: album release dates on plays, for era stats; recently_liked already has them
+	if _, err := Pool.Exec(ctx, `
+		ALTER TABLE recently_played
+			ADD COLUMN IF NOT EXISTS album_release_date VARCHAR(20),
+			ADD COLUMN IF NOT EXISTS album_release_date_precision VARCHAR(10)`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add album release date to recently_played: %v\n", err)
+	}
+
+	// Migration: saved tracks removed on Spotify are soft-deleted by the reconciliation
+	if _, err := Pool.Exec(ctx, `ALTER TABLE recently_liked ADD COLUMN IF NOT EXISTS unliked_at TIMESTAMPTZ`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add unliked_at to recently_liked: %v\n", err)
+	}
+
+	// Migration: saved tracks record what stored them, like recently_played.source;
+	// rows saved before this can only be attributed to the collector
+	if _, err := Pool.Exec(ctx, `ALTER TABLE recently_liked ADD COLUMN IF NOT EXISTS source VARCHAR(50) DEFAULT 'cron'`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add source to recently_liked: %v\n", err)
+	}
+
+	// Create canonical_tracks: maps every spotify_song_id to one canonical id per
+	// recording (matched on ISRC) so singles, album cuts and remasters share counts
+	canonicalTracksTable := `
+	CREATE TABLE IF NOT EXISTS canonical_tracks (
+		spotify_song_id VARCHAR(255) PRIMARY KEY,
+		isrc VARCHAR(20),
+		canonical_id VARCHAR(255) NOT NULL,
+		resolved_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, canonicalTracksTable); err != nil {
+		return fmt.Errorf("failed to create canonical_tracks table: %v", err)
+	}
+
+	// Create external_metadata: what MusicBrainz knows about each ISRC (artist
+	// MBIDs, original release year, label); found is false for unknown ISRCs
+	externalMetadataTable := `
+	CREATE TABLE IF NOT EXISTS external_metadata (
+		isrc VARCHAR(20) PRIMARY KEY,
+		source VARCHAR(20) NOT NULL DEFAULT 'musicbrainz',
+		found BOOLEAN NOT NULL,
+		recording_mbid VARCHAR(36),
+		artist_mbids TEXT[],
+		artist_names TEXT[],
+		release_mbid VARCHAR(36),
+		release_title TEXT,
+		release_year INTEGER,
+		label TEXT,
+		label_mbid VARCHAR(36),
+		looked_up_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, externalMetadataTable); err != nil {
+		return fmt.Errorf("failed to create external_metadata table: %v", err)
+	}
+
+	// Create audio_features table; rows with NULL features mark tracks Spotify has no analysis for
+	audioFeaturesTable := `
+	CREATE TABLE IF NOT EXISTS audio_features (
+		spotify_song_id VARCHAR(255) PRIMARY KEY,
+		danceability REAL,
+		energy REAL,
+		valence REAL,
+		tempo REAL,
+		acousticness REAL,
+		instrumentalness REAL,
+		speechiness REAL,
+		liveness REAL,
+		loudness REAL,
+		key SMALLINT,
+		mode SMALLINT,
+		time_signature SMALLINT,
+		fetched_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, audioFeaturesTable); err != nil {
+		return fmt.Errorf("failed to create audio_features table: %v", err)
+	}
+
+	// Create jobs table: background jobs run by the jobs package
+	jobsTable := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id VARCHAR(32) PRIMARY KEY,
+		type VARCHAR(50) NOT NULL,
+		params JSONB,
+		status VARCHAR(20) NOT NULL,
+		progress REAL NOT NULL DEFAULT 0,
+		result JSONB,
+		error TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		started_at TIMESTAMP,
+		finished_at TIMESTAMP
+	);`
+
+	if _, err := Pool.Exec(ctx, jobsTable); err != nil {
+		return fmt.Errorf("failed to create jobs table: %v", err)
+	}
+
+	// Create recovery_checkpoints: where an interrupted recovery run resumes
+	checkpointsTable := `
+	CREATE TABLE IF NOT EXISTS recovery_checkpoints (
+		job_name VARCHAR(100) PRIMARY KEY,
+		next_offset INTEGER NOT NULL DEFAULT 0,
+		last_item_at TIMESTAMPTZ,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, checkpointsTable); err != nil {
+		return fmt.Errorf("failed to create recovery_checkpoints table: %v", err)
+	}
+
+	// Create discovery_feed: new releases and recommendations not yet in my history
+	discoveryFeedTable := `
+	CREATE TABLE IF NOT EXISTS discovery_feed (
+		kind VARCHAR(10) NOT NULL,
+		spotify_id VARCHAR(255) NOT NULL,
+		name TEXT NOT NULL,
+		artist_name TEXT,
+		artist_id VARCHAR(255),
+		album_name TEXT,
+		image_url TEXT,
+		release_date VARCHAR(20),
+		source VARCHAR(30) NOT NULL,
+		reason TEXT NOT NULL,
+		score INTEGER NOT NULL DEFAULT 0,
+		discovered_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (kind, spotify_id)
+	);`
+
+	if _, err := Pool.Exec(ctx, discoveryFeedTable); err != nil {
+		return fmt.Errorf("failed to create discovery_feed table: %v", err)
+	}
+
+	// Create play_annotations: my own mood/activity tags for individual plays
+	playAnnotationsTable := `
+	CREATE TABLE IF NOT EXISTS play_annotations (
+		play_id INTEGER PRIMARY KEY,
+		mood VARCHAR(50),
+		activity VARCHAR(50),
+		note TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, playAnnotationsTable); err != nil {
+		return fmt.Errorf("failed to create play_annotations table: %v", err)
+	}
+
+	// Create webhooks: endpoints that receive collection events
+	webhooksTable := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id SERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		events TEXT[] NOT NULL DEFAULT '{}',
+		secret TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		last_status INTEGER,
+		last_error TEXT,
+		last_delivered_at TIMESTAMP
+	);`
+
+	if _, err := Pool.Exec(ctx, webhooksTable); err != nil {
+		return fmt.Errorf("failed to create webhooks table: %v", err)
+	}
+
+	// Create milestones: listening milestones, recorded once per (kind, subject)
+	milestonesTable := `
+	CREATE TABLE IF NOT EXISTS milestones (
+		id SERIAL PRIMARY KEY,
+		kind VARCHAR(30) NOT NULL,
+		subject TEXT NOT NULL,
+		value INTEGER NOT NULL DEFAULT 0,
+		label TEXT,
+		spotify_song_id VARCHAR(255),
+		achieved_at TIMESTAMPTZ NOT NULL,
+		detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (kind, subject)
+	);`
+
+	if _, err := Pool.Exec(ctx, milestonesTable); err != nil {
+		return fmt.Errorf("failed to create milestones table: %v", err)
+	}
+
+	// Create collection_gaps: stretches with missing plays, found by the
+	// gap_detection collector or opened while collection is failing
+	collectionGapsTable := `
+	CREATE TABLE IF NOT EXISTS collection_gaps (
+		id SERIAL PRIMARY KEY,
+		kind VARCHAR(30) NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL,
+		ended_at TIMESTAMPTZ,
+		active_minutes INTEGER NOT NULL DEFAULT 0,
+		reason TEXT,
+		detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (kind, started_at)
+	);`
+
+	if _, err := Pool.Exec(ctx, collectionGapsTable); err != nil {
+		return fmt.Errorf("failed to create collection_gaps table: %v", err)
+	}
+
+	// Create play_artists: every artist credited on a play, primary at position 0
+	playArtistsTable := `
+	CREATE TABLE IF NOT EXISTS play_artists (
+		play_id INTEGER NOT NULL,
+		position SMALLINT NOT NULL,
+		artist_id VARCHAR(255),
+		artist_name TEXT NOT NULL,
+		PRIMARY KEY (play_id, position)
+	);`
+
+	if _, err := Pool.Exec(ctx, playArtistsTable); err != nil {
+		return fmt.Errorf("failed to create play_artists table: %v", err)
+	}
+
+	// Migration: credit existing plays to their stored primary artist (first run only)
+	if _, err := Pool.Exec(ctx, `
+		INSERT INTO play_artists (play_id, position, artist_id, artist_name)
+		SELECT rp.id, 0, (SELECT MIN(a.artist_id) FROM artists a WHERE a.name = rp.artist_name), rp.artist_name
+		FROM recently_played rp
+		WHERE rp.artist_name IS NOT NULL AND rp.artist_name <> ''
+		  AND NOT EXISTS (SELECT 1 FROM play_artists)
+		ON CONFLICT DO NOTHING`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to backfill play_artists: %v\n", err)
+	}
+
+	// Create artist_releases: albums and singles found by the release radar
+	artistReleasesTable := `
+	CREATE TABLE IF NOT EXISTS artist_releases (
+		album_id VARCHAR(255) PRIMARY KEY,
+		artist_id VARCHAR(255) NOT NULL,
+		artist_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		album_type VARCHAR(50),
+		release_date VARCHAR(20),
+		release_date_precision VARCHAR(10),
+		total_tracks INTEGER,
+		image_url TEXT,
+		first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := Pool.Exec(ctx, artistReleasesTable); err != nil {
+		return fmt.Errorf("failed to create artist_releases table: %v", err)
+	}
+
+	// Create spotify_api_log: every call sent to Spotify (or a sample of the
+	// successful ones, each standing for weight calls), for tracing quota use
+	apiLogTable := `
+	CREATE TABLE IF NOT EXISTS spotify_api_log (
+		id BIGSERIAL PRIMARY KEY,
+		endpoint VARCHAR(50) NOT NULL,
+		status SMALLINT NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		retry_after_s INTEGER,
+		weight REAL NOT NULL DEFAULT 1,
+		called_at TIMESTAMPTZ NOT NULL
+	);`
+
+	if _, err := Pool.Exec(ctx, apiLogTable); err != nil {
+		return fmt.Errorf("failed to create spotify_api_log table: %v", err)
+	}
+
+	// Create collector_runs: what each cron collector run did, for
+	// GET /admin/collector-runs and the health part of /collection-stats
+	collectorRunsTable := `
+	CREATE TABLE IF NOT EXISTS collector_runs (
+		id BIGSERIAL PRIMARY KEY,
+		collector VARCHAR(30) NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL,
+		finished_at TIMESTAMPTZ NOT NULL,
+		fetched INTEGER NOT NULL DEFAULT 0,
+		inserted INTEGER NOT NULL DEFAULT 0,
+		skipped INTEGER NOT NULL DEFAULT 0,
+		api_calls INTEGER NOT NULL DEFAULT 0,
+		rate_limited INTEGER NOT NULL DEFAULT 0,
+		error TEXT
+	);`
+
+	if _, err := Pool.Exec(ctx, collectorRunsTable); err != nil {
+		return fmt.Errorf("failed to create collector_runs table: %v", err)
+	}
+
+	// Create queue_log: tracks seen in the player's queue by the queue
+	// collector, one row per stretch a track stayed queued, so
+	// GET /stats/queue-dropoff can find the ones that never played
+	queueLogTable := `
+	CREATE TABLE IF NOT EXISTS queue_log (
+		id BIGSERIAL PRIMARY KEY,
+		spotify_song_id VARCHAR(255) NOT NULL,
+		track_name TEXT,
+		artist_name TEXT,
+		position INTEGER NOT NULL,
+		queued_behind VARCHAR(255),
+		first_seen_at TIMESTAMPTZ NOT NULL,
+		last_seen_at TIMESTAMPTZ NOT NULL
+	);`
+
+	if _, err := Pool.Exec(ctx, queueLogTable); err != nil {
+		return fmt.Errorf("failed to create queue_log table: %v", err)
+	}
+
+	// Create artist_relations: Spotify's related artists of the artists I've
+	// played, one row per edge, stored by the related_artists backfill
+	artistRelationsTable := `
+	CREATE TABLE IF NOT EXISTS artist_relations (
+		artist_id VARCHAR(255) NOT NULL,
+		related_artist_id VARCHAR(255) NOT NULL,
+		rank SMALLINT NOT NULL,
+		fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (artist_id, related_artist_id)
+	);`
+
+	if _, err := Pool.Exec(ctx, artistRelationsTable); err != nil {
+		return fmt.Errorf("failed to create artist_relations table: %v", err)
+	}
+
+	// Create recently_played_archive: old plays moved out of the live table by
+	// POST /admin/plays/archive. It inherits recently_played, so every query on
+	// recently_played (stats included) reads archived plays too, and column
+	// migrations reach it; ONLY recently_played is the live table.
+	archiveTable := `
+	CREATE TABLE IF NOT EXISTS recently_played_archive (
+		PRIMARY KEY (id),
+		UNIQUE (spotify_song_id, played_at)
+	) INHERITS (recently_played);`
+
+	if _, err := Pool.Exec(ctx, archiveTable); err != nil {
+		return fmt.Errorf("failed to create recently_played_archive table: %v", err)
+	}
+
+	// Migration: unique keys aren't inherited, so nothing stops a play that
+	// was archived from being inserted into the live table again (import,
+	// historical fetch, a replayed buffer). A trigger skips it, the way
+	// ON CONFLICT DO NOTHING skips a play already in the live table.
+	skipArchived := []string{
+		`CREATE OR REPLACE FUNCTION skip_archived_play() RETURNS trigger AS $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM recently_played_archive
+				WHERE spotify_song_id = NEW.spotify_song_id AND played_at = NEW.played_at) THEN
+				RETURN NULL;
+			END IF;
+			RETURN NEW;
+		END $$ LANGUAGE plpgsql`,
+		`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'recently_played_skip_archived') THEN
+				CREATE TRIGGER recently_played_skip_archived BEFORE INSERT ON recently_played
+					FOR EACH ROW EXECUTE FUNCTION skip_archived_play();
+			END IF;
+		END $$`,
+	}
+	for _, skipSQL := range skipArchived {
+		if _, err := Pool.Exec(ctx, skipSQL); err != nil {
+			return fmt.Errorf("failed to add the archived play check: %v", err)
+		}
+	}
+
+	// Migration: annotations and artists follow their play into the archive, so
+	// a trigger deletes them with the play instead of foreign keys, which only
+	// see ONLY recently_played and would cascade when a play is archived
+	playDetails := []string{
+		`ALTER TABLE play_annotations DROP CONSTRAINT IF EXISTS play_annotations_play_id_fkey`,
+		`ALTER TABLE play_artists DROP CONSTRAINT IF EXISTS play_artists_play_id_fkey`,
+		`CREATE OR REPLACE FUNCTION delete_play_details() RETURNS trigger AS $$
+		BEGIN
+			IF current_setting('spotifydb.archiving', true) = 'on' THEN
+				RETURN OLD;
+			END IF;
+			DELETE FROM play_annotations WHERE play_id = OLD.id;
+			DELETE FROM play_artists WHERE play_id = OLD.id;
+			RETURN OLD;
+		END $$ LANGUAGE plpgsql`,
+	}
+	for _, table := range []string{"recently_played", "recently_played_archive"} {
+		playDetails = append(playDetails, fmt.Sprintf(`
+		DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = '%[1]s_delete_details') THEN
+				CREATE TRIGGER %[1]s_delete_details AFTER DELETE ON %[1]s
+					FOR EACH ROW EXECUTE FUNCTION delete_play_details();
+			END IF;
+		END $$`, table))
+	}
+	for _, detailSQL := range playDetails {
+		if _, err := Pool.Exec(ctx, detailSQL); err != nil {
+			return fmt.Errorf("failed to migrate play details to a delete trigger: %v", err)
+		}
+	}
+
+	// Migration: when the release radar last looked at each artist
+	if _, err := Pool.Exec(ctx, `ALTER TABLE artists ADD COLUMN IF NOT EXISTS releases_checked_at TIMESTAMPTZ`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add releases_checked_at column: %v\n", err)
+	}
+
+	// Migration: when each artist's related artists were last fetched, so
+	// artists Spotify relates to nobody aren't asked about again and again
+	if _, err := Pool.Exec(ctx, `ALTER TABLE artists ADD COLUMN IF NOT EXISTS related_checked_at TIMESTAMPTZ`); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to add related_checked_at column: %v\n", err)
+	}
+
+	// Migration: history timestamps were stored as UTC wall-clock TIMESTAMP;
+	// make them TIMESTAMPTZ so stats can be bucketed in any timezone
+	for _, col := range [][2]string{
+		{"recently_played", "played_at"},
+		{"recently_liked", "added_at"},
+		{"recently_liked", "unliked_at"},
+		{"now_playing_log", "captured_at"},
+	} {
+		var dataType string
+		err := Pool.QueryRow(ctx, `
+			SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`,
+			col[0], col[1]).Scan(&dataType)
+		if err != nil || dataType != "timestamp without time zone" {
+			continue
+		}
+		if _, err := Pool.Exec(ctx, fmt.Sprintf(
+			`ALTER TABLE %[1]s ALTER COLUMN %[2]s TYPE TIMESTAMPTZ USING %[2]s AT TIME ZONE 'UTC'`,
+			col[0], col[1])); err != nil {
+			return fmt.Errorf("failed to migrate %s.%s to TIMESTAMPTZ: %v", col[0], col[1], err)
+		}
+		fmt.Printf("🕐 migrated %s.%s to TIMESTAMPTZ\n", col[0], col[1])
+	}
+
 	// Create useful indexes
 	indexes := []string{
 		"CREATE INDEX IF NOT EXISTS idx_recently_liked_added_at ON recently_liked(added_at DESC);",
 		"CREATE INDEX IF NOT EXISTS idx_recently_liked_genre ON recently_liked(genre);",
 		"CREATE INDEX IF NOT EXISTS idx_recently_played_played_at ON recently_played(played_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_recently_played_archive_played_at ON recently_played_archive(played_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_artists_last_refreshed ON artists(last_refreshed);",
+		"CREATE INDEX IF NOT EXISTS idx_artist_releases_release_date ON artist_releases(release_date DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_track_genres_genre_id ON track_genres(genre_id);",
+		"CREATE INDEX IF NOT EXISTS idx_now_playing_log_captured_at ON now_playing_log(captured_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_canonical_tracks_isrc ON canonical_tracks(isrc);",
+		"CREATE INDEX IF NOT EXISTS idx_recently_played_isrc ON recently_played(isrc);",
+		"CREATE INDEX IF NOT EXISTS idx_external_metadata_label ON external_metadata(label);",
+		"CREATE INDEX IF NOT EXISTS idx_spotify_api_log_called_at ON spotify_api_log(called_at);",
+		"CREATE INDEX IF NOT EXISTS idx_collector_runs_collector ON collector_runs(collector, started_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_collector_runs_started_at ON collector_runs(started_at);",
+		"CREATE INDEX IF NOT EXISTS idx_queue_log_song ON queue_log(spotify_song_id, last_seen_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_queue_log_first_seen_at ON queue_log(first_seen_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_artist_relations_related ON artist_relations(related_artist_id);",
+		"CREATE INDEX IF NOT EXISTS idx_recently_played_scrobble ON recently_played(played_at) WHERE scrobble_status IN ('pending', 'failed');",
+		"CREATE INDEX IF NOT EXISTS idx_recently_played_listenbrainz ON recently_played(played_at) WHERE listenbrainz_status IN ('pending', 'submitted', 'failed');",
+		"CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_play_annotations_mood ON play_annotations(mood);",
+		"CREATE INDEX IF NOT EXISTS idx_play_annotations_activity ON play_annotations(activity);",
+		"CREATE INDEX IF NOT EXISTS idx_milestones_achieved_at ON milestones(achieved_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_collection_gaps_started_at ON collection_gaps(started_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_discovery_feed_score ON discovery_feed(score DESC, discovered_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_play_artists_name ON play_artists(LOWER(artist_name));",
+		"CREATE INDEX IF NOT EXISTS idx_play_artists_artist_id ON play_artists(artist_id);",
 	}
 
 	for _, indexSQL := range indexes {
@@ -138,6 +828,26 @@ func ensureTablesExist() error {
 		}
 	}
 
+	// Views over recently_played: the first-ever play of each track, and every
+	// play marked as a discovery or a repeat listen. Created after the column
+	// migrations above since a view would block ALTER COLUMN TYPE.
+	views := []string{
+		`CREATE OR REPLACE VIEW track_first_plays AS
+			SELECT spotify_song_id, MIN(played_at) AS first_played_at
+			FROM recently_played
+			GROUP BY spotify_song_id`,
+		`CREATE OR REPLACE VIEW plays_classified AS
+			SELECT rp.id, rp.spotify_song_id, rp.played_at,
+			       rp.played_at = f.first_played_at AS is_first_play
+			FROM recently_played rp
+			JOIN track_first_plays f ON f.spotify_song_id = rp.spotify_song_id`,
+	}
+	for _, viewSQL := range views {
+		if _, err := Pool.Exec(ctx, viewSQL); err != nil {
+			return fmt.Errorf("failed to create view: %v", err)
+		}
+	}
+
 	fmt.Println("🏗️  Database schema verified/created")
 	return nil
 }
@@ -145,7 +855,7 @@ func ensureTablesExist() error {
 // GetLatestPlayedAt returns the most recent played_at timestamp from recently_played
 func GetLatestPlayedAt() (time.Time, error) {
 	var latestTime time.Time
-	query := `SELECT COALESCE(MAX(played_at), '1970-01-01'::timestamp) FROM recently_played`
+	query := `SELECT COALESCE(MAX(played_at), '1970-01-01'::timestamptz) FROM recently_played`
 	err := Pool.QueryRow(context.Background(), query).Scan(&latestTime)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("failed to get latest played_at: %v", err)
@@ -156,7 +866,7 @@ func GetLatestPlayedAt() (time.Time, error) {
 // GetLatestAddedAt returns the most recent added_at timestamp from recently_liked
 func GetLatestAddedAt() (time.Time, error) {
 	var latest time.Time
-	query := `SELECT COALESCE(MAX(added_at), '1970-01-01'::timestamp) FROM recently_liked`
+	query := `SELECT COALESCE(MAX(added_at), '1970-01-01'::timestamptz) FROM recently_liked`
 	err := Pool.QueryRow(context.Background(), query).Scan(&latest)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("failed to get latest added_at: %v", err)
@@ -175,35 +885,43 @@ func GetTrackCountSince(since time.Time) (int, error) {
 	return count, nil
 }
 
-// GetTrackCountByDateRange returns counts grouped by date for analytics
-func GetTrackCountByDateRange() ([]struct {
+// GetTrackCountBetween counts plays in [from, to)
+func GetTrackCountBetween(from, to time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM recently_played WHERE played_at >= $1 AND played_at < $2`
+	err := Pool.QueryRow(context.Background(), query, from, to).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tracks between %v and %v: %v", from, to, err)
+	}
+	return count, nil
+}
+
+// DailyCount is the number of plays on one day
+type DailyCount struct {
 	Date  string `json:"date"`
 	Count int    `json:"count"`
-}, error) {
+}
+
+// GetTrackCountByDateRange returns counts grouped by date for analytics
+func GetTrackCountByDateRange() ([]DailyCount, error) {
 	query := `
 		SELECT 
-			DATE(played_at) as date,
+			TO_CHAR((played_at AT TIME ZONE $1)::date, 'YYYY-MM-DD') as date,
 			COUNT(*) as count
 		FROM recently_played 
 		WHERE played_at >= NOW() - INTERVAL '30 days'
-		GROUP BY DATE(played_at)
+		GROUP BY 1
 		ORDER BY date DESC
 	`
-	rows, err := Pool.Query(context.Background(), query)
+	rows, err := Pool.Query(context.Background(), query, config.Timezone().String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get date range counts: %v", err)
 	}
 	defer rows.Close()
 
-	var results []struct {
-		Date  string `json:"date"`
-		Count int    `json:"count"`
-	}
+	var results []DailyCount
 	for rows.Next() {
-		var result struct {
-			Date  string `json:"date"`
-			Count int    `json:"count"`
-		}
+		var result DailyCount
 		if err := rows.Scan(&result.Date, &result.Count); err != nil {
 			return nil, err
 		}
@@ -234,39 +952,34 @@ func GetListeningTimeSince(since time.Time) (int64, error) {
 	return totalMs, nil
 }
 
-// GetListeningTimeByDateRange returns daily listening time for the last 30 days
-func GetListeningTimeByDateRange() ([]struct {
+// DailyListening is the listening time and play count for one day
+type DailyListening struct {
 	Date    string `json:"date"`
 	TotalMs int64  `json:"total_ms"`
 	Count   int    `json:"count"`
-}, error) {
+}
+
+// GetListeningTimeByDateRange returns daily listening time for the last 30 days
+func GetListeningTimeByDateRange() ([]DailyListening, error) {
 	query := `
 		SELECT
-			TO_CHAR(DATE(played_at), 'YYYY-MM-DD') as date,
+			TO_CHAR((played_at AT TIME ZONE $1)::date, 'YYYY-MM-DD') as date,
 			COALESCE(SUM(duration_ms), 0) as total_ms,
 			COUNT(*) as count
 		FROM recently_played
 		WHERE played_at >= NOW() - INTERVAL '30 days'
-		GROUP BY DATE(played_at)
+		GROUP BY 1
 		ORDER BY date DESC
 	`
-	rows, err := Pool.Query(context.Background(), query)
+	rows, err := Pool.Query(context.Background(), query, config.Timezone().String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get listening time by date range: %v", err)
 	}
 	defer rows.Close()
 
-	var results []struct {
-		Date    string `json:"date"`
-		TotalMs int64  `json:"total_ms"`
-		Count   int    `json:"count"`
-	}
+	var results []DailyListening
 	for rows.Next() {
-		var result struct {
-			Date    string `json:"date"`
-			TotalMs int64  `json:"total_ms"`
-			Count   int    `json:"count"`
-		}
+		var result DailyListening
 		if err := rows.Scan(&result.Date, &result.TotalMs, &result.Count); err != nil {
 			return nil, err
 		}
@@ -298,7 +1011,7 @@ type TrackStreak struct {
 func GetTrackStreak(spotifyID string) (longest TrackStreak, current *TrackStreak, err error) {
 	query := `
 	WITH play_dates AS (
-		SELECT DISTINCT DATE(played_at) AS d
+		SELECT DISTINCT (played_at AT TIME ZONE $2)::date AS d
 		FROM recently_played
 		WHERE spotify_song_id = $1
 	),
@@ -319,12 +1032,16 @@ func GetTrackStreak(spotifyID string) (longest TrackStreak, current *TrackStreak
 	ORDER BY streak_len DESC, streak_end DESC
 	`
 
-	rows, err := Pool.Query(context.Background(), query, spotifyID)
+	rows, err := Pool.Query(context.Background(), query, spotifyID, config.Timezone().String())
 	if err != nil {
 		return longest, nil, fmt.Errorf("failed to get track streak: %v", err)
 	}
 	defer rows.Close()
 
+	// A streak is current if it ends today or yesterday in the configured timezone
+	today := config.Today().Format("2006-01-02")
+	yesterday := config.Today().AddDate(0, 0, -1).Format("2006-01-02")
+
 	first := true
 	for rows.Next() {
 		var start, end time.Time
@@ -345,12 +1062,7 @@ func GetTrackStreak(spotifyID string) (longest TrackStreak, current *TrackStreak
 			first = false
 		}
 
-		// Check if this streak is current (ends today or yesterday)
-		today := time.Now().Truncate(24 * time.Hour)
-		yesterday := today.AddDate(0, 0, -1)
-		endDate := end.Truncate(24 * time.Hour)
-
-		if current == nil && (endDate.Equal(today) || endDate.Equal(yesterday)) {
+		if current == nil && (endStr == today || endStr == yesterday) {
 			current = &TrackStreak{
 				LongestStreak: length,
 				LongestStart:  &startStr,
@@ -391,8 +1103,8 @@ func GetTrackStats(spotifyID string, from, to *time.Time) (TrackStats, error) {
 		       MAX(played_at) as last_listen
 		FROM recently_played
 		WHERE spotify_song_id = $1
-		  AND ($2::timestamp IS NULL OR played_at >= $2)
-		  AND ($3::timestamp IS NULL OR played_at <= $3)`
+		  AND ($2::timestamptz IS NULL OR played_at >= $2)
+		  AND ($3::timestamptz IS NULL OR played_at <= $3)`
 	err := Pool.QueryRow(context.Background(), query, spotifyID, from, to).
 		Scan(&stats.PlayCount, &stats.TotalMs, &firstListen, &lastListen)
 	if err != nil {
@@ -417,16 +1129,16 @@ type DailyPlay struct {
 // GetTrackDaily returns per-day play counts and duration for a track
 func GetTrackDaily(spotifyID string, from, to *time.Time) ([]DailyPlay, error) {
 	query := `
-		SELECT TO_CHAR(DATE(played_at), 'YYYY-MM-DD') as date,
+		SELECT TO_CHAR((played_at AT TIME ZONE $4)::date, 'YYYY-MM-DD') as date,
 		       COUNT(*) as play_count,
 		       COALESCE(SUM(duration_ms), 0) as total_ms
 		FROM recently_played
 		WHERE spotify_song_id = $1
-		  AND ($2::timestamp IS NULL OR played_at >= $2)
-		  AND ($3::timestamp IS NULL OR played_at <= $3)
-		GROUP BY DATE(played_at)
+		  AND ($2::timestamptz IS NULL OR played_at >= $2)
+		  AND ($3::timestamptz IS NULL OR played_at <= $3)
+		GROUP BY 1
 		ORDER BY date`
-	rows, err := Pool.Query(context.Background(), query, spotifyID, from, to)
+	rows, err := Pool.Query(context.Background(), query, spotifyID, from, to, config.Timezone().String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get track daily: %v", err)
 	}
@@ -445,32 +1157,36 @@ func GetTrackDaily(spotifyID string, from, to *time.Time) ([]DailyPlay, error) {
 
 // TopTrack holds a ranked track from the top-tracks query
 type TopTrack struct {
-	SpotifyID    string `json:"song_id"`
-	TrackName    string `json:"track_name"`
-	ArtistName   string `json:"artist_name"`
-	AlbumName    string `json:"album_name"`
+	SpotifyID     string `json:"song_id"`
+	TrackName     string `json:"track_name"`
+	ArtistName    string `json:"artist_name"`
+	AlbumName     string `json:"album_name"`
 	AlbumCoverURL string `json:"album_cover_url"`
-	PlayCount    int    `json:"play_count"`
-	TotalMs      int64  `json:"total_ms"`
+	PlayCount     int    `json:"play_count"`
+	TotalMs       int64  `json:"total_ms"`
 }
 
-// GetTopTracks returns the most-played tracks within an optional date range
-func GetTopTracks(from, to *time.Time, limit int) ([]TopTrack, error) {
+// GetTopTracks returns the most-played tracks within an optional date range.
+// With canonical set, variants of the same recording are counted together;
+// a non-empty source counts only plays stored by it.
+func GetTopTracks(from, to *time.Time, limit int, canonical bool, source string) ([]TopTrack, error) {
 	query := `
-		SELECT spotify_song_id,
-		       MAX(track_name) as track_name,
-		       MAX(artist_name) as artist_name,
-		       MAX(album_name) as album_name,
-		       MAX(album_cover_url) as album_cover_url,
+		SELECT CASE WHEN $4 THEN COALESCE(ct.canonical_id, rp.spotify_song_id) ELSE rp.spotify_song_id END,
+		       MAX(rp.track_name) as track_name,
+		       MAX(rp.artist_name) as artist_name,
+		       MAX(rp.album_name) as album_name,
+		       MAX(rp.album_cover_url) as album_cover_url,
 		       COUNT(*) as play_count,
-		       COALESCE(SUM(duration_ms), 0) as total_ms
-		FROM recently_played
-		WHERE ($1::timestamp IS NULL OR played_at >= $1)
-		  AND ($2::timestamp IS NULL OR played_at <= $2)
-		GROUP BY spotify_song_id
+		       COALESCE(SUM(rp.duration_ms), 0) as total_ms
+		FROM recently_played rp
+		LEFT JOIN canonical_tracks ct ON ct.spotify_song_id = rp.spotify_song_id
+		WHERE ($1::timestamptz IS NULL OR rp.played_at >= $1)
+		  AND ($2::timestamptz IS NULL OR rp.played_at <= $2)
+		  AND ($5 = '' OR rp.source = $5)
+		GROUP BY 1
 		ORDER BY play_count DESC
 		LIMIT $3`
-	rows, err := Pool.Query(context.Background(), query, from, to, limit)
+	rows, err := Pool.Query(context.Background(), query, from, to, limit, canonical, source)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get top tracks: %v", err)
 	}
@@ -487,29 +1203,81 @@ func GetTopTracks(from, to *time.Time, limit int) ([]TopTrack, error) {
 	return tracks, nil
 }
 
-// GetArtistsByGenre returns unique artists from specified table that match the given genre
-func GetArtistsByGenre(tableName, genre string) ([]map[string]any, error) {
+// GenreArtist is an artist with tracks tagged with a given genre
+type GenreArtist struct {
+	ArtistName     string `json:"artist_name"`
+	ArtistID       string `json:"artist_id"`
+	TrackCount     int    `json:"track_count"`
+	Genres         string `json:"genres"`
+	ArtistImageURL string `json:"artist_image_url"`
+}
+
+// genreArtistTables are the tables GetArtistsByGenre may read: they need
+// artist_id, album_cover_url and added_at
+var genreArtistTables = map[string]bool{
+	"recently_liked": true,
+}
+
+// Genre match modes for GetArtistsByGenre
+const (
+	GenreMatchExact    = "exact"    // "rock" matches only "rock"
+	GenreMatchContains = "contains" // "rock" also matches "post-rock" and "rock en español"
+)
+
+// EscapeLike escapes the LIKE wildcards in s so it matches literally
+// (with the default ESCAPE '\')
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetArtistsByGenre returns unique artists from specified table with a track
+// tagged with the given genre (via track_genres). GenreMatchExact matches the
+// genre name exactly, so "rock" doesn't match "post-rock"; GenreMatchContains
+// matches it anywhere in the name, taking % and _ literally.
+func GetArtistsByGenre(tableName, genre, match string) ([]GenreArtist, error) {
+	if !genreArtistTables[tableName] {
+		return nil, fmt.Errorf("unknown genre artist table %q", tableName)
+	}
+	table := pgx.Identifier{tableName}.Sanitize()
+
+	genre = strings.ToLower(strings.TrimSpace(genre))
+	cond := "g.name = $1"
+	switch match {
+	case GenreMatchExact, "":
+	case GenreMatchContains:
+		cond = "g.name LIKE $1"
+		genre = "%" + EscapeLike(genre) + "%"
+	default:
+		return nil, fmt.Errorf("unknown genre match %q", match)
+	}
+
 	query := fmt.Sprintf(`
-		SELECT artist_name, artist_id, 
-		       COUNT(*) as track_count,
-		       STRING_AGG(DISTINCT genre, ', ') as genres,
-		       (SELECT album_cover_url FROM %s r2 
+		SELECT r1.artist_name, r1.artist_id,
+		       COUNT(DISTINCT r1.spotify_song_id) as track_count,
+		       COALESCE((SELECT STRING_AGG(DISTINCT g2.name, ', ')
+		                 FROM %[1]s r3
+		                 JOIN track_genres tg2 ON tg2.spotify_song_id = r3.spotify_song_id
+		                 JOIN genres g2 ON g2.id = tg2.genre_id
+		                 WHERE r3.artist_id = r1.artist_id), '') as genres,
+		       (SELECT album_cover_url FROM %[1]s r2 
 		        WHERE r2.artist_id = r1.artist_id 
 		        AND r2.album_cover_url IS NOT NULL 
 		        ORDER BY r2.added_at DESC LIMIT 1) as artist_image_url
-		FROM %s r1
-		WHERE genre ILIKE $1 
-		GROUP BY artist_name, artist_id
-		ORDER BY track_count DESC, artist_name
-	`, tableName, tableName)
+		FROM %[1]s r1
+		JOIN track_genres tg ON tg.spotify_song_id = r1.spotify_song_id
+		JOIN genres g ON g.id = tg.genre_id
+		WHERE %[2]s
+		GROUP BY r1.artist_name, r1.artist_id
+		ORDER BY track_count DESC, r1.artist_name
+	`, table, cond)
 
-	rows, err := Pool.Query(context.Background(), query, "%"+genre+"%")
+	rows, err := Pool.Query(context.Background(), query, genre)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get artists by genre: %v", err)
 	}
 	defer rows.Close()
 
-	var artists []map[string]any
+	var artists []GenreArtist
 	for rows.Next() {
 		var artistName, artistID, genres string
 		var artistImageURL *string
@@ -525,15 +1293,82 @@ func GetArtistsByGenre(tableName, genre string) ([]map[string]any, error) {
 			imageURL = *artistImageURL
 		}
 
-		artist := map[string]any{
-			"artist_name":      artistName,
-			"artist_id":        artistID,
-			"track_count":      trackCount,
-			"genres":           genres,
-			"artist_image_url": imageURL,
-		}
-		artists = append(artists, artist)
+		artists = append(artists, GenreArtist{
+			ArtistName:     artistName,
+			ArtistID:       artistID,
+			TrackCount:     trackCount,
+			Genres:         genres,
+			ArtistImageURL: imageURL,
+		})
 	}
 
 	return artists, nil
 }
+
+// Ping verifies the pool can reach the database
+func Ping(ctx context.Context) error {
+	if Pool == nil {
+		return fmt.Errorf("database pool not initialized")
+	}
+	return Pool.Ping(ctx)
+}
+
+// HasPlayNear reports whether a play of the track exists within window of playedAt.
+// Imports use this because export timestamps don't line up exactly with the API's.
+func HasPlayNear(spotifyID string, playedAt time.Time, window time.Duration) (bool, error) {
+	var exists bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM recently_played
+			WHERE spotify_song_id = $1
+			  AND played_at BETWEEN $2 AND $3
+		)`
+	err := Pool.QueryRow(context.Background(), query, spotifyID, playedAt.Add(-window), playedAt.Add(window)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for existing play: %v", err)
+	}
+	return exists, nil
+}
+
+// GenreCount holds a genre and how much of the library it covers
+type GenreCount struct {
+	Name       string `json:"name"`
+	TrackCount int    `json:"track_count"`
+	PlayCount  int    `json:"play_count"`
+	LikedCount int    `json:"liked_count"`
+}
+
+// ListGenres returns every genre with its distinct tracks, plays and liked tracks
+func ListGenres() ([]GenreCount, error) {
+	query := `
+		SELECT g.name,
+		       COUNT(DISTINCT tg.spotify_song_id) AS track_count,
+		       COALESCE(SUM(p.plays), 0) AS play_count,
+		       COUNT(DISTINCT rl.spotify_song_id) AS liked_count
+		FROM genres g
+		JOIN track_genres tg ON tg.genre_id = g.id
+		LEFT JOIN (
+			SELECT spotify_song_id, COUNT(*) AS plays
+			FROM recently_played
+			GROUP BY spotify_song_id
+		) p ON p.spotify_song_id = tg.spotify_song_id
+		LEFT JOIN recently_liked rl ON rl.spotify_song_id = tg.spotify_song_id
+		GROUP BY g.name
+		ORDER BY track_count DESC, g.name`
+
+	rows, err := Pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list genres: %v", err)
+	}
+	defer rows.Close()
+
+	var genres []GenreCount
+	for rows.Next() {
+		var g GenreCount
+		if err := rows.Scan(&g.Name, &g.TrackCount, &g.PlayCount, &g.LikedCount); err != nil {
+			return nil, err
+		}
+		genres = append(genres, g)
+	}
+	return genres, rows.Err()
+}
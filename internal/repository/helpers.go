@@ -2,6 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // read the single row
@@ -24,3 +33,121 @@ func SaveOrUpdateRefreshToken(tok string) error {
 
 	return err
 }
+
+// SpotifyAccount is the account whose refresh token is stored, as of the
+// last time the token was saved or checked
+type SpotifyAccount struct {
+	UserID      string     `json:"user_id"`
+	DisplayName string     `json:"display_name"`
+	Country     string     `json:"country,omitempty"`
+	Product     string     `json:"product,omitempty"` // premium, free or open
+	Scopes      []string   `json:"scopes"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+// SaveSpotifyAccount records the profile and scopes next to the refresh token
+func SaveSpotifyAccount(a SpotifyAccount) error {
+	_, err := Pool.Exec(context.Background(), `
+		UPDATE spotify_auth
+		SET user_id = $1, display_name = $2, country = $3, product = $4,
+		    scopes = $5, profile_updated_at = NOW()
+		WHERE id = 1`,
+		a.UserID, a.DisplayName, a.Country, a.Product, a.Scopes)
+	if err != nil {
+		return fmt.Errorf("failed to save spotify account: %v", err)
+	}
+	return nil
+}
+
+// GetSpotifyAccount returns the stored account, or nil before one is saved
+func GetSpotifyAccount() (*SpotifyAccount, error) {
+	var a SpotifyAccount
+	err := Pool.QueryRow(context.Background(), `
+		SELECT COALESCE(user_id, ''), COALESCE(display_name, ''), COALESCE(country, ''),
+		       COALESCE(product, ''), COALESCE(scopes, '{}'), profile_updated_at
+		FROM spotify_auth WHERE id = 1`).
+		Scan(&a.UserID, &a.DisplayName, &a.Country, &a.Product, &a.Scopes, &a.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && a.UpdatedAt == nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spotify account: %v", err)
+	}
+	return &a, nil
+}
+
+// IsUnavailable reports whether err means Postgres couldn't be reached, as
+// opposed to the server rejecting the statement: dial failures, timeouts,
+// dropped connections, and connection-exception or shutdown SQLSTATEs.
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"): // connection_exception
+			return true
+		case pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03": // admin/crash shutdown, cannot_connect_now
+			return true
+		case pgErr.Code == "53300": // too_many_connections
+			return true
+		}
+		return false
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) || pgconn.SafeToRetry(err) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// Many callers wrap with %v, which drops the type; fall back to the message
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"failed to connect", "connection refused", "conn closed", "connection reset",
+		"broken pipe", "no such host", "i/o timeout", "unexpected eof",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryable reports whether Postgres aborted a transaction that would
+// likely succeed if run again: serialization failures and deadlocks
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	return false
+}
+
+// IsUniqueViolation reports whether Postgres rejected a write for breaking a
+// unique constraint, like storing a play that's already stored
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// txAttempts is how many times InTx runs a transaction that keeps conflicting
+const txAttempts = 3
+
+// InTx runs fn in a serializable transaction, committing if it returns nil.
+// Transactions aborted by a serialization failure or deadlock are retried
+// from scratch, so fn must be safe to run more than once.
+func InTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= txAttempts; attempt++ {
+		err = pgx.BeginTxFunc(ctx, Pool, pgx.TxOptions{IsoLevel: pgx.Serializable}, fn)
+		if !IsRetryable(err) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+	}
+	return fmt.Errorf("transaction still conflicting after %d attempts: %w", txAttempts, err)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AdvisoryLock is a session-level Postgres advisory lock held on its own
+// connection, outside the pool. Postgres drops the lock with the session, so
+// when the holder dies another process can take it over.
+type AdvisoryLock struct {
+	dsn string
+	key int64
+
+	mu   sync.Mutex
+	conn *pgx.Conn // set while the lock is held
+}
+
+// NewAdvisoryLock returns a lock on key, taken over a connection to dsn
+func NewAdvisoryLock(dsn string, key int64) *AdvisoryLock {
+	return &AdvisoryLock{dsn: dsn, key: key}
+}
+
+// TryAcquire reports whether this process holds the lock, taking it if it's
+// free. A lock already held is confirmed with a query on its connection;
+// once that connection is gone the lock is too, and it's taken afresh.
+func (l *AdvisoryLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if _, err := l.conn.Exec(ctx, "SELECT 1"); err == nil {
+			return true, nil
+		}
+		l.conn.Close(ctx)
+		l.conn = nil
+	}
+
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect for advisory lock %d: %v", l.key, err)
+	}
+	var acquired bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+		conn.Close(ctx)
+		return false, fmt.Errorf("failed to take advisory lock %d: %v", l.key, err)
+	}
+	if !acquired {
+		conn.Close(ctx)
+		return false, nil
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Release gives the lock up, if held
+func (l *AdvisoryLock) Release(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return
+	}
+	l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	l.conn.Close(ctx)
+	l.conn = nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"example.com/spotifydb/internal/metrics"
+	"github.com/jackc/pgx/v5"
+)
+
+// queryTracer records query latency into the DB histogram
+type queryTracer struct{}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	at        time.Time
+	operation string
+}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{at: time.Now(), operation: queryOperation(data.SQL)})
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	status := "ok"
+	if data.Err != nil {
+		status = "error"
+	}
+	metrics.DBQueryDuration.WithLabelValues(start.operation, status).Observe(time.Since(start.at).Seconds())
+}
+
+// queryOperation reduces a statement to its leading keyword (select, insert, ...)
+// so the metric label stays low-cardinality.
+func queryOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	op := strings.ToLower(fields[0])
+	if op == "with" {
+		return "select"
+	}
+	return op
+}
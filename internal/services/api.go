@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// Client is the part of the Spotify Web API the collectors depend on, so they
+// can run against a fake in tests. Live calls the real API.
+type Client interface {
+	ExchangeRefreshToken(ctx context.Context, refreshToken string) (TokenGrant, error)
+	GetCurrentUserProfile(ctx context.Context, accessToken string) (*UserProfile, error)
+	GetRecentlyPlayedAfter(ctx context.Context, accessToken string, after time.Time) ([]PlayedItem, error)
+	GetRecentlyPlayedSince(ctx context.Context, accessToken string, since time.Time) ([]PlayedItem, error)
+	GetUserSavedTracksPage(ctx context.Context, accessToken string, offset, limit int) (*UserSavedTracks, error)
+	GetArtistById(ctx context.Context, accessToken, artistID string) (*Artist, error)
+	GetArtistsByIds(ctx context.Context, accessToken string, artistIDs []string) ([]Artist, error)
+}
+
+// Live implements Client with the package-level Spotify functions
+type Live struct{}
+
+func (Live) ExchangeRefreshToken(ctx context.Context, refreshToken string) (TokenGrant, error) {
+	return ExchangeRefreshToken(ctx, refreshToken)
+}
+
+func (Live) GetCurrentUserProfile(ctx context.Context, accessToken string) (*UserProfile, error) {
+	return GetCurrentUserProfile(ctx, accessToken)
+}
+
+func (Live) GetRecentlyPlayedAfter(ctx context.Context, accessToken string, after time.Time) ([]PlayedItem, error) {
+	return GetRecentlyPlayedAfter(ctx, accessToken, after)
+}
+
+func (Live) GetRecentlyPlayedSince(ctx context.Context, accessToken string, since time.Time) ([]PlayedItem, error) {
+	return GetRecentlyPlayedSince(ctx, accessToken, since)
+}
+
+func (Live) GetUserSavedTracksPage(ctx context.Context, accessToken string, offset, limit int) (*UserSavedTracks, error) {
+	return GetUserSavedTracksPage(ctx, accessToken, offset, limit)
+}
+
+func (Live) GetArtistById(ctx context.Context, accessToken, artistID string) (*Artist, error) {
+	return GetArtistById(ctx, accessToken, artistID)
+}
+
+func (Live) GetArtistsByIds(ctx context.Context, accessToken string, artistIDs []string) ([]Artist, error) {
+	return GetArtistsByIds(ctx, accessToken, artistIDs)
+}
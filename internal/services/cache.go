@@ -0,0 +1,68 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache keeps values for ttl and at most max of them, so lookups of ids
+// from request paths can't grow it without bound
+type ttlCache[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	now     func() time.Time
+	entries map[string]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value    V
+	storedAt time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration, max int) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, max: max, now: time.Now, entries: map[string]ttlEntry[V]{}}
+}
+
+// get returns the value stored for key unless it expired
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || c.now().Sub(e.storedAt) >= c.ttl {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// put stores value for key. When the cache is full, expired entries are
+// dropped first and then the oldest.
+func (c *ttlCache[V]) put(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.max {
+		var oldest string
+		for k, e := range c.entries {
+			if now.Sub(e.storedAt) >= c.ttl {
+				delete(c.entries, k)
+				continue
+			}
+			if oldest == "" || e.storedAt.Before(c.entries[oldest].storedAt) {
+				oldest = k
+			}
+		}
+		if len(c.entries) >= c.max {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = ttlEntry[V]{value: value, storedAt: now}
+}
+
+// len is how many entries are stored, expired or not
+func (c *ttlCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTestCache(ttl time.Duration, max int) (*ttlCache[int], *time.Time) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := newTTLCache[int](ttl, max)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	c, now := newTestCache(time.Hour, 10)
+	c.put("a", 1)
+
+	*now = now.Add(59 * time.Minute)
+	if v, ok := c.get("a"); !ok || v != 1 {
+		t.Errorf("get(a) = %d, %v before the TTL, want 1, true", v, ok)
+	}
+	*now = now.Add(time.Minute)
+	if _, ok := c.get("a"); ok {
+		t.Error("get(a) hit after the TTL")
+	}
+	if _, ok := c.get("b"); ok {
+		t.Error("get(b) hit, never stored")
+	}
+}
+
+func TestTTLCacheBounded(t *testing.T) {
+	c, now := newTestCache(time.Hour, 3)
+	for i := 0; i < 3; i++ {
+		c.put(fmt.Sprint(i), i)
+		*now = now.Add(time.Minute)
+	}
+
+	// full: the oldest goes
+	c.put("3", 3)
+	if c.len() != 3 {
+		t.Errorf("len = %d, want 3", c.len())
+	}
+	if _, ok := c.get("0"); ok {
+		t.Error("oldest entry 0 kept past the bound")
+	}
+	for _, k := range []string{"1", "2", "3"} {
+		if _, ok := c.get(k); !ok {
+			t.Errorf("entry %s evicted, want kept", k)
+		}
+	}
+
+	// replacing a key doesn't evict
+	c.put("3", 30)
+	if v, _ := c.get("3"); v != 30 || c.len() != 3 {
+		t.Errorf("get(3) = %d with %d entries, want 30 with 3", v, c.len())
+	}
+
+	// expired entries go before live ones
+	*now = now.Add(time.Hour)
+	c.put("4", 4)
+	if c.len() != 1 {
+		t.Errorf("len = %d after the rest expired, want 1", c.len())
+	}
+}
+
+func TestTTLCacheIDsFromRequests(t *testing.T) {
+	c, _ := newTestCache(time.Hour, maxArtistProfiles)
+	for i := 0; i < 5*maxArtistProfiles; i++ {
+		c.put(fmt.Sprintf("artist%d", i), i)
+	}
+	if c.len() != maxArtistProfiles {
+		t.Errorf("len = %d after %d distinct ids, want %d", c.len(), 5*maxArtistProfiles, maxArtistProfiles)
+	}
+}
+
+func TestTrackCacheBounded(t *testing.T) {
+	c, _ := newTestCache(TrackTTL, maxCachedTracks)
+	for i := 0; i < 2*maxCachedTracks; i++ {
+		c.put(fmt.Sprintf("track%d", i), i)
+	}
+	if c.len() != maxCachedTracks {
+		t.Errorf("len = %d after %d distinct ids, want %d", c.len(), 2*maxCachedTracks, maxCachedTracks)
+	}
+	if _, ok := c.get(fmt.Sprintf("track%d", 2*maxCachedTracks-1)); !ok {
+		t.Error("newest track evicted")
+	}
+}
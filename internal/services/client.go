@@ -1,6 +1,8 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -9,50 +11,428 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"example.com/spotifydb/internal/metrics"
+	"example.com/spotifydb/internal/utils"
 )
 
-// refreshes access_token; may return a new refresh_token
-func RefreshAccessToken(refreshToken string) (accessToken string, newRefreshTok *string, err error) {
+var (
+	// ErrUnauthorized means Spotify rejected our credentials (expired or revoked)
+	ErrUnauthorized = errors.New("spotify: unauthorized")
+	// ErrNotFound means Spotify has no object with the requested ID
+	ErrNotFound = errors.New("spotify: not found")
+	// ErrMissingScopes means a token wasn't granted every one of RequiredScopes
+	ErrMissingScopes = errors.New("spotify: token is missing required scopes")
+	// ErrRateLimited means Spotify answered 429; see SpotifyError.RetryAfter
+	ErrRateLimited = errors.New("spotify: rate limited")
+	// ErrUnavailable means Spotify doesn't serve an endpoint to this app, as
+	// with the ones deprecated for apps registered after Nov 2024
+	ErrUnavailable = errors.New("spotify: endpoint is not available to this app")
+)
+
+// budget is the process-wide Spotify request budget every call goes through,
+// so collectors running in the same tick can't add up to a 429
+var (
+	budget     *utils.RateLimiter
+	budgetOnce sync.Once
+)
+
+// httpClient sends every Spotify request; its timeout bounds calls made with
+// a context that has no deadline of its own, like the cron's
+var (
+	httpClient     *http.Client
+	httpClientOnce sync.Once
+)
+
+// HTTPTimeout returns the per-request timeout from SPOTIFY_HTTP_TIMEOUT (default 15s)
+func HTTPTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SPOTIFY_HTTP_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 15 * time.Second
+}
+
+func sharedClient() *http.Client {
+	httpClientOnce.Do(func() {
+		httpClient = &http.Client{Timeout: HTTPTimeout()}
+	})
+	return httpClient
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RequestsPerMinute returns the budget from SPOTIFY_REQUESTS_PER_MINUTE (default 60)
+func RequestsPerMinute() int {
+	if v, err := strconv.Atoi(os.Getenv("SPOTIFY_REQUESTS_PER_MINUTE")); err == nil && v > 0 {
+		return v
+	}
+	return 60
+}
+
+// tokens lets do() recover from an access token that expires mid-cycle: the
+// refresher registered with SetTokenRefresher mints a new one, and requests
+// still carrying the expired token are switched to its replacement
+var tokens struct {
+	sync.Mutex
+	refresh  func() (string, error)
+	expired  string
+	replaced string
+}
+
+// refreshMu makes concurrent 401s share one refresh
+var refreshMu sync.Mutex
+
+// SetTokenRefresher registers how to get a fresh access token after a 401.
+// It should exchange the stored refresh token and persist any rotated one.
+func SetTokenRefresher(refresh func() (string, error)) {
+	tokens.Lock()
+	tokens.refresh = refresh
+	tokens.Unlock()
+}
+
+// StoredTokenRefresher builds a refresher for SetTokenRefresher from a
+// refresh-token store: load reads the token, save persists a rotated one
+func StoredTokenRefresher(load func() (string, error), save func(string) error) func() (string, error) {
+	return func() (string, error) {
+		refreshTok, err := load()
+		if err != nil {
+			return "", err
+		}
+		// Not tied to the request that hit the 401: others may be waiting on
+		// this refresh too, and the client timeout still bounds it
+		accessTok, newRefresh, err := RefreshAccessToken(context.Background(), refreshTok)
+		if err != nil {
+			return "", err
+		}
+		if newRefresh != nil && *newRefresh != refreshTok {
+			if err := save(*newRefresh); err != nil {
+				fmt.Printf("⚠️  failed to persist rotated refresh token: %v\n", err)
+			}
+		}
+		return accessTok, nil
+	}
+}
+
+// replacementFor returns the token that replaced tok, or tok if it's current
+func replacementFor(tok string) string {
+	tokens.Lock()
+	defer tokens.Unlock()
+	if tok == tokens.expired && tokens.replaced != "" {
+		return tokens.replaced
+	}
+	return tok
+}
+
+// refreshAfter401 returns a fresh access token to replace one Spotify rejected
+func refreshAfter401(rejected string) (string, error) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+
+	// Another request already refreshed while we waited
+	if tok := replacementFor(rejected); tok != rejected {
+		return tok, nil
+	}
+
+	tokens.Lock()
+	refresh := tokens.refresh
+	tokens.Unlock()
+	if refresh == nil {
+		return "", errors.New("no token refresher registered")
+	}
+
+	tok, err := refresh()
+	if err != nil {
+		return "", err
+	}
+	tokens.Lock()
+	tokens.expired, tokens.replaced = rejected, tok
+	tokens.Unlock()
+	return tok, nil
+}
+
+// do sends req and records the call under the given endpoint label. A 401 on
+// a bearer-token request refreshes the token and retries once.
+func do(req *http.Request, endpoint string) (*http.Response, error) {
+	tok, bearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if bearer {
+		tok = replacementFor(tok)
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+
+	res, err := send(req, endpoint)
+	if err != nil || !bearer || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	fresh, err := refreshAfter401(tok)
+	if err != nil {
+		fmt.Printf("⚠️  spotify %s: 401 and token refresh failed: %v\n", endpoint, err)
+		return res, nil
+	}
+	res.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", "Bearer "+fresh)
+	fmt.Printf("🔑 spotify %s: access token expired, retrying with a fresh one\n", endpoint)
+	return send(retry, endpoint)
+}
+
+// requestCount counts the requests sent to Spotify since startup
+var requestCount atomic.Int64
+
+// RequestCount returns how many requests have been sent to Spotify since
+// startup; the difference across a run is the calls it used
+func RequestCount() int64 {
+	return requestCount.Load()
+}
+
+// rateLimitedCount counts the 429s Spotify answered since startup
+var rateLimitedCount atomic.Int64
+
+// RateLimitedCount returns how many requests Spotify answered with a 429
+// since startup
+func RateLimitedCount() int64 {
+	return rateLimitedCount.Load()
+}
+
+// SpareRequests returns how many calls the shared budget allows right now
+// without waiting, for deciding whether low-priority work can run
+func SpareRequests() int {
+	return sharedBudget().Available()
+}
+
+// sharedBudget returns the process-wide request budget
+func sharedBudget() *utils.RateLimiter {
+	budgetOnce.Do(func() {
+		budget = utils.NewRateLimiterWithBudget(RequestsPerMinute())
+	})
+	return budget
+}
+
+// Call is one request sent to Spotify, as reported to the call logger
+type Call struct {
+	Endpoint   string
+	Status     int // HTTP status, 0 when no response came back
+	Duration   time.Duration
+	RetryAfter time.Duration // from a 429's Retry-After header
+	At         time.Time
+}
+
+var callLogger atomic.Pointer[func(Call)]
+
+// SetCallLogger registers a function told about every request sent to
+// Spotify. It runs on the request's goroutine, so it must not block.
+func SetCallLogger(log func(Call)) {
+	callLogger.Store(&log)
+}
+
+// Payload is a raw response body kept by the payload capturer
+type Payload struct {
+	Endpoint  string    `json:"endpoint"`
+	Status    int       `json:"status"`
+	At        time.Time `json:"at"`
+	Truncated bool      `json:"truncated"`
+	Body      []byte    `json:"-"`
+}
+
+// payloadCapture is the registered capturer: which endpoints it wants, how
+// much of a body to keep, and where to hand payloads
+type payloadCapture struct {
+	wants    func(endpoint string) bool
+	maxBytes int
+	capture  func(Payload)
+}
+
+var payloadCapturer atomic.Pointer[payloadCapture]
+
+// SetPayloadCapture registers a function handed the response bodies of the
+// endpoints wants picks, cut to maxBytes, for debugging. The caller still
+// reads the whole body. capture runs on the request's goroutine, so it must
+// not block.
+func SetPayloadCapture(wants func(endpoint string) bool, maxBytes int, capture func(Payload)) {
+	payloadCapturer.Store(&payloadCapture{wants: wants, maxBytes: maxBytes, capture: capture})
+}
+
+// capturePayload reads res's body for the capturer, if it wants endpoint,
+// and puts it back for the caller
+func capturePayload(res *http.Response, endpoint string, at time.Time) {
+	pc := payloadCapturer.Load()
+	// Token responses carry credentials, so they're never captured
+	if pc == nil || endpoint == "token" || !pc.wants(endpoint) {
+		return
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	p := Payload{Endpoint: endpoint, Status: res.StatusCode, At: at, Body: body}
+	if len(body) > pc.maxBytes {
+		p.Body, p.Truncated = body[:pc.maxBytes], true
+	}
+	pc.capture(p)
+}
+
+// send waits for the request budget, then sends req and records metrics.
+// When req's context ends while waiting, the request isn't sent.
+func send(req *http.Request, endpoint string) (*http.Response, error) {
+	if err := sharedBudget().WaitContext(req.Context()); err != nil {
+		return nil, err
+	}
+	requestCount.Add(1)
+
+	start := time.Now()
+	res, err := sharedClient().Do(req)
+	call := Call{Endpoint: endpoint, Duration: time.Since(start), At: start}
+	if log := callLogger.Load(); log != nil {
+		defer func() { (*log)(call) }()
+	}
+	metrics.SpotifyRequestDuration.WithLabelValues(endpoint).Observe(call.Duration.Seconds())
+	if err != nil {
+		metrics.SpotifyRequests.WithLabelValues(endpoint, "error").Inc()
+		return nil, err
+	}
+	call.Status = res.StatusCode
+	metrics.SpotifyRequests.WithLabelValues(endpoint, strconv.Itoa(res.StatusCode)).Inc()
+	if res.StatusCode == http.StatusTooManyRequests {
+		rateLimitedCount.Add(1)
+		metrics.SpotifyRateLimited.WithLabelValues(endpoint).Inc()
+		if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			call.RetryAfter = time.Duration(secs) * time.Second
+		}
+	}
+	capturePayload(res, endpoint, start)
+	return res, nil
+}
+
+// RequiredScopes are the scopes collection can't work without
+var RequiredScopes = []string{"user-read-recently-played", "user-library-read"}
+
+// TokenGrant is what exchanging a refresh token returns. RefreshToken is set
+// only when Spotify rotated it; Scopes are the scopes the user granted.
+type TokenGrant struct {
+	AccessToken  string
+	RefreshToken *string
+	Scopes       []string
+}
+
+// MissingScopes returns the RequiredScopes not among granted
+func MissingScopes(granted []string) []string {
+	var missing []string
+	for _, want := range RequiredScopes {
+		if !slices.Contains(granted, want) {
+			missing = append(missing, want)
+		}
+	}
+	return missing
+}
+
+// ExchangeRefreshToken gets an access token and the granted scopes for a
+// refresh token; it may also return a new refresh token
+func ExchangeRefreshToken(ctx context.Context, refreshToken string) (TokenGrant, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", refreshToken)
 
-	req, _ := http.NewRequest("POST", "https://accounts.spotify.com/api/token",
+	req, _ := http.NewRequestWithContext(ctx, "POST", "https://accounts.spotify.com/api/token",
 		strings.NewReader(data.Encode()))
 	basic := base64.StdEncoding.EncodeToString([]byte(
 		os.Getenv("SPOTIFY_CLIENT_ID") + ":" + os.Getenv("SPOTIFY_CLIENT_SECRET")))
 	req.Header.Set("Authorization", "Basic "+basic)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := do(req, "token")
 	if err != nil {
-		return
+		return TokenGrant{}, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(res.Body)
-		err = fmt.Errorf("refresh failed: %s - %s", res.Status, string(bodyBytes))
-		return
+		err = fmt.Errorf("refresh failed: %w", statusError(res, "token"))
+		// invalid_grant (400) or bad client credentials (401): re-auth is needed
+		if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized {
+			err = fmt.Errorf("%w: %w", ErrUnauthorized, err)
+		}
+		return TokenGrant{}, err
 	}
 
 	var body struct {
 		AccessToken  string `json:"access_token"`
 		RefreshToken string `json:"refresh_token,omitempty"`
+		Scope        string `json:"scope"`
 	}
 	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
-		return
+		return TokenGrant{}, err
 	}
 
-	accessToken = body.AccessToken
-	// Only set newRefreshTok if Spotify explicitly returns it
+	grant := TokenGrant{AccessToken: body.AccessToken, Scopes: strings.Fields(body.Scope)}
+	// Only set RefreshToken if Spotify explicitly returns it
 	if body.RefreshToken != "" {
-		newRefreshTok = &body.RefreshToken
+		grant.RefreshToken = &body.RefreshToken
+	}
+	return grant, nil
+}
+
+// refreshes access_token; may return a new refresh_token
+func RefreshAccessToken(ctx context.Context, refreshToken string) (accessToken string, newRefreshTok *string, err error) {
+	grant, err := ExchangeRefreshToken(ctx, refreshToken)
+	return grant.AccessToken, grant.RefreshToken, err
+}
+
+/* ─── profile ─────────────────────────────────────────────────── */
+
+// UserProfile is the account a token belongs to. Product is the
+// subscription tier: premium, free or open.
+type UserProfile struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Country     string `json:"country"`
+	Product     string `json:"product"`
+}
+
+// GetCurrentUserProfile returns the token's account via /v1/me. Country and
+// product are empty unless the token has the user-read-private scope.
+func GetCurrentUserProfile(ctx context.Context, accessToken string) (*UserProfile, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/me", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "me")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spotify failed to get the user profile: %w", statusError(res, "me"))
+	}
+
+	var profile UserProfile
+	if err := json.NewDecoder(res.Body).Decode(&profile); err != nil {
+		return nil, err
 	}
-	return
+	return &profile, nil
 }
 
 /* ─── recently‑played ─────────────────────────────────────────── */
@@ -63,15 +443,26 @@ type PlayedItem struct {
 		Name       string `json:"name"`
 		DurationMs int    `json:"duration_ms"`
 		Album      struct {
-			Name   string       `json:"name"`
-			Images []AlbumImage `json:"images"`
+			Name                 string       `json:"name"`
+			Images               []AlbumImage `json:"images"`
+			ReleaseDate          string       `json:"release_date"`
+			ReleaseDatePrecision string       `json:"release_date_precision"`
 		} `json:"album"`
 		Artists []struct {
 			ID   string
 			Name string
 		} `json:"artists"`
+		TrackMetadata
 	} `json:"track"`
-	PlayedAt time.Time `json:"played_at"`
+	PlayedAt time.Time        `json:"played_at"`
+	Context  *PlaybackContext `json:"context"` // nil when played outside a playlist/album/artist
+}
+
+// PlaybackContext is what a track was played from
+type PlaybackContext struct {
+	Type string `json:"type"` // playlist, album, artist, show, collection
+	URI  string `json:"uri"`
+	Href string `json:"href"`
 }
 
 type AlbumImage struct {
@@ -99,6 +490,10 @@ type Artist struct {
 		Height int    `json:"height"`
 		Width  int    `json:"width"`
 	}
+	Popularity int `json:"popularity"`
+	Followers  struct {
+		Total int `json:"total"`
+	} `json:"followers"`
 }
 
 type TrackDetails struct {
@@ -110,9 +505,33 @@ type TrackDetails struct {
 		Name string `json:"name"`
 	} `json:"artists"`
 	Album struct {
-		Name   string       `json:"name"`
-		Images []AlbumImage `json:"images"`
+		Name                 string       `json:"name"`
+		Images               []AlbumImage `json:"images"`
+		ReleaseDate          string       `json:"release_date"`
+		ReleaseDatePrecision string       `json:"release_date_precision"`
+		Artists              []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"artists"`
 	} `json:"album"`
+	TrackMetadata
+}
+
+// TrackMetadata is the catalogue metadata shared by every full track object
+type TrackMetadata struct {
+	ExternalIDs      ExternalIDs `json:"external_ids"`
+	Explicit         bool        `json:"explicit"`
+	DiscNumber       int         `json:"disc_number"`
+	TrackNumber      int         `json:"track_number"`
+	AvailableMarkets []string    `json:"available_markets"`
+}
+
+// ExternalIDs are the industry identifiers Spotify attaches to a track.
+// The ISRC is shared by the same recording across singles, albums and re-releases.
+type ExternalIDs struct {
+	ISRC string `json:"isrc"`
+	EAN  string `json:"ean"`
+	UPC  string `json:"upc"`
 }
 
 // user saved tracks
@@ -135,11 +554,14 @@ type Track struct {
 
 	Name       string `json:"name"`
 	Popularity int    `json:"popularity"`
+	DurationMs int    `json:"duration_ms"`
 
 	Artists []SimplifiedArtist
+	TrackMetadata
 }
 
 type Album struct {
+	ID                   string             `json:"id"`
 	AlbumType            string             `json:"album_type"`
 	TotalTracks          int                `json:"total_tracks"`
 	Images               []AlbumImage       `json:"images"`
@@ -160,54 +582,184 @@ type SimplifiedArtist struct {
 
 // struct for currently playing
 type CurrentlyPlaying struct {
-	ID         string      `json:"id"`
-	Timestamp  int         `json:"timestamp"`
-	ProgressMS int         `json:"progress_ms"`
-	Item       TrackObject `json:"item"`
+	ID                   string           `json:"id"`
+	Timestamp            int              `json:"timestamp"`
+	ProgressMS           int              `json:"progress_ms"`
+	IsPlaying            bool             `json:"is_playing"`
+	CurrentlyPlayingType string           `json:"currently_playing_type"`
+	Context              *PlaybackContext `json:"context"`
+	Item                 TrackObject      `json:"item"`
+
+	// Only set by /v1/me/player
+	Device       *Device `json:"device,omitempty"`
+	ShuffleState bool    `json:"shuffle_state"`
+	RepeatState  string  `json:"repeat_state,omitempty"` // off, track, context
+}
+
+// NotPlaying is the state GetCurrentlyListening reports when the player has
+// no active session (Spotify answers 204)
+func NotPlaying() *CurrentlyPlaying {
+	return &CurrentlyPlaying{}
+}
+
+// HasTrack reports whether the state carries a track; it doesn't for
+// NotPlaying, podcast episodes and ads
+func (p *CurrentlyPlaying) HasTrack() bool {
+	return p != nil && p.Item.ID != ""
 }
 
 type TrackObject struct {
-	Album      Album  `json:"album"`
-	Name       string `json:"name"`
-	Popularity int    `json:"popularity"`
+	ID         string             `json:"id"`
+	Album      Album              `json:"album"`
+	Artists    []SimplifiedArtist `json:"artists"`
+	Name       string             `json:"name"`
+	DurationMs int                `json:"duration_ms"`
+	Popularity int                `json:"popularity"`
+}
+
+// Device is the player a playback state belongs to
+type Device struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	IsActive      bool   `json:"is_active"`
+	VolumePercent *int   `json:"volume_percent"`
+}
+
+func GetRecentlyPlayed(ctx context.Context, accessToken string, limit int) ([]PlayedItem, error) {
+	body, err := getRecentlyPlayedPage(ctx, accessToken, url.Values{"limit": {strconv.Itoa(limit)}})
+	if err != nil {
+		return nil, err
+	}
+	return body.Items, nil
 }
 
-func GetRecentlyPlayed(accessToken string, limit int) ([]PlayedItem, error) {
-	req, _ := http.NewRequest("GET",
-		"https://api.spotify.com/v1/me/player/recently-played?limit="+strconv.Itoa(limit),
-		nil)
+// maxRecentlyPlayedPages bounds how far GetRecentlyPlayedAfter follows cursors
+const maxRecentlyPlayedPages = 20
+
+// GetRecentlyPlayedAfter returns only plays after the given time, newest
+// first, following the after cursor while full pages come back. A zero time
+// fetches the latest page.
+func GetRecentlyPlayedAfter(ctx context.Context, accessToken string, after time.Time) ([]PlayedItem, error) {
+	if after.IsZero() {
+		return GetRecentlyPlayed(ctx, accessToken, 50)
+	}
+
+	var items []PlayedItem
+	cursor := strconv.FormatInt(after.UnixMilli(), 10)
+	for page := 0; page < maxRecentlyPlayedPages; page++ {
+		body, err := getRecentlyPlayedPage(ctx, accessToken, url.Values{"limit": {"50"}, "after": {cursor}})
+		if err != nil {
+			return nil, err
+		}
+		// Each page is newer than the last; keep the result newest first
+		items = append(body.Items, items...)
+		if len(body.Items) < 50 || body.Next == nil || body.Cursors.After == nil || *body.Cursors.After == cursor {
+			break
+		}
+		cursor = *body.Cursors.After
+	}
+	return items, nil
+}
+
+// GetRecentlyPlayedSince walks back from the latest play with the before
+// cursor and returns every play after since, newest first. 429s back off on
+// the shared budget and retry. Spotify only keeps roughly the last 50 plays,
+// so older history needs an export import instead.
+func GetRecentlyPlayedSince(ctx context.Context, accessToken string, since time.Time) ([]PlayedItem, error) {
+	var items []PlayedItem
+	err := WalkRecentlyPlayedSince(ctx, accessToken, since, func(page []PlayedItem) error {
+		items = append(items, page...)
+		return nil
+	})
+	return items, err
+}
+
+// WalkRecentlyPlayedSince is GetRecentlyPlayedSince page by page: fn gets
+// each page's plays after since, newest first, as soon as it arrives. An
+// error from fn stops the walk and is returned.
+func WalkRecentlyPlayedSince(ctx context.Context, accessToken string, since time.Time, fn func(page []PlayedItem) error) error {
+	params := url.Values{"limit": {"50"}}
+	for page := 0; page < maxRecentlyPlayedPages; page++ {
+		body, err := getRecentlyPlayedPageWithRetry(ctx, accessToken, params, 3)
+		if err != nil {
+			return err
+		}
+
+		reachedSince := false
+		items := make([]PlayedItem, 0, len(body.Items))
+		for _, it := range body.Items {
+			if !it.PlayedAt.After(since) {
+				reachedSince = true
+				break
+			}
+			items = append(items, it)
+		}
+		if len(items) > 0 {
+			if err := fn(items); err != nil {
+				return err
+			}
+		}
+		if reachedSince || len(body.Items) == 0 || body.Next == nil || body.Cursors.Before == nil {
+			break
+		}
+		params.Set("before", *body.Cursors.Before)
+	}
+	return nil
+}
+
+// getRecentlyPlayedPageWithRetry retries a page up to maxRetries times on 429
+func getRecentlyPlayedPageWithRetry(ctx context.Context, accessToken string, params url.Values, maxRetries int) (*RecentlyPlayedResponse, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := getRecentlyPlayedPage(ctx, accessToken, params)
+		if err == nil || !IsRateLimited(err) || attempt == maxRetries {
+			return body, err
+		}
+		if err := sleep(ctx, sharedBudget().HandleRateLimit(utils.RetryAfterHeader(err), attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getRecentlyPlayedPage fetches one page of /me/player/recently-played
+func getRecentlyPlayedPage(ctx context.Context, accessToken string, params url.Values) (*RecentlyPlayedResponse, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET",
+		"https://api.spotify.com/v1/me/player/recently-played?"+params.Encode(), nil)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := do(req, "recently_played")
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, errors.New("spotify: " + res.Status)
+		return nil, statusError(res, "recently_played")
 	}
 	var body RecentlyPlayedResponse
 	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
 		return nil, err
 	}
-	return body.Items, nil
+	return &body, nil
 }
 
 // gets the artist by ID
-func GetArtistById(accessToken, artistID string) (*Artist, error) {
-	req, _ := http.NewRequest("GET", "https://api.spotify.com/v1/artists/"+artistID, nil)
+func GetArtistById(ctx context.Context, accessToken, artistID string) (*Artist, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/artists/"+artistID, nil)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := do(req, "artist")
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("spotify failed to get artist %s: %s - %s", artistID, res.Status, string(body))
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusBadRequest:
+		return nil, fmt.Errorf("%w: artist %s", ErrNotFound, artistID)
+	default:
+		return nil, fmt.Errorf("spotify failed to get artist %s: %w", artistID, statusError(res, "artist"))
 	}
 
 	var artist Artist
@@ -219,42 +771,685 @@ func GetArtistById(accessToken, artistID string) (*Artist, error) {
 	return &artist, nil
 }
 
-// gets single track
-func GetTrack(accessToken, trackID string) (*TrackDetails, error) {
-	req, _ := http.NewRequest("GET",
-		"https://api.spotify.com/v1/tracks/"+trackID, nil)
+// MaxArtistsPerRequest is the most IDs /v1/artists accepts in one call
+const MaxArtistsPerRequest = 50
 
+// GetArtistsByIds fetches up to 50 artists in one call via /v1/artists?ids=.
+// Unknown IDs are dropped from the result.
+func GetArtistsByIds(ctx context.Context, accessToken string, artistIDs []string) ([]Artist, error) {
+	if len(artistIDs) == 0 {
+		return nil, nil
+	}
+	if len(artistIDs) > MaxArtistsPerRequest {
+		return nil, fmt.Errorf("spotify: at most %d artist IDs per request, got %d", MaxArtistsPerRequest, len(artistIDs))
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET",
+		"https://api.spotify.com/v1/artists?ids="+url.QueryEscape(strings.Join(artistIDs, ",")), nil)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := do(req, "artists")
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("spotify failed to get track id %s: %s", trackID, res.Status)
+		return nil, fmt.Errorf("spotify failed to get %d artists: %w", len(artistIDs), statusError(res, "artists"))
+	}
 
+	var body struct {
+		Artists []*Artist `json:"artists"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
 	}
 
+	artists := make([]Artist, 0, len(body.Artists))
+	for _, a := range body.Artists {
+		if a != nil {
+			artists = append(artists, *a)
+		}
+	}
+	return artists, nil
+}
+
+// gets single track
+func GetTrack(ctx context.Context, accessToken, trackID string) (*TrackDetails, error) {
 	var track TrackDetails
-	if err := json.NewDecoder(res.Body).Decode(&track); err != nil {
+	if err := getTrack(ctx, accessToken, trackID, &track); err != nil {
 		return nil, err
 	}
+	return &track, nil
+}
 
-	fmt.Println("these are the @@@TRACKD, ", track)
+// GetFullTrack gets a single track with its full album and artist objects,
+// as saved tracks list them
+func GetFullTrack(ctx context.Context, accessToken, trackID string) (*Track, error) {
+	var track Track
+	if err := getTrack(ctx, accessToken, trackID, &track); err != nil {
+		return nil, err
+	}
 	return &track, nil
+}
+
+// getTrack decodes /v1/tracks/{id} into v
+func getTrack(ctx context.Context, accessToken, trackID string, v any) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET",
+		"https://api.spotify.com/v1/tracks/"+trackID, nil)
+
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "track")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusBadRequest:
+		return fmt.Errorf("%w: track %s", ErrNotFound, trackID)
+	default:
+		return fmt.Errorf("spotify failed to get track id %s: %w", trackID, statusError(res, "track"))
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// TrackTTL is how long GetCachedTrack serves a track before asking Spotify
+// again; catalogue metadata rarely changes
+const TrackTTL = 24 * time.Hour
+
+// maxCachedTracks bounds the track cache; past it the oldest are dropped
+const maxCachedTracks = 5000
 
+var trackCache = newTTLCache[*TrackDetails](TrackTTL, maxCachedTracks)
+
+// GetCachedTrack is GetTrack cached per track for TrackTTL
+func GetCachedTrack(ctx context.Context, accessToken, trackID string) (*TrackDetails, error) {
+	if cached, ok := trackCache.get(trackID); ok {
+		return cached, nil
+	}
+
+	track, err := GetTrack(ctx, accessToken, trackID)
+	if err != nil {
+		return nil, err
+	}
+	trackCache.put(trackID, track)
+	return track, nil
+}
+
+// GetTracksByIds fetches up to 50 tracks in one call. Tracks Spotify no
+// longer knows are left out of the result.
+func GetTracksByIds(ctx context.Context, accessToken string, trackIDs []string) ([]TrackDetails, error) {
+	if len(trackIDs) == 0 {
+		return nil, nil
+	}
+	if len(trackIDs) > 50 {
+		return nil, fmt.Errorf("spotify allows max 50 tracks per request, got %d", len(trackIDs))
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET",
+		"https://api.spotify.com/v1/tracks?ids="+strings.Join(trackIDs, ","), nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "tracks")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spotify failed to get tracks: %w", statusError(res, "tracks"))
+	}
+
+	var body struct {
+		Tracks []*TrackDetails `json:"tracks"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	tracks := make([]TrackDetails, 0, len(body.Tracks))
+	for _, t := range body.Tracks {
+		if t != nil {
+			tracks = append(tracks, *t)
+		}
+	}
+	return tracks, nil
+}
+
+// AudioFeatures are Spotify's per-track audio analysis values
+type AudioFeatures struct {
+	ID               string  `json:"id"`
+	Danceability     float64 `json:"danceability"`
+	Energy           float64 `json:"energy"`
+	Valence          float64 `json:"valence"`
+	Tempo            float64 `json:"tempo"`
+	Acousticness     float64 `json:"acousticness"`
+	Instrumentalness float64 `json:"instrumentalness"`
+	Speechiness      float64 `json:"speechiness"`
+	Liveness         float64 `json:"liveness"`
+	Loudness         float64 `json:"loudness"`
+	Key              int     `json:"key"`
+	Mode             int     `json:"mode"`
+	TimeSignature    int     `json:"time_signature"`
+}
+
+// GetAudioFeatures fetches audio features for up to 100 tracks. Tracks without
+// features are left out. Spotify answers 403 for apps registered after it
+// restricted the endpoint (Nov 2024).
+func GetAudioFeatures(ctx context.Context, accessToken string, trackIDs []string) ([]AudioFeatures, error) {
+	if len(trackIDs) == 0 {
+		return nil, nil
+	}
+	if len(trackIDs) > 100 {
+		return nil, fmt.Errorf("spotify allows max 100 tracks per audio-features request, got %d", len(trackIDs))
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET",
+		"https://api.spotify.com/v1/audio-features?ids="+strings.Join(trackIDs, ","), nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "audio_features")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("audio features are not available to this app: %w", statusError(res, "audio_features"))
+	default:
+		return nil, fmt.Errorf("spotify failed to get audio features: %w", statusError(res, "audio_features"))
+	}
+
+	var body struct {
+		AudioFeatures []*AudioFeatures `json:"audio_features"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	features := make([]AudioFeatures, 0, len(body.AudioFeatures))
+	for _, f := range body.AudioFeatures {
+		if f != nil {
+			features = append(features, *f)
+		}
+	}
+	return features, nil
+}
+
+/* ─── artist profile ─────────────────────────────────────────── */
+
+// ArtistProfile is an artist plus their most popular tracks
+type ArtistProfile struct {
+	Artist
+	TopTracks []Track
+	FetchedAt time.Time
+}
+
+// ArtistProfileTTL is how long GetArtistProfile serves a profile before
+// asking Spotify again; followers and top tracks don't move much in an hour
+const ArtistProfileTTL = time.Hour
+
+// maxArtistProfiles bounds the profile cache; past it the oldest are dropped
+const maxArtistProfiles = 1000
+
+var artistProfiles = newTTLCache[*ArtistProfile](ArtistProfileTTL, maxArtistProfiles)
+
+// GetArtistProfile returns the artist and their top tracks, cached per artist
+// for ArtistProfileTTL so repeated page views cost no requests
+func GetArtistProfile(ctx context.Context, accessToken, artistID string) (*ArtistProfile, error) {
+	if cached, ok := artistProfiles.get(artistID); ok {
+		return cached, nil
+	}
+
+	artist, err := GetArtistById(ctx, accessToken, artistID)
+	if err != nil {
+		return nil, err
+	}
+	top, err := GetArtistTopTracks(ctx, accessToken, artistID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &ArtistProfile{Artist: *artist, TopTracks: top, FetchedAt: time.Now()}
+	artistProfiles.put(artistID, profile)
+	return profile, nil
+}
+
+// GetArtistTopTracks returns the artist's (up to 10) most popular tracks in
+// the account's market
+func GetArtistTopTracks(ctx context.Context, accessToken, artistID string) ([]Track, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/artists/"+artistID+"/top-tracks?market=from_token", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "artist_top_tracks")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusBadRequest:
+		return nil, fmt.Errorf("%w: artist %s", ErrNotFound, artistID)
+	default:
+		return nil, fmt.Errorf("spotify failed to get top tracks for %s: %w", artistID, statusError(res, "artist_top_tracks"))
+	}
+
+	var body struct {
+		Tracks []Track `json:"tracks"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Tracks, nil
+}
+
+// GetRelatedArtists returns (up to 20) artists similar to artistID, by
+// listener overlap
+func GetRelatedArtists(ctx context.Context, accessToken, artistID string) ([]Artist, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/artists/"+artistID+"/related-artists", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "related_artists")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("%w: related artists: %w", ErrUnavailable, statusError(res, "related_artists"))
+	case http.StatusNotFound, http.StatusBadRequest:
+		return nil, fmt.Errorf("%w: artist %s", ErrNotFound, artistID)
+	default:
+		return nil, fmt.Errorf("spotify failed to get artists related to %s: %w", artistID, statusError(res, "related_artists"))
+	}
+
+	var body struct {
+		Artists []Artist `json:"artists"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Artists, nil
+}
+
+// GetArtistAlbums returns the first page (limit, max 50) of the artist's own
+// albums and singles, leaving out compilations and appearances
+func GetArtistAlbums(ctx context.Context, accessToken, artistID string, limit int) ([]Album, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(
+		"https://api.spotify.com/v1/artists/%s/albums?include_groups=album,single&market=from_token&limit=%d",
+		artistID, limit), nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "artist_albums")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusBadRequest:
+		return nil, fmt.Errorf("%w: artist %s", ErrNotFound, artistID)
+	default:
+		return nil, fmt.Errorf("spotify failed to get albums for %s: %w", artistID, statusError(res, "artist_albums"))
+	}
+
+	var body struct {
+		Items []Album `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Items, nil
+}
+
+/* ─── search ─────────────────────────────────────────────────── */
+
+// SearchResponse is the /v1/search body; sections not requested stay empty
+type SearchResponse struct {
+	Tracks struct {
+		Items []TrackDetails `json:"items"`
+		Total int            `json:"total"`
+	} `json:"tracks"`
+	Artists struct {
+		Items []Artist `json:"items"`
+		Total int      `json:"total"`
+	} `json:"artists"`
+}
+
+// Search queries Spotify's catalogue for the given types (track, artist, ...)
+func Search(ctx context.Context, accessToken, query string, types []string, limit int) (*SearchResponse, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("type", strings.Join(types, ","))
+	params.Set("limit", strconv.Itoa(limit))
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/search?"+params.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "search")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spotify search failed for %q: %w", query, statusError(res, "search"))
+	}
+
+	var body SearchResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return &body, nil
+}
+
+// SearchTracks runs a track search, e.g. `track:"Dark Angel" artist:"Provoker"`
+func SearchTracks(ctx context.Context, accessToken, query string, limit int) ([]TrackDetails, error) {
+	body, err := Search(ctx, accessToken, query, []string{"track"}, limit)
+	if err != nil {
+		return nil, err
+	}
+	return body.Tracks.Items, nil
 }
 
 // get User saved tracks
 
-func GetUserSavedTracksPage(accessToken string, offset, limit int) (*UserSavedTracks, error) {
+// GetNewReleases returns albums from /v1/browse/new-releases (max 50)
+func GetNewReleases(ctx context.Context, accessToken string, limit int) ([]Album, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET",
+		fmt.Sprintf("https://api.spotify.com/v1/browse/new-releases?limit=%d", limit), nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "new_releases")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spotify failed to get new releases: %w", statusError(res, "new_releases"))
+	}
+
+	var body struct {
+		Albums struct {
+			Items []Album `json:"items"`
+		} `json:"albums"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Albums.Items, nil
+}
+
+// RecommendationSeeds are the inputs to /v1/recommendations. Tracks, artists
+// and genres together must be 1-5 seeds. Targets maps tunable attributes
+// without the target_ prefix (e.g. "energy", "valence") to their value.
+type RecommendationSeeds struct {
+	Tracks  []string
+	Artists []string
+	Genres  []string
+	Targets map[string]float64
+}
+
+// GetRecommendations returns up to limit (max 100) tracks for the given seeds.
+// Like audio features, Spotify answers 403/404 for apps registered after it
+// restricted the endpoint (Nov 2024).
+func GetRecommendations(ctx context.Context, accessToken string, seeds RecommendationSeeds, limit int) ([]Track, error) {
+	if n := len(seeds.Tracks) + len(seeds.Artists) + len(seeds.Genres); n == 0 || n > 5 {
+		return nil, fmt.Errorf("spotify recommendations need 1-5 seeds, got %d", n)
+	}
+
+	params := url.Values{}
+	if len(seeds.Tracks) > 0 {
+		params.Set("seed_tracks", strings.Join(seeds.Tracks, ","))
+	}
+	if len(seeds.Artists) > 0 {
+		params.Set("seed_artists", strings.Join(seeds.Artists, ","))
+	}
+	if len(seeds.Genres) > 0 {
+		params.Set("seed_genres", strings.Join(seeds.Genres, ","))
+	}
+	for attr, v := range seeds.Targets {
+		params.Set("target_"+attr, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	params.Set("limit", strconv.Itoa(limit))
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/recommendations?"+params.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "recommendations")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusForbidden, http.StatusNotFound:
+		return nil, fmt.Errorf("recommendations are not available to this app: %w", statusError(res, "recommendations"))
+	default:
+		return nil, fmt.Errorf("spotify failed to get recommendations: %w", statusError(res, "recommendations"))
+	}
+
+	var body struct {
+		Tracks []Track `json:"tracks"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Tracks, nil
+}
+
+/* ─── playlists ─────────────────────────────────────────────── */
+
+// Writing playlists needs the playlist-modify-private scope (and
+// playlist-modify-public for public ones) on the stored refresh token.
+
+// MaxPlaylistTracksPerRequest is the most URIs one add-items call accepts
+const MaxPlaylistTracksPerRequest = 100
+
+// Playlist is a playlist created on the user's account
+type Playlist struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	URI          string `json:"uri"`
+	ExternalURLs struct {
+		Spotify string `json:"spotify"`
+	} `json:"external_urls"`
+}
+
+// GetCurrentUserID returns the Spotify user id the access token belongs to
+func GetCurrentUserID(ctx context.Context, accessToken string) (string, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/me", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "me")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return "", ErrUnauthorized
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("spotify failed to get current user: %w", statusError(res, "me"))
+	}
+
+	var me struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&me); err != nil {
+		return "", err
+	}
+	return me.ID, nil
+}
+
+// CreatePlaylist creates an empty playlist on the user's account
+func CreatePlaylist(ctx context.Context, accessToken, userID, name, description string, public bool) (*Playlist, error) {
+	payload, _ := json.Marshal(map[string]any{
+		"name":        name,
+		"description": description,
+		"public":      public,
+	})
+	req, _ := http.NewRequestWithContext(ctx, "POST",
+		"https://api.spotify.com/v1/users/"+url.PathEscape(userID)+"/playlists", bytes.NewReader(payload))
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := do(req, "create_playlist")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("re-authorize with the playlist-modify-private/public scopes: %w", statusError(res, "create_playlist"))
+	default:
+		return nil, fmt.Errorf("spotify failed to create playlist: %w", statusError(res, "create_playlist"))
+	}
+
+	var playlist Playlist
+	if err := json.NewDecoder(res.Body).Decode(&playlist); err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// AddTracksToPlaylist appends tracks in order, MaxPlaylistTracksPerRequest at
+// a time. It returns how many were added before any error.
+func AddTracksToPlaylist(ctx context.Context, accessToken, playlistID string, trackIDs []string) (int, error) {
+	added := 0
+	for start := 0; start < len(trackIDs); start += MaxPlaylistTracksPerRequest {
+		batch := trackIDs[start:min(start+MaxPlaylistTracksPerRequest, len(trackIDs))]
+		uris := make([]string, len(batch))
+		for i, id := range batch {
+			uris[i] = "spotify:track:" + id
+		}
+		payload, _ := json.Marshal(map[string]any{"uris": uris})
+		req, _ := http.NewRequestWithContext(ctx, "POST",
+			"https://api.spotify.com/v1/playlists/"+url.PathEscape(playlistID)+"/tracks", bytes.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := do(req, "add_playlist_tracks")
+		if err != nil {
+			return added, err
+		}
+		switch res.StatusCode {
+		case http.StatusOK, http.StatusCreated:
+			res.Body.Close()
+		case http.StatusUnauthorized:
+			res.Body.Close()
+			return added, ErrUnauthorized
+		default:
+			err := statusError(res, "add_playlist_tracks")
+			res.Body.Close()
+			return added, fmt.Errorf("spotify failed to add tracks to playlist %s: %w", playlistID, err)
+		}
+		added += len(batch)
+	}
+	return added, nil
+}
+
+/* ─── library ───────────────────────────────────────────────── */
+
+// Saving and removing tracks needs the user-library-modify scope on the
+// stored refresh token; without it Spotify answers 403 and ErrMissingScopes
+// is returned.
+
+// MaxSavedTracksPerRequest is the most ids one save or remove call accepts
+const MaxSavedTracksPerRequest = 50
+
+// SaveTracks adds tracks to the user's Liked Songs, MaxSavedTracksPerRequest
+// at a time. It returns how many were saved before any error.
+func SaveTracks(ctx context.Context, accessToken string, trackIDs []string) (int, error) {
+	return changeSavedTracks(ctx, accessToken, http.MethodPut, "save_tracks", trackIDs)
+}
+
+// SaveTrack adds one track to the user's Liked Songs
+func SaveTrack(ctx context.Context, accessToken, trackID string) error {
+	_, err := SaveTracks(ctx, accessToken, []string{trackID})
+	return err
+}
+
+// RemoveSavedTrack removes one track from the user's Liked Songs. Removing a
+// track that isn't saved succeeds.
+func RemoveSavedTrack(ctx context.Context, accessToken, trackID string) error {
+	_, err := changeSavedTracks(ctx, accessToken, http.MethodDelete, "remove_saved_tracks", []string{trackID})
+	return err
+}
+
+// changeSavedTracks sends PUT or DELETE /v1/me/tracks for trackIDs in
+// batches, returning how many went through before any error
+func changeSavedTracks(ctx context.Context, accessToken, method, endpoint string, trackIDs []string) (int, error) {
+	done := 0
+	for start := 0; start < len(trackIDs); start += MaxSavedTracksPerRequest {
+		batch := trackIDs[start:min(start+MaxSavedTracksPerRequest, len(trackIDs))]
+		req, _ := http.NewRequestWithContext(ctx, method,
+			"https://api.spotify.com/v1/me/tracks?ids="+url.QueryEscape(strings.Join(batch, ",")), nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		res, err := do(req, endpoint)
+		if err != nil {
+			return done, err
+		}
+		var statusErr *SpotifyError
+		if res.StatusCode >= 300 {
+			statusErr = statusError(res, endpoint)
+		}
+		res.Body.Close()
+
+		switch res.StatusCode {
+		case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		case http.StatusUnauthorized:
+			return done, ErrUnauthorized
+		case http.StatusForbidden:
+			return done, fmt.Errorf("%w: user-library-modify", ErrMissingScopes)
+		case http.StatusNotFound, http.StatusBadRequest:
+			return done, fmt.Errorf("%w: tracks %s", ErrNotFound, strings.Join(batch, ","))
+		default:
+			return done, fmt.Errorf("spotify failed to update saved tracks: %w", statusErr)
+		}
+		done += len(batch)
+	}
+	return done, nil
+}
+
+func GetUserSavedTracksPage(ctx context.Context, accessToken string, offset, limit int) (*UserSavedTracks, error) {
 	url := fmt.Sprintf("https://api.spotify.com/v1/me/tracks?offset=%d&limit=%d", offset, limit)
-	req, _ := http.NewRequest("GET", url, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := do(req, "saved_tracks")
 	if err != nil {
 		return nil, err
 	}
@@ -266,15 +1461,17 @@ func GetUserSavedTracksPage(accessToken string, offset, limit int) (*UserSavedTr
 			retrySeconds, err := strconv.Atoi(retryAfter)
 			if err == nil {
 				fmt.Printf("Rate limited. Retrying after %d seconds...\n", retrySeconds)
-				time.Sleep(time.Duration(retrySeconds+1) * time.Second)
-				return GetUserSavedTracksPage(accessToken, offset, limit)
+				if err := sleep(ctx, time.Duration(retrySeconds+1)*time.Second); err != nil {
+					return nil, err
+				}
+				return GetUserSavedTracksPage(ctx, accessToken, offset, limit)
 			}
 		}
-		return nil, fmt.Errorf("spotify: 429 Too Many Requests (no Retry-After)")
+		return nil, statusError(res, "saved_tracks")
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("spotify failed to get saved tracks at offset %d", offset)
+		return nil, fmt.Errorf("spotify failed to get saved tracks at offset %d: %w", offset, statusError(res, "saved_tracks"))
 	}
 
 	var page UserSavedTracks
@@ -286,29 +1483,88 @@ func GetUserSavedTracksPage(accessToken string, offset, limit int) (*UserSavedTr
 }
 
 // function to get currently listening
-func GetCurrentlyListening(accessToken string) (*CurrentlyPlaying, error) {
-	req, _ := http.NewRequest("GET",
-		"https://api.spotify.com/v1/me/player/currently-playing", nil)
-
+// GetPlaybackState returns the full player state from /v1/me/player, including
+// the device. Returns nil, nil when nothing is playing (Spotify answers 204).
+func GetPlaybackState(ctx context.Context, accessToken string) (*CurrentlyPlaying, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/me/player", nil)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	res, err := http.DefaultClient.Do(req)
-
+	res, err := do(req, "player")
 	if err != nil {
-		fmt.Printf("Error getting currently listening to")
+		return nil, err
 	}
-
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get currently listening to tracks")
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	default:
+		return nil, statusError(res, "player")
+	}
+
+	var state CurrentlyPlaying
+	if err := json.NewDecoder(res.Body).Decode(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetCurrentlyListening returns what the player is doing, including its
+// device, or NotPlaying when there's no active session
+func GetCurrentlyListening(ctx context.Context, accessToken string) (*CurrentlyPlaying, error) {
+	state, err := GetPlaybackState(ctx, accessToken)
+	if err != nil {
+		return nil, err
 	}
+	if state == nil {
+		return NotPlaying(), nil
+	}
+	return state, nil
+}
+
+// Queue is the player's queue from /v1/me/player/queue: what's playing and
+// what plays next, the user's own queue first, then the context's next tracks
+type Queue struct {
+	CurrentlyPlaying *QueueItem  `json:"currently_playing"` // nil when nothing is playing
+	Queue            []QueueItem `json:"queue"`
+}
+
+// QueueItem is a queued track, or a podcast episode when Type is "episode"
+type QueueItem struct {
+	TrackObject
+	Type string `json:"type"`
+}
 
-	var currentlyPlaying CurrentlyPlaying
-	if err := json.NewDecoder(res.Body).Decode(&currentlyPlaying); err != nil {
+// GetQueue returns what's playing and queued after it. Spotify lists at most
+// 20 queued items.
+func GetQueue(ctx context.Context, accessToken string) (*Queue, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/me/player/queue", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := do(req, "queue")
+	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 
-	return &currentlyPlaying, nil
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return &Queue{}, nil
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("%w: user-read-playback-state", ErrMissingScopes)
+	default:
+		return nil, fmt.Errorf("spotify failed to get the queue: %w", statusError(res, "queue"))
+	}
 
+	var queue Queue
+	if err := json.NewDecoder(res.Body).Decode(&queue); err != nil {
+		return nil, err
+	}
+	return &queue, nil
 }
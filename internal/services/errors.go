@@ -0,0 +1,108 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody caps how much of an error response is read for its message
+const maxErrorBody = 4 << 10
+
+// SpotifyError is a non-2xx answer from Spotify. errors.Is matches it against
+// ErrRateLimited, ErrUnauthorized and ErrNotFound by status code, so callers
+// needn't look at the message.
+type SpotifyError struct {
+	Endpoint   string        // the call's label, as in the request metrics
+	StatusCode int           // HTTP status Spotify answered with
+	RetryAfter time.Duration // from a 429's Retry-After header, 0 when absent
+	Message    string        // Spotify's explanation, when it sent one
+}
+
+func (e *SpotifyError) Error() string {
+	msg := fmt.Sprintf("spotify %s: %d %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += " - " + e.Message
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrRateLimited) and friends work on a wrapped SpotifyError
+func (e *SpotifyError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// RateLimited reports whether Spotify answered 429; with RetryDelay it
+// satisfies utils.RateLimitError
+func (e *SpotifyError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// RetryDelay returns how long Spotify asked us to wait before retrying
+func (e *SpotifyError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// AsSpotifyError returns the SpotifyError err is or wraps
+func AsSpotifyError(err error) (*SpotifyError, bool) {
+	var spErr *SpotifyError
+	if errors.As(err, &spErr) {
+		return spErr, true
+	}
+	return nil, false
+}
+
+// IsRateLimited reports whether err is or wraps a 429 from Spotify
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// statusError builds the SpotifyError for a non-2xx response, reading
+// Spotify's message from the body
+func statusError(res *http.Response, endpoint string) *SpotifyError {
+	spErr := &SpotifyError{Endpoint: endpoint, StatusCode: res.StatusCode}
+	if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && secs > 0 {
+		spErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	spErr.Message = errorMessage(body)
+	return spErr
+}
+
+// errorMessage pulls the message out of a Web API error ({"error": {"message"}})
+// or an accounts error ({"error", "error_description"}), falling back to the
+// raw body
+func errorMessage(body []byte) string {
+	var api struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &api) == nil && api.Error.Message != "" {
+		return api.Error.Message
+	}
+	var accounts struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &accounts) == nil && accounts.Error != "" {
+		if accounts.Description != "" {
+			return accounts.Error + ": " + accounts.Description
+		}
+		return accounts.Error
+	}
+	return strings.TrimSpace(string(body))
+}
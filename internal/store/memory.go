@@ -0,0 +1,341 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+)
+
+// Memory is an in-memory Store for tests. It mirrors the Postgres behaviour
+// that matters to callers: duplicate plays (same track and played_at) and
+// duplicate liked tracks are ignored, and missing rows read as zero values.
+type Memory struct {
+	mu           sync.Mutex
+	refreshToken string
+	account      *repository.SpotifyAccount
+	plays        []Play
+	liked        []LikedTrack
+	artists      map[string]*models.CachedArtist
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{artists: map[string]*models.CachedArtist{}}
+}
+
+// Plays returns a copy of every stored play, oldest first
+func (m *Memory) Plays() []Play {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := append([]Play(nil), m.plays...)
+	sort.Slice(out, func(i, j int) bool { return out[i].PlayedAt.Before(out[j].PlayedAt) })
+	return out
+}
+
+// Liked returns a copy of every stored liked track
+func (m *Memory) Liked() []LikedTrack {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]LikedTrack(nil), m.liked...)
+}
+
+func (m *Memory) GetRefreshToken() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.refreshToken, nil
+}
+
+func (m *Memory) SaveRefreshToken(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshToken = token
+	return nil
+}
+
+func (m *Memory) SaveSpotifyAccount(a repository.SpotifyAccount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	a.UpdatedAt = &now
+	m.account = &a
+	return nil
+}
+
+// SpotifyAccount returns the account last saved, or nil
+func (m *Memory) SpotifyAccount() *repository.SpotifyAccount {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.account
+}
+
+func (m *Memory) LatestPlayedAt() (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latest := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, p := range m.plays {
+		if p.PlayedAt.After(latest) {
+			latest = p.PlayedAt
+		}
+	}
+	return latest, nil
+}
+
+func (m *Memory) InsertRecentlyPlayed(p Play) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.plays {
+		if existing.SpotifyID == p.SpotifyID && existing.PlayedAt.Equal(p.PlayedAt) {
+			return false, nil
+		}
+	}
+	if p.Source == "" {
+		p.Source = models.SourceCron
+	}
+	m.plays = append(m.plays, p)
+	return true, nil
+}
+
+func (m *Memory) InsertRecentlyPlayedBatch(plays []Play) ([]Play, error) {
+	var added []Play
+	for _, p := range plays {
+		inserted, err := m.InsertRecentlyPlayed(p)
+		if err != nil {
+			return nil, err
+		}
+		if inserted {
+			added = append(added, p)
+		}
+	}
+	return added, nil
+}
+
+func (m *Memory) HasPlayNear(spotifyID string, at time.Time, window time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.plays {
+		if p.SpotifyID == spotifyID && !p.PlayedAt.Before(at.Add(-window)) && !p.PlayedAt.After(at.Add(window)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) RecentlyPlayed(source string) ([]models.RecentlyPlayedTrack, error) {
+	plays := m.Plays()
+	out := make([]models.RecentlyPlayedTrack, 0, len(plays))
+	for i := len(plays) - 1; i >= 0; i-- {
+		p := plays[i]
+		if source != "" && p.Source != source {
+			continue
+		}
+		out = append(out, models.RecentlyPlayedTrack{
+			ID:            i + 1,
+			SpotifySongID: p.SpotifyID,
+			TrackName:     p.TrackName,
+			DurationMS:    p.DurationMs,
+			ArtistName:    p.ArtistName,
+			AlbumName:     p.AlbumName,
+			PlayedAt:      p.PlayedAt,
+			Source:        p.Source,
+			AlbumCoverUrl: p.AlbumCoverURL,
+			Genre:         p.Genre,
+		})
+	}
+	return out, nil
+}
+
+func (m *Memory) LatestAddedAt() (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var latest time.Time
+	for _, t := range m.liked {
+		if t.AddedAt.After(latest) {
+			latest = t.AddedAt
+		}
+	}
+	return latest, nil
+}
+
+func (m *Memory) InsertRecentlyLiked(t LikedTrack) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.liked {
+		existing := &m.liked[i]
+		if existing.SpotifyID != t.SpotifyID {
+			continue
+		}
+		if t.AddedAt.After(existing.AddedAt) {
+			existing.AddedAt = t.AddedAt
+			existing.TrackPopularity = t.TrackPopularity
+			existing.UnlikedAt = nil
+		}
+		return false, nil
+	}
+	if t.Source == "" {
+		t.Source = models.SourceCron
+	}
+	m.liked = append(m.liked, t)
+	return true, nil
+}
+
+func (m *Memory) ReconcileLiked(present []string, at time.Time) (removed, restored int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := make(map[string]bool, len(present))
+	for _, id := range present {
+		saved[id] = true
+	}
+	for i := range m.liked {
+		t := &m.liked[i]
+		switch {
+		case t.UnlikedAt == nil && !saved[t.SpotifyID]:
+			unliked := at
+			t.UnlikedAt = &unliked
+			removed++
+		case t.UnlikedAt != nil && saved[t.SpotifyID]:
+			t.UnlikedAt = nil
+			restored++
+		}
+	}
+	return removed, restored, nil
+}
+
+// AttachPlaybackState is a no-op: Memory keeps no player snapshots
+func (m *Memory) AttachPlaybackState() (int, error) {
+	return 0, nil
+}
+
+func (m *Memory) GetCachedArtist(artistID string) (*models.CachedArtist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a, ok := m.artists[artistID]; ok {
+		copied := *a
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (m *Memory) GetCachedArtists(artistIDs []string) (map[string]*models.CachedArtist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]*models.CachedArtist, len(artistIDs))
+	for _, id := range artistIDs {
+		if a, ok := m.artists[id]; ok {
+			copied := *a
+			out[id] = &copied
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) UpsertArtist(artist *services.Artist) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cached := &models.CachedArtist{
+		ArtistID:      artist.ID,
+		Name:          artist.Name,
+		Genres:        artist.Genres,
+		LastRefreshed: time.Now(),
+	}
+	if len(artist.Images) > 0 {
+		cached.ImageURL = artist.Images[0].URL
+	}
+	m.artists[artist.ID] = cached
+	return nil
+}
+
+func (m *Memory) TrackCountSince(since time.Time) (int, error) {
+	count := 0
+	for _, p := range m.Plays() {
+		if !p.PlayedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// The memory store has no ISRC mapping, so canonical grouping is the same as
+// grouping by spotify id
+func (m *Memory) TopTracks(from, to *time.Time, limit int, canonical bool, source string) ([]repository.TopTrack, error) {
+	byID := map[string]*repository.TopTrack{}
+	var order []string
+	for _, p := range m.Plays() {
+		// TopTracks treats "to" as inclusive, like the SQL it replaces
+		if (from != nil && p.PlayedAt.Before(*from)) || (to != nil && p.PlayedAt.After(*to)) {
+			continue
+		}
+		if source != "" && p.Source != source {
+			continue
+		}
+		t, ok := byID[p.SpotifyID]
+		if !ok {
+			t = &repository.TopTrack{SpotifyID: p.SpotifyID}
+			byID[p.SpotifyID] = t
+			order = append(order, p.SpotifyID)
+		}
+		t.TrackName, t.ArtistName, t.AlbumName, t.AlbumCoverURL = p.TrackName, p.ArtistName, p.AlbumName, p.AlbumCoverURL
+		t.PlayCount++
+		t.TotalMs += int64(p.DurationMs)
+	}
+
+	tracks := make([]repository.TopTrack, 0, len(order))
+	for _, id := range order {
+		tracks = append(tracks, *byID[id])
+	}
+	sort.SliceStable(tracks, func(i, j int) bool { return tracks[i].PlayCount > tracks[j].PlayCount })
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks, nil
+}
+
+func (m *Memory) MostPlayed(from, to *time.Time, limit int, canonical bool, source string, dedupWindow time.Duration) ([]models.MostPlayedTrack, error) {
+	byID := map[string]*models.MostPlayedTrack{}
+	var order []string
+	previous := map[string]time.Time{} // last play of each track, any source
+	for _, p := range m.Plays() {
+		last, seen := previous[p.SpotifyID]
+		previous[p.SpotifyID] = p.PlayedAt
+		if dedupWindow > 0 && seen && p.PlayedAt.Sub(last) <= dedupWindow {
+			continue
+		}
+		if (from != nil && p.PlayedAt.Before(*from)) || (to != nil && !p.PlayedAt.Before(*to)) {
+			continue
+		}
+		if source != "" && p.Source != source {
+			continue
+		}
+		t, ok := byID[p.SpotifyID]
+		if !ok {
+			t = &models.MostPlayedTrack{SpotifySongID: p.SpotifyID, FirstPlayed: p.PlayedAt}
+			byID[p.SpotifyID] = t
+			order = append(order, p.SpotifyID)
+		}
+		t.TrackName, t.ArtistName, t.AlbumName = p.TrackName, p.ArtistName, p.AlbumName
+		t.AlbumCoverUrl, t.Genre = p.AlbumCoverURL, p.Genre
+		t.PlayCount++
+		t.TotalMs += int64(p.DurationMs)
+		t.LastPlayed = p.PlayedAt
+	}
+
+	tracks := make([]models.MostPlayedTrack, 0, len(order))
+	for _, id := range order {
+		tracks = append(tracks, *byID[id])
+	}
+	sort.SliceStable(tracks, func(i, j int) bool {
+		if tracks[i].PlayCount != tracks[j].PlayCount {
+			return tracks[i].PlayCount > tracks[j].PlayCount
+		}
+		return tracks[i].LastPlayed.After(tracks[j].LastPlayed)
+	})
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks, nil
+}
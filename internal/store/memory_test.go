@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryMostPlayedDedup(t *testing.T) {
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	for _, p := range []Play{
+		{SpotifyID: "a", PlayedAt: base},
+		{SpotifyID: "a", PlayedAt: base.Add(4 * time.Second)},  // repeat report
+		{SpotifyID: "a", PlayedAt: base.Add(8 * time.Second)},  // still the same run
+		{SpotifyID: "a", PlayedAt: base.Add(5 * time.Minute)},  // a real replay
+		{SpotifyID: "b", PlayedAt: base.Add(2 * time.Second)},  // another track in between
+		{SpotifyID: "b", PlayedAt: base.Add(10 * time.Minute)}, // a real replay
+	} {
+		if _, err := m.InsertRecentlyPlayed(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		from   *time.Time
+		window time.Duration
+		want   map[string]int
+	}{
+		{"dedup", nil, 10 * time.Second, map[string]int{"a": 2, "b": 2}},
+		{"every play", nil, 0, map[string]int{"a": 4, "b": 2}},
+		// a duplicate whose first report is before the range still isn't counted
+		{"range starts mid-run", ptr(base.Add(time.Second)), 10 * time.Second, map[string]int{"a": 1, "b": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks, err := m.MostPlayed(tt.from, nil, 10, false, "", tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int{}
+			for _, tr := range tracks {
+				got[tr.SpotifySongID] = tr.PlayCount
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("counts = %v, want %v", got, tt.want)
+			}
+			for id, n := range tt.want {
+				if got[id] != n {
+					t.Errorf("counts = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time { return &t }
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+)
+
+// Postgres implements Store on top of the repository and models packages,
+// which share repository.Pool
+type Postgres struct{}
+
+var _ Store = Postgres{}
+
+func (Postgres) GetRefreshToken() (string, error) {
+	return repository.GetRefreshToken()
+}
+
+func (Postgres) SaveRefreshToken(token string) error {
+	return repository.SaveOrUpdateRefreshToken(token)
+}
+
+func (Postgres) SaveSpotifyAccount(a repository.SpotifyAccount) error {
+	return repository.SaveSpotifyAccount(a)
+}
+
+func (Postgres) LatestPlayedAt() (time.Time, error) {
+	return repository.GetLatestPlayedAt()
+}
+
+// InsertRecentlyPlayed writes the play, then its artists, genres, playback context, release date and metadata.
+// Plays already stored are ignored, so replaying a play is safe; inserted
+// says whether this one was new.
+func (Postgres) InsertRecentlyPlayed(p Play) (inserted bool, err error) {
+	source := p.Source
+	if source == "" {
+		source = models.SourceCron
+	}
+	inserted, err = models.InsertRecentlyPlayed(context.Background(), models.RecentlyPlayedTrack{
+		SpotifySongID: p.SpotifyID,
+		TrackName:     p.TrackName,
+		ArtistName:    p.ArtistName,
+		AlbumName:     p.AlbumName,
+		AlbumCoverUrl: p.AlbumCoverURL,
+		Genre:         p.Genre,
+		DurationMS:    p.DurationMs,
+		PlayedAt:      p.PlayedAt,
+		Source:        source,
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(p.Artists) > 0 {
+		if err := models.SetPlayArtists(p.SpotifyID, p.PlayedAt, p.Artists, p.ArtistName); err != nil {
+			return inserted, err
+		}
+	}
+	if err := models.SetTrackGenres(p.SpotifyID, p.Genres); err != nil {
+		return inserted, err
+	}
+	if err := models.SetPlayContext(p.SpotifyID, p.PlayedAt, p.ContextType, p.ContextURI); err != nil {
+		return inserted, err
+	}
+	if err := models.SetPlayReleaseDate(p.SpotifyID, p.PlayedAt, p.AlbumReleaseDate, p.AlbumReleaseDatePrecision); err != nil {
+		return inserted, err
+	}
+	return inserted, models.SetPlayMetadata(p.SpotifyID, p.PlayedAt, p.Metadata)
+}
+
+// InsertRecentlyPlayedBatch writes every play with its details in one round trip
+func (Postgres) InsertRecentlyPlayedBatch(plays []Play) ([]Play, error) {
+	rows := make([]models.RecentlyPlayedRow, len(plays))
+	for i, p := range plays {
+		rows[i] = models.RecentlyPlayedRow{
+			Source:        p.Source,
+			SpotifyID:     p.SpotifyID,
+			TrackName:     p.TrackName,
+			ArtistName:    p.ArtistName,
+			AlbumName:     p.AlbumName,
+			AlbumCoverURL: p.AlbumCoverURL,
+			Genre:         p.Genre,
+			Genres:        p.Genres,
+			DurationMs:    p.DurationMs,
+			PlayedAt:      p.PlayedAt,
+			ContextType:   p.ContextType,
+			ContextURI:    p.ContextURI,
+			Artists:       p.Artists,
+			Metadata:      p.Metadata,
+
+			AlbumReleaseDate:          p.AlbumReleaseDate,
+			AlbumReleaseDatePrecision: p.AlbumReleaseDatePrecision,
+		}
+	}
+	inserted, err := models.InsertRecentlyPlayedBatch(rows)
+	if err != nil {
+		return nil, err
+	}
+	var added []Play
+	for i, ok := range inserted {
+		if ok {
+			added = append(added, plays[i])
+		}
+	}
+	return added, nil
+}
+
+func (Postgres) HasPlayNear(spotifyID string, at time.Time, window time.Duration) (bool, error) {
+	return repository.HasPlayNear(spotifyID, at, window)
+}
+
+func (Postgres) RecentlyPlayed(source string) ([]models.RecentlyPlayedTrack, error) {
+	return models.GetAllRecentPlayedHistory(repository.Pool, source)
+}
+
+func (Postgres) LatestAddedAt() (time.Time, error) {
+	return repository.GetLatestAddedAt()
+}
+
+func (Postgres) InsertRecentlyLiked(t LikedTrack) (bool, error) {
+	source := t.Source
+	if source == "" {
+		source = models.SourceCron
+	}
+	inserted, err := models.InsertRecentlyLiked(
+		source,
+		t.SpotifyID,
+		t.TrackName,
+		t.TrackPopularity,
+		t.AlbumName,
+		t.AlbumType,
+		t.AlbumCoverURL,
+		t.AlbumReleaseDate,
+		t.AlbumReleaseDatePrecision,
+		t.ArtistName,
+		t.ArtistID,
+		t.ArtistHref,
+		t.ArtistURI,
+		t.AlbumTotalTracks,
+		t.AlbumCoverWidth,
+		t.AlbumCoverHeight,
+		t.AddedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return inserted, models.SetLikedMetadata(t.SpotifyID, t.Metadata)
+}
+
+func (Postgres) ReconcileLiked(present []string, at time.Time) (int, int, error) {
+	return models.ReconcileRecentlyLiked(repository.Pool, present, at)
+}
+
+func (Postgres) AttachPlaybackState() (int, error) {
+	return models.AttachPlaybackState(repository.Pool)
+}
+
+func (Postgres) GetCachedArtist(artistID string) (*models.CachedArtist, error) {
+	return models.GetCachedArtist(artistID)
+}
+
+func (Postgres) GetCachedArtists(artistIDs []string) (map[string]*models.CachedArtist, error) {
+	return models.GetCachedArtists(artistIDs)
+}
+
+func (Postgres) UpsertArtist(artist *services.Artist) error {
+	return models.UpsertArtist(artist)
+}
+
+func (Postgres) TrackCountSince(since time.Time) (int, error) {
+	return repository.GetTrackCountSince(since)
+}
+
+func (Postgres) TopTracks(from, to *time.Time, limit int, canonical bool, source string) ([]repository.TopTrack, error) {
+	return repository.GetTopTracks(from, to, limit, canonical, source)
+}
+
+func (Postgres) MostPlayed(from, to *time.Time, limit int, canonical bool, source string, dedupWindow time.Duration) ([]models.MostPlayedTrack, error) {
+	return models.GetMostPlayedFromHistory(repository.Pool, from, to, limit, canonical, source, dedupWindow)
+}
@@ -0,0 +1,120 @@
+// Package store abstracts the database behind interfaces so handlers and the
+// cron collectors can run against an in-memory fake. Postgres is the real
+// implementation; Memory is the fake.
+package store
+
+import (
+	"time"
+
+	"example.com/spotifydb/internal/models"
+	"example.com/spotifydb/internal/repository"
+	"example.com/spotifydb/internal/services"
+)
+
+// Play is one recently-played row plus the genres, context and catalogue
+// metadata recorded with it
+type Play struct {
+	Source        string    `json:"source"` // models.SourceCron when empty
+	SpotifyID     string    `json:"spotify_song_id"`
+	TrackName     string    `json:"track_name"`
+	ArtistName    string    `json:"artist_name"`
+	AlbumName     string    `json:"album_name"`
+	AlbumCoverURL string    `json:"album_cover_url"`
+	Genre         string    `json:"genre"`
+	Genres        []string  `json:"genres,omitempty"`
+	DurationMs    int       `json:"duration_ms"`
+	PlayedAt      time.Time `json:"played_at"`
+	ContextType   string    `json:"context_type,omitempty"`
+	ContextURI    string    `json:"context_uri,omitempty"`
+
+	// AlbumReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD, per AlbumReleaseDatePrecision
+	AlbumReleaseDate          string `json:"album_release_date,omitempty"`
+	AlbumReleaseDatePrecision string `json:"album_release_date_precision,omitempty"`
+
+	// Artists credits every artist on the track, primary first
+	Artists  []models.PlayArtist    `json:"artists,omitempty"`
+	Metadata services.TrackMetadata `json:"metadata"`
+}
+
+// LikedTrack is one recently_liked row
+type LikedTrack struct {
+	Source                    string // models.SourceCron when empty
+	SpotifyID                 string
+	TrackName                 string
+	TrackPopularity           string
+	AlbumName                 string
+	AlbumType                 string
+	AlbumCoverURL             string
+	AlbumReleaseDate          string
+	AlbumReleaseDatePrecision string
+	ArtistName                string
+	ArtistID                  string
+	ArtistHref                string
+	ArtistURI                 string
+	AlbumTotalTracks          int
+	AlbumCoverWidth           int
+	AlbumCoverHeight          int
+	AddedAt                   time.Time
+	UnlikedAt                 *time.Time // set once the track is no longer saved on Spotify
+	Metadata                  services.TrackMetadata
+}
+
+// AuthStore keeps the Spotify refresh token and the account it belongs to
+type AuthStore interface {
+	GetRefreshToken() (string, error)
+	SaveRefreshToken(token string) error
+	// SaveSpotifyAccount records the profile and granted scopes of the
+	// stored token's account
+	SaveSpotifyAccount(a repository.SpotifyAccount) error
+}
+
+// TrackStore reads and writes listening history and saved tracks
+type TrackStore interface {
+	LatestPlayedAt() (time.Time, error)
+	// InsertRecentlyPlayed writes one play; inserted is false when it was
+	// already stored
+	InsertRecentlyPlayed(p Play) (inserted bool, err error)
+	// InsertRecentlyPlayedBatch writes all plays at once, all or nothing, and
+	// returns the ones that weren't already stored
+	InsertRecentlyPlayedBatch(plays []Play) (inserted []Play, err error)
+	// HasPlayNear reports whether a play of the track is stored within window of at
+	HasPlayNear(spotifyID string, at time.Time, window time.Duration) (bool, error)
+	// RecentlyPlayed returns plays newest first, only those stored by source
+	// unless it's empty
+	RecentlyPlayed(source string) ([]models.RecentlyPlayedTrack, error)
+	LatestAddedAt() (time.Time, error)
+	// InsertRecentlyLiked stores a saved track, or refreshes an existing one
+	// when this save is newer; inserted is false for an existing track
+	InsertRecentlyLiked(t LikedTrack) (inserted bool, err error)
+	// ReconcileLiked soft-deletes liked tracks missing from present (every id
+	// currently saved on Spotify) and restores removed ones that reappear
+	ReconcileLiked(present []string, at time.Time) (removed, restored int, err error)
+	// AttachPlaybackState copies device/shuffle/repeat from player snapshots onto recent plays
+	AttachPlaybackState() (int, error)
+}
+
+// ArtistStore caches artist metadata
+type ArtistStore interface {
+	GetCachedArtist(artistID string) (*models.CachedArtist, error)
+	// GetCachedArtists looks up many artists at once; unknown ids are absent
+	GetCachedArtists(artistIDs []string) (map[string]*models.CachedArtist, error)
+	UpsertArtist(artist *services.Artist) error
+}
+
+// StatsStore serves aggregate reads over the listening history
+type StatsStore interface {
+	TrackCountSince(since time.Time) (int, error)
+	// canonical groups variants of the same recording (see canonical_tracks);
+	// a non-empty source counts only plays stored by it
+	TopTracks(from, to *time.Time, limit int, canonical bool, source string) ([]repository.TopTrack, error)
+	// a play within dedupWindow of the track's previous play isn't counted
+	MostPlayed(from, to *time.Time, limit int, canonical bool, source string, dedupWindow time.Duration) ([]models.MostPlayedTrack, error)
+}
+
+// Store is everything the handlers and collectors need from the database
+type Store interface {
+	AuthStore
+	TrackStore
+	ArtistStore
+	StatsStore
+}
@@ -1,73 +1,195 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 )
 
-// RateLimiter handles Spotify API rate limiting
+// RateLimiter handles Spotify API rate limiting. It is a token bucket that
+// refills at maxRequestsPerMinute and is safe to share between goroutines.
 type RateLimiter struct {
-	requestCount     int
-	windowStart      time.Time
+	mu                   sync.Mutex
+	clock                Clock
+	tokens               float64
+	lastRefill           time.Time
 	maxRequestsPerMinute int
-	backoffMultiplier   float64
-	maxBackoffSeconds   int
+	burst                int
+	backoffMultiplier    float64
+	maxBackoffSeconds    int
+}
+
+// Reservation is a token taken from the bucket that may only be used after Delay
+type Reservation struct {
+	rl        *RateLimiter
+	timeToAct time.Time
 }
 
 // NewRateLimiter creates a new rate limiter
 // Spotify allows ~100 requests per minute, we'll be conservative with 60
 func NewRateLimiter() *RateLimiter {
+	return NewRateLimiterWithBudget(60) // Conservative limit
+}
+
+// NewRateLimiterWithBudget creates a limiter allowing requestsPerMinute on
+// average, with bursts of up to ten seconds' worth of requests
+func NewRateLimiterWithBudget(requestsPerMinute int) *RateLimiter {
+	return NewRateLimiterWithClock(requestsPerMinute, RealClock{})
+}
+
+// NewRateLimiterWithClock is NewRateLimiterWithBudget driven by clock
+func NewRateLimiterWithClock(requestsPerMinute int, clock Clock) *RateLimiter {
+	if requestsPerMinute < 1 {
+		requestsPerMinute = 1
+	}
+	burst := requestsPerMinute / 6
+	if burst < 1 {
+		burst = 1
+	}
 	return &RateLimiter{
-		requestCount:         0,
-		windowStart:         time.Now(),
-		maxRequestsPerMinute: 60, // Conservative limit
-		backoffMultiplier:   2.0,
-		maxBackoffSeconds:   60,
+		clock:                clock,
+		tokens:               float64(burst),
+		lastRefill:           clock.Now(),
+		maxRequestsPerMinute: requestsPerMinute,
+		burst:                burst,
+		backoffMultiplier:    2.0,
+		maxBackoffSeconds:    60,
 	}
 }
 
-// Wait blocks if we're approaching rate limits
-func (rl *RateLimiter) Wait() {
-	now := time.Now()
-	
-	// Reset counter if we've moved to a new minute
-	if now.Sub(rl.windowStart) >= time.Minute {
-		rl.requestCount = 0
-		rl.windowStart = now
-	}
-	
-	// If we're approaching the limit, wait
-	if rl.requestCount >= rl.maxRequestsPerMinute {
-		waitTime := time.Until(rl.windowStart.Add(time.Minute))
-		if waitTime > 0 {
-			fmt.Printf("🐌 Rate limit protection: waiting %v before next request\n", waitTime.Round(time.Second))
-			time.Sleep(waitTime)
-			// Reset after waiting
-			rl.requestCount = 0
-			rl.windowStart = time.Now()
+// perSecond is the refill rate
+func (rl *RateLimiter) perSecond() float64 {
+	return float64(rl.maxRequestsPerMinute) / 60
+}
+
+// refill adds the tokens earned since the last call; callers hold rl.mu
+func (rl *RateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(rl.lastRefill); elapsed > 0 {
+		rl.tokens += elapsed.Seconds() * rl.perSecond()
+		if rl.tokens > float64(rl.burst) {
+			rl.tokens = float64(rl.burst)
 		}
+		rl.lastRefill = now
+	}
+}
+
+// Allow takes a token if one is available right now, without waiting
+func (rl *RateLimiter) Allow() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.refill(rl.clock.Now())
+	if rl.tokens < 1 {
+		return false
+	}
+	rl.tokens--
+	return true
+}
+
+// Available returns how many requests could be made right now without waiting
+func (rl *RateLimiter) Available() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.refill(rl.clock.Now())
+	if rl.tokens < 1 {
+		return 0
+	}
+	return int(rl.tokens)
+}
+
+// Reserve takes a token unconditionally, going into debt if the bucket is
+// empty. The caller must wait Delay() before making the request, or Cancel().
+func (rl *RateLimiter) Reserve() *Reservation {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	rl.refill(now)
+	rl.tokens--
+
+	r := &Reservation{rl: rl, timeToAct: now}
+	if rl.tokens < 0 {
+		r.timeToAct = now.Add(time.Duration(-rl.tokens / rl.perSecond() * float64(time.Second)))
+	}
+	return r
+}
+
+// Delay is how long to wait before acting on the reservation
+func (r *Reservation) Delay() time.Duration {
+	if d := r.timeToAct.Sub(r.rl.clock.Now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Cancel gives the token back if the reservation hasn't come due yet,
+// e.g. when the request is abandoned
+func (r *Reservation) Cancel() {
+	if r.rl == nil {
+		return
+	}
+	r.rl.mu.Lock()
+	defer r.rl.mu.Unlock()
+
+	now := r.rl.clock.Now()
+	if !r.timeToAct.After(now) {
+		r.rl = nil
+		return
+	}
+	r.rl.refill(now)
+	r.rl.tokens++
+	if r.rl.tokens > float64(r.rl.burst) {
+		r.rl.tokens = float64(r.rl.burst)
+	}
+	r.rl = nil
+}
+
+// Wait blocks until the budget allows another request. Concurrent callers
+// queue up behind each other's reservations.
+func (rl *RateLimiter) Wait() {
+	waitTime := rl.Reserve().Delay()
+	if waitTime > time.Second {
+		fmt.Printf("🐌 Rate limit protection: waiting %v before next request\n", waitTime.Round(time.Second))
+	}
+	rl.clock.Sleep(waitTime)
+}
+
+// WaitContext is Wait that gives up when ctx is done first, handing the
+// reserved token back so an abandoned request doesn't cost the budget
+func (rl *RateLimiter) WaitContext(ctx context.Context) error {
+	r := rl.Reserve()
+	waitTime := r.Delay()
+	if waitTime == 0 {
+		return nil
+	}
+	if waitTime > time.Second {
+		fmt.Printf("🐌 Rate limit protection: waiting %v before next request\n", waitTime.Round(time.Second))
+	}
+	select {
+	case <-rl.clock.After(waitTime):
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
 	}
-	
-	rl.requestCount++
-	
-	// Add small delay between requests regardless
-	time.Sleep(100 * time.Millisecond)
 }
 
 // HandleRateLimit handles 429 responses with exponential backoff
 func (rl *RateLimiter) HandleRateLimit(retryAfterHeader string, attempt int) time.Duration {
 	var waitTime time.Duration
-	
+
 	// Try to parse Retry-After header
 	if retryAfterHeader != "" {
 		if seconds, err := strconv.Atoi(retryAfterHeader); err == nil {
 			waitTime = time.Duration(seconds) * time.Second
 		}
 	}
-	
+
 	// If no Retry-After header, use exponential backoff
 	if waitTime == 0 {
 		backoffSeconds := int(math.Min(
@@ -76,49 +198,63 @@ func (rl *RateLimiter) HandleRateLimit(retryAfterHeader string, attempt int) tim
 		))
 		waitTime = time.Duration(backoffSeconds) * time.Second
 	}
-	
+
 	fmt.Printf("⏳ Rate limited! Waiting %v (attempt %d)\n", waitTime.Round(time.Second), attempt+1)
 	return waitTime
 }
 
-// IsRateLimitError checks if an error is a rate limit error
+// RateLimitError is an error that knows whether it's a 429 and how long the
+// server asked to wait, like services.SpotifyError. utils can't import
+// services, so errors are matched by behaviour rather than by type.
+type RateLimitError interface {
+	error
+	RateLimited() bool
+	RetryDelay() time.Duration
+}
+
+// IsRateLimitError reports whether err is or wraps a rate limit error
 func IsRateLimitError(err error) bool {
-	if err == nil {
-		return false
+	var rlErr RateLimitError
+	return errors.As(err, &rlErr) && rlErr.RateLimited()
+}
+
+// RetryAfterHeader returns the wait a rate limit error in err asked for, in
+// whole seconds as HandleRateLimit takes it, or "" when it didn't say
+func RetryAfterHeader(err error) string {
+	var rlErr RateLimitError
+	if !errors.As(err, &rlErr) || rlErr.RetryDelay() <= 0 {
+		return ""
 	}
-	errStr := strings.ToLower(err.Error())
-	return strings.Contains(errStr, "429") || 
-		   strings.Contains(errStr, "rate limit") ||
-		   strings.Contains(errStr, "too many requests")
+	return strconv.Itoa(int(math.Ceil(rlErr.RetryDelay().Seconds())))
 }
 
 // RetryWithBackoff executes a function with retry logic for rate limits
 func (rl *RateLimiter) RetryWithBackoff(operation func() error, maxRetries int) error {
 	var lastErr error
-	
+
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		// Wait for rate limiting before each attempt
 		rl.Wait()
-		
+
 		err := operation()
 		if err == nil {
 			return nil // Success!
 		}
-		
+
 		lastErr = err
-		
+
 		// If it's a rate limit error, wait and retry
 		if IsRateLimitError(err) && attempt < maxRetries {
-			waitTime := rl.HandleRateLimit("", attempt)
-			time.Sleep(waitTime)
+			waitTime := rl.HandleRateLimit(RetryAfterHeader(err), attempt)
+			rl.clock.Sleep(waitTime)
 			continue
 		}
-		
+
 		// If it's not a rate limit error, don't retry
 		if !IsRateLimitError(err) {
 			return err
 		}
 	}
-	
-	return fmt.Errorf("max retries exceeded: %v", lastErr)
-}
\ No newline at end of file
+
+	return fmt.Errorf("max retries exceeded: %w", lastErr)
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to. Sleep moves it, as if
+// the sleeper had waited; After fires once Advance passes its deadline.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	slept   time.Duration
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	c.slept += d
+	c.mu.Unlock()
+	c.Advance(d)
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// waiting is how many After channels haven't fired
+func (c *fakeClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func TestBurstAndRefill(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRateLimiterWithClock(60, clock) // 1/s, burst 10
+
+	if got := rl.Available(); got != 10 {
+		t.Errorf("Available() = %d on a fresh limiter, want the burst of 10", got)
+	}
+	for i := 0; i < 10; i++ {
+		if !rl.Allow() {
+			t.Fatalf("Allow() refused request %d of the burst", i)
+		}
+	}
+	if rl.Allow() {
+		t.Error("Allow() past the burst")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if rl.Allow() {
+		t.Error("Allow() with half a token refilled")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if !rl.Allow() {
+		t.Error("Allow() refused after a second's refill")
+	}
+
+	// refilling stops at the burst
+	clock.Advance(time.Hour)
+	if got := rl.Available(); got != 10 {
+		t.Errorf("Available() = %d after an hour idle, want 10", got)
+	}
+}
+
+func TestBudgetBounds(t *testing.T) {
+	tests := []struct {
+		rpm       int
+		wantBurst int
+	}{
+		{600, 100},
+		{60, 10},
+		{5, 1}, // at least one
+		{0, 1}, // taken as 1 a minute
+	}
+	for _, tt := range tests {
+		rl := NewRateLimiterWithClock(tt.rpm, newFakeClock())
+		if got := rl.Available(); got != tt.wantBurst {
+			t.Errorf("NewRateLimiterWithClock(%d): burst %d, want %d", tt.rpm, got, tt.wantBurst)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRateLimiterWithClock(60, clock)
+	for rl.Allow() {
+	}
+
+	// each reservation queues a second behind the last
+	first, second := rl.Reserve(), rl.Reserve()
+	if d := first.Delay(); d != time.Second {
+		t.Errorf("first Delay() = %v, want 1s", d)
+	}
+	if d := second.Delay(); d != 2*time.Second {
+		t.Errorf("second Delay() = %v, want 2s", d)
+	}
+
+	// canceling the second gives its slot back to the next caller
+	second.Cancel()
+	if d := rl.Reserve().Delay(); d != 2*time.Second {
+		t.Errorf("Delay() after a cancel = %v, want 2s", d)
+	}
+
+	clock.Advance(time.Second)
+	if d := first.Delay(); d != 0 {
+		t.Errorf("Delay() once due = %v, want 0", d)
+	}
+	// a due reservation was used, so canceling it gives nothing back
+	first.Cancel()
+	if rl.Allow() {
+		t.Error("canceling a due reservation returned its token")
+	}
+	first.Cancel() // twice is harmless
+}
+
+func TestWait(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRateLimiterWithClock(120, clock) // 2/s, burst 20
+
+	for i := 0; i < 20; i++ {
+		rl.Wait()
+	}
+	if clock.slept != 0 {
+		t.Errorf("slept %v within the burst, want none", clock.slept)
+	}
+	for i := 0; i < 10; i++ {
+		rl.Wait()
+	}
+	if clock.slept != 5*time.Second {
+		t.Errorf("slept %v for 10 requests past the burst at 2/s, want 5s", clock.slept)
+	}
+}
+
+type rateLimitErr struct{ retry time.Duration }
+
+func (e rateLimitErr) Error() string             { return "429" }
+func (e rateLimitErr) RateLimited() bool         { return true }
+func (e rateLimitErr) RetryDelay() time.Duration { return e.retry }
+
+func TestRetryWithBackoff(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRateLimiterWithClock(600, clock)
+
+	calls := 0
+	err := rl.RetryWithBackoff(func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("get tracks: %w", rateLimitErr{retry: 7 * time.Second})
+		}
+		return nil
+	}, 5)
+	if err != nil || calls != 3 {
+		t.Errorf("RetryWithBackoff() = %v after %d calls, want nil after 3", err, calls)
+	}
+	if clock.slept != 14*time.Second {
+		t.Errorf("slept %v, want the two Retry-After waits of 7s", clock.slept)
+	}
+
+	calls = 0
+	other := errors.New("boom")
+	if err := rl.RetryWithBackoff(func() error { calls++; return other }, 5); err != other || calls != 1 {
+		t.Errorf("RetryWithBackoff() = %v after %d calls, want %v after 1", err, calls, other)
+	}
+
+	calls = 0
+	err = rl.RetryWithBackoff(func() error { calls++; return rateLimitErr{} }, 2)
+	if !IsRateLimitError(err) || calls != 3 {
+		t.Errorf("RetryWithBackoff() = %v after %d calls, want a rate limit error after 3", err, calls)
+	}
+}
+
+func TestWaitContext(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRateLimiterWithClock(60, clock) // 1/s, burst 10
+	for i := 0; i < 10; i++ {
+		if err := rl.WaitContext(context.Background()); err != nil {
+			t.Fatalf("burst request %d: %v", i, err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- rl.WaitContext(context.Background()) }()
+	waitForWaiters(t, clock, 1)
+	select {
+	case err := <-done:
+		t.Fatalf("WaitContext returned %v with the bucket empty", err)
+	default:
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("WaitContext() = %v after the refill, want nil", err)
+	}
+}
+
+func TestWaitContextCanceled(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRateLimiterWithClock(60, clock)
+	for rl.Allow() {
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- rl.WaitContext(ctx) }()
+	waitForWaiters(t, clock, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitContext() = %v, want context.Canceled", err)
+	}
+
+	// the abandoned reservation gave its token back: one second later
+	// there's a token again rather than a debt
+	clock.Advance(time.Second)
+	if !rl.Allow() {
+		t.Error("canceled wait kept its token")
+	}
+}
+
+func waitForWaiters(t *testing.T, clock *fakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for clock.waiting() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines waiting on the clock, want %d", clock.waiting(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"example.com/spotifydb/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const webhookColumns = `id, url, events, COALESCE(secret, ''), created_at,
+	COALESCE(last_status, 0), COALESCE(last_error, ''), last_delivered_at`
+
+func scanWebhook(row pgx.Row) (*Webhook, error) {
+	var h Webhook
+	if err := row.Scan(&h.ID, &h.URL, &h.Events, &h.secret, &h.CreatedAt,
+		&h.LastStatus, &h.LastError, &h.LastDeliveredAt); err != nil {
+		return nil, err
+	}
+	h.HasSecret = h.secret != ""
+	return &h, nil
+}
+
+// load reads every webhook; nothing is registered while there's no database
+func load() ([]Webhook, error) {
+	if repository.Pool == nil {
+		return nil, nil
+	}
+	return List()
+}
+
+// List returns every registered webhook, oldest first
+func List() ([]Webhook, error) {
+	rows, err := repository.Pool.Query(context.Background(),
+		`SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %v", err)
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		h, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *h)
+	}
+	return hooks, rows.Err()
+}
+
+// Get returns one webhook, or nil if there's no such id
+func Get(id int) (*Webhook, error) {
+	h, err := scanWebhook(repository.Pool.QueryRow(context.Background(),
+		`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook %d: %v", id, err)
+	}
+	return h, nil
+}
+
+// Create registers a webhook for events (empty for all of them)
+func Create(url string, events []string, secret string) (*Webhook, error) {
+	if events == nil {
+		events = []string{}
+	}
+	h, err := scanWebhook(repository.Pool.QueryRow(context.Background(), `
+		INSERT INTO webhooks (url, events, secret)
+		VALUES ($1, $2, NULLIF($3, ''))
+		RETURNING `+webhookColumns, url, events, secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %v", err)
+	}
+	invalidate()
+	return h, nil
+}
+
+// Delete removes a webhook, reporting whether it existed
+func Delete(id int) (bool, error) {
+	tag, err := repository.Pool.Exec(context.Background(), `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook %d: %v", id, err)
+	}
+	invalidate()
+	return tag.RowsAffected() > 0, nil
+}
+
+// recordDelivery stores the outcome of the latest delivery. Failures are
+// logged, not returned: the event has already been sent or lost.
+func recordDelivery(id, status int, deliveryErr error) {
+	if repository.Pool == nil {
+		return
+	}
+	var errMsg *string
+	if deliveryErr != nil {
+		msg := deliveryErr.Error()
+		errMsg = &msg
+	}
+	_, err := repository.Pool.Exec(context.Background(), `
+		UPDATE webhooks
+		SET last_status = NULLIF($2, 0), last_error = $3, last_delivered_at = NOW()
+		WHERE id = $1`, id, status, errMsg)
+	if err != nil {
+		fmt.Printf("⚠️  webhooks: failed to record delivery for %d: %v\n", id, err)
+	}
+}
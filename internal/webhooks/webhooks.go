@@ -0,0 +1,226 @@
+// Package webhooks POSTs collection events (new plays, newly seen artists,
+// a collector that keeps failing) to the URLs registered in the webhooks
+// table. Bodies carry "content" and "text" next to the event so Discord and
+// Slack incoming webhooks can be registered as-is.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event types a webhook can subscribe to
+const (
+	EventTracksCollected   = "tracks.collected"
+	EventArtistDiscovered  = "artist.discovered"
+	EventCollectionFailing = "collection.failing"
+	EventPing              = "ping" // sent by POST /admin/webhooks/:id/test
+)
+
+// Events lists the types accepted when registering a webhook
+var Events = []string{EventTracksCollected, EventArtistDiscovered, EventCollectionFailing}
+
+// IsEvent reports whether name is a subscribable event type
+func IsEvent(name string) bool {
+	for _, e := range Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook is a registered endpoint
+type Webhook struct {
+	ID              int        `json:"id"`
+	URL             string     `json:"url"`
+	Events          []string   `json:"events"` // empty means every event
+	HasSecret       bool       `json:"has_secret"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastStatus      int        `json:"last_status,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty"`
+
+	secret string
+}
+
+// Wants reports whether the webhook subscribes to event
+func (w Webhook) Wants(event string) bool {
+	if len(w.Events) == 0 || event == EventPing {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectedTrack is one new play in a tracks.collected event
+type CollectedTrack struct {
+	SpotifyID  string    `json:"spotify_id"`
+	TrackName  string    `json:"track_name"`
+	ArtistName string    `json:"artist_name"`
+	AlbumName  string    `json:"album_name"`
+	PlayedAt   time.Time `json:"played_at"`
+}
+
+// TracksCollected is the data of a tracks.collected event, sent once per
+// collection cycle that stored new plays
+type TracksCollected struct {
+	Count  int              `json:"count"`
+	Tracks []CollectedTrack `json:"tracks"`
+}
+
+// ArtistDiscovered is the data of an artist.discovered event, sent the first
+// time a play by the artist is collected
+type ArtistDiscovered struct {
+	ArtistID   string   `json:"artist_id"`
+	Name       string   `json:"name"`
+	Genres     []string `json:"genres"`
+	SpotifyURL string   `json:"spotify_url"`
+}
+
+// CollectionFailing is the data of a collection.failing event
+type CollectionFailing struct {
+	Collector string `json:"collector"`
+	Failures  int    `json:"failures"`
+	Error     string `json:"error"`
+}
+
+// Event is the JSON body POSTed to a webhook
+type Event struct {
+	Type    string    `json:"type"`
+	At      time.Time `json:"at"`
+	Data    any       `json:"data"`
+	Content string    `json:"content"` // shown by Discord
+	Text    string    `json:"text"`    // shown by Slack
+}
+
+// Discord rejects messages over 2000 characters
+const maxSummary = 2000
+
+// NewEvent builds an event; summary is the line chat apps display
+func NewEvent(eventType, summary string, data any) Event {
+	if r := []rune(summary); len(r) > maxSummary {
+		summary = string(r[:maxSummary-1]) + "…"
+	}
+	return Event{Type: eventType, At: time.Now().UTC(), Data: data, Content: summary, Text: summary}
+}
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Emit delivers the event to every subscribed webhook in the background,
+// so a slow receiver never holds up collection
+func Emit(eventType, summary string, data any) {
+	hooks, err := subscribers(eventType)
+	if err != nil {
+		fmt.Printf("⚠️  webhooks: %v\n", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	event := NewEvent(eventType, summary, data)
+	go func() {
+		for _, h := range hooks {
+			if err := Deliver(h, event); err != nil {
+				fmt.Printf("⚠️  webhooks: %s to %s: %v\n", event.Type, h.URL, err)
+			}
+		}
+	}()
+}
+
+// Deliver POSTs the event to one webhook, retrying once on a network error,
+// 429 or 5xx, and records the outcome on the webhook's row. With a secret
+// the body is signed in X-Webhook-Signature as sha256=<hex HMAC>.
+func Deliver(h Webhook, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var status int
+	for attempt := 1; attempt <= 2; attempt++ {
+		status, err = post(h, event.Type, body)
+		if err == nil && status != http.StatusTooManyRequests && status < 500 {
+			break
+		}
+		if attempt == 1 {
+			time.Sleep(2 * time.Second)
+		}
+	}
+	if err == nil && (status < 200 || status >= 300) {
+		err = fmt.Errorf("returned %d %s", status, http.StatusText(status))
+	}
+	recordDelivery(h.ID, status, err)
+	return err
+}
+
+func post(h Webhook, eventType string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", eventType)
+	if h.secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.secret))
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	return res.StatusCode, nil
+}
+
+/* ---------- subscriber cache ---------- */
+
+// Events fire every collection cycle, so the webhook list is cached rather
+// than read per event. Changes through this package drop it immediately.
+const cacheTTL = time.Minute
+
+var cache struct {
+	sync.Mutex
+	hooks    []Webhook
+	loadedAt time.Time
+}
+
+func subscribers(event string) ([]Webhook, error) {
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.loadedAt.IsZero() || time.Since(cache.loadedAt) > cacheTTL {
+		hooks, err := load()
+		if err != nil {
+			return nil, err
+		}
+		cache.hooks, cache.loadedAt = hooks, time.Now()
+	}
+
+	var out []Webhook
+	for _, h := range cache.hooks {
+		if h.Wants(event) {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+func invalidate() {
+	cache.Lock()
+	cache.loadedAt = time.Time{}
+	cache.Unlock()
+}